@@ -1,7 +1,10 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"crypto/subtle"
+	"database/sql"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -11,20 +14,34 @@ import (
 	"time"
 
 	"chatsheet/config"
-	"chatsheet/internal/db"
+	"chatsheet/internal/cron"
+	dbpkg "chatsheet/internal/db"
+	"chatsheet/internal/envelope"
+	"chatsheet/internal/events"
 	"chatsheet/internal/handler"
+	"chatsheet/internal/health"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/jobs"
+	"chatsheet/internal/logging"
+	"chatsheet/internal/metrics"
+	"chatsheet/internal/model"
+	"chatsheet/internal/outbox"
 	"chatsheet/internal/repository/gormimpl"
+	"chatsheet/internal/repository/memory"
+	"chatsheet/internal/repository/redisimpl"
 	"chatsheet/internal/service"
+	"chatsheet/internal/tracing"
+	"chatsheet/internal/unipile"
 
 	"github.com/MatusOllah/slogcolor"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 	//_ "chatsheet/docs" // Swagger 產生的文件
 )
 
 func main() {
-	gin.ForceConsoleColor()
-
-	// 初始化結構化日誌
+	// 初始化結構化日誌 (載入配置之前使用預設格式)
 	slog.SetDefault(slog.New(slogcolor.NewHandler(os.Stderr, slogcolor.DefaultOptions)))
 
 	// 載入配置
@@ -33,10 +50,21 @@ func main() {
 		slog.Error("Failed to load configuration", "err", err)
 		os.Exit(1)
 	}
+
+	// 依 log 設定輸出格式，並帶上 request_id 等 context 欄位、隱藏敏感欄位
+	logHandler, err := logging.New(cfg.Log, os.Stderr)
+	if err != nil {
+		slog.Error("Failed to initialize logging", "err", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(slog.New(logHandler))
+	if cfg.Log.Format != "json" {
+		gin.ForceConsoleColor()
+	}
 	slog.Info("Configuration loaded successfully")
 
 	// 等待 DB 啟動
-	db, err := db.InitDB(cfg)
+	db, err := dbpkg.InitDB(cfg)
 	if err != nil {
 		slog.Error("Failed to connect to database", "err", err)
 		os.Exit(1)
@@ -48,20 +76,160 @@ func main() {
 		os.Exit(1)
 	}
 	defer sqlDB.Close()
+	if err := dbpkg.RegisterPoolMetrics(metrics.Registry, sqlDB, cfg.Database.Name); err != nil {
+		slog.Error("Failed to register database pool metrics", "err", err)
+		os.Exit(1)
+	}
+
+	// 分散式追蹤，exporter 為 none 時 tracerProvider 為 nil，不記錄任何 span
+	tracerProvider, err := tracing.New(cfg.Tracing)
+	if err != nil {
+		slog.Error("Failed to initialize tracing", "err", err)
+		os.Exit(1)
+	}
+
+	// 連線憑證加密使用的 KMS
+	kms, err := envelope.NewKMS(cfg.Crypto)
+	if err != nil {
+		slog.Error("Failed to initialize KMS", "err", err)
+		os.Exit(1)
+	}
 
 	// 依賴注入：組裝 Repository, Service, Handler
 	userRepo := gormimpl.NewUserRepository(db)
 	unipileRepo := gormimpl.NewUnipileRepository(db)
+	credRepo := gormimpl.NewCredentialRepository(db)
+	idemRepo := gormimpl.NewIdempotencyRepository(db)
+	auditRepo := gormimpl.NewAuditRepository(db)
+	messageRepo := gormimpl.NewMessageRepository(db)
+	chatRepo := gormimpl.NewChatRepository(db)
+	attendeeRepo := gormimpl.NewChatAttendeeRepository(db)
+	checkpointRepo := gormimpl.NewSyncCheckpointRepository(db)
+	searchRepo := gormimpl.NewSearchRepository(db)
+	contactRepo := gormimpl.NewContactRepository(db)
+	invitationRepo := gormimpl.NewInvitationRepository(db)
+	campaignRepo := gormimpl.NewCampaignRepository(db)
+	enrollmentRepo := gormimpl.NewEnrollmentRepository(db)
+	quotaRepo := gormimpl.NewQuotaRepository(db)
+	templateRepo := gormimpl.NewTemplateRepository(db)
+	scheduledRepo := gormimpl.NewScheduledMessageRepository(db)
+	jobRepo := gormimpl.NewJobRepository(db)
+	taskRunRepo := gormimpl.NewTaskRunRepository(db)
+	webhookEndpointRepo := gormimpl.NewWebhookEndpointRepository(db)
+	webhookDeliveryRepo := gormimpl.NewWebhookDeliveryRepository(db)
+	outboxRepo := gormimpl.NewOutboxRepository(db)
+
+	// 內部事件匯流排，outbox relay 把與資料變更一起寫入的事件發布到這裡
+	bus, err := events.New(cfg.Events)
+	if err != nil {
+		slog.Error("Failed to initialize event bus", "err", err)
+		os.Exit(1)
+	}
 
 	userSvc := service.NewUserService(userRepo)
 	authSvc := service.NewAuthService(cfg.Server.JWTSecret)
-	unipileSvc := service.NewUnipileService(unipileRepo)
+	unipileSvc := service.NewUnipileService(unipileRepo, gormimpl.NewTransactor(db), outbox.NewWriter(outboxRepo))
+	env := envelope.New(kms)
+	credSvc := service.NewCredentialService(credRepo, env)
+	idemSvc := service.NewIdempotencyService(idemRepo, cfg.Idempotency)
+	// 請求限流，store 為 redis 時 redisClient 不為 nil
+	rateLimitStore, redisClient, err := newRateLimitStore(cfg.RateLimit, db)
+	if err != nil {
+		slog.Error("Failed to initialize rate limit store", "err", err)
+		os.Exit(1)
+	}
+	rateLimitSvc, err := service.NewRateLimitService(cfg.RateLimit, rateLimitStore)
+	if err != nil {
+		slog.Error("Failed to initialize rate limiting", "err", err)
+		os.Exit(1)
+	}
+	auditLogger := service.NewAuditLogger(auditRepo)
+	unipileClient := unipile.NewClient(cfg.Unipile)
+	// 背景工作佇列，工作類型以 jobs.Handle 註冊
+	jobQueue := jobs.NewQueue(cfg.Jobs, jobRepo)
+	quotaSvc := service.NewQuotaService(cfg.Quotas, unipileSvc, quotaRepo)
+	inboxSvc := service.NewInboxService(unipileSvc, unipileClient, messageRepo, chatRepo, attendeeRepo, checkpointRepo, contactRepo, quotaSvc)
+	exportSvc := service.NewExportService(unipileSvc, messageRepo, chatRepo, attendeeRepo)
+	searchSvc := service.NewSearchService(unipileSvc, searchRepo)
+	contactSvc := service.NewContactService(cfg.Contacts, unipileSvc, unipileClient, contactRepo)
+	templateSvc := service.NewTemplateService(contactSvc, unipileClient, templateRepo)
+	invitationSvc := service.NewInvitationService(unipileSvc, unipileClient, unipileRepo, invitationRepo, quotaSvc)
+	campaignSvc := service.NewCampaignService(cfg.Campaigns, unipileSvc, inboxSvc, invitationSvc, campaignRepo, enrollmentRepo, messageRepo)
+	scheduledSvc := service.NewScheduledMessageService(cfg.Scheduled, unipileSvc, inboxSvc, scheduledRepo)
+	syncSvc := service.NewSyncService(cfg.Sync, unipileClient, unipileRepo, chatRepo, messageRepo, attendeeRepo, checkpointRepo)
+	// 對方回覆時停止外展活動的報名
+	syncSvc.OnInboundMessage(campaignSvc.HandleReply)
+	// 帳號與訊息事件送到使用者設定的 webhook endpoint
+	webhookSvc := service.NewWebhookService(cfg.Webhooks, env, webhookEndpointRepo, webhookDeliveryRepo, unipileRepo, jobQueue)
+	syncSvc.OnAccountStatusChanged(webhookSvc.AccountStatusChanged)
+	syncSvc.OnInboundMessage(webhookSvc.MessageReceived)
+	// 帳號需要重新登入時以保存的 Cookie 重新連結
+	reconnectSvc := service.NewReconnectService(credSvc, unipileClient, jobQueue)
+	syncSvc.OnAccountStatusChanged(reconnectSvc.AccountStatusChanged)
 
-	userHdl := handler.NewUserHandler(userSvc, authSvc)
-	unipileHdl := handler.NewUnipileHandler(cfg, unipileSvc)
+	// 連結與移除帳號的事件：送出 webhook，並立即開始同步新連結的帳號
+	subscriptions := []struct {
+		topic   string
+		handler events.Handler
+	}{
+		{model.EventAccountConnected, webhookSvc.HandleAccountEvent},
+		{model.EventAccountDisconnected, webhookSvc.HandleAccountEvent},
+		{model.EventAccountConnected, syncSvc.HandleAccountConnected},
+	}
+	for _, sub := range subscriptions {
+		if _, err := bus.Subscribe(sub.topic, sub.handler); err != nil {
+			slog.Error("Failed to subscribe to events", "topic", sub.topic, "err", err)
+			os.Exit(1)
+		}
+	}
+	relay := outbox.NewRelay(cfg.Outbox, outboxRepo, bus)
+
+	// 佇列長度在每次抓取 /metrics 時查詢
+	metrics.Registry.MustRegister(jobQueue.DepthCollector(10 * time.Second))
+
+	// 定期維護工作，排程在 config.yml 的 cron.tasks；以 advisory lock 確保每次只有一個程序執行
+	scheduler, err := cron.NewScheduler(cfg.Cron, gormimpl.NewAdvisoryLocker(db), taskRunRepo)
+	if err != nil {
+		slog.Error("Failed to initialize cron scheduler", "err", err)
+		os.Exit(1)
+	}
+	scheduler.Register("refresh_account_status", syncSvc.RefreshAccountStatuses)
+	scheduler.Register("purge_idempotency_keys", idemSvc.PurgeExpired)
+	scheduler.Register("purge_jobs", jobQueue.Purge)
+	scheduler.Register("purge_outbox", relay.Purge)
+	scheduler.Register("purge_rate_limits", rateLimitSvc.PurgeExpired)
+
+	userHdl := handler.NewUserHandler(userSvc, authSvc, auditLogger)
+	unipileHdl := handler.NewUnipileHandler(cfg, unipileSvc, credSvc, auditLogger)
+	auditHdl := handler.NewAuditHandler(auditLogger)
+	inboxHdl := handler.NewInboxHandler(inboxSvc)
+	exportHdl := handler.NewExportHandler(exportSvc)
+	searchHdl := handler.NewSearchHandler(searchSvc)
+	contactHdl := handler.NewContactHandler(contactSvc)
+	invitationHdl := handler.NewInvitationHandler(invitationSvc)
+	campaignHdl := handler.NewCampaignHandler(campaignSvc)
+	quotaHdl := handler.NewQuotaHandler(quotaSvc)
+	templateHdl := handler.NewTemplateHandler(templateSvc)
+	scheduledHdl := handler.NewScheduledMessageHandler(scheduledSvc)
+	webhookHdl := handler.NewWebhookHandler(cfg.Sync.WebhookSecret, syncSvc, invitationSvc)
+	webhookEndpointHdl := handler.NewWebhookEndpointHandler(webhookSvc)
+	taskHdl := handler.NewTaskHandler(scheduler)
+	healthChecker := newHealthChecker(cfg.Health, sqlDB, unipileClient, jobQueue)
+	if redisClient != nil {
+		// Redis 無法使用時限流不生效 (fail open)，不影響就緒
+		healthChecker.Add("redis", false, func(ctx context.Context) (any, error) {
+			return nil, redisClient.Ping(ctx).Err()
+		})
+	}
+	healthHdl := handler.NewHealthHandler(healthChecker)
 
 	// 設定路由
-	r := handler.SetupRouter(cfg, userHdl, unipileHdl)
+	r := handler.SetupRouter(cfg, idemSvc, rateLimitSvc, userHdl, unipileHdl, auditHdl, inboxHdl, exportHdl, searchHdl, contactHdl, invitationHdl, campaignHdl, quotaHdl, templateHdl, scheduledHdl, webhookHdl, webhookEndpointHdl, taskHdl, healthHdl)
+	// 只採用來自可信任代理的 X-Forwarded-For，否則用戶端可以偽造 IP 避開限流
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		slog.Error("Invalid server.trusted_proxies", "err", err)
+		os.Exit(1)
+	}
 	slog.Info("Router setup complete")
 
 	// 5. 將 Gin 路由器包裝在標準的 http.Server 中
@@ -80,6 +248,61 @@ func main() {
 		}
 	}()
 
+	// Prometheus 指標使用獨立的位址，不對外開放
+	metricsSrv := newMetricsServer(cfg.Metrics)
+	if metricsSrv != nil {
+		go func() {
+			slog.Info("Metrics server starting", "addr", metricsSrv.Addr)
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("Metrics server failed to start", "err", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	// 啟動背景同步 (每個連結帳號一個 worker)
+	syncCtx, stopSync := context.WithCancel(context.Background())
+	syncDone := make(chan struct{})
+	go func() {
+		defer close(syncDone)
+		syncSvc.Run(syncCtx)
+	}()
+
+	// 啟動外展活動排程器
+	campaignDone := make(chan struct{})
+	go func() {
+		defer close(campaignDone)
+		campaignSvc.Run(syncCtx)
+	}()
+
+	// 啟動排程訊息 worker
+	scheduledDone := make(chan struct{})
+	go func() {
+		defer close(scheduledDone)
+		scheduledSvc.Run(syncCtx)
+	}()
+
+	// 啟動背景工作佇列
+	jobsDone := make(chan struct{})
+	go func() {
+		defer close(jobsDone)
+		jobQueue.Run(syncCtx)
+	}()
+
+	// 啟動 outbox relay
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		relay.Run(syncCtx)
+	}()
+
+	// 啟動定期維護工作
+	cronDone := make(chan struct{})
+	go func() {
+		defer close(cronDone)
+		scheduler.Run(syncCtx)
+	}()
+
 	// 7. Graceful Shutdown 邏輯
 	// 建立一個 channel 來接收作業系統訊號
 	quit := make(chan os.Signal, 1)
@@ -87,8 +310,16 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
 	// 阻塞直到接收到訊號
-	<-quit
-	slog.Warn("Shutting down server...")
+	sig := <-quit
+	slog.Warn("Shutting down server...", "signal", sig.String())
+
+	// SIGTERM 來自 orchestrator：先讓 /readyz 回報 503，等負載平衡器停止轉送後才關閉伺服器
+	// (Ctrl+C 直接關閉)
+	healthChecker.SetDraining()
+	if sig == syscall.SIGTERM && cfg.Health.ShutdownDelay > 0 {
+		slog.Info("Draining before shutdown", "delay", cfg.Health.ShutdownDelay)
+		time.Sleep(cfg.Health.ShutdownDelay)
+	}
 
 	// 8. 執行伺服器關閉
 	// 建立一個具有 5 秒超時的 Context
@@ -101,5 +332,166 @@ func main() {
 		os.Exit(1)
 	}
 
+	if metricsSrv != nil {
+		if err := metricsSrv.Shutdown(ctx); err != nil {
+			slog.Error("Metrics server forced to shutdown", "err", err)
+		}
+	}
+
+	// 停止背景同步與排程器，進行中的同步會保存進度後結束
+	stopSync()
+	// 背景工作與定期維護工作可能執行較久，各自有等待完成的時間，不與 HTTP 伺服器共用
+	jobsDrain, cronDrain := cmp.Or(cfg.Jobs.DrainTimeout, 30*time.Second), cmp.Or(cfg.Cron.DrainTimeout, 30*time.Second)
+	jobsCtx, cancelJobs := context.WithTimeout(context.Background(), jobsDrain)
+	defer cancelJobs()
+	cronCtx, cancelCron := context.WithTimeout(context.Background(), cronDrain)
+	defer cancelCron()
+	select {
+	case <-syncDone:
+	case <-ctx.Done():
+		slog.Warn("Sync workers did not stop in time")
+	}
+	select {
+	case <-campaignDone:
+	case <-ctx.Done():
+		slog.Warn("Campaign scheduler did not stop in time")
+	}
+	select {
+	case <-scheduledDone:
+	case <-ctx.Done():
+		slog.Warn("Scheduled message worker did not stop in time")
+	}
+	// 沒有發布的事件留在 outbox，下一次啟動或由其他程序發布
+	select {
+	case <-relayDone:
+	case <-ctx.Done():
+		slog.Warn("Outbox relay did not stop in time")
+	}
+	// 不再取出新的工作，等待執行中的工作完成；來不及完成的工作在租約到期後由其他程序重新執行
+	select {
+	case <-jobsDone:
+	case <-jobsCtx.Done():
+		slog.Warn("Background jobs did not finish in time", "drain_timeout", jobsDrain)
+	}
+	select {
+	case <-cronDone:
+	case <-cronCtx.Done():
+		slog.Warn("Cron tasks did not finish in time", "drain_timeout", cronDrain)
+	}
+	if err := bus.Close(); err != nil {
+		slog.Error("Failed to close event bus", "err", err)
+	}
+	if redisClient != nil {
+		redisClient.Close()
+	}
+	// 送出剩下的 span；等待背景工作後 ctx 可能已經到期，另外給 5 秒
+	if tracerProvider != nil {
+		flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelFlush()
+		if err := tracerProvider.Shutdown(flushCtx); err != nil {
+			slog.Warn("Failed to flush trace spans", "err", err)
+		}
+	}
+
 	slog.Info("Server exiting gracefully.")
 }
+
+// newRateLimitStore 依 rate_limit.store 建立保存 token bucket 的 store，預設為 memory
+// memory 只在同一個程序內計算，執行多個程序時請使用 postgres 或 redis
+func newRateLimitStore(cfg config.RateLimitConfig, db *gorm.DB) (itfc.RateLimitStore, *redis.Client, error) {
+	switch cfg.Store {
+	case "", "memory":
+		return memory.NewRateLimitStore(), nil, nil
+	case "postgres":
+		return gormimpl.NewRateLimitStore(db), nil, nil
+	case "redis":
+		client, err := redisimpl.Dial(cfg.Redis)
+		if err != nil {
+			return nil, nil, err
+		}
+		return redisimpl.NewRateLimitStore(client, cfg.Redis.KeyPrefix), client, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown rate_limit.store %q", cfg.Store)
+	}
+}
+
+// newHealthChecker 建立 /readyz 使用的檢查：資料庫連線、結構版本、Unipile 與工作佇列 worker
+func newHealthChecker(cfg config.HealthConfig, sqlDB *sql.DB, unipileClient *unipile.Client, jobQueue *jobs.Queue) *health.Checker {
+	checker := health.NewChecker(cfg.Timeout)
+
+	checker.Add("database", true, func(ctx context.Context) (any, error) {
+		if err := sqlDB.PingContext(ctx); err != nil {
+			return nil, err
+		}
+		stats := sqlDB.Stats()
+		return map[string]int{"open_connections": stats.OpenConnections, "in_use": stats.InUse}, nil
+	})
+
+	checker.Add("migrations", true, func(ctx context.Context) (any, error) {
+		version, err := dbpkg.CheckSchemaVersion(ctx, sqlDB)
+		return map[string]int64{"version": version, "required": dbpkg.SchemaVersion}, err
+	})
+
+	// Unipile 以快取的結果回報，避免每次探測都呼叫外部 API
+	ttl := cfg.UnipileCacheTTL
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	checker.Add("unipile", cfg.UnipileRequired, health.Cached(ttl, func(ctx context.Context) (any, error) {
+		return nil, unipileClient.Ping(ctx)
+	}))
+
+	// 每個佇列至少每 poll_interval 檢查一次工作，太久沒有檢查代表 worker 卡住
+	heartbeatTimeout := cfg.HeartbeatTimeout
+	if heartbeatTimeout <= 0 {
+		heartbeatTimeout = 3 * jobQueue.PollInterval()
+	}
+	checker.Add("jobs", true, func(ctx context.Context) (any, error) {
+		last := jobQueue.Heartbeat()
+		if last.IsZero() {
+			return nil, fmt.Errorf("job worker has not started")
+		}
+		age := time.Since(last)
+		detail := map[string]any{"last_heartbeat": last.UTC(), "age_seconds": age.Seconds()}
+		if age > heartbeatTimeout {
+			return detail, fmt.Errorf("no heartbeat for %s", age.Round(time.Second))
+		}
+		return detail, nil
+	})
+
+	return checker
+}
+
+// newMetricsServer 建立在 metrics.listen 提供 /metrics 的伺服器，沒有設定 listen 時回傳 nil (改由 API 路由提供)
+func newMetricsServer(cfg config.MetricsConfig) *http.Server {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Listen == "" {
+		if cfg.Username == "" {
+			slog.Warn("Metrics enabled but neither metrics.listen nor metrics.username is set, /metrics is not exposed")
+		}
+		return nil
+	}
+
+	var h http.Handler = metrics.Handler(10 * time.Second)
+	if cfg.Username != "" {
+		h = basicAuth(cfg.Username, cfg.Password, h)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", h)
+	return &http.Server{Addr: cfg.Listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+}
+
+// basicAuth 驗證 HTTP basic auth 的帳號密碼
+func basicAuth(username, password string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(u), []byte(username)) != 1 || subtle.ConstantTimeCompare([]byte(p), []byte(password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
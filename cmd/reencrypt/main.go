@@ -0,0 +1,89 @@
+// reencrypt 將連線憑證的資料金鑰重新包裝為目前版本的主金鑰，用於主金鑰輪替。
+//
+// 使用 config.yml 的主金鑰 (kms: config)：
+//  1. go run ./cmd/reencrypt -genkey 產生新金鑰
+//  2. 將新金鑰加入 crypto.master_keys 並調高 crypto.active_key_version (舊版本先保留)
+//  3. go run ./cmd/reencrypt
+//  4. 完成後即可從 master_keys 移除舊版本
+//
+// 使用本機 keyring (kms: local)：
+//
+//	go run ./cmd/reencrypt -rotate
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"chatsheet/config"
+	"chatsheet/internal/db"
+	"chatsheet/internal/envelope"
+	"chatsheet/internal/repository/gormimpl"
+	"chatsheet/internal/service"
+
+	"github.com/MatusOllah/slogcolor"
+)
+
+func main() {
+	genKey := flag.Bool("genkey", false, "產生一把新的 base64 主金鑰後結束")
+	rotate := flag.Bool("rotate", false, "先在本機 keyring 產生新版本的主金鑰 (僅限 kms: local)")
+	flag.Parse()
+
+	slog.SetDefault(slog.New(slogcolor.NewHandler(os.Stderr, slogcolor.DefaultOptions)))
+
+	if *genKey {
+		key, err := envelope.GenerateKey()
+		if err != nil {
+			slog.Error("Failed to generate key", "err", err)
+			os.Exit(1)
+		}
+		fmt.Println(base64.StdEncoding.EncodeToString(key))
+		return
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		slog.Error("Failed to load configuration", "err", err)
+		os.Exit(1)
+	}
+
+	kms, err := envelope.NewKMS(cfg.Crypto)
+	if err != nil {
+		slog.Error("Failed to initialize KMS", "err", err)
+		os.Exit(1)
+	}
+
+	if *rotate {
+		keyring, ok := kms.(*envelope.Keyring)
+		if !ok {
+			slog.Error("-rotate requires crypto.kms: local")
+			os.Exit(1)
+		}
+		version, err := keyring.Rotate()
+		if err != nil {
+			slog.Error("Failed to rotate master key", "err", err)
+			os.Exit(1)
+		}
+		slog.Info("Rotated master key", "key_version", version)
+	}
+
+	db, err := db.InitDB(cfg)
+	if err != nil {
+		slog.Error("Failed to connect to database", "err", err)
+		os.Exit(1)
+	}
+
+	credSvc := service.NewCredentialService(gormimpl.NewCredentialRepository(db), envelope.New(kms))
+
+	n, err := credSvc.Rotate(context.Background())
+	if err != nil {
+		slog.Error("Failed to re-encrypt credentials", "rewrapped", n, "err", err)
+		os.Exit(1)
+	}
+
+	slog.Info("Re-encryption complete", "rewrapped", n, "key_version", kms.ActiveVersion())
+}
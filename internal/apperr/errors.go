@@ -0,0 +1,75 @@
+package apperr
+
+import "errors"
+
+// 領域錯誤的種類 (sentinel errors)
+// Repository 負責將底層錯誤轉換為這些錯誤，所有實作 (gormimpl、memory) 都必須回傳相同的錯誤，
+// 讓 Service 層可以用 errors.Is 判斷，而不需要知道底層是哪一種儲存方式。
+var (
+	// ErrNotFound 查無資料
+	ErrNotFound = errors.New("record not found")
+	// ErrConflict 違反唯一性約束 (例如重複的 email 或 account_id)
+	ErrConflict = errors.New("record already exists")
+	// ErrValidation 請求內容不合法
+	ErrValidation = errors.New("validation failed")
+	// ErrUnauthorized 未登入或憑證無效
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden 已登入但沒有權限
+	ErrForbidden = errors.New("forbidden")
+	// ErrTooManyRequests 超過使用量限制，例如帳號的每日配額
+	ErrTooManyRequests = errors.New("too many requests")
+	// ErrUpstream 第三方服務 (例如 Unipile) 回傳錯誤或無法連線
+	ErrUpstream = errors.New("upstream service error")
+)
+
+// Error 是帶有錯誤種類、對外訊息與細節的錯誤
+// Message 與 Details 會原樣回傳給前端，Err 只會寫入日誌
+type Error struct {
+	Kind    error  // 上面定義的錯誤種類之一
+	Message string // 對外顯示的訊息
+	Details any    // 額外資訊，例如欄位驗證錯誤
+	Err     error  // 原始錯誤
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Is 讓 errors.Is(err, apperr.ErrNotFound) 之類的判斷成立
+func (e *Error) Is(target error) bool {
+	return e.Kind == target
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithDetails 設定錯誤細節並回傳自身，方便串接
+func (e *Error) WithDetails(details any) *Error {
+	e.Details = details
+	return e
+}
+
+// New 建立指定種類的錯誤
+func New(kind error, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+// Wrap 以指定種類包裝原始錯誤
+func Wrap(kind error, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+func NotFound(message string) *Error        { return New(ErrNotFound, message) }
+func Conflict(message string) *Error        { return New(ErrConflict, message) }
+func Validation(message string) *Error      { return New(ErrValidation, message) }
+func Unauthorized(message string) *Error    { return New(ErrUnauthorized, message) }
+func TooManyRequests(message string) *Error { return New(ErrTooManyRequests, message) }
+
+// Upstream 包裝第三方服務的錯誤
+func Upstream(message string, err error) *Error {
+	return Wrap(ErrUpstream, message, err)
+}
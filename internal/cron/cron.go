@@ -0,0 +1,239 @@
+// Package cron 依 cron 運算式定期執行維護工作。
+//
+// 工作的排程寫在 config.yml 的 cron.tasks，執行的函式以 Register 註冊；只有兩者都存在的工作會執行。
+// 多個程序同時執行 Run 時，每次排程只有取得 advisory lock 的程序會執行 (leader election)，
+// 執行結果保存在 task_runs，其他程序看到同一個排程時間已經執行過就會略過。
+package cron
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TaskFunc 執行一次工作，回傳的字串是結果摘要 (例如刪除的筆數)，會保存在執行紀錄中
+type TaskFunc func(ctx context.Context) (string, error)
+
+// task 是設定中的一個工作
+type task struct {
+	name     string
+	expr     string
+	schedule Schedule
+}
+
+// TaskStatus 是工作的排程與最後一次的執行紀錄
+type TaskStatus struct {
+	Name       string         `json:"name"`
+	Schedule   string         `json:"schedule"`
+	Registered bool           `json:"registered"`  // 是否有註冊執行的函式，false 代表不會執行
+	NextRunAt  *time.Time     `json:"next_run_at"` // 下一次排程的時間
+	LastRun    *model.TaskRun `json:"last_run"`    // 從未執行時為 null
+}
+
+// Scheduler 依排程執行註冊的工作
+type Scheduler struct {
+	loc     *time.Location
+	tasks   map[string]task
+	locker  itfc.Locker
+	runRepo itfc.TaskRunRepository
+	host    string
+
+	mu    sync.RWMutex
+	funcs map[string]TaskFunc
+}
+
+// NewScheduler 解析設定中的排程，時區或運算式錯誤時回傳錯誤
+func NewScheduler(cfg config.CronConfig, locker itfc.Locker, runRepo itfc.TaskRunRepository) (*Scheduler, error) {
+	if cfg.Timezone == "" {
+		cfg.Timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("cron: invalid timezone %q: %w", cfg.Timezone, err)
+	}
+
+	tasks := make(map[string]task, len(cfg.Tasks))
+	for name, expr := range cfg.Tasks {
+		schedule, err := Parse(expr)
+		if err != nil {
+			return nil, fmt.Errorf("cron: task %s: %w", name, err)
+		}
+		tasks[name] = task{name: name, expr: expr, schedule: schedule}
+	}
+
+	host, _ := os.Hostname()
+
+	return &Scheduler{
+		loc:     loc,
+		tasks:   tasks,
+		locker:  locker,
+		runRepo: runRepo,
+		host:    host,
+		funcs:   map[string]TaskFunc{},
+	}, nil
+}
+
+// Register 註冊工作執行的函式，請在 Run 之前註冊；同一個名稱重複註冊時會 panic
+// 設定中沒有排程的工作不會執行
+func (s *Scheduler) Register(name string, fn TaskFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.funcs[name]; ok {
+		panic("cron: duplicate task " + name)
+	}
+	s.funcs[name] = fn
+}
+
+// Run 依排程執行工作，程序沒有在執行的排程時間 (例如重新啟動期間) 不會補執行
+// 阻塞直到 ctx 結束，且執行中的工作都已返回
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, t := range s.tasks {
+		fn := s.taskFunc(t.name)
+		if fn == nil {
+			slog.WarnContext(ctx, "Cron task has a schedule but no registered function", "task", t.name)
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runTask(ctx, t, fn)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// List 依名稱列出設定中的工作與最後一次的執行紀錄
+func (s *Scheduler) List(ctx context.Context) ([]TaskStatus, error) {
+	runs, err := s.runRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lastRuns := make(map[string]*model.TaskRun, len(runs))
+	for i := range runs {
+		lastRuns[runs[i].Name] = &runs[i]
+	}
+
+	now := time.Now().In(s.loc)
+	statuses := make([]TaskStatus, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		st := TaskStatus{
+			Name:       t.name,
+			Schedule:   t.expr,
+			Registered: s.taskFunc(t.name) != nil,
+			LastRun:    lastRuns[t.name],
+		}
+		if next := t.schedule.Next(now); st.Registered && !next.IsZero() {
+			st.NextRunAt = &next
+		}
+		statuses = append(statuses, st)
+	}
+	slices.SortFunc(statuses, func(a, b TaskStatus) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return statuses, nil
+}
+
+func (s *Scheduler) taskFunc(name string) TaskFunc {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.funcs[name]
+}
+
+// runTask 等到每一次排程時間並執行工作，直到 ctx 結束
+func (s *Scheduler) runTask(ctx context.Context, t task, fn TaskFunc) {
+	for {
+		due := t.schedule.Next(time.Now().In(s.loc))
+		if due.IsZero() {
+			slog.WarnContext(ctx, "Cron task has no next run", "task", t.name, "schedule", t.expr)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(due))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := s.fire(ctx, t, fn, due); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Failed to run cron task", "task", t.name, "err", err)
+		}
+	}
+}
+
+// fire 在取得鎖之後執行一次排程，其他程序已經執行過同一個排程時間時略過
+func (s *Scheduler) fire(ctx context.Context, t task, fn TaskFunc, due time.Time) error {
+	unlock, ok, err := s.locker.TryLock(ctx, "cron:"+t.name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// 其他程序正在執行
+		return nil
+	}
+	defer unlock()
+
+	last, err := s.runRepo.Get(ctx, t.name)
+	if err != nil && !errors.Is(err, apperr.ErrNotFound) {
+		return err
+	}
+	if last != nil && !last.ScheduledAt.Before(due) {
+		return nil
+	}
+
+	run := &model.TaskRun{
+		Name:        t.name,
+		Schedule:    t.expr,
+		ScheduledAt: due.UTC(),
+		StartedAt:   time.Now().UTC().Truncate(time.Microsecond),
+		Status:      model.TaskRunning,
+		Host:        s.host,
+	}
+	if err := s.runRepo.Save(ctx, run); err != nil {
+		return err
+	}
+
+	result, taskErr := s.call(ctx, fn)
+
+	finished := time.Now().UTC().Truncate(time.Microsecond)
+	run.FinishedAt = &finished
+	run.DurationMs = finished.Sub(run.StartedAt).Milliseconds()
+	run.Result = result
+	run.Status = model.TaskSucceeded
+	if taskErr != nil {
+		run.Status = model.TaskFailed
+		run.Error = taskErr.Error()
+		slog.WarnContext(ctx, "Cron task failed", "task", t.name, "err", taskErr)
+	} else {
+		slog.InfoContext(ctx, "Cron task finished", "task", t.name, "result", result, "duration_ms", run.DurationMs)
+	}
+
+	// 即使 ctx 已結束 (例如正在關機)，也要保存結果
+	return s.runRepo.Save(context.WithoutCancel(ctx), run)
+}
+
+// call 執行工作並把 panic 轉為錯誤，避免單一工作讓程序結束
+func (s *Scheduler) call(ctx context.Context, fn TaskFunc) (result string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
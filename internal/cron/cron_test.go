@@ -0,0 +1,124 @@
+package cron
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/model"
+	"chatsheet/internal/repository/memory"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFireRunsOncePerSchedule(t *testing.T) {
+	ctx := context.Background()
+	// 兩個程序共用鎖與執行紀錄
+	locker, runs := memory.NewLocker(), memory.NewTaskRunRepository()
+	cfg := config.CronConfig{Tasks: map[string]string{"purge": "@hourly"}}
+
+	var calls atomic.Int32
+	fn := func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		return "deleted 3", nil
+	}
+	var schedulers []*Scheduler
+	for range 2 {
+		s, err := NewScheduler(cfg, locker, runs)
+		if err != nil {
+			t.Fatalf("NewScheduler: %v", err)
+		}
+		s.Register("purge", fn)
+		schedulers = append(schedulers, s)
+	}
+
+	due := time.Date(2025, 1, 6, 10, 0, 0, 0, time.UTC)
+	var wg sync.WaitGroup
+	for _, s := range schedulers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.fire(ctx, s.tasks["purge"], fn, due); err != nil {
+				t.Errorf("fire: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	// 同一個排程時間已經執行過，再觸發也會略過
+	if err := schedulers[0].fire(ctx, schedulers[0].tasks["purge"], fn, due); err != nil {
+		t.Fatalf("fire: %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("task ran %d times for one schedule, want 1", n)
+	}
+
+	run, err := runs.Get(ctx, "purge")
+	if err != nil {
+		t.Fatalf("Get run: %v", err)
+	}
+	if run.Status != model.TaskSucceeded || run.Result != "deleted 3" || !run.ScheduledAt.Equal(due) || run.FinishedAt == nil {
+		t.Errorf("run = %+v, want succeeded at %v", run, due)
+	}
+
+	// 下一個排程時間會再執行
+	if err := schedulers[1].fire(ctx, schedulers[1].tasks["purge"], fn, due.Add(time.Hour)); err != nil {
+		t.Fatalf("fire: %v", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("task ran %d times, want 2", n)
+	}
+}
+
+func TestFireRecordsFailureAndPanic(t *testing.T) {
+	ctx := context.Background()
+	runs := memory.NewTaskRunRepository()
+	s, err := NewScheduler(config.CronConfig{Tasks: map[string]string{"fail": "@daily", "panic": "@daily"}}, memory.NewLocker(), runs)
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+
+	due := time.Now().UTC().Truncate(time.Minute)
+	failing := func(ctx context.Context) (string, error) { return "", errors.New("database unavailable") }
+	panicking := func(ctx context.Context) (string, error) { panic("boom") }
+	if err := s.fire(ctx, s.tasks["fail"], failing, due); err != nil {
+		t.Fatalf("fire: %v", err)
+	}
+	if err := s.fire(ctx, s.tasks["panic"], panicking, due); err != nil {
+		t.Fatalf("fire: %v", err)
+	}
+
+	for name, want := range map[string]string{"fail": "database unavailable", "panic": "panic: boom"} {
+		run, err := runs.Get(ctx, name)
+		if err != nil {
+			t.Fatalf("Get run %s: %v", name, err)
+		}
+		if run.Status != model.TaskFailed || run.Error != want {
+			t.Errorf("run %s = %s %q, want failed %q", name, run.Status, run.Error, want)
+		}
+	}
+}
+
+func TestListReportsRegisteredTasks(t *testing.T) {
+	s, err := NewScheduler(config.CronConfig{Timezone: "UTC", Tasks: map[string]string{"b": "@hourly", "a": "@daily"}}, memory.NewLocker(), memory.NewTaskRunRepository())
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+	s.Register("a", func(ctx context.Context) (string, error) { return "", nil })
+
+	statuses, err := s.List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(statuses) != 2 || statuses[0].Name != "a" || statuses[1].Name != "b" {
+		t.Fatalf("List = %+v, want a and b by name", statuses)
+	}
+	// 沒有註冊函式的工作不會執行，也沒有下一次執行時間
+	if !statuses[0].Registered || statuses[0].NextRunAt == nil || statuses[1].Registered || statuses[1].NextRunAt != nil {
+		t.Errorf("List = %+v", statuses)
+	}
+
+	if _, err := NewScheduler(config.CronConfig{Tasks: map[string]string{"bad": "* *"}}, memory.NewLocker(), memory.NewTaskRunRepository()); err == nil {
+		t.Error("NewScheduler with an invalid schedule succeeded")
+	}
+}
@@ -0,0 +1,52 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNext(t *testing.T) {
+	// 2025-01-06 是週一
+	from := time.Date(2025, 1, 6, 10, 7, 30, 0, time.UTC)
+
+	for expr, want := range map[string]time.Time{
+		"*/15 * * * *":     time.Date(2025, 1, 6, 10, 15, 0, 0, time.UTC),
+		"17 * * * *":       time.Date(2025, 1, 6, 10, 17, 0, 0, time.UTC),
+		"0 9 * * mon-fri":  time.Date(2025, 1, 7, 9, 0, 0, 0, time.UTC),
+		"0 0 1 feb *":      time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+		"0-30/10 10 * * *": time.Date(2025, 1, 6, 10, 10, 0, 0, time.UTC),
+		"0 12 1,15 * *":    time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC),
+		// 日與星期都有限制時，符合其中一個即執行
+		"0 0 13 * fri": time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC),
+		"@daily":       time.Date(2025, 1, 7, 0, 0, 0, 0, time.UTC),
+		"@hourly":      time.Date(2025, 1, 6, 11, 0, 0, 0, time.UTC),
+		"@every 10m":   time.Date(2025, 1, 6, 10, 10, 0, 0, time.UTC),
+	} {
+		s, err := Parse(expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", expr, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(want) {
+			t.Errorf("Parse(%q).Next = %v, want %v", expr, got, want)
+		}
+	}
+}
+
+func TestParseNeverMatches(t *testing.T) {
+	s, err := Parse("0 0 30 feb *")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if next := s.Next(time.Now()); !next.IsZero() {
+		t.Errorf("Next = %v, want zero for a date that never exists", next)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *", "@every -1m", "@sometimes"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", expr)
+		}
+	}
+}
@@ -3,12 +3,15 @@ package db
 import (
 	"fmt"
 	"log/slog"
+	"time"
 
 	"chatsheet/config"
 	"chatsheet/internal/model"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/plugin/opentelemetry/tracing"
 )
 
 var DB *gorm.DB
@@ -22,19 +25,76 @@ func InitDB(cfg *config.AppConfig) (*gorm.DB, error) {
 		dbCfg.Host, dbCfg.User, dbCfg.Password, dbCfg.Name, dbCfg.Port, dbCfg.SSLMode)
 
 	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// TranslateError 讓 gorm 將唯一性衝突轉為 gorm.ErrDuplicatedKey
+	// SQL 的錯誤與慢查詢以 slog 記錄，只記錄佔位符 ($1) 不記錄參數的值 (可能包含密碼或 token)
+	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
+		TranslateError: true,
+		Logger: logger.NewSlogLogger(slog.Default(), logger.Config{
+			LogLevel:                  logger.Warn,
+			SlowThreshold:             200 * time.Millisecond,
+			ParameterizedQueries:      true,
+			IgnoreRecordNotFoundError: true,
+		}),
+	})
 	if err != nil {
 		slog.Error("Failed to connect to database", "err", err)
 		return nil, err
 	}
 
+	// 查詢時間指標與追蹤
+	if err = DB.Use(metricsPlugin{}); err != nil {
+		slog.Error("Failed to register database metrics", "err", err)
+		return nil, err
+	}
+	// SQL 只記錄佔位符 ($1)，不記錄參數的值；查詢時間指標由 metricsPlugin 記錄
+	if err = DB.Use(tracing.NewPlugin(tracing.WithDBSystem("postgresql"), tracing.WithoutQueryVariables(), tracing.WithoutMetrics())); err != nil {
+		slog.Error("Failed to register database tracing", "err", err)
+		return nil, err
+	}
+
 	// 自動遷移模型
-	err = DB.AutoMigrate(&model.User{}, &model.UnipileAccount{})
+	err = DB.AutoMigrate(&model.User{}, &model.UnipileAccount{}, &model.UnipileCredential{}, &model.IdempotencyKey{}, &model.AuditEvent{}, &model.Message{}, &model.Chat{}, &model.ChatAttendee{}, &model.SyncCheckpoint{}, &model.Contact{}, &model.Invitation{}, &model.Campaign{}, &model.Enrollment{}, &model.EnrollmentEvent{}, &model.AccountLimits{}, &model.ActionUsage{}, &model.MessageTemplate{}, &model.ScheduledMessage{}, &model.Job{}, &model.TaskRun{}, &model.WebhookEndpoint{}, &model.WebhookDelivery{}, &model.OutboxEvent{}, &model.RateLimitBucket{})
 	if err != nil {
 		slog.Error("Failed to database auto migrate", "err", err)
 		return nil, err
 	}
-	slog.Info("Connecting to database", "on", dsn)
+
+	// AutoMigrate 無法建立 generated column 與 GIN index，另外執行 (與 migrations/007、022 相同)
+	if err = DB.Exec(messageSearchDDL).Error; err != nil {
+		slog.Error("Failed to migrate message search", "err", err)
+		return nil, err
+	}
+	if err = DB.Exec(unipileAccountUserFKDDL).Error; err != nil {
+		slog.Error("Failed to migrate unipile account foreign key", "err", err)
+		return nil, err
+	}
+	// DSN 包含密碼，只記錄連線位置
+	slog.Info("Connecting to database", "host", dbCfg.Host, "port", dbCfg.Port, "dbname", dbCfg.Name)
 
 	return DB, nil
 }
+
+// messageSearchDDL 建立訊息與參與者名稱全文搜尋使用的 tsvector 欄位與 GIN index
+const messageSearchDDL = `
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+	setweight(to_tsvector('simple', coalesce(text, '')), 'A') ||
+	setweight(to_tsvector('simple', coalesce(sender_name, '')), 'B')
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_messages_search ON messages USING GIN (search_vector);
+ALTER TABLE chat_attendees ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+	to_tsvector('simple', coalesce(name, ''))
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_chat_attendees_search ON chat_attendees USING GIN (search_vector);
+`
+
+// unipileAccountUserFKDDL 建立 unipile_accounts.user_email 的外鍵 (與 migrations/001 相同)，模型沒有關聯欄位，AutoMigrate 不會建立
+const unipileAccountUserFKDDL = `
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_user_email') THEN
+		ALTER TABLE unipile_accounts ADD CONSTRAINT fk_user_email
+			FOREIGN KEY (user_email) REFERENCES users(email) ON DELETE CASCADE;
+	END IF;
+END
+$$;
+`
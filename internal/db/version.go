@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"chatsheet/migrations"
+)
+
+// SchemaVersion 為程式需要的資料庫結構版本，即 migrations/ 中最後一個檔案的編號
+var SchemaVersion = mustLatestMigration(migrations.Files)
+
+// latestMigration 回傳 fsys 中編號最大的遷移檔案 (NNN_description.sql) 的編號
+func latestMigration(fsys fs.FS) (int64, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return 0, err
+	}
+	var latest int64
+	for _, name := range names {
+		prefix, _, ok := strings.Cut(name, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if !ok || err != nil {
+			return 0, fmt.Errorf("migration %s does not start with a version number", name)
+		}
+		latest = max(latest, version)
+	}
+	if latest == 0 {
+		return 0, errors.New("no migrations found")
+	}
+	return latest, nil
+}
+
+func mustLatestMigration(fsys fs.FS) int64 {
+	version, err := latestMigration(fsys)
+	if err != nil {
+		panic("db: " + err.Error())
+	}
+	return version
+}
+
+// MigrationVersion 回傳資料庫目前的結構版本，由每個遷移檔案在套用時寫入 schema_migrations
+// 資料表不存在或沒有紀錄時回傳錯誤
+func MigrationVersion(ctx context.Context, sqlDB *sql.DB) (version int64, dirty bool, err error) {
+	err = sqlDB.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, errors.New("schema_migrations is empty")
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("read schema_migrations (apply migrations/ up to %d): %w", SchemaVersion, err)
+	}
+	return version, dirty, nil
+}
+
+// CheckSchemaVersion 確認資料庫已套用到 SchemaVersion 的遷移且沒有中斷的遷移
+// 程式本身不寫入 schema_migrations，版本只反映實際套用的遷移檔案
+func CheckSchemaVersion(ctx context.Context, sqlDB *sql.DB) (int64, error) {
+	version, dirty, err := MigrationVersion(ctx, sqlDB)
+	if err != nil {
+		return 0, err
+	}
+	return version, checkVersion(version, dirty, SchemaVersion)
+}
+
+// checkVersion 比較資料庫的版本與程式需要的版本
+func checkVersion(version int64, dirty bool, required int64) error {
+	if dirty {
+		return fmt.Errorf("schema version %d is dirty", version)
+	}
+	if version < required {
+		return fmt.Errorf("schema version %d is older than required %d: apply the remaining migrations/", version, required)
+	}
+	return nil
+}
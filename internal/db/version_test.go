@@ -0,0 +1,69 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSchemaVersionMatchesMigrations(t *testing.T) {
+	names, err := filepath.Glob("../../migrations/*.sql")
+	if err != nil || len(names) == 0 {
+		t.Fatalf("glob migrations: %v %v", names, err)
+	}
+	last := filepath.Base(names[len(names)-1])
+	want, _ := strconv.ParseInt(strings.SplitN(last, "_", 2)[0], 10, 64)
+	if SchemaVersion != want {
+		t.Errorf("SchemaVersion = %d, want %d from %s", SchemaVersion, want, last)
+	}
+
+	// 每個遷移檔案都必須在套用時寫入自己的版本，否則 /readyz 會認為尚未套用
+	data, err := os.ReadFile("../../migrations/" + last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "UPDATE schema_migrations SET version = "+strconv.FormatInt(want, 10)+";") {
+		t.Errorf("%s does not record schema version %d", last, want)
+	}
+}
+
+func TestLatestMigration(t *testing.T) {
+	got, err := latestMigration(fstest.MapFS{
+		"001_create_tables.sql": {},
+		"010_add_index.sql":     {},
+		"002_add_column.sql":    {},
+		"README.md":             {},
+	})
+	if err != nil || got != 10 {
+		t.Errorf("latestMigration = %d, %v, want 10", got, err)
+	}
+
+	if _, err := latestMigration(fstest.MapFS{"create_tables.sql": {}}); err == nil {
+		t.Error("latestMigration accepted a file without a version")
+	}
+	if _, err := latestMigration(fstest.MapFS{}); err == nil {
+		t.Error("latestMigration accepted an empty directory")
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	tests := []struct {
+		version int64
+		dirty   bool
+		wantErr string
+	}{
+		{20, false, ""},
+		{21, false, ""}, // 新版本的程序已先遷移
+		{19, false, "older than required 20"},
+		{20, true, "dirty"},
+	}
+	for _, tt := range tests {
+		err := checkVersion(tt.version, tt.dirty, 20)
+		if (tt.wantErr == "" && err != nil) || (tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr))) {
+			t.Errorf("checkVersion(%d, %v) = %v, want %q", tt.version, tt.dirty, err, tt.wantErr)
+		}
+	}
+}
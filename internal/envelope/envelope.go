@@ -0,0 +1,86 @@
+// Package envelope 實作信封加密 (envelope encryption)。
+//
+// 每一筆資料使用各自隨機產生的資料金鑰 (data key) 以 AES-256-GCM 加密，
+// 資料金鑰再由 KMS 的主金鑰 (master key) 包裝後與密文一起儲存。
+// 輪替主金鑰時只需要重新包裝資料金鑰 (Rewrap)，不需要重新加密資料本身。
+package envelope
+
+import (
+	"context"
+)
+
+// Sealed 是加密後要儲存的內容
+type Sealed struct {
+	KeyVersion int    // 包裝資料金鑰所使用的主金鑰版本
+	WrappedKey []byte // 被主金鑰包裝的資料金鑰
+	Ciphertext []byte // nonce || 以資料金鑰加密的密文
+}
+
+// Envelope 提供加密、解密與重新包裝
+type Envelope struct {
+	kms KMS
+}
+
+func New(kms KMS) *Envelope {
+	return &Envelope{kms: kms}
+}
+
+// ActiveVersion 目前的主金鑰版本
+func (e *Envelope) ActiveVersion() int {
+	return e.kms.ActiveVersion()
+}
+
+// Seal 以新的資料金鑰加密 plaintext
+// aad 會被綁定在密文上 (例如 account_id)，解密時必須提供相同的值
+func (e *Envelope) Seal(ctx context.Context, plaintext, aad []byte) (*Sealed, error) {
+	dataKey, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	defer clear(dataKey)
+
+	ciphertext, err := seal(dataKey, plaintext, aad)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, version, err := e.kms.Wrap(ctx, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sealed{KeyVersion: version, WrappedKey: wrapped, Ciphertext: ciphertext}, nil
+}
+
+// Open 解密 Seal 的輸出
+func (e *Envelope) Open(ctx context.Context, s *Sealed, aad []byte) ([]byte, error) {
+	dataKey, err := e.kms.Unwrap(ctx, s.KeyVersion, s.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(dataKey)
+
+	return open(dataKey, s.Ciphertext, aad)
+}
+
+// Rewrap 以目前版本的主金鑰重新包裝資料金鑰，密文不變
+// 若已經是目前版本則回傳 false
+func (e *Envelope) Rewrap(ctx context.Context, s *Sealed) (bool, error) {
+	if s.KeyVersion == e.kms.ActiveVersion() {
+		return false, nil
+	}
+
+	dataKey, err := e.kms.Unwrap(ctx, s.KeyVersion, s.WrappedKey)
+	if err != nil {
+		return false, err
+	}
+	defer clear(dataKey)
+
+	wrapped, version, err := e.kms.Wrap(ctx, dataKey)
+	if err != nil {
+		return false, err
+	}
+
+	s.KeyVersion, s.WrappedKey = version, wrapped
+	return true, nil
+}
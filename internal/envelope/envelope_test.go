@@ -0,0 +1,207 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"chatsheet/config"
+)
+
+func newTestKMS(t *testing.T, versions ...int) *LocalKMS {
+	t.Helper()
+
+	keys := make(map[int][]byte, len(versions))
+	for _, v := range versions {
+		key, err := GenerateKey()
+		if err != nil {
+			t.Fatalf("GenerateKey: %v", err)
+		}
+		keys[v] = key
+	}
+	kms, err := NewLocalKMS(keys, versions[len(versions)-1])
+	if err != nil {
+		t.Fatalf("NewLocalKMS: %v", err)
+	}
+	return kms
+}
+
+func TestSealOpen(t *testing.T) {
+	ctx := context.Background()
+	env := New(newTestKMS(t, 1))
+	plaintext := []byte(`{"access_token":"li_at-secret"}`)
+
+	sealed, err := env.Seal(ctx, plaintext, []byte("acc-1"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if sealed.KeyVersion != 1 || bytes.Contains(sealed.Ciphertext, plaintext) {
+		t.Errorf("sealed = %+v, want version 1 without the plaintext", sealed)
+	}
+
+	got, err := env.Open(ctx, sealed, []byte("acc-1"))
+	if err != nil || !bytes.Equal(got, plaintext) {
+		t.Fatalf("Open = %q, %v, want %q", got, err, plaintext)
+	}
+
+	// 每次使用新的資料金鑰與 nonce
+	again, _ := env.Seal(ctx, plaintext, []byte("acc-1"))
+	if bytes.Equal(again.Ciphertext, sealed.Ciphertext) || bytes.Equal(again.WrappedKey, sealed.WrappedKey) {
+		t.Error("Seal reused a data key or nonce")
+	}
+}
+
+func TestOpenRejects(t *testing.T) {
+	ctx := context.Background()
+	env := New(newTestKMS(t, 1))
+	sealed, err := env.Seal(ctx, []byte("secret"), []byte("acc-1"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	flip := func(b []byte) []byte {
+		b = bytes.Clone(b)
+		b[len(b)-1] ^= 1
+		return b
+	}
+	tests := map[string]struct {
+		sealed *Sealed
+		aad    string
+	}{
+		"aad mismatch":        {sealed, "acc-2"},
+		"tampered ciphertext": {&Sealed{KeyVersion: 1, WrappedKey: sealed.WrappedKey, Ciphertext: flip(sealed.Ciphertext)}, "acc-1"},
+		"tampered data key":   {&Sealed{KeyVersion: 1, WrappedKey: flip(sealed.WrappedKey), Ciphertext: sealed.Ciphertext}, "acc-1"},
+		"short ciphertext":    {&Sealed{KeyVersion: 1, WrappedKey: sealed.WrappedKey, Ciphertext: sealed.Ciphertext[:4]}, "acc-1"},
+	}
+	for name, tt := range tests {
+		if got, err := env.Open(ctx, tt.sealed, []byte(tt.aad)); err == nil {
+			t.Errorf("%s: Open = %q, want an error", name, got)
+		}
+	}
+
+	unknown := &Sealed{KeyVersion: 7, WrappedKey: sealed.WrappedKey, Ciphertext: sealed.Ciphertext}
+	if _, err := env.Open(ctx, unknown, []byte("acc-1")); !errors.Is(err, ErrUnknownKeyVersion) {
+		t.Errorf("Open with an unknown version = %v, want ErrUnknownKeyVersion", err)
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	ctx := context.Background()
+	kms := newTestKMS(t, 1)
+	env := New(kms)
+	sealed, err := env.Seal(ctx, []byte("secret"), []byte("acc-1"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if changed, err := env.Rewrap(ctx, sealed); err != nil || changed {
+		t.Fatalf("Rewrap at the active version = %v, %v, want unchanged", changed, err)
+	}
+
+	key, _ := GenerateKey()
+	kms.keys[2], kms.active = key, 2
+	ciphertext := bytes.Clone(sealed.Ciphertext)
+	if changed, err := env.Rewrap(ctx, sealed); err != nil || !changed {
+		t.Fatalf("Rewrap = %v, %v, want changed", changed, err)
+	}
+	if sealed.KeyVersion != 2 || !bytes.Equal(sealed.Ciphertext, ciphertext) {
+		t.Errorf("rewrapped = version %d, ciphertext changed %v; want version 2 with the same ciphertext", sealed.KeyVersion, !bytes.Equal(sealed.Ciphertext, ciphertext))
+	}
+
+	// 移除舊版本後仍可解密
+	delete(kms.keys, 1)
+	if got, err := env.Open(ctx, sealed, []byte("acc-1")); err != nil || string(got) != "secret" {
+		t.Errorf("Open after rewrap = %q, %v", got, err)
+	}
+}
+
+func TestKeyringRotate(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keyring.json")
+
+	kr, err := OpenKeyring(path)
+	if err != nil {
+		t.Fatalf("OpenKeyring: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("keyring file = %v, %v, want mode 0600", info, err)
+	}
+	old, err := New(kr).Seal(ctx, []byte("old"), nil)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	version, err := kr.Rotate()
+	if err != nil || version != 2 {
+		t.Fatalf("Rotate = %d, %v, want version 2", version, err)
+	}
+
+	// 重新開啟檔案：新資料使用新版本，舊版本的資料仍可解密
+	reopened, err := OpenKeyring(path)
+	if err != nil {
+		t.Fatalf("reopen keyring: %v", err)
+	}
+	if reopened.ActiveVersion() != 2 || len(reopened.Versions()) != 2 {
+		t.Fatalf("reopened keyring = active %d versions %v", reopened.ActiveVersion(), reopened.Versions())
+	}
+	env := New(reopened)
+	if got, err := env.Open(ctx, old, nil); err != nil || string(got) != "old" {
+		t.Errorf("Open version 1 after rotate = %q, %v", got, err)
+	}
+	sealed, err := env.Seal(ctx, []byte("new"), nil)
+	if err != nil || sealed.KeyVersion != 2 {
+		t.Errorf("Seal after rotate = %+v, %v, want version 2", sealed, err)
+	}
+}
+
+func TestNewKMS(t *testing.T) {
+	key, _ := GenerateKey()
+	encoded := base64.StdEncoding.EncodeToString(key)
+
+	kms, err := NewKMS(config.CryptoConfig{KMS: "config", ActiveKeyVersion: 1, MasterKeys: map[string]string{"1": encoded}})
+	if err != nil || kms.ActiveVersion() != 1 {
+		t.Fatalf("NewKMS(config) = %v, %v", kms, err)
+	}
+	if kms, err := NewKMS(config.CryptoConfig{KMS: "local", KeyringFile: filepath.Join(t.TempDir(), "keyring.json")}); err != nil || kms.ActiveVersion() != 1 {
+		t.Errorf("NewKMS(local) = %v, %v", kms, err)
+	}
+
+	tests := map[string]struct {
+		cfg  config.CryptoConfig
+		want string
+	}{
+		"no master keys":     {config.CryptoConfig{KMS: "config", ActiveKeyVersion: 1}, "crypto.master_keys"},
+		"inactive version":   {config.CryptoConfig{KMS: "config", ActiveKeyVersion: 2, MasterKeys: map[string]string{"1": encoded}}, "crypto.active_key_version"},
+		"short key":          {config.CryptoConfig{KMS: "config", ActiveKeyVersion: 1, MasterKeys: map[string]string{"1": "c2hvcnQ="}}, "32 bytes"},
+		"invalid base64":     {config.CryptoConfig{KMS: "config", ActiveKeyVersion: 1, MasterKeys: map[string]string{"1": "not base64!"}}, "version 1"},
+		"missing keyring":    {config.CryptoConfig{KMS: "local"}, "keyring_file"},
+		"unsupported driver": {config.CryptoConfig{KMS: "vault"}, "vault"},
+	}
+	for name, tt := range tests {
+		if _, err := NewKMS(tt.cfg); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: NewKMS = %v, want an error mentioning %q", name, err, tt.want)
+		}
+	}
+}
+
+func TestShippedConfigStarts(t *testing.T) {
+	wd, _ := os.Getwd()
+	if err := os.Chdir("../.."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	// 不在 repo 中留下產生的 keyring
+	cfg.Crypto.KeyringFile = filepath.Join(t.TempDir(), "keyring.json")
+	if _, err := NewKMS(cfg.Crypto); err != nil {
+		t.Errorf("NewKMS with the shipped config.yml: %v", err)
+	}
+}
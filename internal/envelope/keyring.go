@@ -0,0 +1,114 @@
+package envelope
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+)
+
+// Keyring 是以本機 JSON 檔案保存主金鑰的 KMS 替身
+// 僅適用於開發與單機部署，正式環境應改用真正的 KMS
+type Keyring struct {
+	*LocalKMS
+	path string
+}
+
+// keyringFile 是 keyring 檔案的格式
+type keyringFile struct {
+	Active int               `json:"active"`
+	Keys   map[string]string `json:"keys"` // 版本 → base64 主金鑰
+}
+
+// OpenKeyring 讀取 keyring 檔案，檔案不存在時產生第一把主金鑰並寫入
+func OpenKeyring(path string) (*Keyring, error) {
+	if path == "" {
+		return nil, errors.New(`crypto.keyring_file is required when crypto.kms is "local"`)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err := GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		kms, err := NewLocalKMS(map[int][]byte{1: key}, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		kr := &Keyring{LocalKMS: kms, path: path}
+		if err := kr.save(); err != nil {
+			return nil, err
+		}
+		slog.Warn("Created new local keyring", "path", path)
+		return kr, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f keyringFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid keyring file %s: %w", path, err)
+	}
+
+	keys := make(map[int][]byte, len(f.Keys))
+	for v, encoded := range f.Keys {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid keyring version %q: %w", v, err)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid keyring key version %d: %w", version, err)
+		}
+		keys[version] = key
+	}
+
+	kms, err := NewLocalKMS(keys, f.Active)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Keyring{LocalKMS: kms, path: path}, nil
+}
+
+// Rotate 產生新版本的主金鑰並設為目前版本，舊版本保留以解開既有的資料金鑰
+func (kr *Keyring) Rotate() (int, error) {
+	key, err := GenerateKey()
+	if err != nil {
+		return 0, err
+	}
+
+	kr.mu.Lock()
+	version := 0
+	for v := range kr.keys {
+		version = max(version, v)
+	}
+	version++
+	kr.keys[version] = key
+	kr.active = version
+	kr.mu.Unlock()
+
+	return version, kr.save()
+}
+
+func (kr *Keyring) save() error {
+	kr.mu.RLock()
+	f := keyringFile{Active: kr.active, Keys: make(map[string]string, len(kr.keys))}
+	for v, k := range kr.keys {
+		f.Keys[strconv.Itoa(v)] = base64.StdEncoding.EncodeToString(k)
+	}
+	kr.mu.RUnlock()
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(kr.path, data, 0o600)
+}
@@ -0,0 +1,185 @@
+package envelope
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"chatsheet/config"
+)
+
+// KeySize 主金鑰與資料金鑰的長度 (AES-256)
+const KeySize = 32
+
+// ErrUnknownKeyVersion 找不到指定版本的主金鑰
+var ErrUnknownKeyVersion = errors.New("unknown master key version")
+
+// KMS 負責以主金鑰包裝 (wrap) 與解開 (unwrap) 資料金鑰
+// 主金鑰本身永遠不會離開 KMS，真正的 KMS (例如 AWS KMS) 也能實作這個介面
+type KMS interface {
+	// ActiveVersion 目前用來包裝新資料金鑰的主金鑰版本
+	ActiveVersion() int
+	// Wrap 以目前版本的主金鑰包裝資料金鑰，回傳包裝後的金鑰與使用的版本
+	Wrap(ctx context.Context, dataKey []byte) (wrapped []byte, version int, err error)
+	// Unwrap 以指定版本的主金鑰解開資料金鑰
+	Unwrap(ctx context.Context, version int, wrapped []byte) ([]byte, error)
+}
+
+// LocalKMS 以記憶體中的主金鑰實作 KMS，主金鑰來自 config.yml 或本機 keyring 檔案
+type LocalKMS struct {
+	mu     sync.RWMutex
+	keys   map[int][]byte
+	active int
+}
+
+// NewLocalKMS 以版本對應主金鑰的 map 建立 LocalKMS
+func NewLocalKMS(keys map[int][]byte, active int) (*LocalKMS, error) {
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("%w: active version %d", ErrUnknownKeyVersion, active)
+	}
+	for v, k := range keys {
+		if len(k) != KeySize {
+			return nil, fmt.Errorf("master key version %d must be %d bytes, got %d", v, KeySize, len(k))
+		}
+	}
+
+	return &LocalKMS{keys: keys, active: active}, nil
+}
+
+// NewKMS 依照設定建立 KMS
+//   - kms: config 使用 config.yml 中的 master_keys
+//   - kms: local  使用 keyring_file 指定的本機檔案 (不存在時自動建立)，用來模擬 KMS
+func NewKMS(cfg config.CryptoConfig) (KMS, error) {
+	switch cfg.KMS {
+	case "", "config":
+		if len(cfg.MasterKeys) == 0 {
+			return nil, errors.New(`crypto.kms is "config" but crypto.master_keys is empty: ` +
+				`generate a key with "go run ./cmd/reencrypt -genkey" and set crypto.master_keys to {"1": "<key>"}, ` +
+				`or set crypto.kms to "local" to keep a development keyring in crypto.keyring_file`)
+		}
+		keys := make(map[int][]byte, len(cfg.MasterKeys))
+		for v, encoded := range cfg.MasterKeys {
+			version, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid master key version %q: %w", v, err)
+			}
+			key, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("invalid master key version %d: %w", version, err)
+			}
+			keys[version] = key
+		}
+		if _, ok := keys[cfg.ActiveKeyVersion]; !ok {
+			return nil, fmt.Errorf("%w: crypto.active_key_version %d is not in crypto.master_keys", ErrUnknownKeyVersion, cfg.ActiveKeyVersion)
+		}
+		return NewLocalKMS(keys, cfg.ActiveKeyVersion)
+	case "local":
+		return OpenKeyring(cfg.KeyringFile)
+	default:
+		return nil, fmt.Errorf("unsupported kms %q", cfg.KMS)
+	}
+}
+
+func (k *LocalKMS) ActiveVersion() int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.active
+}
+
+// Versions 回傳所有主金鑰版本 (由小到大)
+func (k *LocalKMS) Versions() []int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	versions := make([]int, 0, len(k.keys))
+	for v := range k.keys {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+
+	return versions
+}
+
+func (k *LocalKMS) Wrap(ctx context.Context, dataKey []byte) ([]byte, int, error) {
+	k.mu.RLock()
+	version, key := k.active, k.keys[k.active]
+	k.mu.RUnlock()
+
+	wrapped, err := seal(key, dataKey, versionAAD(version))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return wrapped, version, nil
+}
+
+func (k *LocalKMS) Unwrap(ctx context.Context, version int, wrapped []byte) ([]byte, error) {
+	k.mu.RLock()
+	key, ok := k.keys[version]
+	k.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownKeyVersion, version)
+	}
+
+	return open(key, wrapped, versionAAD(version))
+}
+
+// versionAAD 將版本綁定在包裝後的金鑰上，避免被拿去用其他版本解開
+func versionAAD(version int) []byte {
+	return []byte("chatsheet-master-key-v" + strconv.Itoa(version))
+}
+
+// GenerateKey 產生一把隨機的 AES-256 金鑰
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// seal 以 AES-GCM 加密，輸出格式為 nonce || ciphertext
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// open 解開 seal 的輸出
+func open(key, sealed, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
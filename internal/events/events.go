@@ -0,0 +1,110 @@
+// Package events 提供程序內部的事件匯流排 (EventBus)。
+//
+// 事件由 internal/outbox 的 relay 發布，訂閱者以 topic (例如 account.connected) 訂閱，
+// topic 以 "." 分隔，訂閱時可以用 "*" 比對一段、以結尾的 ">" 比對剩下的所有段 (與 NATS subject 相同)。
+//
+// 兩種實作：
+//   - LocalBus 在同一個程序內同步呼叫訂閱者，訂閱者的錯誤會回傳給 Publish，relay 會重新發布
+//   - NATSBus 透過 NATS 伺服器傳遞，Publish 只保證伺服器已收到；訂閱者的錯誤只記錄在日誌
+//
+// 兩者都可能重複送達同一個事件，訂閱者必須以 Event.ID 去重或可以重複處理。
+package events
+
+import (
+	"chatsheet/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Event 是發布到匯流排的事件
+type Event struct {
+	ID        string          `json:"id"`            // outbox 事件 ID，重複送達時相同
+	Topic     string          `json:"topic"`         // 例如 account.connected
+	Key       string          `json:"key,omitempty"` // 事件所屬的資料，例如 account_id
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Handler 處理訂閱的事件
+type Handler func(ctx context.Context, ev Event) error
+
+// Subscription 是一個訂閱，Unsubscribe 後不再收到事件
+type Subscription interface {
+	Unsubscribe() error
+}
+
+// Bus 發布與訂閱事件
+type Bus interface {
+	// Publish 發布事件，回傳 nil 代表事件已送出 (LocalBus 為所有訂閱者都處理成功)
+	Publish(ctx context.Context, ev Event) error
+	// Subscribe 訂閱符合 topic 的事件，topic 可以包含萬用字元
+	Subscribe(topic string, h Handler) (Subscription, error)
+	// Close 取消所有訂閱並等待處理中的事件結束
+	Close() error
+}
+
+// New 依 events.driver 建立 Bus，預設為 local
+func New(cfg config.EventsConfig) (Bus, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return NewLocalBus(), nil
+	case "nats":
+		b, err := DialNATS(cfg.NATS)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("events: unknown driver %q", cfg.Driver)
+	}
+}
+
+// validTopic 回傳 topic 是否可以發布：不可為空，不可包含空白或萬用字元，每一段都不可為空
+func validTopic(topic string) bool {
+	if topic == "" || strings.ContainsAny(topic, " \t\r\n*>") {
+		return false
+	}
+	for _, token := range strings.Split(topic, ".") {
+		if token == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// validPattern 回傳訂閱的 topic 是否正確：">" 只能是最後一段，"*" 與 ">" 必須單獨成為一段
+func validPattern(pattern string) bool {
+	if pattern == "" || strings.ContainsAny(pattern, " \t\r\n") {
+		return false
+	}
+	tokens := strings.Split(pattern, ".")
+	for i, token := range tokens {
+		switch {
+		case token == "":
+			return false
+		case token == ">" && i != len(tokens)-1:
+			return false
+		case token != "*" && token != ">" && strings.ContainsAny(token, "*>"):
+			return false
+		}
+	}
+	return true
+}
+
+// Match 回傳 topic 是否符合訂閱的 pattern
+func Match(pattern, topic string) bool {
+	pts := strings.Split(pattern, ".")
+	tts := strings.Split(topic, ".")
+	for i, p := range pts {
+		if p == ">" {
+			return len(tts) > i
+		}
+		if i >= len(tts) || (p != "*" && p != tts[i]) {
+			return false
+		}
+	}
+	return len(pts) == len(tts)
+}
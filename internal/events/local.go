@@ -0,0 +1,109 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+)
+
+// LocalBus 在同一個程序內傳遞事件
+type LocalBus struct {
+	mu     sync.RWMutex
+	subs   map[int]*localSub
+	nextID int
+	closed bool
+
+	running sync.WaitGroup // 處理中的 Publish
+}
+
+type localSub struct {
+	bus     *LocalBus
+	id      int
+	pattern string
+	handler Handler
+}
+
+// NewLocalBus 建立只在同一個程序內傳遞事件的 Bus
+func NewLocalBus() *LocalBus {
+	return &LocalBus{subs: map[int]*localSub{}}
+}
+
+// Publish 依訂閱順序同步呼叫所有符合的訂閱者，回傳所有訂閱者的錯誤
+// 一個訂閱者失敗不影響其他訂閱者；重新發布時所有訂閱者都會再收到一次
+func (b *LocalBus) Publish(ctx context.Context, ev Event) error {
+	if !validTopic(ev.Topic) {
+		return fmt.Errorf("events: invalid topic %q", ev.Topic)
+	}
+
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return errors.New("events: bus closed")
+	}
+	var handlers []Handler
+	for id := 0; id < b.nextID; id++ {
+		if s, ok := b.subs[id]; ok && Match(s.pattern, ev.Topic) {
+			handlers = append(handlers, s.handler)
+		}
+	}
+	b.running.Add(1)
+	b.mu.RUnlock()
+	defer b.running.Done()
+
+	var errs []error
+	for _, h := range handlers {
+		if err := call(ctx, h, ev); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (b *LocalBus) Subscribe(topic string, h Handler) (Subscription, error) {
+	if !validPattern(topic) {
+		return nil, fmt.Errorf("events: invalid topic %q", topic)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, errors.New("events: bus closed")
+	}
+	s := &localSub{bus: b, id: b.nextID, pattern: topic, handler: h}
+	b.subs[s.id] = s
+	b.nextID++
+	return s, nil
+}
+
+// Close 取消所有訂閱，並等待處理中的 Publish 結束
+func (b *LocalBus) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.subs = map[int]*localSub{}
+	b.mu.Unlock()
+
+	b.running.Wait()
+	return nil
+}
+
+func (s *localSub) Unsubscribe() error {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	delete(s.bus.subs, s.id)
+	return nil
+}
+
+// call 呼叫訂閱者並把 panic 轉為錯誤，避免單一訂閱者讓程序結束
+func call(ctx context.Context, h Handler, ev Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "Event handler panicked", "topic", ev.Topic, "event_id", ev.ID, "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h(ctx, ev)
+}
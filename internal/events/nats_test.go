@@ -0,0 +1,255 @@
+package events
+
+import (
+	"chatsheet/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	natstest "github.com/nats-io/nats-server/v2/test"
+)
+
+// runNATSServer 在隨機的 port 啟動 nats-server，port 不是 0 時使用指定的 port (重新啟動)
+func runNATSServer(t *testing.T, port int, token string) (*server.Server, int) {
+	t.Helper()
+
+	opts := natstest.DefaultTestOptions
+	opts.Port = -1
+	if port != 0 {
+		opts.Port = port
+	}
+	opts.Authorization = token
+	srv := natstest.RunServer(&opts)
+	t.Cleanup(srv.Shutdown)
+
+	return srv, srv.Addr().(*net.TCPAddr).Port
+}
+
+func dialTestNATS(t *testing.T, port int, cfg config.NATSConfig) *NATSBus {
+	t.Helper()
+
+	cfg.URL = fmt.Sprintf("nats://127.0.0.1:%d", port)
+	if cfg.ReconnectWait == 0 {
+		cfg.ReconnectWait = 20 * time.Millisecond
+	}
+	b, err := DialNATS(cfg)
+	if err != nil {
+		t.Fatalf("DialNATS: %v", err)
+	}
+	t.Cleanup(func() { b.Close() })
+
+	return b
+}
+
+// receive 等待訂閱收到事件
+func receive(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+		return Event{}
+	}
+}
+
+// subscribeChan 訂閱 topic，收到的事件送到回傳的 channel
+func subscribeChan(t *testing.T, b *NATSBus, topic string) <-chan Event {
+	t.Helper()
+
+	ch := make(chan Event, 16)
+	if _, err := b.Subscribe(topic, func(ctx context.Context, ev Event) error {
+		ch <- ev
+		return nil
+	}); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	// 以 Publish 的 PING/PONG 確認伺服器已經處理 SUB
+	if err := b.Publish(context.Background(), Event{ID: "sync", Topic: "test.sync"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	return ch
+}
+
+func TestNATSPublishSubscribe(t *testing.T) {
+	_, port := runNATSServer(t, 0, "")
+	pub := dialTestNATS(t, port, config.NATSConfig{})
+	sub := dialTestNATS(t, port, config.NATSConfig{})
+
+	ch := subscribeChan(t, sub, "account.*")
+
+	want := Event{
+		ID:        "evt-1",
+		Topic:     "account.connected",
+		Key:       "acc-1",
+		Payload:   json.RawMessage(`{"account_id":"acc-1"}`),
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	if err := pub.Publish(context.Background(), want); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if err := pub.Publish(context.Background(), Event{ID: "evt-2", Topic: "message.received"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	got := receive(t, ch)
+	if got.ID != want.ID || got.Topic != want.Topic || got.Key != want.Key ||
+		string(got.Payload) != string(want.Payload) || !got.CreatedAt.Equal(want.CreatedAt) {
+		t.Errorf("event = %+v, want %+v", got, want)
+	}
+	select {
+	case ev := <-ch:
+		t.Errorf("received %s, which does not match account.*", ev.Topic)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNATSQueueGroup(t *testing.T) {
+	_, port := runNATSServer(t, 0, "")
+	cfg := config.NATSConfig{QueueGroup: "workers"}
+	pub := dialTestNATS(t, port, cfg)
+	ch1 := subscribeChan(t, dialTestNATS(t, port, cfg), "account.connected")
+	ch2 := subscribeChan(t, dialTestNATS(t, port, cfg), "account.connected")
+
+	const n = 10
+	for i := range n {
+		if err := pub.Publish(context.Background(), Event{ID: fmt.Sprint(i), Topic: "account.connected"}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+
+	seen := map[string]int{}
+	for range n {
+		select {
+		case ev := <-ch1:
+			seen[ev.ID]++
+		case ev := <-ch2:
+			seen[ev.ID]++
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d events, want %d", len(seen), n)
+		}
+	}
+	select {
+	case ev := <-ch1:
+		seen[ev.ID]++
+	case ev := <-ch2:
+		seen[ev.ID]++
+	case <-time.After(100 * time.Millisecond):
+	}
+	for id, count := range seen {
+		if count != 1 {
+			t.Errorf("event %s delivered %d times in the queue group", id, count)
+		}
+	}
+}
+
+func TestNATSReconnect(t *testing.T) {
+	srv, port := runNATSServer(t, 0, "")
+	b := dialTestNATS(t, port, config.NATSConfig{Timeout: time.Second})
+	ch := subscribeChan(t, b, "account.connected")
+
+	srv.Shutdown()
+	// 中斷期間 Publish 回傳錯誤，讓 relay 稍後重新發布
+	deadline := time.Now().Add(5 * time.Second)
+	for b.Publish(context.Background(), Event{ID: "lost", Topic: "account.connected"}) == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Publish kept succeeding after the server shut down")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	runNATSServer(t, port, "")
+	// 重新連線後 Publish 恢復，且訂閱重新建立
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		err := b.Publish(context.Background(), Event{ID: "after", Topic: "account.connected"})
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Publish after restart: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// 伺服器關閉前送出的 lost 可能已經送達，略過
+	for ev := receive(t, ch); ev.ID != "after"; ev = receive(t, ch) {
+		if ev.ID != "lost" {
+			t.Fatalf("received %s after reconnect, want after", ev.ID)
+		}
+	}
+}
+
+func TestNATSAuth(t *testing.T) {
+	_, port := runNATSServer(t, 0, "s3cret")
+
+	if _, err := DialNATS(config.NATSConfig{URL: fmt.Sprintf("nats://127.0.0.1:%d", port), Token: "wrong"}); err == nil {
+		t.Error("DialNATS with a wrong token succeeded")
+	}
+
+	b := dialTestNATS(t, port, config.NATSConfig{Token: "s3cret"})
+	ch := subscribeChan(t, b, "account.connected")
+	if err := b.Publish(context.Background(), Event{ID: "evt-1", Topic: "account.connected"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if ev := receive(t, ch); ev.ID != "evt-1" {
+		t.Errorf("received %s, want evt-1", ev.ID)
+	}
+}
+
+func TestNATSRejectsInvalidTopic(t *testing.T) {
+	_, port := runNATSServer(t, 0, "")
+	b := dialTestNATS(t, port, config.NATSConfig{})
+
+	if err := b.Publish(context.Background(), Event{ID: "x", Topic: "account.*"}); err == nil {
+		t.Error("Publish with a wildcard topic succeeded")
+	}
+	if _, err := b.Subscribe("account.>.x", func(context.Context, Event) error { return nil }); err == nil {
+		t.Error("Subscribe with > in the middle succeeded")
+	}
+}
+
+func TestNATSCloseWaitsForHandlers(t *testing.T) {
+	_, port := runNATSServer(t, 0, "")
+	b := dialTestNATS(t, port, config.NATSConfig{})
+
+	started, release := make(chan struct{}), make(chan struct{})
+	if _, err := b.Subscribe("account.connected", func(context.Context, Event) error {
+		close(started)
+		<-release
+		return nil
+	}); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if err := b.Publish(context.Background(), Event{ID: "evt-1", Topic: "account.connected"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	<-started
+
+	closed := make(chan struct{})
+	go func() {
+		b.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned while a handler was running")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return after the handler finished")
+	}
+
+	if err := b.Publish(context.Background(), Event{ID: "evt-2", Topic: "account.connected"}); err == nil {
+		t.Error("Publish after Close succeeded")
+	}
+}
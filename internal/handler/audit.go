@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"chatsheet/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newAuditEntry 以請求的 IP 與 User-Agent 建立稽核事件
+func newAuditEntry(c *gin.Context, actor, action, targetType, targetID string, err error) service.AuditEntry {
+	return service.AuditEntry{
+		ActorEmail: actor,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		Err:        err,
+	}
+}
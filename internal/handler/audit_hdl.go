@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/model"
+	"chatsheet/internal/service"
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	audit *service.AuditLogger
+}
+
+func NewAuditHandler(audit *service.AuditLogger) *AuditHandler {
+	return &AuditHandler{audit: audit}
+}
+
+// auditCSVHeader 匯出 CSV 的欄位
+var auditCSVHeader = []string{"id", "created_at", "actor_email", "action", "target_type", "target_id", "ip", "user_agent", "result", "reason"}
+
+// @Summary 我的稽核紀錄
+// @Description 分頁列出目前使用者的稽核事件 (由新到舊)
+// @Tags audit
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param cursor query string false "上一頁回傳的 next_cursor"
+// @Param limit query int false "每頁筆數 (最多 200)"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=[]model.AuditEvent}
+// @Failure 401 {object} ErrorResponse "未授權"
+// @Router /me/audit [get]
+func (h *AuditHandler) ListMine(c *gin.Context) {
+	h.list(c, c.GetString("email"))
+}
+
+// @Summary 所有稽核紀錄
+// @Description 管理員分頁列出所有使用者的稽核事件，可用 actor 篩選
+// @Tags audit
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param actor query string false "使用者 email"
+// @Param cursor query string false "上一頁回傳的 next_cursor"
+// @Param limit query int false "每頁筆數 (最多 200)"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=[]model.AuditEvent}
+// @Failure 403 {object} ErrorResponse "非管理員"
+// @Router /admin/audit [get]
+func (h *AuditHandler) ListAll(c *gin.Context) {
+	h.list(c, c.Query("actor"))
+}
+
+// @Summary 匯出我的稽核紀錄
+// @Description 以 CSV 或 JSONL 串流匯出目前使用者的所有稽核事件
+// @Tags audit
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param format query string false "csv 或 jsonl (預設)"
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Router /me/audit/export [get]
+func (h *AuditHandler) ExportMine(c *gin.Context) {
+	h.export(c, c.GetString("email"))
+}
+
+// @Summary 匯出所有稽核紀錄
+// @Description 管理員以 CSV 或 JSONL 串流匯出稽核事件，可用 actor 篩選
+// @Tags audit
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param actor query string false "使用者 email"
+// @Param format query string false "csv 或 jsonl (預設)"
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Router /admin/audit/export [get]
+func (h *AuditHandler) ExportAll(c *gin.Context) {
+	h.export(c, c.Query("actor"))
+}
+
+func (h *AuditHandler) list(c *gin.Context, actor string) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	events, next, err := h.audit.List(c.Request.Context(), actor, c.Query("cursor"), limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Get success",
+		"events":      events,
+		"next_cursor": next,
+	})
+}
+
+func (h *AuditHandler) export(c *gin.Context, actor string) {
+	format := c.DefaultQuery("format", "jsonl")
+	filename := "audit-" + time.Now().UTC().Format("20060102T150405Z")
+
+	var write func(model.AuditEvent) error
+	var flush func() error
+
+	switch format {
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.csv"`)
+
+		w := csv.NewWriter(c.Writer)
+		if err := w.Write(auditCSVHeader); err != nil {
+			c.Error(err)
+			return
+		}
+		write = func(e model.AuditEvent) error {
+			record := []string{
+				e.ID.String(), e.CreatedAt.UTC().Format(time.RFC3339Nano), e.ActorEmail, e.Action,
+				e.TargetType, e.TargetID, e.IP, e.UserAgent, e.Result, e.Reason,
+			}
+			// User-Agent 等欄位由用戶端提供
+			for i, v := range record {
+				record[i] = spreadsheetCell(v)
+			}
+			return w.Write(record)
+		}
+		flush = func() error {
+			w.Flush()
+			return w.Error()
+		}
+	case "jsonl":
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.jsonl"`)
+
+		enc := json.NewEncoder(c.Writer)
+		write = func(e model.AuditEvent) error { return enc.Encode(e) }
+		flush = func() error { return nil }
+	default:
+		c.Error(apperr.Validation("format must be csv or jsonl"))
+		return
+	}
+
+	c.Status(http.StatusOK)
+	err := h.audit.Export(c.Request.Context(), actor, write)
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		// 回應已經開始輸出，無法再改變狀態碼，只能記錄錯誤
+		slog.ErrorContext(c.Request.Context(), "Failed to export audit events", "actor", actor, "err", err)
+	}
+}
+
+// spreadsheetCell 在以 = + - @ tab 或 CR 開頭的值前加上 '，讓試算表把它當成文字而不是公式 (CSV injection)
+func spreadsheetCell(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
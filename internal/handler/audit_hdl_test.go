@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"chatsheet/internal/model"
+	"chatsheet/internal/repository/memory"
+	"chatsheet/internal/service"
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuditExportCSVEscapesFormulas(t *testing.T) {
+	audit := service.NewAuditLogger(memory.NewAuditRepository())
+	audit.Log(context.Background(), service.AuditEntry{
+		ActorEmail: "a@example.com",
+		Action:     model.AuditUserLogin,
+		IP:         "127.0.0.1",
+		UserAgent:  "=cmd|' /C calc'!A0",
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("email", "a@example.com") })
+	r.GET("/me/audit/export", NewAuditHandler(audit).ExportMine)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/me/audit/export?format=csv", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("read CSV: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want header and 1 event", len(rows))
+	}
+	if got := rows[1][7]; got != "'=cmd|' /C calc'!A0" {
+		t.Errorf("user_agent cell = %q, want it escaped", got)
+	}
+}
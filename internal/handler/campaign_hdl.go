@@ -0,0 +1,292 @@
+package handler
+
+import (
+	"chatsheet/internal/model"
+	"chatsheet/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CampaignStepRequest 外展活動的一個步驟
+type CampaignStepRequest struct {
+	Action     string `json:"action" binding:"required,oneof=invite message"` // invite: 連結邀請, message: 訊息
+	DelayHours int    `json:"delay_hours" binding:"min=0"`                    // 與上一步 (第一步為報名時) 間隔的小時數
+	Text       string `json:"text"`                                           // 邀請附加的訊息 (選填) 或訊息內容
+}
+
+// CreateCampaignRequest 建立外展活動的請求
+type CreateCampaignRequest struct {
+	AccountID string                `json:"account_id" binding:"required"` // 執行步驟的 Unipile account_id
+	Name      string                `json:"name" binding:"required"`
+	Steps     []CampaignStepRequest `json:"steps" binding:"required,min=1,max=20,dive"`
+}
+
+// ProspectRequest 要報名的對象
+type ProspectRequest struct {
+	ProviderID string `json:"provider_id" binding:"required"` // 對方的 provider id
+	Name       string `json:"name"`
+}
+
+// EnrollRequest 報名外展活動的請求
+type EnrollRequest struct {
+	Prospects []ProspectRequest `json:"prospects" binding:"required,min=1,max=500,dive"`
+}
+
+type CampaignHandler struct {
+	campaignSvc *service.CampaignService
+}
+
+func NewCampaignHandler(campaignSvc *service.CampaignService) *CampaignHandler {
+	return &CampaignHandler{campaignSvc: campaignSvc}
+}
+
+// @Summary 建立外展活動
+// @Description 建立透過連結帳號執行的多步驟外展活動 (連結邀請、訊息)，建立後步驟不能修改
+// @Tags campaigns
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param request body CreateCampaignRequest true "活動內容"
+// @Accept json
+// @Produce json
+// @Success 201 {object} StandardResponse{data=model.Campaign}
+// @Failure 400 {object} ErrorResponse "請求格式錯誤"
+// @Failure 404 {object} ErrorResponse "帳號不存在"
+// @Router /campaigns [post]
+func (h *CampaignHandler) Create(c *gin.Context) {
+	var req CreateCampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(bindingError(err))
+		return
+	}
+
+	steps := make([]model.CampaignStep, len(req.Steps))
+	for i, s := range req.Steps {
+		steps[i] = model.CampaignStep{Action: s.Action, DelayHours: s.DelayHours, Text: s.Text}
+	}
+
+	campaign, err := h.campaignSvc.Create(c.Request.Context(), c.GetString("email"), service.CampaignInput{
+		AccountID: req.AccountID,
+		Name:      req.Name,
+		Steps:     steps,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Create success",
+		"campaign": campaign,
+	})
+}
+
+// @Summary 外展活動列表
+// @Description 列出使用者建立的外展活動
+// @Tags campaigns
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Produce json
+// @Success 200 {object} StandardResponse{data=[]model.Campaign}
+// @Router /campaigns [get]
+func (h *CampaignHandler) List(c *gin.Context) {
+	campaigns, err := h.campaignSvc.List(c.Request.Context(), c.GetString("email"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Get success",
+		"campaigns": campaigns,
+	})
+}
+
+// @Summary 外展活動
+// @Description 取得使用者的外展活動
+// @Tags campaigns
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "活動 ID"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=model.Campaign}
+// @Failure 404 {object} ErrorResponse "活動不存在"
+// @Router /campaigns/{id} [get]
+func (h *CampaignHandler) Get(c *gin.Context) {
+	campaign, err := h.campaignSvc.Get(c.Request.Context(), c.GetString("email"), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Get success",
+		"campaign": campaign,
+	})
+}
+
+// @Summary 暫停外展活動
+// @Description 暫停期間不執行任何步驟，恢復後繼續
+// @Tags campaigns
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "活動 ID"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=model.Campaign}
+// @Failure 404 {object} ErrorResponse "活動不存在"
+// @Router /campaigns/{id}/pause [post]
+func (h *CampaignHandler) Pause(c *gin.Context) {
+	campaign, err := h.campaignSvc.Pause(c.Request.Context(), c.GetString("email"), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Pause success",
+		"campaign": campaign,
+	})
+}
+
+// @Summary 恢復外展活動
+// @Description 恢復暫停的活動，暫停期間到期的步驟會盡快執行
+// @Tags campaigns
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "活動 ID"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=model.Campaign}
+// @Failure 404 {object} ErrorResponse "活動不存在"
+// @Router /campaigns/{id}/resume [post]
+func (h *CampaignHandler) Resume(c *gin.Context) {
+	campaign, err := h.campaignSvc.Resume(c.Request.Context(), c.GetString("email"), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Resume success",
+		"campaign": campaign,
+	})
+}
+
+// @Summary 報名外展活動
+// @Description 讓對象報名活動，第一個步驟在其 delay_hours 之後執行；已報名過的對象會被略過
+// @Tags campaigns
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "活動 ID"
+// @Param request body EnrollRequest true "報名的對象"
+// @Accept json
+// @Produce json
+// @Success 201 {object} StandardResponse{data=[]model.Enrollment}
+// @Failure 400 {object} ErrorResponse "請求格式錯誤"
+// @Failure 404 {object} ErrorResponse "活動不存在"
+// @Router /campaigns/{id}/enrollments [post]
+func (h *CampaignHandler) Enroll(c *gin.Context) {
+	var req EnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(bindingError(err))
+		return
+	}
+
+	prospects := make([]service.Prospect, len(req.Prospects))
+	for i, p := range req.Prospects {
+		prospects[i] = service.Prospect{ProviderID: p.ProviderID, Name: p.Name}
+	}
+
+	enrollments, skipped, err := h.campaignSvc.Enroll(c.Request.Context(), c.GetString("email"), c.Param("id"), prospects)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":     "Enroll success",
+		"enrollments": enrollments,
+		"skipped":     skipped,
+	})
+}
+
+// @Summary 報名列表
+// @Description 依報名時間由新到舊列出活動的報名與目前進度
+// @Tags campaigns
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "活動 ID"
+// @Param cursor query string false "上一頁回傳的 next_cursor"
+// @Param limit query int false "每頁筆數 (最多 200)"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=[]model.Enrollment}
+// @Failure 404 {object} ErrorResponse "活動不存在"
+// @Router /campaigns/{id}/enrollments [get]
+func (h *CampaignHandler) ListEnrollments(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	enrollments, next, err := h.campaignSvc.ListEnrollments(c.Request.Context(), c.GetString("email"), c.Param("id"), c.Query("cursor"), limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Get success",
+		"enrollments": enrollments,
+		"next_cursor": next,
+	})
+}
+
+// @Summary 停止報名
+// @Description 停止進行中的報名，不再執行之後的步驟
+// @Tags campaigns
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "活動 ID"
+// @Param enrollment_id path string true "報名 ID"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=model.Enrollment}
+// @Failure 404 {object} ErrorResponse "活動或報名不存在"
+// @Failure 409 {object} ErrorResponse "報名已結束"
+// @Router /campaigns/{id}/enrollments/{enrollment_id} [delete]
+func (h *CampaignHandler) StopEnrollment(c *gin.Context) {
+	enrollment, err := h.campaignSvc.Stop(c.Request.Context(), c.GetString("email"), c.Param("id"), c.Param("enrollment_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Stop success",
+		"enrollment": enrollment,
+	})
+}
+
+// @Summary 報名歷史
+// @Description 依時間由新到舊列出活動所有報名的狀態變化與步驟執行結果
+// @Tags campaigns
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "活動 ID"
+// @Param cursor query string false "上一頁回傳的 next_cursor"
+// @Param limit query int false "每頁筆數 (最多 200)"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=[]model.EnrollmentEvent}
+// @Failure 404 {object} ErrorResponse "活動不存在"
+// @Router /campaigns/{id}/history [get]
+func (h *CampaignHandler) History(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	events, next, err := h.campaignSvc.History(c.Request.Context(), c.GetString("email"), c.Param("id"), c.Query("cursor"), limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Get success",
+		"events":      events,
+		"next_cursor": next,
+	})
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"chatsheet/internal/metrics"
+	"chatsheet/internal/model"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var checkpointsTotal = promauto.With(metrics.Registry).NewCounterVec(prometheus.CounterOpts{
+	Name: "unipile_checkpoints_total",
+	Help: "LinkedIn checkpoint outcomes by checkpoint type (required, solved, failed).",
+}, []string{"type", "outcome"})
+
+// checkpoint 的結果
+const (
+	checkpointRequired = "required"
+	checkpointSolved   = "solved"
+	checkpointFailed   = "failed"
+)
+
+// checkpointTTL 與 Unipile CheckpointIntent 的時限相同，之後再送出 code 只會失敗
+const checkpointTTL = 5 * time.Minute
+
+// checkpointTypes 記住每個 account_id 最後一次要求的 checkpoint 類型
+// 解決 checkpoint 的請求只帶 account_id 與 code，記錄結果時需要知道當初的類型；
+// 只用於指標，程序重新啟動或多個程序時查不到的類型記為 unknown
+type checkpointTypes struct {
+	mu    sync.Mutex
+	types map[string]checkpointEntry
+}
+
+type checkpointEntry struct {
+	typ       string
+	expiresAt time.Time
+}
+
+func newCheckpointTypes() *checkpointTypes {
+	return &checkpointTypes{types: map[string]checkpointEntry{}}
+}
+
+// required 記錄 account_id 需要解決 typ 類型的 checkpoint
+func (t *checkpointTypes) required(accountID, typ string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	for id, e := range t.types {
+		if now.After(e.expiresAt) {
+			delete(t.types, id)
+		}
+	}
+	t.types[accountID] = checkpointEntry{typ: typ, expiresAt: now.Add(checkpointTTL)}
+	checkpointsTotal.WithLabelValues(typ, checkpointRequired).Inc()
+}
+
+// result 記錄解決 checkpoint 的結果，成功後不再記住類型；失敗時使用者可以再送一次 code
+func (t *checkpointTypes) result(accountID string, solved bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	typ := "unknown"
+	if e, ok := t.types[accountID]; ok && time.Now().Before(e.expiresAt) {
+		typ = e.typ
+	}
+	if solved {
+		delete(t.types, accountID)
+		checkpointsTotal.WithLabelValues(typ, checkpointSolved).Inc()
+	} else {
+		checkpointsTotal.WithLabelValues(typ, checkpointFailed).Inc()
+	}
+}
+
+// pendingSecrets 保存以 Cookie 連結時遇到 checkpoint 的憑證，解決 checkpoint、帳號確定屬於使用者後才加密儲存
+// 只保存在記憶體並在 checkpointTTL 後丟棄；程序重新啟動或由其他程序解決時不會保存憑證
+type pendingSecrets struct {
+	mu      sync.Mutex
+	secrets map[string]pendingSecret
+}
+
+type pendingSecret struct {
+	email     string
+	secret    model.ConnectionSecret
+	expiresAt time.Time
+}
+
+func newPendingSecrets() *pendingSecrets {
+	return &pendingSecrets{secrets: map[string]pendingSecret{}}
+}
+
+// put 保存 account_id 等待 checkpoint 的憑證
+func (p *pendingSecrets) put(accountID, email string, secret model.ConnectionSecret) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for id, s := range p.secrets {
+		if now.After(s.expiresAt) {
+			delete(p.secrets, id)
+		}
+	}
+	p.secrets[accountID] = pendingSecret{email: email, secret: secret, expiresAt: now.Add(checkpointTTL)}
+}
+
+// take 取出並移除 account_id 等待中的憑證，只有同一個使用者可以取出
+func (p *pendingSecrets) take(accountID, email string) (*model.ConnectionSecret, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.secrets[accountID]
+	if !ok || s.email != email || time.Now().After(s.expiresAt) {
+		return nil, false
+	}
+	delete(p.secrets, accountID)
+	return &s.secret, true
+}
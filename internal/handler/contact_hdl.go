@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"chatsheet/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ContactHandler struct {
+	contactSvc *service.ContactService
+}
+
+func NewContactHandler(contactSvc *service.ContactService) *ContactHandler {
+	return &ContactHandler{contactSvc: contactSvc}
+}
+
+// @Summary 查詢 LinkedIn 個人檔案
+// @Description 透過連結的帳號查詢個人檔案 (職稱、公司、地點、網址)，結果會快取並用於補充對話參與者的資訊
+// @Tags contacts
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "Unipile account_id"
+// @Param identifier path string true "LinkedIn provider id 或公開識別碼"
+// @Param refresh query bool false "忽略快取，重新向 Unipile 取得"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=model.Contact}
+// @Failure 404 {object} ErrorResponse "帳號或個人檔案不存在"
+// @Router /accounts/{id}/profiles/{identifier} [get]
+func (h *ContactHandler) GetProfile(c *gin.Context) {
+	refresh := c.Query("refresh") == "true"
+
+	contact, err := h.contactSvc.GetProfile(c.Request.Context(), c.GetString("email"), c.Param("id"), c.Param("identifier"), refresh)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Get success",
+		"profile": contact,
+	})
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"chatsheet/internal/apperr"
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError 描述單一欄位的驗證錯誤
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+}
+
+// bindingError 將 ShouldBindJSON 的錯誤轉換為 apperr.ErrValidation
+// 若是欄位驗證失敗，會在 details 中列出每個欄位與違反的規則
+func bindingError(err error) error {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return apperr.Wrap(apperr.ErrValidation, "Invalid request body", err)
+	}
+
+	fields := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		fields = append(fields, FieldError{Field: fe.Field(), Rule: fe.Tag()})
+	}
+
+	return apperr.Wrap(apperr.ErrValidation, "Invalid request body", err).WithDetails(fields)
+}
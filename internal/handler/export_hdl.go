@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/service"
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+type ExportHandler struct {
+	exportSvc *service.ExportService
+}
+
+func NewExportHandler(exportSvc *service.ExportService) *ExportHandler {
+	return &ExportHandler{exportSvc: exportSvc}
+}
+
+// messageExportHeader 匯出 CSV / XLSX 的欄位
+var messageExportHeader = []string{"sent_at", "direction", "sender", "recipient", "text", "attachments", "chat_name", "chat_id", "message_id"}
+
+// xlsxSheet 匯出 XLSX 的工作表名稱
+const xlsxSheet = "Messages"
+
+// @Summary 匯出訊息
+// @Description 以 CSV、XLSX 或 JSONL 串流匯出帳號已同步到本地的訊息，每則訊息一列 (由新到舊)
+// @Tags inbox
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "Unipile account_id"
+// @Param format query string false "csv、xlsx 或 jsonl (預設)"
+// @Param from query string false "起始時間 (含)，RFC3339 或 YYYY-MM-DD"
+// @Param to query string false "結束時間，RFC3339 (不含) 或 YYYY-MM-DD (含當天)"
+// @Param chat_id query string false "只匯出此對話"
+// @Param direction query string false "inbound 或 outbound"
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Produce application/x-ndjson
+// @Failure 400 {object} ErrorResponse "篩選條件錯誤"
+// @Failure 404 {object} ErrorResponse "帳號不存在"
+// @Router /accounts/{id}/export [get]
+func (h *ExportHandler) Export(c *gin.Context) {
+	filter, err := exportFilter(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	format := c.DefaultQuery("format", "jsonl")
+	filename := "messages-" + time.Now().UTC().Format("20060102T150405Z")
+
+	var writeHeader, flush func() error
+	var write func(service.ExportRow) error
+
+	switch format {
+	case "csv":
+		w := csv.NewWriter(c.Writer)
+		writeHeader = func() error { return w.Write(messageExportHeader) }
+		write = func(r service.ExportRow) error { return w.Write(exportRecord(r)) }
+		flush = func() error {
+			w.Flush()
+			return w.Error()
+		}
+	case "xlsx":
+		// XLSX 是 zip 檔，必須在最後才能輸出
+		// StreamWriter 在資料量大時會暫存到磁碟，不會把整份檔案留在記憶體
+		f := excelize.NewFile()
+		defer f.Close()
+		if err := f.SetSheetName(f.GetSheetName(0), xlsxSheet); err != nil {
+			c.Error(err)
+			return
+		}
+		sw, err := f.NewStreamWriter(xlsxSheet)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		row := 1
+		writeRow := func(values []string) error {
+			cells := make([]any, len(values))
+			for i, v := range values {
+				cells[i] = v
+			}
+			cell, _ := excelize.CoordinatesToCellName(1, row)
+			row++
+			return sw.SetRow(cell, cells)
+		}
+		writeHeader = func() error { return writeRow(messageExportHeader) }
+		write = func(r service.ExportRow) error { return writeRow(exportRecord(r)) }
+		flush = func() error {
+			if err := sw.Flush(); err != nil {
+				return err
+			}
+			return f.Write(c.Writer)
+		}
+	case "jsonl":
+		enc := json.NewEncoder(c.Writer)
+		writeHeader = func() error { return nil }
+		write = func(r service.ExportRow) error { return enc.Encode(r) }
+		flush = func() error { return nil }
+	default:
+		c.Error(apperr.Validation("format must be csv, xlsx or jsonl"))
+		return
+	}
+
+	// 第一列資料寫出前才送出 header，帳號不存在等錯誤仍能以 JSON 回應
+	started := false
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+
+		switch format {
+		case "csv":
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+		case "xlsx":
+			c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		case "jsonl":
+			c.Header("Content-Type", "application/x-ndjson")
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.`+format+`"`)
+		c.Status(http.StatusOK)
+
+		return writeHeader()
+	}
+
+	err = h.exportSvc.Export(c.Request.Context(), c.GetString("email"), c.Param("id"), filter, func(r service.ExportRow) error {
+		if err := start(); err != nil {
+			return err
+		}
+		return write(r)
+	})
+	if err != nil && !started {
+		c.Error(err)
+		return
+	}
+	if err == nil {
+		// 沒有任何訊息時仍然輸出只有標題列的檔案
+		if err = start(); err == nil {
+			err = flush()
+		}
+	}
+	if err != nil {
+		// 回應已經開始輸出，無法再改變狀態碼，只能記錄錯誤
+		slog.ErrorContext(c.Request.Context(), "Failed to export messages", "account_id", c.Param("id"), "err", err)
+	}
+}
+
+// exportRecord 將一列轉成 CSV / XLSX 的欄位
+// 訊息內容與名稱來自 LinkedIn 上的其他人，以 spreadsheetCell 避免被試算表當成公式執行
+func exportRecord(r service.ExportRow) []string {
+	record := []string{
+		r.SentAt.UTC().Format(time.RFC3339), r.Direction, r.Sender, r.Recipient, r.Text,
+		strings.Join(r.Attachments, "; "), r.ChatName, r.ChatID, r.MessageID,
+	}
+	for i, v := range record {
+		record[i] = spreadsheetCell(v)
+	}
+	return record
+}
+
+// exportFilter 解析匯出的篩選條件
+func exportFilter(c *gin.Context) (service.ExportFilter, error) {
+	filter := service.ExportFilter{
+		ChatID:    c.Query("chat_id"),
+		Direction: c.Query("direction"),
+	}
+
+	var err error
+	if filter.Since, err = parseTimeQuery(c.Query("from"), false); err != nil {
+		return filter, apperr.Wrap(apperr.ErrValidation, "from must be RFC3339 or YYYY-MM-DD", err)
+	}
+	if filter.Until, err = parseTimeQuery(c.Query("to"), true); err != nil {
+		return filter, apperr.Wrap(apperr.ErrValidation, "to must be RFC3339 or YYYY-MM-DD", err)
+	}
+
+	return filter, nil
+}
+
+// parseTimeQuery 解析 RFC3339 或 YYYY-MM-DD (UTC)
+// endOfDay 為 true 時，日期代表包含當天，回傳隔天 00:00
+func parseTimeQuery(s string, endOfDay bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"chatsheet/internal/service"
+	"testing"
+	"time"
+)
+
+func TestExportRecordEscapesFormulas(t *testing.T) {
+	r := service.ExportRow{
+		MessageID:   "m1",
+		ChatID:      "c1",
+		ChatName:    "@team",
+		SentAt:      time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Direction:   "inbound",
+		Sender:      "+1 555 0100",
+		Recipient:   "\tme",
+		Text:        `=HYPERLINK("http://evil.example","click")`,
+		Attachments: []string{"-2+3", "ok.pdf"},
+	}
+
+	got := exportRecord(r)
+	want := []string{
+		"2025-01-01T00:00:00Z", "inbound", "'+1 555 0100", "'\tme", `'=HYPERLINK("http://evil.example","click")`,
+		"'-2+3; ok.pdf", "'@team", "c1", "m1",
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("cell %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSpreadsheetCell(t *testing.T) {
+	for in, want := range map[string]string{
+		"":        "",
+		"hello":   "hello",
+		"a=b":     "a=b",
+		"=1+1":    "'=1+1",
+		"\rfoo":   "'\rfoo",
+		"@SUM(1)": "'@SUM(1)",
+	} {
+		if got := spreadsheetCell(in); got != want {
+			t.Errorf("spreadsheetCell(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
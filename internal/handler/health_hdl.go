@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"chatsheet/internal/health"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{checker: checker}
+}
+
+// @Summary 存活檢查
+// @Description 程序可以處理請求即回應 200，不檢查任何相依服務
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /healthz [get]
+func (h *HealthHandler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// @Summary 就緒檢查
+// @Description 檢查資料庫、結構版本、Unipile 與工作佇列，回傳每一項的結果；
+// @Description required 的檢查失敗或程序即將關閉 (收到 SIGTERM) 時回應 503
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report
+// @Failure 503 {object} health.Report "未就緒"
+// @Router /readyz [get]
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	report := h.checker.Ready(c.Request.Context())
+
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	// 探測結果不可被快取
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, report)
+}
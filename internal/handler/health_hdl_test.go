@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"chatsheet/internal/health"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newHealthRouter(checker *health.Checker) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h := NewHealthHandler(checker)
+	r.GET("/healthz", h.Healthz)
+	r.GET("/readyz", h.Readyz)
+	return r
+}
+
+func getReadyz(t *testing.T, r http.Handler) (int, health.Report, http.Header) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var report health.Report
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode report: %v\n%s", err, w.Body)
+	}
+	return w.Code, report, w.Header()
+}
+
+func TestReadyzStatusCodes(t *testing.T) {
+	var dbErr error
+	checker := health.NewChecker(time.Second)
+	checker.Add("database", true, func(ctx context.Context) (any, error) { return nil, dbErr })
+	checker.Add("unipile", false, func(ctx context.Context) (any, error) { return nil, errors.New("unreachable") })
+	r := newHealthRouter(checker)
+
+	// 只有非 required 的檢查失敗：仍然接受流量
+	code, report, header := getReadyz(t, r)
+	if code != http.StatusOK || report.Status != health.StatusDegraded || report.Checks["unipile"].Error != "unreachable" {
+		t.Errorf("degraded = %d %+v", code, report)
+	}
+	if header.Get("Cache-Control") != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", header.Get("Cache-Control"))
+	}
+
+	dbErr = errors.New("connection refused")
+	code, report, _ = getReadyz(t, r)
+	if code != http.StatusServiceUnavailable || report.Status != health.StatusFail || report.Checks["database"].Error != "connection refused" {
+		t.Errorf("failing = %d %+v", code, report)
+	}
+
+	dbErr = nil
+	checker.SetDraining()
+	code, report, _ = getReadyz(t, r)
+	if code != http.StatusServiceUnavailable || report.Status != health.StatusDraining || report.Checks != nil {
+		t.Errorf("draining = %d %+v", code, report)
+	}
+
+	// 存活檢查不受相依服務與 draining 影響
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"status":"ok"}` {
+		t.Errorf("healthz = %d %s", w.Code, w.Body)
+	}
+}
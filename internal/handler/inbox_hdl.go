@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/service"
+	"chatsheet/internal/unipile"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSendBodySize 送出訊息 (含附件) 的請求大小上限
+const maxSendBodySize = 15 << 20
+
+// SendMessageRequest 送出訊息的請求，可使用 JSON 或 multipart/form-data (附件欄位為 attachments)
+type SendMessageRequest struct {
+	Text string `json:"text" form:"text"`
+}
+
+// StartChatRequest 建立新對話的請求，可使用 JSON 或 multipart/form-data (附件欄位為 attachments)
+type StartChatRequest struct {
+	AttendeeIDs []string `json:"attendee_ids" form:"attendee_ids" binding:"required,min=1"` // 對方的 provider id
+	Text        string   `json:"text" form:"text"`
+}
+
+type InboxHandler struct {
+	inboxSvc *service.InboxService
+}
+
+func NewInboxHandler(inboxSvc *service.InboxService) *InboxHandler {
+	return &InboxHandler{inboxSvc: inboxSvc}
+}
+
+// @Summary 對話列表
+// @Description 列出已連結帳號的對話 (由新到舊)，附帶參與者資訊
+// @Tags inbox
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "Unipile account_id"
+// @Param cursor query string false "上一頁回傳的 next_cursor"
+// @Param limit query int false "每頁筆數 (最多 200)"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=service.InboxPage[service.InboxChat]}
+// @Failure 401 {object} ErrorResponse "未授權"
+// @Failure 404 {object} ErrorResponse "帳號不存在"
+// @Failure 502 {object} ErrorResponse "Unipile 錯誤"
+// @Router /accounts/{id}/chats [get]
+func (h *InboxHandler) ListChats(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	page, err := h.inboxSvc.ListChats(c.Request.Context(), c.GetString("email"), c.Param("id"), c.Query("cursor"), limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Get success",
+		"chats":       page.Items,
+		"next_cursor": page.NextCursor,
+	})
+}
+
+// @Summary 同步進度
+// @Description 取得帳號同步到本地的進度；回填完成後對話與訊息會直接從本地讀取
+// @Tags inbox
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "Unipile account_id"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=model.SyncCheckpoint}
+// @Failure 401 {object} ErrorResponse "未授權"
+// @Failure 404 {object} ErrorResponse "帳號不存在"
+// @Router /accounts/{id}/sync [get]
+func (h *InboxHandler) SyncStatus(c *gin.Context) {
+	cp, err := h.inboxSvc.SyncStatus(c.Request.Context(), c.GetString("email"), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Get success",
+		"sync":    cp,
+	})
+}
+
+// @Summary 訊息列表
+// @Description 列出對話的訊息 (由新到舊)，附帶寄件者資訊
+// @Tags inbox
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param chat_id path string true "Unipile chat id"
+// @Param cursor query string false "上一頁回傳的 next_cursor"
+// @Param limit query int false "每頁筆數 (最多 200)"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=service.InboxPage[service.InboxMessage]}
+// @Failure 401 {object} ErrorResponse "未授權"
+// @Failure 404 {object} ErrorResponse "對話不存在"
+// @Failure 502 {object} ErrorResponse "Unipile 錯誤"
+// @Router /chats/{chat_id}/messages [get]
+func (h *InboxHandler) ListMessages(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	page, err := h.inboxSvc.ListMessages(c.Request.Context(), c.GetString("email"), c.Param("chat_id"), c.Query("cursor"), limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Get success",
+		"messages":    page.Items,
+		"next_cursor": page.NextCursor,
+	})
+}
+
+// @Summary 送出訊息
+// @Description 在對話中送出訊息 (回覆)，可附加檔案，送出的訊息會保存在本地
+// @Tags inbox
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param Idempotency-Key header string false "重送時使用相同的 key，訊息只會送出一次"
+// @Param chat_id path string true "Unipile chat id"
+// @Param text formData string false "訊息內容 (沒有附件時必填)"
+// @Param attachments formData file false "附件 (可多個)"
+// @Accept json,mpfd
+// @Produce json
+// @Success 201 {object} StandardResponse{data=model.Message}
+// @Failure 400 {object} ErrorResponse "請求格式錯誤"
+// @Failure 401 {object} ErrorResponse "未授權"
+// @Failure 404 {object} ErrorResponse "對話不存在"
+// @Failure 502 {object} ErrorResponse "Unipile 錯誤"
+// @Router /chats/{chat_id}/messages [post]
+func (h *InboxHandler) SendMessage(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSendBodySize)
+
+	var req SendMessageRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.Error(sendBindingError(err))
+		return
+	}
+
+	files, closeFiles, err := attachments(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer closeFiles()
+
+	msg, err := h.inboxSvc.SendMessage(c.Request.Context(), c.GetString("email"), c.Param("chat_id"), service.OutgoingMessage{Text: req.Text, Files: files})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Send success",
+		"sent":    msg,
+	})
+}
+
+// @Summary 建立新對話
+// @Description 以對方的 provider id 建立新對話並送出第一則訊息，可附加檔案
+// @Tags inbox
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param Idempotency-Key header string false "重送時使用相同的 key，對話只會建立一次"
+// @Param id path string true "Unipile account_id"
+// @Param attendee_ids formData []string true "對方的 provider id"
+// @Param text formData string false "訊息內容 (沒有附件時必填)"
+// @Param attachments formData file false "附件 (可多個)"
+// @Accept json,mpfd
+// @Produce json
+// @Success 201 {object} StandardResponse{data=service.StartedChat}
+// @Failure 400 {object} ErrorResponse "請求格式錯誤"
+// @Failure 401 {object} ErrorResponse "未授權"
+// @Failure 404 {object} ErrorResponse "帳號不存在"
+// @Failure 502 {object} ErrorResponse "Unipile 錯誤"
+// @Router /accounts/{id}/chats [post]
+func (h *InboxHandler) StartChat(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSendBodySize)
+
+	var req StartChatRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.Error(sendBindingError(err))
+		return
+	}
+
+	files, closeFiles, err := attachments(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer closeFiles()
+
+	started, err := h.inboxSvc.StartChat(c.Request.Context(), c.GetString("email"), c.Param("id"), req.AttendeeIDs, service.OutgoingMessage{Text: req.Text, Files: files})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Send success",
+		"chat_id": started.ChatID,
+		"sent":    started.Message,
+	})
+}
+
+// sendBindingError 與 bindingError 相同，但請求過大時回傳明確的訊息
+func sendBindingError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return apperr.Validation("Request body too large (max 15MB)")
+	}
+	return bindingError(err)
+}
+
+// attachments 開啟 multipart 請求中的附件，非 multipart 請求回傳空列表
+// 呼叫者必須在使用完附件後呼叫回傳的 close
+func attachments(c *gin.Context) ([]unipile.File, func(), error) {
+	if c.ContentType() != gin.MIMEMultipartPOSTForm || c.Request.MultipartForm == nil {
+		return nil, func() {}, nil
+	}
+
+	headers := c.Request.MultipartForm.File["attachments"]
+	opened := make([]multipart.File, 0, len(headers))
+	closeAll := func() {
+		for _, f := range opened {
+			f.Close()
+		}
+	}
+
+	files := make([]unipile.File, 0, len(headers))
+	for _, fh := range headers {
+		f, err := fh.Open()
+		if err != nil {
+			closeAll()
+			return nil, nil, apperr.Wrap(apperr.ErrValidation, "Invalid attachment", err)
+		}
+		opened = append(opened, f)
+		files = append(files, unipile.File{
+			Name:        fh.Filename,
+			ContentType: fh.Header.Get("Content-Type"),
+			Content:     f,
+		})
+	}
+
+	return files, closeAll, nil
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"chatsheet/internal/model"
+	"chatsheet/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SendInvitationRequest 送出連結邀請的請求
+type SendInvitationRequest struct {
+	ProviderID string `json:"provider_id" binding:"required"` // 對方的 provider id
+	Message    string `json:"message" binding:"max=300"`      // 附加的訊息 (選填)
+}
+
+type InvitationHandler struct {
+	invitationSvc *service.InvitationService
+}
+
+func NewInvitationHandler(invitationSvc *service.InvitationService) *InvitationHandler {
+	return &InvitationHandler{invitationSvc: invitationSvc}
+}
+
+// @Summary 送出連結邀請
+// @Description 透過連結的帳號向對方送出 LinkedIn 連結邀請，可附加 300 字以內的訊息
+// @Tags invitations
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "Unipile account_id"
+// @Param request body SendInvitationRequest true "邀請內容"
+// @Accept json
+// @Produce json
+// @Success 201 {object} StandardResponse{data=model.Invitation}
+// @Failure 400 {object} ErrorResponse "請求格式錯誤"
+// @Failure 404 {object} ErrorResponse "帳號不存在"
+// @Failure 502 {object} ErrorResponse "Unipile 錯誤"
+// @Router /accounts/{id}/invitations [post]
+func (h *InvitationHandler) Send(c *gin.Context) {
+	var req SendInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(bindingError(err))
+		return
+	}
+
+	inv, err := h.invitationSvc.Send(c.Request.Context(), c.GetString("email"), c.Param("id"), req.ProviderID, req.Message)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Invite success",
+		"invitation": inv,
+	})
+}
+
+// @Summary 待回應的邀請
+// @Description 列出帳號尚未回應的已送出 (sent) 或收到 (received) 的邀請
+// @Tags invitations
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "Unipile account_id"
+// @Param direction query string false "sent (預設) 或 received"
+// @Param cursor query string false "上一頁回傳的 next_cursor"
+// @Param limit query int false "每頁筆數 (最多 200)"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=[]model.Invitation}
+// @Failure 400 {object} ErrorResponse "direction 錯誤"
+// @Failure 404 {object} ErrorResponse "帳號不存在"
+// @Router /accounts/{id}/invitations [get]
+func (h *InvitationHandler) List(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	direction := c.DefaultQuery("direction", model.InvitationSent)
+
+	invs, next, err := h.invitationSvc.List(c.Request.Context(), c.GetString("email"), c.Param("id"), direction, c.Query("cursor"), limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Get success",
+		"invitations": invs,
+		"next_cursor": next,
+	})
+}
+
+// @Summary 接受邀請
+// @Description 接受帳號收到的連結邀請
+// @Tags invitations
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "Unipile account_id"
+// @Param invitation_id path string true "Unipile invitation id"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=model.Invitation}
+// @Failure 404 {object} ErrorResponse "帳號或邀請不存在"
+// @Failure 409 {object} ErrorResponse "邀請已回應"
+// @Router /accounts/{id}/invitations/{invitation_id}/accept [post]
+func (h *InvitationHandler) Accept(c *gin.Context) {
+	inv, err := h.invitationSvc.Accept(c.Request.Context(), c.GetString("email"), c.Param("id"), c.Param("invitation_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Accept success",
+		"invitation": inv,
+	})
+}
+
+// @Summary 拒絕邀請
+// @Description 拒絕帳號收到的連結邀請
+// @Tags invitations
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "Unipile account_id"
+// @Param invitation_id path string true "Unipile invitation id"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=model.Invitation}
+// @Failure 404 {object} ErrorResponse "帳號或邀請不存在"
+// @Failure 409 {object} ErrorResponse "邀請已回應"
+// @Router /accounts/{id}/invitations/{invitation_id}/decline [post]
+func (h *InvitationHandler) Decline(c *gin.Context) {
+	inv, err := h.invitationSvc.Decline(c.Request.Context(), c.GetString("email"), c.Param("id"), c.Param("invitation_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Decline success",
+		"invitation": inv,
+	})
+}
+
+// @Summary 撤回邀請
+// @Description 撤回帳號已送出且尚未被回應的連結邀請
+// @Tags invitations
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "Unipile account_id"
+// @Param invitation_id path string true "Unipile invitation id"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=model.Invitation}
+// @Failure 404 {object} ErrorResponse "帳號或邀請不存在"
+// @Failure 409 {object} ErrorResponse "邀請已回應"
+// @Router /accounts/{id}/invitations/{invitation_id} [delete]
+func (h *InvitationHandler) Withdraw(c *gin.Context) {
+	inv, err := h.invitationSvc.Withdraw(c.Request.Context(), c.GetString("email"), c.Param("id"), c.Param("invitation_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Withdraw success",
+		"invitation": inv,
+	})
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"chatsheet/internal/model"
+	"chatsheet/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateLimitsRequest 設定帳號配額與工作時間的請求
+type UpdateLimitsRequest struct {
+	Timezone      string   `json:"timezone" binding:"required"`   // IANA 時區，例如 Asia/Taipei
+	WorkStart     string   `json:"work_start" binding:"required"` // HH:MM
+	WorkEnd       string   `json:"work_end" binding:"required"`   // HH:MM，24:00 代表到當天結束
+	WorkDays      []string `json:"work_days"`                     // mon ~ sun，空白代表每天
+	InviteDaily   int      `json:"invite_daily" binding:"min=0"`  // 0 代表不限制
+	InviteWeekly  int      `json:"invite_weekly" binding:"min=0"`
+	MessageDaily  int      `json:"message_daily" binding:"min=0"`
+	MessageWeekly int      `json:"message_weekly" binding:"min=0"`
+}
+
+type QuotaHandler struct {
+	quotaSvc *service.QuotaService
+}
+
+func NewQuotaHandler(quotaSvc *service.QuotaService) *QuotaHandler {
+	return &QuotaHandler{quotaSvc: quotaSvc}
+}
+
+// @Summary 帳號配額
+// @Description 取得帳號的配額設定、工作時間，以及各動作本日與本週的剩餘配額
+// @Tags accounts
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "Unipile account_id"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=service.AccountQuota}
+// @Failure 404 {object} ErrorResponse "帳號不存在"
+// @Router /accounts/{id}/limits [get]
+func (h *QuotaHandler) Get(c *gin.Context) {
+	quota, err := h.quotaSvc.Get(c.Request.Context(), c.GetString("email"), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Get success",
+		"limits":  quota,
+	})
+}
+
+// @Summary 設定帳號配額
+// @Description 設定帳號的每日與每週配額 (邀請、訊息) 及工作時間，超過配額或不在工作時間的動作回傳 429
+// @Tags accounts
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "Unipile account_id"
+// @Param request body UpdateLimitsRequest true "配額設定"
+// @Accept json
+// @Produce json
+// @Success 200 {object} StandardResponse{data=service.AccountQuota}
+// @Failure 400 {object} ErrorResponse "請求格式錯誤"
+// @Failure 404 {object} ErrorResponse "帳號不存在"
+// @Router /accounts/{id}/limits [put]
+func (h *QuotaHandler) Update(c *gin.Context) {
+	var req UpdateLimitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(bindingError(err))
+		return
+	}
+
+	quota, err := h.quotaSvc.Update(c.Request.Context(), c.GetString("email"), c.Param("id"), model.AccountLimits{
+		Timezone:      req.Timezone,
+		WorkStart:     req.WorkStart,
+		WorkEnd:       req.WorkEnd,
+		WorkDays:      model.StringList(req.WorkDays),
+		InviteDaily:   req.InviteDaily,
+		InviteWeekly:  req.InviteWeekly,
+		MessageDaily:  req.MessageDaily,
+		MessageWeekly: req.MessageWeekly,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Update success",
+		"limits":  quota,
+	})
+}
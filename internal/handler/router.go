@@ -2,13 +2,18 @@ package handler
 
 import (
 	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/metrics"
 	"chatsheet/internal/middleware"
+	"chatsheet/internal/service"
 	"net/http"
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 // @title Chatsheet API
@@ -16,24 +21,63 @@ import (
 // @description An app for connecting user's LinkedIn account by Unipile's native authentication。
 // @host localhost:8080
 // @BasePath /
-func SetupRouter(cfg *config.AppConfig, userHdl *UserHandler, unipileHdl *UnipileHandler) *gin.Engine {
-	r := gin.Default()
+func SetupRouter(cfg *config.AppConfig, idemSvc *service.IdempotencyService, rateLimitSvc *service.RateLimitService, userHdl *UserHandler, unipileHdl *UnipileHandler, auditHdl *AuditHandler, inboxHdl *InboxHandler, exportHdl *ExportHandler, searchHdl *SearchHandler, contactHdl *ContactHandler, invitationHdl *InvitationHandler, campaignHdl *CampaignHandler, quotaHdl *QuotaHandler, templateHdl *TemplateHandler, scheduledHdl *ScheduledMessageHandler, webhookHdl *WebhookHandler, webhookEndpointHdl *WebhookEndpointHandler, taskHdl *TaskHandler, healthHdl *HealthHandler) *gin.Engine {
+	// 不使用 gin.Default() 的 Logger 與 Recovery，改以 slog 記錄
+	r := gin.New()
+
+	// request id 與請求日誌 (必須最先註冊，才能記錄到最後的狀態碼)
+	r.Use(middleware.RequestIDMiddleware())
+	// 探測請求頻繁，成功時不記錄
+	r.Use(middleware.LoggerMiddleware("/healthz", "/readyz"))
+
+	// 請求延遲指標 (在錯誤處理之前註冊，才能記錄最後的狀態碼)
+	r.Use(middleware.MetricsMiddleware())
+
+	// 分散式追蹤 (span 以路由命名)，之後的 handler 與 repository 以 c.Request.Context() 接續同一個 trace
+	r.Use(otelgin.Middleware(cfg.Tracing.ServiceName))
+
+	// 統一錯誤回應 (必須在 AuthMiddleware 等回報錯誤的 middleware 之前註冊)
+	r.Use(middleware.ErrorMiddleware())
+
+	// handler panic 時回應 500 (在 ErrorMiddleware 之後註冊，由它輸出錯誤回應)
+	r.Use(middleware.RecoveryMiddleware())
 
 	// CORS 設定
 	r.Use(middleware.CORSMiddleware(cfg.App.FrontendURL))
 
+	// Prometheus 指標，設定 metrics.listen 時改由另一個位址提供 (見 main)
+	if cfg.Metrics.Enabled && cfg.Metrics.Listen == "" && cfg.Metrics.Username != "" {
+		r.GET("/metrics", gin.BasicAuth(gin.Accounts{cfg.Metrics.Username: cfg.Metrics.Password}), gin.WrapH(metrics.Handler(10*time.Second)))
+	}
+
+	// 存活與就緒檢查 (orchestrator 與負載平衡器使用，不需要驗證)
+	r.GET("/healthz", healthHdl.Healthz)
+	r.GET("/readyz", healthHdl.Readyz)
+
 	// **Swagger 文件路由** (完成後取消註釋)
 	// r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// 限流 (config 的 rate_limit.policies)，/api 下的路由在驗證之後才檢查，才能以使用者為鍵
+	rateLimit := middleware.RateLimitMiddleware(rateLimitSvc)
+
 	authApi := r.Group("/auth")
+	authApi.Use(rateLimit)
 	{
 		authApi.POST("/signup", userHdl.Signup)
 		authApi.POST("/login", userHdl.Login)
 	}
 
+	// 外部服務的 webhook，以各自的密鑰驗證而非 JWT
+	webhookApi := r.Group("/webhooks")
+	{
+		webhookApi.POST("/unipile", webhookHdl.Unipile)
+	}
+
 	// 路由群組
 	api := r.Group("/api")
 	api.Use(middleware.AuthMiddleware(userHdl.AuthService))
+	api.Use(rateLimit)
+	api.Use(middleware.IdempotencyMiddleware(idemSvc))
 	{
 		unipileApi := api.Group("/unipile")
 		{
@@ -41,6 +85,89 @@ func SetupRouter(cfg *config.AppConfig, userHdl *UserHandler, unipileHdl *Unipil
 			unipileApi.POST("/linkedin/basic", unipileHdl.LinkedInBasic)
 			unipileApi.POST("/linkedin/cookie", unipileHdl.LinkedInCookie)
 			unipileApi.POST("/linkedin/checkpoint", unipileHdl.Checkpoint)
+			unipileApi.DELETE("/:account_id", unipileHdl.Remove)
+		}
+
+		accountsApi := api.Group("/accounts/:id")
+		{
+			accountsApi.GET("/chats", inboxHdl.ListChats)
+			accountsApi.POST("/chats", inboxHdl.StartChat)
+			accountsApi.GET("/sync", inboxHdl.SyncStatus)
+			accountsApi.GET("/export", exportHdl.Export)
+			accountsApi.GET("/profiles/:identifier", contactHdl.GetProfile)
+			accountsApi.GET("/invitations", invitationHdl.List)
+			accountsApi.POST("/invitations", invitationHdl.Send)
+			accountsApi.POST("/invitations/:invitation_id/accept", invitationHdl.Accept)
+			accountsApi.POST("/invitations/:invitation_id/decline", invitationHdl.Decline)
+			accountsApi.DELETE("/invitations/:invitation_id", invitationHdl.Withdraw)
+			accountsApi.GET("/limits", quotaHdl.Get)
+			accountsApi.PUT("/limits", quotaHdl.Update)
+		}
+
+		chatsApi := api.Group("/chats/:chat_id")
+		{
+			chatsApi.GET("/messages", inboxHdl.ListMessages)
+			chatsApi.POST("/messages", inboxHdl.SendMessage)
+		}
+
+		api.GET("/search", searchHdl.Search)
+
+		campaignsApi := api.Group("/campaigns")
+		{
+			campaignsApi.GET("", campaignHdl.List)
+			campaignsApi.POST("", campaignHdl.Create)
+			campaignsApi.GET("/:id", campaignHdl.Get)
+			campaignsApi.POST("/:id/pause", campaignHdl.Pause)
+			campaignsApi.POST("/:id/resume", campaignHdl.Resume)
+			campaignsApi.GET("/:id/enrollments", campaignHdl.ListEnrollments)
+			campaignsApi.POST("/:id/enrollments", campaignHdl.Enroll)
+			campaignsApi.DELETE("/:id/enrollments/:enrollment_id", campaignHdl.StopEnrollment)
+			campaignsApi.GET("/:id/history", campaignHdl.History)
+		}
+
+		templatesApi := api.Group("/templates")
+		{
+			templatesApi.GET("", templateHdl.List)
+			templatesApi.POST("", templateHdl.Create)
+			templatesApi.GET("/:id", templateHdl.Get)
+			templatesApi.PUT("/:id", templateHdl.Update)
+			templatesApi.DELETE("/:id", templateHdl.Delete)
+			templatesApi.POST("/:id/preview", templateHdl.Preview)
+		}
+
+		scheduledApi := api.Group("/scheduled-messages")
+		{
+			scheduledApi.GET("", scheduledHdl.List)
+			scheduledApi.POST("", scheduledHdl.Create)
+			scheduledApi.GET("/:id", scheduledHdl.Get)
+			scheduledApi.PUT("/:id", scheduledHdl.Update)
+			scheduledApi.DELETE("/:id", scheduledHdl.Cancel)
+		}
+
+		webhookEndpointsApi := api.Group("/webhook-endpoints")
+		{
+			webhookEndpointsApi.GET("", webhookEndpointHdl.List)
+			webhookEndpointsApi.POST("", webhookEndpointHdl.Create)
+			webhookEndpointsApi.GET("/:id", webhookEndpointHdl.Get)
+			webhookEndpointsApi.PUT("/:id", webhookEndpointHdl.Update)
+			webhookEndpointsApi.DELETE("/:id", webhookEndpointHdl.Delete)
+			webhookEndpointsApi.POST("/:id/rotate-secret", webhookEndpointHdl.RotateSecret)
+			webhookEndpointsApi.GET("/:id/deliveries", webhookEndpointHdl.ListDeliveries)
+			webhookEndpointsApi.POST("/:id/deliveries/:delivery_id/redeliver", webhookEndpointHdl.Redeliver)
+		}
+
+		meApi := api.Group("/me")
+		{
+			meApi.GET("/audit", auditHdl.ListMine)
+			meApi.GET("/audit/export", auditHdl.ExportMine)
+		}
+
+		adminApi := api.Group("/admin")
+		adminApi.Use(middleware.RequireAdmin())
+		{
+			adminApi.GET("/audit", auditHdl.ListAll)
+			adminApi.GET("/audit/export", auditHdl.ExportAll)
+			adminApi.GET("/tasks", taskHdl.List)
 		}
 	}
 
@@ -53,7 +180,7 @@ func SetupRouter(cfg *config.AppConfig, userHdl *UserHandler, unipileHdl *Unipil
 		// 確保不是發往 /api/ 的請求
 		if strings.HasPrefix(c.Request.URL.Path, "/api/") {
 			// 如果是 API 請求，但找不到對應路由，返回 404
-			c.JSON(http.StatusNotFound, gin.H{"error": "API 路由未找到"})
+			c.Error(apperr.NotFound("API 路由未找到"))
 			return
 		}
 
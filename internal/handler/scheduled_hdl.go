@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"chatsheet/internal/service"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScheduleRequest 排程訊息的內容與送出時間，send_at 與 local_time 二擇一
+type ScheduleRequest struct {
+	Text      string     `json:"text" binding:"required"`
+	SendAt    *time.Time `json:"send_at"`    // RFC 3339，例如 2026-01-02T09:00:00+01:00
+	LocalTime string     `json:"local_time"` // 對方時區的當地時間，例如 2026-01-02T09:00
+	Timezone  string     `json:"timezone"`   // 對方的 IANA 時區，使用 local_time 時必填
+}
+
+func (r ScheduleRequest) input() service.ScheduleInput {
+	in := service.ScheduleInput{Text: r.Text, LocalTime: r.LocalTime, Timezone: r.Timezone}
+	if r.SendAt != nil {
+		in.SendAt = *r.SendAt
+	}
+	return in
+}
+
+// CreateScheduledMessageRequest 建立排程訊息的請求，chat_id 與 provider_id 二擇一
+type CreateScheduledMessageRequest struct {
+	ScheduleRequest
+	AccountID  string `json:"account_id"`  // 以 provider_id 送出時必填
+	ChatID     string `json:"chat_id"`     // 送到既有的對話
+	ProviderID string `json:"provider_id"` // 以對方的 provider id 開始對話 (已有一對一對話時沿用)
+}
+
+type ScheduledMessageHandler struct {
+	scheduledSvc *service.ScheduledMessageService
+}
+
+func NewScheduledMessageHandler(scheduledSvc *service.ScheduledMessageService) *ScheduledMessageHandler {
+	return &ScheduledMessageHandler{scheduledSvc: scheduledSvc}
+}
+
+// @Summary 建立排程訊息
+// @Description 在指定時間透過連結帳號送出訊息；送出時間可用 send_at，或以 local_time 與 timezone 指定對方時區的當地時間
+// @Tags scheduled-messages
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param request body CreateScheduledMessageRequest true "訊息內容與送出時間"
+// @Accept json
+// @Produce json
+// @Success 201 {object} StandardResponse{data=model.ScheduledMessage}
+// @Failure 400 {object} ErrorResponse "請求格式錯誤"
+// @Failure 404 {object} ErrorResponse "帳號或對話不存在"
+// @Router /scheduled-messages [post]
+func (h *ScheduledMessageHandler) Create(c *gin.Context) {
+	var req CreateScheduledMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(bindingError(err))
+		return
+	}
+
+	msg, err := h.scheduledSvc.Create(c.Request.Context(), c.GetString("email"), service.ScheduledMessageInput{
+		ScheduleInput: req.input(),
+		AccountID:     req.AccountID,
+		ChatID:        req.ChatID,
+		ProviderID:    req.ProviderID,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":           "Schedule success",
+		"scheduled_message": msg,
+	})
+}
+
+// @Summary 排程訊息列表
+// @Description 依送出時間由晚到早列出排程訊息；失敗的訊息 status 為 failed，原因在 last_error
+// @Tags scheduled-messages
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param status query string false "scheduled、sending、sent、failed 或 canceled"
+// @Param cursor query string false "上一頁回傳的 next_cursor"
+// @Param limit query int false "每頁筆數 (最多 200)"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=[]model.ScheduledMessage}
+// @Failure 400 {object} ErrorResponse "status 錯誤"
+// @Router /scheduled-messages [get]
+func (h *ScheduledMessageHandler) List(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	msgs, next, err := h.scheduledSvc.List(c.Request.Context(), c.GetString("email"), c.Query("status"), c.Query("cursor"), limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "Get success",
+		"scheduled_messages": msgs,
+		"next_cursor":        next,
+	})
+}
+
+// @Summary 排程訊息
+// @Description 取得排程訊息與送出結果
+// @Tags scheduled-messages
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "排程訊息 ID"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=model.ScheduledMessage}
+// @Failure 404 {object} ErrorResponse "排程訊息不存在"
+// @Router /scheduled-messages/{id} [get]
+func (h *ScheduledMessageHandler) Get(c *gin.Context) {
+	msg, err := h.scheduledSvc.Get(c.Request.Context(), c.GetString("email"), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Get success",
+		"scheduled_message": msg,
+	})
+}
+
+// @Summary 修改排程訊息
+// @Description 修改等待送出的訊息的內容與送出時間；已失敗的訊息修改後會重新排程
+// @Tags scheduled-messages
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "排程訊息 ID"
+// @Param request body ScheduleRequest true "訊息內容與送出時間"
+// @Accept json
+// @Produce json
+// @Success 200 {object} StandardResponse{data=model.ScheduledMessage}
+// @Failure 400 {object} ErrorResponse "請求格式錯誤"
+// @Failure 404 {object} ErrorResponse "排程訊息不存在"
+// @Failure 409 {object} ErrorResponse "訊息正在送出、已送出或已取消"
+// @Router /scheduled-messages/{id} [put]
+func (h *ScheduledMessageHandler) Update(c *gin.Context) {
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(bindingError(err))
+		return
+	}
+
+	msg, err := h.scheduledSvc.Update(c.Request.Context(), c.GetString("email"), c.Param("id"), req.input())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Update success",
+		"scheduled_message": msg,
+	})
+}
+
+// @Summary 取消排程訊息
+// @Description 取消等待送出或已失敗的訊息
+// @Tags scheduled-messages
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "排程訊息 ID"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=model.ScheduledMessage}
+// @Failure 404 {object} ErrorResponse "排程訊息不存在"
+// @Failure 409 {object} ErrorResponse "訊息正在送出、已送出或已取消"
+// @Router /scheduled-messages/{id} [delete]
+func (h *ScheduledMessageHandler) Cancel(c *gin.Context) {
+	msg, err := h.scheduledSvc.Cancel(c.Request.Context(), c.GetString("email"), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Cancel success",
+		"scheduled_message": msg,
+	})
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchHandler struct {
+	searchSvc *service.SearchService
+}
+
+func NewSearchHandler(searchSvc *service.SearchService) *SearchHandler {
+	return &SearchHandler{searchSvc: searchSvc}
+}
+
+// @Summary 搜尋訊息
+// @Description 全文搜尋使用者所有帳號已同步到本地的訊息內文與寄件者名稱，依符合程度排序，符合的字詞以 <mark> 標記
+// @Tags inbox
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param q query string true "搜尋字詞"
+// @Param account_id query string false "只搜尋此 Unipile account_id"
+// @Param from query string false "起始時間 (含)，RFC3339 或 YYYY-MM-DD"
+// @Param to query string false "結束時間，RFC3339 (不含) 或 YYYY-MM-DD (含當天)"
+// @Param cursor query string false "上一頁回傳的 next_cursor"
+// @Param limit query int false "每頁筆數 (最多 200)"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=[]service.SearchResult}
+// @Failure 400 {object} ErrorResponse "缺少 q 或篩選條件錯誤"
+// @Failure 404 {object} ErrorResponse "帳號不存在"
+// @Router /search [get]
+func (h *SearchHandler) Search(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	query := service.SearchQuery{
+		Query:     c.Query("q"),
+		AccountID: c.Query("account_id"),
+		Cursor:    c.Query("cursor"),
+		Limit:     limit,
+	}
+
+	var err error
+	if query.Since, err = parseTimeQuery(c.Query("from"), false); err != nil {
+		c.Error(apperr.Wrap(apperr.ErrValidation, "from must be RFC3339 or YYYY-MM-DD", err))
+		return
+	}
+	if query.Until, err = parseTimeQuery(c.Query("to"), true); err != nil {
+		c.Error(apperr.Wrap(apperr.ErrValidation, "to must be RFC3339 or YYYY-MM-DD", err))
+		return
+	}
+
+	results, next, err := h.searchSvc.Search(c.Request.Context(), c.GetString("email"), query)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Get success",
+		"results":     results,
+		"next_cursor": next,
+	})
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"chatsheet/internal/cron"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TaskHandler struct {
+	scheduler *cron.Scheduler
+}
+
+func NewTaskHandler(scheduler *cron.Scheduler) *TaskHandler {
+	return &TaskHandler{scheduler: scheduler}
+}
+
+// @Summary 定期工作
+// @Description 管理員列出 config.yml 中排程的維護工作、下一次執行時間與最後一次的執行結果
+// @Tags admin
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Produce json
+// @Success 200 {object} StandardResponse{data=[]cron.TaskStatus}
+// @Failure 403 {object} ErrorResponse "非管理員"
+// @Router /admin/tasks [get]
+func (h *TaskHandler) List(c *gin.Context) {
+	tasks, err := h.scheduler.List(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Get success",
+		"tasks":   tasks,
+	})
+}
@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"chatsheet/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TemplateRequest 建立或更新訊息範本的請求
+type TemplateRequest struct {
+	Name string `json:"name" binding:"required,max=255"`
+	Body string `json:"body" binding:"required"` // text/template 語法，例如 Hi {{.FirstName}}
+}
+
+// PreviewTemplateRequest 預覽訊息範本的請求
+type PreviewTemplateRequest struct {
+	AccountID  string `json:"account_id" binding:"required"` // 查詢個人檔案與送出訊息的 Unipile account_id
+	Identifier string `json:"identifier" binding:"required"` // 對方的 provider id 或公開識別碼
+}
+
+type TemplateHandler struct {
+	templateSvc *service.TemplateService
+}
+
+func NewTemplateHandler(templateSvc *service.TemplateService) *TemplateHandler {
+	return &TemplateHandler{templateSvc: templateSvc}
+}
+
+// @Summary 建立訊息範本
+// @Description 建立訊息範本，可使用 {{.FirstName}}、{{.LastName}}、{{.Name}}、{{.Company}}、{{.Headline}}、{{.Location}}、{{.ProfileURL}} 與 {{.Sender.Name}} 等變數
+// @Tags templates
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param request body TemplateRequest true "範本內容"
+// @Accept json
+// @Produce json
+// @Success 201 {object} StandardResponse{data=model.MessageTemplate}
+// @Failure 400 {object} ErrorResponse "範本語法錯誤或使用不存在的變數"
+// @Failure 409 {object} ErrorResponse "名稱重複"
+// @Router /templates [post]
+func (h *TemplateHandler) Create(c *gin.Context) {
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(bindingError(err))
+		return
+	}
+
+	tmpl, err := h.templateSvc.Create(c.Request.Context(), c.GetString("email"), service.TemplateInput{Name: req.Name, Body: req.Body})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Create success",
+		"template": tmpl,
+	})
+}
+
+// @Summary 訊息範本列表
+// @Description 依名稱列出使用者的訊息範本
+// @Tags templates
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Produce json
+// @Success 200 {object} StandardResponse{data=[]model.MessageTemplate}
+// @Router /templates [get]
+func (h *TemplateHandler) List(c *gin.Context) {
+	tmpls, err := h.templateSvc.List(c.Request.Context(), c.GetString("email"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Get success",
+		"templates": tmpls,
+	})
+}
+
+// @Summary 訊息範本
+// @Description 取得使用者的訊息範本
+// @Tags templates
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "範本 ID"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=model.MessageTemplate}
+// @Failure 404 {object} ErrorResponse "範本不存在"
+// @Router /templates/{id} [get]
+func (h *TemplateHandler) Get(c *gin.Context) {
+	tmpl, err := h.templateSvc.Get(c.Request.Context(), c.GetString("email"), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Get success",
+		"template": tmpl,
+	})
+}
+
+// @Summary 更新訊息範本
+// @Description 覆寫範本的名稱與內容
+// @Tags templates
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "範本 ID"
+// @Param request body TemplateRequest true "範本內容"
+// @Accept json
+// @Produce json
+// @Success 200 {object} StandardResponse{data=model.MessageTemplate}
+// @Failure 400 {object} ErrorResponse "範本語法錯誤或使用不存在的變數"
+// @Failure 404 {object} ErrorResponse "範本不存在"
+// @Failure 409 {object} ErrorResponse "名稱重複"
+// @Router /templates/{id} [put]
+func (h *TemplateHandler) Update(c *gin.Context) {
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(bindingError(err))
+		return
+	}
+
+	tmpl, err := h.templateSvc.Update(c.Request.Context(), c.GetString("email"), c.Param("id"), service.TemplateInput{Name: req.Name, Body: req.Body})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Update success",
+		"template": tmpl,
+	})
+}
+
+// @Summary 刪除訊息範本
+// @Tags templates
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "範本 ID"
+// @Produce json
+// @Success 200 {object} StandardResponse
+// @Failure 404 {object} ErrorResponse "範本不存在"
+// @Router /templates/{id} [delete]
+func (h *TemplateHandler) Delete(c *gin.Context) {
+	if err := h.templateSvc.Delete(c.Request.Context(), c.GetString("email"), c.Param("id")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Delete success",
+	})
+}
+
+// @Summary 預覽訊息範本
+// @Description 以帳號查詢對方的個人檔案 (優先使用快取) 並套用範本；對方沒有資料的變數列在 missing 中，這些變數請以 {{if}} 包住或改用其他變數
+// @Tags templates
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "範本 ID"
+// @Param request body PreviewTemplateRequest true "預覽的對象"
+// @Accept json
+// @Produce json
+// @Success 200 {object} StandardResponse{data=service.TemplatePreview}
+// @Failure 400 {object} ErrorResponse "請求格式錯誤"
+// @Failure 404 {object} ErrorResponse "範本、帳號或個人檔案不存在"
+// @Failure 502 {object} ErrorResponse "Unipile 錯誤"
+// @Router /templates/{id}/preview [post]
+func (h *TemplateHandler) Preview(c *gin.Context) {
+	var req PreviewTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(bindingError(err))
+		return
+	}
+
+	preview, err := h.templateSvc.Preview(c.Request.Context(), c.GetString("email"), c.Param("id"), req.AccountID, req.Identifier)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Preview success",
+		"preview": preview,
+	})
+}
@@ -1,9 +1,13 @@
 package handler
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
 
 	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/model"
 	"chatsheet/internal/service"
 	"chatsheet/internal/unipile"
 
@@ -13,12 +17,22 @@ import (
 type UnipileHandler struct {
 	cfg        *config.AppConfig
 	unipileSvc *service.UnipileService
+	credSvc    *service.CredentialService
+	audit      *service.AuditLogger
+
+	checkpoints *checkpointTypes // 指標使用的 checkpoint 類型
+	pending     *pendingSecrets  // 等待解決 checkpoint 的 Cookie
 }
 
-func NewUnipileHandler(cfg *config.AppConfig, unipileSvc *service.UnipileService) *UnipileHandler {
+func NewUnipileHandler(cfg *config.AppConfig, unipileSvc *service.UnipileService, credSvc *service.CredentialService, audit *service.AuditLogger) *UnipileHandler {
 	return &UnipileHandler{
 		cfg:        cfg,
 		unipileSvc: unipileSvc,
+		credSvc:    credSvc,
+		audit:      audit,
+
+		checkpoints: newCheckpointTypes(),
+		pending:     newPendingSecrets(),
 	}
 }
 
@@ -42,12 +56,17 @@ type UnipileCheckpointRequest struct {
 
 // handleUnipileResponse 封裝 Unipile 響應的處理邏輯
 // 它負責檢查是否為 Checkpoint，並將 account_id 儲存到 session 或返回給前端。
-func handleUnipileResponse(c *gin.Context, status int, response *unipile.CheckpointResponse, svc *service.UnipileService, userEmail string) {
+// secret 為以 Cookie 連結時的憑證，在帳號寫入 (確認屬於使用者) 後才加密儲存；遇到 checkpoint 時等到解決後再儲存
+func (h *UnipileHandler) handleUnipileResponse(c *gin.Context, status int, response *unipile.CheckpointResponse, userEmail string, secret *model.ConnectionSecret) {
 	if status == http.StatusAccepted { // 202 Accepted, Checkpoint
 		if response.Object == "Checkpoint" && response.Checkpoint != nil {
 			// **TODO: 儲存 AccountID 到 Redis/Session**
 			// 由於 CheckpointIntent 有 5 分鐘時限，AccountID 必須儲存並與 UserEmail 關聯。
 			// 為了簡化，這裡僅返回給前端，讓前端在下一步 Checkpoint 請求中傳回。
+			h.checkpoints.required(response.AccountID, response.Checkpoint.Type)
+			if secret != nil {
+				h.pending.put(response.AccountID, userEmail, *secret)
+			}
 
 			c.JSON(http.StatusAccepted, gin.H{
 				"message":         "需要解決 Checkpoint",
@@ -60,12 +79,29 @@ func handleUnipileResponse(c *gin.Context, status int, response *unipile.Checkpo
 
 	// 200 OK - 成功連接
 	if response.AccountID != "" {
-		_, err := svc.Create(c.Request.Context(), userEmail, "linkedin", response.AccountID)
+		acct, err := h.unipileSvc.Create(c.Request.Context(), userEmail, "linkedin", response.AccountID)
+		action := model.AuditAccountLink
+		if err == nil && acct.CreatedAt != nil && acct.UpdatedAt != nil && acct.UpdatedAt.After(*acct.CreatedAt) {
+			// 同一個使用者重新連結既有的帳號
+			action = model.AuditAccountReconnect
+		}
+		h.audit.Log(c.Request.Context(), newAuditEntry(c, userEmail, action, "unipile_account", response.AccountID, err))
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			c.Error(err)
 			return
 		}
 
+		// Checkpoint 的 account_id 與完成後相同，解決 checkpoint 時取出連結時的 Cookie
+		if secret == nil {
+			secret, _ = h.pending.take(response.AccountID, userEmail)
+		}
+		if secret != nil {
+			if err := h.credSvc.Save(c.Request.Context(), userEmail, response.AccountID, *secret); err != nil {
+				// 帳號已連結成功，儲存憑證失敗不應讓使用者重新登入
+				slog.ErrorContext(c.Request.Context(), "Failed to save connection credential", "account_id", response.AccountID, "err", err)
+			}
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"message":    "LinkedIn 帳號成功連結",
 			"account_id": response.AccountID,
@@ -74,7 +110,7 @@ func handleUnipileResponse(c *gin.Context, status int, response *unipile.Checkpo
 	}
 
 	// 其他非 Checkpoint 的成功響應，通常不應該發生
-	c.JSON(http.StatusInternalServerError, gin.H{"error": "Unipile 返回了未預期的成功響應"})
+	c.Error(apperr.New(apperr.ErrUpstream, "Unipile 返回了未預期的成功響應"))
 }
 
 // @Summary LinkedInBasic
@@ -90,13 +126,13 @@ func handleUnipileResponse(c *gin.Context, status int, response *unipile.Checkpo
 func (h *UnipileHandler) LinkedInBasic(c *gin.Context) {
 	emailAny, ok := c.Get("email") // 從 AuthMiddleware 取得
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.Error(apperr.Unauthorized("User not authenticated"))
 		return
 	}
 
 	var req UnipileLoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(bindingError(err))
 		return
 	}
 
@@ -107,16 +143,17 @@ func (h *UnipileHandler) LinkedInBasic(c *gin.Context) {
 		"password": req.Password,
 	}
 
-	// 2. 呼叫 Unipile API
+	// 2. 呼叫 Unipile API (用戶端中斷時仍完成請求，避免 Unipile 上的帳號與本地不一致)
 	var resp unipile.CheckpointResponse
-	status, err := unipile.PerformRequest(h.cfg.Unipile, unipile.AccountsEndpoint, unipileReq, &resp)
+	status, err := unipile.PerformRequest(context.WithoutCancel(c.Request.Context()), h.cfg.Unipile, unipile.AccountsEndpoint, unipileReq, &resp)
 	if err != nil {
-		c.JSON(status, gin.H{"error": err.Error()})
+		h.audit.Log(c.Request.Context(), newAuditEntry(c, emailAny.(string), model.AuditAccountLink, "unipile_account", "", err))
+		c.Error(err)
 		return
 	}
 
 	// 3. 處理響應
-	handleUnipileResponse(c, status, &resp, h.unipileSvc, emailAny.(string))
+	h.handleUnipileResponse(c, status, &resp, emailAny.(string), nil)
 }
 
 // @Summary LinkedInCookie
@@ -132,13 +169,13 @@ func (h *UnipileHandler) LinkedInBasic(c *gin.Context) {
 func (h *UnipileHandler) LinkedInCookie(c *gin.Context) {
 	emailAny, ok := c.Get("email") // 從 AuthMiddleware 取得
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.Error(apperr.Unauthorized("User not authenticated"))
 		return
 	}
 
 	var req UnipileCookieRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(bindingError(err))
 		return
 	}
 
@@ -149,16 +186,18 @@ func (h *UnipileHandler) LinkedInCookie(c *gin.Context) {
 		"user_agent":   req.UserAgent,
 	}
 
-	// 2. 呼叫 Unipile API
+	// 2. 呼叫 Unipile API (用戶端中斷時仍完成請求，避免 Unipile 上的帳號與本地不一致)
 	var resp unipile.CheckpointResponse
-	status, err := unipile.PerformRequest(h.cfg.Unipile, unipile.AccountsEndpoint, unipileReq, &resp)
+	status, err := unipile.PerformRequest(context.WithoutCancel(c.Request.Context()), h.cfg.Unipile, unipile.AccountsEndpoint, unipileReq, &resp)
 	if err != nil {
-		c.JSON(status, gin.H{"error": err.Error()})
+		h.audit.Log(c.Request.Context(), newAuditEntry(c, emailAny.(string), model.AuditAccountLink, "unipile_account", "", err))
+		c.Error(err)
 		return
 	}
 
-	// 3. 處理響應
-	handleUnipileResponse(c, status, &resp, h.unipileSvc, emailAny.(string))
+	// 3. 處理響應；帳號確定屬於使用者後才加密保存 Cookie，讓背景工作之後可以重新連線
+	secret := model.ConnectionSecret{AccessToken: req.AccessToken, UserAgent: req.UserAgent}
+	h.handleUnipileResponse(c, status, &resp, emailAny.(string), &secret)
 }
 
 // @Summary SolveCheckpoint
@@ -174,13 +213,13 @@ func (h *UnipileHandler) LinkedInCookie(c *gin.Context) {
 func (h *UnipileHandler) Checkpoint(c *gin.Context) {
 	emailAny, ok := c.Get("email") // 從 AuthMiddleware 取得
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.Error(apperr.Unauthorized("User not authenticated"))
 		return
 	}
 
 	var req UnipileCheckpointRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(bindingError(err))
 		return
 	}
 
@@ -191,17 +230,20 @@ func (h *UnipileHandler) Checkpoint(c *gin.Context) {
 		"code":       req.Code, // 可能是 2FA code 或 Phone Number
 	}
 
-	// 2. 呼叫 Unipile API
+	// 2. 呼叫 Unipile API (用戶端中斷時仍完成請求，避免 Unipile 上的帳號與本地不一致)
 	var resp unipile.CheckpointResponse
-	status, err := unipile.PerformRequest(h.cfg.Unipile, unipile.CheckpointEndpoint, unipileReq, &resp)
+	status, err := unipile.PerformRequest(context.WithoutCancel(c.Request.Context()), h.cfg.Unipile, unipile.CheckpointEndpoint, unipileReq, &resp)
+	// 解決後可能還需要下一個 checkpoint，由 handleUnipileResponse 記錄
+	h.checkpoints.result(req.AccountID, err == nil)
 	if err != nil {
-		// 檢查是否為 408 Timeout 或 400 Bad Request (Intent 銷毀)
-		c.JSON(status, gin.H{"error": err.Error()})
+		// 408 Timeout 或 400 Bad Request (Intent 銷毀) 會帶在 details.upstream_status
+		h.audit.Log(c.Request.Context(), newAuditEntry(c, emailAny.(string), model.AuditCheckpointSolve, "unipile_account", req.AccountID, err))
+		c.Error(err)
 		return
 	}
 
-	// 3. 處理響應
-	handleUnipileResponse(c, status, &resp, h.unipileSvc, emailAny.(string))
+	// 3. 處理響應 (以 Cookie 連結時保存的憑證在這裡儲存)
+	h.handleUnipileResponse(c, status, &resp, emailAny.(string), nil)
 }
 
 // @Summary 獲取帳號列表
@@ -217,13 +259,13 @@ func (h *UnipileHandler) Checkpoint(c *gin.Context) {
 func (h *UnipileHandler) List(c *gin.Context) {
 	emailAny, exists := c.Get("email")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.Error(apperr.Unauthorized("User not authenticated"))
 		return
 	}
 
 	accts, err := h.unipileSvc.ListByEmail(c.Request.Context(), emailAny.(string))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		c.Error(err)
 		return
 	}
 
@@ -232,3 +274,52 @@ func (h *UnipileHandler) List(c *gin.Context) {
 		"accounts": accts,
 	})
 }
+
+// @Summary 移除帳號
+// @Description 中斷 LinkedIn 帳號連結，並刪除 Unipile 上的帳號與保存的憑證
+// @Tags unipile
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param account_id path string true "Unipile account_id"
+// @Produce json
+// @Success 200 {object} StandardResponse "成功移除帳號"
+// @Failure 401 {object} ErrorResponse "未授權"
+// @Failure 404 {object} ErrorResponse "帳號不存在"
+// @Failure 502 {object} ErrorResponse "Unipile 錯誤"
+// @Router /unipile/{account_id} [delete]
+func (h *UnipileHandler) Remove(c *gin.Context) {
+	email := c.GetString("email")
+	accountID := c.Param("account_id")
+
+	err := h.remove(c, email, accountID)
+	h.audit.Log(c.Request.Context(), newAuditEntry(c, email, model.AuditAccountRemove, "unipile_account", accountID, err))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "LinkedIn 帳號已移除",
+		"account_id": accountID,
+	})
+}
+
+func (h *UnipileHandler) remove(c *gin.Context, email, accountID string) error {
+	ctx := c.Request.Context()
+
+	// 1. 確認帳號屬於目前的使用者，避免刪除他人在 Unipile 上的帳號
+	if _, err := h.unipileSvc.Get(ctx, email, accountID); err != nil {
+		return err
+	}
+
+	// 2. 刪除 Unipile 上的帳號
+	if err := unipile.DeleteAccount(context.WithoutCancel(ctx), h.cfg.Unipile, accountID); err != nil {
+		return err
+	}
+
+	// 3. 刪除本地資料與保存的憑證
+	if err := h.unipileSvc.Delete(ctx, email, accountID); err != nil {
+		return err
+	}
+	return h.credSvc.Delete(ctx, accountID)
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/envelope"
+	"chatsheet/internal/middleware"
+	"chatsheet/internal/model"
+	"chatsheet/internal/outbox"
+	"chatsheet/internal/repository/memory"
+	"chatsheet/internal/service"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unipileTestEnv 以假的 Unipile 伺服器與記憶體 Repository 組裝 UnipileHandler
+type unipileTestEnv struct {
+	router     *gin.Engine
+	unipileSvc *service.UnipileService
+	credSvc    *service.CredentialService
+}
+
+// newUnipileTestEnv 建立測試環境；checkpoint 為 true 時連結帳號需要先解決 2FA checkpoint
+func newUnipileTestEnv(t *testing.T, checkpoint bool) *unipileTestEnv {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/accounts", func(w http.ResponseWriter, r *http.Request) {
+		if checkpoint {
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(map[string]any{"object": "Checkpoint", "account_id": "acc-1", "checkpoint": map[string]any{"type": "2FA"}})
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{"object": "AccountCreated", "account_id": "acc-1"})
+	})
+	mux.HandleFunc("POST /api/v1/accounts/checkpoint", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"object": "AccountCreated", "account_id": "acc-1"})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	key, err := envelope.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kms, err := envelope.NewLocalKMS(map[int][]byte{1: key}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 帳號必須參照存在的使用者
+	users := memory.NewUserRepository()
+	for _, email := range []string{"owner@example.com", "attacker@example.com"} {
+		if _, err := users.Create(context.Background(), &model.User{Email: email, Password: "hashed"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.AppConfig{Unipile: config.UnipileConfig{APIBaseURL: srv.URL}}
+	env := &unipileTestEnv{
+		unipileSvc: service.NewUnipileService(memory.NewUnipileRepository(users), memory.NewTransactor(), outbox.NewWriter(memory.NewOutboxRepository())),
+		credSvc:    service.NewCredentialService(memory.NewCredentialRepository(), envelope.New(kms)),
+	}
+	h := NewUnipileHandler(cfg, env.unipileSvc, env.credSvc, service.NewAuditLogger(memory.NewAuditRepository()))
+
+	gin.SetMode(gin.TestMode)
+	env.router = gin.New()
+	env.router.Use(middleware.ErrorMiddleware())
+	// 以 X-Test-Email 代替 AuthMiddleware
+	env.router.Use(func(c *gin.Context) {
+		c.Set("email", c.GetHeader("X-Test-Email"))
+	})
+	env.router.POST("/api/unipile/linkedin/cookie", h.LinkedInCookie)
+	env.router.POST("/api/unipile/linkedin/checkpoint", h.Checkpoint)
+
+	return env
+}
+
+func (e *unipileTestEnv) post(email, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-Email", email)
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, req)
+	return w
+}
+
+const cookieBody = `{"access_token":"li_at-value","user_agent":"test-agent"}`
+
+func TestLinkedInCookieSavesCredential(t *testing.T) {
+	env := newUnipileTestEnv(t, false)
+
+	w := env.post("owner@example.com", "/api/unipile/linkedin/cookie", cookieBody)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+
+	secret, err := env.credSvc.Get(context.Background(), "acc-1")
+	if err != nil {
+		t.Fatalf("Get credential: %v", err)
+	}
+	if secret.AccessToken != "li_at-value" || secret.UserAgent != "test-agent" {
+		t.Errorf("saved credential = %+v", secret)
+	}
+}
+
+func TestLinkedInCookieOtherOwnerDoesNotSaveCredential(t *testing.T) {
+	env := newUnipileTestEnv(t, false)
+	if _, err := env.unipileSvc.Create(context.Background(), "owner@example.com", "linkedin", "acc-1"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// 帳號已屬於其他使用者：不能連結，也不能保存 (覆寫) 它的憑證
+	w := env.post("attacker@example.com", "/api/unipile/linkedin/cookie", cookieBody)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409, body = %s", w.Code, w.Body)
+	}
+	if _, err := env.credSvc.Get(context.Background(), "acc-1"); !errors.Is(err, apperr.ErrNotFound) {
+		t.Fatalf("Get credential err = %v, want apperr.ErrNotFound", err)
+	}
+}
+
+func TestLinkedInCookieCheckpointSavesCredentialAfterSolved(t *testing.T) {
+	env := newUnipileTestEnv(t, true)
+	ctx := context.Background()
+
+	w := env.post("owner@example.com", "/api/unipile/linkedin/cookie", cookieBody)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202, body = %s", w.Code, w.Body)
+	}
+	if _, err := env.credSvc.Get(ctx, "acc-1"); !errors.Is(err, apperr.ErrNotFound) {
+		t.Fatalf("credential saved before checkpoint was solved, err = %v", err)
+	}
+
+	w = env.post("owner@example.com", "/api/unipile/linkedin/checkpoint", `{"account_id":"acc-1","code":"123456"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("checkpoint status = %d, body = %s", w.Code, w.Body)
+	}
+	secret, err := env.credSvc.Get(ctx, "acc-1")
+	if err != nil {
+		t.Fatalf("Get credential: %v", err)
+	}
+	if secret.AccessToken != "li_at-value" {
+		t.Errorf("saved credential = %+v", secret)
+	}
+}
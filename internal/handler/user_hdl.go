@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"chatsheet/internal/model"
 	"chatsheet/internal/service"
 	"net/http"
 
@@ -11,13 +12,15 @@ import (
 type UserHandler struct {
 	userService *service.UserService
 	AuthService *service.AuthService
+	audit       *service.AuditLogger
 	validate    *validator.Validate
 }
 
-func NewUserHandler(userSvc *service.UserService, authSvc *service.AuthService) *UserHandler {
+func NewUserHandler(userSvc *service.UserService, authSvc *service.AuthService, audit *service.AuditLogger) *UserHandler {
 	return &UserHandler{
 		userService: userSvc,
 		AuthService: authSvc,
+		audit:       audit,
 		validate:    validator.New(),
 	}
 }
@@ -30,18 +33,20 @@ func NewUserHandler(userSvc *service.UserService, authSvc *service.AuthService)
 // @Param request body SignupRequest true "註冊請求"
 // @Success 201 {object} StandardResponse{data=model.User}
 // @Failure 400 {object} ErrorResponse "無效的請求"
+// @Failure 409 {object} ErrorResponse "E-mail 已被註冊"
 // @Failure 500 {object} ErrorResponse "內部伺服器錯誤"
 // @Router /signup [post]
 func (h *UserHandler) Signup(c *gin.Context) {
 	var req SignupRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		c.Error(bindingError(err))
 		return
 	}
 
 	user, err := h.userService.Create(c.Request.Context(), req.Email, req.Password)
+	h.audit.Log(c.Request.Context(), newAuditEntry(c, req.Email, model.AuditUserSignup, "user", req.Email, err))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		c.Error(err)
 		return
 	}
 
@@ -65,20 +70,21 @@ func (h *UserHandler) Signup(c *gin.Context) {
 func (h *UserHandler) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		c.Error(bindingError(err))
 		return
 	}
 
 	user, err := h.userService.Authenticate(c.Request.Context(), req.Email, req.Password)
+	h.audit.Log(c.Request.Context(), newAuditEntry(c, req.Email, model.AuditUserLogin, "user", req.Email, err))
 	if err != nil {
-		// 建議回傳通用的錯誤訊息，避免暴露使用者不存在等細節
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		// Service 已回傳通用的錯誤訊息，避免暴露使用者不存在等細節
+		c.Error(err)
 		return
 	}
 
-	token, err := h.AuthService.GenerateToken(user.Email)
+	token, err := h.AuthService.GenerateToken(user.Email, user.Role)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		c.Error(err)
 		return
 	}
 
@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"chatsheet/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookEndpointRequest 建立或更新 webhook endpoint 的請求
+type WebhookEndpointRequest struct {
+	URL         string   `json:"url" binding:"required"`
+	Description string   `json:"description" binding:"max=255"`
+	Events      []string `json:"events" binding:"required"` // account.connected、account.disconnected、account.status_changed 或 message.received
+	Active      *bool    `json:"active"`                    // 建立時預設 true，更新時省略代表不變
+}
+
+func (r WebhookEndpointRequest) input() service.WebhookEndpointInput {
+	return service.WebhookEndpointInput{URL: r.URL, Description: r.Description, Events: r.Events, Active: r.Active}
+}
+
+type WebhookEndpointHandler struct {
+	webhookSvc *service.WebhookService
+}
+
+func NewWebhookEndpointHandler(webhookSvc *service.WebhookService) *WebhookEndpointHandler {
+	return &WebhookEndpointHandler{webhookSvc: webhookSvc}
+}
+
+// @Summary 建立 webhook endpoint
+// @Description 訂閱事件並在發生時 POST 到 url；回應中的 secret 只會出現這一次，用於驗證 X-Chatsheet-Signature：
+// @Description "v1=" + hex(HMAC-SHA256(secret, X-Chatsheet-Timestamp + "." + body))
+// @Tags webhook-endpoints
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param request body WebhookEndpointRequest true "接收網址與訂閱的事件"
+// @Accept json
+// @Produce json
+// @Success 201 {object} StandardResponse{data=model.WebhookEndpoint}
+// @Failure 400 {object} ErrorResponse "網址或事件類型錯誤"
+// @Router /webhook-endpoints [post]
+func (h *WebhookEndpointHandler) Create(c *gin.Context) {
+	var req WebhookEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(bindingError(err))
+		return
+	}
+
+	ep, secret, err := h.webhookSvc.Create(c.Request.Context(), c.GetString("email"), req.input())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":          "Create success",
+		"webhook_endpoint": ep,
+		"secret":           secret,
+	})
+}
+
+// @Summary webhook endpoint 列表
+// @Tags webhook-endpoints
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Produce json
+// @Success 200 {object} StandardResponse{data=[]model.WebhookEndpoint}
+// @Router /webhook-endpoints [get]
+func (h *WebhookEndpointHandler) List(c *gin.Context) {
+	eps, err := h.webhookSvc.List(c.Request.Context(), c.GetString("email"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Get success",
+		"webhook_endpoints": eps,
+	})
+}
+
+// @Summary webhook endpoint
+// @Tags webhook-endpoints
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "endpoint ID"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=model.WebhookEndpoint}
+// @Failure 404 {object} ErrorResponse "endpoint 不存在"
+// @Router /webhook-endpoints/{id} [get]
+func (h *WebhookEndpointHandler) Get(c *gin.Context) {
+	ep, err := h.webhookSvc.Get(c.Request.Context(), c.GetString("email"), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Get success",
+		"webhook_endpoint": ep,
+	})
+}
+
+// @Summary 修改 webhook endpoint
+// @Description 修改接收網址與訂閱的事件，active 為 false 時停止送出 (等待重試的事件會標記為 failed)
+// @Tags webhook-endpoints
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "endpoint ID"
+// @Param request body WebhookEndpointRequest true "接收網址與訂閱的事件"
+// @Accept json
+// @Produce json
+// @Success 200 {object} StandardResponse{data=model.WebhookEndpoint}
+// @Failure 400 {object} ErrorResponse "網址或事件類型錯誤"
+// @Failure 404 {object} ErrorResponse "endpoint 不存在"
+// @Router /webhook-endpoints/{id} [put]
+func (h *WebhookEndpointHandler) Update(c *gin.Context) {
+	var req WebhookEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(bindingError(err))
+		return
+	}
+
+	ep, err := h.webhookSvc.Update(c.Request.Context(), c.GetString("email"), c.Param("id"), req.input())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Update success",
+		"webhook_endpoint": ep,
+	})
+}
+
+// @Summary 刪除 webhook endpoint
+// @Description 刪除 endpoint 與其送出紀錄
+// @Tags webhook-endpoints
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "endpoint ID"
+// @Produce json
+// @Success 200 {object} StandardResponse
+// @Failure 404 {object} ErrorResponse "endpoint 不存在"
+// @Router /webhook-endpoints/{id} [delete]
+func (h *WebhookEndpointHandler) Delete(c *gin.Context) {
+	if err := h.webhookSvc.Delete(c.Request.Context(), c.GetString("email"), c.Param("id")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Delete success",
+	})
+}
+
+// @Summary 輪替 webhook 密鑰
+// @Description 產生新的密鑰並立即生效，回應中的 secret 只會出現這一次
+// @Tags webhook-endpoints
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "endpoint ID"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=model.WebhookEndpoint}
+// @Failure 404 {object} ErrorResponse "endpoint 不存在"
+// @Router /webhook-endpoints/{id}/rotate-secret [post]
+func (h *WebhookEndpointHandler) RotateSecret(c *gin.Context) {
+	ep, secret, err := h.webhookSvc.RotateSecret(c.Request.Context(), c.GetString("email"), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Rotate success",
+		"webhook_endpoint": ep,
+		"secret":           secret,
+	})
+}
+
+// @Summary webhook 送出紀錄
+// @Description 依建立時間由新到舊列出送出紀錄，包含狀態、次數與最後一次的回應狀態碼
+// @Tags webhook-endpoints
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "endpoint ID"
+// @Param cursor query string false "上一頁回傳的 next_cursor"
+// @Param limit query int false "每頁筆數 (最多 200)"
+// @Produce json
+// @Success 200 {object} StandardResponse{data=[]model.WebhookDelivery}
+// @Failure 404 {object} ErrorResponse "endpoint 不存在"
+// @Router /webhook-endpoints/{id}/deliveries [get]
+func (h *WebhookEndpointHandler) ListDeliveries(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	deliveries, next, err := h.webhookSvc.ListDeliveries(c.Request.Context(), c.GetString("email"), c.Param("id"), c.Query("cursor"), limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Get success",
+		"deliveries":  deliveries,
+		"next_cursor": next,
+	})
+}
+
+// @Summary 重送 webhook
+// @Description 以相同的內容 (相同的事件 id) 重新送出，建立新的送出紀錄
+// @Tags webhook-endpoints
+// @Security BearerAuth
+// @Param Authorization header string true "JWT token" default(Bearer <your_JWT_token>)
+// @Param id path string true "endpoint ID"
+// @Param delivery_id path string true "送出紀錄 ID"
+// @Produce json
+// @Success 202 {object} StandardResponse{data=model.WebhookDelivery}
+// @Failure 404 {object} ErrorResponse "endpoint 或送出紀錄不存在"
+// @Failure 409 {object} ErrorResponse "endpoint 已停用"
+// @Router /webhook-endpoints/{id}/deliveries/{delivery_id}/redeliver [post]
+func (h *WebhookEndpointHandler) Redeliver(c *gin.Context) {
+	d, err := h.webhookSvc.Redeliver(c.Request.Context(), c.GetString("email"), c.Param("id"), c.Param("delivery_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":  "Redeliver success",
+		"delivery": d,
+	})
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/service"
+	"chatsheet/internal/unipile"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	secret        string
+	syncSvc       *service.SyncService
+	invitationSvc *service.InvitationService
+}
+
+func NewWebhookHandler(secret string, syncSvc *service.SyncService, invitationSvc *service.InvitationService) *WebhookHandler {
+	return &WebhookHandler{secret: secret, syncSvc: syncSvc, invitationSvc: invitationSvc}
+}
+
+// @Summary Unipile webhook
+// @Description 接收 Unipile 的訊息事件 (message_received 等)，寫入本地並觸發帳號的補同步；
+// @Description 以及 users webhook 的 new_relation 事件，將對應的邀請標記為已接受
+// @Tags webhook
+// @Param Unipile-Auth header string true "建立 webhook 時設定的密鑰 (sync.webhook_secret)"
+// @Param request body unipile.MessageEvent true "Unipile 訊息事件"
+// @Accept json
+// @Produce json
+// @Success 200 {object} StandardResponse
+// @Failure 400 {object} ErrorResponse "請求格式錯誤"
+// @Failure 401 {object} ErrorResponse "密鑰錯誤"
+// @Router /webhooks/unipile [post]
+func (h *WebhookHandler) Unipile(c *gin.Context) {
+	// 沒有設定密鑰時拒絕所有請求，避免任何人都能寫入訊息
+	got := c.GetHeader(unipile.WebhookAuthHeader)
+	if h.secret == "" || subtle.ConstantTimeCompare([]byte(got), []byte(h.secret)) != 1 {
+		c.Error(apperr.Unauthorized("Invalid webhook secret"))
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		c.Error(bindingError(err))
+		return
+	}
+	var head struct {
+		Event string `json:"event"`
+	}
+	if err := json.Unmarshal(body, &head); err != nil {
+		c.Error(bindingError(err))
+		return
+	}
+
+	// messaging 與 users 兩種 webhook 共用同一個網址，依事件名稱區分
+	if head.Event == unipile.EventNewRelation {
+		var ev unipile.RelationEvent
+		if err := json.Unmarshal(body, &ev); err != nil {
+			c.Error(bindingError(err))
+			return
+		}
+		err = h.invitationSvc.HandleRelationEvent(c.Request.Context(), &ev)
+	} else {
+		var ev unipile.MessageEvent
+		if err := json.Unmarshal(body, &ev); err != nil {
+			c.Error(bindingError(err))
+			return
+		}
+		err = h.syncSvc.HandleMessageEvent(c.Request.Context(), &ev)
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Received"})
+}
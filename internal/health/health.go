@@ -0,0 +1,182 @@
+// Package health 提供 /healthz 與 /readyz 使用的健康檢查。
+//
+// Checker 以 Add 註冊各項相依服務的檢查 (資料庫、Unipile 等)，Ready 同時執行所有檢查並回傳每一項的結果。
+// required 的檢查失敗時回報未就緒；非 required 的檢查失敗只回報 degraded，仍然接受流量。
+// 呼叫外部服務的檢查可以用 Cached 包裝，避免每次探測都送出請求。
+//
+// 收到 SIGTERM 時呼叫 SetDraining，之後 Ready 一律回報未就緒，讓負載平衡器在伺服器關閉前停止轉送請求。
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 檢查與整體的狀態
+const (
+	StatusOK       = "ok"
+	StatusFail     = "fail"
+	StatusDegraded = "degraded" // 只有非 required 的檢查失敗
+	StatusDraining = "draining" // 程序即將關閉
+)
+
+// CheckFunc 執行一項檢查，detail 會原樣輸出在結果中 (例如版本、延遲)
+type CheckFunc func(ctx context.Context) (detail any, err error)
+
+// Result 是一項檢查的結果
+type Result struct {
+	Status     string  `json:"status"`
+	Required   bool    `json:"required"`
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+	Detail     any     `json:"detail,omitempty"`
+}
+
+// Report 是 Ready 的結果
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Ready 回傳是否可以接受流量
+func (r Report) Ready() bool {
+	return r.Status == StatusOK || r.Status == StatusDegraded
+}
+
+type check struct {
+	name     string
+	required bool
+	fn       CheckFunc
+}
+
+// Checker 執行註冊的檢查
+type Checker struct {
+	timeout  time.Duration
+	checks   []check
+	draining atomic.Bool
+}
+
+// NewChecker 建立每項檢查最多執行 timeout 的 Checker
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &Checker{timeout: timeout}
+}
+
+// Add 註冊檢查，必須在開始處理請求前呼叫
+func (c *Checker) Add(name string, required bool, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, required: required, fn: fn})
+}
+
+// SetDraining 讓之後的 Ready 一律回報未就緒
+func (c *Checker) SetDraining() {
+	c.draining.Store(true)
+}
+
+// Draining 回傳是否已呼叫 SetDraining
+func (c *Checker) Draining() bool {
+	return c.draining.Load()
+}
+
+// Ready 同時執行所有檢查；draining 時不執行檢查，直接回報 StatusDraining
+func (c *Checker) Ready(ctx context.Context) Report {
+	if c.Draining() {
+		return Report{Status: StatusDraining}
+	}
+
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, chk := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, chk)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(c.checks))}
+	for i, chk := range c.checks {
+		res := results[i]
+		report.Checks[chk.name] = res
+		if res.Status == StatusOK {
+			continue
+		}
+		if chk.required {
+			report.Status = StatusFail
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+// run 在逾時內執行一項檢查；檢查函式沒有遵守 ctx 時不等待它結束
+func (c *Checker) run(ctx context.Context, chk check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	type outcome struct {
+		detail any
+		err    error
+	}
+	done := make(chan outcome, 1)
+	start := time.Now()
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{err: fmt.Errorf("panic: %v", r)}
+			}
+		}()
+		detail, err := chk.fn(ctx)
+		done <- outcome{detail: detail, err: err}
+	}()
+
+	var out outcome
+	select {
+	case out = <-done:
+	case <-ctx.Done():
+		out.err = fmt.Errorf("timed out after %s", c.timeout)
+	}
+
+	res := Result{
+		Status:     StatusOK,
+		Required:   chk.required,
+		DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+		Detail:     out.detail,
+	}
+	if out.err != nil {
+		res.Status = StatusFail
+		res.Error = out.err.Error()
+	}
+	return res
+}
+
+// Cached 在 ttl 內重複使用 fn 上一次的結果 (包含失敗)，同時間只有一個呼叫會執行 fn
+func Cached(ttl time.Duration, fn CheckFunc) CheckFunc {
+	var (
+		mu        sync.Mutex
+		checkedAt time.Time
+		detail    any
+		lastErr   error
+	)
+	return func(ctx context.Context) (any, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if checkedAt.IsZero() || time.Since(checkedAt) >= ttl {
+			detail, lastErr = fn(ctx)
+			checkedAt = time.Now()
+		}
+		return cachedDetail{Detail: detail, CheckedAt: checkedAt.UTC()}, lastErr
+	}
+}
+
+// cachedDetail 附上結果實際取得的時間
+type cachedDetail struct {
+	Detail    any       `json:"detail,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
@@ -0,0 +1,135 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func ok(ctx context.Context) (any, error) { return "ok", nil }
+
+func fail(ctx context.Context) (any, error) { return nil, errors.New("connection refused") }
+
+func TestReadyStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		add   func(c *Checker)
+		want  string
+		ready bool
+	}{
+		{"no checks", func(c *Checker) {}, StatusOK, true},
+		{"all pass", func(c *Checker) {
+			c.Add("database", true, ok)
+			c.Add("unipile", false, ok)
+		}, StatusOK, true},
+		{"optional fails", func(c *Checker) {
+			c.Add("database", true, ok)
+			c.Add("unipile", false, fail)
+		}, StatusDegraded, true},
+		{"required fails", func(c *Checker) {
+			c.Add("database", true, fail)
+			c.Add("unipile", false, fail)
+		}, StatusFail, false},
+	}
+	for _, tt := range tests {
+		c := NewChecker(time.Second)
+		tt.add(c)
+		r := c.Ready(context.Background())
+		if r.Status != tt.want || r.Ready() != tt.ready {
+			t.Errorf("%s: status = %s ready = %v, want %s %v", tt.name, r.Status, r.Ready(), tt.want, tt.ready)
+		}
+	}
+}
+
+func TestReadyResults(t *testing.T) {
+	c := NewChecker(time.Second)
+	c.Add("database", true, func(ctx context.Context) (any, error) { return map[string]int{"open": 3}, nil })
+	c.Add("unipile", false, fail)
+	c.Add("jobs", true, func(ctx context.Context) (any, error) { panic("nil queue") })
+
+	r := c.Ready(context.Background())
+	if len(r.Checks) != 3 {
+		t.Fatalf("checks = %+v, want 3", r.Checks)
+	}
+	if db := r.Checks["database"]; db.Status != StatusOK || !db.Required || db.Detail.(map[string]int)["open"] != 3 {
+		t.Errorf("database = %+v", db)
+	}
+	if u := r.Checks["unipile"]; u.Status != StatusFail || u.Required || u.Error != "connection refused" {
+		t.Errorf("unipile = %+v", u)
+	}
+	// panic 視為失敗，不影響其他檢查
+	if j := r.Checks["jobs"]; j.Status != StatusFail || j.Error != "panic: nil queue" {
+		t.Errorf("jobs = %+v", j)
+	}
+}
+
+func TestReadyTimeout(t *testing.T) {
+	c := NewChecker(50 * time.Millisecond)
+	// 不遵守 ctx 的檢查也不會拖住 Ready
+	c.Add("stuck", true, func(ctx context.Context) (any, error) {
+		time.Sleep(time.Second)
+		return nil, nil
+	})
+	c.Add("database", true, ok)
+
+	start := time.Now()
+	r := c.Ready(context.Background())
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Ready took %v, want about the 50ms timeout", elapsed)
+	}
+	if r.Status != StatusFail || r.Checks["stuck"].Error != "timed out after 50ms" || r.Checks["database"].Status != StatusOK {
+		t.Errorf("report = %+v", r)
+	}
+}
+
+func TestReadyDraining(t *testing.T) {
+	var calls atomic.Int32
+	c := NewChecker(time.Second)
+	c.Add("database", true, func(ctx context.Context) (any, error) {
+		calls.Add(1)
+		return nil, nil
+	})
+
+	if r := c.Ready(context.Background()); !r.Ready() {
+		t.Fatalf("report before draining = %+v", r)
+	}
+	c.SetDraining()
+	r := c.Ready(context.Background())
+	if r.Status != StatusDraining || r.Ready() || !c.Draining() {
+		t.Errorf("report while draining = %+v", r)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("checks ran %d times, want no runs while draining", calls.Load())
+	}
+}
+
+func TestCached(t *testing.T) {
+	var calls atomic.Int32
+	fn := Cached(100*time.Millisecond, func(ctx context.Context) (any, error) {
+		calls.Add(1)
+		return "v1", errors.New("unauthorized")
+	})
+
+	first, err := fn(context.Background())
+	if err == nil || first.(cachedDetail).Detail != "v1" {
+		t.Fatalf("first = %+v, %v", first, err)
+	}
+	// 失敗也會快取
+	for range 5 {
+		detail, err := fn(context.Background())
+		if err == nil || detail.(cachedDetail).CheckedAt != first.(cachedDetail).CheckedAt {
+			t.Fatalf("cached = %+v, %v; want the first result", detail, err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("fn called %d times within the ttl, want 1", calls.Load())
+	}
+
+	time.Sleep(120 * time.Millisecond)
+	fn(context.Background())
+	if calls.Load() != 2 {
+		t.Errorf("fn called %d times after the ttl, want 2", calls.Load())
+	}
+}
@@ -2,7 +2,11 @@ package itfc
 
 import (
 	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
 	"context"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 // UserRepository 定義了使用者資料的存取方法
@@ -13,6 +17,395 @@ type UserRepository interface {
 }
 
 type UnipileRepository interface {
+	// Create 新增帳號，user_email 不存在時回傳 apperr.ErrNotFound
 	Create(ctx context.Context, ua *model.UnipileAccount) (*model.UnipileAccount, error)
+	// Upsert 依 account_id 新增帳號；若同一個使用者已連結過則更新，屬於其他使用者時回傳 apperr.ErrConflict，
+	// user_email 不存在時回傳 apperr.ErrNotFound
+	Upsert(ctx context.Context, ua *model.UnipileAccount) (*model.UnipileAccount, error)
 	ListByEmail(ctx context.Context, email string) ([]model.UnipileAccount, error)
+	// ListAll 列出所有使用者連結的帳號 (供背景同步使用)
+	ListAll(ctx context.Context) ([]model.UnipileAccount, error)
+	// GetByAccountID 以 Unipile account_id 取得帳號，不存在時回傳 apperr.ErrNotFound
+	GetByAccountID(ctx context.Context, accountID string) (*model.UnipileAccount, error)
+	// DeleteByAccountID 刪除使用者的帳號，帳號不存在或不屬於該使用者時回傳 apperr.ErrNotFound
+	DeleteByAccountID(ctx context.Context, email, accountID string) error
+	// UpdateStatus 更新帳號在 Unipile 上的狀態，帳號不存在時回傳 apperr.ErrNotFound
+	UpdateStatus(ctx context.Context, accountID, status string, checkedAt time.Time) error
+}
+
+// CredentialRepository 存取加密後的連線憑證，加解密由 Service 層負責
+type CredentialRepository interface {
+	// Upsert 依 account_id 新增或覆寫憑證，account_id 已屬於其他使用者時回傳 apperr.ErrConflict
+	Upsert(ctx context.Context, cred *model.UnipileCredential) (*model.UnipileCredential, error)
+	GetByAccountID(ctx context.Context, accountID string) (*model.UnipileCredential, error)
+	// ListByKeyVersionNot 列出不是以指定主金鑰版本包裝的憑證，用於金鑰輪替
+	ListByKeyVersionNot(ctx context.Context, version, limit int) ([]model.UnipileCredential, error)
+	// UpdateWrappedKey 只更新資料金鑰的包裝結果，密文不變
+	UpdateWrappedKey(ctx context.Context, id uuid.UUID, version int, wrappedKey []byte) error
+	// DeleteByAccountID 刪除帳號的憑證，不存在時不回傳錯誤
+	DeleteByAccountID(ctx context.Context, accountID string) error
+}
+
+// IdempotencyRepository 存取 Idempotency-Key 與其快取的回應
+type IdempotencyRepository interface {
+	// Create 保留一個 key，(user_email, key) 已存在時回傳 apperr.ErrConflict
+	Create(ctx context.Context, rec *model.IdempotencyKey) (*model.IdempotencyKey, error)
+	Get(ctx context.Context, email, key string) (*model.IdempotencyKey, error)
+	// Complete 儲存回應並將狀態設為 completed
+	Complete(ctx context.Context, id uuid.UUID, code int, contentType string, body []byte) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	// DeleteExpired 刪除 expires_at 早於 before 的 key，回傳刪除的筆數
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+// AuditFilter 稽核事件的查詢條件，結果依時間由新到舊排列
+type AuditFilter struct {
+	ActorEmail string             // 空字串代表所有使用者
+	After      *pagination.Cursor // 上一頁最後一筆，nil 代表第一頁
+	Limit      int
+}
+
+// AuditRepository 存取稽核事件，只能新增不能修改或刪除
+type AuditRepository interface {
+	Append(ctx context.Context, event *model.AuditEvent) error
+	List(ctx context.Context, filter AuditFilter) ([]model.AuditEvent, error)
+}
+
+// MessageFilter 篩選訊息的條件，零值的欄位代表不篩選
+type MessageFilter struct {
+	AccountID string
+	ChatID    string
+	Since     time.Time // sent_at >= Since
+	Until     time.Time // sent_at < Until
+	SenderID  string    // 寄件者的 provider id
+	IsSender  *bool     // true: 由連結的帳號送出, false: 收到的訊息
+	After     *pagination.Cursor
+	Limit     int
+}
+
+// MessageRepository 存取保存在本地的訊息
+type MessageRepository interface {
+	// Create 新增訊息，unipile_id 已存在時回傳 apperr.ErrConflict
+	Create(ctx context.Context, msg *model.Message) (*model.Message, error)
+	// Upsert 以 unipile_id 新增或更新同步來的訊息，不會覆寫 sent_by_email
+	Upsert(ctx context.Context, msg *model.Message) error
+	// ListByChatSince 列出對話中 sent_at >= since 的訊息 (由新到舊)
+	ListByChatSince(ctx context.Context, chatID string, since time.Time) ([]model.Message, error)
+	// ListByChat 以 (sent_at, id) 由新到舊分頁列出對話的訊息，after 為 nil 時從最新開始
+	ListByChat(ctx context.Context, chatID string, after *pagination.Cursor, limit int) ([]model.Message, error)
+	// List 以 (sent_at, id) 由新到舊分頁列出符合條件的訊息
+	List(ctx context.Context, filter MessageFilter) ([]model.Message, error)
+}
+
+// ChatRepository 存取同步到本地的對話
+type ChatRepository interface {
+	// Upsert 以 unipile_id 新增或更新對話，不會覆寫訊息回填的進度
+	Upsert(ctx context.Context, chat *model.Chat) error
+	// GetByUnipileID 取得對話，不存在時回傳 apperr.ErrNotFound
+	GetByUnipileID(ctx context.Context, unipileID string) (*model.Chat, error)
+	// ListByAccount 以 (last_message_at, id) 由新到舊分頁列出帳號的對話，after 為 nil 時從最新開始
+	ListByAccount(ctx context.Context, accountID string, after *pagination.Cursor, limit int) ([]model.Chat, error)
+	// ListNotBackfilled 列出帳號中訊息尚未回填完成的對話
+	ListNotBackfilled(ctx context.Context, accountID string, limit int) ([]model.Chat, error)
+	// UpdateMessagesCheckpoint 記錄對話的訊息回填進度，對話不存在時回傳 apperr.ErrNotFound
+	UpdateMessagesCheckpoint(ctx context.Context, unipileID, cursor string, backfilled bool) error
+}
+
+// ChatAttendeeRepository 存取同步到本地的對話參與者
+type ChatAttendeeRepository interface {
+	// Upsert 以 (chat_id, provider_id) 新增或更新參與者
+	Upsert(ctx context.Context, attendee *model.ChatAttendee) error
+	// ListByChats 列出多個對話的參與者
+	ListByChats(ctx context.Context, chatIDs []string) ([]model.ChatAttendee, error)
+}
+
+// SyncCheckpointRepository 存取每個帳號的同步進度
+type SyncCheckpointRepository interface {
+	// Get 取得帳號的同步進度，尚未同步過時回傳 apperr.ErrNotFound
+	Get(ctx context.Context, accountID string) (*model.SyncCheckpoint, error)
+	// Save 以 account_id 新增或更新同步進度
+	Save(ctx context.Context, cp *model.SyncCheckpoint) error
+}
+
+// SearchFilter 全文搜尋訊息的條件
+type SearchFilter struct {
+	Query      string
+	AccountIDs []string  // 只搜尋這些帳號，必須至少有一個
+	Since      time.Time // sent_at >= Since
+	Until      time.Time // sent_at < Until
+	After      *pagination.RankedCursor
+	Limit      int
+}
+
+// SearchHit 是一筆搜尋結果
+type SearchHit struct {
+	model.Message
+	Rank float64 `gorm:"column:rank"`
+	// 以 search.HighlightStart / search.HighlightStop 標記符合的字詞，尚未經過 HTML escape
+	Highlight string `gorm:"column:highlight"`
+}
+
+// Cursor 回傳指向這筆結果的游標，下一頁從它之後開始
+func (h SearchHit) Cursor() pagination.RankedCursor {
+	var created time.Time
+	if h.CreatedAt != nil {
+		created = *h.CreatedAt
+	}
+	return pagination.RankedCursor{Rank: h.Rank, Time: created, ID: h.ID.String()}
+}
+
+// SearchRepository 全文搜尋保存在本地的訊息內文、寄件者名稱與對話參與者的名稱
+type SearchRepository interface {
+	// Search 依符合程度 (rank DESC)、created_at DESC、id DESC 排序
+	// 以不會變動的 created_at 為次要排序鍵，分頁期間訊息的 sent_at 被同步更新也不會重複或遺漏
+	Search(ctx context.Context, filter SearchFilter) ([]SearchHit, error)
+}
+
+// ContactRepository 存取 LinkedIn 個人檔案的快取
+type ContactRepository interface {
+	// Upsert 依 (account_id, provider_id) 新增或更新
+	Upsert(ctx context.Context, contact *model.Contact) error
+	// GetByIdentifier 以 provider id 或公開識別碼查詢，不存在時回傳 apperr.ErrNotFound
+	GetByIdentifier(ctx context.Context, accountID, identifier string) (*model.Contact, error)
+	ListByProviderIDs(ctx context.Context, accountID string, providerIDs []string) ([]model.Contact, error)
+}
+
+// InvitationRepository 存取 LinkedIn 連結邀請的本地紀錄
+type InvitationRepository interface {
+	// Upsert 依 (account_id, unipile_id) 新增或更新對方的資訊，已存在時不會改變 status、sent_by_email 與 responded_at
+	// message、shared_secret 與 invited_at 為空值時保留原本的值
+	Upsert(ctx context.Context, inv *model.Invitation) error
+	// GetByUnipileID 不存在時回傳 apperr.ErrNotFound
+	GetByUnipileID(ctx context.Context, accountID, unipileID string) (*model.Invitation, error)
+	// UpdateStatus 不存在時回傳 apperr.ErrNotFound
+	UpdateStatus(ctx context.Context, id uuid.UUID, status string, respondedAt time.Time) error
+	// MarkAccepted 將帳號與對方之間所有 pending 的邀請標記為已接受，回傳更新的筆數
+	MarkAccepted(ctx context.Context, accountID, providerID string, respondedAt time.Time) (int64, error)
+}
+
+// CampaignRepository 存取外展活動
+type CampaignRepository interface {
+	Create(ctx context.Context, campaign *model.Campaign) error
+	// Get 不存在時回傳 apperr.ErrNotFound
+	Get(ctx context.Context, id uuid.UUID) (*model.Campaign, error)
+	// ListByUser 依建立時間由新到舊列出使用者的活動
+	ListByUser(ctx context.Context, email string) ([]model.Campaign, error)
+	// UpdateStatus 不存在時回傳 apperr.ErrNotFound
+	UpdateStatus(ctx context.Context, id uuid.UUID, status string) error
+}
+
+// EnrollmentRepository 存取外展活動的報名與其狀態歷史
+// 改變報名狀態的方法會在同一個交易中新增 EnrollmentEvent
+type EnrollmentRepository interface {
+	// Create 新增報名與第一筆歷史，(campaign_id, provider_id) 已存在時回傳 apperr.ErrConflict
+	Create(ctx context.Context, e *model.Enrollment, ev *model.EnrollmentEvent) error
+	// Get 不存在時回傳 apperr.ErrNotFound
+	Get(ctx context.Context, id uuid.UUID) (*model.Enrollment, error)
+	// ListByCampaign 以 (created_at, id) 由新到舊分頁列出活動的報名
+	ListByCampaign(ctx context.Context, campaignID uuid.UUID, after *pagination.Cursor, limit int) ([]model.Enrollment, error)
+	// ListActiveByProspect 列出帳號與對方之間所有進行中的報名
+	ListActiveByProspect(ctx context.Context, accountID, providerID string) ([]model.Enrollment, error)
+	// ClaimDue 取出進行中的活動裡 next_run_at <= now 的報名，設定新的 lease_id 並把 next_run_at 延後 lease 避免被重複取出
+	// 多個程序同時執行時，同一筆報名只會被其中一個取出
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.Enrollment, error)
+	// Transition 在狀態仍為 fromStatus 且 lease_id 與 e.LeaseID 相同時更新報名的進度並新增歷史，否則回傳 apperr.ErrConflict
+	// lease_id 不會被覆寫；租約到期後被重新取出的報名 lease_id 已經改變，原本的持有者無法再記錄結果
+	Transition(ctx context.Context, e *model.Enrollment, fromStatus string, ev *model.EnrollmentEvent) error
+	// ListEvents 以 (created_at, id) 由新到舊分頁列出活動所有報名的歷史
+	ListEvents(ctx context.Context, campaignID uuid.UUID, after *pagination.Cursor, limit int) ([]model.EnrollmentEvent, error)
+}
+
+// QuotaWindow 是一段配額期間：自 Since 起最多 Limit 次
+type QuotaWindow struct {
+	Since time.Time
+	Limit int
+}
+
+// QuotaRepository 存取連結帳號的配額設定與使用紀錄
+type QuotaRepository interface {
+	// GetLimits 尚未設定時回傳 apperr.ErrNotFound
+	GetLimits(ctx context.Context, accountID string) (*model.AccountLimits, error)
+	// SaveLimits 依 account_id 新增或更新設定
+	SaveLimits(ctx context.Context, limits *model.AccountLimits) error
+	// Consume 在每個 window 的使用次數都小於 Limit 時新增使用紀錄並回傳 true，否則不新增並回傳 false
+	// 同一個帳號與動作的 Consume 會依序執行，不會同時通過檢查而超過配額
+	Consume(ctx context.Context, usage *model.ActionUsage, windows []QuotaWindow) (bool, error)
+	// Release 刪除使用紀錄 (動作沒有成功送出時歸還配額)，不存在時不回傳錯誤
+	Release(ctx context.Context, id uuid.UUID) error
+	// Count 回傳 created_at >= since 的使用次數
+	Count(ctx context.Context, accountID, action string, since time.Time) (int, error)
+}
+
+// TemplateRepository 存取使用者的訊息範本
+type TemplateRepository interface {
+	// Create 同一使用者已有相同名稱的範本時回傳 apperr.ErrConflict
+	Create(ctx context.Context, tmpl *model.MessageTemplate) error
+	// Get 不存在時回傳 apperr.ErrNotFound
+	Get(ctx context.Context, id uuid.UUID) (*model.MessageTemplate, error)
+	// ListByUser 依名稱排序列出使用者的範本
+	ListByUser(ctx context.Context, email string) ([]model.MessageTemplate, error)
+	// Update 更新名稱、內容與變數，不存在時回傳 apperr.ErrNotFound，名稱重複時回傳 apperr.ErrConflict
+	Update(ctx context.Context, tmpl *model.MessageTemplate) error
+	// Delete 不存在時回傳 apperr.ErrNotFound
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// ScheduledMessageRepository 存取排程訊息
+type ScheduledMessageRepository interface {
+	Create(ctx context.Context, m *model.ScheduledMessage) error
+	// Get 不存在時回傳 apperr.ErrNotFound
+	Get(ctx context.Context, id uuid.UUID) (*model.ScheduledMessage, error)
+	// ListByUser 以 (send_at, id) 由新到舊分頁列出使用者的排程訊息，status 為空字串時列出所有狀態
+	ListByUser(ctx context.Context, email, status string, after *pagination.Cursor, limit int) ([]model.ScheduledMessage, error)
+	// Update 在狀態仍為 fromStatus 且 lease_id 與 m.LeaseID 相同時覆寫訊息的內容、時間與送出結果，否則回傳 apperr.ErrConflict
+	// lease_id 不會被覆寫；租約到期後被重新取出的訊息 lease_id 已經改變，原本的持有者無法再記錄結果
+	Update(ctx context.Context, m *model.ScheduledMessage, fromStatus string) error
+	// ClaimDue 取出最多 limit 筆 next_attempt_at <= now 的 scheduled 訊息，以及租約已經到期的 sending 訊息
+	// 取出的訊息改為 sending、設定新的 lease_id 並將 next_attempt_at 延後 lease，在此期間不會再被取出
+	// 多個程序可以同時呼叫，每筆訊息只會被其中一個取出
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.ScheduledMessage, error)
+}
+
+// JobRepository 存取背景工作佇列
+type JobRepository interface {
+	// Create 排入工作，UniqueKey 與等待或執行中的工作重複時回傳 apperr.ErrConflict
+	Create(ctx context.Context, j *model.Job) error
+	// Get 不存在時回傳 apperr.ErrNotFound
+	Get(ctx context.Context, id uuid.UUID) (*model.Job, error)
+	// List 以 (created_at, id) 由新到舊分頁列出工作，queue 或 status 為空字串時不篩選
+	List(ctx context.Context, queue, status string, after *pagination.Cursor, limit int) ([]model.Job, error)
+	// ClaimDue 取出佇列中最多 limit 筆 run_at <= now 的 queued 工作，以及租約已經到期的 running 工作
+	// 取出的工作改為 running、attempts 加一並將 run_at 延後 lease，在此期間不會再被取出
+	// 多個程序可以同時呼叫，每個工作只會被其中一個取出
+	ClaimDue(ctx context.Context, queue string, now time.Time, lease time.Duration, limit int) ([]model.Job, error)
+	// Finish 寫入執行結果 (status、run_at、last_error、finished_at)
+	// 工作已經不是這次取出的狀態 (不是 running 或 attempts 不同，例如租約到期後被重新取出) 時回傳 apperr.ErrConflict
+	Finish(ctx context.Context, j *model.Job) error
+	// Retry 將 dead 工作重新排入並將 attempts 歸零，不是 dead 時回傳 apperr.ErrConflict
+	// UniqueKey 與等待或執行中的工作重複時也回傳 apperr.ErrConflict
+	Retry(ctx context.Context, id uuid.UUID, runAt time.Time) error
+	// DeleteFinished 刪除 finished_at 早於 before 的 succeeded 與 dead 工作，回傳刪除的筆數
+	DeleteFinished(ctx context.Context, before time.Time) (int64, error)
+	// CountByStatus 依 (queue, status) 統計 statuses 中各狀態的工作數量，沒有工作的組合不會回傳
+	CountByStatus(ctx context.Context, statuses []string) ([]model.JobCount, error)
+}
+
+// TaskRunRepository 存取定期工作最後一次的執行紀錄
+type TaskRunRepository interface {
+	// Get 不存在 (從未執行) 時回傳 apperr.ErrNotFound
+	Get(ctx context.Context, name string) (*model.TaskRun, error)
+	// List 依名稱列出所有執行過的工作
+	List(ctx context.Context) ([]model.TaskRun, error)
+	// Save 依名稱新增或覆寫紀錄
+	Save(ctx context.Context, run *model.TaskRun) error
+}
+
+// Locker 提供跨程序的互斥鎖，例如 Postgres advisory lock
+type Locker interface {
+	// TryLock 嘗試取得名稱對應的鎖，已被其他持有者取得時回傳 false 且不等待
+	// 取得時必須呼叫 unlock 釋放；持有者的連線中斷時鎖也會釋放
+	TryLock(ctx context.Context, name string) (unlock func(), ok bool, err error)
+}
+
+// WebhookEndpointRepository 存取使用者設定的 webhook 接收網址，密鑰的加解密由 Service 層負責
+type WebhookEndpointRepository interface {
+	Create(ctx context.Context, ep *model.WebhookEndpoint) error
+	// Get 不存在時回傳 apperr.ErrNotFound
+	Get(ctx context.Context, id uuid.UUID) (*model.WebhookEndpoint, error)
+	// ListByUser 依建立時間列出使用者的 endpoint
+	ListByUser(ctx context.Context, email string) ([]model.WebhookEndpoint, error)
+	// Update 更新網址、說明、訂閱的事件與啟用狀態，不存在時回傳 apperr.ErrNotFound
+	Update(ctx context.Context, ep *model.WebhookEndpoint) error
+	// UpdateSecret 只更新加密後的密鑰，不存在時回傳 apperr.ErrNotFound
+	UpdateSecret(ctx context.Context, ep *model.WebhookEndpoint) error
+	// Delete 不存在時回傳 apperr.ErrNotFound
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// WebhookDeliveryRepository 存取 webhook 的送出紀錄
+type WebhookDeliveryRepository interface {
+	// Create 同一個 endpoint 已有相同 event_id 的紀錄 (重送除外) 時回傳 apperr.ErrConflict
+	Create(ctx context.Context, d *model.WebhookDelivery) error
+	// Get 不存在時回傳 apperr.ErrNotFound
+	Get(ctx context.Context, id uuid.UUID) (*model.WebhookDelivery, error)
+	// ListByEndpoint 依建立時間由新到舊列出 endpoint 的送出紀錄
+	ListByEndpoint(ctx context.Context, endpointID uuid.UUID, after *pagination.Cursor, limit int) ([]model.WebhookDelivery, error)
+	// SaveAttempt 保存一次嘗試的結果 (狀態、次數、回應與時間)，不存在時回傳 apperr.ErrNotFound
+	SaveAttempt(ctx context.Context, d *model.WebhookDelivery) error
+	// DeleteByEndpoint 刪除 endpoint 的所有送出紀錄
+	DeleteByEndpoint(ctx context.Context, endpointID uuid.UUID) error
+}
+
+// Transactor 讓多個 Repository 的寫入在同一個交易中完成，例如資料變更與 outbox 事件
+type Transactor interface {
+	// WithinTx 在交易中執行 fn，fn 回傳錯誤時 rollback；fn 中必須以傳入的 ctx 呼叫 Repository 才會使用交易
+	// 已經在交易中時加入外層的交易
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// OutboxRepository 存取 transactional outbox 的事件
+type OutboxRepository interface {
+	// Create 寫入事件，ctx 來自 Transactor.WithinTx 時與其他寫入在同一個交易中
+	Create(ctx context.Context, e *model.OutboxEvent) error
+	// ClaimPending 依建立順序取出 available_at <= now 且尚未發布的事件，
+	// 並將 available_at 延後到 now + lease、attempts 加一，讓其他程序在租約期間不會取出相同的事件
+	ClaimPending(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.OutboxEvent, error)
+	// MarkPublished 標記事件已發布，不存在時回傳 apperr.ErrNotFound
+	MarkPublished(ctx context.Context, id uuid.UUID, at time.Time) error
+	// Reschedule 記錄發布失敗的原因並在 availableAt 重試，不存在或已發布時回傳 apperr.ErrNotFound
+	Reschedule(ctx context.Context, id uuid.UUID, availableAt time.Time, lastError string) error
+	// DeletePublished 刪除在 before 之前發布的事件，回傳刪除的數量
+	DeletePublished(ctx context.Context, before time.Time) (int64, error)
+}
+
+// TokenBucket 是限流使用的 token bucket：最多累積 Capacity 個 token，每秒補充 Rate 個，每個請求取出一個
+type TokenBucket struct {
+	Capacity int
+	Rate     float64
+}
+
+// RateLimitResult 是取出 token 的結果
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int           // 取出後剩下的完整 token 數
+	RetryAfter time.Duration // 未通過時，多久後會有一個 token
+	ResetAfter time.Duration // 多久後 bucket 會補滿 (補滿的 bucket 與不存在相同，可以刪除)
+}
+
+// Take 依 updatedAt 到 now 經過的時間補充 tokens 後嘗試取出一個，回傳 now 時剩下的 token 與結果
+// 所有 RateLimitStore 實作都以此計算，Redis 的 Lua script 也必須與此一致
+func (b TokenBucket) Take(tokens float64, updatedAt, now time.Time) (float64, RateLimitResult) {
+	if elapsed := now.Sub(updatedAt).Seconds(); elapsed > 0 {
+		tokens = min(float64(b.Capacity), tokens+elapsed*b.Rate)
+	}
+
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+	return tokens, b.Result(tokens, allowed)
+}
+
+// Result 由取出後剩下的 token 計算結果，用於在 store 中完成計算的實作 (例如 Redis)
+func (b TokenBucket) Result(tokens float64, allowed bool) RateLimitResult {
+	res := RateLimitResult{
+		Allowed:    allowed,
+		Remaining:  int(tokens),
+		ResetAfter: time.Duration((float64(b.Capacity) - tokens) / b.Rate * float64(time.Second)),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) / b.Rate * float64(time.Second))
+	}
+	return res
+}
+
+// RateLimitStore 保存限流的 token bucket，例如 Postgres 或 Redis，讓多個程序共用同一個限制
+type RateLimitStore interface {
+	// Take 從 key 的 bucket 取出一個 token，不存在的 key 視為已補滿的 bucket
+	// 同一個 key 同時的 Take 必須依序計算，不會超出限制
+	Take(ctx context.Context, key string, bucket TokenBucket, now time.Time) (RateLimitResult, error)
+	// Peek 回傳此時 Take 的結果，但不取出 token，用於一個請求適用多個 policy 時先確認全部都會通過
+	Peek(ctx context.Context, key string, bucket TokenBucket, now time.Time) (RateLimitResult, error)
+	// DeleteExpired 刪除在 before 之前就已補滿的 bucket，回傳刪除的數量；會自行讓 bucket 到期的實作 (例如 Redis) 回傳 0
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
 }
@@ -0,0 +1,277 @@
+// Package jobs 提供保存在資料庫中的背景工作佇列。
+//
+// 工作以 Enqueue 寫入 jobs 資料表，Run 依佇列取出到期的工作，交給以 Handle 註冊的處理函式執行。
+// 每個佇列有各自的同時執行數量；失敗的工作依次數延後重試，超過最大次數或回傳 Permanent 錯誤後
+// 進入 dead 狀態 (dead letter)，可以用 Retry 重新排入。
+//
+// 多個程序可以同時執行 Run，ClaimDue 保證每個工作同時只會被其中一個執行；
+// 程序在工作結束前中斷時，工作會在租約到期後重新執行，因此處理函式必須可以重複執行。
+//
+// 儲存方式由 itfc.JobRepository 決定：正式環境使用 gormimpl (Postgres)，測試使用 memory。
+package jobs
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultQueue 沒有指定佇列時使用的佇列
+const DefaultQueue = "default"
+
+// EnqueueOptions 排入工作的選項，零值使用預設
+type EnqueueOptions struct {
+	Queue       string    // 預設 DefaultQueue
+	RunAt       time.Time // 預設立即執行
+	UniqueKey   string    // 相同 key 的工作在等待或執行中時，Enqueue 回傳 apperr.ErrConflict
+	MaxAttempts int       // 預設 jobs.max_attempts
+}
+
+// handlerFunc 以 JSON payload 執行工作
+type handlerFunc func(ctx context.Context, payload []byte) error
+
+// Queue 排入並執行背景工作
+type Queue struct {
+	cfg     config.JobsConfig
+	jobRepo itfc.JobRepository
+
+	mu       sync.RWMutex
+	handlers map[string]handlerFunc   // 工作類型 → 處理函式
+	wake     map[string]chan struct{} // 佇列 → 有新工作時通知 Run
+
+	heartbeats map[string]*atomic.Int64 // 佇列 → Run 最後一次檢查工作的時間 (UnixNano)
+}
+
+func NewQueue(cfg config.JobsConfig, jobRepo itfc.JobRepository) *Queue {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 10 * time.Minute
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 10
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = 30 * time.Second
+	}
+	if cfg.MaxRetryDelay <= 0 {
+		cfg.MaxRetryDelay = 6 * time.Hour
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = 7 * 24 * time.Hour
+	}
+
+	// 複製一份，避免修改呼叫者的設定
+	queues := map[string]int{DefaultQueue: 1}
+	for name, concurrency := range cfg.Queues {
+		queues[name] = max(concurrency, 1)
+	}
+	cfg.Queues = queues
+
+	wake := make(map[string]chan struct{}, len(queues))
+	heartbeats := make(map[string]*atomic.Int64, len(queues))
+	for name := range queues {
+		wake[name] = make(chan struct{}, 1)
+		heartbeats[name] = &atomic.Int64{}
+	}
+
+	return &Queue{
+		cfg:        cfg,
+		jobRepo:    jobRepo,
+		handlers:   map[string]handlerFunc{},
+		wake:       wake,
+		heartbeats: heartbeats,
+	}
+}
+
+// Handle 註冊工作類型的處理函式，payload 以 JSON 解碼為 T
+// 請在 Run 之前註冊；同一個類型重複註冊時會 panic
+func Handle[T any](q *Queue, jobType string, fn func(ctx context.Context, payload T) error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.handlers[jobType]; ok {
+		panic("jobs: duplicate handler for " + jobType)
+	}
+	q.handlers[jobType] = func(ctx context.Context, raw []byte) error {
+		var payload T
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return Permanent(fmt.Errorf("decode payload: %w", err))
+		}
+		return fn(ctx, payload)
+	}
+}
+
+// Enqueue 排入工作，payload 以 JSON 保存
+// 佇列必須列在 jobs.queues (或為 DefaultQueue)，否則不會有 worker 執行
+func (q *Queue) Enqueue(ctx context.Context, jobType string, payload any, opts EnqueueOptions) (*model.Job, error) {
+	if opts.Queue == "" {
+		opts.Queue = DefaultQueue
+	}
+	if _, ok := q.cfg.Queues[opts.Queue]; !ok {
+		return nil, fmt.Errorf("jobs: unknown queue %q", opts.Queue)
+	}
+	if opts.RunAt.IsZero() {
+		opts.RunAt = time.Now()
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = q.cfg.MaxAttempts
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("jobs: encode payload: %w", err)
+	}
+
+	j := &model.Job{
+		Queue:       opts.Queue,
+		Type:        jobType,
+		Payload:     string(raw),
+		UniqueKey:   opts.UniqueKey,
+		Status:      model.JobQueued,
+		RunAt:       opts.RunAt.UTC().Truncate(time.Microsecond),
+		MaxAttempts: opts.MaxAttempts,
+	}
+	if err := q.jobRepo.Create(ctx, j); err != nil {
+		return nil, err
+	}
+
+	q.notify(j.Queue)
+	return j, nil
+}
+
+// Get 取得工作，不存在時回傳 apperr.ErrNotFound
+func (q *Queue) Get(ctx context.Context, jobID string) (*model.Job, error) {
+	id, err := uuid.Parse(jobID)
+	if err != nil {
+		return nil, apperr.NotFound("Job not found")
+	}
+
+	j, err := q.jobRepo.Get(ctx, id)
+	if errors.Is(err, apperr.ErrNotFound) {
+		return nil, apperr.NotFound("Job not found")
+	}
+	return j, err
+}
+
+// List 依建立時間由新到舊列出工作，例如以 status=dead 檢查 dead letter
+func (q *Queue) List(ctx context.Context, queue, status, cursor string, limit int) ([]model.Job, string, error) {
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return nil, "", apperr.Wrap(apperr.ErrValidation, "Invalid cursor", err)
+	}
+
+	limit = pagination.Limit(limit)
+	jobs, err := q.jobRepo.List(ctx, queue, status, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(jobs) == limit {
+		last := jobs[len(jobs)-1]
+		next = pagination.Cursor{Time: *last.CreatedAt, ID: last.ID.String()}.Encode()
+	}
+
+	return jobs, next, nil
+}
+
+// Retry 立即重新執行 dead 工作，次數重新計算
+func (q *Queue) Retry(ctx context.Context, jobID string) error {
+	j, err := q.Get(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if j.Status != model.JobDead {
+		return apperr.Conflict("Only dead jobs can be retried")
+	}
+
+	if err := q.jobRepo.Retry(ctx, j.ID, time.Now().UTC().Truncate(time.Microsecond)); err != nil {
+		if errors.Is(err, apperr.ErrConflict) {
+			// 剛好被其他請求重新排入，或有相同 unique key 的工作正在等待
+			return apperr.Conflict("Job was already retried or a job with the same unique key is queued")
+		}
+		return err
+	}
+
+	q.notify(j.Queue)
+	return nil
+}
+
+// Purge 刪除結束超過 jobs.retention 的 succeeded 與 dead 工作，由定期工作執行
+func (q *Queue) Purge(ctx context.Context) (string, error) {
+	n, err := q.jobRepo.DeleteFinished(ctx, time.Now().Add(-q.cfg.Retention))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("deleted %d finished jobs", n), nil
+}
+
+// Depth 回傳各佇列 queued、running 與 dead 工作的數量
+func (q *Queue) Depth(ctx context.Context) ([]model.JobCount, error) {
+	return q.jobRepo.CountByStatus(ctx, []string{model.JobQueued, model.JobRunning, model.JobDead})
+}
+
+// Heartbeat 回傳所有佇列中最久沒有檢查工作的時間，Run 尚未開始時為零
+// 每個佇列至少每 poll_interval 檢查一次，用於確認 worker 沒有卡住
+func (q *Queue) Heartbeat() time.Time {
+	var oldest int64
+	for _, hb := range q.heartbeats {
+		t := hb.Load()
+		if t == 0 {
+			return time.Time{}
+		}
+		if oldest == 0 || t < oldest {
+			oldest = t
+		}
+	}
+	return time.Unix(0, oldest)
+}
+
+// PollInterval 回傳檢查到期工作的間隔
+func (q *Queue) PollInterval() time.Duration {
+	return q.cfg.PollInterval
+}
+
+// notify 通知同一程序中的 Run 立即取出佇列的工作
+func (q *Queue) notify(queue string) {
+	select {
+	case q.wake[queue] <- struct{}{}:
+	default:
+	}
+}
+
+// permanentError 標記不需要重試的錯誤
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 包裝處理函式的錯誤，讓工作不再重試並直接進入 dead 狀態
+// 例如 payload 指向的資料已經不存在
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent 回傳錯誤是否以 Permanent 包裝
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
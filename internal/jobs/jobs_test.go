@@ -0,0 +1,182 @@
+package jobs
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/model"
+	"chatsheet/internal/repository/memory"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type testPayload struct {
+	N int `json:"n"`
+}
+
+// waitStatus 等待工作進入 status
+func waitStatus(t *testing.T, q *Queue, id string, status string) *model.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		j, err := q.Get(context.Background(), id)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if j.Status == status {
+			return j
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job status = %s, want %s", j.Status, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRunDrainsRunningJobs(t *testing.T) {
+	q := NewQueue(config.JobsConfig{PollInterval: 10 * time.Millisecond}, memory.NewJobRepository())
+	started, release := make(chan struct{}), make(chan struct{})
+	Handle(q, "slow", func(ctx context.Context, p testPayload) error {
+		close(started)
+		<-release
+		// 關機時執行中的工作不會被取消
+		return ctx.Err()
+	})
+
+	j, err := q.Enqueue(context.Background(), "slow", testPayload{N: 1}, EnqueueOptions{})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Run(ctx)
+	}()
+
+	<-started
+	cancel()
+	select {
+	case <-done:
+		t.Fatal("Run returned before the running job finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the job finished")
+	}
+	waitStatus(t, q, j.ID.String(), model.JobSucceeded)
+}
+
+func TestFailedJobsRetryThenDie(t *testing.T) {
+	q := NewQueue(config.JobsConfig{PollInterval: 10 * time.Millisecond, RetryDelay: time.Millisecond, MaxRetryDelay: time.Millisecond}, memory.NewJobRepository())
+	calls := make(chan int, 10)
+	Handle(q, "flaky", func(ctx context.Context, p testPayload) error {
+		calls <- p.N
+		if p.N == 0 {
+			return Permanent(errors.New("bad input"))
+		}
+		return errors.New("temporary")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+
+	retried, err := q.Enqueue(ctx, "flaky", testPayload{N: 1}, EnqueueOptions{MaxAttempts: 3})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	j := waitStatus(t, q, retried.ID.String(), model.JobDead)
+	if j.Attempts != 3 || j.LastError != "temporary" {
+		t.Errorf("dead job = %+v, want 3 attempts", j)
+	}
+
+	permanent, err := q.Enqueue(ctx, "flaky", testPayload{N: 0}, EnqueueOptions{MaxAttempts: 3})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	// Permanent 錯誤不重試
+	if j := waitStatus(t, q, permanent.ID.String(), model.JobDead); j.Attempts != 1 {
+		t.Errorf("permanent failure attempts = %d, want 1", j.Attempts)
+	}
+}
+
+func TestEnqueueUniqueKey(t *testing.T) {
+	ctx := context.Background()
+	q := NewQueue(config.JobsConfig{}, memory.NewJobRepository())
+
+	opts := EnqueueOptions{UniqueKey: "sync:acc-1", RunAt: time.Now().Add(time.Hour)}
+	if _, err := q.Enqueue(ctx, "sync", testPayload{}, opts); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if _, err := q.Enqueue(ctx, "sync", testPayload{}, opts); !errors.Is(err, apperr.ErrConflict) {
+		t.Errorf("Enqueue duplicate err = %v, want apperr.ErrConflict", err)
+	}
+	if _, err := q.Enqueue(ctx, "sync", testPayload{}, EnqueueOptions{Queue: "missing"}); err == nil {
+		t.Error("Enqueue to an unknown queue succeeded")
+	}
+}
+
+func TestRetryDelayIsCapped(t *testing.T) {
+	q := NewQueue(config.JobsConfig{RetryDelay: time.Second, MaxRetryDelay: 10 * time.Second}, memory.NewJobRepository())
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 100: 10 * time.Second} {
+		if got := q.retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	q := NewQueue(config.JobsConfig{PollInterval: 10 * time.Millisecond, Queues: map[string]int{"webhooks": 1}}, memory.NewJobRepository())
+	if !q.Heartbeat().IsZero() {
+		t.Fatal("Heartbeat before Run is not zero")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for q.Heartbeat().IsZero() {
+		if time.Now().After(deadline) {
+			t.Fatal("Heartbeat stayed zero after Run started")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	first := q.Heartbeat()
+
+	// 沒有工作時每個佇列仍然每 poll_interval 更新一次
+	time.Sleep(50 * time.Millisecond)
+	if !q.Heartbeat().After(first) {
+		t.Errorf("Heartbeat did not advance while idle: %v then %v", first, q.Heartbeat())
+	}
+}
+
+func TestDepthCollector(t *testing.T) {
+	ctx := context.Background()
+	q := NewQueue(config.JobsConfig{Queues: map[string]int{"webhooks": 1}}, memory.NewJobRepository())
+	for _, queue := range []string{DefaultQueue, DefaultQueue, "webhooks"} {
+		if _, err := q.Enqueue(ctx, "noop", testPayload{}, EnqueueOptions{Queue: queue}); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+
+	want := `
+# HELP jobs_queue_depth Background jobs by queue and status (queued, running, dead).
+# TYPE jobs_queue_depth gauge
+jobs_queue_depth{queue="default",status="queued"} 2
+jobs_queue_depth{queue="webhooks",status="queued"} 1
+`
+	if err := testutil.CollectAndCompare(q.DepthCollector(time.Second), strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
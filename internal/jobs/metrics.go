@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var depthDesc = prometheus.NewDesc(
+	"jobs_queue_depth",
+	"Background jobs by queue and status (queued, running, dead).",
+	[]string{"queue", "status"}, nil,
+)
+
+// depthCollector 在每次抓取 /metrics 時查詢佇列長度
+type depthCollector struct {
+	q       *Queue
+	timeout time.Duration
+}
+
+// DepthCollector 回傳輸出 jobs_queue_depth 的 prometheus.Collector，每次查詢最多等待 timeout
+func (q *Queue) DepthCollector(timeout time.Duration) prometheus.Collector {
+	return depthCollector{q: q, timeout: timeout}
+}
+
+func (c depthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- depthDesc
+}
+
+func (c depthCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	counts, err := c.q.Depth(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(depthDesc, err)
+		return
+	}
+	for _, count := range counts {
+		ch <- prometheus.MustNewConstMetric(depthDesc, prometheus.GaugeValue, float64(count.Count), count.Queue, count.Status)
+	}
+}
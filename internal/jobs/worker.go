@@ -0,0 +1,168 @@
+package jobs
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("chatsheet/internal/jobs")
+
+// Run 執行所有佇列的工作，直到 ctx 結束
+// ctx 結束後不再取出新的工作，並等待執行中的工作結束後才回傳 (graceful drain)；
+// 執行中的工作不會因為 ctx 結束而取消，只受租約時間限制
+func (q *Queue) Run(ctx context.Context) {
+	var running sync.WaitGroup
+	var loops sync.WaitGroup
+	for name, concurrency := range q.cfg.Queues {
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+			q.runQueue(ctx, name, concurrency, &running)
+		}()
+	}
+
+	loops.Wait()
+	running.Wait()
+}
+
+// runQueue 依空閒的執行數量取出佇列中到期的工作，並在背景執行
+func (q *Queue) runQueue(ctx context.Context, queue string, concurrency int, running *sync.WaitGroup) {
+	slots := make(chan struct{}, concurrency)
+	ticker := time.NewTicker(q.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		q.heartbeats[queue].Store(time.Now().UnixNano())
+
+		for ctx.Err() == nil {
+			// 只有這個 goroutine 佔用 slots，其他 goroutine 只會釋放，因此佔用時不會阻塞
+			free := concurrency - len(slots)
+			if free == 0 {
+				break
+			}
+
+			jobs, err := q.jobRepo.ClaimDue(ctx, queue, time.Now().UTC(), q.cfg.Lease, free)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.ErrorContext(ctx, "Failed to claim due jobs", "queue", queue, "err", err)
+				}
+				break
+			}
+
+			for _, j := range jobs {
+				slots <- struct{}{}
+				running.Add(1)
+				go func() {
+					defer running.Done()
+					q.execute(ctx, j)
+					<-slots
+					// 有空閒的執行數量，立即取出下一個工作
+					q.notify(queue)
+				}()
+			}
+
+			if len(jobs) < free {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-q.wake[queue]:
+		}
+	}
+}
+
+// execute 執行工作並記錄結果，失敗時依次數延後重試
+func (q *Queue) execute(ctx context.Context, j model.Job) {
+	var err error
+	if j.Attempts > j.MaxAttempts {
+		// 之前的執行都沒有在租約內結束 (例如程序中斷或處理函式卡住)，不再執行
+		err = Permanent(errors.New("job did not finish within the lease"))
+	} else {
+		// 關機時讓執行中的工作繼續完成，只受租約時間限制
+		runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), q.cfg.Lease)
+		runCtx, span := tracer.Start(runCtx, "job "+j.Type, trace.WithAttributes(
+			attribute.String("job.id", j.ID.String()),
+			attribute.String("job.queue", j.Queue),
+			attribute.Int("job.attempt", j.Attempts),
+		))
+		err = q.call(runCtx, &j)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		cancel()
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	switch {
+	case err == nil:
+		j.Status = model.JobSucceeded
+		j.LastError = ""
+		j.FinishedAt = &now
+	case IsPermanent(err) || j.Attempts >= j.MaxAttempts:
+		j.Status = model.JobDead
+		j.LastError = err.Error()
+		j.FinishedAt = &now
+		slog.ErrorContext(ctx, "Job failed permanently", "job_id", j.ID, "type", j.Type, "attempts", j.Attempts, "err", err)
+	default:
+		j.Status = model.JobQueued
+		j.LastError = err.Error()
+		j.RunAt = now.Add(q.retryDelay(j.Attempts))
+		slog.WarnContext(ctx, "Job failed, will retry", "job_id", j.ID, "type", j.Type, "attempts", j.Attempts, "retry_at", j.RunAt, "err", err)
+	}
+
+	// 工作已經執行完，即使 ctx 已結束也要記錄，避免重複執行
+	err = q.jobRepo.Finish(context.WithoutCancel(ctx), &j)
+	if errors.Is(err, apperr.ErrConflict) {
+		// 租約已經到期，工作被其他程序重新取出
+		slog.WarnContext(ctx, "Job changed while running", "job_id", j.ID, "type", j.Type)
+	} else if err != nil {
+		slog.ErrorContext(ctx, "Failed to record job result", "job_id", j.ID, "type", j.Type, "err", err)
+	}
+}
+
+// call 以註冊的處理函式執行工作，處理函式 panic 時視為失敗
+func (q *Queue) call(ctx context.Context, j *model.Job) (err error) {
+	q.mu.RLock()
+	fn, ok := q.handlers[j.Type]
+	q.mu.RUnlock()
+	if !ok {
+		// 可能是新版本才有的類型 (部署期間)，照一般失敗重試
+		return fmt.Errorf("no handler for job type %q", j.Type)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "Job handler panicked", "job_id", j.ID, "type", j.Type, "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return fn(ctx, []byte(j.Payload))
+}
+
+// retryDelay 第 attempts 次失敗後的重試間隔，每次加倍直到 MaxRetryDelay
+func (q *Queue) retryDelay(attempts int) time.Duration {
+	delay := q.cfg.RetryDelay
+	for i := 1; i < attempts && delay < q.cfg.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, q.cfg.MaxRetryDelay)
+}
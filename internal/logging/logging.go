@@ -0,0 +1,91 @@
+// Package logging 設定應用程式使用的 slog。
+//
+// New 建立的 handler 依序：
+//   - 加上 context 中以 With 附加的欄位 (例如 request_id、user、route) 與目前 span 的 trace_id
+//   - 將設定的敏感欄位 (密碼、token、驗證碼等) 以 [REDACTED] 取代
+//   - 以 text (開發用，彩色) 或 json (正式環境) 輸出
+//
+// 呼叫端必須使用 slog.InfoContext 等帶有 ctx 的函式，才會帶上 context 中的欄位。
+package logging
+
+import (
+	"chatsheet/config"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/MatusOllah/slogcolor"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// New 依設定建立 slog.Handler
+func New(cfg config.LogConfig, w io.Writer) (slog.Handler, error) {
+	var level slog.Level
+	if cfg.Level != "" {
+		if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q", cfg.Level)
+		}
+	}
+
+	var h slog.Handler
+	switch cfg.Format {
+	case "", "text":
+		opts := *slogcolor.DefaultOptions
+		opts.Level = level
+		h = slogcolor.NewHandler(w, &opts)
+	case "json":
+		h = slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+	default:
+		return nil, fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+
+	return &contextHandler{next: NewRedactHandler(h, cfg.Redact)}, nil
+}
+
+type attrsKey struct{}
+
+// With 回傳附加了 attrs 的 context，之後以這個 context 寫入的日誌都會帶上這些欄位
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	prev, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(prev)+len(attrs))
+	merged = append(merged, prev...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+// contextHandler 將 context 中的欄位與 trace_id 加到每一筆日誌
+type contextHandler struct {
+	next slog.Handler
+}
+
+func (h *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	sc := trace.SpanContextFromContext(ctx)
+	if len(attrs) > 0 || sc.IsValid() {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+		if sc.IsValid() {
+			r.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+		}
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{next: h.next.WithGroup(name)}
+}
+
+// normalizeKey 讓 access_token、Access-Token、accessToken 視為同一個欄位
+func normalizeKey(key string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+}
@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"bytes"
+	"chatsheet/config"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestNewAddsContextAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	h, err := New(config.LogConfig{Format: "json", Level: "debug", Redact: []string{"password"}}, &buf)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	ctx := With(context.Background(), slog.String("request_id", "req-1"))
+	ctx = With(ctx, slog.String("user", "alice@example.com"), slog.String("password", "ctx-secret"))
+	incoming := http.Header{}
+	incoming.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx = propagation.TraceContext{}.Extract(ctx, propagation.HeaderCarrier(incoming))
+
+	slog.New(h).DebugContext(ctx, "Handled request", "status", 200)
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("decode log: %v\n%s", err, buf.String())
+	}
+	for key, want := range map[string]any{
+		"msg":        "Handled request",
+		"level":      "DEBUG",
+		"request_id": "req-1",
+		"user":       "alice@example.com",
+		"password":   Redacted,
+		"trace_id":   "4bf92f3577b34da6a3ce929d0e0e4736",
+		"status":     float64(200),
+	} {
+		if entry[key] != want {
+			t.Errorf("%s = %v, want %v", key, entry[key], want)
+		}
+	}
+
+	// 沒有附加欄位的 context 不影響其他日誌
+	buf.Reset()
+	slog.New(h).InfoContext(context.Background(), "Plain")
+	if strings.Contains(buf.String(), "request_id") || strings.Contains(buf.String(), "trace_id") {
+		t.Errorf("log without context attrs = %s", buf.String())
+	}
+}
+
+func TestNewLevelAndFormat(t *testing.T) {
+	var buf bytes.Buffer
+	h, err := New(config.LogConfig{Level: "warn", Redact: []string{"password"}}, &buf)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	l := slog.New(h)
+	l.Info("below level")
+	l.Warn("above level", "password", "text-secret")
+
+	out := buf.String()
+	if strings.Contains(out, "below level") || !strings.Contains(out, "above level") {
+		t.Errorf("text log = %q, want only the warning", out)
+	}
+	if strings.Contains(out, "text-secret") || !strings.Contains(out, Redacted) {
+		t.Errorf("text log = %q, want the password redacted", out)
+	}
+
+	if _, err := New(config.LogConfig{Format: "xml"}, &buf); err == nil {
+		t.Error("New accepted an unknown format")
+	}
+	if _, err := New(config.LogConfig{Level: "loud"}, &buf); err == nil {
+		t.Error("New accepted an unknown level")
+	}
+}
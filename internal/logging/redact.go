@@ -0,0 +1,140 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Redacted 取代敏感欄位的值
+const Redacted = "[REDACTED]"
+
+// RedactHandler 將敏感欄位的值以 Redacted 取代再交給下一個 handler
+// 欄位名稱不分大小寫並忽略 _ 與 -；也處理群組、map、http.Header，
+// 以及字串值中 JSON 格式的同名欄位 (例如記錄下來的請求或響應內容)
+type RedactHandler struct {
+	next   slog.Handler
+	keys   map[string]bool
+	jsonRE *regexp.Regexp
+}
+
+// NewRedactHandler 建立取代 keys 欄位的 handler，keys 為空時不做任何取代
+func NewRedactHandler(next slog.Handler, keys []string) slog.Handler {
+	if len(keys) == 0 {
+		return next
+	}
+
+	h := &RedactHandler{next: next, keys: make(map[string]bool, len(keys))}
+	quoted := make([]string, 0, len(keys))
+	for _, k := range keys {
+		h.keys[normalizeKey(k)] = true
+		quoted = append(quoted, regexp.QuoteMeta(k))
+	}
+	// "password":"..." 或 "password": "..."，不處理跳脫的引號以外的巢狀結構
+	h.jsonRE = regexp.MustCompile(`(?i)("(?:` + strings.Join(quoted, "|") + `)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	return h
+}
+
+func (h *RedactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *RedactHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, h.redactString(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.redact(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *RedactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redact(a)
+	}
+	return &RedactHandler{next: h.next.WithAttrs(redacted), keys: h.keys, jsonRE: h.jsonRE}
+}
+
+func (h *RedactHandler) WithGroup(name string) slog.Handler {
+	return &RedactHandler{next: h.next.WithGroup(name), keys: h.keys, jsonRE: h.jsonRE}
+}
+
+func (h *RedactHandler) sensitive(key string) bool {
+	return h.keys[normalizeKey(key)]
+}
+
+func (h *RedactHandler) redact(a slog.Attr) slog.Attr {
+	if h.sensitive(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindGroup:
+		group := v.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redacted[i] = h.redact(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindString:
+		return slog.String(a.Key, h.redactString(v.String()))
+	case slog.KindAny:
+		return slog.Any(a.Key, h.redactAny(v.Any()))
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+// redactAny 處理常見的 map 類型與 error，其他值原樣輸出
+// error 以 Error() 的字串處理，例如包含上游響應內容的 unipile.APIError
+func (h *RedactHandler) redactAny(v any) any {
+	switch m := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(m))
+		for k, mv := range m {
+			if h.sensitive(k) {
+				out[k] = Redacted
+			} else {
+				out[k] = h.redactAny(mv)
+			}
+		}
+		return out
+	case map[string]string:
+		out := make(map[string]string, len(m))
+		for k, mv := range m {
+			if h.sensitive(k) {
+				out[k] = Redacted
+			} else {
+				out[k] = h.redactString(mv)
+			}
+		}
+		return out
+	case http.Header:
+		out := make(http.Header, len(m))
+		for k, mv := range m {
+			if h.sensitive(k) {
+				out[k] = []string{Redacted}
+			} else {
+				out[k] = mv
+			}
+		}
+		return out
+	case string:
+		return h.redactString(m)
+	case []byte:
+		return h.redactString(string(m))
+	case error:
+		return h.redactString(m.Error())
+	}
+	return v
+}
+
+func (h *RedactHandler) redactString(s string) string {
+	if !strings.Contains(s, `"`) {
+		return s
+	}
+	return h.jsonRE.ReplaceAllString(s, `$1"`+Redacted+`"`)
+}
@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"bytes"
+	"chatsheet/internal/unipile"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newRedactLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(NewRedactHandler(slog.NewJSONHandler(buf, nil), []string{"password", "access_token", "code", "authorization"}))
+}
+
+func TestRedactAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	l := newRedactLogger(&buf).With("password", "with-secret", slog.Group("otp", "code", "123456"))
+
+	l.Info(`login {"password":"message-secret","remember":true}`,
+		"Access-Token", "header-style-secret",
+		"accessToken", "camel-secret",
+		"request", map[string]any{"username": "alice", "password": "map-secret", "nested": map[string]any{"code": "nested-secret"}},
+		"form", map[string]string{"access_token": "form-secret", "note": `{"code":"string-map-secret"}`},
+		"headers", http.Header{"Authorization": {"Bearer header-secret"}, "Accept": {"application/json"}},
+		"body", `{"code" : "body-secret", "ok": "yes"}`,
+		"raw", []byte(`{"access_token":"raw\"secret"}`),
+		"fine", "hello",
+	)
+
+	out := buf.String()
+	for _, secret := range []string{"with-secret", "message-secret", "123456", "header-style-secret", "camel-secret", "map-secret",
+		"nested-secret", "form-secret", "string-map-secret", "header-secret", "body-secret", `raw\\\"secret`} {
+		if strings.Contains(out, secret) {
+			t.Errorf("log leaked %q:\n%s", secret, out)
+		}
+	}
+	for _, want := range []string{`"username":"alice"`, `"fine":"hello"`, `\"ok\": \"yes\"`, `"Accept":["application/json"]`, `\"remember\":true`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRedactErrors(t *testing.T) {
+	var buf bytes.Buffer
+	l := newRedactLogger(&buf)
+
+	apiErr := &unipile.APIError{Status: 400, Title: "Bad Request", Detail: `{"access_token":"upstream-secret","reason":"expired"}`}
+	l.Error("Failed to send", "err", apiErr, "wrapped", fmt.Errorf("reconnect: %w", apiErr), "plain", errors.New("no quotes here"))
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("decode log: %v\n%s", err, buf.String())
+	}
+	if strings.Contains(buf.String(), "upstream-secret") {
+		t.Errorf("log leaked the token inside the error:\n%s", buf.String())
+	}
+	if err, _ := entry["err"].(string); !strings.Contains(err, `"reason":"expired"`) || !strings.Contains(err, Redacted) {
+		t.Errorf("err = %v, want the error text with only the token redacted", entry["err"])
+	}
+	if wrapped, _ := entry["wrapped"].(string); !strings.HasPrefix(wrapped, "reconnect: ") || !strings.Contains(wrapped, Redacted) {
+		t.Errorf("wrapped = %v", entry["wrapped"])
+	}
+	if entry["plain"] != "no quotes here" {
+		t.Errorf("plain = %v, want it unchanged", entry["plain"])
+	}
+}
+
+func TestRedactWithoutKeys(t *testing.T) {
+	next := slog.NewJSONHandler(&bytes.Buffer{}, nil)
+	if h := NewRedactHandler(next, nil); h != next {
+		t.Error("NewRedactHandler without keys should return the next handler")
+	}
+}
@@ -0,0 +1,44 @@
+// Package metrics 提供 Prometheus 格式的指標 (github.com/prometheus/client_golang)。
+//
+// 指標以 promauto.With(metrics.Registry) 建立，通常在使用的套件中宣告為套件變數；
+// 只能在抓取時取得的數值 (例如連線池狀態、佇列長度) 以 prometheus.Collector 註冊到 Registry。
+// Registry 同時包含 Go runtime 與程序的指標 (go_*、process_*)。
+package metrics
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry 是應用程式共用的 Registry
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler 回傳輸出 Registry 所有指標的 http.Handler，每次抓取最多等待 timeout
+// 單一指標收集失敗時仍輸出其他指標，錯誤記錄在日誌
+func Handler(timeout time.Duration) http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{
+		ErrorLog:      errorLog{},
+		ErrorHandling: promhttp.ContinueOnError,
+		Timeout:       timeout,
+	})
+}
+
+// errorLog 把 promhttp 的錯誤寫入 slog
+type errorLog struct{}
+
+func (errorLog) Println(v ...any) {
+	slog.Warn("Failed to collect metrics", "err", fmt.Sprint(v...))
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// failingCollector 每次抓取都回傳錯誤
+type failingCollector struct{ desc *prometheus.Desc }
+
+func (c failingCollector) Describe(ch chan<- *prometheus.Desc) { ch <- c.desc }
+func (c failingCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.NewInvalidMetric(c.desc, errors.New("unavailable"))
+}
+
+func TestHandler(t *testing.T) {
+	requests := promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{Name: "metrics_test_requests_total", Help: "Requests."}, []string{"route"})
+	requests.WithLabelValues("/a").Add(3)
+	broken := failingCollector{prometheus.NewDesc("metrics_test_broken", "Always fails.", nil, nil)}
+	Registry.MustRegister(broken)
+	t.Cleanup(func() {
+		Registry.Unregister(requests)
+		Registry.Unregister(broken)
+	})
+
+	w := httptest.NewRecorder()
+	Handler(time.Second).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	out := w.Body.String()
+
+	// 單一指標失敗時仍輸出其他指標
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200:\n%s", w.Code, out)
+	}
+	for _, want := range []string{
+		`metrics_test_requests_total{route="/a"} 3` + "\n",
+		"# TYPE go_goroutines gauge\n",
+		"process_start_time_seconds ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "metrics_test_broken") {
+		t.Errorf("failed collector was written:\n%s", out)
+	}
+}
@@ -1,8 +1,11 @@
 package middleware
 
 import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/logging"
+	"chatsheet/internal/model"
 	"chatsheet/internal/service"
-	"net/http"
+	"log/slog"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -13,28 +16,38 @@ func AuthMiddleware(authService *service.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header missing"})
-			c.Abort()
+			AbortWithError(c, apperr.Unauthorized("Authorization header missing"))
 			return
 		}
 
 		parts := strings.SplitN(authHeader, " ", 2)
 		if !(len(parts) == 2 && parts[0] == "Bearer") {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token format"})
-			c.Abort()
+			AbortWithError(c, apperr.Unauthorized("Invalid token format"))
 			return
 		}
 
 		tokenStr := parts[1]
 		claims, err := authService.ParseToken(tokenStr)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+			AbortWithError(c, apperr.Unauthorized("Invalid token"))
 			return
 		}
 
-		// 將使用者 ID 存入 Gin context，以便後續的 handler 使用
+		// 將使用者 ID 與角色存入 Gin context，以便後續的 handler 使用
 		c.Set("email", claims.Email)
+		c.Set("role", claims.Role)
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), slog.String("user", claims.Email)))
+		c.Next()
+	}
+}
+
+// RequireAdmin 只允許管理員存取，必須註冊在 AuthMiddleware 之後
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") != model.RoleAdmin {
+			AbortWithError(c, apperr.New(apperr.ErrForbidden, "Admin only"))
+			return
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"chatsheet/internal/apperr"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDKey 是 request id 在 Gin context 中的 key
+const RequestIDKey = "request_id"
+
+// ErrorResponse 是所有錯誤回應共用的 JSON 結構
+type ErrorResponse struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// errorKind 描述一種錯誤對應的 HTTP 狀態碼、錯誤代碼與預設訊息
+type errorKind struct {
+	err     error
+	status  int
+	code    string
+	message string
+}
+
+var errorKinds = []errorKind{
+	{apperr.ErrValidation, http.StatusBadRequest, "validation_error", "Invalid request"},
+	{apperr.ErrUnauthorized, http.StatusUnauthorized, "unauthorized", "Unauthorized"},
+	{apperr.ErrForbidden, http.StatusForbidden, "forbidden", "Forbidden"},
+	{apperr.ErrNotFound, http.StatusNotFound, "not_found", "Resource not found"},
+	{apperr.ErrConflict, http.StatusConflict, "conflict", "Resource already exists"},
+	{apperr.ErrTooManyRequests, http.StatusTooManyRequests, "too_many_requests", "Too many requests"},
+	{apperr.ErrUpstream, http.StatusBadGateway, "upstream_error", "Upstream service error"},
+}
+
+var internalKind = errorKind{nil, http.StatusInternalServerError, "internal_error", "Something went wrong"}
+
+// ErrorMiddleware 將 handler 透過 c.Error 回報的錯誤轉換為統一的 JSON 回應
+// 必須註冊在其他 middleware 之前，才能處理到 AuthMiddleware 等回報的錯誤
+func ErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 {
+			return
+		}
+		err := c.Errors.Last().Err
+
+		kind := internalKind
+		for _, k := range errorKinds {
+			if errors.Is(err, k.err) {
+				kind = k
+				break
+			}
+		}
+
+		resp := ErrorResponse{
+			Code:      kind.code,
+			Message:   kind.message,
+			RequestID: RequestID(c),
+		}
+
+		// 只有 apperr.Error 的訊息可以對外顯示，其他錯誤可能包含內部細節
+		var appErr *apperr.Error
+		if errors.As(err, &appErr) && kind.err != nil {
+			resp.Message = appErr.Message
+			resp.Details = appErr.Details
+		}
+
+		if kind.status >= http.StatusInternalServerError {
+			slog.ErrorContext(c.Request.Context(), "Request failed", "status", kind.status, "err", err)
+		}
+
+		if c.Writer.Written() {
+			// handler 已經寫出回應，無法再改變狀態碼
+			return
+		}
+		c.JSON(kind.status, resp)
+	}
+}
+
+// AbortWithError 回報錯誤並中止後續的 handler，由 ErrorMiddleware 負責輸出
+func AbortWithError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// RequestID 取得目前請求的 request id
+func RequestID(c *gin.Context) string {
+	if id := c.GetString(RequestIDKey); id != "" {
+		return id
+	}
+	return c.GetHeader("X-Request-ID")
+}
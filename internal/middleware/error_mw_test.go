@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"chatsheet/internal/apperr"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveError 以 ErrorMiddleware 處理 handler 回報的 err，回傳狀態碼與解析後的回應
+func serveError(t *testing.T, err error) (int, ErrorResponse, string) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ErrorMiddleware())
+	r.GET("/", func(c *gin.Context) {
+		c.Set(RequestIDKey, "req-1")
+		AbortWithError(c, err)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	var resp ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v\n%s", err, w.Body)
+	}
+	return w.Code, resp, w.Body.String()
+}
+
+func TestErrorMiddlewareMapsKinds(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+	}{
+		{"validation", apperr.Validation("Name is required"), http.StatusBadRequest, "validation_error", "Name is required"},
+		{"unauthorized", apperr.Unauthorized("Token expired"), http.StatusUnauthorized, "unauthorized", "Token expired"},
+		{"forbidden", apperr.New(apperr.ErrForbidden, "Admins only"), http.StatusForbidden, "forbidden", "Admins only"},
+		{"not found", apperr.NotFound("Account not found"), http.StatusNotFound, "not_found", "Account not found"},
+		{"conflict", apperr.Conflict("Email already registered"), http.StatusConflict, "conflict", "Email already registered"},
+		{"too many requests", apperr.TooManyRequests("Daily quota reached"), http.StatusTooManyRequests, "too_many_requests", "Daily quota reached"},
+		{"upstream", apperr.Upstream("Unipile unavailable", errors.New("dial tcp: connection refused")), http.StatusBadGateway, "upstream_error", "Unipile unavailable"},
+		// 沒有訊息的 sentinel 使用預設訊息
+		{"bare sentinel", apperr.ErrNotFound, http.StatusNotFound, "not_found", "Resource not found"},
+		{"wrapped sentinel", fmt.Errorf("get user: %w", apperr.ErrConflict), http.StatusConflict, "conflict", "Resource already exists"},
+		{"wrapped apperr", fmt.Errorf("connect: %w", apperr.Validation("Cookie is required")), http.StatusBadRequest, "validation_error", "Cookie is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp, _ := serveError(t, tt.err)
+			if status != tt.wantStatus || resp.Code != tt.wantCode || resp.Message != tt.wantMessage || resp.RequestID != "req-1" {
+				t.Errorf("response = %d %+v, want %d %s %q", status, resp, tt.wantStatus, tt.wantCode, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestErrorMiddlewareDetails(t *testing.T) {
+	_, resp, _ := serveError(t, apperr.Validation("Invalid fields").WithDetails(map[string]string{"email": "required"}))
+	if details, _ := resp.Details.(map[string]any); details["email"] != "required" {
+		t.Errorf("details = %#v", resp.Details)
+	}
+}
+
+func TestErrorMiddlewareHidesInternalErrors(t *testing.T) {
+	tests := []error{
+		errors.New(`pq: password authentication failed for user "chatsheet"`),
+		fmt.Errorf("query: %w", errors.New(`pq: password authentication failed for user "chatsheet"`)),
+		// 沒有已知種類的 apperr.Error 也不能顯示訊息
+		apperr.New(errors.New("custom kind"), `pq: password authentication failed for user "chatsheet"`),
+	}
+	for _, err := range tests {
+		status, resp, body := serveError(t, err)
+		if status != http.StatusInternalServerError || resp.Code != "internal_error" || resp.Message != "Something went wrong" {
+			t.Errorf("%v: response = %d %+v", err, status, resp)
+		}
+		if strings.Contains(body, "password") || resp.Details != nil {
+			t.Errorf("%v: response leaked the internal error: %s", err, body)
+		}
+	}
+}
+
+func TestErrorMiddlewareKeepsWrittenResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ErrorMiddleware())
+	r.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, "partial")
+		_ = c.Error(errors.New("stream broke"))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "partial" {
+		t.Errorf("response = %d %q, want the handler's response untouched", w.Code, w.Body)
+	}
+}
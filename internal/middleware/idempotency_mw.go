@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"bytes"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/model"
+	"chatsheet/internal/service"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// IdempotencyKeyHeader 客戶端提供的 Idempotency-Key
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader 標示回應是重播的快取
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLength Idempotency-Key 的長度上限
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentBodySize 帶 Idempotency-Key 的請求 body 上限；body 會完整讀入記憶體計算雜湊，
+	// 略大於最大的請求 (送出訊息含附件 15MB)
+	maxIdempotentBodySize = 16 << 20
+)
+
+// responseRecorder 在寫出回應的同時保留一份副本
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware 讓帶有 Idempotency-Key header 的變更請求只會被執行一次
+// 必須註冊在 AuthMiddleware 之後，key 以使用者為範圍
+//   - 第一個請求的成功回應會被快取，之後相同 key 的請求直接重播
+//   - 同時送出的重複請求會等待第一個完成，逾時則回傳 409
+//   - 失敗的請求會釋放 key，客戶端可以用相同的 key 重試
+func IdempotencyMiddleware(svc *service.IdempotencyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || !isMutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			AbortWithError(c, apperr.Validation("Idempotency-Key is too long"))
+			return
+		}
+
+		email := c.GetString("email")
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodySize))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			AbortWithError(c, apperr.Validation(fmt.Sprintf("Request body too large (max %dMB)", maxIdempotentBodySize>>20)))
+			return
+		}
+		if err != nil {
+			AbortWithError(c, apperr.Wrap(apperr.ErrValidation, "Failed to read request body", err))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		rec, err := svc.Begin(c.Request.Context(), email, key, fingerprint(c.Request, body))
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+
+		if rec.Status == model.IdempotencyCompleted {
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(rec.ResponseCode, rec.ContentType, rec.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		c.Next()
+
+		// 請求可能因為客戶端斷線而被取消，保存結果時不應受影響
+		ctx := context.WithoutCancel(c.Request.Context())
+		status := recorder.Status()
+
+		// 錯誤由 ErrorMiddleware 在之後才輸出，因此有 c.Errors 時也視為失敗
+		if len(c.Errors) > 0 || status >= http.StatusBadRequest || !recorder.Written() {
+			if err := svc.Release(ctx, rec); err != nil {
+				slog.ErrorContext(ctx, "Failed to release idempotency key", "key", key, "err", err)
+			}
+			return
+		}
+
+		if err := svc.Complete(ctx, rec, status, recorder.Header().Get("Content-Type"), recorder.body.Bytes()); err != nil {
+			slog.ErrorContext(ctx, "Failed to store idempotent response", "key", key, "err", err)
+		}
+	}
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// fingerprint 以 method、path、query 與 body 計算請求的雜湊，query 參數依名稱排序
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method)
+	io.WriteString(h, "\n")
+	io.WriteString(h, r.URL.Path)
+	io.WriteString(h, "\n")
+	io.WriteString(h, r.URL.Query().Encode())
+	io.WriteString(h, "\n")
+	h.Write(bodyDigest(r, body))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// bodyDigest 回傳計算 fingerprint 用的 body
+// multipart body 以各 part 的內容計算，不受每次請求隨機產生的 boundary 影響；無法解析時使用原始 body
+func bodyDigest(r *http.Request, body []byte) []byte {
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if strings.HasPrefix(mediaType, "multipart/") {
+		h := sha256.New()
+		if err := hashMultipart(h, body, params["boundary"]); err == nil {
+			return h.Sum(nil)
+		}
+	}
+	return body
+}
+
+// hashMultipart 依序寫入每個 part 的欄位名稱、檔名、Content-Type 與內容
+func hashMultipart(w io.Writer, body []byte, boundary string) error {
+	if boundary == "" {
+		return errors.New("missing multipart boundary")
+	}
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%q %q %q %d\n", part.FormName(), part.FileName(), part.Header.Get("Content-Type"), len(content))
+		w.Write(content)
+	}
+}
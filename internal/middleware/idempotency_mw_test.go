@@ -0,0 +1,203 @@
+package middleware
+
+import (
+	"bytes"
+	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/repository/memory"
+	"chatsheet/internal/service"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newIdempotencyRouter 建立套用 IdempotencyMiddleware 的路由，calls 記錄 handler 執行的次數
+// body 為 "fail" 時 handler 回傳錯誤
+func newIdempotencyRouter(calls *int) *gin.Engine {
+	svc := service.NewIdempotencyService(memory.NewIdempotencyRepository(), config.IdempotencyConfig{
+		TTL:         time.Hour,
+		LockTimeout: time.Minute,
+		WaitTimeout: time.Second,
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ErrorMiddleware())
+	// 以 X-Test-Email 代替 AuthMiddleware
+	r.Use(func(c *gin.Context) {
+		c.Set("email", c.GetHeader("X-Test-Email"))
+	})
+	r.Use(IdempotencyMiddleware(svc))
+	r.POST("/things", func(c *gin.Context) {
+		*calls++
+		body, _ := c.GetRawData()
+		if string(body) == "fail" {
+			c.Error(apperr.Upstream("upstream failed", nil))
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"call": *calls, "body": string(body)})
+	})
+	return r
+}
+
+func postIdempotent(r http.Handler, email, key, body string) *httptest.ResponseRecorder {
+	return postIdempotentTo(r, "/things", "", email, key, body)
+}
+
+func postIdempotentTo(r http.Handler, target, contentType, email, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("X-Test-Email", email)
+	req.Header.Set(IdempotencyKeyHeader, key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	var calls int
+	r := newIdempotencyRouter(&calls)
+
+	first := postIdempotent(r, "a@example.com", "key-1", "x")
+	second := postIdempotent(r, "a@example.com", "key-1", "x")
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %s, want %d %s", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("replay missing %s header", IdempotentReplayedHeader)
+	}
+	if first.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("first response has %s header", IdempotentReplayedHeader)
+	}
+}
+
+func TestIdempotencyKeyReusedWithDifferentBody(t *testing.T) {
+	var calls int
+	r := newIdempotencyRouter(&calls)
+
+	postIdempotent(r, "a@example.com", "key-1", "x")
+	w := postIdempotent(r, "a@example.com", "key-1", "y")
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400, body = %s", w.Code, w.Body)
+	}
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+}
+
+func TestIdempotencyKeyScopedToUser(t *testing.T) {
+	var calls int
+	r := newIdempotencyRouter(&calls)
+
+	postIdempotent(r, "a@example.com", "key-1", "x")
+	w := postIdempotent(r, "b@example.com", "key-1", "x")
+
+	if calls != 2 {
+		t.Fatalf("handler ran %d times, want 2", calls)
+	}
+	if w.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("another user's request was replayed: %s", w.Body)
+	}
+}
+
+func TestIdempotencyFailedRequestReleasesKey(t *testing.T) {
+	var calls int
+	r := newIdempotencyRouter(&calls)
+
+	// 失敗的請求不快取，客戶端可以用相同的 key 重試
+	if w := postIdempotent(r, "a@example.com", "key-1", "fail"); w.Code != http.StatusBadGateway {
+		t.Fatalf("failed request status = %d, want 502, body = %s", w.Code, w.Body)
+	}
+	if w := postIdempotent(r, "a@example.com", "key-1", "fail"); w.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Fatalf("failed response was replayed: %s", w.Body)
+	}
+	if calls != 2 {
+		t.Errorf("handler ran %d times, want 2", calls)
+	}
+
+	w := postIdempotent(r, "a@example.com", "key-2", "x")
+	want := fmt.Sprintf(`{"body":"x","call":%d}`, calls)
+	if w.Code != http.StatusCreated || w.Body.String() != want {
+		t.Errorf("response = %d %s, want 201 %s", w.Code, w.Body, want)
+	}
+}
+
+func TestIdempotencyFingerprintIncludesQuery(t *testing.T) {
+	var calls int
+	r := newIdempotencyRouter(&calls)
+
+	postIdempotentTo(r, "/things?account=1&dry_run=true", "", "a@example.com", "key-1", "x")
+	// 參數順序不同仍是同一個請求
+	if w := postIdempotentTo(r, "/things?dry_run=true&account=1", "", "a@example.com", "key-1", "x"); w.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("reordered query = %d %s, want a replay", w.Code, w.Body)
+	}
+	if w := postIdempotentTo(r, "/things?account=2&dry_run=true", "", "a@example.com", "key-1", "x"); w.Code != http.StatusBadRequest {
+		t.Errorf("different query = %d %s, want 400", w.Code, w.Body)
+	}
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+}
+
+// multipartBody 以新的隨機 boundary 建立含一個附件的 multipart body
+func multipartBody(t *testing.T, text, file string) (string, string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("text", text)
+	fw, err := mw.CreateFormFile("attachments", "photo.png")
+	if err != nil {
+		t.Fatalf("CreateFormFile: %v", err)
+	}
+	fw.Write([]byte(file))
+	mw.Close()
+	return mw.FormDataContentType(), buf.String()
+}
+
+func TestIdempotencyMultipartIgnoresBoundary(t *testing.T) {
+	var calls int
+	r := newIdempotencyRouter(&calls)
+
+	contentType, body := multipartBody(t, "hello", "png-bytes")
+	postIdempotentTo(r, "/things", contentType, "a@example.com", "key-1", body)
+
+	// 重試時客戶端通常會產生新的 boundary
+	contentType, body = multipartBody(t, "hello", "png-bytes")
+	if w := postIdempotentTo(r, "/things", contentType, "a@example.com", "key-1", body); w.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("retry with a new boundary = %d %s, want a replay", w.Code, w.Body)
+	}
+	contentType, body = multipartBody(t, "hello", "other-bytes")
+	if w := postIdempotentTo(r, "/things", contentType, "a@example.com", "key-1", body); w.Code != http.StatusBadRequest {
+		t.Errorf("different attachment = %d %s, want 400", w.Code, w.Body)
+	}
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+}
+
+func TestIdempotencyBodyTooLarge(t *testing.T) {
+	var calls int
+	r := newIdempotencyRouter(&calls)
+
+	w := postIdempotent(r, "a@example.com", "key-1", strings.Repeat("x", maxIdempotentBodySize+1))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "too large") {
+		t.Errorf("oversized body = %d %s, want 400", w.Code, w.Body)
+	}
+	if calls != 0 {
+		t.Errorf("handler ran %d times, want 0", calls)
+	}
+}
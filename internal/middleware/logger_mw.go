@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoggerMiddleware 取代 gin 的 Logger，以 slog 記錄每個請求
+// 只記錄路徑，不記錄 query string 與 header (可能包含 token)；必須註冊在 RequestIDMiddleware 之後
+// skipPaths (例如健康檢查) 只在失敗時記錄
+func LoggerMiddleware(skipPaths ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(skipPaths))
+	for _, p := range skipPaths {
+		skip[p] = true
+	}
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		if skip[c.Request.URL.Path] && status < http.StatusBadRequest {
+			return
+		}
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		// 之後的 middleware 會替換 c.Request，這時的 context 已帶有 user 與 trace_id
+		slog.LogAttrs(c.Request.Context(), level, "Request",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("size", c.Writer.Size()),
+		)
+	}
+}
+
+// RecoveryMiddleware 取代 gin 的 Recovery：handler panic 時記錄 stack，並以 500 交給 ErrorMiddleware 回應
+func RecoveryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				if err, ok := r.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					// 用戶端中斷連線，交給 net/http 處理
+					panic(r)
+				}
+				slog.ErrorContext(c.Request.Context(), "Handler panicked", "panic", r, "stack", string(debug.Stack()))
+				AbortWithError(c, fmt.Errorf("panic: %v", r))
+			}
+		}()
+		c.Next()
+	}
+}
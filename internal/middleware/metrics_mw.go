@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"chatsheet/internal/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var httpRequestDuration = promauto.With(metrics.Registry).NewHistogramVec(prometheus.HistogramOpts{
+	Name: "http_request_duration_seconds",
+	Help: "HTTP request latency by route and status.",
+}, []string{"method", "route", "status"})
+
+// MetricsMiddleware 記錄每個請求的處理時間
+// route 使用路由樣板 (例如 /api/chats/:chat_id/messages) 而非實際路徑，避免每個 ID 產生一個序列
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			// NoRoute (前端頁面與不存在的路徑)
+			route = "unmatched"
+		}
+		httpRequestDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Observe(time.Since(start).Seconds())
+	}
+}
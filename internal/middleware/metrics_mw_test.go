@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"chatsheet/internal/metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMetricsMiddlewareUsesRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(MetricsMiddleware())
+	r.GET("/metrics-test/:chat_id/messages", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	for _, path := range []string{"/metrics-test/chat-1/messages", "/metrics-test/chat-2/messages", "/metrics-test/missing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	metrics.Handler(time.Second).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	out := w.Body.String()
+
+	if want := `http_request_duration_seconds_count{method="GET",route="/metrics-test/:chat_id/messages",status="204"} 2`; !strings.Contains(out, want) {
+		t.Errorf("output missing %q:\n%s", want, out)
+	}
+	if want := `http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 1`; !strings.Contains(out, want) {
+		t.Errorf("output missing %q:\n%s", want, out)
+	}
+	if strings.Contains(out, "chat-1") {
+		t.Error("request path leaked into the route label")
+	}
+}
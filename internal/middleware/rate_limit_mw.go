@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/metrics"
+	"chatsheet/internal/service"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// RateLimit-* header (IETF draft-ietf-httpapi-ratelimit-headers)
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+	RateLimitPolicyHeader    = "RateLimit-Policy"
+)
+
+var rateLimitRequests = promauto.With(metrics.Registry).NewCounterVec(prometheus.CounterOpts{
+	Name: "rate_limit_requests_total",
+	Help: "Requests checked by rate limit policies, by outcome (allowed, limited or error).",
+}, []string{"policy", "outcome"})
+
+// RateLimitMiddleware 依 config 中的 policy 限制請求，超過時以 429 回應
+// 必須註冊在 AuthMiddleware 之後，才能以使用者為鍵；store 無法使用時不限制請求 (fail open)
+// 有多個 policy 時先確認全部都會通過才取出 token，被拒絕的請求不會消耗其他 policy 的額度；
+// 回應帶有 RateLimit-* header，回報剩下最少的一個 policy
+func RateLimitMiddleware(svc *service.RateLimitService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policies := svc.Policies(c.Request.Method, c.FullPath())
+		if len(policies) == 0 {
+			c.Next()
+			return
+		}
+
+		if len(policies) > 1 {
+			for _, p := range policies {
+				kind, subject := rateLimitSubject(c, p.Key)
+				// 錯誤在下面 Take 時記錄
+				if res, err := svc.Peek(c.Request.Context(), p, kind, subject); err == nil && !res.Allowed {
+					abortRateLimited(c, p, res)
+					return
+				}
+			}
+		}
+
+		var tightest *service.RateLimitPolicy
+		var tightestRes itfc.RateLimitResult
+		for _, p := range policies {
+			kind, subject := rateLimitSubject(c, p.Key)
+			res, err := svc.Take(c.Request.Context(), p, kind, subject)
+			if err != nil {
+				rateLimitRequests.WithLabelValues(p.Name, "error").Inc()
+				slog.WarnContext(c.Request.Context(), "Rate limit unavailable, allowing request", "policy", p.Name, "err", err)
+				continue
+			}
+
+			// 同時的其他請求可能在 Peek 之後取走了最後的 token
+			if !res.Allowed {
+				abortRateLimited(c, p, res)
+				return
+			}
+
+			rateLimitRequests.WithLabelValues(p.Name, "allowed").Inc()
+			if tightest == nil || res.Remaining < tightestRes.Remaining {
+				tightest, tightestRes = p, res
+			}
+		}
+
+		if tightest != nil {
+			setRateLimitHeaders(c, tightest, tightestRes)
+		}
+		c.Next()
+	}
+}
+
+// abortRateLimited 以 429 回應超過 p 的請求
+func abortRateLimited(c *gin.Context, p *service.RateLimitPolicy, res itfc.RateLimitResult) {
+	rateLimitRequests.WithLabelValues(p.Name, "limited").Inc()
+	setRateLimitHeaders(c, p, res)
+	retryAfter := ceilSeconds(res.RetryAfter)
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	AbortWithError(c, apperr.TooManyRequests(fmt.Sprintf("Too many requests, retry in %d seconds", retryAfter)).
+		WithDetails(service.RateLimitExceeded{Policy: p.Name, Limit: p.Limit, Period: p.Period.String(), RetryAfter: retryAfter}))
+}
+
+// rateLimitSubject 回傳 policy 使用的鍵；請求沒有登入的使用者時改用 IP
+// IP 來自 c.ClientIP，只有 server.trusted_proxies 送來的 X-Forwarded-For 會被採用
+func rateLimitSubject(c *gin.Context, key string) (kind, subject string) {
+	switch key {
+	case service.RateLimitByUser:
+		if email := c.GetString("email"); email != "" {
+			return service.RateLimitByUser, email
+		}
+	}
+	return service.RateLimitByIP, c.ClientIP()
+}
+
+// setRateLimitHeaders 寫入 RateLimit-* header；Reset 為補滿的秒數，未通過時為可以重試的秒數
+func setRateLimitHeaders(c *gin.Context, p *service.RateLimitPolicy, res itfc.RateLimitResult) {
+	reset := res.ResetAfter
+	if !res.Allowed {
+		reset = res.RetryAfter
+	}
+	c.Header(RateLimitLimitHeader, strconv.Itoa(p.Bucket.Capacity))
+	c.Header(RateLimitRemainingHeader, strconv.Itoa(res.Remaining))
+	c.Header(RateLimitResetHeader, strconv.Itoa(ceilSeconds(reset)))
+	c.Header(RateLimitPolicyHeader, fmt.Sprintf("%d;w=%d", p.Limit, ceilSeconds(p.Period)))
+}
+
+// ceilSeconds 將時間無條件進位為秒，讓用戶端不會太早重試
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
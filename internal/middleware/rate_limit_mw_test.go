@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/repository/memory"
+	"chatsheet/internal/service"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newRateLimitRouter 建立套用 RateLimitMiddleware 的路由
+// 以 X-Test-Email 代替 AuthMiddleware
+func newRateLimitRouter(t *testing.T, policies map[string]config.RateLimitPolicy) *gin.Engine {
+	t.Helper()
+
+	svc, err := service.NewRateLimitService(config.RateLimitConfig{Enabled: true, Policies: policies}, memory.NewRateLimitStore())
+	if err != nil {
+		t.Fatalf("NewRateLimitService: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	// 與 server.trusted_proxies 為空時相同
+	r.SetTrustedProxies(nil)
+	r.Use(ErrorMiddleware())
+	r.Use(func(c *gin.Context) {
+		if email := c.GetHeader("X-Test-Email"); email != "" {
+			c.Set("email", email)
+		}
+	})
+	r.Use(RateLimitMiddleware(svc))
+	r.POST("/limited", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.POST("/open", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	return r
+}
+
+func postLimited(r http.Handler, ip string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/limited", nil)
+	req.RemoteAddr = ip + ":1234"
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitByIP(t *testing.T) {
+	r := newRateLimitRouter(t, map[string]config.RateLimitPolicy{
+		"login": {Routes: []string{"POST /limited"}, Key: service.RateLimitByIP, Limit: 2, Period: time.Minute},
+	})
+
+	for i, wantRemaining := range []string{"1", "0"} {
+		w := postLimited(r, "203.0.113.1")
+		if w.Code != http.StatusNoContent || w.Header().Get(RateLimitRemainingHeader) != wantRemaining {
+			t.Fatalf("request %d = %d remaining %q, want 204 with %s remaining", i+1, w.Code, w.Header().Get(RateLimitRemainingHeader), wantRemaining)
+		}
+	}
+	if got := postLimited(r, "203.0.113.1").Header().Get(RateLimitPolicyHeader); got != "2;w=60" {
+		t.Errorf("RateLimit-Policy = %q, want 2;w=60", got)
+	}
+
+	// 沒有設定信任的 proxy，X-Forwarded-For 不會改變 IP
+	w := postLimited(r, "203.0.113.1", "X-Forwarded-For", "198.51.100.7")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("spoofed X-Forwarded-For = %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "30" || w.Header().Get(RateLimitRemainingHeader) != "0" {
+		t.Errorf("429 headers = %v", w.Header())
+	}
+	var body struct {
+		Code    string                    `json:"code"`
+		Details service.RateLimitExceeded `json:"details"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode 429 body: %v", err)
+	}
+	if body.Details.Policy != "login" || body.Details.Limit != 2 || body.Details.RetryAfter != 30 {
+		t.Errorf("429 details = %+v", body.Details)
+	}
+
+	if w := postLimited(r, "203.0.113.2"); w.Code != http.StatusNoContent {
+		t.Errorf("other IP = %d, want 204", w.Code)
+	}
+
+	// 沒有 policy 的路由不限制也不回傳 header
+	req := httptest.NewRequest(http.MethodPost, "/open", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent || w.Header().Get(RateLimitLimitHeader) != "" {
+		t.Errorf("route without policy = %d %v", w.Code, w.Header())
+	}
+}
+
+func TestRateLimitByUser(t *testing.T) {
+	r := newRateLimitRouter(t, map[string]config.RateLimitPolicy{
+		"connect": {Routes: []string{"POST /limited"}, Key: service.RateLimitByUser, Limit: 1, Period: time.Hour},
+	})
+
+	if w := postLimited(r, "203.0.113.1", "X-Test-Email", "a@example.com"); w.Code != http.StatusNoContent {
+		t.Fatalf("first request = %d, want 204", w.Code)
+	}
+	// 同一個使用者換 IP 也受限制
+	if w := postLimited(r, "203.0.113.9", "X-Test-Email", "a@example.com"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("same user from another IP = %d, want 429", w.Code)
+	}
+	if w := postLimited(r, "203.0.113.1", "X-Test-Email", "b@example.com"); w.Code != http.StatusNoContent {
+		t.Errorf("other user = %d, want 204", w.Code)
+	}
+}
+
+func TestRateLimitDeniedRequestKeepsOtherTokens(t *testing.T) {
+	r := newRateLimitRouter(t, map[string]config.RateLimitPolicy{
+		"a-user": {Routes: []string{"POST /limited"}, Key: service.RateLimitByUser, Limit: 2, Period: time.Hour},
+		"b-ip":   {Routes: []string{"POST /limited"}, Key: service.RateLimitByIP, Limit: 1, Period: time.Hour},
+	})
+
+	if w := postLimited(r, "203.0.113.1", "X-Test-Email", "a@example.com"); w.Code != http.StatusNoContent {
+		t.Fatalf("first request = %d, want 204", w.Code)
+	}
+	w := postLimited(r, "203.0.113.1", "X-Test-Email", "a@example.com")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get(RateLimitPolicyHeader) != "1;w=3600" {
+		t.Fatalf("second request from the same IP = %d %v, want 429 from b-ip", w.Code, w.Header())
+	}
+	// 被 b-ip 拒絕的請求沒有消耗 a-user 的額度
+	w = postLimited(r, "203.0.113.2", "X-Test-Email", "a@example.com")
+	if w.Code != http.StatusNoContent {
+		t.Errorf("request from another IP = %d, want 204 with the user's second token", w.Code)
+	}
+	if w := postLimited(r, "203.0.113.3", "X-Test-Email", "a@example.com"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("third allowed request = %d, want 429 from a-user", w.Code)
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"chatsheet/internal/logging"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader 是傳遞 request id 的 header
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength 上游傳入的 request id 長度上限
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware 沿用上游 (例如負載平衡器) 傳入的 X-Request-ID，沒有或格式不合時產生新的
+// request id 會寫回響應 header 與錯誤回應，並與路由一起附加到請求的日誌 context
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		c.Set(RequestIDKey, id)
+		c.Header(RequestIDHeader, id)
+
+		ctx := logging.With(c.Request.Context(), slog.String("request_id", id), slog.String("route", c.FullPath()))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// validRequestID 只接受可見的 ASCII 字元，避免日誌注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
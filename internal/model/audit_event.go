@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/google/uuid"
+)
+
+// 稽核事件的動作
+const (
+	AuditUserSignup       = "user.signup"
+	AuditUserLogin        = "user.login"
+	AuditAccountLink      = "account.link"
+	AuditAccountReconnect = "account.reconnect"
+	AuditAccountRemove    = "account.remove"
+	AuditCheckpointSolve  = "account.checkpoint"
+)
+
+// 稽核事件的結果
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEvent 是只能新增、不能修改的安全稽核紀錄
+type AuditEvent struct {
+	ID         uuid.UUID `gorm:"primaryKey;default:gen_random_uuid();not null" json:"id"`
+	ActorEmail string    `gorm:"not null;index:idx_audit_events_actor_created,priority:1" json:"actor_email"` // 執行動作的使用者 (登入失敗時為嘗試的 email)
+	Action     string    `gorm:"not null" json:"action"`                                                      // 例如: "account.link"
+	TargetType string    `json:"target_type"`                                                                 // 例如: "unipile_account"
+	TargetID   string    `json:"target_id"`                                                                   // 例如: Unipile account_id
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	Result     string    `gorm:"not null" json:"result"` // success 或 failure
+	Reason     string    `json:"reason,omitempty"`       // 失敗原因
+	CreatedAt  time.Time `gorm:"not null;index:idx_audit_events_actor_created,priority:2;index" json:"created_at"`
+}
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	uuid "github.com/google/uuid"
+)
+
+// 外展活動的狀態
+const (
+	CampaignActive = "active"
+	CampaignPaused = "paused" // 暫停時不執行任何步驟，恢復後繼續
+)
+
+// 外展活動步驟的動作
+const (
+	StepInvite  = "invite"  // 送出連結邀請，Text 為附加的訊息
+	StepMessage = "message" // 送出訊息
+)
+
+// 報名的狀態
+const (
+	EnrollmentActive    = "active"    // 等待執行下一步
+	EnrollmentCompleted = "completed" // 所有步驟都已執行
+	EnrollmentReplied   = "replied"   // 對方已回覆，自動停止
+	EnrollmentFailed    = "failed"    // 步驟重試後仍然失敗
+	EnrollmentStopped   = "stopped"   // 使用者手動停止
+)
+
+// CampaignStep 是外展活動的一個步驟
+type CampaignStep struct {
+	Action     string `json:"action"`      // invite 或 message
+	DelayHours int    `json:"delay_hours"` // 與上一步 (第一步為報名時) 間隔的小時數
+	Text       string `json:"text"`
+}
+
+// CampaignSteps 以 JSON 陣列儲存在單一欄位的步驟列表
+type CampaignSteps []CampaignStep
+
+func (s CampaignSteps) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]CampaignStep(s))
+	return string(b), err
+}
+
+func (s *CampaignSteps) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, (*[]CampaignStep)(s))
+	case string:
+		return json.Unmarshal([]byte(v), (*[]CampaignStep)(s))
+	default:
+		return fmt.Errorf("cannot scan %T into CampaignSteps", src)
+	}
+}
+
+// GormDataType 讓 AutoMigrate 建立 text 欄位
+func (CampaignSteps) GormDataType() string {
+	return "text"
+}
+
+// Campaign 是透過連結帳號執行的多步驟外展活動
+// 建立後步驟不能修改，避免進行中的報名對應到不同的步驟
+type Campaign struct {
+	ID        uuid.UUID     `gorm:"primaryKey;default:gen_random_uuid();not null" json:"id"`
+	UserEmail string        `gorm:"not null;index" json:"user_email"` // 建立活動的使用者
+	AccountID string        `gorm:"not null" json:"account_id"`       // 執行步驟的 Unipile account_id
+	Name      string        `gorm:"not null" json:"name"`
+	Status    string        `gorm:"not null;default:active" json:"status"` // active 或 paused
+	Steps     CampaignSteps `gorm:"not null" json:"steps"`
+
+	CreatedAt *time.Time `gorm:"default:now()" json:"created_at"`
+	UpdatedAt *time.Time `gorm:"default:now()" json:"updated_at"`
+}
+
+// Enrollment 是一位對象 (prospect) 在外展活動中的進度
+type Enrollment struct {
+	ID          uuid.UUID  `gorm:"primaryKey;default:gen_random_uuid();not null" json:"id"`
+	CampaignID  uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_enrollments_campaign_provider,priority:1" json:"campaign_id"`
+	AccountID   string     `gorm:"not null;index:idx_enrollments_prospect,priority:1" json:"account_id"`                                                           // 與活動相同，用於比對對方的回覆
+	ProviderID  string     `gorm:"not null;uniqueIndex:idx_enrollments_campaign_provider,priority:2;index:idx_enrollments_prospect,priority:2" json:"provider_id"` // 對方的 LinkedIn provider id
+	Name        string     `json:"name"`
+	Status      string     `gorm:"not null;default:active;index:idx_enrollments_due,priority:1" json:"status"`
+	CurrentStep int        `gorm:"not null;default:0" json:"current_step"`                  // 下一個要執行的步驟 (從 0 開始)
+	NextRunAt   *time.Time `gorm:"index:idx_enrollments_due,priority:2" json:"next_run_at"` // 下一步的執行時間，結束後為 nil
+	LeaseID     *uuid.UUID `gorm:"type:uuid" json:"-"`                                      // 最近一次取出的租約，記錄步驟結果時確認報名仍由同一次取出持有
+	ChatID      string     `json:"chat_id,omitempty"`                                       // 最後一次送出訊息的 Unipile chat id
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`                      // 目前步驟失敗的次數
+	LastError   string     `json:"last_error,omitempty"`
+
+	CreatedAt *time.Time `gorm:"default:now()" json:"created_at"`
+	UpdatedAt *time.Time `gorm:"default:now()" json:"updated_at"`
+}
+
+// EnrollmentEvent 是報名狀態機的歷史，每次執行步驟或改變狀態都會新增一筆，只能新增
+type EnrollmentEvent struct {
+	ID           uuid.UUID `gorm:"primaryKey;default:gen_random_uuid();not null" json:"id"`
+	CampaignID   uuid.UUID `gorm:"type:uuid;not null;index:idx_enrollment_events_campaign_created,priority:1" json:"campaign_id"`
+	EnrollmentID uuid.UUID `gorm:"type:uuid;not null;index" json:"enrollment_id"`
+	ProviderID   string    `json:"provider_id"`
+	Step         int       `json:"step"`             // 相關的步驟 (從 0 開始)
+	Action       string    `json:"action,omitempty"` // 執行的步驟動作，狀態改變時為空字串
+	FromStatus   string    `json:"from_status"`
+	ToStatus     string    `json:"to_status"`
+	Detail       string    `json:"detail,omitempty"` // 例如錯誤訊息
+	CreatedAt    time.Time `gorm:"not null;index:idx_enrollment_events_campaign_created,priority:2" json:"created_at"`
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/google/uuid"
+)
+
+// Chat 是同步到本地的 LinkedIn 對話
+type Chat struct {
+	ID                 uuid.UUID `gorm:"primaryKey;default:gen_random_uuid();not null" json:"id"`
+	AccountID          string    `gorm:"not null;index:idx_chats_account_last,priority:1" json:"account_id"` // Unipile account_id
+	UnipileID          string    `gorm:"unique;not null" json:"unipile_id"`                                  // Unipile chat id
+	ProviderID         string    `json:"provider_id"`
+	AttendeeProviderID string    `json:"attendee_provider_id"` // 一對一對話中對方的 provider id
+	Name               string    `json:"name"`
+	Type               int       `gorm:"not null;default:0" json:"type"` // 0: 一對一, 1: 群組
+	UnreadCount        int       `gorm:"not null;default:0" json:"unread_count"`
+	Archived           bool      `gorm:"not null;default:false" json:"archived"`
+	ReadOnly           bool      `gorm:"not null;default:false" json:"read_only"`
+	LastMessageAt      time.Time `gorm:"not null;index:idx_chats_account_last,priority:2" json:"last_message_at"`
+
+	// 訊息回填 (backfill) 的進度，重新啟動時從 MessagesCursor 繼續
+	MessagesCursor     string `json:"-"`
+	MessagesBackfilled bool   `gorm:"not null;default:false" json:"messages_backfilled"`
+
+	CreatedAt *time.Time `gorm:"default:now()" json:"created_at"`
+	UpdatedAt *time.Time `gorm:"default:now()" json:"updated_at"`
+}
+
+// ChatAttendee 是同步到本地的對話參與者
+type ChatAttendee struct {
+	ID         uuid.UUID  `gorm:"primaryKey;default:gen_random_uuid();not null" json:"id"`
+	AccountID  string     `gorm:"not null;index" json:"account_id"`                                                // Unipile account_id
+	ChatID     string     `gorm:"not null;uniqueIndex:idx_chat_attendees_chat_provider,priority:1" json:"chat_id"` // Unipile chat id
+	UnipileID  string     `json:"unipile_id"`                                                                      // Unipile attendee id
+	ProviderID string     `gorm:"not null;uniqueIndex:idx_chat_attendees_chat_provider,priority:2" json:"provider_id"`
+	Name       string     `json:"name"`
+	IsSelf     bool       `gorm:"not null;default:false" json:"is_self"`
+	ProfileURL string     `json:"profile_url,omitempty"`
+	PictureURL string     `json:"picture_url,omitempty"`
+	CreatedAt  *time.Time `gorm:"default:now()" json:"created_at"`
+	UpdatedAt  *time.Time `gorm:"default:now()" json:"updated_at"`
+}
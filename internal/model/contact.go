@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/google/uuid"
+)
+
+// Contact 快取透過連結帳號查詢到的 LinkedIn 個人檔案，用於補充對話參與者的資訊
+// 超過設定的 TTL 後，下次查詢會重新向 Unipile 取得
+type Contact struct {
+	ID               uuid.UUID `gorm:"primaryKey;default:gen_random_uuid();not null" json:"id"`
+	AccountID        string    `gorm:"not null;uniqueIndex:idx_contacts_account_provider,priority:1" json:"account_id"`  // 查詢時使用的 Unipile account_id
+	ProviderID       string    `gorm:"not null;uniqueIndex:idx_contacts_account_provider,priority:2" json:"provider_id"` // LinkedIn provider id
+	PublicIdentifier string    `gorm:"index" json:"public_identifier"`                                                   // 個人檔案網址中的名稱
+	Name             string    `json:"name"`
+	FirstName        string    `json:"first_name"`
+	LastName         string    `json:"last_name"`
+	Headline         string    `json:"headline"`
+	Company          string    `json:"company"` // 目前任職的公司
+	Location         string    `json:"location"`
+	ProfileURL       string    `json:"profile_url"`
+	PictureURL       string    `json:"picture_url"`
+	FetchedAt        time.Time `gorm:"not null" json:"fetched_at"` // 上次向 Unipile 取得的時間
+
+	CreatedAt *time.Time `gorm:"default:now()" json:"created_at"`
+	UpdatedAt *time.Time `gorm:"default:now()" json:"updated_at"`
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/google/uuid"
+)
+
+// Idempotency-Key 的處理狀態
+const (
+	IdempotencyInProgress = "in_progress"
+	IdempotencyCompleted  = "completed"
+)
+
+// IdempotencyKey 記錄帶有 Idempotency-Key 的請求及其第一次的回應
+type IdempotencyKey struct {
+	ID           uuid.UUID  `gorm:"primaryKey;default:gen_random_uuid();not null" json:"id"`
+	UserEmail    string     `gorm:"not null;uniqueIndex:idx_idempotency_keys_user_key" json:"user_email"`
+	Key          string     `gorm:"not null;uniqueIndex:idx_idempotency_keys_user_key" json:"key"`
+	Fingerprint  string     `gorm:"not null" json:"fingerprint"` // method + path + body 的雜湊，用來偵測同一個 key 被用在不同請求
+	Status       string     `gorm:"not null" json:"status"`      // in_progress 或 completed
+	ResponseCode int        `json:"response_code"`
+	ContentType  string     `json:"content_type"`
+	ResponseBody []byte     `json:"-"`
+	ExpiresAt    time.Time  `gorm:"not null;index" json:"expires_at"`
+	CreatedAt    *time.Time `gorm:"default:now()" json:"created_at"`
+	UpdatedAt    *time.Time `gorm:"default:now()" json:"updated_at"`
+}
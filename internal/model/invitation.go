@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/google/uuid"
+)
+
+// 邀請的方向
+const (
+	InvitationSent     = "sent"     // 由連結的帳號送出
+	InvitationReceived = "received" // 連結的帳號收到
+)
+
+// 邀請的狀態
+const (
+	InvitationPending   = "pending"
+	InvitationAccepted  = "accepted"
+	InvitationDeclined  = "declined"
+	InvitationWithdrawn = "withdrawn"
+)
+
+// Invitation 是 LinkedIn 連結邀請在本地的紀錄，追蹤從送出 (或收到) 到回應的過程
+type Invitation struct {
+	ID               uuid.UUID `gorm:"primaryKey;default:gen_random_uuid();not null" json:"id"`
+	AccountID        string    `gorm:"not null;uniqueIndex:idx_invitations_account_unipile,priority:1;index:idx_invitations_account_provider,priority:1" json:"account_id"` // Unipile account_id
+	UnipileID        string    `gorm:"not null;uniqueIndex:idx_invitations_account_unipile,priority:2" json:"unipile_id"`                                                   // Unipile invitation id
+	Direction        string    `gorm:"not null" json:"direction"`                                                                                                           // sent 或 received
+	Status           string    `gorm:"not null;default:pending" json:"status"`                                                                                              // pending、accepted、declined 或 withdrawn
+	ProviderID       string    `gorm:"index:idx_invitations_account_provider,priority:2" json:"provider_id"`                                                                // 對方的 LinkedIn provider id
+	PublicIdentifier string    `json:"public_identifier"`
+	Name             string    `json:"name"`
+	Headline         string    `json:"headline"`
+	Message          string    `json:"message"`                 // 邀請附加的訊息
+	SharedSecret     string    `json:"-"`                       // 接受或拒絕收到的邀請時 Unipile 需要的值
+	SentByEmail      string    `json:"sent_by_email,omitempty"` // 透過 Chatsheet 送出時的使用者
+
+	InvitedAt   *time.Time `json:"invited_at"`
+	RespondedAt *time.Time `json:"responded_at"`
+
+	CreatedAt *time.Time `gorm:"default:now()" json:"created_at"`
+	UpdatedAt *time.Time `gorm:"default:now()" json:"updated_at"`
+}
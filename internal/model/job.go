@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/google/uuid"
+)
+
+// 背景工作的狀態
+const (
+	JobQueued    = "queued"  // 等待執行，失敗後等待重試時也是此狀態
+	JobRunning   = "running" // 已被 worker 取出，正在執行
+	JobSucceeded = "succeeded"
+	JobDead      = "dead" // 重試後仍然失敗或無法重試 (dead letter)，可以手動重新排入
+)
+
+// Job 是保存在資料庫中的背景工作，由 internal/jobs 的 worker 依佇列取出執行
+type Job struct {
+	ID      uuid.UUID `gorm:"primaryKey;default:gen_random_uuid();not null" json:"id"`
+	Queue   string    `gorm:"not null;index:idx_jobs_due,priority:1" json:"queue"`
+	Type    string    `gorm:"not null" json:"type"`              // 工作類型，決定由哪個 handler 執行
+	Payload string    `gorm:"type:text;not null" json:"payload"` // JSON
+	// UniqueKey 相同 key 的工作同時只會有一個在等待或執行中
+	UniqueKey   string     `gorm:"index:idx_jobs_unique_key,unique,where:unique_key <> '' AND (status = 'queued' OR status = 'running')" json:"unique_key,omitempty"`
+	Status      string     `gorm:"not null;default:queued;index:idx_jobs_due,priority:2" json:"status"`
+	RunAt       time.Time  `gorm:"not null;index:idx_jobs_due,priority:3" json:"run_at"` // 下一次執行的時間，執行中為租約到期時間
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`                   // 已經開始執行的次數
+	MaxAttempts int        `gorm:"not null" json:"max_attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at"`
+
+	CreatedAt *time.Time `gorm:"default:now()" json:"created_at"`
+	UpdatedAt *time.Time `gorm:"default:now()" json:"updated_at"`
+}
+
+// JobCount 是佇列中某個狀態的工作數量
+type JobCount struct {
+	Queue  string `json:"queue"`
+	Status string `json:"status"`
+	Count  int64  `json:"count"`
+}
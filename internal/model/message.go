@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/google/uuid"
+)
+
+// Message 是保存在本地的 LinkedIn 訊息
+// 由同步 worker 與 webhook 寫入；透過 Chatsheet 送出的訊息會立即寫入，即使 Unipile 尚未完成索引，使用者也能看到送出的紀錄
+type Message struct {
+	ID              uuid.UUID  `gorm:"primaryKey;default:gen_random_uuid();not null" json:"id"`
+	AccountID       string     `gorm:"not null;index" json:"account_id"`                                // Unipile account_id
+	ChatID          string     `gorm:"not null;index:idx_messages_chat_sent,priority:1" json:"chat_id"` // Unipile chat id
+	UnipileID       string     `gorm:"unique;not null" json:"unipile_id"`                               // Unipile message id
+	SenderID        string     `json:"sender_id"`                                                       // 寄件者的 provider id
+	SenderName      string     `json:"sender_name"`                                                     // 寄件者名稱，供全文搜尋使用
+	Text            string     `json:"text"`
+	IsSender        bool       `gorm:"not null" json:"is_sender"` // true 代表由連結的帳號送出
+	AttachmentNames StringList `json:"attachment_names"`          // 附件檔名
+	SentByEmail     string     `json:"sent_by_email,omitempty"`   // 透過 Chatsheet 送出時的使用者
+	SentAt          time.Time  `gorm:"not null;index:idx_messages_chat_sent,priority:2" json:"sent_at"`
+	CreatedAt       *time.Time `gorm:"default:now()" json:"created_at"`
+	UpdatedAt       *time.Time `gorm:"default:now()" json:"updated_at"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/google/uuid"
+)
+
+// OutboxEvent 是與資料變更在同一個交易中寫入的事件 (transactional outbox)
+// 由 internal/outbox 的 relay 取出並發布到 EventBus，發布成功後設定 PublishedAt
+type OutboxEvent struct {
+	ID      uuid.UUID `gorm:"primaryKey;default:gen_random_uuid();not null" json:"id"`
+	Topic   string    `gorm:"not null" json:"topic"`             // 事件類型，例如 account.connected
+	Key     string    `json:"key"`                               // 事件所屬的資料，例如 account_id
+	Payload string    `gorm:"type:text;not null" json:"payload"` // JSON
+	// AvailableAt 下一次發布的時間，relay 取出後為租約到期時間
+	AvailableAt time.Time  `gorm:"not null;index:idx_outbox_events_pending,where:published_at IS NULL" json:"available_at"`
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"` // 已經嘗試發布的次數
+	LastError   string     `json:"last_error,omitempty"`
+	PublishedAt *time.Time `gorm:"index" json:"published_at"`
+
+	CreatedAt *time.Time `gorm:"default:now()" json:"created_at"`
+}
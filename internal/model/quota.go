@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/google/uuid"
+)
+
+// 受配額限制的對外動作
+const (
+	ActionInvite  = "invite"  // 送出連結邀請
+	ActionMessage = "message" // 送出訊息或建立對話
+)
+
+// AccountLimits 是連結帳號的配額與工作時間設定，沒有設定時使用 config 的預設值
+// 配額為 0 代表不限制
+type AccountLimits struct {
+	ID            uuid.UUID  `gorm:"primaryKey;default:gen_random_uuid();not null" json:"-"`
+	AccountID     string     `gorm:"unique;not null" json:"account_id"` // Unipile account_id
+	Timezone      string     `gorm:"not null" json:"timezone"`          // IANA 時區，例如 Asia/Taipei
+	WorkStart     string     `gorm:"not null" json:"work_start"`        // 工作時間開始 (HH:MM，帳號時區)
+	WorkEnd       string     `gorm:"not null" json:"work_end"`          // 工作時間結束 (HH:MM，不包含)
+	WorkDays      StringList `json:"work_days"`                         // mon ~ sun，空陣列代表每天
+	InviteDaily   int        `gorm:"not null" json:"invite_daily"`
+	InviteWeekly  int        `gorm:"not null" json:"invite_weekly"`
+	MessageDaily  int        `gorm:"not null" json:"message_daily"`
+	MessageWeekly int        `gorm:"not null" json:"message_weekly"`
+	CreatedAt     *time.Time `gorm:"default:now()" json:"created_at,omitempty"`
+	UpdatedAt     *time.Time `gorm:"default:now()" json:"updated_at,omitempty"`
+}
+
+// ActionUsage 是一次消耗配額的對外動作，只能新增；動作失敗時刪除
+type ActionUsage struct {
+	ID        uuid.UUID `gorm:"primaryKey;default:gen_random_uuid();not null" json:"id"`
+	AccountID string    `gorm:"not null;index:idx_action_usages_account_action_created,priority:1" json:"account_id"`
+	Action    string    `gorm:"not null;index:idx_action_usages_account_action_created,priority:2" json:"action"`
+	CreatedAt time.Time `gorm:"not null;index:idx_action_usages_account_action_created,priority:3" json:"created_at"`
+}
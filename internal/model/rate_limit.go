@@ -0,0 +1,14 @@
+package model
+
+import (
+	"time"
+)
+
+// RateLimitBucket 是一個限流鍵 (例如 policy 與 IP) 的 token bucket 狀態
+// 補滿之後與不存在相同，ExpiresAt 之後可以刪除
+type RateLimitBucket struct {
+	Key       string    `gorm:"primaryKey;size:255" json:"key"`
+	Tokens    float64   `gorm:"not null" json:"tokens"`                          // UpdatedAt 時剩下的 token
+	UpdatedAt time.Time `gorm:"not null;autoUpdateTime:false" json:"updated_at"` // 最後一次取出 token 的時間，由 Take 設定
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+}
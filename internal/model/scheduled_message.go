@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/google/uuid"
+)
+
+// 排程訊息的狀態
+const (
+	ScheduledPending  = "scheduled" // 等待送出，失敗後等待重試時也是此狀態
+	ScheduledSending  = "sending"   // 已被 worker 取出，正在送出
+	ScheduledSent     = "sent"
+	ScheduledFailed   = "failed" // 重試後仍然失敗，修改後可以重新排程
+	ScheduledCanceled = "canceled"
+)
+
+// ScheduledMessage 是使用者預先寫好、在指定時間透過連結帳號送出的訊息
+// 送到既有的對話 (ChatID) 或以對方的 provider id 開始對話 (ProviderID)，兩者擇一
+type ScheduledMessage struct {
+	ID            uuid.UUID  `gorm:"primaryKey;default:gen_random_uuid();not null" json:"id"`
+	UserEmail     string     `gorm:"not null;index:idx_scheduled_messages_user_send,priority:1" json:"user_email"`
+	AccountID     string     `gorm:"not null" json:"account_id"`     // 送出訊息的 Unipile account_id
+	ChatID        string     `json:"chat_id,omitempty"`              // 送出的 Unipile chat id，以 ProviderID 送出後為新對話的 id
+	ProviderID    string     `json:"provider_id,omitempty"`          // 對方的 provider id
+	Text          string     `gorm:"type:text;not null" json:"text"` // 訊息內容
+	SendAt        time.Time  `gorm:"not null;index:idx_scheduled_messages_user_send,priority:2" json:"send_at"`
+	Timezone      string     `json:"timezone,omitempty"` // 建立時指定的對方時區，僅供顯示
+	Status        string     `gorm:"not null;default:scheduled;index:idx_scheduled_messages_due,priority:1" json:"status"`
+	NextAttemptAt *time.Time `gorm:"index:idx_scheduled_messages_due,priority:2" json:"next_attempt_at"` // 下一次送出的時間，送出中為租約到期時間，結束後為 nil
+	LeaseID       *uuid.UUID `gorm:"type:uuid" json:"-"`                                                 // 最近一次取出的租約，記錄送出結果時確認訊息仍由同一次取出持有
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`                                 // 失敗的次數
+	LastError     string     `json:"last_error,omitempty"`
+	MessageID     string     `json:"message_id,omitempty"` // 送出後的 Unipile message id
+	SentAt        *time.Time `json:"sent_at"`
+
+	CreatedAt *time.Time `gorm:"default:now()" json:"created_at"`
+	UpdatedAt *time.Time `gorm:"default:now()" json:"updated_at"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/google/uuid"
+)
+
+// SyncCheckpoint 記錄每個連結帳號的同步進度，重新啟動時從這裡繼續而不是重新開始
+type SyncCheckpoint struct {
+	ID        uuid.UUID `gorm:"primaryKey;default:gen_random_uuid();not null" json:"-"`
+	AccountID string    `gorm:"unique;not null" json:"account_id"` // Unipile account_id
+
+	// 對話列表回填 (backfill) 的進度
+	ChatsCursor     string `json:"-"`
+	ChatsBackfilled bool   `gorm:"not null;default:false" json:"chats_backfilled"`
+
+	// 上次增量同步開始的時間，下次只向 Unipile 要求此時間之後的對話與訊息
+	LastSyncedAt *time.Time `json:"last_synced_at"`
+	LastError    string     `json:"last_error,omitempty"`
+
+	CreatedAt *time.Time `gorm:"default:now()" json:"created_at"`
+	UpdatedAt *time.Time `gorm:"default:now()" json:"updated_at"`
+}
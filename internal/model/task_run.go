@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+)
+
+// 定期工作最後一次執行的結果
+const (
+	TaskRunning   = "running"
+	TaskSucceeded = "succeeded"
+	TaskFailed    = "failed"
+)
+
+// TaskRun 記錄每個定期工作 (internal/cron) 最後一次的執行
+// 只有取得 advisory lock 的程序會執行並寫入，ScheduledAt 讓其他程序略過同一次排程
+type TaskRun struct {
+	Name        string     `gorm:"primaryKey" json:"name"`
+	Schedule    string     `gorm:"not null" json:"schedule"`     // 執行時的 cron 運算式
+	ScheduledAt time.Time  `gorm:"not null" json:"scheduled_at"` // 這次執行對應的排程時間
+	StartedAt   time.Time  `gorm:"not null" json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	Status      string     `gorm:"not null" json:"status"` // running, succeeded 或 failed
+	Result      string     `json:"result,omitempty"`       // 工作回傳的摘要，例如刪除的筆數
+	Error       string     `json:"error,omitempty"`
+	DurationMs  int64      `json:"duration_ms"`
+	Host        string     `json:"host"` // 執行的程序所在的主機
+
+	UpdatedAt *time.Time `gorm:"default:now()" json:"updated_at"`
+}
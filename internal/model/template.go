@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/google/uuid"
+)
+
+// MessageTemplate 是使用者儲存的訊息範本，Body 使用 text/template 語法，例如 {{.FirstName}}
+// 可用的變數見 service.TemplateData
+type MessageTemplate struct {
+	ID        uuid.UUID  `gorm:"primaryKey;default:gen_random_uuid();not null" json:"id"`
+	UserEmail string     `gorm:"not null;uniqueIndex:idx_message_templates_user_name,priority:1" json:"user_email"`
+	Name      string     `gorm:"not null;uniqueIndex:idx_message_templates_user_name,priority:2" json:"name"`
+	Body      string     `gorm:"type:text;not null" json:"body"`
+	Variables StringList `json:"variables"` // Body 中使用的變數，例如 FirstName、Sender.Name
+	CreatedAt *time.Time `gorm:"default:now()" json:"created_at"`
+	UpdatedAt *time.Time `gorm:"default:now()" json:"updated_at"`
+}
@@ -8,10 +8,13 @@ import (
 
 // UnipileAccount 模型用於儲存連結的第三方帳號
 type UnipileAccount struct {
-	ID        uuid.UUID  `gorm:"primaryKey;default:gen_random_uuid();not null" json:"id"`
-	UserEmail string     `gorm:"not null" json:"user_email"`
-	Provider  string     `gorm:"not null" json:"provider"`          // 例如 "linkedin"
-	AccountID string     `gorm:"unique;not null" json:"account_id"` // Unipile 返回的 account_id
-	CreatedAt *time.Time `gorm:"default:now()" json:"created_at"`
-	UpdatedAt *time.Time `gorm:"default:now()" json:"updated_at"`
+	ID        uuid.UUID `gorm:"primaryKey;default:gen_random_uuid();not null" json:"id"`
+	UserEmail string    `gorm:"not null" json:"user_email"`
+	Provider  string    `gorm:"not null" json:"provider"`          // 例如 "linkedin"
+	AccountID string    `gorm:"unique;not null" json:"account_id"` // Unipile 返回的 account_id
+	// Status 是 Unipile 上帳號的狀態 (例如 OK 或需要重新登入的 CREDENTIALS)，由定期工作更新
+	Status          string     `json:"status,omitempty"`
+	StatusCheckedAt *time.Time `json:"status_checked_at"`
+	CreatedAt       *time.Time `gorm:"default:now()" json:"created_at"`
+	UpdatedAt       *time.Time `gorm:"default:now()" json:"updated_at"`
 }
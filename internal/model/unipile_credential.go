@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/google/uuid"
+)
+
+// UnipileCredential 儲存加密後的連線憑證，供背景工作重新連線帳號使用
+// 明文 (ConnectionSecret) 永遠不會寫入資料庫
+type UnipileCredential struct {
+	ID         uuid.UUID  `gorm:"primaryKey;default:gen_random_uuid();not null" json:"id"`
+	UserEmail  string     `gorm:"not null" json:"user_email"`
+	AccountID  string     `gorm:"unique;not null" json:"account_id"` // Unipile 返回的 account_id
+	KeyVersion int        `gorm:"not null;index" json:"key_version"` // 包裝資料金鑰的主金鑰版本
+	WrappedKey []byte     `gorm:"not null" json:"-"`                 // 被主金鑰包裝的資料金鑰
+	Ciphertext []byte     `gorm:"not null" json:"-"`                 // 加密後的 ConnectionSecret
+	CreatedAt  *time.Time `gorm:"default:now()" json:"created_at"`
+	UpdatedAt  *time.Time `gorm:"default:now()" json:"updated_at"`
+}
+
+// ConnectionSecret 是重新連線 LinkedIn 帳號所需的明文憑證
+type ConnectionSecret struct {
+	AccessToken string `json:"access_token"` // li_at cookie
+	UserAgent   string `json:"user_agent"`
+}
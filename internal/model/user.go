@@ -2,10 +2,17 @@ package model
 
 import "time"
 
+// 使用者角色
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 // User 模型用於應用程式使用者
 type User struct {
 	Email     string     `gorm:"primaryKey" json:"email"`
 	Password  string     `gorm:"password" json:"-"`
+	Role      string     `gorm:"not null;default:user" json:"role"` // user 或 admin
 	CreatedAt *time.Time `gorm:"default:now()" json:"created_at"`
 	UpdatedAt *time.Time `gorm:"default:now()" json:"updated_at"`
 }
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/google/uuid"
+)
+
+// 可訂閱的 webhook 事件類型
+const (
+	EventAccountConnected     = "account.connected"      // 連結或重新連結 LinkedIn 帳號
+	EventAccountDisconnected  = "account.disconnected"   // 移除帳號
+	EventAccountStatusChanged = "account.status_changed" // 帳號在 Unipile 上的狀態改變，例如需要重新登入
+	EventMessageReceived      = "message.received"       // 收到對方的訊息
+)
+
+// WebhookEventTypes 所有可訂閱的事件類型
+var WebhookEventTypes = []string{
+	EventAccountConnected,
+	EventAccountDisconnected,
+	EventAccountStatusChanged,
+	EventMessageReceived,
+}
+
+// webhook 送出的狀態
+const (
+	DeliveryPending   = "pending" // 等待送出，失敗後等待重試時也是此狀態
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed" // 重試後仍然失敗，可以手動重送
+)
+
+// WebhookEndpoint 是使用者設定的 webhook 接收網址，訂閱的事件會以 HMAC-SHA256 簽章後 POST 到 URL
+// 簽章密鑰以 envelope 加密保存，只在建立與輪替時回傳一次
+type WebhookEndpoint struct {
+	ID               uuid.UUID  `gorm:"primaryKey;default:gen_random_uuid();not null" json:"id"`
+	UserEmail        string     `gorm:"not null;index" json:"user_email"`
+	URL              string     `gorm:"not null" json:"url"`
+	Description      string     `json:"description"`
+	Events           StringList `gorm:"not null" json:"events"` // 訂閱的事件類型
+	Active           bool       `gorm:"not null" json:"active"`
+	KeyVersion       int        `gorm:"not null" json:"-"` // 包裝資料金鑰的主金鑰版本
+	WrappedKey       []byte     `gorm:"not null" json:"-"` // 被主金鑰包裝的資料金鑰
+	SecretCiphertext []byte     `gorm:"not null" json:"-"` // 加密後的簽章密鑰
+	CreatedAt        *time.Time `gorm:"default:now()" json:"created_at"`
+	UpdatedAt        *time.Time `gorm:"default:now()" json:"updated_at"`
+}
+
+// WebhookDelivery 是一次事件送出的紀錄，由背景工作送出並在失敗時重試
+// 手動重送會建立新的紀錄，EventID 與原本的紀錄相同
+type WebhookDelivery struct {
+	ID         uuid.UUID `gorm:"primaryKey;default:gen_random_uuid();not null" json:"id"`
+	EndpointID uuid.UUID `gorm:"not null;index:idx_webhook_deliveries_endpoint,priority:1;uniqueIndex:idx_webhook_deliveries_event,priority:1,where:redelivery_of IS NULL" json:"endpoint_id"`
+	// EventID 同一個事件只會送到同一個 endpoint 一次 (手動重送除外)
+	EventID        uuid.UUID  `gorm:"not null;uniqueIndex:idx_webhook_deliveries_event,priority:2" json:"event_id"`
+	EventType      string     `gorm:"not null" json:"event_type"`
+	Payload        string     `gorm:"type:text;not null" json:"payload"` // 送出的 JSON 內容
+	Status         string     `gorm:"not null;default:pending" json:"status"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	ResponseStatus int        `json:"response_status,omitempty"` // 最後一次嘗試的 HTTP 狀態碼，連線失敗時為 0
+	ResponseBody   string     `json:"response_body,omitempty"`   // 最後一次嘗試的回應內容 (截斷)
+	Error          string     `json:"error,omitempty"`
+	DurationMs     int64      `json:"duration_ms"`
+	RedeliveryOf   *uuid.UUID `json:"redelivery_of,omitempty"` // 手動重送時為原本的紀錄
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+
+	CreatedAt *time.Time `gorm:"default:now();index:idx_webhook_deliveries_endpoint,priority:2" json:"created_at"`
+	UpdatedAt *time.Time `gorm:"default:now()" json:"updated_at"`
+}
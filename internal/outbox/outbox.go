@@ -0,0 +1,175 @@
+// Package outbox 實作 transactional outbox。
+//
+// 事件以 Writer.Add 寫入 outbox_events，與資料變更在同一個交易 (itfc.Transactor) 中 commit 或 rollback；
+// Relay 再依建立順序取出尚未發布的事件，發布到 events.Bus 後標記為已發布。
+// 程序在 commit 之後、發布之前中斷時，事件會在下一次輪詢 (或租約到期後由其他程序) 發布，不會遺失；
+// 因此同一個事件可能發布不只一次 (at-least-once)，訂閱者必須以 events.Event.ID 去重或可以重複處理。
+//
+// 多個程序可以同時執行 Relay，ClaimPending 保證每個事件同時只會被其中一個發布；
+// 不同程序之間不保證發布的順序。
+package outbox
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/events"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Writer 寫入 outbox 事件
+type Writer struct {
+	outboxRepo itfc.OutboxRepository
+}
+
+func NewWriter(outboxRepo itfc.OutboxRepository) *Writer {
+	return &Writer{outboxRepo: outboxRepo}
+}
+
+// Add 寫入事件，payload 以 JSON 保存
+// 請在 Transactor.WithinTx 的 fn 中以傳入的 ctx 呼叫，讓事件與資料變更一起 commit
+func (w *Writer) Add(ctx context.Context, topic, key string, payload any) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("outbox: encode payload: %w", err)
+	}
+
+	return w.outboxRepo.Create(ctx, &model.OutboxEvent{
+		Topic:       topic,
+		Key:         key,
+		Payload:     string(raw),
+		AvailableAt: time.Now().UTC().Truncate(time.Microsecond),
+	})
+}
+
+// Relay 將 outbox 中尚未發布的事件發布到 Bus
+type Relay struct {
+	cfg        config.OutboxConfig
+	outboxRepo itfc.OutboxRepository
+	bus        events.Bus
+}
+
+func NewRelay(cfg config.OutboxConfig, outboxRepo itfc.OutboxRepository, bus events.Bus) *Relay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = time.Minute
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = 5 * time.Second
+	}
+	if cfg.MaxRetryDelay <= 0 {
+		cfg.MaxRetryDelay = 10 * time.Minute
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = 7 * 24 * time.Hour
+	}
+
+	return &Relay{
+		cfg:        cfg,
+		outboxRepo: outboxRepo,
+		bus:        bus,
+	}
+}
+
+// Run 定期發布尚未發布的事件，直到 ctx 結束
+// 發布中的事件在 ctx 結束後仍會完成並記錄結果，沒有取出的事件留給下一次啟動或其他程序
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// 一次取滿時代表可能還有更多事件，立即取下一批
+		for ctx.Err() == nil {
+			n, err := r.relayBatch(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.ErrorContext(ctx, "Failed to relay outbox events", "err", err)
+				}
+				break
+			}
+			if n < r.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge 刪除發布超過 outbox.retention 的事件，由定期工作執行
+func (r *Relay) Purge(ctx context.Context) (string, error) {
+	n, err := r.outboxRepo.DeletePublished(ctx, time.Now().Add(-r.cfg.Retention))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("deleted %d published events", n), nil
+}
+
+// relayBatch 取出一批到期的事件並依序發布，回傳取出的數量
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	pending, err := r.outboxRepo.ClaimPending(ctx, time.Now().UTC(), r.cfg.Lease, r.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	// 已經取出的事件即使 ctx 已結束也要發布並記錄，避免等到租約到期
+	ctx = context.WithoutCancel(ctx)
+	for _, e := range pending {
+		r.publish(ctx, e)
+	}
+	return len(pending), nil
+}
+
+// publish 發布一個事件並記錄結果，失敗時依次數延後重試
+func (r *Relay) publish(ctx context.Context, e model.OutboxEvent) {
+	ev := events.Event{
+		ID:      e.ID.String(),
+		Topic:   e.Topic,
+		Key:     e.Key,
+		Payload: json.RawMessage(e.Payload),
+	}
+	if e.CreatedAt != nil {
+		ev.CreatedAt = e.CreatedAt.UTC()
+	}
+
+	pubCtx, cancel := context.WithTimeout(ctx, r.cfg.Lease)
+	err := r.bus.Publish(pubCtx, ev)
+	cancel()
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	if err == nil {
+		if err := r.outboxRepo.MarkPublished(ctx, e.ID, now); err != nil {
+			// 事件會在租約到期後再發布一次
+			slog.ErrorContext(ctx, "Failed to mark outbox event published", "event_id", e.ID, "topic", e.Topic, "err", err)
+		}
+		return
+	}
+
+	retryAt := now.Add(r.retryDelay(e.Attempts))
+	slog.WarnContext(ctx, "Failed to publish outbox event, will retry", "event_id", e.ID, "topic", e.Topic, "attempts", e.Attempts, "retry_at", retryAt, "err", err)
+	if err := r.outboxRepo.Reschedule(ctx, e.ID, retryAt, err.Error()); err != nil {
+		slog.ErrorContext(ctx, "Failed to reschedule outbox event", "event_id", e.ID, "topic", e.Topic, "err", err)
+	}
+}
+
+// retryDelay 第 attempts 次失敗後的重試間隔，每次加倍直到 MaxRetryDelay
+func (r *Relay) retryDelay(attempts int) time.Duration {
+	delay := r.cfg.RetryDelay
+	for i := 1; i < attempts && delay < r.cfg.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, r.cfg.MaxRetryDelay)
+}
@@ -0,0 +1,157 @@
+package outbox
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/events"
+	"chatsheet/internal/repository/memory"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRelayPublishesInOrder(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewOutboxRepository()
+	bus := events.NewLocalBus()
+	w := NewWriter(repo)
+
+	var got []events.Event
+	bus.Subscribe("account.>", func(ctx context.Context, ev events.Event) error {
+		got = append(got, ev)
+		return nil
+	})
+
+	for _, key := range []string{"acc-1", "acc-2", "acc-3"} {
+		if err := w.Add(ctx, "account.connected", key, map[string]string{"account_id": key}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	r := NewRelay(config.OutboxConfig{BatchSize: 2}, repo, bus)
+	if n, err := r.relayBatch(ctx); err != nil || n != 2 {
+		t.Fatalf("relayBatch = %d, %v; want 2", n, err)
+	}
+	if n, err := r.relayBatch(ctx); err != nil || n != 1 {
+		t.Fatalf("relayBatch = %d, %v; want 1", n, err)
+	}
+	if n, err := r.relayBatch(ctx); err != nil || n != 0 {
+		t.Fatalf("relayBatch after publishing everything = %d, %v; want 0", n, err)
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("published %d events, want 3", len(got))
+	}
+	for i, key := range []string{"acc-1", "acc-2", "acc-3"} {
+		ev := got[i]
+		if ev.Key != key || ev.Topic != "account.connected" || string(ev.Payload) != `{"account_id":"`+key+`"}` || ev.ID == "" || ev.CreatedAt.IsZero() {
+			t.Errorf("event %d = %+v", i, ev)
+		}
+	}
+}
+
+func TestRelayReschedulesFailedPublish(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewOutboxRepository()
+	bus := events.NewLocalBus()
+
+	fail := true
+	var published []string
+	bus.Subscribe("account.connected", func(ctx context.Context, ev events.Event) error {
+		if fail {
+			return errors.New("subscriber down")
+		}
+		published = append(published, ev.ID)
+		return nil
+	})
+	if err := NewWriter(repo).Add(ctx, "account.connected", "acc-1", nil); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	r := NewRelay(config.OutboxConfig{RetryDelay: time.Hour, MaxRetryDelay: 2 * time.Hour}, repo, bus)
+	start := time.Now()
+	if _, err := r.relayBatch(ctx); err != nil {
+		t.Fatalf("relayBatch: %v", err)
+	}
+
+	// 延後 RetryDelay 後才會再取出
+	if pending, _ := repo.ClaimPending(ctx, start.Add(59*time.Minute), time.Minute, 10); len(pending) != 0 {
+		t.Fatalf("event claimable before the retry delay: %+v", pending)
+	}
+	pending, err := repo.ClaimPending(ctx, start.Add(61*time.Minute), time.Minute, 10)
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("ClaimPending after the retry delay = %d, %v; want 1", len(pending), err)
+	}
+	if e := pending[0]; e.Attempts != 2 || e.LastError == "" {
+		t.Errorf("rescheduled event attempts=%d last_error=%q, want 2 and an error", e.Attempts, e.LastError)
+	}
+
+	fail = false
+	r.publish(ctx, pending[0])
+	if len(published) != 1 || published[0] != pending[0].ID.String() {
+		t.Errorf("published = %v, want [%s]", published, pending[0].ID)
+	}
+	if pending, _ := repo.ClaimPending(ctx, start.Add(24*time.Hour), time.Minute, 10); len(pending) != 0 {
+		t.Errorf("published event is still pending: %+v", pending)
+	}
+}
+
+func TestRelayRetryDelay(t *testing.T) {
+	r := NewRelay(config.OutboxConfig{RetryDelay: time.Second, MaxRetryDelay: 10 * time.Second}, memory.NewOutboxRepository(), events.NewLocalBus())
+
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 100: 10 * time.Second} {
+		if got := r.retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestRelayPurge(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewOutboxRepository()
+	bus := events.NewLocalBus()
+	NewWriter(repo).Add(ctx, "account.connected", "acc-1", nil)
+
+	r := NewRelay(config.OutboxConfig{Retention: time.Hour}, repo, bus)
+	r.relayBatch(ctx)
+	if msg, err := r.Purge(ctx); err != nil || msg != "deleted 0 published events" {
+		t.Errorf("Purge = %q, %v; want nothing deleted within retention", msg, err)
+	}
+
+	r = NewRelay(config.OutboxConfig{Retention: time.Nanosecond}, repo, bus)
+	time.Sleep(time.Millisecond)
+	if msg, err := r.Purge(ctx); err != nil || msg != "deleted 1 published events" {
+		t.Errorf("Purge = %q, %v; want 1 deleted", msg, err)
+	}
+}
+
+func TestRelayRunStopsWithContext(t *testing.T) {
+	repo := memory.NewOutboxRepository()
+	bus := events.NewLocalBus()
+
+	received := make(chan events.Event, 1)
+	bus.Subscribe("account.connected", func(ctx context.Context, ev events.Event) error {
+		received <- ev
+		return nil
+	})
+	NewWriter(repo).Add(context.Background(), "account.connected", "acc-1", nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewRelay(config.OutboxConfig{PollInterval: 10 * time.Millisecond}, repo, bus).Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not publish the pending event")
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the context was cancelled")
+	}
+}
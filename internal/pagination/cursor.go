@@ -0,0 +1,110 @@
+// Package pagination 提供以 (時間, ID) 為鍵的 keyset 分頁游標。
+//
+// 游標對前端而言是不透明的字串，內容為 base64 編碼的 "RFC3339Nano|id"；
+// 依分數排序的結果則使用 RankedCursor。
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultLimit 與 MaxLimit 是列表 API 的預設與最大筆數
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// ErrInvalidCursor 游標格式錯誤
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor 指向上一頁最後一筆資料，下一頁從它之後開始
+type Cursor struct {
+	Time time.Time
+	ID   string
+}
+
+// Encode 將游標編碼為字串
+func (c Cursor) Encode() string {
+	raw := c.Time.UTC().Format(time.RFC3339Nano) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// Decode 解析游標字串，空字串代表第一頁並回傳 nil
+func Decode(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{Time: t, ID: id}, nil
+}
+
+// Limit 將使用者傳入的筆數限制在 1 ~ MaxLimit，0 則使用預設值
+func Limit(n int) int {
+	switch {
+	case n <= 0:
+		return DefaultLimit
+	case n > MaxLimit:
+		return MaxLimit
+	default:
+		return n
+	}
+}
+
+// RankedCursor 用於依分數排序的結果 (例如搜尋)，以 (分數, 時間, ID) 為鍵
+type RankedCursor struct {
+	Rank float64
+	Time time.Time
+	ID   string
+}
+
+// Encode 將游標編碼為字串，內容為 base64 編碼的 "rank|RFC3339Nano|id"
+func (c RankedCursor) Encode() string {
+	raw := strconv.FormatFloat(c.Rank, 'g', -1, 64) + "|" + Cursor{Time: c.Time, ID: c.ID}.Encode()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeRanked 解析 RankedCursor 字串，空字串代表第一頁並回傳 nil
+func DecodeRanked(s string) (*RankedCursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	rank, rest, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	r, err := strconv.ParseFloat(rank, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c, err := Decode(rest)
+	if err != nil || c == nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &RankedCursor{Rank: r, Time: c.Time, ID: c.ID}, nil
+}
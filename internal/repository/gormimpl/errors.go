@@ -0,0 +1,26 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/apperr"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// translateError 將 gorm 的錯誤轉換為 apperr 定義的錯誤
+// 需要在 gorm.Config 開啟 TranslateError，gorm 才會回傳 ErrDuplicatedKey 與 ErrForeignKeyViolated
+func translateError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return apperr.ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return apperr.ErrConflict
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		// 參照的資料 (例如 unipile_accounts.user_email 對應的使用者) 不存在
+		return apperr.ErrNotFound
+	default:
+		return err
+	}
+}
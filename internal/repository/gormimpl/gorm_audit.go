@@ -0,0 +1,53 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"log/slog"
+
+	"gorm.io/gorm"
+)
+
+type gormAuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) itfc.AuditRepository {
+	return &gormAuditRepository{db: db}
+}
+
+func (r *gormAuditRepository) Append(ctx context.Context, event *model.AuditEvent) error {
+	err := r.db.WithContext(ctx).
+		Create(&event).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to append AuditEvent", "error", err)
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *gormAuditRepository) List(ctx context.Context, filter itfc.AuditFilter) ([]model.AuditEvent, error) {
+	query := r.db.WithContext(ctx).Model(&model.AuditEvent{})
+	if filter.ActorEmail != "" {
+		query = query.Where("actor_email = ?", filter.ActorEmail)
+	}
+	if filter.After != nil {
+		query = query.Where("(created_at, id) < (?, ?)", filter.After.Time, filter.After.ID)
+	}
+
+	var events []model.AuditEvent
+	err := query.
+		Order("created_at DESC, id DESC").
+		Limit(filter.Limit).
+		Find(&events).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list AuditEvent", "error", err)
+		return nil, translateError(err)
+	}
+
+	return events, nil
+}
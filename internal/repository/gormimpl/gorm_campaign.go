@@ -0,0 +1,80 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type gormCampaignRepository struct {
+	db *gorm.DB
+}
+
+func NewCampaignRepository(db *gorm.DB) itfc.CampaignRepository {
+	return &gormCampaignRepository{db: db}
+}
+
+func (r *gormCampaignRepository) Create(ctx context.Context, campaign *model.Campaign) error {
+	err := r.db.WithContext(ctx).
+		Create(&campaign).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create Campaign", "error", err)
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *gormCampaignRepository) Get(ctx context.Context, id uuid.UUID) (*model.Campaign, error) {
+	var campaign *model.Campaign
+	err := r.db.WithContext(ctx).
+		Where("id = ?", id).
+		First(&campaign).
+		Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return campaign, nil
+}
+
+func (r *gormCampaignRepository) ListByUser(ctx context.Context, email string) ([]model.Campaign, error) {
+	var campaigns []model.Campaign
+	err := r.db.WithContext(ctx).
+		Where("user_email = ?", email).
+		Order("created_at DESC, id DESC").
+		Find(&campaigns).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list Campaign by user", "error", err)
+		return nil, translateError(err)
+	}
+
+	return campaigns, nil
+}
+
+func (r *gormCampaignRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status string) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.Campaign{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"status":     status,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to update Campaign status", "error", result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"context"
+	"log/slog"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormChatRepository struct {
+	db *gorm.DB
+}
+
+func NewChatRepository(db *gorm.DB) itfc.ChatRepository {
+	return &gormChatRepository{db: db}
+}
+
+func (r *gormChatRepository) Upsert(ctx context.Context, chat *model.Chat) error {
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "unipile_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"provider_id", "attendee_provider_id", "name", "type",
+				"unread_count", "archived", "read_only", "last_message_at", "updated_at",
+			}),
+		}).
+		Clauses(clause.Returning{}). // 衝突時取回既有資料列的 id 與回填進度
+		Create(&chat).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to upsert Chat", "error", err)
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *gormChatRepository) GetByUnipileID(ctx context.Context, unipileID string) (*model.Chat, error) {
+	var chat *model.Chat
+	err := r.db.WithContext(ctx).
+		Where("unipile_id = ?", unipileID).
+		First(&chat).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get Chat by unipile_id", "error", err)
+		return nil, translateError(err)
+	}
+
+	return chat, nil
+}
+
+func (r *gormChatRepository) ListByAccount(ctx context.Context, accountID string, after *pagination.Cursor, limit int) ([]model.Chat, error) {
+	query := r.db.WithContext(ctx).Where("account_id = ?", accountID)
+	if after != nil {
+		query = query.Where("(last_message_at, id) < (?, ?)", after.Time, after.ID)
+	}
+
+	var chats []model.Chat
+	err := query.
+		Order("last_message_at DESC, id DESC").
+		Limit(limit).
+		Find(&chats).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list Chat by account", "error", err)
+		return nil, translateError(err)
+	}
+
+	return chats, nil
+}
+
+func (r *gormChatRepository) ListNotBackfilled(ctx context.Context, accountID string, limit int) ([]model.Chat, error) {
+	var chats []model.Chat
+	err := r.db.WithContext(ctx).
+		Where("account_id = ? AND messages_backfilled = ?", accountID, false).
+		Order("last_message_at DESC, id DESC").
+		Limit(limit).
+		Find(&chats).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list not backfilled Chat", "error", err)
+		return nil, translateError(err)
+	}
+
+	return chats, nil
+}
+
+func (r *gormChatRepository) UpdateMessagesCheckpoint(ctx context.Context, unipileID, cursor string, backfilled bool) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.Chat{}).
+		Where("unipile_id = ?", unipileID).
+		Updates(map[string]any{"messages_cursor": cursor, "messages_backfilled": backfilled})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to update Chat messages checkpoint", "error", result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"log/slog"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormChatAttendeeRepository struct {
+	db *gorm.DB
+}
+
+func NewChatAttendeeRepository(db *gorm.DB) itfc.ChatAttendeeRepository {
+	return &gormChatAttendeeRepository{db: db}
+}
+
+func (r *gormChatAttendeeRepository) Upsert(ctx context.Context, attendee *model.ChatAttendee) error {
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "chat_id"}, {Name: "provider_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"unipile_id", "name", "is_self", "profile_url", "picture_url", "updated_at"}),
+		}).
+		Create(&attendee).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to upsert ChatAttendee", "error", err)
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *gormChatAttendeeRepository) ListByChats(ctx context.Context, chatIDs []string) ([]model.ChatAttendee, error) {
+	var attendees []model.ChatAttendee
+	if len(chatIDs) == 0 {
+		return attendees, nil
+	}
+
+	err := r.db.WithContext(ctx).
+		Where("chat_id IN ?", chatIDs).
+		Order("chat_id, created_at, id").
+		Find(&attendees).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list ChatAttendee by chats", "error", err)
+		return nil, translateError(err)
+	}
+
+	return attendees, nil
+}
@@ -0,0 +1,68 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"log/slog"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormContactRepository struct {
+	db *gorm.DB
+}
+
+func NewContactRepository(db *gorm.DB) itfc.ContactRepository {
+	return &gormContactRepository{db: db}
+}
+
+func (r *gormContactRepository) Upsert(ctx context.Context, contact *model.Contact) error {
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "account_id"}, {Name: "provider_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"public_identifier", "name", "first_name", "last_name", "headline", "company", "location", "profile_url", "picture_url", "fetched_at", "updated_at"}),
+		}).
+		Clauses(clause.Returning{}).
+		Create(&contact).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to upsert Contact", "error", err)
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *gormContactRepository) GetByIdentifier(ctx context.Context, accountID, identifier string) (*model.Contact, error) {
+	var contact *model.Contact
+	err := r.db.WithContext(ctx).
+		Where("account_id = ? AND (provider_id = ? OR public_identifier = ?)", accountID, identifier, identifier).
+		First(&contact).
+		Error
+	if err != nil {
+		// 尚未快取是正常情況，不記錄錯誤
+		return nil, translateError(err)
+	}
+
+	return contact, nil
+}
+
+func (r *gormContactRepository) ListByProviderIDs(ctx context.Context, accountID string, providerIDs []string) ([]model.Contact, error) {
+	var contacts []model.Contact
+	if len(providerIDs) == 0 {
+		return contacts, nil
+	}
+
+	err := r.db.WithContext(ctx).
+		Where("account_id = ? AND provider_id IN ?", accountID, providerIDs).
+		Find(&contacts).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list Contact by provider ids", "error", err)
+		return nil, translateError(err)
+	}
+
+	return contacts, nil
+}
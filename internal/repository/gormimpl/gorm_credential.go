@@ -0,0 +1,108 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormCredentialRepository struct {
+	db *gorm.DB
+}
+
+func NewCredentialRepository(db *gorm.DB) itfc.CredentialRepository {
+	return &gormCredentialRepository{db: db}
+}
+
+func (r *gormCredentialRepository) Upsert(ctx context.Context, cred *model.UnipileCredential) (*model.UnipileCredential, error) {
+	// 只覆寫同一個使用者的憑證，屬於其他使用者時不會影響任何資料列
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "account_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"key_version", "wrapped_key", "ciphertext", "updated_at"}),
+			Where: clause.Where{Exprs: []clause.Expression{
+				clause.Expr{SQL: "unipile_credentials.user_email = excluded.user_email"},
+			}},
+		}).
+		Clauses(clause.Returning{}). // 衝突時取回既有資料列的 id 與 created_at
+		Create(&cred)
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to upsert UnipileCredential", "error", result.Error)
+		return nil, translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, apperr.ErrConflict
+	}
+
+	return cred, nil
+}
+
+func (r *gormCredentialRepository) GetByAccountID(ctx context.Context, accountID string) (*model.UnipileCredential, error) {
+	var cred *model.UnipileCredential
+	err := r.db.WithContext(ctx).
+		Where("account_id = ?", accountID).
+		First(&cred).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get UnipileCredential by account_id", "error", err)
+		return nil, translateError(err)
+	}
+
+	return cred, nil
+}
+
+func (r *gormCredentialRepository) ListByKeyVersionNot(ctx context.Context, version, limit int) ([]model.UnipileCredential, error) {
+	var creds []model.UnipileCredential
+	err := r.db.WithContext(ctx).
+		Where("key_version <> ?", version).
+		Order("id").
+		Limit(limit).
+		Find(&creds).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list UnipileCredential by key version", "error", err)
+		return nil, translateError(err)
+	}
+
+	return creds, nil
+}
+
+func (r *gormCredentialRepository) UpdateWrappedKey(ctx context.Context, id uuid.UUID, version int, wrappedKey []byte) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.UnipileCredential{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"key_version": version,
+			"wrapped_key": wrappedKey,
+			"updated_at":  time.Now(),
+		})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to update UnipileCredential wrapped key", "error", result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *gormCredentialRepository) DeleteByAccountID(ctx context.Context, accountID string) error {
+	err := r.db.WithContext(ctx).
+		Where("account_id = ?", accountID).
+		Delete(&model.UnipileCredential{}).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to delete UnipileCredential", "error", err)
+		return translateError(err)
+	}
+
+	return nil
+}
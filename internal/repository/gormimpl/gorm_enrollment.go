@@ -0,0 +1,191 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormEnrollmentRepository struct {
+	db *gorm.DB
+}
+
+func NewEnrollmentRepository(db *gorm.DB) itfc.EnrollmentRepository {
+	return &gormEnrollmentRepository{db: db}
+}
+
+func (r *gormEnrollmentRepository) Create(ctx context.Context, e *model.Enrollment, ev *model.EnrollmentEvent) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&e).Error; err != nil {
+			return err
+		}
+		ev.EnrollmentID = e.ID
+		return tx.Create(&ev).Error
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create Enrollment", "error", err)
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *gormEnrollmentRepository) Get(ctx context.Context, id uuid.UUID) (*model.Enrollment, error) {
+	var e *model.Enrollment
+	err := r.db.WithContext(ctx).
+		Where("id = ?", id).
+		First(&e).
+		Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return e, nil
+}
+
+func (r *gormEnrollmentRepository) ListByCampaign(ctx context.Context, campaignID uuid.UUID, after *pagination.Cursor, limit int) ([]model.Enrollment, error) {
+	query := r.db.WithContext(ctx).Where("campaign_id = ?", campaignID)
+	if after != nil {
+		query = query.Where("(created_at, id) < (?, ?)", after.Time, after.ID)
+	}
+
+	var enrollments []model.Enrollment
+	err := query.
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&enrollments).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list Enrollment by campaign", "error", err)
+		return nil, translateError(err)
+	}
+
+	return enrollments, nil
+}
+
+func (r *gormEnrollmentRepository) ListActiveByProspect(ctx context.Context, accountID, providerID string) ([]model.Enrollment, error) {
+	var enrollments []model.Enrollment
+	err := r.db.WithContext(ctx).
+		Where("account_id = ? AND provider_id = ? AND status = ?", accountID, providerID, model.EnrollmentActive).
+		Find(&enrollments).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list Enrollment by prospect", "error", err)
+		return nil, translateError(err)
+	}
+
+	return enrollments, nil
+}
+
+func (r *gormEnrollmentRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.Enrollment, error) {
+	var enrollments []model.Enrollment
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// SKIP LOCKED 讓其他程序略過已被取出的報名，而不是等待交易結束
+		err := tx.
+			Select("enrollments.*").
+			Joins("JOIN campaigns ON campaigns.id = enrollments.campaign_id").
+			Where("enrollments.status = ? AND enrollments.next_run_at <= ? AND campaigns.status = ?", model.EnrollmentActive, now, model.CampaignActive).
+			Order("enrollments.next_run_at").
+			Limit(limit).
+			Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "enrollments"}, Options: "SKIP LOCKED"}).
+			Find(&enrollments).
+			Error
+		if err != nil || len(enrollments) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(enrollments))
+		for i, e := range enrollments {
+			ids[i] = e.ID
+		}
+		leaseUntil, leaseID := now.Add(lease), uuid.New()
+		err = tx.
+			Model(&model.Enrollment{}).
+			Where("id IN ?", ids).
+			Updates(map[string]any{
+				"next_run_at": leaseUntil,
+				"lease_id":    leaseID,
+				"updated_at":  time.Now(),
+			}).
+			Error
+		if err != nil {
+			return err
+		}
+		for i := range enrollments {
+			enrollments[i].NextRunAt = &leaseUntil
+			enrollments[i].LeaseID = &leaseID
+		}
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to claim due Enrollment", "error", err)
+		return nil, translateError(err)
+	}
+
+	return enrollments, nil
+}
+
+func (r *gormEnrollmentRepository) Transition(ctx context.Context, e *model.Enrollment, fromStatus string, ev *model.EnrollmentEvent) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.
+			Model(&model.Enrollment{}).
+			Where("id = ? AND status = ? AND lease_id IS NOT DISTINCT FROM ?", e.ID, fromStatus, e.LeaseID).
+			Updates(map[string]any{
+				"status":       e.Status,
+				"current_step": e.CurrentStep,
+				"next_run_at":  e.NextRunAt,
+				"chat_id":      e.ChatID,
+				"attempts":     e.Attempts,
+				"last_error":   e.LastError,
+				"updated_at":   time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apperr.ErrConflict
+		}
+
+		ev.EnrollmentID = e.ID
+		return tx.Create(&ev).Error
+	})
+	if errors.Is(err, apperr.ErrConflict) {
+		// 報名已經被其他程序改變狀態 (例如對方剛好回覆) 或重新取出，由呼叫者決定如何處理
+		return err
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to transition Enrollment", "error", err)
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *gormEnrollmentRepository) ListEvents(ctx context.Context, campaignID uuid.UUID, after *pagination.Cursor, limit int) ([]model.EnrollmentEvent, error) {
+	query := r.db.WithContext(ctx).Where("campaign_id = ?", campaignID)
+	if after != nil {
+		query = query.Where("(created_at, id) < (?, ?)", after.Time, after.ID)
+	}
+
+	var events []model.EnrollmentEvent
+	err := query.
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&events).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list EnrollmentEvent", "error", err)
+		return nil, translateError(err)
+	}
+
+	return events, nil
+}
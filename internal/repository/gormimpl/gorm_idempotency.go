@@ -0,0 +1,97 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type gormIdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) itfc.IdempotencyRepository {
+	return &gormIdempotencyRepository{db: db}
+}
+
+func (r *gormIdempotencyRepository) Create(ctx context.Context, rec *model.IdempotencyKey) (*model.IdempotencyKey, error) {
+	err := r.db.WithContext(ctx).
+		Create(&rec).
+		Error
+	if err != nil {
+		// 重複的 key 是預期中的情況，不需要記錄錯誤
+		err = translateError(err)
+		if err != apperr.ErrConflict {
+			slog.ErrorContext(ctx, "Failed to create IdempotencyKey", "error", err)
+		}
+		return nil, err
+	}
+
+	return rec, nil
+}
+
+func (r *gormIdempotencyRepository) Get(ctx context.Context, email, key string) (*model.IdempotencyKey, error) {
+	var rec *model.IdempotencyKey
+	err := r.db.WithContext(ctx).
+		Where("user_email = ? AND key = ?", email, key).
+		First(&rec).
+		Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return rec, nil
+}
+
+func (r *gormIdempotencyRepository) Complete(ctx context.Context, id uuid.UUID, code int, contentType string, body []byte) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.IdempotencyKey{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"status":        model.IdempotencyCompleted,
+			"response_code": code,
+			"content_type":  contentType,
+			"response_body": body,
+			"updated_at":    time.Now(),
+		})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to complete IdempotencyKey", "error", result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *gormIdempotencyRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	err := r.db.WithContext(ctx).
+		Where("id = ?", id).
+		Delete(&model.IdempotencyKey{}).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to delete IdempotencyKey", "error", err)
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *gormIdempotencyRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("expires_at < ?", before).
+		Delete(&model.IdempotencyKey{})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to delete expired IdempotencyKey", "error", result.Error)
+		return 0, translateError(result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,96 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormInvitationRepository struct {
+	db *gorm.DB
+}
+
+func NewInvitationRepository(db *gorm.DB) itfc.InvitationRepository {
+	return &gormInvitationRepository{db: db}
+}
+
+func (r *gormInvitationRepository) Upsert(ctx context.Context, inv *model.Invitation) error {
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "account_id"}, {Name: "unipile_id"}},
+			DoUpdates: append(
+				clause.AssignmentColumns([]string{"provider_id", "public_identifier", "name", "headline", "updated_at"}),
+				// Unipile 的列表不一定有這些值，沒有時保留原本的紀錄
+				clause.Assignment{Column: clause.Column{Name: "message"}, Value: gorm.Expr("COALESCE(NULLIF(excluded.message, ''), invitations.message)")},
+				clause.Assignment{Column: clause.Column{Name: "shared_secret"}, Value: gorm.Expr("COALESCE(NULLIF(excluded.shared_secret, ''), invitations.shared_secret)")},
+				clause.Assignment{Column: clause.Column{Name: "invited_at"}, Value: gorm.Expr("COALESCE(excluded.invited_at, invitations.invited_at)")},
+			),
+		}).
+		Clauses(clause.Returning{}).
+		Create(&inv).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to upsert Invitation", "error", err)
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *gormInvitationRepository) GetByUnipileID(ctx context.Context, accountID, unipileID string) (*model.Invitation, error) {
+	var inv *model.Invitation
+	err := r.db.WithContext(ctx).
+		Where("account_id = ? AND unipile_id = ?", accountID, unipileID).
+		First(&inv).
+		Error
+	if err != nil {
+		// 不是透過 Chatsheet 送出或列出過的邀請，不記錄錯誤
+		return nil, translateError(err)
+	}
+
+	return inv, nil
+}
+
+func (r *gormInvitationRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status string, respondedAt time.Time) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.Invitation{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"status":       status,
+			"responded_at": respondedAt,
+			"updated_at":   time.Now(),
+		})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to update Invitation status", "error", result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *gormInvitationRepository) MarkAccepted(ctx context.Context, accountID, providerID string, respondedAt time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.Invitation{}).
+		Where("account_id = ? AND provider_id = ? AND status = ?", accountID, providerID, model.InvitationPending).
+		Updates(map[string]any{
+			"status":       model.InvitationAccepted,
+			"responded_at": respondedAt,
+			"updated_at":   time.Now(),
+		})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to mark Invitation accepted", "error", result.Error)
+		return 0, translateError(result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,202 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormJobRepository struct {
+	db *gorm.DB
+}
+
+func NewJobRepository(db *gorm.DB) itfc.JobRepository {
+	return &gormJobRepository{db: db}
+}
+
+func (r *gormJobRepository) Create(ctx context.Context, j *model.Job) error {
+	err := r.db.WithContext(ctx).
+		Create(&j).
+		Error
+	if err = translateError(err); err != nil {
+		// 重複的 unique key 是預期的情況，由呼叫者處理
+		if !errors.Is(err, apperr.ErrConflict) {
+			slog.ErrorContext(ctx, "Failed to create Job", "error", err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (r *gormJobRepository) Get(ctx context.Context, id uuid.UUID) (*model.Job, error) {
+	var j *model.Job
+	err := r.db.WithContext(ctx).
+		Where("id = ?", id).
+		First(&j).
+		Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return j, nil
+}
+
+func (r *gormJobRepository) List(ctx context.Context, queue, status string, after *pagination.Cursor, limit int) ([]model.Job, error) {
+	query := r.db.WithContext(ctx)
+	if queue != "" {
+		query = query.Where("queue = ?", queue)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if after != nil {
+		query = query.Where("(created_at, id) < (?, ?)", after.Time, after.ID)
+	}
+
+	var jobs []model.Job
+	err := query.
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&jobs).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list Job", "error", err)
+		return nil, translateError(err)
+	}
+
+	return jobs, nil
+}
+
+func (r *gormJobRepository) ClaimDue(ctx context.Context, queue string, now time.Time, lease time.Duration, limit int) ([]model.Job, error) {
+	var jobs []model.Job
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// SKIP LOCKED 讓其他程序略過已被取出的工作，而不是等待交易結束
+		err := tx.
+			Where("queue = ? AND status IN ? AND run_at <= ?", queue, []string{model.JobQueued, model.JobRunning}, now).
+			Order("run_at").
+			Limit(limit).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Find(&jobs).
+			Error
+		if err != nil || len(jobs) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(jobs))
+		for i, j := range jobs {
+			ids[i] = j.ID
+		}
+		leaseUntil := now.Add(lease)
+		err = tx.
+			Model(&model.Job{}).
+			Where("id IN ?", ids).
+			Updates(map[string]any{
+				"status":     model.JobRunning,
+				"run_at":     leaseUntil,
+				"attempts":   gorm.Expr("attempts + 1"),
+				"updated_at": time.Now(),
+			}).
+			Error
+		if err != nil {
+			return err
+		}
+		for i := range jobs {
+			jobs[i].Status = model.JobRunning
+			jobs[i].RunAt = leaseUntil
+			jobs[i].Attempts++
+		}
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to claim due Job", "error", err)
+		return nil, translateError(err)
+	}
+
+	return jobs, nil
+}
+
+func (r *gormJobRepository) Finish(ctx context.Context, j *model.Job) error {
+	now := time.Now()
+	result := r.db.WithContext(ctx).
+		Model(&model.Job{}).
+		Where("id = ? AND status = ? AND attempts = ?", j.ID, model.JobRunning, j.Attempts).
+		Updates(map[string]any{
+			"status":      j.Status,
+			"run_at":      j.RunAt,
+			"last_error":  j.LastError,
+			"finished_at": j.FinishedAt,
+			"updated_at":  now,
+		})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to finish Job", "error", result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperr.ErrConflict
+	}
+
+	j.UpdatedAt = &now
+	return nil
+}
+
+func (r *gormJobRepository) Retry(ctx context.Context, id uuid.UUID, runAt time.Time) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.Job{}).
+		Where("id = ? AND status = ?", id, model.JobDead).
+		Updates(map[string]any{
+			"status":      model.JobQueued,
+			"run_at":      runAt,
+			"attempts":    0,
+			"finished_at": nil,
+			"updated_at":  time.Now(),
+		})
+	if result.Error != nil {
+		// unique key 與等待或執行中的工作重複時為 apperr.ErrConflict
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperr.ErrConflict
+	}
+
+	return nil
+}
+
+func (r *gormJobRepository) DeleteFinished(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("status IN ? AND finished_at < ?", []string{model.JobSucceeded, model.JobDead}, before).
+		Delete(&model.Job{})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to delete finished Job", "error", result.Error)
+		return 0, translateError(result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
+
+func (r *gormJobRepository) CountByStatus(ctx context.Context, statuses []string) ([]model.JobCount, error) {
+	var counts []model.JobCount
+	err := r.db.WithContext(ctx).
+		Model(&model.Job{}).
+		Select("queue, status, COUNT(*) AS count").
+		Where("status IN ?", statuses).
+		Group("queue, status").
+		Order("queue, status").
+		Scan(&counts).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to count Job by status", "error", err)
+		return nil, translateError(err)
+	}
+
+	return counts, nil
+}
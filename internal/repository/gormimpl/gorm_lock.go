@@ -0,0 +1,63 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/itfc"
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type gormAdvisoryLocker struct {
+	db *gorm.DB
+}
+
+// NewAdvisoryLocker 建立以 Postgres session advisory lock 實作的 Locker
+// 每個鎖使用一條獨立的連線，連線中斷 (例如程序結束) 時 Postgres 會自動釋放
+func NewAdvisoryLocker(db *gorm.DB) itfc.Locker {
+	return &gormAdvisoryLocker{db: db}
+}
+
+func (l *gormAdvisoryLocker) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	sqlDB, err := l.db.DB()
+	if err != nil {
+		return nil, false, err
+	}
+	// advisory lock 屬於 session，必須在同一條連線上取得與釋放
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	key := advisoryKey(name)
+	var ok bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&ok); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	if !ok {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	var once sync.Once
+	unlock := func() {
+		once.Do(func() {
+			// 即使呼叫者的 ctx 已結束也要釋放，否則連線回到連線池後仍持有鎖
+			if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+				slog.ErrorContext(ctx, "Failed to release advisory lock", "name", name, "error", err)
+			}
+			conn.Close()
+		})
+	}
+	return unlock, true, nil
+}
+
+// advisoryKey 將鎖的名稱轉換為 advisory lock 使用的 bigint
+func advisoryKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,122 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"context"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormMessageRepository struct {
+	db *gorm.DB
+}
+
+func NewMessageRepository(db *gorm.DB) itfc.MessageRepository {
+	return &gormMessageRepository{db: db}
+}
+
+func (r *gormMessageRepository) Create(ctx context.Context, msg *model.Message) (*model.Message, error) {
+	err := r.db.WithContext(ctx).
+		Create(&msg).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create Message", "error", err)
+		return nil, translateError(err)
+	}
+
+	return msg, nil
+}
+
+func (r *gormMessageRepository) ListByChatSince(ctx context.Context, chatID string, since time.Time) ([]model.Message, error) {
+	var msgs []model.Message
+	err := r.db.WithContext(ctx).
+		Where("chat_id = ? AND sent_at >= ?", chatID, since).
+		Order("sent_at DESC, id DESC").
+		Find(&msgs).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list Message by chat", "error", err)
+		return nil, translateError(err)
+	}
+
+	return msgs, nil
+}
+
+func (r *gormMessageRepository) Upsert(ctx context.Context, msg *model.Message) error {
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "unipile_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"sender_id", "sender_name", "text", "is_sender", "attachment_names", "sent_at", "updated_at"}),
+		}).
+		Create(&msg).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to upsert Message", "error", err)
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *gormMessageRepository) ListByChat(ctx context.Context, chatID string, after *pagination.Cursor, limit int) ([]model.Message, error) {
+	query := r.db.WithContext(ctx).Where("chat_id = ?", chatID)
+	if after != nil {
+		query = query.Where("(sent_at, id) < (?, ?)", after.Time, after.ID)
+	}
+
+	var msgs []model.Message
+	err := query.
+		Order("sent_at DESC, id DESC").
+		Limit(limit).
+		Find(&msgs).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list Message by chat", "error", err)
+		return nil, translateError(err)
+	}
+
+	return msgs, nil
+}
+
+func (r *gormMessageRepository) List(ctx context.Context, filter itfc.MessageFilter) ([]model.Message, error) {
+	query := r.db.WithContext(ctx).Model(&model.Message{})
+	if filter.AccountID != "" {
+		query = query.Where("account_id = ?", filter.AccountID)
+	}
+	if filter.ChatID != "" {
+		query = query.Where("chat_id = ?", filter.ChatID)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("sent_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("sent_at < ?", filter.Until)
+	}
+	if filter.SenderID != "" {
+		query = query.Where("sender_id = ?", filter.SenderID)
+	}
+	if filter.IsSender != nil {
+		query = query.Where("is_sender = ?", *filter.IsSender)
+	}
+	if filter.After != nil {
+		query = query.Where("(sent_at, id) < (?, ?)", filter.After.Time, filter.After.ID)
+	}
+
+	var msgs []model.Message
+	err := query.
+		Order("sent_at DESC, id DESC").
+		Limit(filter.Limit).
+		Find(&msgs).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list Message", "error", err)
+		return nil, translateError(err)
+	}
+
+	return msgs, nil
+}
@@ -0,0 +1,130 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormOutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) itfc.OutboxRepository {
+	return &gormOutboxRepository{db: db}
+}
+
+func (r *gormOutboxRepository) Create(ctx context.Context, e *model.OutboxEvent) error {
+	// 在呼叫者的交易中寫入，與資料變更一起 commit 或 rollback
+	err := txOrDB(ctx, r.db).
+		Create(&e).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create OutboxEvent", "error", err)
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *gormOutboxRepository) ClaimPending(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.OutboxEvent, error) {
+	var events []model.OutboxEvent
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// SKIP LOCKED 讓其他程序略過已被取出的事件，而不是等待交易結束
+		err := tx.
+			Where("published_at IS NULL AND available_at <= ?", now).
+			Order("created_at, id").
+			Limit(limit).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Find(&events).
+			Error
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(events))
+		for i, e := range events {
+			ids[i] = e.ID
+		}
+		leaseUntil := now.Add(lease)
+		err = tx.
+			Model(&model.OutboxEvent{}).
+			Where("id IN ?", ids).
+			Updates(map[string]any{
+				"available_at": leaseUntil,
+				"attempts":     gorm.Expr("attempts + 1"),
+			}).
+			Error
+		if err != nil {
+			return err
+		}
+		for i := range events {
+			events[i].AvailableAt = leaseUntil
+			events[i].Attempts++
+		}
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to claim pending OutboxEvent", "error", err)
+		return nil, translateError(err)
+	}
+
+	return events, nil
+}
+
+func (r *gormOutboxRepository) MarkPublished(ctx context.Context, id uuid.UUID, at time.Time) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"published_at": at,
+			"last_error":   "",
+		})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to mark OutboxEvent published", "error", result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *gormOutboxRepository) Reschedule(ctx context.Context, id uuid.UUID, availableAt time.Time, lastError string) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.OutboxEvent{}).
+		Where("id = ? AND published_at IS NULL", id).
+		Updates(map[string]any{
+			"available_at": availableAt,
+			"last_error":   lastError,
+		})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to reschedule OutboxEvent", "error", result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *gormOutboxRepository) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("published_at < ?", before).
+		Delete(&model.OutboxEvent{})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to delete published OutboxEvent", "error", result.Error)
+		return 0, translateError(result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,117 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormQuotaRepository struct {
+	db *gorm.DB
+}
+
+func NewQuotaRepository(db *gorm.DB) itfc.QuotaRepository {
+	return &gormQuotaRepository{db: db}
+}
+
+func (r *gormQuotaRepository) GetLimits(ctx context.Context, accountID string) (*model.AccountLimits, error) {
+	var limits *model.AccountLimits
+	err := r.db.WithContext(ctx).
+		Where("account_id = ?", accountID).
+		First(&limits).
+		Error
+	if err != nil {
+		// 尚未設定時使用預設值，不記錄錯誤
+		return nil, translateError(err)
+	}
+
+	return limits, nil
+}
+
+func (r *gormQuotaRepository) SaveLimits(ctx context.Context, limits *model.AccountLimits) error {
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "account_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"timezone", "work_start", "work_end", "work_days", "invite_daily", "invite_weekly", "message_daily", "message_weekly", "updated_at"}),
+		}).
+		Clauses(clause.Returning{}).
+		Create(&limits).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to save AccountLimits", "error", err)
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *gormQuotaRepository) Consume(ctx context.Context, usage *model.ActionUsage, windows []itfc.QuotaWindow) (bool, error) {
+	ok := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 以帳號與動作為鍵的 advisory lock 讓同時的 Consume 依序檢查，交易結束時自動釋放
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", usage.AccountID+":"+usage.Action).Error; err != nil {
+			return err
+		}
+
+		for _, w := range windows {
+			var used int64
+			err := tx.
+				Model(&model.ActionUsage{}).
+				Where("account_id = ? AND action = ? AND created_at >= ?", usage.AccountID, usage.Action, w.Since).
+				Count(&used).
+				Error
+			if err != nil {
+				return err
+			}
+			if used >= int64(w.Limit) {
+				return nil
+			}
+		}
+
+		if err := tx.Create(&usage).Error; err != nil {
+			return err
+		}
+		ok = true
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to consume ActionUsage", "error", err)
+		return false, translateError(err)
+	}
+
+	return ok, nil
+}
+
+func (r *gormQuotaRepository) Release(ctx context.Context, id uuid.UUID) error {
+	err := r.db.WithContext(ctx).
+		Where("id = ?", id).
+		Delete(&model.ActionUsage{}).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to release ActionUsage", "error", err)
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *gormQuotaRepository) Count(ctx context.Context, accountID, action string, since time.Time) (int, error) {
+	var used int64
+	err := r.db.WithContext(ctx).
+		Model(&model.ActionUsage{}).
+		Where("account_id = ? AND action = ? AND created_at >= ?", accountID, action, since).
+		Count(&used).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to count ActionUsage", "error", err)
+		return 0, translateError(err)
+	}
+
+	return int(used), nil
+}
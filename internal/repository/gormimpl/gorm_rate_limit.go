@@ -0,0 +1,80 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormRateLimitStore struct {
+	db *gorm.DB
+}
+
+// NewRateLimitStore 建立保存在 Postgres 的 RateLimitStore，多個程序共用同一個限制
+func NewRateLimitStore(db *gorm.DB) itfc.RateLimitStore {
+	return &gormRateLimitStore{db: db}
+}
+
+func (s *gormRateLimitStore) Take(ctx context.Context, key string, bucket itfc.TokenBucket, now time.Time) (itfc.RateLimitResult, error) {
+	now = now.UTC().Truncate(time.Microsecond)
+	var res itfc.RateLimitResult
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 第一次使用的 key 先建立補滿的 bucket，之後才能以 FOR UPDATE 讓同一個 key 的 Take 依序執行
+		full := model.RateLimitBucket{Key: key, Tokens: float64(bucket.Capacity), UpdatedAt: now, ExpiresAt: now}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&full).Error; err != nil {
+			return err
+		}
+
+		var b model.RateLimitBucket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&b).Error; err != nil {
+			return err
+		}
+
+		var tokens float64
+		tokens, res = bucket.Take(b.Tokens, b.UpdatedAt, now)
+		return tx.Model(&model.RateLimitBucket{}).
+			Where("key = ?", key).
+			Updates(map[string]any{"tokens": tokens, "updated_at": now, "expires_at": now.Add(res.ResetAfter)}).
+			Error
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to take RateLimitBucket token", "error", err)
+		return itfc.RateLimitResult{}, translateError(err)
+	}
+
+	return res, nil
+}
+
+func (s *gormRateLimitStore) Peek(ctx context.Context, key string, bucket itfc.TokenBucket, now time.Time) (itfc.RateLimitResult, error) {
+	now = now.UTC().Truncate(time.Microsecond)
+	var buckets []model.RateLimitBucket
+	if err := s.db.WithContext(ctx).Where("key = ?", key).Limit(1).Find(&buckets).Error; err != nil {
+		slog.ErrorContext(ctx, "Failed to get RateLimitBucket", "error", err)
+		return itfc.RateLimitResult{}, translateError(err)
+	}
+
+	// 不存在的 key 視為補滿的 bucket
+	b := model.RateLimitBucket{Tokens: float64(bucket.Capacity), UpdatedAt: now}
+	if len(buckets) > 0 {
+		b = buckets[0]
+	}
+	_, res := bucket.Take(b.Tokens, b.UpdatedAt, now)
+	return res, nil
+}
+
+func (s *gormRateLimitStore) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result := s.db.WithContext(ctx).
+		Where("expires_at < ?", before).
+		Delete(&model.RateLimitBucket{})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to delete expired RateLimitBucket", "error", result.Error)
+		return 0, translateError(result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,150 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormScheduledMessageRepository struct {
+	db *gorm.DB
+}
+
+func NewScheduledMessageRepository(db *gorm.DB) itfc.ScheduledMessageRepository {
+	return &gormScheduledMessageRepository{db: db}
+}
+
+func (r *gormScheduledMessageRepository) Create(ctx context.Context, m *model.ScheduledMessage) error {
+	err := r.db.WithContext(ctx).
+		Create(&m).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create ScheduledMessage", "error", err)
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *gormScheduledMessageRepository) Get(ctx context.Context, id uuid.UUID) (*model.ScheduledMessage, error) {
+	var m *model.ScheduledMessage
+	err := r.db.WithContext(ctx).
+		Where("id = ?", id).
+		First(&m).
+		Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return m, nil
+}
+
+func (r *gormScheduledMessageRepository) ListByUser(ctx context.Context, email, status string, after *pagination.Cursor, limit int) ([]model.ScheduledMessage, error) {
+	query := r.db.WithContext(ctx).Where("user_email = ?", email)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if after != nil {
+		query = query.Where("(send_at, id) < (?, ?)", after.Time, after.ID)
+	}
+
+	var messages []model.ScheduledMessage
+	err := query.
+		Order("send_at DESC, id DESC").
+		Limit(limit).
+		Find(&messages).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list ScheduledMessage by user", "error", err)
+		return nil, translateError(err)
+	}
+
+	return messages, nil
+}
+
+func (r *gormScheduledMessageRepository) Update(ctx context.Context, m *model.ScheduledMessage, fromStatus string) error {
+	now := time.Now()
+	result := r.db.WithContext(ctx).
+		Model(&model.ScheduledMessage{}).
+		Where("id = ? AND status = ? AND lease_id IS NOT DISTINCT FROM ?", m.ID, fromStatus, m.LeaseID).
+		Updates(map[string]any{
+			"chat_id":         m.ChatID,
+			"text":            m.Text,
+			"send_at":         m.SendAt,
+			"timezone":        m.Timezone,
+			"status":          m.Status,
+			"next_attempt_at": m.NextAttemptAt,
+			"attempts":        m.Attempts,
+			"last_error":      m.LastError,
+			"message_id":      m.MessageID,
+			"sent_at":         m.SentAt,
+			"updated_at":      now,
+		})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to update ScheduledMessage", "error", result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		// 訊息已經被其他程序改變狀態 (例如使用者剛好取消)，由呼叫者決定如何處理
+		return apperr.ErrConflict
+	}
+
+	m.UpdatedAt = &now
+	return nil
+}
+
+func (r *gormScheduledMessageRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.ScheduledMessage, error) {
+	var messages []model.ScheduledMessage
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// SKIP LOCKED 讓其他程序略過已被取出的訊息，而不是等待交易結束
+		err := tx.
+			Where("status IN ? AND next_attempt_at <= ?", []string{model.ScheduledPending, model.ScheduledSending}, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Find(&messages).
+			Error
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(messages))
+		for i, m := range messages {
+			ids[i] = m.ID
+		}
+		leaseUntil, leaseID := now.Add(lease), uuid.New()
+		err = tx.
+			Model(&model.ScheduledMessage{}).
+			Where("id IN ?", ids).
+			Updates(map[string]any{
+				"status":          model.ScheduledSending,
+				"next_attempt_at": leaseUntil,
+				"lease_id":        leaseID,
+				"updated_at":      time.Now(),
+			}).
+			Error
+		if err != nil {
+			return err
+		}
+		for i := range messages {
+			messages[i].Status = model.ScheduledSending
+			messages[i].NextAttemptAt = &leaseUntil
+			messages[i].LeaseID = &leaseID
+		}
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to claim due ScheduledMessage", "error", err)
+		return nil, translateError(err)
+	}
+
+	return messages, nil
+}
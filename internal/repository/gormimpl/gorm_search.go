@@ -0,0 +1,149 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/search"
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type gormSearchRepository struct {
+	db *gorm.DB
+}
+
+// NewSearchRepository 建立 SearchRepository
+// Postgres 使用 messages 與 chat_attendees 的 search_vector (tsvector + GIN index)，
+// 其他資料庫 (例如開發用的 SQLite) 改用 LIKE，以子字串比對
+func NewSearchRepository(db *gorm.DB) itfc.SearchRepository {
+	return &gormSearchRepository{db: db}
+}
+
+// headlineOptions ts_headline 的設定，標記字元與 search 套件相同
+var headlineOptions = "StartSel=" + search.HighlightStart + ", StopSel=" + search.HighlightStop +
+	", MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=\" … \""
+
+func (r *gormSearchRepository) Search(ctx context.Context, filter itfc.SearchFilter) ([]itfc.SearchHit, error) {
+	if len(filter.AccountIDs) == 0 {
+		return []itfc.SearchHit{}, nil
+	}
+
+	var hits []itfc.SearchHit
+	var err error
+	if r.db.Dialector.Name() == "postgres" {
+		hits, err = r.searchTSVector(ctx, filter)
+	} else {
+		hits, err = r.searchLike(ctx, filter)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to search Message", "error", err)
+		return nil, translateError(err)
+	}
+
+	return hits, nil
+}
+
+func (r *gormSearchRepository) searchTSVector(ctx context.Context, filter itfc.SearchFilter) ([]itfc.SearchHit, error) {
+	// ts_rank 回傳 real，轉成 float8 讓游標中的分數與資料庫的比較結果一致
+	const rank = "ts_rank(messages.search_vector, query)::float8"
+
+	// 內文或寄件者名稱符合，或是對話中其他參與者的名稱符合
+	// 兩者分別查詢再合併，各自使用自己的 GIN index (放在同一個 OR 中無法使用 index)
+	matched := r.db.Raw(`SELECT messages.id FROM messages
+		WHERE messages.search_vector @@ websearch_to_tsquery('simple', ?) AND messages.account_id IN ?
+		UNION
+		SELECT messages.id FROM chat_attendees
+		JOIN messages ON messages.chat_id = chat_attendees.chat_id AND messages.account_id = chat_attendees.account_id
+		WHERE chat_attendees.search_vector @@ websearch_to_tsquery('simple', ?) AND NOT chat_attendees.is_self
+			AND chat_attendees.account_id IN ?`,
+		filter.Query, filter.AccountIDs, filter.Query, filter.AccountIDs)
+
+	query := r.db.WithContext(ctx).
+		Table("messages").
+		Select("messages.*, "+rank+" AS rank, ts_headline('simple', coalesce(messages.text, ''), query, ?) AS highlight", headlineOptions).
+		Joins("CROSS JOIN websearch_to_tsquery('simple', ?) AS query", filter.Query).
+		Where("messages.id IN (?)", matched)
+	query = r.filter(query, filter)
+	if filter.After != nil {
+		query = query.Where("("+rank+", messages.created_at, messages.id) < (?, ?, ?)", filter.After.Rank, filter.After.Time, filter.After.ID)
+	}
+
+	var hits []itfc.SearchHit
+	err := query.
+		Order("rank DESC, messages.created_at DESC, messages.id DESC").
+		Limit(filter.Limit).
+		Scan(&hits).
+		Error
+	return hits, err
+}
+
+// searchLike 每個字詞都必須出現在內文或寄件者名稱中，或是都出現在對話中其他參與者的名稱中
+// 分數與 search.Rank 相同，只有參與者名稱符合時為 0
+func (r *gormSearchRepository) searchLike(ctx context.Context, filter itfc.SearchFilter) ([]itfc.SearchHit, error) {
+	terms := search.Terms(filter.Query)
+	if len(terms) == 0 {
+		return []itfc.SearchHit{}, nil
+	}
+
+	var messageMatch, attendeeMatch, scores []string
+	var messageArgs, attendeeArgs, scoreArgs []any
+	for _, t := range terms {
+		pattern := "%" + escapeLike(t) + "%"
+		messageMatch = append(messageMatch, `(LOWER(messages.text) LIKE ? ESCAPE '\' OR LOWER(messages.sender_name) LIKE ? ESCAPE '\')`)
+		messageArgs = append(messageArgs, pattern, pattern)
+		attendeeMatch = append(attendeeMatch, `LOWER(chat_attendees.name) LIKE ? ESCAPE '\'`)
+		attendeeArgs = append(attendeeArgs, pattern)
+		scores = append(scores, `CASE WHEN LOWER(messages.text) LIKE ? ESCAPE '\' THEN 1.0 ELSE 0.5 END`)
+		scoreArgs = append(scoreArgs, pattern)
+	}
+	rank := "(CASE WHEN " + strings.Join(messageMatch, " AND ") + " THEN (" + strings.Join(scores, " + ") + ") / " + strconv.Itoa(len(terms)) + ".0 ELSE 0 END)"
+	rankArgs := append(append([]any{}, messageArgs...), scoreArgs...)
+
+	query := r.db.WithContext(ctx).
+		Table("messages").
+		Select("messages.*, "+rank+" AS rank", rankArgs...).
+		Where("("+strings.Join(messageMatch, " AND ")+`) OR EXISTS (
+			SELECT 1 FROM chat_attendees
+			WHERE chat_attendees.chat_id = messages.chat_id AND chat_attendees.account_id = messages.account_id
+				AND NOT chat_attendees.is_self AND `+strings.Join(attendeeMatch, " AND ")+`
+		)`, append(append([]any{}, messageArgs...), attendeeArgs...)...)
+	query = r.filter(query, filter)
+	if filter.After != nil {
+		args := append(append([]any{}, rankArgs...), filter.After.Rank, filter.After.Time, filter.After.ID)
+		query = query.Where("("+rank+", messages.created_at, messages.id) < (?, ?, ?)", args...)
+	}
+
+	var hits []itfc.SearchHit
+	err := query.
+		Order("rank DESC, messages.created_at DESC, messages.id DESC").
+		Limit(filter.Limit).
+		Scan(&hits).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range hits {
+		hits[i].Highlight = search.Highlight(hits[i].Text, terms)
+	}
+	return hits, nil
+}
+
+func (r *gormSearchRepository) filter(query *gorm.DB, filter itfc.SearchFilter) *gorm.DB {
+	query = query.Where("messages.account_id IN ?", filter.AccountIDs)
+	if !filter.Since.IsZero() {
+		query = query.Where("messages.sent_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("messages.sent_at < ?", filter.Until)
+	}
+	return query
+}
+
+// escapeLike 跳脫 LIKE 的萬用字元
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
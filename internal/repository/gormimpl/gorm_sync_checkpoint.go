@@ -0,0 +1,50 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"log/slog"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormSyncCheckpointRepository struct {
+	db *gorm.DB
+}
+
+func NewSyncCheckpointRepository(db *gorm.DB) itfc.SyncCheckpointRepository {
+	return &gormSyncCheckpointRepository{db: db}
+}
+
+func (r *gormSyncCheckpointRepository) Get(ctx context.Context, accountID string) (*model.SyncCheckpoint, error) {
+	var cp *model.SyncCheckpoint
+	err := r.db.WithContext(ctx).
+		Where("account_id = ?", accountID).
+		First(&cp).
+		Error
+	if err != nil {
+		// 尚未同步過是正常情況，不記錄錯誤
+		return nil, translateError(err)
+	}
+
+	return cp, nil
+}
+
+func (r *gormSyncCheckpointRepository) Save(ctx context.Context, cp *model.SyncCheckpoint) error {
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "account_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"chats_cursor", "chats_backfilled", "last_synced_at", "last_error", "updated_at"}),
+		}).
+		Clauses(clause.Returning{}).
+		Create(&cp).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to save SyncCheckpoint", "error", err)
+		return translateError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"log/slog"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormTaskRunRepository struct {
+	db *gorm.DB
+}
+
+func NewTaskRunRepository(db *gorm.DB) itfc.TaskRunRepository {
+	return &gormTaskRunRepository{db: db}
+}
+
+func (r *gormTaskRunRepository) Get(ctx context.Context, name string) (*model.TaskRun, error) {
+	var run *model.TaskRun
+	err := r.db.WithContext(ctx).
+		Where("name = ?", name).
+		First(&run).
+		Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return run, nil
+}
+
+func (r *gormTaskRunRepository) List(ctx context.Context) ([]model.TaskRun, error) {
+	var runs []model.TaskRun
+	err := r.db.WithContext(ctx).
+		Order("name").
+		Find(&runs).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list TaskRun", "error", err)
+		return nil, translateError(err)
+	}
+
+	return runs, nil
+}
+
+func (r *gormTaskRunRepository) Save(ctx context.Context, run *model.TaskRun) error {
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"schedule", "scheduled_at", "started_at", "finished_at", "status", "result", "error", "duration_ms", "host", "updated_at"}),
+		}).
+		Create(&run).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to save TaskRun", "error", err)
+		return translateError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type gormTemplateRepository struct {
+	db *gorm.DB
+}
+
+func NewTemplateRepository(db *gorm.DB) itfc.TemplateRepository {
+	return &gormTemplateRepository{db: db}
+}
+
+func (r *gormTemplateRepository) Create(ctx context.Context, tmpl *model.MessageTemplate) error {
+	err := r.db.WithContext(ctx).
+		Create(&tmpl).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create MessageTemplate", "error", err)
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *gormTemplateRepository) Get(ctx context.Context, id uuid.UUID) (*model.MessageTemplate, error) {
+	var tmpl *model.MessageTemplate
+	err := r.db.WithContext(ctx).
+		Where("id = ?", id).
+		First(&tmpl).
+		Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return tmpl, nil
+}
+
+func (r *gormTemplateRepository) ListByUser(ctx context.Context, email string) ([]model.MessageTemplate, error) {
+	var tmpls []model.MessageTemplate
+	err := r.db.WithContext(ctx).
+		Where("user_email = ?", email).
+		Order("name, id").
+		Find(&tmpls).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list MessageTemplate by user", "error", err)
+		return nil, translateError(err)
+	}
+
+	return tmpls, nil
+}
+
+func (r *gormTemplateRepository) Update(ctx context.Context, tmpl *model.MessageTemplate) error {
+	now := time.Now()
+	result := r.db.WithContext(ctx).
+		Model(&model.MessageTemplate{}).
+		Where("id = ?", tmpl.ID).
+		Updates(map[string]any{
+			"name":       tmpl.Name,
+			"body":       tmpl.Body,
+			"variables":  tmpl.Variables,
+			"updated_at": now,
+		})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to update MessageTemplate", "error", result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperr.ErrNotFound
+	}
+
+	tmpl.UpdatedAt = &now
+	return nil
+}
+
+func (r *gormTemplateRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).
+		Where("id = ?", id).
+		Delete(&model.MessageTemplate{})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to delete MessageTemplate", "error", result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
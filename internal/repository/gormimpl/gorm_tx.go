@@ -0,0 +1,39 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/itfc"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// txKey 是 ctx 中保存交易 (*gorm.DB) 的 key
+type txKey struct{}
+
+type gormTransactor struct {
+	db *gorm.DB
+}
+
+// NewTransactor 建立以資料庫交易實作的 Transactor
+// 交易保存在 ctx 中，Repository 以 txOrDB 取得；目前 UnipileRepository 與 OutboxRepository 會使用交易
+func NewTransactor(db *gorm.DB) itfc.Transactor {
+	return &gormTransactor{db: db}
+}
+
+func (t *gormTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+
+	return t.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// txOrDB 回傳 ctx 中 WithinTx 開啟的交易，不在交易中時回傳 db
+func txOrDB(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
@@ -1,12 +1,15 @@
 package gormimpl
 
 import (
+	"chatsheet/internal/apperr"
 	"chatsheet/internal/itfc"
 	"chatsheet/internal/model"
 	"context"
 	"log/slog"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type gormUnipileRepository struct {
@@ -18,12 +21,12 @@ func NewUnipileRepository(db *gorm.DB) itfc.UnipileRepository {
 }
 
 func (r *gormUnipileRepository) Create(ctx context.Context, acct *model.UnipileAccount) (*model.UnipileAccount, error) {
-	err := r.db.WithContext(ctx).
+	err := txOrDB(ctx, r.db).
 		Create(&acct).
 		Error
 	if err != nil {
-		slog.Error("Failed to create a UnipileAccount", "error", err)
-		return nil, err
+		slog.ErrorContext(ctx, "Failed to create a UnipileAccount", "error", err)
+		return nil, translateError(err)
 	}
 
 	return acct, nil
@@ -31,14 +34,99 @@ func (r *gormUnipileRepository) Create(ctx context.Context, acct *model.UnipileA
 
 func (r *gormUnipileRepository) ListByEmail(ctx context.Context, email string) ([]model.UnipileAccount, error) {
 	var accts []model.UnipileAccount
-	err := r.db.WithContext(ctx).
+	err := txOrDB(ctx, r.db).
 		Where("user_email = ?", email).
 		Find(&accts).
 		Error
 	if err != nil {
-		slog.Error("Failed to list UnipileAccount by email", "error", err)
-		return nil, err
+		slog.ErrorContext(ctx, "Failed to list UnipileAccount by email", "error", err)
+		return nil, translateError(err)
 	}
 
 	return accts, nil
 }
+
+func (r *gormUnipileRepository) Upsert(ctx context.Context, acct *model.UnipileAccount) (*model.UnipileAccount, error) {
+	// 只有同一個使用者重新連結時才更新，屬於其他使用者時不會影響任何資料列
+	result := txOrDB(ctx, r.db).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "account_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"provider", "status", "status_checked_at", "updated_at"}),
+			Where: clause.Where{Exprs: []clause.Expression{
+				clause.Expr{SQL: "unipile_accounts.user_email = excluded.user_email"},
+			}},
+		}).
+		Clauses(clause.Returning{}). // 衝突時取回既有資料列的 id 與 created_at
+		Create(&acct)
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to upsert a UnipileAccount", "error", result.Error)
+		return nil, translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, apperr.ErrConflict
+	}
+
+	return acct, nil
+}
+
+func (r *gormUnipileRepository) DeleteByAccountID(ctx context.Context, email, accountID string) error {
+	result := txOrDB(ctx, r.db).
+		Where("user_email = ? AND account_id = ?", email, accountID).
+		Delete(&model.UnipileAccount{})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to delete a UnipileAccount", "error", result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *gormUnipileRepository) ListAll(ctx context.Context) ([]model.UnipileAccount, error) {
+	var accts []model.UnipileAccount
+	err := txOrDB(ctx, r.db).
+		Order("created_at, id").
+		Find(&accts).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list all UnipileAccount", "error", err)
+		return nil, translateError(err)
+	}
+
+	return accts, nil
+}
+
+func (r *gormUnipileRepository) GetByAccountID(ctx context.Context, accountID string) (*model.UnipileAccount, error) {
+	var acct *model.UnipileAccount
+	err := txOrDB(ctx, r.db).
+		Where("account_id = ?", accountID).
+		First(&acct).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get UnipileAccount by account_id", "error", err)
+		return nil, translateError(err)
+	}
+
+	return acct, nil
+}
+
+func (r *gormUnipileRepository) UpdateStatus(ctx context.Context, accountID, status string, checkedAt time.Time) error {
+	result := txOrDB(ctx, r.db).
+		Model(&model.UnipileAccount{}).
+		Where("account_id = ?", accountID).
+		Updates(map[string]any{
+			"status":            status,
+			"status_checked_at": checkedAt,
+		})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to update UnipileAccount status", "error", result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
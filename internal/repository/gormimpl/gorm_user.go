@@ -22,8 +22,8 @@ func (r *gormUserRepository) Create(ctx context.Context, user *model.User) (*mod
 		Create(&user).
 		Error
 	if err != nil {
-		slog.Error("Failed to create user", "error", err)
-		return nil, err
+		slog.ErrorContext(ctx, "Failed to create user", "error", err)
+		return nil, translateError(err)
 	}
 
 	return user, nil
@@ -36,8 +36,8 @@ func (r *gormUserRepository) GetByEmail(ctx context.Context, email string) (*mod
 		First(&user).
 		Error
 	if err != nil {
-		slog.Error("Failed to get user by email", "error", err)
-		return nil, err
+		slog.ErrorContext(ctx, "Failed to get user by email", "error", err)
+		return nil, translateError(err)
 	}
 
 	return user, nil
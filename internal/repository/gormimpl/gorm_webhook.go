@@ -0,0 +1,220 @@
+package gormimpl
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type gormWebhookEndpointRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookEndpointRepository(db *gorm.DB) itfc.WebhookEndpointRepository {
+	return &gormWebhookEndpointRepository{db: db}
+}
+
+func (r *gormWebhookEndpointRepository) Create(ctx context.Context, ep *model.WebhookEndpoint) error {
+	err := r.db.WithContext(ctx).
+		Create(&ep).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create WebhookEndpoint", "error", err)
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *gormWebhookEndpointRepository) Get(ctx context.Context, id uuid.UUID) (*model.WebhookEndpoint, error) {
+	var ep *model.WebhookEndpoint
+	err := r.db.WithContext(ctx).
+		Where("id = ?", id).
+		First(&ep).
+		Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return ep, nil
+}
+
+func (r *gormWebhookEndpointRepository) ListByUser(ctx context.Context, email string) ([]model.WebhookEndpoint, error) {
+	var eps []model.WebhookEndpoint
+	err := r.db.WithContext(ctx).
+		Where("user_email = ?", email).
+		Order("created_at, id").
+		Find(&eps).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list WebhookEndpoint by user", "error", err)
+		return nil, translateError(err)
+	}
+
+	return eps, nil
+}
+
+func (r *gormWebhookEndpointRepository) Update(ctx context.Context, ep *model.WebhookEndpoint) error {
+	now := time.Now()
+	result := r.db.WithContext(ctx).
+		Model(&model.WebhookEndpoint{}).
+		Where("id = ?", ep.ID).
+		Updates(map[string]any{
+			"url":         ep.URL,
+			"description": ep.Description,
+			"events":      ep.Events,
+			"active":      ep.Active,
+			"updated_at":  now,
+		})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to update WebhookEndpoint", "error", result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperr.ErrNotFound
+	}
+
+	ep.UpdatedAt = &now
+	return nil
+}
+
+func (r *gormWebhookEndpointRepository) UpdateSecret(ctx context.Context, ep *model.WebhookEndpoint) error {
+	now := time.Now()
+	result := r.db.WithContext(ctx).
+		Model(&model.WebhookEndpoint{}).
+		Where("id = ?", ep.ID).
+		Updates(map[string]any{
+			"key_version":       ep.KeyVersion,
+			"wrapped_key":       ep.WrappedKey,
+			"secret_ciphertext": ep.SecretCiphertext,
+			"updated_at":        now,
+		})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to update WebhookEndpoint secret", "error", result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperr.ErrNotFound
+	}
+
+	ep.UpdatedAt = &now
+	return nil
+}
+
+func (r *gormWebhookEndpointRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).
+		Where("id = ?", id).
+		Delete(&model.WebhookEndpoint{})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to delete WebhookEndpoint", "error", result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
+
+type gormWebhookDeliveryRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookDeliveryRepository(db *gorm.DB) itfc.WebhookDeliveryRepository {
+	return &gormWebhookDeliveryRepository{db: db}
+}
+
+func (r *gormWebhookDeliveryRepository) Create(ctx context.Context, d *model.WebhookDelivery) error {
+	err := r.db.WithContext(ctx).
+		Create(&d).
+		Error
+	if err = translateError(err); err != nil {
+		// 重複觸發的事件是預期的情況，由呼叫者處理
+		if !errors.Is(err, apperr.ErrConflict) {
+			slog.ErrorContext(ctx, "Failed to create WebhookDelivery", "error", err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (r *gormWebhookDeliveryRepository) Get(ctx context.Context, id uuid.UUID) (*model.WebhookDelivery, error) {
+	var d *model.WebhookDelivery
+	err := r.db.WithContext(ctx).
+		Where("id = ?", id).
+		First(&d).
+		Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return d, nil
+}
+
+func (r *gormWebhookDeliveryRepository) ListByEndpoint(ctx context.Context, endpointID uuid.UUID, after *pagination.Cursor, limit int) ([]model.WebhookDelivery, error) {
+	query := r.db.WithContext(ctx).
+		Where("endpoint_id = ?", endpointID)
+	if after != nil {
+		query = query.Where("(created_at, id) < (?, ?)", after.Time, after.ID)
+	}
+
+	var ds []model.WebhookDelivery
+	err := query.
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&ds).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list WebhookDelivery", "error", err)
+		return nil, translateError(err)
+	}
+
+	return ds, nil
+}
+
+func (r *gormWebhookDeliveryRepository) SaveAttempt(ctx context.Context, d *model.WebhookDelivery) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.WebhookDelivery{}).
+		Where("id = ?", d.ID).
+		Updates(map[string]any{
+			"status":          d.Status,
+			"attempts":        d.Attempts,
+			"response_status": d.ResponseStatus,
+			"response_body":   d.ResponseBody,
+			"error":           d.Error,
+			"duration_ms":     d.DurationMs,
+			"last_attempt_at": d.LastAttemptAt,
+			"delivered_at":    d.DeliveredAt,
+		})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to save WebhookDelivery attempt", "error", result.Error)
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return apperr.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *gormWebhookDeliveryRepository) DeleteByEndpoint(ctx context.Context, endpointID uuid.UUID) error {
+	err := r.db.WithContext(ctx).
+		Where("endpoint_id = ?", endpointID).
+		Delete(&model.WebhookDelivery{}).
+		Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to delete WebhookDelivery by endpoint", "error", err)
+		return translateError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryAuditRepository struct {
+	mu     sync.RWMutex
+	events []model.AuditEvent
+}
+
+// NewAuditRepository 建立以記憶體儲存的 AuditRepository
+func NewAuditRepository() itfc.AuditRepository {
+	return &memoryAuditRepository{}
+}
+
+func (r *memoryAuditRepository) Append(ctx context.Context, event *model.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	r.events = append(r.events, *event)
+
+	return nil
+}
+
+func (r *memoryAuditRepository) List(ctx context.Context, filter itfc.AuditFilter) ([]model.AuditEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	events := []model.AuditEvent{}
+	for _, e := range r.events {
+		if filter.ActorEmail != "" && e.ActorEmail != filter.ActorEmail {
+			continue
+		}
+		// 與 gormimpl 相同：(created_at, id) < (cursor.Time, cursor.ID)
+		if filter.After != nil && compareAudit(e, filter.After.Time, filter.After.ID) >= 0 {
+			continue
+		}
+		events = append(events, e)
+	}
+
+	slices.SortFunc(events, func(a, b model.AuditEvent) int {
+		return -compareAudit(a, b.CreatedAt, b.ID.String())
+	})
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[:filter.Limit]
+	}
+
+	return events, nil
+}
+
+func compareAudit(e model.AuditEvent, t time.Time, id string) int {
+	if c := e.CreatedAt.Compare(t); c != 0 {
+		return c
+	}
+	return strings.Compare(e.ID.String(), id)
+}
@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryCampaignRepository struct {
+	mu        sync.RWMutex
+	campaigns []model.Campaign
+}
+
+// NewCampaignRepository 建立以記憶體儲存的 CampaignRepository
+func NewCampaignRepository() itfc.CampaignRepository {
+	return &memoryCampaignRepository{}
+}
+
+func (r *memoryCampaignRepository) Create(ctx context.Context, campaign *model.Campaign) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if campaign.ID == uuid.Nil {
+		campaign.ID = uuid.New()
+	}
+	if campaign.Status == "" {
+		campaign.Status = model.CampaignActive
+	}
+	now := time.Now()
+	campaign.CreatedAt = &now
+	campaign.UpdatedAt = &now
+
+	stored := *campaign
+	stored.Steps = slices.Clone(campaign.Steps)
+	r.campaigns = append(r.campaigns, stored)
+
+	return nil
+}
+
+func (r *memoryCampaignRepository) Get(ctx context.Context, id uuid.UUID) (*model.Campaign, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.get(id)
+}
+
+// get 呼叫者必須持有 r.mu
+func (r *memoryCampaignRepository) get(id uuid.UUID) (*model.Campaign, error) {
+	for _, c := range r.campaigns {
+		if c.ID == id {
+			c.Steps = slices.Clone(c.Steps)
+			return &c, nil
+		}
+	}
+
+	return nil, apperr.ErrNotFound
+}
+
+func (r *memoryCampaignRepository) ListByUser(ctx context.Context, email string) ([]model.Campaign, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	campaigns := []model.Campaign{}
+	for _, c := range r.campaigns {
+		if c.UserEmail == email {
+			c.Steps = slices.Clone(c.Steps)
+			campaigns = append(campaigns, c)
+		}
+	}
+
+	// 與 gormimpl 相同：created_at DESC, id DESC
+	slices.SortFunc(campaigns, func(a, b model.Campaign) int {
+		return -compareKey(*a.CreatedAt, a.ID.String(), *b.CreatedAt, b.ID.String())
+	})
+
+	return campaigns, nil
+}
+
+func (r *memoryCampaignRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, c := range r.campaigns {
+		if c.ID == id {
+			now := time.Now()
+			r.campaigns[i].Status = status
+			r.campaigns[i].UpdatedAt = &now
+			return nil
+		}
+	}
+
+	return apperr.ErrNotFound
+}
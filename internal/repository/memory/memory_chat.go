@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryChatRepository struct {
+	mu    sync.RWMutex
+	chats []model.Chat
+}
+
+// NewChatRepository 建立以記憶體儲存的 ChatRepository
+func NewChatRepository() itfc.ChatRepository {
+	return &memoryChatRepository{}
+}
+
+func (r *memoryChatRepository) Upsert(ctx context.Context, chat *model.Chat) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for i, c := range r.chats {
+		if c.UnipileID != chat.UnipileID {
+			continue
+		}
+		// 與 gormimpl 相同：保留 id、created_at 與訊息回填進度
+		chat.ID = c.ID
+		chat.CreatedAt = c.CreatedAt
+		chat.MessagesCursor = c.MessagesCursor
+		chat.MessagesBackfilled = c.MessagesBackfilled
+		chat.UpdatedAt = &now
+		r.chats[i] = *chat
+		return nil
+	}
+
+	if chat.ID == uuid.Nil {
+		chat.ID = uuid.New()
+	}
+	chat.CreatedAt = &now
+	chat.UpdatedAt = &now
+	r.chats = append(r.chats, *chat)
+
+	return nil
+}
+
+func (r *memoryChatRepository) GetByUnipileID(ctx context.Context, unipileID string) (*model.Chat, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, c := range r.chats {
+		if c.UnipileID == unipileID {
+			return &c, nil
+		}
+	}
+
+	return nil, apperr.ErrNotFound
+}
+
+func (r *memoryChatRepository) ListByAccount(ctx context.Context, accountID string, after *pagination.Cursor, limit int) ([]model.Chat, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	chats := []model.Chat{}
+	for _, c := range r.chats {
+		if c.AccountID != accountID {
+			continue
+		}
+		// 與 gormimpl 相同：(last_message_at, id) < (cursor.Time, cursor.ID)
+		if after != nil && compareKey(c.LastMessageAt, c.ID.String(), after.Time, after.ID) >= 0 {
+			continue
+		}
+		chats = append(chats, c)
+	}
+
+	return limitChats(sortChats(chats), limit), nil
+}
+
+func (r *memoryChatRepository) ListNotBackfilled(ctx context.Context, accountID string, limit int) ([]model.Chat, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	chats := []model.Chat{}
+	for _, c := range r.chats {
+		if c.AccountID == accountID && !c.MessagesBackfilled {
+			chats = append(chats, c)
+		}
+	}
+
+	return limitChats(sortChats(chats), limit), nil
+}
+
+func (r *memoryChatRepository) UpdateMessagesCheckpoint(ctx context.Context, unipileID, cursor string, backfilled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, c := range r.chats {
+		if c.UnipileID == unipileID {
+			now := time.Now()
+			r.chats[i].MessagesCursor = cursor
+			r.chats[i].MessagesBackfilled = backfilled
+			r.chats[i].UpdatedAt = &now
+			return nil
+		}
+	}
+
+	return apperr.ErrNotFound
+}
+
+// sortChats 與 gormimpl 相同：last_message_at DESC, id DESC
+func sortChats(chats []model.Chat) []model.Chat {
+	slices.SortFunc(chats, func(a, b model.Chat) int {
+		return -compareKey(a.LastMessageAt, a.ID.String(), b.LastMessageAt, b.ID.String())
+	})
+	return chats
+}
+
+func limitChats(chats []model.Chat, limit int) []model.Chat {
+	if limit > 0 && len(chats) > limit {
+		return chats[:limit]
+	}
+	return chats
+}
@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryChatAttendeeRepository struct {
+	mu        sync.RWMutex
+	attendees []model.ChatAttendee // 依建立順序排列
+}
+
+// NewChatAttendeeRepository 建立以記憶體儲存的 ChatAttendeeRepository
+func NewChatAttendeeRepository() itfc.ChatAttendeeRepository {
+	return &memoryChatAttendeeRepository{}
+}
+
+func (r *memoryChatAttendeeRepository) Upsert(ctx context.Context, attendee *model.ChatAttendee) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	// 對應 UNIQUE (chat_id, provider_id)
+	for i, a := range r.attendees {
+		if a.ChatID != attendee.ChatID || a.ProviderID != attendee.ProviderID {
+			continue
+		}
+		a.UnipileID = attendee.UnipileID
+		a.Name = attendee.Name
+		a.IsSelf = attendee.IsSelf
+		a.ProfileURL = attendee.ProfileURL
+		a.PictureURL = attendee.PictureURL
+		a.UpdatedAt = &now
+		r.attendees[i] = a
+		return nil
+	}
+
+	if attendee.ID == uuid.Nil {
+		attendee.ID = uuid.New()
+	}
+	attendee.CreatedAt = &now
+	attendee.UpdatedAt = &now
+	r.attendees = append(r.attendees, *attendee)
+
+	return nil
+}
+
+func (r *memoryChatAttendeeRepository) ListByChats(ctx context.Context, chatIDs []string) ([]model.ChatAttendee, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	attendees := []model.ChatAttendee{}
+	for _, a := range r.attendees {
+		if slices.Contains(chatIDs, a.ChatID) {
+			attendees = append(attendees, a)
+		}
+	}
+
+	return attendees, nil
+}
@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryContactRepository struct {
+	mu       sync.RWMutex
+	contacts []model.Contact
+}
+
+// NewContactRepository 建立以記憶體儲存的 ContactRepository
+func NewContactRepository() itfc.ContactRepository {
+	return &memoryContactRepository{}
+}
+
+func (r *memoryContactRepository) Upsert(ctx context.Context, contact *model.Contact) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	// 對應 UNIQUE (account_id, provider_id)
+	for i, c := range r.contacts {
+		if c.AccountID != contact.AccountID || c.ProviderID != contact.ProviderID {
+			continue
+		}
+		c.PublicIdentifier = contact.PublicIdentifier
+		c.Name = contact.Name
+		c.FirstName = contact.FirstName
+		c.LastName = contact.LastName
+		c.Headline = contact.Headline
+		c.Company = contact.Company
+		c.Location = contact.Location
+		c.ProfileURL = contact.ProfileURL
+		c.PictureURL = contact.PictureURL
+		c.FetchedAt = contact.FetchedAt
+		c.UpdatedAt = &now
+		r.contacts[i] = c
+		*contact = c
+		return nil
+	}
+
+	if contact.ID == uuid.Nil {
+		contact.ID = uuid.New()
+	}
+	contact.CreatedAt = &now
+	contact.UpdatedAt = &now
+	r.contacts = append(r.contacts, *contact)
+
+	return nil
+}
+
+func (r *memoryContactRepository) GetByIdentifier(ctx context.Context, accountID, identifier string) (*model.Contact, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, c := range r.contacts {
+		if c.AccountID == accountID && (c.ProviderID == identifier || c.PublicIdentifier == identifier) {
+			return &c, nil
+		}
+	}
+
+	return nil, apperr.ErrNotFound
+}
+
+func (r *memoryContactRepository) ListByProviderIDs(ctx context.Context, accountID string, providerIDs []string) ([]model.Contact, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	contacts := []model.Contact{}
+	for _, c := range r.contacts {
+		if c.AccountID == accountID && slices.Contains(providerIDs, c.ProviderID) {
+			contacts = append(contacts, c)
+		}
+	}
+
+	return contacts, nil
+}
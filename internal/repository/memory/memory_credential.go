@@ -0,0 +1,118 @@
+package memory
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryCredentialRepository struct {
+	mu    sync.RWMutex
+	creds map[string]model.UnipileCredential // key: account_id
+}
+
+// NewCredentialRepository 建立以記憶體儲存的 CredentialRepository
+func NewCredentialRepository() itfc.CredentialRepository {
+	return &memoryCredentialRepository{creds: make(map[string]model.UnipileCredential)}
+}
+
+func (r *memoryCredentialRepository) Upsert(ctx context.Context, cred *model.UnipileCredential) (*model.UnipileCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := r.creds[cred.AccountID]; ok {
+		// 與 ON CONFLICT (account_id) DO UPDATE ... WHERE user_email 相同：只覆寫同一個使用者的憑證，保留 id 與 created_at
+		if existing.UserEmail != cred.UserEmail {
+			return nil, apperr.ErrConflict
+		}
+		cred.ID = existing.ID
+		cred.CreatedAt = existing.CreatedAt
+	} else {
+		if cred.ID == uuid.Nil {
+			cred.ID = uuid.New()
+		}
+		cred.CreatedAt = &now
+	}
+	cred.UpdatedAt = &now
+
+	r.creds[cred.AccountID] = cloneCredential(*cred)
+
+	return cred, nil
+}
+
+func (r *memoryCredentialRepository) GetByAccountID(ctx context.Context, accountID string) (*model.UnipileCredential, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cred, ok := r.creds[accountID]
+	if !ok {
+		return nil, apperr.ErrNotFound
+	}
+
+	cred = cloneCredential(cred)
+	return &cred, nil
+}
+
+func (r *memoryCredentialRepository) ListByKeyVersionNot(ctx context.Context, version, limit int) ([]model.UnipileCredential, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	creds := []model.UnipileCredential{}
+	for _, c := range r.creds {
+		if c.KeyVersion != version {
+			creds = append(creds, cloneCredential(c))
+		}
+	}
+
+	// 與 gormimpl 相同依 id 排序
+	slices.SortFunc(creds, func(a, b model.UnipileCredential) int {
+		return strings.Compare(a.ID.String(), b.ID.String())
+	})
+	if limit > 0 && len(creds) > limit {
+		creds = creds[:limit]
+	}
+
+	return creds, nil
+}
+
+func (r *memoryCredentialRepository) UpdateWrappedKey(ctx context.Context, id uuid.UUID, version int, wrappedKey []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for accountID, c := range r.creds {
+		if c.ID == id {
+			now := time.Now()
+			c.KeyVersion = version
+			c.WrappedKey = slices.Clone(wrappedKey)
+			c.UpdatedAt = &now
+			r.creds[accountID] = c
+			return nil
+		}
+	}
+
+	return apperr.ErrNotFound
+}
+
+// cloneCredential 複製 byte slice，避免呼叫端修改到儲存的資料
+func cloneCredential(c model.UnipileCredential) model.UnipileCredential {
+	c.WrappedKey = slices.Clone(c.WrappedKey)
+	c.Ciphertext = slices.Clone(c.Ciphertext)
+	return c
+}
+
+func (r *memoryCredentialRepository) DeleteByAccountID(ctx context.Context, accountID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.creds, accountID)
+
+	return nil
+}
@@ -0,0 +1,213 @@
+package memory
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryEnrollmentRepository struct {
+	campaigns *memoryCampaignRepository
+
+	mu          sync.Mutex
+	enrollments []model.Enrollment
+	events      []model.EnrollmentEvent
+}
+
+// NewEnrollmentRepository 建立以記憶體儲存的 EnrollmentRepository
+// ClaimDue 需要活動的狀態，campaignRepo 必須是 NewCampaignRepository 建立的
+func NewEnrollmentRepository(campaignRepo itfc.CampaignRepository) itfc.EnrollmentRepository {
+	return &memoryEnrollmentRepository{campaigns: campaignRepo.(*memoryCampaignRepository)}
+}
+
+func (r *memoryEnrollmentRepository) Create(ctx context.Context, e *model.Enrollment, ev *model.EnrollmentEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 對應 UNIQUE (campaign_id, provider_id)
+	for _, existing := range r.enrollments {
+		if existing.CampaignID == e.CampaignID && existing.ProviderID == e.ProviderID {
+			return apperr.ErrConflict
+		}
+	}
+
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	if e.Status == "" {
+		e.Status = model.EnrollmentActive
+	}
+	now := time.Now()
+	e.CreatedAt = &now
+	e.UpdatedAt = &now
+	r.enrollments = append(r.enrollments, *e)
+
+	ev.EnrollmentID = e.ID
+	r.appendEvent(ev)
+
+	return nil
+}
+
+func (r *memoryEnrollmentRepository) Get(ctx context.Context, id uuid.UUID) (*model.Enrollment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, e := range r.enrollments {
+		if e.ID == id {
+			return &e, nil
+		}
+	}
+
+	return nil, apperr.ErrNotFound
+}
+
+func (r *memoryEnrollmentRepository) ListByCampaign(ctx context.Context, campaignID uuid.UUID, after *pagination.Cursor, limit int) ([]model.Enrollment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	enrollments := []model.Enrollment{}
+	for _, e := range r.enrollments {
+		if e.CampaignID != campaignID {
+			continue
+		}
+		if after != nil && compareKey(*e.CreatedAt, e.ID.String(), after.Time, after.ID) >= 0 {
+			continue
+		}
+		enrollments = append(enrollments, e)
+	}
+
+	// 與 gormimpl 相同：created_at DESC, id DESC
+	slices.SortFunc(enrollments, func(a, b model.Enrollment) int {
+		return -compareKey(*a.CreatedAt, a.ID.String(), *b.CreatedAt, b.ID.String())
+	})
+	if limit > 0 && len(enrollments) > limit {
+		enrollments = enrollments[:limit]
+	}
+
+	return enrollments, nil
+}
+
+func (r *memoryEnrollmentRepository) ListActiveByProspect(ctx context.Context, accountID, providerID string) ([]model.Enrollment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	enrollments := []model.Enrollment{}
+	for _, e := range r.enrollments {
+		if e.AccountID == accountID && e.ProviderID == providerID && e.Status == model.EnrollmentActive {
+			enrollments = append(enrollments, e)
+		}
+	}
+
+	return enrollments, nil
+}
+
+func (r *memoryEnrollmentRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.Enrollment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []int
+	for i, e := range r.enrollments {
+		if e.Status != model.EnrollmentActive || e.NextRunAt == nil || e.NextRunAt.After(now) {
+			continue
+		}
+		r.campaigns.mu.RLock()
+		campaign, err := r.campaigns.get(e.CampaignID)
+		r.campaigns.mu.RUnlock()
+		if err != nil || campaign.Status != model.CampaignActive {
+			continue
+		}
+		due = append(due, i)
+	}
+
+	// 與 gormimpl 相同：next_run_at 由早到晚
+	slices.SortStableFunc(due, func(a, b int) int {
+		return r.enrollments[a].NextRunAt.Compare(*r.enrollments[b].NextRunAt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+
+	leaseUntil, leaseID := now.Add(lease), uuid.New()
+	updated := time.Now()
+	enrollments := make([]model.Enrollment, len(due))
+	for i, idx := range due {
+		r.enrollments[idx].NextRunAt = &leaseUntil
+		r.enrollments[idx].LeaseID = &leaseID
+		r.enrollments[idx].UpdatedAt = &updated
+		enrollments[i] = r.enrollments[idx]
+	}
+
+	return enrollments, nil
+}
+
+func (r *memoryEnrollmentRepository) Transition(ctx context.Context, e *model.Enrollment, fromStatus string, ev *model.EnrollmentEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, existing := range r.enrollments {
+		if existing.ID != e.ID {
+			continue
+		}
+		if existing.Status != fromStatus || !sameLease(existing.LeaseID, e.LeaseID) {
+			return apperr.ErrConflict
+		}
+
+		now := time.Now()
+		existing.Status = e.Status
+		existing.CurrentStep = e.CurrentStep
+		existing.NextRunAt = e.NextRunAt
+		existing.ChatID = e.ChatID
+		existing.Attempts = e.Attempts
+		existing.LastError = e.LastError
+		existing.UpdatedAt = &now
+		r.enrollments[i] = existing
+
+		ev.EnrollmentID = e.ID
+		r.appendEvent(ev)
+		return nil
+	}
+
+	// 與 gormimpl 相同：條件式更新無法區分不存在與狀態已改變
+	return apperr.ErrConflict
+}
+
+func (r *memoryEnrollmentRepository) ListEvents(ctx context.Context, campaignID uuid.UUID, after *pagination.Cursor, limit int) ([]model.EnrollmentEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := []model.EnrollmentEvent{}
+	for _, ev := range r.events {
+		if ev.CampaignID != campaignID {
+			continue
+		}
+		if after != nil && compareKey(ev.CreatedAt, ev.ID.String(), after.Time, after.ID) >= 0 {
+			continue
+		}
+		events = append(events, ev)
+	}
+
+	// 與 gormimpl 相同：created_at DESC, id DESC
+	slices.SortFunc(events, func(a, b model.EnrollmentEvent) int {
+		return -compareKey(a.CreatedAt, a.ID.String(), b.CreatedAt, b.ID.String())
+	})
+	if limit > 0 && len(events) > limit {
+		events = events[:limit]
+	}
+
+	return events, nil
+}
+
+// appendEvent 呼叫者必須持有 r.mu
+func (r *memoryEnrollmentRepository) appendEvent(ev *model.EnrollmentEvent) {
+	if ev.ID == uuid.Nil {
+		ev.ID = uuid.New()
+	}
+	r.events = append(r.events, *ev)
+}
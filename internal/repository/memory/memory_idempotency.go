@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type idempotencyKey struct {
+	email string
+	key   string
+}
+
+type memoryIdempotencyRepository struct {
+	mu   sync.RWMutex
+	recs map[idempotencyKey]model.IdempotencyKey
+}
+
+// NewIdempotencyRepository 建立以記憶體儲存的 IdempotencyRepository
+func NewIdempotencyRepository() itfc.IdempotencyRepository {
+	return &memoryIdempotencyRepository{recs: make(map[idempotencyKey]model.IdempotencyKey)}
+}
+
+func (r *memoryIdempotencyRepository) Create(ctx context.Context, rec *model.IdempotencyKey) (*model.IdempotencyKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 對應 (user_email, key) UNIQUE
+	k := idempotencyKey{rec.UserEmail, rec.Key}
+	if _, ok := r.recs[k]; ok {
+		return nil, apperr.ErrConflict
+	}
+
+	if rec.ID == uuid.Nil {
+		rec.ID = uuid.New()
+	}
+	now := time.Now()
+	rec.CreatedAt = &now
+	rec.UpdatedAt = &now
+
+	stored := *rec
+	stored.ResponseBody = slices.Clone(rec.ResponseBody)
+	r.recs[k] = stored
+
+	return rec, nil
+}
+
+func (r *memoryIdempotencyRepository) Get(ctx context.Context, email, key string) (*model.IdempotencyKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rec, ok := r.recs[idempotencyKey{email, key}]
+	if !ok {
+		return nil, apperr.ErrNotFound
+	}
+
+	rec.ResponseBody = slices.Clone(rec.ResponseBody)
+	return &rec, nil
+}
+
+func (r *memoryIdempotencyRepository) Complete(ctx context.Context, id uuid.UUID, code int, contentType string, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for k, rec := range r.recs {
+		if rec.ID == id {
+			now := time.Now()
+			rec.Status = model.IdempotencyCompleted
+			rec.ResponseCode = code
+			rec.ContentType = contentType
+			rec.ResponseBody = slices.Clone(body)
+			rec.UpdatedAt = &now
+			r.recs[k] = rec
+			return nil
+		}
+	}
+
+	return apperr.ErrNotFound
+}
+
+func (r *memoryIdempotencyRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for k, rec := range r.recs {
+		if rec.ID == id {
+			delete(r.recs, k)
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryIdempotencyRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for k, rec := range r.recs {
+		if rec.ExpiresAt.Before(before) {
+			delete(r.recs, k)
+			n++
+		}
+	}
+
+	return n, nil
+}
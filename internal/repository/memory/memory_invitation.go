@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryInvitationRepository struct {
+	mu          sync.RWMutex
+	invitations []model.Invitation
+}
+
+// NewInvitationRepository 建立以記憶體儲存的 InvitationRepository
+func NewInvitationRepository() itfc.InvitationRepository {
+	return &memoryInvitationRepository{}
+}
+
+func (r *memoryInvitationRepository) Upsert(ctx context.Context, inv *model.Invitation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	// 對應 UNIQUE (account_id, unipile_id)
+	for i, existing := range r.invitations {
+		if existing.AccountID != inv.AccountID || existing.UnipileID != inv.UnipileID {
+			continue
+		}
+		existing.ProviderID = inv.ProviderID
+		existing.PublicIdentifier = inv.PublicIdentifier
+		existing.Name = inv.Name
+		existing.Headline = inv.Headline
+		if inv.Message != "" {
+			existing.Message = inv.Message
+		}
+		if inv.SharedSecret != "" {
+			existing.SharedSecret = inv.SharedSecret
+		}
+		if inv.InvitedAt != nil {
+			existing.InvitedAt = inv.InvitedAt
+		}
+		existing.UpdatedAt = &now
+		r.invitations[i] = existing
+		*inv = existing
+		return nil
+	}
+
+	if inv.ID == uuid.Nil {
+		inv.ID = uuid.New()
+	}
+	if inv.Status == "" {
+		inv.Status = model.InvitationPending
+	}
+	inv.CreatedAt = &now
+	inv.UpdatedAt = &now
+	r.invitations = append(r.invitations, *inv)
+
+	return nil
+}
+
+func (r *memoryInvitationRepository) GetByUnipileID(ctx context.Context, accountID, unipileID string) (*model.Invitation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, inv := range r.invitations {
+		if inv.AccountID == accountID && inv.UnipileID == unipileID {
+			return &inv, nil
+		}
+	}
+
+	return nil, apperr.ErrNotFound
+}
+
+func (r *memoryInvitationRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status string, respondedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, inv := range r.invitations {
+		if inv.ID == id {
+			now := time.Now()
+			r.invitations[i].Status = status
+			r.invitations[i].RespondedAt = &respondedAt
+			r.invitations[i].UpdatedAt = &now
+			return nil
+		}
+	}
+
+	return apperr.ErrNotFound
+}
+
+func (r *memoryInvitationRepository) MarkAccepted(ctx context.Context, accountID, providerID string, respondedAt time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	now := time.Now()
+	for i, inv := range r.invitations {
+		if inv.AccountID == accountID && inv.ProviderID == providerID && inv.Status == model.InvitationPending {
+			r.invitations[i].Status = model.InvitationAccepted
+			r.invitations[i].RespondedAt = &respondedAt
+			r.invitations[i].UpdatedAt = &now
+			n++
+		}
+	}
+
+	return n, nil
+}
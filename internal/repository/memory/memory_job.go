@@ -0,0 +1,226 @@
+package memory
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryJobRepository struct {
+	mu   sync.RWMutex
+	jobs []model.Job
+}
+
+// NewJobRepository 建立以記憶體儲存的 JobRepository
+func NewJobRepository() itfc.JobRepository {
+	return &memoryJobRepository{}
+}
+
+func (r *memoryJobRepository) Create(ctx context.Context, j *model.Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.activeKey(j.UniqueKey, uuid.Nil) {
+		return apperr.ErrConflict
+	}
+
+	if j.ID == uuid.Nil {
+		j.ID = uuid.New()
+	}
+	if j.Status == "" {
+		j.Status = model.JobQueued
+	}
+	now := time.Now()
+	j.CreatedAt = &now
+	j.UpdatedAt = &now
+	r.jobs = append(r.jobs, *j)
+
+	return nil
+}
+
+// activeKey 回傳是否有其他等待或執行中的工作使用相同的 unique key (與 gormimpl 的 partial unique index 相同)
+func (r *memoryJobRepository) activeKey(key string, except uuid.UUID) bool {
+	if key == "" {
+		return false
+	}
+	for _, j := range r.jobs {
+		if j.ID != except && j.UniqueKey == key && (j.Status == model.JobQueued || j.Status == model.JobRunning) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryJobRepository) Get(ctx context.Context, id uuid.UUID) (*model.Job, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, j := range r.jobs {
+		if j.ID == id {
+			return &j, nil
+		}
+	}
+
+	return nil, apperr.ErrNotFound
+}
+
+func (r *memoryJobRepository) List(ctx context.Context, queue, status string, after *pagination.Cursor, limit int) ([]model.Job, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	jobs := []model.Job{}
+	for _, j := range r.jobs {
+		if (queue != "" && j.Queue != queue) || (status != "" && j.Status != status) {
+			continue
+		}
+		if after != nil && compareKey(*j.CreatedAt, j.ID.String(), after.Time, after.ID) >= 0 {
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+
+	// 與 gormimpl 相同：created_at DESC, id DESC
+	slices.SortFunc(jobs, func(a, b model.Job) int {
+		return -compareKey(*a.CreatedAt, a.ID.String(), *b.CreatedAt, b.ID.String())
+	})
+	if limit > 0 && len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+
+	return jobs, nil
+}
+
+func (r *memoryJobRepository) ClaimDue(ctx context.Context, queue string, now time.Time, lease time.Duration, limit int) ([]model.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []int
+	for i, j := range r.jobs {
+		if j.Queue != queue || (j.Status != model.JobQueued && j.Status != model.JobRunning) {
+			continue
+		}
+		if j.RunAt.After(now) {
+			continue
+		}
+		due = append(due, i)
+	}
+
+	// 與 gormimpl 相同：run_at 由早到晚
+	slices.SortStableFunc(due, func(a, b int) int {
+		return r.jobs[a].RunAt.Compare(r.jobs[b].RunAt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+
+	leaseUntil := now.Add(lease)
+	updated := time.Now()
+	jobs := make([]model.Job, len(due))
+	for i, idx := range due {
+		r.jobs[idx].Status = model.JobRunning
+		r.jobs[idx].RunAt = leaseUntil
+		r.jobs[idx].Attempts++
+		r.jobs[idx].UpdatedAt = &updated
+		jobs[i] = r.jobs[idx]
+	}
+
+	return jobs, nil
+}
+
+func (r *memoryJobRepository) Finish(ctx context.Context, j *model.Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, existing := range r.jobs {
+		if existing.ID != j.ID {
+			continue
+		}
+		if existing.Status != model.JobRunning || existing.Attempts != j.Attempts {
+			return apperr.ErrConflict
+		}
+
+		now := time.Now()
+		existing.Status = j.Status
+		existing.RunAt = j.RunAt
+		existing.LastError = j.LastError
+		existing.FinishedAt = j.FinishedAt
+		existing.UpdatedAt = &now
+		r.jobs[i] = existing
+		j.UpdatedAt = &now
+		return nil
+	}
+
+	// 與 gormimpl 相同：條件式更新無法區分不存在與狀態已改變
+	return apperr.ErrConflict
+}
+
+func (r *memoryJobRepository) Retry(ctx context.Context, id uuid.UUID, runAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, existing := range r.jobs {
+		if existing.ID != id {
+			continue
+		}
+		if existing.Status != model.JobDead || r.activeKey(existing.UniqueKey, id) {
+			return apperr.ErrConflict
+		}
+
+		now := time.Now()
+		existing.Status = model.JobQueued
+		existing.RunAt = runAt
+		existing.Attempts = 0
+		existing.FinishedAt = nil
+		existing.UpdatedAt = &now
+		r.jobs[i] = existing
+		return nil
+	}
+
+	return apperr.ErrConflict
+}
+
+func (r *memoryJobRepository) DeleteFinished(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := len(r.jobs)
+	r.jobs = slices.DeleteFunc(r.jobs, func(j model.Job) bool {
+		return (j.Status == model.JobSucceeded || j.Status == model.JobDead) && j.FinishedAt != nil && j.FinishedAt.Before(before)
+	})
+
+	return int64(n - len(r.jobs)), nil
+}
+
+func (r *memoryJobRepository) CountByStatus(ctx context.Context, statuses []string) ([]model.JobCount, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := map[[2]string]int64{}
+	for _, j := range r.jobs {
+		if slices.Contains(statuses, j.Status) {
+			counts[[2]string{j.Queue, j.Status}]++
+		}
+	}
+
+	result := make([]model.JobCount, 0, len(counts))
+	for k, n := range counts {
+		result = append(result, model.JobCount{Queue: k[0], Status: k[1], Count: n})
+	}
+	// 與 gormimpl 相同：queue, status
+	slices.SortFunc(result, func(a, b model.JobCount) int {
+		if c := strings.Compare(a.Queue, b.Queue); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Status, b.Status)
+	})
+
+	return result, nil
+}
@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"chatsheet/internal/itfc"
+	"context"
+	"sync"
+)
+
+type memoryLocker struct {
+	mu     sync.Mutex
+	locked map[string]bool
+}
+
+// NewLocker 建立只在同一個程序內互斥的 Locker
+func NewLocker() itfc.Locker {
+	return &memoryLocker{locked: map[string]bool{}}
+}
+
+func (l *memoryLocker) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.locked[name] {
+		return nil, false, nil
+	}
+	l.locked[name] = true
+
+	var once sync.Once
+	unlock := func() {
+		once.Do(func() {
+			l.mu.Lock()
+			delete(l.locked, name)
+			l.mu.Unlock()
+		})
+	}
+	return unlock, true, nil
+}
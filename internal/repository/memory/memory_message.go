@@ -0,0 +1,179 @@
+package memory
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryMessageRepository struct {
+	mu   sync.RWMutex
+	msgs []model.Message
+}
+
+// NewMessageRepository 建立以記憶體儲存的 MessageRepository
+func NewMessageRepository() itfc.MessageRepository {
+	return &memoryMessageRepository{}
+}
+
+func (r *memoryMessageRepository) Create(ctx context.Context, msg *model.Message) (*model.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 對應 messages.unipile_id UNIQUE
+	for _, m := range r.msgs {
+		if m.UnipileID == msg.UnipileID {
+			return nil, apperr.ErrConflict
+		}
+	}
+
+	if msg.ID == uuid.Nil {
+		msg.ID = uuid.New()
+	}
+	now := time.Now()
+	msg.CreatedAt = &now
+	msg.UpdatedAt = &now
+
+	stored := *msg
+	stored.AttachmentNames = slices.Clone(msg.AttachmentNames)
+	r.msgs = append(r.msgs, stored)
+
+	return msg, nil
+}
+
+func (r *memoryMessageRepository) ListByChatSince(ctx context.Context, chatID string, since time.Time) ([]model.Message, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	msgs := []model.Message{}
+	for _, m := range r.msgs {
+		if m.ChatID == chatID && !m.SentAt.Before(since) {
+			m.AttachmentNames = slices.Clone(m.AttachmentNames)
+			msgs = append(msgs, m)
+		}
+	}
+
+	sortMessages(msgs)
+
+	return msgs, nil
+}
+
+func (r *memoryMessageRepository) Upsert(ctx context.Context, msg *model.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for i, m := range r.msgs {
+		if m.UnipileID != msg.UnipileID {
+			continue
+		}
+		m.SenderID = msg.SenderID
+		m.SenderName = msg.SenderName
+		m.Text = msg.Text
+		m.IsSender = msg.IsSender
+		m.AttachmentNames = slices.Clone(msg.AttachmentNames)
+		m.SentAt = msg.SentAt
+		m.UpdatedAt = &now
+		r.msgs[i] = m
+		return nil
+	}
+
+	if msg.ID == uuid.Nil {
+		msg.ID = uuid.New()
+	}
+	msg.CreatedAt = &now
+	msg.UpdatedAt = &now
+
+	stored := *msg
+	stored.AttachmentNames = slices.Clone(msg.AttachmentNames)
+	r.msgs = append(r.msgs, stored)
+
+	return nil
+}
+
+func (r *memoryMessageRepository) ListByChat(ctx context.Context, chatID string, after *pagination.Cursor, limit int) ([]model.Message, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	msgs := []model.Message{}
+	for _, m := range r.msgs {
+		if m.ChatID != chatID {
+			continue
+		}
+		// 與 gormimpl 相同：(sent_at, id) < (cursor.Time, cursor.ID)
+		if after != nil && compareKey(m.SentAt, m.ID.String(), after.Time, after.ID) >= 0 {
+			continue
+		}
+		m.AttachmentNames = slices.Clone(m.AttachmentNames)
+		msgs = append(msgs, m)
+	}
+
+	sortMessages(msgs)
+	if limit > 0 && len(msgs) > limit {
+		msgs = msgs[:limit]
+	}
+
+	return msgs, nil
+}
+
+func (r *memoryMessageRepository) List(ctx context.Context, filter itfc.MessageFilter) ([]model.Message, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	msgs := []model.Message{}
+	for _, m := range r.msgs {
+		if filter.AccountID != "" && m.AccountID != filter.AccountID {
+			continue
+		}
+		if filter.ChatID != "" && m.ChatID != filter.ChatID {
+			continue
+		}
+		if !filter.Since.IsZero() && m.SentAt.Before(filter.Since) {
+			continue
+		}
+		if !filter.Until.IsZero() && !m.SentAt.Before(filter.Until) {
+			continue
+		}
+		if filter.SenderID != "" && m.SenderID != filter.SenderID {
+			continue
+		}
+		if filter.IsSender != nil && m.IsSender != *filter.IsSender {
+			continue
+		}
+		if filter.After != nil && compareKey(m.SentAt, m.ID.String(), filter.After.Time, filter.After.ID) >= 0 {
+			continue
+		}
+		m.AttachmentNames = slices.Clone(m.AttachmentNames)
+		msgs = append(msgs, m)
+	}
+
+	sortMessages(msgs)
+	if filter.Limit > 0 && len(msgs) > filter.Limit {
+		msgs = msgs[:filter.Limit]
+	}
+
+	return msgs, nil
+}
+
+// sortMessages 與 gormimpl 相同：sent_at DESC, id DESC
+func sortMessages(msgs []model.Message) {
+	slices.SortFunc(msgs, func(a, b model.Message) int {
+		return -compareKey(a.SentAt, a.ID.String(), b.SentAt, b.ID.String())
+	})
+}
+
+// compareKey 比較 (time, id) 組合鍵，對應 SQL 的 row comparison
+func compareKey(t1 time.Time, id1 string, t2 time.Time, id2 string) int {
+	if c := t1.Compare(t2); c != 0 {
+		return c
+	}
+	return strings.Compare(id1, id2)
+}
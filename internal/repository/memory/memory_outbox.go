@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryOutboxRepository struct {
+	mu     sync.RWMutex
+	events []model.OutboxEvent
+}
+
+// NewOutboxRepository 建立以記憶體儲存的 OutboxRepository
+func NewOutboxRepository() itfc.OutboxRepository {
+	return &memoryOutboxRepository{}
+}
+
+func (r *memoryOutboxRepository) Create(ctx context.Context, e *model.OutboxEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	now := time.Now()
+	e.CreatedAt = &now
+	r.events = append(r.events, *e)
+
+	return nil
+}
+
+func (r *memoryOutboxRepository) ClaimPending(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.OutboxEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var pending []int
+	for i, e := range r.events {
+		if e.PublishedAt == nil && !e.AvailableAt.After(now) {
+			pending = append(pending, i)
+		}
+	}
+
+	// 與 gormimpl 相同：created_at, id
+	slices.SortStableFunc(pending, func(a, b int) int {
+		ea, eb := r.events[a], r.events[b]
+		return compareKey(*ea.CreatedAt, ea.ID.String(), *eb.CreatedAt, eb.ID.String())
+	})
+	if limit > 0 && len(pending) > limit {
+		pending = pending[:limit]
+	}
+
+	leaseUntil := now.Add(lease)
+	events := make([]model.OutboxEvent, len(pending))
+	for i, idx := range pending {
+		r.events[idx].AvailableAt = leaseUntil
+		r.events[idx].Attempts++
+		events[i] = r.events[idx]
+	}
+
+	return events, nil
+}
+
+func (r *memoryOutboxRepository) MarkPublished(ctx context.Context, id uuid.UUID, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.events {
+		if r.events[i].ID == id {
+			r.events[i].PublishedAt = &at
+			r.events[i].LastError = ""
+			return nil
+		}
+	}
+
+	return apperr.ErrNotFound
+}
+
+func (r *memoryOutboxRepository) Reschedule(ctx context.Context, id uuid.UUID, availableAt time.Time, lastError string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.events {
+		if r.events[i].ID == id && r.events[i].PublishedAt == nil {
+			r.events[i].AvailableAt = availableAt
+			r.events[i].LastError = lastError
+			return nil
+		}
+	}
+
+	return apperr.ErrNotFound
+}
+
+func (r *memoryOutboxRepository) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := len(r.events)
+	r.events = slices.DeleteFunc(r.events, func(e model.OutboxEvent) bool {
+		return e.PublishedAt != nil && e.PublishedAt.Before(before)
+	})
+
+	return int64(n - len(r.events)), nil
+}
@@ -0,0 +1,118 @@
+package memory
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryQuotaRepository struct {
+	mu     sync.RWMutex
+	limits []model.AccountLimits
+	usages []model.ActionUsage
+}
+
+// NewQuotaRepository 建立以記憶體儲存的 QuotaRepository
+func NewQuotaRepository() itfc.QuotaRepository {
+	return &memoryQuotaRepository{}
+}
+
+func (r *memoryQuotaRepository) GetLimits(ctx context.Context, accountID string) (*model.AccountLimits, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, l := range r.limits {
+		if l.AccountID == accountID {
+			l.WorkDays = slices.Clone(l.WorkDays)
+			return &l, nil
+		}
+	}
+
+	return nil, apperr.ErrNotFound
+}
+
+func (r *memoryQuotaRepository) SaveLimits(ctx context.Context, limits *model.AccountLimits) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	// 對應 UNIQUE (account_id)
+	for i, existing := range r.limits {
+		if existing.AccountID != limits.AccountID {
+			continue
+		}
+		stored := *limits
+		stored.ID = existing.ID
+		stored.WorkDays = slices.Clone(limits.WorkDays)
+		stored.CreatedAt = existing.CreatedAt
+		stored.UpdatedAt = &now
+		r.limits[i] = stored
+		*limits = stored
+		return nil
+	}
+
+	if limits.ID == uuid.Nil {
+		limits.ID = uuid.New()
+	}
+	limits.CreatedAt = &now
+	limits.UpdatedAt = &now
+
+	stored := *limits
+	stored.WorkDays = slices.Clone(limits.WorkDays)
+	r.limits = append(r.limits, stored)
+
+	return nil
+}
+
+func (r *memoryQuotaRepository) Consume(ctx context.Context, usage *model.ActionUsage, windows []itfc.QuotaWindow) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, w := range windows {
+		if r.count(usage.AccountID, usage.Action, w.Since) >= w.Limit {
+			return false, nil
+		}
+	}
+
+	if usage.ID == uuid.Nil {
+		usage.ID = uuid.New()
+	}
+	r.usages = append(r.usages, *usage)
+
+	return true, nil
+}
+
+func (r *memoryQuotaRepository) Release(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.usages = slices.DeleteFunc(r.usages, func(u model.ActionUsage) bool {
+		return u.ID == id
+	})
+
+	return nil
+}
+
+func (r *memoryQuotaRepository) Count(ctx context.Context, accountID, action string, since time.Time) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.count(accountID, action, since), nil
+}
+
+// count 呼叫者必須持有 r.mu
+func (r *memoryQuotaRepository) count(accountID, action string, since time.Time) int {
+	n := 0
+	for _, u := range r.usages {
+		if u.AccountID == accountID && u.Action == action && !u.CreatedAt.Before(since) {
+			n++
+		}
+	}
+	return n
+}
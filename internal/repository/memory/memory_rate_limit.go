@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimitSweepInterval 每隔多久在 Take 時順便刪除已補滿的 bucket
+const rateLimitSweepInterval = time.Minute
+
+type memoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]model.RateLimitBucket
+	lastSweep time.Time
+}
+
+// NewRateLimitStore 建立只在同一個程序內共用的 RateLimitStore
+func NewRateLimitStore() itfc.RateLimitStore {
+	return &memoryRateLimitStore{buckets: map[string]model.RateLimitBucket{}}
+}
+
+func (s *memoryRateLimitStore) Take(ctx context.Context, key string, bucket itfc.TokenBucket, now time.Time) (itfc.RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 沒有 cron 清理時也不讓 map 無限增長
+	if now.Sub(s.lastSweep) >= rateLimitSweepInterval {
+		s.deleteExpired(now)
+		s.lastSweep = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok || !b.ExpiresAt.After(now) {
+		b = model.RateLimitBucket{Key: key, Tokens: float64(bucket.Capacity), UpdatedAt: now}
+	}
+
+	tokens, res := bucket.Take(b.Tokens, b.UpdatedAt, now)
+	b.Tokens = tokens
+	b.UpdatedAt = now
+	b.ExpiresAt = now.Add(res.ResetAfter)
+	s.buckets[key] = b
+
+	return res, nil
+}
+
+func (s *memoryRateLimitStore) Peek(ctx context.Context, key string, bucket itfc.TokenBucket, now time.Time) (itfc.RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok || !b.ExpiresAt.After(now) {
+		b = model.RateLimitBucket{Key: key, Tokens: float64(bucket.Capacity), UpdatedAt: now}
+	}
+
+	_, res := bucket.Take(b.Tokens, b.UpdatedAt, now)
+	return res, nil
+}
+
+func (s *memoryRateLimitStore) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deleteExpired(before), nil
+}
+
+// deleteExpired 呼叫者必須持有 mu
+func (s *memoryRateLimitStore) deleteExpired(before time.Time) int64 {
+	var n int64
+	for k, b := range s.buckets {
+		if b.ExpiresAt.Before(before) {
+			delete(s.buckets, k)
+			n++
+		}
+	}
+	return n
+}
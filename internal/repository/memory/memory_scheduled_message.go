@@ -0,0 +1,159 @@
+package memory
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryScheduledMessageRepository struct {
+	mu       sync.RWMutex
+	messages []model.ScheduledMessage
+}
+
+// NewScheduledMessageRepository 建立以記憶體儲存的 ScheduledMessageRepository
+func NewScheduledMessageRepository() itfc.ScheduledMessageRepository {
+	return &memoryScheduledMessageRepository{}
+}
+
+func (r *memoryScheduledMessageRepository) Create(ctx context.Context, m *model.ScheduledMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	if m.Status == "" {
+		m.Status = model.ScheduledPending
+	}
+	now := time.Now()
+	m.CreatedAt = &now
+	m.UpdatedAt = &now
+	r.messages = append(r.messages, *m)
+
+	return nil
+}
+
+func (r *memoryScheduledMessageRepository) Get(ctx context.Context, id uuid.UUID) (*model.ScheduledMessage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, m := range r.messages {
+		if m.ID == id {
+			return &m, nil
+		}
+	}
+
+	return nil, apperr.ErrNotFound
+}
+
+func (r *memoryScheduledMessageRepository) ListByUser(ctx context.Context, email, status string, after *pagination.Cursor, limit int) ([]model.ScheduledMessage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	messages := []model.ScheduledMessage{}
+	for _, m := range r.messages {
+		if m.UserEmail != email || (status != "" && m.Status != status) {
+			continue
+		}
+		if after != nil && compareKey(m.SendAt, m.ID.String(), after.Time, after.ID) >= 0 {
+			continue
+		}
+		messages = append(messages, m)
+	}
+
+	// 與 gormimpl 相同：send_at DESC, id DESC
+	slices.SortFunc(messages, func(a, b model.ScheduledMessage) int {
+		return -compareKey(a.SendAt, a.ID.String(), b.SendAt, b.ID.String())
+	})
+	if limit > 0 && len(messages) > limit {
+		messages = messages[:limit]
+	}
+
+	return messages, nil
+}
+
+func (r *memoryScheduledMessageRepository) Update(ctx context.Context, m *model.ScheduledMessage, fromStatus string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, existing := range r.messages {
+		if existing.ID != m.ID {
+			continue
+		}
+		if existing.Status != fromStatus || !sameLease(existing.LeaseID, m.LeaseID) {
+			return apperr.ErrConflict
+		}
+
+		now := time.Now()
+		existing.ChatID = m.ChatID
+		existing.Text = m.Text
+		existing.SendAt = m.SendAt
+		existing.Timezone = m.Timezone
+		existing.Status = m.Status
+		existing.NextAttemptAt = m.NextAttemptAt
+		existing.Attempts = m.Attempts
+		existing.LastError = m.LastError
+		existing.MessageID = m.MessageID
+		existing.SentAt = m.SentAt
+		existing.UpdatedAt = &now
+		r.messages[i] = existing
+		m.UpdatedAt = &now
+		return nil
+	}
+
+	// 與 gormimpl 相同：條件式更新無法區分不存在與狀態已改變
+	return apperr.ErrConflict
+}
+
+func (r *memoryScheduledMessageRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.ScheduledMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []int
+	for i, m := range r.messages {
+		if m.Status != model.ScheduledPending && m.Status != model.ScheduledSending {
+			continue
+		}
+		if m.NextAttemptAt == nil || m.NextAttemptAt.After(now) {
+			continue
+		}
+		due = append(due, i)
+	}
+
+	// 與 gormimpl 相同：next_attempt_at 由早到晚
+	slices.SortStableFunc(due, func(a, b int) int {
+		return r.messages[a].NextAttemptAt.Compare(*r.messages[b].NextAttemptAt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+
+	leaseUntil, leaseID := now.Add(lease), uuid.New()
+	updated := time.Now()
+	messages := make([]model.ScheduledMessage, len(due))
+	for i, idx := range due {
+		r.messages[idx].Status = model.ScheduledSending
+		r.messages[idx].NextAttemptAt = &leaseUntil
+		r.messages[idx].LeaseID = &leaseID
+		r.messages[idx].UpdatedAt = &updated
+		messages[i] = r.messages[idx]
+	}
+
+	return messages, nil
+}
+
+// sameLease 對應 lease_id IS NOT DISTINCT FROM
+func sameLease(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/search"
+	"cmp"
+	"context"
+	"slices"
+)
+
+type memorySearchRepository struct {
+	msgs      *memoryMessageRepository
+	attendees *memoryChatAttendeeRepository
+}
+
+// NewSearchRepository 建立以記憶體實作的 SearchRepository，搜尋 messageRepo 中的訊息與 attendeeRepo 中的參與者名稱
+// 兩者必須是 memory.NewMessageRepository 與 memory.NewChatAttendeeRepository 建立的實例
+// 與 Postgres 相同以完整的字詞比對 (不分大小寫，見 search.Tokens)，分數使用 search.Rank
+func NewSearchRepository(messageRepo itfc.MessageRepository, attendeeRepo itfc.ChatAttendeeRepository) itfc.SearchRepository {
+	return &memorySearchRepository{
+		msgs:      messageRepo.(*memoryMessageRepository),
+		attendees: attendeeRepo.(*memoryChatAttendeeRepository),
+	}
+}
+
+func (r *memorySearchRepository) Search(ctx context.Context, filter itfc.SearchFilter) ([]itfc.SearchHit, error) {
+	r.msgs.mu.RLock()
+	defer r.msgs.mu.RUnlock()
+	r.attendees.mu.RLock()
+	defer r.attendees.mu.RUnlock()
+
+	terms := search.Terms(filter.Query)
+	hits := []itfc.SearchHit{}
+	if len(terms) == 0 {
+		return hits, nil
+	}
+
+	// 與 gormimpl 相同：對話中其他參與者的名稱包含所有字詞時，對話的所有訊息都符合
+	attendeeChats := map[string]bool{}
+	for _, a := range r.attendees.attendees {
+		if !a.IsSelf && search.MatchesAll(a.Name, terms) {
+			attendeeChats[a.ChatID] = true
+		}
+	}
+
+	for _, m := range r.msgs.msgs {
+		if !slices.Contains(filter.AccountIDs, m.AccountID) {
+			continue
+		}
+		if !filter.Since.IsZero() && m.SentAt.Before(filter.Since) {
+			continue
+		}
+		if !filter.Until.IsZero() && !m.SentAt.Before(filter.Until) {
+			continue
+		}
+		// 只有參與者名稱符合時分數為 0，排在最後
+		rank := search.Rank(m.Text, m.SenderName, terms)
+		if rank == 0 && !attendeeChats[m.ChatID] {
+			continue
+		}
+		if after := filter.After; after != nil {
+			c := cmp.Compare(rank, after.Rank)
+			if c == 0 {
+				c = compareKey(*m.CreatedAt, m.ID.String(), after.Time, after.ID)
+			}
+			if c >= 0 {
+				continue
+			}
+		}
+
+		m.AttachmentNames = slices.Clone(m.AttachmentNames)
+		hits = append(hits, itfc.SearchHit{Message: m, Rank: rank, Highlight: search.Highlight(m.Text, terms)})
+	}
+
+	// 與 gormimpl 相同：rank DESC, created_at DESC, id DESC
+	slices.SortFunc(hits, func(a, b itfc.SearchHit) int {
+		if c := cmp.Compare(b.Rank, a.Rank); c != 0 {
+			return c
+		}
+		return -compareKey(*a.CreatedAt, a.ID.String(), *b.CreatedAt, b.ID.String())
+	})
+	if filter.Limit > 0 && len(hits) > filter.Limit {
+		hits = hits[:filter.Limit]
+	}
+
+	return hits, nil
+}
@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memorySyncCheckpointRepository struct {
+	mu  sync.RWMutex
+	cps map[string]model.SyncCheckpoint // account_id → checkpoint
+}
+
+// NewSyncCheckpointRepository 建立以記憶體儲存的 SyncCheckpointRepository
+func NewSyncCheckpointRepository() itfc.SyncCheckpointRepository {
+	return &memorySyncCheckpointRepository{cps: map[string]model.SyncCheckpoint{}}
+}
+
+func (r *memorySyncCheckpointRepository) Get(ctx context.Context, accountID string) (*model.SyncCheckpoint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cp, ok := r.cps[accountID]
+	if !ok {
+		return nil, apperr.ErrNotFound
+	}
+
+	return &cp, nil
+}
+
+func (r *memorySyncCheckpointRepository) Save(ctx context.Context, cp *model.SyncCheckpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := r.cps[cp.AccountID]; ok {
+		cp.ID = existing.ID
+		cp.CreatedAt = existing.CreatedAt
+	} else {
+		if cp.ID == uuid.Nil {
+			cp.ID = uuid.New()
+		}
+		cp.CreatedAt = &now
+	}
+	cp.UpdatedAt = &now
+	r.cps[cp.AccountID] = *cp
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryTaskRunRepository struct {
+	mu   sync.RWMutex
+	runs map[string]model.TaskRun
+}
+
+// NewTaskRunRepository 建立以記憶體儲存的 TaskRunRepository
+func NewTaskRunRepository() itfc.TaskRunRepository {
+	return &memoryTaskRunRepository{runs: map[string]model.TaskRun{}}
+}
+
+func (r *memoryTaskRunRepository) Get(ctx context.Context, name string) (*model.TaskRun, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	run, ok := r.runs[name]
+	if !ok {
+		return nil, apperr.ErrNotFound
+	}
+
+	return &run, nil
+}
+
+func (r *memoryTaskRunRepository) List(ctx context.Context) ([]model.TaskRun, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	runs := []model.TaskRun{}
+	for _, run := range r.runs {
+		runs = append(runs, run)
+	}
+
+	// 與 gormimpl 相同：依名稱排列
+	slices.SortFunc(runs, func(a, b model.TaskRun) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return runs, nil
+}
+
+func (r *memoryTaskRunRepository) Save(ctx context.Context, run *model.TaskRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	run.UpdatedAt = &now
+	r.runs[run.Name] = *run
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package memory
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryTemplateRepository struct {
+	mu        sync.RWMutex
+	templates []model.MessageTemplate
+}
+
+// NewTemplateRepository 建立以記憶體儲存的 TemplateRepository
+func NewTemplateRepository() itfc.TemplateRepository {
+	return &memoryTemplateRepository{}
+}
+
+func (r *memoryTemplateRepository) Create(ctx context.Context, tmpl *model.MessageTemplate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.nameTaken(tmpl.UserEmail, tmpl.Name, uuid.Nil) {
+		return apperr.ErrConflict
+	}
+
+	if tmpl.ID == uuid.Nil {
+		tmpl.ID = uuid.New()
+	}
+	now := time.Now()
+	tmpl.CreatedAt = &now
+	tmpl.UpdatedAt = &now
+
+	stored := *tmpl
+	stored.Variables = slices.Clone(tmpl.Variables)
+	r.templates = append(r.templates, stored)
+
+	return nil
+}
+
+// nameTaken 對應 UNIQUE (user_email, name)，呼叫者必須持有 r.mu
+func (r *memoryTemplateRepository) nameTaken(email, name string, except uuid.UUID) bool {
+	for _, t := range r.templates {
+		if t.UserEmail == email && t.Name == name && t.ID != except {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryTemplateRepository) Get(ctx context.Context, id uuid.UUID) (*model.MessageTemplate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, t := range r.templates {
+		if t.ID == id {
+			t.Variables = slices.Clone(t.Variables)
+			return &t, nil
+		}
+	}
+
+	return nil, apperr.ErrNotFound
+}
+
+func (r *memoryTemplateRepository) ListByUser(ctx context.Context, email string) ([]model.MessageTemplate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tmpls := []model.MessageTemplate{}
+	for _, t := range r.templates {
+		if t.UserEmail == email {
+			t.Variables = slices.Clone(t.Variables)
+			tmpls = append(tmpls, t)
+		}
+	}
+
+	// 與 gormimpl 相同：name, id
+	slices.SortFunc(tmpls, func(a, b model.MessageTemplate) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID.String(), b.ID.String())
+	})
+
+	return tmpls, nil
+}
+
+func (r *memoryTemplateRepository) Update(ctx context.Context, tmpl *model.MessageTemplate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, t := range r.templates {
+		if t.ID != tmpl.ID {
+			continue
+		}
+		if r.nameTaken(t.UserEmail, tmpl.Name, t.ID) {
+			return apperr.ErrConflict
+		}
+		now := time.Now()
+		r.templates[i].Name = tmpl.Name
+		r.templates[i].Body = tmpl.Body
+		r.templates[i].Variables = slices.Clone(tmpl.Variables)
+		r.templates[i].UpdatedAt = &now
+		tmpl.UpdatedAt = &now
+		return nil
+	}
+
+	return apperr.ErrNotFound
+}
+
+func (r *memoryTemplateRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, t := range r.templates {
+		if t.ID == id {
+			r.templates = slices.Delete(r.templates, i, i+1)
+			return nil
+		}
+	}
+
+	return apperr.ErrNotFound
+}
@@ -0,0 +1,41 @@
+package memory_test
+
+import (
+	"chatsheet/internal/repository/memory"
+	"chatsheet/internal/repository/repotest"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repositories {
+		messages, attendees := memory.NewMessageRepository(), memory.NewChatAttendeeRepository()
+		campaigns, users := memory.NewCampaignRepository(), memory.NewUserRepository()
+		return repotest.Repositories{
+			Users:       users,
+			Unipile:     memory.NewUnipileRepository(users),
+			Credentials: memory.NewCredentialRepository(),
+			Idempotency: memory.NewIdempotencyRepository(),
+			Audit:       memory.NewAuditRepository(),
+			Messages:    messages,
+			Chats:       memory.NewChatRepository(),
+			Attendees:   attendees,
+			Checkpoints: memory.NewSyncCheckpointRepository(),
+			Search:      memory.NewSearchRepository(messages, attendees),
+			Contacts:    memory.NewContactRepository(),
+			Invitations: memory.NewInvitationRepository(),
+			Campaigns:   campaigns,
+			Enrollments: memory.NewEnrollmentRepository(campaigns),
+			Quotas:      memory.NewQuotaRepository(),
+			Templates:   memory.NewTemplateRepository(),
+			Scheduled:   memory.NewScheduledMessageRepository(),
+			Jobs:        memory.NewJobRepository(),
+			TaskRuns:    memory.NewTaskRunRepository(),
+			Locker:      memory.NewLocker(),
+			Webhooks:    memory.NewWebhookEndpointRepository(),
+			Deliveries:  memory.NewWebhookDeliveryRepository(),
+			Transactor:  memory.NewTransactor(),
+			Outbox:      memory.NewOutboxRepository(),
+			RateLimits:  memory.NewRateLimitStore(),
+		}
+	})
+}
@@ -0,0 +1,18 @@
+package memory
+
+import (
+	"chatsheet/internal/itfc"
+	"context"
+)
+
+type memoryTransactor struct{}
+
+// NewTransactor 建立記憶體實作使用的 Transactor
+// 記憶體實作沒有交易：fn 中的寫入會立即生效，fn 回傳錯誤時不會 rollback
+func NewTransactor() itfc.Transactor {
+	return memoryTransactor{}
+}
+
+func (memoryTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
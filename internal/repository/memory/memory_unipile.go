@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryUnipileRepository struct {
+	users itfc.UserRepository
+
+	mu    sync.RWMutex
+	accts []model.UnipileAccount // 依建立順序排列
+}
+
+// NewUnipileRepository 建立以記憶體儲存的 UnipileRepository
+// 新增帳號時以 userRepo 確認使用者存在，對應 unipile_accounts.user_email 的外鍵
+func NewUnipileRepository(userRepo itfc.UserRepository) itfc.UnipileRepository {
+	return &memoryUnipileRepository{users: userRepo}
+}
+
+// checkUser 對應 fk_user_email：使用者不存在時回傳 apperr.ErrNotFound
+func (r *memoryUnipileRepository) checkUser(ctx context.Context, email string) error {
+	_, err := r.users.GetByEmail(ctx, email)
+	return err
+}
+
+func (r *memoryUnipileRepository) Create(ctx context.Context, acct *model.UnipileAccount) (*model.UnipileAccount, error) {
+	if err := r.checkUser(ctx, acct.UserEmail); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 對應 unipile_accounts.account_id UNIQUE
+	for _, a := range r.accts {
+		if a.AccountID == acct.AccountID || (acct.ID != uuid.Nil && a.ID == acct.ID) {
+			return nil, apperr.ErrConflict
+		}
+	}
+
+	if acct.ID == uuid.Nil {
+		acct.ID = uuid.New()
+	}
+	now := time.Now()
+	if acct.CreatedAt == nil {
+		acct.CreatedAt = &now
+	}
+	if acct.UpdatedAt == nil {
+		acct.UpdatedAt = &now
+	}
+
+	r.accts = append(r.accts, *acct)
+
+	return acct, nil
+}
+
+func (r *memoryUnipileRepository) ListByEmail(ctx context.Context, email string) ([]model.UnipileAccount, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	accts := []model.UnipileAccount{}
+	for _, a := range r.accts {
+		if a.UserEmail == email {
+			accts = append(accts, a)
+		}
+	}
+
+	return accts, nil
+}
+
+func (r *memoryUnipileRepository) Upsert(ctx context.Context, acct *model.UnipileAccount) (*model.UnipileAccount, error) {
+	if err := r.checkUser(ctx, acct.UserEmail); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, a := range r.accts {
+		if a.AccountID != acct.AccountID {
+			continue
+		}
+		// 已被其他使用者連結
+		if a.UserEmail != acct.UserEmail {
+			return nil, apperr.ErrConflict
+		}
+
+		now := time.Now()
+		a.Provider = acct.Provider
+		a.Status = acct.Status
+		a.StatusCheckedAt = acct.StatusCheckedAt
+		a.UpdatedAt = &now
+		r.accts[i] = a
+
+		*acct = a
+		return acct, nil
+	}
+
+	if acct.ID == uuid.Nil {
+		acct.ID = uuid.New()
+	}
+	now := time.Now()
+	acct.CreatedAt = &now
+	acct.UpdatedAt = &now
+
+	r.accts = append(r.accts, *acct)
+
+	return acct, nil
+}
+
+func (r *memoryUnipileRepository) DeleteByAccountID(ctx context.Context, email, accountID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, a := range r.accts {
+		if a.AccountID == accountID && a.UserEmail == email {
+			r.accts = append(r.accts[:i], r.accts[i+1:]...)
+			return nil
+		}
+	}
+
+	return apperr.ErrNotFound
+}
+
+func (r *memoryUnipileRepository) ListAll(ctx context.Context) ([]model.UnipileAccount, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]model.UnipileAccount{}, r.accts...), nil
+}
+
+func (r *memoryUnipileRepository) GetByAccountID(ctx context.Context, accountID string) (*model.UnipileAccount, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, a := range r.accts {
+		if a.AccountID == accountID {
+			return &a, nil
+		}
+	}
+
+	return nil, apperr.ErrNotFound
+}
+
+func (r *memoryUnipileRepository) UpdateStatus(ctx context.Context, accountID, status string, checkedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, a := range r.accts {
+		if a.AccountID == accountID {
+			r.accts[i].Status = status
+			r.accts[i].StatusCheckedAt = &checkedAt
+			return nil
+		}
+	}
+
+	return apperr.ErrNotFound
+}
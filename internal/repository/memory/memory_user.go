@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[string]model.User // key: email
+}
+
+// NewUserRepository 建立以記憶體儲存的 UserRepository，適合不需要 Postgres 的測試
+func NewUserRepository() itfc.UserRepository {
+	return &memoryUserRepository{users: make(map[string]model.User)}
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, user *model.User) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 對應 users.email PRIMARY KEY
+	if _, ok := r.users[user.Email]; ok {
+		return nil, apperr.ErrConflict
+	}
+
+	now := time.Now()
+	if user.CreatedAt == nil {
+		user.CreatedAt = &now
+	}
+	if user.UpdatedAt == nil {
+		user.UpdatedAt = &now
+	}
+
+	r.users[user.Email] = *user
+
+	return user, nil
+}
+
+func (r *memoryUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[email]
+	if !ok {
+		return nil, apperr.ErrNotFound
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,248 @@
+package memory
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryWebhookEndpointRepository struct {
+	mu        sync.RWMutex
+	endpoints []model.WebhookEndpoint
+}
+
+// NewWebhookEndpointRepository 建立以記憶體儲存的 WebhookEndpointRepository
+func NewWebhookEndpointRepository() itfc.WebhookEndpointRepository {
+	return &memoryWebhookEndpointRepository{}
+}
+
+// cloneEndpoint 複製 slice 欄位，避免呼叫者修改儲存的資料
+func cloneEndpoint(ep model.WebhookEndpoint) model.WebhookEndpoint {
+	ep.Events = slices.Clone(ep.Events)
+	ep.WrappedKey = slices.Clone(ep.WrappedKey)
+	ep.SecretCiphertext = slices.Clone(ep.SecretCiphertext)
+	return ep
+}
+
+func (r *memoryWebhookEndpointRepository) Create(ctx context.Context, ep *model.WebhookEndpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if ep.ID == uuid.Nil {
+		ep.ID = uuid.New()
+	}
+	now := time.Now()
+	ep.CreatedAt = &now
+	ep.UpdatedAt = &now
+
+	r.endpoints = append(r.endpoints, cloneEndpoint(*ep))
+
+	return nil
+}
+
+func (r *memoryWebhookEndpointRepository) Get(ctx context.Context, id uuid.UUID) (*model.WebhookEndpoint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, ep := range r.endpoints {
+		if ep.ID == id {
+			ep = cloneEndpoint(ep)
+			return &ep, nil
+		}
+	}
+
+	return nil, apperr.ErrNotFound
+}
+
+func (r *memoryWebhookEndpointRepository) ListByUser(ctx context.Context, email string) ([]model.WebhookEndpoint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	eps := []model.WebhookEndpoint{}
+	for _, ep := range r.endpoints {
+		if ep.UserEmail == email {
+			eps = append(eps, cloneEndpoint(ep))
+		}
+	}
+
+	// 與 gormimpl 相同：created_at, id
+	slices.SortFunc(eps, func(a, b model.WebhookEndpoint) int {
+		return compareKey(*a.CreatedAt, a.ID.String(), *b.CreatedAt, b.ID.String())
+	})
+
+	return eps, nil
+}
+
+func (r *memoryWebhookEndpointRepository) Update(ctx context.Context, ep *model.WebhookEndpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.endpoints {
+		if r.endpoints[i].ID != ep.ID {
+			continue
+		}
+		now := time.Now()
+		r.endpoints[i].URL = ep.URL
+		r.endpoints[i].Description = ep.Description
+		r.endpoints[i].Events = slices.Clone(ep.Events)
+		r.endpoints[i].Active = ep.Active
+		r.endpoints[i].UpdatedAt = &now
+		ep.UpdatedAt = &now
+		return nil
+	}
+
+	return apperr.ErrNotFound
+}
+
+func (r *memoryWebhookEndpointRepository) UpdateSecret(ctx context.Context, ep *model.WebhookEndpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.endpoints {
+		if r.endpoints[i].ID != ep.ID {
+			continue
+		}
+		now := time.Now()
+		r.endpoints[i].KeyVersion = ep.KeyVersion
+		r.endpoints[i].WrappedKey = slices.Clone(ep.WrappedKey)
+		r.endpoints[i].SecretCiphertext = slices.Clone(ep.SecretCiphertext)
+		r.endpoints[i].UpdatedAt = &now
+		ep.UpdatedAt = &now
+		return nil
+	}
+
+	return apperr.ErrNotFound
+}
+
+func (r *memoryWebhookEndpointRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := slices.IndexFunc(r.endpoints, func(ep model.WebhookEndpoint) bool { return ep.ID == id })
+	if i < 0 {
+		return apperr.ErrNotFound
+	}
+	r.endpoints = slices.Delete(r.endpoints, i, i+1)
+
+	return nil
+}
+
+type memoryWebhookDeliveryRepository struct {
+	mu         sync.RWMutex
+	deliveries []model.WebhookDelivery
+}
+
+// NewWebhookDeliveryRepository 建立以記憶體儲存的 WebhookDeliveryRepository
+func NewWebhookDeliveryRepository() itfc.WebhookDeliveryRepository {
+	return &memoryWebhookDeliveryRepository{}
+}
+
+func (r *memoryWebhookDeliveryRepository) Create(ctx context.Context, d *model.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 與 gormimpl 相同：UNIQUE (endpoint_id, event_id) WHERE redelivery_of IS NULL
+	if d.RedeliveryOf == nil {
+		for _, existing := range r.deliveries {
+			if existing.EndpointID == d.EndpointID && existing.EventID == d.EventID && existing.RedeliveryOf == nil {
+				return apperr.ErrConflict
+			}
+		}
+	}
+
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	if d.Status == "" {
+		d.Status = model.DeliveryPending
+	}
+	now := time.Now()
+	d.CreatedAt = &now
+	d.UpdatedAt = &now
+
+	r.deliveries = append(r.deliveries, *d)
+
+	return nil
+}
+
+func (r *memoryWebhookDeliveryRepository) Get(ctx context.Context, id uuid.UUID) (*model.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, d := range r.deliveries {
+		if d.ID == id {
+			return &d, nil
+		}
+	}
+
+	return nil, apperr.ErrNotFound
+}
+
+func (r *memoryWebhookDeliveryRepository) ListByEndpoint(ctx context.Context, endpointID uuid.UUID, after *pagination.Cursor, limit int) ([]model.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ds := []model.WebhookDelivery{}
+	for _, d := range r.deliveries {
+		if d.EndpointID != endpointID {
+			continue
+		}
+		if after != nil && compareKey(*d.CreatedAt, d.ID.String(), after.Time, after.ID) >= 0 {
+			continue
+		}
+		ds = append(ds, d)
+	}
+
+	// 與 gormimpl 相同：created_at DESC, id DESC
+	slices.SortFunc(ds, func(a, b model.WebhookDelivery) int {
+		return -compareKey(*a.CreatedAt, a.ID.String(), *b.CreatedAt, b.ID.String())
+	})
+	if limit > 0 && len(ds) > limit {
+		ds = ds[:limit]
+	}
+
+	return ds, nil
+}
+
+func (r *memoryWebhookDeliveryRepository) SaveAttempt(ctx context.Context, d *model.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.deliveries {
+		if r.deliveries[i].ID != d.ID {
+			continue
+		}
+		now := time.Now()
+		stored := &r.deliveries[i]
+		stored.Status = d.Status
+		stored.Attempts = d.Attempts
+		stored.ResponseStatus = d.ResponseStatus
+		stored.ResponseBody = d.ResponseBody
+		stored.Error = d.Error
+		stored.DurationMs = d.DurationMs
+		stored.LastAttemptAt = d.LastAttemptAt
+		stored.DeliveredAt = d.DeliveredAt
+		stored.UpdatedAt = &now
+		return nil
+	}
+
+	return apperr.ErrNotFound
+}
+
+func (r *memoryWebhookDeliveryRepository) DeleteByEndpoint(ctx context.Context, endpointID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deliveries = slices.DeleteFunc(r.deliveries, func(d model.WebhookDelivery) bool {
+		return d.EndpointID == endpointID
+	})
+
+	return nil
+}
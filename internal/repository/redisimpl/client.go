@@ -0,0 +1,47 @@
+// Package redisimpl 以 Redis 實作需要在多個程序間共用、但不需要保存在 Postgres 的 Repository (例如限流)。
+//
+// 連線使用 github.com/redis/go-redis/v9，可以連線到 Redis 或相容 RESP 協定的伺服器 (例如 Valkey、KeyDB)。
+package redisimpl
+
+import (
+	"chatsheet/config"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Dial 解析 redis:// (或 TLS 的 rediss://) URL 建立連線池，並以 PING 確認可以連線
+// URL 中的使用者、密碼與資料庫編號用於 AUTH 與 SELECT
+func Dial(cfg config.RedisConfig) (*redis.Client, error) {
+	if cfg.URL == "" {
+		cfg.URL = "redis://localhost:6379/0"
+	}
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = 10
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+
+	opts, err := redis.ParseURL(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("redis: invalid url: %w", err)
+	}
+	opts.PoolSize = cfg.PoolSize
+	opts.DialTimeout = cfg.Timeout
+	opts.ReadTimeout = cfg.Timeout
+	opts.WriteTimeout = cfg.Timeout
+	// miniredis 與較舊的伺服器不支援 CLIENT SETINFO
+	opts.DisableIdentity = true
+
+	client := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	return client, nil
+}
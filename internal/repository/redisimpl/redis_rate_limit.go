@@ -0,0 +1,132 @@
+package redisimpl
+
+import (
+	"chatsheet/internal/itfc"
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript 在 Redis 中以單一指令完成 itfc.TokenBucket.Take，同一個 key 的 Take 因此依序執行
+// bucket 以 hash 保存 (tokens、ts 為微秒)，補滿的時間到期後由 Redis 刪除
+// Lua 的數字回傳給客戶端時會被截斷為整數，因此剩下的 token 以字串回傳；
+// tostring 只保留 14 位有效數字，時間直接保存傳入的字串
+//
+// KEYS[1] bucket 的 key；ARGV[1] capacity、ARGV[2] 每秒補充的數量、ARGV[3] 現在時間 (微秒)
+const takeScript = `
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+
+local elapsed = (now - ts) / 1000000
+if elapsed > 0 then
+	tokens = math.min(capacity, tokens + elapsed * rate)
+end
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+local reset = math.ceil((capacity - tokens) / rate * 1000)
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', ARGV[3])
+redis.call('PEXPIRE', KEYS[1], math.max(reset, 1))
+return {allowed, tostring(tokens)}
+`
+
+// takeRedisScript 先以 SHA1 執行 (EVALSHA)，伺服器尚未載入 (例如重新啟動後) 時才送出完整的 script
+var takeRedisScript = redis.NewScript(takeScript)
+
+type redisRateLimitStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRateLimitStore 建立保存在 Redis 的 RateLimitStore，所有 key 加上 prefix
+func NewRateLimitStore(client redis.Cmdable, prefix string) itfc.RateLimitStore {
+	if prefix == "" {
+		prefix = "chatsheet:ratelimit:"
+	}
+	return &redisRateLimitStore{client: client, prefix: prefix}
+}
+
+func (s *redisRateLimitStore) Take(ctx context.Context, key string, bucket itfc.TokenBucket, now time.Time) (itfc.RateLimitResult, error) {
+	reply, err := takeRedisScript.Run(ctx, s.client, []string{s.prefix + key},
+		bucket.Capacity,
+		strconv.FormatFloat(bucket.Rate, 'g', -1, 64),
+		strconv.FormatInt(now.UnixMicro(), 10),
+	).Result()
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to take rate limit token", "error", err)
+		return itfc.RateLimitResult{}, err
+	}
+
+	allowed, tokens, err := parseTakeReply(reply)
+	if err != nil {
+		return itfc.RateLimitResult{}, err
+	}
+	return bucket.Result(tokens, allowed), nil
+}
+
+// Peek 讀取 bucket 並在本地計算，與 takeScript 相同：沒有 tokens 或 ts 時視為補滿的 bucket
+func (s *redisRateLimitStore) Peek(ctx context.Context, key string, bucket itfc.TokenBucket, now time.Time) (itfc.RateLimitResult, error) {
+	items, err := s.client.HMGet(ctx, s.prefix+key, "tokens", "ts").Result()
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get rate limit bucket", "error", err)
+		return itfc.RateLimitResult{}, err
+	}
+
+	tokens, updatedAt := float64(bucket.Capacity), now
+	rawTokens, okTokens := items[0].(string)
+	rawTS, okTS := items[1].(string)
+	if okTokens && okTS {
+		t, errTokens := strconv.ParseFloat(rawTokens, 64)
+		ts, errTS := strconv.ParseInt(rawTS, 10, 64)
+		if errTokens != nil || errTS != nil {
+			return itfc.RateLimitResult{}, fmt.Errorf("redis: unexpected rate limit bucket %q %q", rawTokens, rawTS)
+		}
+		tokens, updatedAt = t, time.UnixMicro(ts)
+	}
+
+	_, res := bucket.Take(tokens, updatedAt, now)
+	return res, nil
+}
+
+// DeleteExpired 不需要做任何事，Redis 會在補滿時刪除 bucket
+func (s *redisRateLimitStore) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+// parseTakeReply 解析 takeScript 回傳的 {allowed, tokens}
+func parseTakeReply(reply any) (bool, float64, error) {
+	items, ok := reply.([]any)
+	if !ok || len(items) != 2 {
+		return false, 0, fmt.Errorf("redis: unexpected rate limit reply %v", reply)
+	}
+	allowed, ok := items[0].(int64)
+	if !ok {
+		return false, 0, fmt.Errorf("redis: unexpected rate limit reply %v", reply)
+	}
+	raw, ok := items[1].(string)
+	if !ok {
+		return false, 0, fmt.Errorf("redis: unexpected rate limit reply %v", reply)
+	}
+	tokens, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("redis: unexpected rate limit reply %v", reply)
+	}
+	return allowed == 1, tokens, nil
+}
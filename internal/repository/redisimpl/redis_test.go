@@ -0,0 +1,107 @@
+package redisimpl
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/repository/repotest"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func dialTest(t *testing.T, url string) *redis.Client {
+	t.Helper()
+
+	c, err := Dial(config.RedisConfig{URL: url, Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("Dial(%s): %v", url, err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestDialAuthAndDatabase(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	mr.RequireAuth("secret")
+
+	if _, err := Dial(config.RedisConfig{URL: "redis://" + mr.Addr(), Timeout: time.Second}); err == nil {
+		t.Error("Dial without the password succeeded")
+	}
+	if _, err := Dial(config.RedisConfig{URL: "redis://:wrong@" + mr.Addr(), Timeout: time.Second}); err == nil {
+		t.Error("Dial with a wrong password succeeded")
+	}
+
+	c := dialTest(t, "redis://:secret@"+mr.Addr()+"/2")
+	if err := c.Set(ctx, "k", "v", 0).Err(); err != nil {
+		t.Fatalf("SET: %v", err)
+	}
+	if v, err := mr.DB(2).Get("k"); err != nil || v != "v" {
+		t.Errorf("db 2 k = %q, %v, want the key in the selected database", v, err)
+	}
+	if mr.DB(0).Exists("k") {
+		t.Error("key written to db 0")
+	}
+
+	mr.RequireUserAuth("alice", "alice-secret")
+	c = dialTest(t, "redis://alice:alice-secret@"+mr.Addr())
+	if reply, err := c.Ping(ctx).Result(); err != nil || reply != "PONG" {
+		t.Errorf("PING as alice = %v, %v", reply, err)
+	}
+}
+
+func TestDialInvalidURL(t *testing.T) {
+	for _, url := range []string{"http://localhost:6379", "redis://localhost:6379/abc", "redis://%zz"} {
+		if _, err := Dial(config.RedisConfig{URL: url, Timeout: time.Second}); err == nil {
+			t.Errorf("Dial(%s) succeeded", url)
+		}
+	}
+}
+
+func TestRateLimitStore(t *testing.T) {
+	repotest.RunRateLimitStore(t, func(t *testing.T) itfc.RateLimitStore {
+		mr := miniredis.RunT(t)
+		return NewRateLimitStore(dialTest(t, "redis://"+mr.Addr()), "")
+	})
+}
+
+func TestRateLimitStoreScript(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	c := dialTest(t, "redis://"+mr.Addr())
+	store := NewRateLimitStore(c, "test:")
+	bucket := itfc.TokenBucket{Capacity: 3, Rate: 1}
+	now := time.Now()
+
+	// 新的伺服器沒有 script，EVALSHA 回應 NOSCRIPT 後改送完整的 script
+	if res, err := store.Take(ctx, "k", bucket, now); err != nil || !res.Allowed || res.Remaining != 2 {
+		t.Fatalf("Take on a fresh server = %+v, %v", res, err)
+	}
+	if loaded, err := c.ScriptExists(ctx, takeRedisScript.Hash()).Result(); err != nil || !loaded[0] {
+		t.Errorf("SCRIPT EXISTS = %v, %v, want the script loaded", loaded, err)
+	}
+	if err := c.ScriptFlush(ctx).Err(); err != nil {
+		t.Fatalf("SCRIPT FLUSH: %v", err)
+	}
+	if res, err := store.Take(ctx, "k", bucket, now); err != nil || !res.Allowed || res.Remaining != 1 {
+		t.Fatalf("Take after SCRIPT FLUSH = %+v, %v", res, err)
+	}
+
+	// 剩下 1 個 token，補滿需要 2 秒，之後由 Redis 刪除
+	if ttl := mr.TTL("test:k"); ttl != 2*time.Second {
+		t.Errorf("TTL = %v, want 2s", ttl)
+	}
+	mr.FastForward(2 * time.Second)
+	if mr.Exists("test:k") {
+		t.Error("bucket still exists after it refilled")
+	}
+
+	// 無法解析的 bucket 回傳錯誤
+	mr.HSet("test:bad", "tokens", "x", "ts", "1")
+	if _, err := store.Peek(ctx, "bad", bucket, now); err == nil {
+		t.Error("Peek on a corrupted bucket succeeded")
+	}
+}
@@ -0,0 +1,2759 @@
+// Package repotest 提供所有 Repository 實作共用的一致性測試 (conformance suite)。
+//
+// 每一種實作 (gormimpl、memory…) 只需要在自己的測試中提供 Factory，
+// 呼叫 Run 即可證明其行為與其他實作一致：
+//
+//	func TestConformance(t *testing.T) {
+//		repotest.Run(t, func(t *testing.T) repotest.Repositories {
+//			messages, attendees := memory.NewMessageRepository(), memory.NewChatAttendeeRepository()
+//			campaigns, users := memory.NewCampaignRepository(), memory.NewUserRepository()
+//			return repotest.Repositories{
+//				Users:       users,
+//				Unipile:     memory.NewUnipileRepository(users), // 需要 Users 確認使用者存在
+//				Credentials: memory.NewCredentialRepository(),
+//				Idempotency: memory.NewIdempotencyRepository(),
+//				Audit:       memory.NewAuditRepository(),
+//				Messages:    messages,
+//				Chats:       memory.NewChatRepository(),
+//				Attendees:   attendees,
+//				Checkpoints: memory.NewSyncCheckpointRepository(),
+//				Search:      memory.NewSearchRepository(messages, attendees), // 搜尋 Messages 中的訊息與 Attendees 中的名稱
+//				Contacts:    memory.NewContactRepository(),
+//				Invitations: memory.NewInvitationRepository(),
+//				Campaigns:   campaigns,
+//				Enrollments: memory.NewEnrollmentRepository(campaigns), // 需要 Campaigns 中活動的狀態
+//				Quotas:      memory.NewQuotaRepository(),
+//				Templates:   memory.NewTemplateRepository(),
+//				Scheduled:   memory.NewScheduledMessageRepository(),
+//				Jobs:        memory.NewJobRepository(),
+//				TaskRuns:    memory.NewTaskRunRepository(),
+//				Locker:      memory.NewLocker(),
+//				Webhooks:    memory.NewWebhookEndpointRepository(),
+//				Deliveries:  memory.NewWebhookDeliveryRepository(),
+//				Transactor:  memory.NewTransactor(),
+//				Outbox:      memory.NewOutboxRepository(),
+//				RateLimits:  memory.NewRateLimitStore(),
+//			}
+//		})
+//	}
+//
+// 測試資料都使用隨機產生的 email 與 account_id，因此也可以在共用的 Postgres 上執行。
+package repotest
+
+import (
+	"bytes"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"chatsheet/internal/search"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Repositories 收集一種實作的所有 Repository
+// 新增 Repository 介面時，請在此加入欄位並補上對應的測試
+type Repositories struct {
+	Users       itfc.UserRepository
+	Unipile     itfc.UnipileRepository
+	Credentials itfc.CredentialRepository
+	Idempotency itfc.IdempotencyRepository
+	Audit       itfc.AuditRepository
+	Messages    itfc.MessageRepository
+	Chats       itfc.ChatRepository
+	Attendees   itfc.ChatAttendeeRepository
+	Checkpoints itfc.SyncCheckpointRepository
+	Search      itfc.SearchRepository
+	Contacts    itfc.ContactRepository
+	Invitations itfc.InvitationRepository
+	Campaigns   itfc.CampaignRepository
+	Enrollments itfc.EnrollmentRepository
+	Quotas      itfc.QuotaRepository
+	Templates   itfc.TemplateRepository
+	Scheduled   itfc.ScheduledMessageRepository
+	Jobs        itfc.JobRepository
+	TaskRuns    itfc.TaskRunRepository
+	Locker      itfc.Locker
+	Webhooks    itfc.WebhookEndpointRepository
+	Deliveries  itfc.WebhookDeliveryRepository
+	Transactor  itfc.Transactor
+	Outbox      itfc.OutboxRepository
+	RateLimits  itfc.RateLimitStore
+}
+
+// Factory 為每個子測試建立一組 Repository
+type Factory func(t *testing.T) Repositories
+
+// RunRateLimitStore 只執行 RateLimitStore 的一致性測試，用於只實作限流的 store (例如 Redis)
+func RunRateLimitStore(t *testing.T, newStore func(t *testing.T) itfc.RateLimitStore) {
+	testRateLimitStore(t, func(t *testing.T) Repositories {
+		return Repositories{RateLimits: newStore(t)}
+	})
+}
+
+// Run 執行完整的一致性測試
+func Run(t *testing.T, newRepos Factory) {
+	t.Run("UserRepository", func(t *testing.T) { testUserRepository(t, newRepos) })
+	t.Run("UnipileRepository", func(t *testing.T) { testUnipileRepository(t, newRepos) })
+	t.Run("CredentialRepository", func(t *testing.T) { testCredentialRepository(t, newRepos) })
+	t.Run("IdempotencyRepository", func(t *testing.T) { testIdempotencyRepository(t, newRepos) })
+	t.Run("AuditRepository", func(t *testing.T) { testAuditRepository(t, newRepos) })
+	t.Run("MessageRepository", func(t *testing.T) { testMessageRepository(t, newRepos) })
+	t.Run("ChatRepository", func(t *testing.T) { testChatRepository(t, newRepos) })
+	t.Run("ChatAttendeeRepository", func(t *testing.T) { testChatAttendeeRepository(t, newRepos) })
+	t.Run("SyncCheckpointRepository", func(t *testing.T) { testSyncCheckpointRepository(t, newRepos) })
+	t.Run("SearchRepository", func(t *testing.T) { testSearchRepository(t, newRepos) })
+	t.Run("ContactRepository", func(t *testing.T) { testContactRepository(t, newRepos) })
+	t.Run("InvitationRepository", func(t *testing.T) { testInvitationRepository(t, newRepos) })
+	t.Run("CampaignRepository", func(t *testing.T) { testCampaignRepository(t, newRepos) })
+	t.Run("EnrollmentRepository", func(t *testing.T) { testEnrollmentRepository(t, newRepos) })
+	t.Run("QuotaRepository", func(t *testing.T) { testQuotaRepository(t, newRepos) })
+	t.Run("TemplateRepository", func(t *testing.T) { testTemplateRepository(t, newRepos) })
+	t.Run("ScheduledMessageRepository", func(t *testing.T) { testScheduledMessageRepository(t, newRepos) })
+	t.Run("JobRepository", func(t *testing.T) { testJobRepository(t, newRepos) })
+	t.Run("TaskRunRepository", func(t *testing.T) { testTaskRunRepository(t, newRepos) })
+	t.Run("Locker", func(t *testing.T) { testLocker(t, newRepos) })
+	t.Run("WebhookEndpointRepository", func(t *testing.T) { testWebhookEndpointRepository(t, newRepos) })
+	t.Run("WebhookDeliveryRepository", func(t *testing.T) { testWebhookDeliveryRepository(t, newRepos) })
+	t.Run("OutboxRepository", func(t *testing.T) { testOutboxRepository(t, newRepos) })
+	t.Run("RateLimitStore", func(t *testing.T) { testRateLimitStore(t, newRepos) })
+}
+
+// randomEmail 產生不會與其他測試衝突的 email
+func randomEmail() string {
+	return "repotest-" + uuid.NewString() + "@example.com"
+}
+
+// mustCreateUser 建立測試用的使用者 (unipile_accounts.user_email 有外鍵約束)
+func mustCreateUser(t *testing.T, repo itfc.UserRepository, email string) *model.User {
+	t.Helper()
+
+	user, err := repo.Create(context.Background(), &model.User{Email: email, Password: "hashed"})
+	if err != nil {
+		t.Fatalf("Create user %q: %v", email, err)
+	}
+	return user
+}
+
+func testUserRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repo := newRepos(t).Users
+		email := randomEmail()
+
+		created := mustCreateUser(t, repo, email)
+		if created.Email != email {
+			t.Errorf("Create returned email %q, want %q", created.Email, email)
+		}
+
+		got, err := repo.GetByEmail(ctx, email)
+		if err != nil {
+			t.Fatalf("GetByEmail: %v", err)
+		}
+		if got.Email != email || got.Password != "hashed" {
+			t.Errorf("GetByEmail = %+v, want email %q with stored password", got, email)
+		}
+		if got.CreatedAt == nil || got.UpdatedAt == nil {
+			t.Errorf("GetByEmail timestamps not populated: %+v", got)
+		}
+	})
+
+	t.Run("DuplicateEmailConflict", func(t *testing.T) {
+		repo := newRepos(t).Users
+		email := randomEmail()
+		mustCreateUser(t, repo, email)
+
+		_, err := repo.Create(ctx, &model.User{Email: email, Password: "other"})
+		if !errors.Is(err, apperr.ErrConflict) {
+			t.Fatalf("Create duplicate email err = %v, want apperr.ErrConflict", err)
+		}
+	})
+
+	t.Run("GetByEmailNotFound", func(t *testing.T) {
+		repo := newRepos(t).Users
+
+		user, err := repo.GetByEmail(ctx, randomEmail())
+		if !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("GetByEmail unknown email err = %v, want apperr.ErrNotFound", err)
+		}
+		if user != nil {
+			t.Errorf("GetByEmail unknown email returned %+v, want nil", user)
+		}
+	})
+}
+
+func testUnipileRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	t.Run("CreateAssignsID", func(t *testing.T) {
+		repos := newRepos(t)
+		email := randomEmail()
+		mustCreateUser(t, repos.Users, email)
+
+		acct, err := repos.Unipile.Create(ctx, &model.UnipileAccount{
+			UserEmail: email,
+			Provider:  "linkedin",
+			AccountID: uuid.NewString(),
+		})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if acct.ID == uuid.Nil {
+			t.Errorf("Create did not assign an ID: %+v", acct)
+		}
+	})
+
+	t.Run("DuplicateAccountIDConflict", func(t *testing.T) {
+		repos := newRepos(t)
+		email, other := randomEmail(), randomEmail()
+		mustCreateUser(t, repos.Users, email)
+		mustCreateUser(t, repos.Users, other)
+		accountID := uuid.NewString()
+
+		if _, err := repos.Unipile.Create(ctx, &model.UnipileAccount{UserEmail: email, Provider: "linkedin", AccountID: accountID}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		// 即使是不同的使用者，account_id 也必須唯一
+		_, err := repos.Unipile.Create(ctx, &model.UnipileAccount{UserEmail: other, Provider: "linkedin", AccountID: accountID})
+		if !errors.Is(err, apperr.ErrConflict) {
+			t.Fatalf("Create duplicate account_id err = %v, want apperr.ErrConflict", err)
+		}
+	})
+
+	t.Run("UnknownUserNotFound", func(t *testing.T) {
+		repos := newRepos(t)
+		email := randomEmail()
+
+		// 對應 unipile_accounts.user_email 參照 users.email 的外鍵
+		_, err := repos.Unipile.Create(ctx, &model.UnipileAccount{UserEmail: email, Provider: "linkedin", AccountID: uuid.NewString()})
+		if !errors.Is(err, apperr.ErrNotFound) {
+			t.Errorf("Create for an unknown user err = %v, want apperr.ErrNotFound", err)
+		}
+		_, err = repos.Unipile.Upsert(ctx, &model.UnipileAccount{UserEmail: email, Provider: "linkedin", AccountID: uuid.NewString()})
+		if !errors.Is(err, apperr.ErrNotFound) {
+			t.Errorf("Upsert for an unknown user err = %v, want apperr.ErrNotFound", err)
+		}
+		if accts, err := repos.Unipile.ListByEmail(ctx, email); err != nil || len(accts) != 0 {
+			t.Errorf("ListByEmail = %+v, %v, want no accounts", accts, err)
+		}
+	})
+
+	t.Run("GetByAccountIDAndListAll", func(t *testing.T) {
+		repos := newRepos(t)
+		email := randomEmail()
+		mustCreateUser(t, repos.Users, email)
+		accountID := uuid.NewString()
+
+		if _, err := repos.Unipile.GetByAccountID(ctx, accountID); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("GetByAccountID before Create err = %v, want apperr.ErrNotFound", err)
+		}
+		if _, err := repos.Unipile.Create(ctx, &model.UnipileAccount{UserEmail: email, Provider: "linkedin", AccountID: accountID}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		acct, err := repos.Unipile.GetByAccountID(ctx, accountID)
+		if err != nil {
+			t.Fatalf("GetByAccountID: %v", err)
+		}
+		if acct.UserEmail != email {
+			t.Errorf("GetByAccountID user_email = %q, want %q", acct.UserEmail, email)
+		}
+
+		accts, err := repos.Unipile.ListAll(ctx)
+		if err != nil {
+			t.Fatalf("ListAll: %v", err)
+		}
+		found := false
+		for _, a := range accts {
+			found = found || a.AccountID == accountID
+		}
+		if !found {
+			t.Errorf("ListAll did not return account %s", accountID)
+		}
+	})
+
+	t.Run("ListByEmail", func(t *testing.T) {
+		repos := newRepos(t)
+		email, other := randomEmail(), randomEmail()
+		mustCreateUser(t, repos.Users, email)
+		mustCreateUser(t, repos.Users, other)
+
+		want := map[string]bool{uuid.NewString(): true, uuid.NewString(): true}
+		for accountID := range want {
+			if _, err := repos.Unipile.Create(ctx, &model.UnipileAccount{UserEmail: email, Provider: "linkedin", AccountID: accountID}); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+		}
+		if _, err := repos.Unipile.Create(ctx, &model.UnipileAccount{UserEmail: other, Provider: "linkedin", AccountID: uuid.NewString()}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		accts, err := repos.Unipile.ListByEmail(ctx, email)
+		if err != nil {
+			t.Fatalf("ListByEmail: %v", err)
+		}
+		if len(accts) != len(want) {
+			t.Fatalf("ListByEmail returned %d accounts, want %d", len(accts), len(want))
+		}
+		for _, a := range accts {
+			if a.UserEmail != email || !want[a.AccountID] {
+				t.Errorf("ListByEmail returned unexpected account %+v", a)
+			}
+		}
+	})
+
+	t.Run("UpsertSameUser", func(t *testing.T) {
+		repos := newRepos(t)
+		email := randomEmail()
+		mustCreateUser(t, repos.Users, email)
+		accountID := uuid.NewString()
+
+		first, err := repos.Unipile.Upsert(ctx, &model.UnipileAccount{UserEmail: email, Provider: "linkedin", AccountID: accountID})
+		if err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+		second, err := repos.Unipile.Upsert(ctx, &model.UnipileAccount{UserEmail: email, Provider: "linkedin", AccountID: accountID})
+		if err != nil {
+			t.Fatalf("Upsert same user again: %v", err)
+		}
+		if second.ID != first.ID {
+			t.Errorf("Upsert same user changed ID from %s to %s", first.ID, second.ID)
+		}
+
+		accts, err := repos.Unipile.ListByEmail(ctx, email)
+		if err != nil {
+			t.Fatalf("ListByEmail: %v", err)
+		}
+		if len(accts) != 1 {
+			t.Errorf("ListByEmail after re-link returned %d accounts, want 1", len(accts))
+		}
+	})
+
+	t.Run("UpsertOtherUserConflict", func(t *testing.T) {
+		repos := newRepos(t)
+		email, other := randomEmail(), randomEmail()
+		mustCreateUser(t, repos.Users, email)
+		mustCreateUser(t, repos.Users, other)
+		accountID := uuid.NewString()
+
+		if _, err := repos.Unipile.Upsert(ctx, &model.UnipileAccount{UserEmail: email, Provider: "linkedin", AccountID: accountID}); err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+
+		_, err := repos.Unipile.Upsert(ctx, &model.UnipileAccount{UserEmail: other, Provider: "linkedin", AccountID: accountID})
+		if !errors.Is(err, apperr.ErrConflict) {
+			t.Fatalf("Upsert account_id owned by another user err = %v, want apperr.ErrConflict", err)
+		}
+
+		accts, err := repos.Unipile.ListByEmail(ctx, other)
+		if err != nil {
+			t.Fatalf("ListByEmail: %v", err)
+		}
+		if len(accts) != 0 {
+			t.Errorf("ListByEmail for rejected user returned %d accounts, want 0", len(accts))
+		}
+	})
+
+	t.Run("DeleteByAccountID", func(t *testing.T) {
+		repos := newRepos(t)
+		email, other := randomEmail(), randomEmail()
+		mustCreateUser(t, repos.Users, email)
+		mustCreateUser(t, repos.Users, other)
+		accountID := uuid.NewString()
+
+		if _, err := repos.Unipile.Create(ctx, &model.UnipileAccount{UserEmail: email, Provider: "linkedin", AccountID: accountID}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		// 其他使用者不能刪除
+		if err := repos.Unipile.DeleteByAccountID(ctx, other, accountID); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("DeleteByAccountID by another user err = %v, want apperr.ErrNotFound", err)
+		}
+
+		if err := repos.Unipile.DeleteByAccountID(ctx, email, accountID); err != nil {
+			t.Fatalf("DeleteByAccountID: %v", err)
+		}
+		accts, err := repos.Unipile.ListByEmail(ctx, email)
+		if err != nil {
+			t.Fatalf("ListByEmail: %v", err)
+		}
+		if len(accts) != 0 {
+			t.Errorf("ListByEmail after delete returned %d accounts, want 0", len(accts))
+		}
+
+		if err := repos.Unipile.DeleteByAccountID(ctx, email, accountID); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("DeleteByAccountID twice err = %v, want apperr.ErrNotFound", err)
+		}
+	})
+
+	t.Run("ListByEmailEmpty", func(t *testing.T) {
+		repos := newRepos(t)
+
+		accts, err := repos.Unipile.ListByEmail(ctx, randomEmail())
+		if err != nil {
+			t.Fatalf("ListByEmail: %v", err)
+		}
+		if accts == nil || len(accts) != 0 {
+			t.Errorf("ListByEmail unknown email = %#v, want empty non-nil slice", accts)
+		}
+	})
+
+	t.Run("UpdateStatus", func(t *testing.T) {
+		repos := newRepos(t)
+		email := randomEmail()
+		mustCreateUser(t, repos.Users, email)
+		accountID := uuid.NewString()
+		if _, err := repos.Unipile.Create(ctx, &model.UnipileAccount{UserEmail: email, Provider: "linkedin", AccountID: accountID, Status: "OK"}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		checkedAt := time.Now().UTC().Truncate(time.Microsecond)
+		if err := repos.Unipile.UpdateStatus(ctx, accountID, "CREDENTIALS", checkedAt); err != nil {
+			t.Fatalf("UpdateStatus: %v", err)
+		}
+		got, err := repos.Unipile.GetByAccountID(ctx, accountID)
+		if err != nil {
+			t.Fatalf("GetByAccountID: %v", err)
+		}
+		if got.Status != "CREDENTIALS" || got.StatusCheckedAt == nil || !got.StatusCheckedAt.Equal(checkedAt) {
+			t.Errorf("GetByAccountID = %+v, want the updated status", got)
+		}
+
+		// 重新連結時以新的狀態覆寫
+		if _, err := repos.Unipile.Upsert(ctx, &model.UnipileAccount{UserEmail: email, Provider: "linkedin", AccountID: accountID, Status: "OK", StatusCheckedAt: &checkedAt}); err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+		if got, _ := repos.Unipile.GetByAccountID(ctx, accountID); got == nil || got.Status != "OK" {
+			t.Errorf("GetByAccountID after Upsert = %+v, want status OK", got)
+		}
+
+		if err := repos.Unipile.UpdateStatus(ctx, uuid.NewString(), "OK", checkedAt); !errors.Is(err, apperr.ErrNotFound) {
+			t.Errorf("UpdateStatus missing err = %v, want apperr.ErrNotFound", err)
+		}
+	})
+}
+
+func testCredentialRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	newCredential := func(email string, version int) *model.UnipileCredential {
+		return &model.UnipileCredential{
+			UserEmail:  email,
+			AccountID:  uuid.NewString(),
+			KeyVersion: version,
+			WrappedKey: []byte("wrapped-" + uuid.NewString()),
+			Ciphertext: []byte("ciphertext-" + uuid.NewString()),
+		}
+	}
+
+	t.Run("UpsertAndGet", func(t *testing.T) {
+		repos := newRepos(t)
+		email := randomEmail()
+		mustCreateUser(t, repos.Users, email)
+		want := newCredential(email, 1)
+
+		created, err := repos.Credentials.Upsert(ctx, want)
+		if err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+		if created.ID == uuid.Nil {
+			t.Errorf("Upsert did not assign an ID: %+v", created)
+		}
+
+		got, err := repos.Credentials.GetByAccountID(ctx, want.AccountID)
+		if err != nil {
+			t.Fatalf("GetByAccountID: %v", err)
+		}
+		if got.KeyVersion != 1 || !bytes.Equal(got.WrappedKey, want.WrappedKey) || !bytes.Equal(got.Ciphertext, want.Ciphertext) {
+			t.Errorf("GetByAccountID = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("UpsertOverwrites", func(t *testing.T) {
+		repos := newRepos(t)
+		email := randomEmail()
+		mustCreateUser(t, repos.Users, email)
+		first := newCredential(email, 1)
+
+		created, err := repos.Credentials.Upsert(ctx, first)
+		if err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+
+		second := newCredential(email, 2)
+		second.AccountID = first.AccountID
+		updated, err := repos.Credentials.Upsert(ctx, second)
+		if err != nil {
+			t.Fatalf("Upsert existing account_id: %v", err)
+		}
+		if updated.ID != created.ID {
+			t.Errorf("Upsert existing account_id changed ID from %s to %s", created.ID, updated.ID)
+		}
+
+		got, err := repos.Credentials.GetByAccountID(ctx, first.AccountID)
+		if err != nil {
+			t.Fatalf("GetByAccountID: %v", err)
+		}
+		if got.KeyVersion != 2 || !bytes.Equal(got.Ciphertext, second.Ciphertext) {
+			t.Errorf("GetByAccountID after overwrite = %+v, want %+v", got, second)
+		}
+	})
+
+	t.Run("UpsertOtherOwnerConflicts", func(t *testing.T) {
+		repos := newRepos(t)
+		owner, other := randomEmail(), randomEmail()
+		mustCreateUser(t, repos.Users, owner)
+		mustCreateUser(t, repos.Users, other)
+		first := newCredential(owner, 1)
+		if _, err := repos.Credentials.Upsert(ctx, first); err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+
+		// 其他使用者不能覆寫同一個 account_id 的憑證
+		stolen := newCredential(other, 1)
+		stolen.AccountID = first.AccountID
+		if _, err := repos.Credentials.Upsert(ctx, stolen); !errors.Is(err, apperr.ErrConflict) {
+			t.Fatalf("Upsert by another user err = %v, want apperr.ErrConflict", err)
+		}
+
+		got, err := repos.Credentials.GetByAccountID(ctx, first.AccountID)
+		if err != nil {
+			t.Fatalf("GetByAccountID: %v", err)
+		}
+		if got.UserEmail != owner || !bytes.Equal(got.Ciphertext, first.Ciphertext) {
+			t.Errorf("GetByAccountID after conflicting upsert = %+v, want %+v", got, first)
+		}
+	})
+
+	t.Run("GetByAccountIDNotFound", func(t *testing.T) {
+		repos := newRepos(t)
+
+		_, err := repos.Credentials.GetByAccountID(ctx, uuid.NewString())
+		if !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("GetByAccountID unknown account err = %v, want apperr.ErrNotFound", err)
+		}
+	})
+
+	t.Run("RewrapByKeyVersion", func(t *testing.T) {
+		repos := newRepos(t)
+		email := randomEmail()
+		mustCreateUser(t, repos.Users, email)
+
+		// 使用極大的版本號，避免在共用資料庫上與其他資料衝突
+		const oldVersion, newVersion = 900001, 900002
+		old := newCredential(email, oldVersion)
+		if _, err := repos.Credentials.Upsert(ctx, old); err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+
+		creds, err := repos.Credentials.ListByKeyVersionNot(ctx, newVersion, 1000)
+		if err != nil {
+			t.Fatalf("ListByKeyVersionNot: %v", err)
+		}
+		var found *model.UnipileCredential
+		for i := range creds {
+			if creds[i].AccountID == old.AccountID {
+				found = &creds[i]
+			}
+		}
+		if found == nil {
+			t.Fatalf("ListByKeyVersionNot(%d) did not include version %d credential", newVersion, oldVersion)
+		}
+
+		if err := repos.Credentials.UpdateWrappedKey(ctx, found.ID, newVersion, []byte("rewrapped")); err != nil {
+			t.Fatalf("UpdateWrappedKey: %v", err)
+		}
+
+		got, err := repos.Credentials.GetByAccountID(ctx, old.AccountID)
+		if err != nil {
+			t.Fatalf("GetByAccountID: %v", err)
+		}
+		if got.KeyVersion != newVersion || string(got.WrappedKey) != "rewrapped" || !bytes.Equal(got.Ciphertext, old.Ciphertext) {
+			t.Errorf("GetByAccountID after UpdateWrappedKey = %+v", got)
+		}
+
+		creds, err = repos.Credentials.ListByKeyVersionNot(ctx, newVersion, 1000)
+		if err != nil {
+			t.Fatalf("ListByKeyVersionNot: %v", err)
+		}
+		for _, c := range creds {
+			if c.AccountID == old.AccountID {
+				t.Errorf("ListByKeyVersionNot(%d) still includes rewrapped credential", newVersion)
+			}
+		}
+	})
+
+	t.Run("DeleteByAccountID", func(t *testing.T) {
+		repos := newRepos(t)
+		email := randomEmail()
+		mustCreateUser(t, repos.Users, email)
+		cred := newCredential(email, 1)
+		if _, err := repos.Credentials.Upsert(ctx, cred); err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+
+		if err := repos.Credentials.DeleteByAccountID(ctx, cred.AccountID); err != nil {
+			t.Fatalf("DeleteByAccountID: %v", err)
+		}
+		if _, err := repos.Credentials.GetByAccountID(ctx, cred.AccountID); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("GetByAccountID after delete err = %v, want apperr.ErrNotFound", err)
+		}
+
+		// 不存在時不回傳錯誤
+		if err := repos.Credentials.DeleteByAccountID(ctx, cred.AccountID); err != nil {
+			t.Fatalf("DeleteByAccountID twice: %v", err)
+		}
+	})
+
+	t.Run("UpdateWrappedKeyNotFound", func(t *testing.T) {
+		repos := newRepos(t)
+
+		err := repos.Credentials.UpdateWrappedKey(ctx, uuid.New(), 1, []byte("x"))
+		if !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("UpdateWrappedKey unknown id err = %v, want apperr.ErrNotFound", err)
+		}
+	})
+}
+
+func testIdempotencyRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	newKey := func(email string) *model.IdempotencyKey {
+		return &model.IdempotencyKey{
+			UserEmail:   email,
+			Key:         uuid.NewString(),
+			Fingerprint: "fingerprint",
+			Status:      model.IdempotencyInProgress,
+			ExpiresAt:   time.Now().Add(time.Hour),
+		}
+	}
+
+	t.Run("CreateCompleteGet", func(t *testing.T) {
+		repos := newRepos(t)
+		email := randomEmail()
+		rec := newKey(email)
+
+		created, err := repos.Idempotency.Create(ctx, rec)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if created.ID == uuid.Nil {
+			t.Errorf("Create did not assign an ID: %+v", created)
+		}
+
+		if err := repos.Idempotency.Complete(ctx, created.ID, 201, "application/json", []byte(`{"ok":true}`)); err != nil {
+			t.Fatalf("Complete: %v", err)
+		}
+
+		got, err := repos.Idempotency.Get(ctx, email, rec.Key)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if got.Status != model.IdempotencyCompleted || got.ResponseCode != 201 ||
+			got.ContentType != "application/json" || string(got.ResponseBody) != `{"ok":true}` || got.Fingerprint != "fingerprint" {
+			t.Errorf("Get after Complete = %+v", got)
+		}
+	})
+
+	t.Run("DuplicateKeyConflict", func(t *testing.T) {
+		repos := newRepos(t)
+		email := randomEmail()
+		rec := newKey(email)
+		if _, err := repos.Idempotency.Create(ctx, rec); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		dup := newKey(email)
+		dup.Key = rec.Key
+		if _, err := repos.Idempotency.Create(ctx, dup); !errors.Is(err, apperr.ErrConflict) {
+			t.Fatalf("Create duplicate key err = %v, want apperr.ErrConflict", err)
+		}
+
+		// 不同使用者可以使用相同的 key
+		other := newKey(randomEmail())
+		other.Key = rec.Key
+		if _, err := repos.Idempotency.Create(ctx, other); err != nil {
+			t.Fatalf("Create same key for another user: %v", err)
+		}
+	})
+
+	t.Run("DeleteReleasesKey", func(t *testing.T) {
+		repos := newRepos(t)
+		email := randomEmail()
+		rec := newKey(email)
+		created, err := repos.Idempotency.Create(ctx, rec)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		if err := repos.Idempotency.Delete(ctx, created.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := repos.Idempotency.Get(ctx, email, rec.Key); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("Get after Delete err = %v, want apperr.ErrNotFound", err)
+		}
+
+		again := newKey(email)
+		again.Key = rec.Key
+		if _, err := repos.Idempotency.Create(ctx, again); err != nil {
+			t.Fatalf("Create after Delete: %v", err)
+		}
+	})
+
+	t.Run("CompleteNotFound", func(t *testing.T) {
+		repos := newRepos(t)
+
+		err := repos.Idempotency.Complete(ctx, uuid.New(), 200, "application/json", nil)
+		if !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("Complete unknown id err = %v, want apperr.ErrNotFound", err)
+		}
+	})
+
+	t.Run("DeleteExpired", func(t *testing.T) {
+		repos := newRepos(t)
+		email := randomEmail()
+		expired := newKey(email)
+		expired.ExpiresAt = time.Now().Add(-time.Minute)
+		if _, err := repos.Idempotency.Create(ctx, expired); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		fresh := newKey(email)
+		if _, err := repos.Idempotency.Create(ctx, fresh); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		// 共用的資料庫可能有其他測試過期的 key，只檢查這個測試建立的
+		n, err := repos.Idempotency.DeleteExpired(ctx, time.Now())
+		if err != nil {
+			t.Fatalf("DeleteExpired: %v", err)
+		}
+		if n < 1 {
+			t.Errorf("DeleteExpired = %d, want at least the expired key", n)
+		}
+		if _, err := repos.Idempotency.Get(ctx, email, expired.Key); !errors.Is(err, apperr.ErrNotFound) {
+			t.Errorf("Get expired key err = %v, want apperr.ErrNotFound", err)
+		}
+		if _, err := repos.Idempotency.Get(ctx, email, fresh.Key); err != nil {
+			t.Errorf("Get fresh key: %v", err)
+		}
+	})
+}
+
+func testAuditRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	appendEvents := func(t *testing.T, repo itfc.AuditRepository, email string, n int) {
+		t.Helper()
+		base := time.Now().UTC().Truncate(time.Microsecond)
+		for i := 0; i < n; i++ {
+			err := repo.Append(ctx, &model.AuditEvent{
+				ActorEmail: email,
+				Action:     model.AuditUserLogin,
+				TargetType: "user",
+				TargetID:   email,
+				Result:     model.AuditSuccess,
+				// 最後兩筆時間相同，確認以 id 作為次要排序
+				CreatedAt: base.Add(time.Duration(min(i, n-2)) * time.Second),
+			})
+			if err != nil {
+				t.Fatalf("Append: %v", err)
+			}
+		}
+	}
+
+	t.Run("ListPaginates", func(t *testing.T) {
+		repos := newRepos(t)
+		email := randomEmail()
+		appendEvents(t, repos.Audit, email, 5)
+		appendEvents(t, repos.Audit, randomEmail(), 2)
+
+		seen := map[uuid.UUID]bool{}
+		var after *pagination.Cursor
+		var prev *model.AuditEvent
+		for page := 0; ; page++ {
+			events, err := repos.Audit.List(ctx, itfc.AuditFilter{ActorEmail: email, After: after, Limit: 2})
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			for i := range events {
+				e := events[i]
+				if e.ActorEmail != email {
+					t.Errorf("List returned event of another actor: %+v", e)
+				}
+				if seen[e.ID] {
+					t.Errorf("List returned event %s twice", e.ID)
+				}
+				seen[e.ID] = true
+				if prev != nil && (e.CreatedAt.After(prev.CreatedAt) ||
+					(e.CreatedAt.Equal(prev.CreatedAt) && e.ID.String() > prev.ID.String())) {
+					t.Errorf("List not ordered by (created_at, id) DESC: %+v after %+v", e, *prev)
+				}
+				prev = &e
+			}
+			if len(events) < 2 {
+				break
+			}
+			last := events[len(events)-1]
+			after = &pagination.Cursor{Time: last.CreatedAt, ID: last.ID.String()}
+			if page > 5 {
+				t.Fatal("List did not terminate")
+			}
+		}
+		if len(seen) != 5 {
+			t.Errorf("List paged through %d events, want 5", len(seen))
+		}
+	})
+
+	t.Run("ListAllActors", func(t *testing.T) {
+		repos := newRepos(t)
+		a, b := randomEmail(), randomEmail()
+		appendEvents(t, repos.Audit, a, 2)
+		appendEvents(t, repos.Audit, b, 2)
+
+		events, err := repos.Audit.List(ctx, itfc.AuditFilter{Limit: 1000})
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		count := map[string]int{}
+		for _, e := range events {
+			count[e.ActorEmail]++
+		}
+		if count[a] != 2 || count[b] != 2 {
+			t.Errorf("List without actor returned %v, want 2 events each for %s and %s", count, a, b)
+		}
+	})
+}
+
+func testMessageRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	newMessage := func(chatID string, sentAt time.Time) *model.Message {
+		return &model.Message{
+			AccountID:       "acc-" + uuid.NewString(),
+			ChatID:          chatID,
+			UnipileID:       "msg-" + uuid.NewString(),
+			SenderID:        "me",
+			Text:            "hello",
+			IsSender:        true,
+			AttachmentNames: model.StringList{"a.pdf"},
+			SentAt:          sentAt,
+		}
+	}
+
+	t.Run("CreateDuplicateUnipileID", func(t *testing.T) {
+		repos := newRepos(t)
+		msg := newMessage("chat-"+uuid.NewString(), time.Now().UTC())
+		if _, err := repos.Messages.Create(ctx, msg); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		dup := newMessage(msg.ChatID, time.Now().UTC())
+		dup.UnipileID = msg.UnipileID
+		if _, err := repos.Messages.Create(ctx, dup); !errors.Is(err, apperr.ErrConflict) {
+			t.Errorf("Create duplicate unipile_id: got %v, want ErrConflict", err)
+		}
+	})
+
+	t.Run("ListByChatSince", func(t *testing.T) {
+		repos := newRepos(t)
+		chatID := "chat-" + uuid.NewString()
+		base := time.Now().UTC().Truncate(time.Microsecond)
+		for i := 0; i < 3; i++ {
+			if _, err := repos.Messages.Create(ctx, newMessage(chatID, base.Add(time.Duration(i)*time.Minute))); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+		}
+		if _, err := repos.Messages.Create(ctx, newMessage("chat-"+uuid.NewString(), base.Add(time.Hour))); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		msgs, err := repos.Messages.ListByChatSince(ctx, chatID, base.Add(time.Minute))
+		if err != nil {
+			t.Fatalf("ListByChatSince: %v", err)
+		}
+		if len(msgs) != 2 {
+			t.Fatalf("ListByChatSince returned %d messages, want 2", len(msgs))
+		}
+		if !msgs[0].SentAt.After(msgs[1].SentAt) {
+			t.Errorf("ListByChatSince not ordered by sent_at DESC: %v, %v", msgs[0].SentAt, msgs[1].SentAt)
+		}
+		if len(msgs[0].AttachmentNames) != 1 || msgs[0].AttachmentNames[0] != "a.pdf" {
+			t.Errorf("AttachmentNames = %v, want [a.pdf]", msgs[0].AttachmentNames)
+		}
+	})
+
+	t.Run("UpsertKeepsSentByEmail", func(t *testing.T) {
+		repos := newRepos(t)
+		msg := newMessage("chat-"+uuid.NewString(), time.Now().UTC().Truncate(time.Microsecond))
+		msg.SentByEmail = randomEmail()
+		if _, err := repos.Messages.Create(ctx, msg); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		// 同步回來的同一則訊息沒有 sent_by_email
+		synced := newMessage(msg.ChatID, msg.SentAt.Add(time.Second))
+		synced.UnipileID = msg.UnipileID
+		synced.Text = "hello (synced)"
+		if err := repos.Messages.Upsert(ctx, synced); err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+
+		msgs, err := repos.Messages.ListByChat(ctx, msg.ChatID, nil, 10)
+		if err != nil {
+			t.Fatalf("ListByChat: %v", err)
+		}
+		if len(msgs) != 1 {
+			t.Fatalf("ListByChat returned %d messages, want 1", len(msgs))
+		}
+		if msgs[0].Text != synced.Text || msgs[0].SentByEmail != msg.SentByEmail {
+			t.Errorf("Upsert result = %+v, want text %q and sent_by_email %q", msgs[0], synced.Text, msg.SentByEmail)
+		}
+	})
+
+	t.Run("ListFilters", func(t *testing.T) { testMessageFilter(t, newRepos) })
+
+	t.Run("ListByChatPaginates", func(t *testing.T) {
+		repos := newRepos(t)
+		chatID := "chat-" + uuid.NewString()
+		base := time.Now().UTC().Truncate(time.Microsecond)
+		for i := 0; i < 5; i++ {
+			// 最後兩筆時間相同，確認以 id 作為次要排序
+			if err := repos.Messages.Upsert(ctx, newMessage(chatID, base.Add(time.Duration(min(i, 3))*time.Second))); err != nil {
+				t.Fatalf("Upsert: %v", err)
+			}
+		}
+
+		seen := map[uuid.UUID]bool{}
+		var after *pagination.Cursor
+		for page := 0; page < 5; page++ {
+			msgs, err := repos.Messages.ListByChat(ctx, chatID, after, 2)
+			if err != nil {
+				t.Fatalf("ListByChat: %v", err)
+			}
+			for _, m := range msgs {
+				if seen[m.ID] {
+					t.Errorf("ListByChat returned message %s twice", m.ID)
+				}
+				seen[m.ID] = true
+			}
+			if len(msgs) < 2 {
+				break
+			}
+			last := msgs[len(msgs)-1]
+			after = &pagination.Cursor{Time: last.SentAt, ID: last.ID.String()}
+		}
+		if len(seen) != 5 {
+			t.Errorf("ListByChat paged through %d messages, want 5", len(seen))
+		}
+	})
+}
+
+func testMessageFilter(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+	repos := newRepos(t)
+	accountID := "acc-" + uuid.NewString()
+	chatA, chatB := "chat-"+uuid.NewString(), "chat-"+uuid.NewString()
+	base := time.Now().UTC().Truncate(time.Microsecond)
+
+	for i, m := range []struct {
+		chatID   string
+		senderID string
+		isSender bool
+	}{{chatA, "prov-self", true}, {chatA, "prov-a", false}, {chatA, "prov-self", true}, {chatB, "prov-b", false}} {
+		err := repos.Messages.Upsert(ctx, &model.Message{
+			AccountID: accountID,
+			ChatID:    m.chatID,
+			UnipileID: "msg-" + uuid.NewString(),
+			SenderID:  m.senderID,
+			IsSender:  m.isSender,
+			SentAt:    base.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+	}
+	if err := repos.Messages.Upsert(ctx, &model.Message{AccountID: "acc-" + uuid.NewString(), ChatID: "chat-" + uuid.NewString(), UnipileID: "msg-" + uuid.NewString(), SentAt: base}); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+
+	sent := true
+	for name, tc := range map[string]struct {
+		filter itfc.MessageFilter
+		want   int
+	}{
+		"Account":   {itfc.MessageFilter{AccountID: accountID}, 4},
+		"Chat":      {itfc.MessageFilter{AccountID: accountID, ChatID: chatA}, 3},
+		"Direction": {itfc.MessageFilter{AccountID: accountID, IsSender: &sent}, 2},
+		"Sender":    {itfc.MessageFilter{AccountID: accountID, SenderID: "prov-a"}, 1},
+		"Range":     {itfc.MessageFilter{AccountID: accountID, Since: base.Add(time.Minute), Until: base.Add(3 * time.Minute)}, 2},
+		"Limit":     {itfc.MessageFilter{AccountID: accountID, Limit: 3}, 3},
+	} {
+		t.Run(name, func(t *testing.T) {
+			msgs, err := repos.Messages.List(ctx, tc.filter)
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if len(msgs) != tc.want {
+				t.Errorf("List returned %d messages, want %d", len(msgs), tc.want)
+			}
+			for i := 1; i < len(msgs); i++ {
+				if msgs[i].SentAt.After(msgs[i-1].SentAt) {
+					t.Errorf("List not ordered by sent_at DESC")
+				}
+			}
+		})
+	}
+
+	// 以最後一筆作為游標取得下一頁
+	first, err := repos.Messages.List(ctx, itfc.MessageFilter{AccountID: accountID, Limit: 3})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	last := first[len(first)-1]
+	rest, err := repos.Messages.List(ctx, itfc.MessageFilter{AccountID: accountID, After: &pagination.Cursor{Time: last.SentAt, ID: last.ID.String()}, Limit: 3})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(rest) != 1 || !rest[0].SentAt.Equal(base) {
+		t.Errorf("List second page = %+v, want the oldest message", rest)
+	}
+}
+
+func testChatRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	newChat := func(accountID string, lastMessageAt time.Time) *model.Chat {
+		return &model.Chat{
+			AccountID:     accountID,
+			UnipileID:     "chat-" + uuid.NewString(),
+			Name:          "Bob",
+			LastMessageAt: lastMessageAt,
+		}
+	}
+
+	t.Run("UpsertKeepsCheckpoint", func(t *testing.T) {
+		repos := newRepos(t)
+		chat := newChat("acc-"+uuid.NewString(), time.Now().UTC().Truncate(time.Microsecond))
+		if err := repos.Chats.Upsert(ctx, chat); err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+		if err := repos.Chats.UpdateMessagesCheckpoint(ctx, chat.UnipileID, "cursor-1", true); err != nil {
+			t.Fatalf("UpdateMessagesCheckpoint: %v", err)
+		}
+
+		updated := newChat(chat.AccountID, chat.LastMessageAt.Add(time.Minute))
+		updated.UnipileID = chat.UnipileID
+		updated.Name = "Bob Updated"
+		updated.UnreadCount = 3
+		if err := repos.Chats.Upsert(ctx, updated); err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+
+		got, err := repos.Chats.GetByUnipileID(ctx, chat.UnipileID)
+		if err != nil {
+			t.Fatalf("GetByUnipileID: %v", err)
+		}
+		if got.Name != "Bob Updated" || got.UnreadCount != 3 || !got.LastMessageAt.Equal(updated.LastMessageAt) {
+			t.Errorf("Upsert did not update fields: %+v", got)
+		}
+		if got.MessagesCursor != "cursor-1" || !got.MessagesBackfilled {
+			t.Errorf("Upsert overwrote messages checkpoint: %+v", got)
+		}
+	})
+
+	t.Run("GetAndUpdateMissing", func(t *testing.T) {
+		repos := newRepos(t)
+		if _, err := repos.Chats.GetByUnipileID(ctx, "chat-"+uuid.NewString()); !errors.Is(err, apperr.ErrNotFound) {
+			t.Errorf("GetByUnipileID missing err = %v, want apperr.ErrNotFound", err)
+		}
+		if err := repos.Chats.UpdateMessagesCheckpoint(ctx, "chat-"+uuid.NewString(), "", true); !errors.Is(err, apperr.ErrNotFound) {
+			t.Errorf("UpdateMessagesCheckpoint missing err = %v, want apperr.ErrNotFound", err)
+		}
+	})
+
+	t.Run("ListByAccountAndNotBackfilled", func(t *testing.T) {
+		repos := newRepos(t)
+		accountID := "acc-" + uuid.NewString()
+		base := time.Now().UTC().Truncate(time.Microsecond)
+		var chats []*model.Chat
+		for i := 0; i < 3; i++ {
+			chat := newChat(accountID, base.Add(time.Duration(i)*time.Minute))
+			if err := repos.Chats.Upsert(ctx, chat); err != nil {
+				t.Fatalf("Upsert: %v", err)
+			}
+			chats = append(chats, chat)
+		}
+		if err := repos.Chats.Upsert(ctx, newChat("acc-"+uuid.NewString(), base)); err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+
+		first, err := repos.Chats.ListByAccount(ctx, accountID, nil, 2)
+		if err != nil {
+			t.Fatalf("ListByAccount: %v", err)
+		}
+		if len(first) != 2 || first[0].UnipileID != chats[2].UnipileID || first[1].UnipileID != chats[1].UnipileID {
+			t.Fatalf("ListByAccount first page = %+v, want newest two chats", first)
+		}
+		last := first[1]
+		rest, err := repos.Chats.ListByAccount(ctx, accountID, &pagination.Cursor{Time: last.LastMessageAt, ID: last.ID.String()}, 2)
+		if err != nil {
+			t.Fatalf("ListByAccount: %v", err)
+		}
+		if len(rest) != 1 || rest[0].UnipileID != chats[0].UnipileID {
+			t.Errorf("ListByAccount second page = %+v, want oldest chat", rest)
+		}
+
+		if err := repos.Chats.UpdateMessagesCheckpoint(ctx, chats[1].UnipileID, "", true); err != nil {
+			t.Fatalf("UpdateMessagesCheckpoint: %v", err)
+		}
+		pending, err := repos.Chats.ListNotBackfilled(ctx, accountID, 10)
+		if err != nil {
+			t.Fatalf("ListNotBackfilled: %v", err)
+		}
+		if len(pending) != 2 {
+			t.Errorf("ListNotBackfilled returned %d chats, want 2", len(pending))
+		}
+		for _, c := range pending {
+			if c.UnipileID == chats[1].UnipileID {
+				t.Errorf("ListNotBackfilled returned backfilled chat %s", c.UnipileID)
+			}
+		}
+	})
+}
+
+func testChatAttendeeRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	t.Run("UpsertAndListByChats", func(t *testing.T) {
+		repos := newRepos(t)
+		accountID := "acc-" + uuid.NewString()
+		chatA, chatB := "chat-"+uuid.NewString(), "chat-"+uuid.NewString()
+
+		for _, a := range []*model.ChatAttendee{
+			{AccountID: accountID, ChatID: chatA, ProviderID: "p1", Name: "Alice"},
+			{AccountID: accountID, ChatID: chatA, ProviderID: "p2", Name: "Bob"},
+			{AccountID: accountID, ChatID: chatA, ProviderID: "p2", Name: "Bob Updated"},
+			{AccountID: accountID, ChatID: chatB, ProviderID: "p1", Name: "Alice"},
+			{AccountID: accountID, ChatID: "chat-" + uuid.NewString(), ProviderID: "p3", Name: "Carol"},
+		} {
+			if err := repos.Attendees.Upsert(ctx, a); err != nil {
+				t.Fatalf("Upsert: %v", err)
+			}
+		}
+
+		attendees, err := repos.Attendees.ListByChats(ctx, []string{chatA, chatB})
+		if err != nil {
+			t.Fatalf("ListByChats: %v", err)
+		}
+		if len(attendees) != 3 {
+			t.Fatalf("ListByChats returned %d attendees, want 3", len(attendees))
+		}
+		for _, a := range attendees {
+			if a.ProviderID == "p2" && a.Name != "Bob Updated" {
+				t.Errorf("Upsert did not update attendee: %+v", a)
+			}
+		}
+
+		empty, err := repos.Attendees.ListByChats(ctx, nil)
+		if err != nil {
+			t.Fatalf("ListByChats(nil): %v", err)
+		}
+		if len(empty) != 0 {
+			t.Errorf("ListByChats(nil) returned %d attendees, want 0", len(empty))
+		}
+	})
+}
+
+func testSyncCheckpointRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	t.Run("SaveAndGet", func(t *testing.T) {
+		repos := newRepos(t)
+		accountID := "acc-" + uuid.NewString()
+
+		if _, err := repos.Checkpoints.Get(ctx, accountID); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("Get before Save err = %v, want apperr.ErrNotFound", err)
+		}
+
+		if err := repos.Checkpoints.Save(ctx, &model.SyncCheckpoint{AccountID: accountID, ChatsCursor: "c1"}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		synced := time.Now().UTC().Truncate(time.Microsecond)
+		if err := repos.Checkpoints.Save(ctx, &model.SyncCheckpoint{AccountID: accountID, ChatsBackfilled: true, LastSyncedAt: &synced}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+
+		cp, err := repos.Checkpoints.Get(ctx, accountID)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if cp.ChatsCursor != "" || !cp.ChatsBackfilled || cp.LastSyncedAt == nil || !cp.LastSyncedAt.Equal(synced) {
+			t.Errorf("Get = %+v, want the second saved checkpoint", cp)
+		}
+	})
+}
+
+func testSearchRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+	repos := newRepos(t)
+	accountA, accountB := "acc-"+uuid.NewString(), "acc-"+uuid.NewString()
+	base := time.Now().UTC().Truncate(time.Microsecond)
+
+	msgs := make([]*model.Message, 0, 6)
+	for i, m := range []struct {
+		accountID, text, senderName string
+	}{
+		{accountA, "Quarterly budget review tomorrow", "Alice Wang"},
+		{accountA, "Budget approved", "Bob Chen"},
+		{accountA, "Lunch?", "Alice Wang"},
+		{accountA, "See the budget sheet", "Carol Lin"},
+		{accountB, "Budget for the other account", "Alice Wang"},
+		{accountA, "Thanks for connecting", ""},
+	} {
+		msg := &model.Message{
+			AccountID:  m.accountID,
+			ChatID:     "chat-" + uuid.NewString(),
+			UnipileID:  "msg-" + uuid.NewString(),
+			Text:       m.text,
+			SenderName: m.senderName,
+			SentAt:     base.Add(time.Duration(i) * time.Minute),
+		}
+		if err := repos.Messages.Upsert(ctx, msg); err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+		msgs = append(msgs, msg)
+	}
+
+	// 最後一則訊息只能以對話參與者的名稱找到；連結的帳號本身 (is_self) 不參與比對
+	lastChat := msgs[len(msgs)-1].ChatID
+	for _, a := range []model.ChatAttendee{
+		{AccountID: accountA, ChatID: lastChat, ProviderID: "prov-dana", Name: "Dana Lee"},
+		{AccountID: accountA, ChatID: lastChat, ProviderID: "prov-self", Name: "Erin Self", IsSelf: true},
+	} {
+		if err := repos.Attendees.Upsert(ctx, &a); err != nil {
+			t.Fatalf("Upsert attendee: %v", err)
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		filter itfc.SearchFilter
+		want   int
+	}{
+		"Text":          {itfc.SearchFilter{Query: "budget", AccountIDs: []string{accountA}}, 3},
+		"CaseSensitive": {itfc.SearchFilter{Query: "BUDGET", AccountIDs: []string{accountA}}, 3},
+		"AllTerms":      {itfc.SearchFilter{Query: "budget review", AccountIDs: []string{accountA}}, 1},
+		"SenderName":    {itfc.SearchFilter{Query: "alice", AccountIDs: []string{accountA}}, 2},
+		"AttendeeName":  {itfc.SearchFilter{Query: "dana lee", AccountIDs: []string{accountA}}, 1},
+		"SelfAttendee":  {itfc.SearchFilter{Query: "erin", AccountIDs: []string{accountA}}, 0},
+		"Accounts":      {itfc.SearchFilter{Query: "budget", AccountIDs: []string{accountA, accountB}}, 4},
+		"NoAccounts":    {itfc.SearchFilter{Query: "budget"}, 0},
+		"Range":         {itfc.SearchFilter{Query: "budget", AccountIDs: []string{accountA}, Since: base.Add(time.Minute), Until: base.Add(3 * time.Minute)}, 1},
+		"NoMatch":       {itfc.SearchFilter{Query: "invoice", AccountIDs: []string{accountA}}, 0},
+		// 以完整的字詞比對 (與 Postgres 的 tsvector 相同)：字詞的一部分不符合，標點符號是分隔
+		"PartialWord":     {itfc.SearchFilter{Query: "budg", AccountIDs: []string{accountA}}, 0},
+		"PartialAttendee": {itfc.SearchFilter{Query: "dan", AccountIDs: []string{accountA}}, 0},
+		"Punctuation":     {itfc.SearchFilter{Query: "lunch", AccountIDs: []string{accountA}}, 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			hits, err := repos.Search.Search(ctx, tc.filter)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if len(hits) != tc.want {
+				t.Errorf("Search returned %d hits, want %d", len(hits), tc.want)
+			}
+		})
+	}
+
+	t.Run("RankAndHighlight", func(t *testing.T) {
+		hits, err := repos.Search.Search(ctx, itfc.SearchFilter{Query: "alice", AccountIDs: []string{accountA}})
+		if err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+		for _, h := range hits {
+			if h.Rank <= 0 {
+				t.Errorf("Search hit rank = %v, want > 0", h.Rank)
+			}
+		}
+
+		hits, err = repos.Search.Search(ctx, itfc.SearchFilter{Query: "review", AccountIDs: []string{accountA}})
+		if err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+		if len(hits) != 1 || !strings.Contains(hits[0].Highlight, search.HighlightStart+"review"+search.HighlightStop) {
+			t.Errorf("Search highlight = %+v, want the term wrapped in highlight markers", hits)
+		}
+	})
+
+	t.Run("Paginates", func(t *testing.T) {
+		seen := map[uuid.UUID]bool{}
+		filter := itfc.SearchFilter{Query: "budget", AccountIDs: []string{accountA, accountB}, Limit: 3}
+		for page := 0; page < 5; page++ {
+			if page == 1 {
+				// 分頁期間同步更新了最早一則訊息的 sent_at，游標不能因此重複或遺漏訊息
+				msgs[0].SentAt = base.Add(time.Hour)
+				if err := repos.Messages.Upsert(ctx, msgs[0]); err != nil {
+					t.Fatalf("Upsert: %v", err)
+				}
+			}
+			hits, err := repos.Search.Search(ctx, filter)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			for i, h := range hits {
+				if seen[h.ID] {
+					t.Errorf("Search returned message %s twice", h.ID)
+				}
+				seen[h.ID] = true
+				if i > 0 && h.Rank > hits[i-1].Rank {
+					t.Errorf("Search not ordered by rank DESC")
+				}
+			}
+			if len(hits) < filter.Limit {
+				break
+			}
+			after := hits[len(hits)-1].Cursor()
+			filter.After = &after
+		}
+		if len(seen) != 4 {
+			t.Errorf("Search paged through %d messages, want 4", len(seen))
+		}
+	})
+}
+
+func testContactRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	t.Run("UpsertAndGetByIdentifier", func(t *testing.T) {
+		repos := newRepos(t)
+		accountID := "acc-" + uuid.NewString()
+		providerID := "prov-" + uuid.NewString()
+		publicID := "public-" + uuid.NewString()
+		fetched := time.Now().UTC().Truncate(time.Microsecond)
+
+		if _, err := repos.Contacts.GetByIdentifier(ctx, accountID, providerID); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("GetByIdentifier before Upsert err = %v, want apperr.ErrNotFound", err)
+		}
+
+		if err := repos.Contacts.Upsert(ctx, &model.Contact{AccountID: accountID, ProviderID: providerID, PublicIdentifier: publicID, Headline: "old", FetchedAt: fetched.Add(-time.Hour)}); err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+		contact := &model.Contact{AccountID: accountID, ProviderID: providerID, PublicIdentifier: publicID, Headline: "Recruiter", Company: "Acme", FetchedAt: fetched}
+		if err := repos.Contacts.Upsert(ctx, contact); err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+		if contact.ID == uuid.Nil {
+			t.Errorf("Upsert did not set ID")
+		}
+
+		for _, identifier := range []string{providerID, publicID} {
+			got, err := repos.Contacts.GetByIdentifier(ctx, accountID, identifier)
+			if err != nil {
+				t.Fatalf("GetByIdentifier(%q): %v", identifier, err)
+			}
+			if got.Headline != "Recruiter" || got.Company != "Acme" || !got.FetchedAt.Equal(fetched) {
+				t.Errorf("GetByIdentifier(%q) = %+v, want the second upsert", identifier, got)
+			}
+		}
+
+		// 快取依帳號區分
+		if _, err := repos.Contacts.GetByIdentifier(ctx, "acc-"+uuid.NewString(), providerID); !errors.Is(err, apperr.ErrNotFound) {
+			t.Errorf("GetByIdentifier other account err = %v, want apperr.ErrNotFound", err)
+		}
+	})
+
+	t.Run("ListByProviderIDs", func(t *testing.T) {
+		repos := newRepos(t)
+		accountID := "acc-" + uuid.NewString()
+		ids := []string{"prov-" + uuid.NewString(), "prov-" + uuid.NewString(), "prov-" + uuid.NewString()}
+		for _, id := range ids {
+			if err := repos.Contacts.Upsert(ctx, &model.Contact{AccountID: accountID, ProviderID: id, FetchedAt: time.Now()}); err != nil {
+				t.Fatalf("Upsert: %v", err)
+			}
+		}
+
+		contacts, err := repos.Contacts.ListByProviderIDs(ctx, accountID, []string{ids[0], ids[2], "prov-missing"})
+		if err != nil {
+			t.Fatalf("ListByProviderIDs: %v", err)
+		}
+		if len(contacts) != 2 {
+			t.Errorf("ListByProviderIDs returned %d contacts, want 2", len(contacts))
+		}
+
+		contacts, err = repos.Contacts.ListByProviderIDs(ctx, accountID, nil)
+		if err != nil || len(contacts) != 0 {
+			t.Errorf("ListByProviderIDs(nil) = %v, %v, want no contacts", contacts, err)
+		}
+	})
+}
+
+func testInvitationRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	t.Run("UpsertKeepsStatus", func(t *testing.T) {
+		repos := newRepos(t)
+		accountID := "acc-" + uuid.NewString()
+		unipileID := "inv-" + uuid.NewString()
+
+		if _, err := repos.Invitations.GetByUnipileID(ctx, accountID, unipileID); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("GetByUnipileID before Upsert err = %v, want apperr.ErrNotFound", err)
+		}
+
+		inv := &model.Invitation{AccountID: accountID, UnipileID: unipileID, Direction: model.InvitationSent, ProviderID: "prov-1", Message: "hello", SentByEmail: "a@example.com"}
+		if err := repos.Invitations.Upsert(ctx, inv); err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+		if inv.ID == uuid.Nil || inv.Status != model.InvitationPending {
+			t.Errorf("Upsert = %+v, want an ID and status pending", inv)
+		}
+
+		responded := time.Now().UTC().Truncate(time.Microsecond)
+		if err := repos.Invitations.UpdateStatus(ctx, inv.ID, model.InvitationWithdrawn, responded); err != nil {
+			t.Fatalf("UpdateStatus: %v", err)
+		}
+		// 再次從 Unipile 列出時只更新對方的資訊
+		if err := repos.Invitations.Upsert(ctx, &model.Invitation{AccountID: accountID, UnipileID: unipileID, Direction: model.InvitationSent, ProviderID: "prov-1", Name: "Bob"}); err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+
+		got, err := repos.Invitations.GetByUnipileID(ctx, accountID, unipileID)
+		if err != nil {
+			t.Fatalf("GetByUnipileID: %v", err)
+		}
+		if got.ID != inv.ID || got.Name != "Bob" || got.Message != "hello" || got.Status != model.InvitationWithdrawn || got.SentByEmail != "a@example.com" ||
+			got.RespondedAt == nil || !got.RespondedAt.Equal(responded) {
+			t.Errorf("GetByUnipileID = %+v, want the updated name with message, status and sender kept", got)
+		}
+
+		if err := repos.Invitations.UpdateStatus(ctx, uuid.New(), model.InvitationAccepted, responded); !errors.Is(err, apperr.ErrNotFound) {
+			t.Errorf("UpdateStatus missing err = %v, want apperr.ErrNotFound", err)
+		}
+	})
+
+	t.Run("MarkAccepted", func(t *testing.T) {
+		repos := newRepos(t)
+		accountID := "acc-" + uuid.NewString()
+		for _, inv := range []*model.Invitation{
+			{AccountID: accountID, UnipileID: "inv-" + uuid.NewString(), Direction: model.InvitationSent, ProviderID: "prov-1"},
+			{AccountID: accountID, UnipileID: "inv-" + uuid.NewString(), Direction: model.InvitationSent, ProviderID: "prov-2"},
+			{AccountID: "acc-" + uuid.NewString(), UnipileID: "inv-" + uuid.NewString(), Direction: model.InvitationSent, ProviderID: "prov-1"},
+		} {
+			if err := repos.Invitations.Upsert(ctx, inv); err != nil {
+				t.Fatalf("Upsert: %v", err)
+			}
+		}
+
+		n, err := repos.Invitations.MarkAccepted(ctx, accountID, "prov-1", time.Now())
+		if err != nil {
+			t.Fatalf("MarkAccepted: %v", err)
+		}
+		if n != 1 {
+			t.Errorf("MarkAccepted updated %d invitations, want 1", n)
+		}
+		if n, _ := repos.Invitations.MarkAccepted(ctx, accountID, "prov-1", time.Now()); n != 0 {
+			t.Errorf("MarkAccepted again updated %d invitations, want 0", n)
+		}
+	})
+}
+
+func mustCreateCampaign(t *testing.T, repo itfc.CampaignRepository, email, accountID string) *model.Campaign {
+	t.Helper()
+	campaign := &model.Campaign{
+		UserEmail: email,
+		AccountID: accountID,
+		Name:      "Outreach",
+		Status:    model.CampaignActive,
+		Steps:     model.CampaignSteps{{Action: model.StepInvite}, {Action: model.StepMessage, DelayHours: 24, Text: "Thanks!"}},
+	}
+	if err := repo.Create(context.Background(), campaign); err != nil {
+		t.Fatalf("Create campaign: %v", err)
+	}
+	return campaign
+}
+
+func testCampaignRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repos := newRepos(t)
+		email := randomEmail()
+		campaign := mustCreateCampaign(t, repos.Campaigns, email, "acc-"+uuid.NewString())
+		if campaign.ID == uuid.Nil {
+			t.Fatalf("Create did not set ID")
+		}
+
+		got, err := repos.Campaigns.Get(ctx, campaign.ID)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if got.UserEmail != email || got.Status != model.CampaignActive || len(got.Steps) != 2 || got.Steps[1].Text != "Thanks!" || got.Steps[1].DelayHours != 24 {
+			t.Errorf("Get = %+v, want the created campaign with its steps", got)
+		}
+
+		if _, err := repos.Campaigns.Get(ctx, uuid.New()); !errors.Is(err, apperr.ErrNotFound) {
+			t.Errorf("Get missing err = %v, want apperr.ErrNotFound", err)
+		}
+	})
+
+	t.Run("ListByUser", func(t *testing.T) {
+		repos := newRepos(t)
+		email := randomEmail()
+		first := mustCreateCampaign(t, repos.Campaigns, email, "acc-"+uuid.NewString())
+		second := mustCreateCampaign(t, repos.Campaigns, email, "acc-"+uuid.NewString())
+		mustCreateCampaign(t, repos.Campaigns, randomEmail(), "acc-"+uuid.NewString())
+
+		campaigns, err := repos.Campaigns.ListByUser(ctx, email)
+		if err != nil {
+			t.Fatalf("ListByUser: %v", err)
+		}
+		if len(campaigns) != 2 || campaigns[0].ID != second.ID || campaigns[1].ID != first.ID {
+			t.Errorf("ListByUser = %+v, want the user's two campaigns newest first", campaigns)
+		}
+	})
+
+	t.Run("UpdateStatus", func(t *testing.T) {
+		repos := newRepos(t)
+		campaign := mustCreateCampaign(t, repos.Campaigns, randomEmail(), "acc-"+uuid.NewString())
+
+		if err := repos.Campaigns.UpdateStatus(ctx, campaign.ID, model.CampaignPaused); err != nil {
+			t.Fatalf("UpdateStatus: %v", err)
+		}
+		got, err := repos.Campaigns.Get(ctx, campaign.ID)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if got.Status != model.CampaignPaused {
+			t.Errorf("Status = %q, want %q", got.Status, model.CampaignPaused)
+		}
+
+		if err := repos.Campaigns.UpdateStatus(ctx, uuid.New(), model.CampaignPaused); !errors.Is(err, apperr.ErrNotFound) {
+			t.Errorf("UpdateStatus missing err = %v, want apperr.ErrNotFound", err)
+		}
+	})
+}
+
+func mustEnroll(t *testing.T, repo itfc.EnrollmentRepository, campaign *model.Campaign, providerID string, nextRunAt time.Time) *model.Enrollment {
+	t.Helper()
+	e := &model.Enrollment{
+		CampaignID: campaign.ID,
+		AccountID:  campaign.AccountID,
+		ProviderID: providerID,
+		Status:     model.EnrollmentActive,
+		NextRunAt:  &nextRunAt,
+	}
+	ev := &model.EnrollmentEvent{
+		CampaignID: campaign.ID,
+		ProviderID: providerID,
+		ToStatus:   model.EnrollmentActive,
+		CreatedAt:  time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if err := repo.Create(context.Background(), e, ev); err != nil {
+		t.Fatalf("Create enrollment: %v", err)
+	}
+	return e
+}
+
+func testEnrollmentRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repos := newRepos(t)
+		campaign := mustCreateCampaign(t, repos.Campaigns, randomEmail(), "acc-"+uuid.NewString())
+		e := mustEnroll(t, repos.Enrollments, campaign, "prov-1", time.Now())
+
+		got, err := repos.Enrollments.Get(ctx, e.ID)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if got.CampaignID != campaign.ID || got.ProviderID != "prov-1" || got.Status != model.EnrollmentActive || got.CreatedAt == nil {
+			t.Errorf("Get = %+v, want the created enrollment", got)
+		}
+
+		// 同一個對象不能重複報名同一個活動
+		dup := &model.Enrollment{CampaignID: campaign.ID, AccountID: campaign.AccountID, ProviderID: "prov-1"}
+		if err := repos.Enrollments.Create(ctx, dup, &model.EnrollmentEvent{CampaignID: campaign.ID, CreatedAt: time.Now()}); !errors.Is(err, apperr.ErrConflict) {
+			t.Errorf("Create duplicate err = %v, want apperr.ErrConflict", err)
+		}
+
+		events, err := repos.Enrollments.ListEvents(ctx, campaign.ID, nil, 10)
+		if err != nil {
+			t.Fatalf("ListEvents: %v", err)
+		}
+		if len(events) != 1 || events[0].EnrollmentID != e.ID {
+			t.Errorf("ListEvents = %+v, want only the event of the created enrollment", events)
+		}
+
+		if _, err := repos.Enrollments.Get(ctx, uuid.New()); !errors.Is(err, apperr.ErrNotFound) {
+			t.Errorf("Get missing err = %v, want apperr.ErrNotFound", err)
+		}
+	})
+
+	t.Run("ListByCampaign", func(t *testing.T) {
+		repos := newRepos(t)
+		campaign := mustCreateCampaign(t, repos.Campaigns, randomEmail(), "acc-"+uuid.NewString())
+		for i := range 3 {
+			mustEnroll(t, repos.Enrollments, campaign, fmt.Sprintf("prov-%d", i), time.Now())
+		}
+		other := mustCreateCampaign(t, repos.Campaigns, randomEmail(), "acc-"+uuid.NewString())
+		mustEnroll(t, repos.Enrollments, other, "prov-0", time.Now())
+
+		first, err := repos.Enrollments.ListByCampaign(ctx, campaign.ID, nil, 2)
+		if err != nil {
+			t.Fatalf("ListByCampaign: %v", err)
+		}
+		if len(first) != 2 {
+			t.Fatalf("ListByCampaign returned %d enrollments, want 2", len(first))
+		}
+		last := first[len(first)-1]
+		rest, err := repos.Enrollments.ListByCampaign(ctx, campaign.ID, &pagination.Cursor{Time: *last.CreatedAt, ID: last.ID.String()}, 2)
+		if err != nil {
+			t.Fatalf("ListByCampaign: %v", err)
+		}
+		if len(rest) != 1 || rest[0].ID == first[0].ID || rest[0].ID == first[1].ID {
+			t.Errorf("ListByCampaign second page = %+v, want the remaining enrollment", rest)
+		}
+	})
+
+	t.Run("ClaimDue", func(t *testing.T) {
+		repos := newRepos(t)
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		campaign := mustCreateCampaign(t, repos.Campaigns, randomEmail(), "acc-"+uuid.NewString())
+		due := mustEnroll(t, repos.Enrollments, campaign, "prov-due", now.Add(-time.Minute))
+		mustEnroll(t, repos.Enrollments, campaign, "prov-later", now.Add(time.Hour))
+		paused := mustCreateCampaign(t, repos.Campaigns, randomEmail(), "acc-"+uuid.NewString())
+		mustEnroll(t, repos.Enrollments, paused, "prov-paused", now.Add(-time.Minute))
+		if err := repos.Campaigns.UpdateStatus(ctx, paused.ID, model.CampaignPaused); err != nil {
+			t.Fatalf("UpdateStatus: %v", err)
+		}
+
+		// 共用的資料庫可能有其他測試的資料，只檢查這個測試建立的報名
+		claimed, err := repos.Enrollments.ClaimDue(ctx, now, 10*time.Minute, 1000)
+		if err != nil {
+			t.Fatalf("ClaimDue: %v", err)
+		}
+		var found int
+		for _, e := range claimed {
+			if e.CampaignID == paused.ID || (e.CampaignID == campaign.ID && e.ID != due.ID) {
+				t.Errorf("ClaimDue returned %+v, want only due enrollments of active campaigns", e)
+			}
+			if e.ID == due.ID {
+				found++
+			}
+		}
+		if found != 1 {
+			t.Errorf("ClaimDue did not return the due enrollment")
+		}
+
+		// 租約期間不會再被取出
+		claimed, err = repos.Enrollments.ClaimDue(ctx, now, 10*time.Minute, 1000)
+		if err != nil {
+			t.Fatalf("ClaimDue: %v", err)
+		}
+		for _, e := range claimed {
+			if e.ID == due.ID {
+				t.Errorf("ClaimDue returned the leased enrollment again")
+			}
+		}
+		got, err := repos.Enrollments.Get(ctx, due.ID)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if got.NextRunAt == nil || !got.NextRunAt.Equal(now.Add(10*time.Minute)) {
+			t.Errorf("NextRunAt = %v, want the end of the lease", got.NextRunAt)
+		}
+
+		// 租約到期後被重新取出，原持有者不能再記錄結果，只有重新取出的程序可以
+		var reclaimed *model.Enrollment
+		claimed, err = repos.Enrollments.ClaimDue(ctx, now.Add(11*time.Minute), 10*time.Minute, 1000)
+		if err != nil {
+			t.Fatalf("ClaimDue: %v", err)
+		}
+		for _, e := range claimed {
+			if e.ID == due.ID {
+				reclaimed = &e
+			}
+		}
+		if reclaimed == nil {
+			t.Fatalf("ClaimDue after the lease did not return the expired enrollment")
+		}
+		stale := *got
+		stale.CurrentStep = 1
+		ev := &model.EnrollmentEvent{CampaignID: campaign.ID, ProviderID: "prov-due", FromStatus: model.EnrollmentActive, ToStatus: model.EnrollmentActive, CreatedAt: now}
+		if err := repos.Enrollments.Transition(ctx, &stale, model.EnrollmentActive, ev); !errors.Is(err, apperr.ErrConflict) {
+			t.Errorf("Transition with an expired lease err = %v, want apperr.ErrConflict", err)
+		}
+		reclaimed.CurrentStep = 1
+		if err := repos.Enrollments.Transition(ctx, reclaimed, model.EnrollmentActive, ev); err != nil {
+			t.Errorf("Transition with the current lease: %v", err)
+		}
+	})
+
+	t.Run("Transition", func(t *testing.T) {
+		repos := newRepos(t)
+		campaign := mustCreateCampaign(t, repos.Campaigns, randomEmail(), "acc-"+uuid.NewString())
+		e := mustEnroll(t, repos.Enrollments, campaign, "prov-1", time.Now())
+
+		e.Status = model.EnrollmentActive
+		e.CurrentStep = 1
+		e.ChatID = "chat-1"
+		ev := &model.EnrollmentEvent{CampaignID: campaign.ID, ProviderID: "prov-1", Action: model.StepInvite, FromStatus: model.EnrollmentActive, ToStatus: model.EnrollmentActive, CreatedAt: time.Now().UTC().Truncate(time.Microsecond).Add(time.Second)}
+		if err := repos.Enrollments.Transition(ctx, e, model.EnrollmentActive, ev); err != nil {
+			t.Fatalf("Transition: %v", err)
+		}
+
+		replied := *e
+		replied.Status = model.EnrollmentReplied
+		replied.NextRunAt = nil
+		if err := repos.Enrollments.Transition(ctx, &replied, model.EnrollmentActive, &model.EnrollmentEvent{CampaignID: campaign.ID, ProviderID: "prov-1", FromStatus: model.EnrollmentActive, ToStatus: model.EnrollmentReplied, CreatedAt: ev.CreatedAt.Add(time.Second)}); err != nil {
+			t.Fatalf("Transition: %v", err)
+		}
+
+		// 狀態已經改變，舊的狀態不能再轉換
+		stopped := *e
+		stopped.Status = model.EnrollmentStopped
+		if err := repos.Enrollments.Transition(ctx, &stopped, model.EnrollmentActive, &model.EnrollmentEvent{CampaignID: campaign.ID, CreatedAt: time.Now()}); !errors.Is(err, apperr.ErrConflict) {
+			t.Errorf("Transition from stale status err = %v, want apperr.ErrConflict", err)
+		}
+
+		got, err := repos.Enrollments.Get(ctx, e.ID)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if got.Status != model.EnrollmentReplied || got.CurrentStep != 1 || got.ChatID != "chat-1" || got.NextRunAt != nil {
+			t.Errorf("Get = %+v, want the replied enrollment", got)
+		}
+
+		active, err := repos.Enrollments.ListActiveByProspect(ctx, campaign.AccountID, "prov-1")
+		if err != nil {
+			t.Fatalf("ListActiveByProspect: %v", err)
+		}
+		if len(active) != 0 {
+			t.Errorf("ListActiveByProspect = %+v, want none after reply", active)
+		}
+
+		events, err := repos.Enrollments.ListEvents(ctx, campaign.ID, nil, 10)
+		if err != nil {
+			t.Fatalf("ListEvents: %v", err)
+		}
+		if len(events) != 3 || events[0].ToStatus != model.EnrollmentReplied || events[1].Action != model.StepInvite {
+			t.Errorf("ListEvents = %+v, want 3 events newest first", events)
+		}
+
+		page, err := repos.Enrollments.ListEvents(ctx, campaign.ID, &pagination.Cursor{Time: events[0].CreatedAt, ID: events[0].ID.String()}, 10)
+		if err != nil {
+			t.Fatalf("ListEvents: %v", err)
+		}
+		if len(page) != 2 || page[0].ID != events[1].ID {
+			t.Errorf("ListEvents second page = %+v, want the 2 older events", page)
+		}
+	})
+
+	t.Run("ListActiveByProspect", func(t *testing.T) {
+		repos := newRepos(t)
+		accountID := "acc-" + uuid.NewString()
+		a := mustCreateCampaign(t, repos.Campaigns, randomEmail(), accountID)
+		b := mustCreateCampaign(t, repos.Campaigns, randomEmail(), accountID)
+		mustEnroll(t, repos.Enrollments, a, "prov-1", time.Now())
+		mustEnroll(t, repos.Enrollments, b, "prov-1", time.Now())
+		mustEnroll(t, repos.Enrollments, a, "prov-2", time.Now())
+
+		active, err := repos.Enrollments.ListActiveByProspect(ctx, accountID, "prov-1")
+		if err != nil {
+			t.Fatalf("ListActiveByProspect: %v", err)
+		}
+		if len(active) != 2 {
+			t.Errorf("ListActiveByProspect returned %d enrollments, want 2", len(active))
+		}
+	})
+}
+
+func testQuotaRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	t.Run("SaveLimits", func(t *testing.T) {
+		repos := newRepos(t)
+		accountID := "acc-" + uuid.NewString()
+
+		if _, err := repos.Quotas.GetLimits(ctx, accountID); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("GetLimits before SaveLimits err = %v, want apperr.ErrNotFound", err)
+		}
+
+		limits := &model.AccountLimits{AccountID: accountID, Timezone: "Asia/Taipei", WorkStart: "09:00", WorkEnd: "18:00", WorkDays: model.StringList{"mon", "fri"}, InviteDaily: 20}
+		if err := repos.Quotas.SaveLimits(ctx, limits); err != nil {
+			t.Fatalf("SaveLimits: %v", err)
+		}
+		limits.InviteDaily = 10
+		limits.WorkDays = nil
+		if err := repos.Quotas.SaveLimits(ctx, limits); err != nil {
+			t.Fatalf("SaveLimits again: %v", err)
+		}
+
+		got, err := repos.Quotas.GetLimits(ctx, accountID)
+		if err != nil {
+			t.Fatalf("GetLimits: %v", err)
+		}
+		if got.InviteDaily != 10 || len(got.WorkDays) != 0 || got.Timezone != "Asia/Taipei" {
+			t.Errorf("GetLimits = %+v, want the updated limits", got)
+		}
+	})
+
+	t.Run("Consume", func(t *testing.T) {
+		repos := newRepos(t)
+		accountID := "acc-" + uuid.NewString()
+		now := time.Now().UTC().Truncate(time.Microsecond)
+
+		// 一小時前已使用一次
+		if ok, err := repos.Quotas.Consume(ctx, &model.ActionUsage{AccountID: accountID, Action: model.ActionInvite, CreatedAt: now.Add(-time.Hour)}, nil); err != nil || !ok {
+			t.Fatalf("Consume = %v, %v, want true", ok, err)
+		}
+
+		windows := []itfc.QuotaWindow{{Since: now.Add(-30 * time.Minute), Limit: 2}, {Since: now.Add(-2 * time.Hour), Limit: 3}}
+		var last *model.ActionUsage
+		for i := range 2 {
+			last = &model.ActionUsage{AccountID: accountID, Action: model.ActionInvite, CreatedAt: now}
+			ok, err := repos.Quotas.Consume(ctx, last, windows)
+			if err != nil {
+				t.Fatalf("Consume: %v", err)
+			}
+			if !ok {
+				t.Fatalf("Consume #%d = false, want true", i)
+			}
+		}
+		// 第二個 window 已經用完 3 次
+		if ok, err := repos.Quotas.Consume(ctx, &model.ActionUsage{AccountID: accountID, Action: model.ActionInvite, CreatedAt: now}, windows); err != nil || ok {
+			t.Errorf("Consume over quota = %v, %v, want false", ok, err)
+		}
+		// 其他動作不受影響
+		if ok, err := repos.Quotas.Consume(ctx, &model.ActionUsage{AccountID: accountID, Action: model.ActionMessage, CreatedAt: now}, windows); err != nil || !ok {
+			t.Errorf("Consume other action = %v, %v, want true", ok, err)
+		}
+
+		if n, err := repos.Quotas.Count(ctx, accountID, model.ActionInvite, now.Add(-30*time.Minute)); err != nil || n != 2 {
+			t.Errorf("Count = %d, %v, want 2", n, err)
+		}
+
+		// 歸還後可以再使用
+		if err := repos.Quotas.Release(ctx, last.ID); err != nil {
+			t.Fatalf("Release: %v", err)
+		}
+		if err := repos.Quotas.Release(ctx, uuid.New()); err != nil {
+			t.Errorf("Release missing err = %v, want nil", err)
+		}
+		if ok, err := repos.Quotas.Consume(ctx, &model.ActionUsage{AccountID: accountID, Action: model.ActionInvite, CreatedAt: now}, windows); err != nil || !ok {
+			t.Errorf("Consume after Release = %v, %v, want true", ok, err)
+		}
+	})
+}
+
+func testTemplateRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+	repos := newRepos(t)
+	email := randomEmail()
+
+	first := &model.MessageTemplate{UserEmail: email, Name: "intro", Body: "Hi {{.FirstName}}", Variables: model.StringList{"FirstName"}}
+	if err := repos.Templates.Create(ctx, first); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if first.ID == uuid.Nil || first.CreatedAt == nil {
+		t.Errorf("Create did not populate ID and CreatedAt: %+v", first)
+	}
+	if err := repos.Templates.Create(ctx, &model.MessageTemplate{UserEmail: email, Name: "intro", Body: "dup"}); !errors.Is(err, apperr.ErrConflict) {
+		t.Errorf("Create duplicate name err = %v, want apperr.ErrConflict", err)
+	}
+	// 不同使用者可以使用相同名稱
+	if err := repos.Templates.Create(ctx, &model.MessageTemplate{UserEmail: randomEmail(), Name: "intro", Body: "x"}); err != nil {
+		t.Errorf("Create same name for another user: %v", err)
+	}
+	second := &model.MessageTemplate{UserEmail: email, Name: "follow up", Body: "Hello"}
+	if err := repos.Templates.Create(ctx, second); err != nil {
+		t.Fatalf("Create second: %v", err)
+	}
+
+	list, err := repos.Templates.ListByUser(ctx, email)
+	if err != nil {
+		t.Fatalf("ListByUser: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != second.ID || list[1].ID != first.ID {
+		t.Errorf("ListByUser = %+v, want [follow up, intro]", list)
+	}
+
+	second.Name = "intro"
+	if err := repos.Templates.Update(ctx, second); !errors.Is(err, apperr.ErrConflict) {
+		t.Errorf("Update to duplicate name err = %v, want apperr.ErrConflict", err)
+	}
+	first.Body = "Hi {{.FirstName}} from {{.Sender.Name}}"
+	first.Variables = model.StringList{"FirstName", "Sender.Name"}
+	if err := repos.Templates.Update(ctx, first); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, err := repos.Templates.Get(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Body != first.Body || len(got.Variables) != 2 || got.Variables[1] != "Sender.Name" || got.UserEmail != email {
+		t.Errorf("Get = %+v, want the updated template", got)
+	}
+	if err := repos.Templates.Update(ctx, &model.MessageTemplate{ID: uuid.New(), Name: "x"}); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("Update missing err = %v, want apperr.ErrNotFound", err)
+	}
+
+	if err := repos.Templates.Delete(ctx, first.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := repos.Templates.Get(ctx, first.ID); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("Get after Delete err = %v, want apperr.ErrNotFound", err)
+	}
+	if err := repos.Templates.Delete(ctx, first.ID); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("Delete missing err = %v, want apperr.ErrNotFound", err)
+	}
+}
+
+// mustSchedule 建立在 sendAt 送出的排程訊息
+func mustSchedule(t *testing.T, repo itfc.ScheduledMessageRepository, email string, sendAt time.Time) *model.ScheduledMessage {
+	t.Helper()
+	m := &model.ScheduledMessage{UserEmail: email, AccountID: "acc-" + uuid.NewString(), ProviderID: "prov-1", Text: "hello", SendAt: sendAt, Status: model.ScheduledPending, NextAttemptAt: &sendAt}
+	if err := repo.Create(context.Background(), m); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return m
+}
+
+func testScheduledMessageRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	t.Run("ListByUser", func(t *testing.T) {
+		repos := newRepos(t)
+		email := randomEmail()
+		base := time.Now().UTC().Truncate(time.Microsecond)
+
+		var created []*model.ScheduledMessage
+		for i := range 3 {
+			created = append(created, mustSchedule(t, repos.Scheduled, email, base.Add(time.Duration(i)*time.Hour)))
+		}
+		mustSchedule(t, repos.Scheduled, randomEmail(), base)
+		if created[0].ID == uuid.Nil || created[0].CreatedAt == nil {
+			t.Errorf("Create did not populate ID and CreatedAt: %+v", created[0])
+		}
+
+		page, err := repos.Scheduled.ListByUser(ctx, email, "", nil, 2)
+		if err != nil {
+			t.Fatalf("ListByUser: %v", err)
+		}
+		if len(page) != 2 || page[0].ID != created[2].ID || page[1].ID != created[1].ID {
+			t.Fatalf("ListByUser first page = %+v, want the two latest send_at", page)
+		}
+		last := page[1]
+		page, err = repos.Scheduled.ListByUser(ctx, email, "", &pagination.Cursor{Time: last.SendAt, ID: last.ID.String()}, 2)
+		if err != nil {
+			t.Fatalf("ListByUser: %v", err)
+		}
+		if len(page) != 1 || page[0].ID != created[0].ID {
+			t.Errorf("ListByUser second page = %+v, want the earliest message", page)
+		}
+
+		canceled := *created[1]
+		canceled.Status = model.ScheduledCanceled
+		canceled.NextAttemptAt = nil
+		if err := repos.Scheduled.Update(ctx, &canceled, model.ScheduledPending); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		page, err = repos.Scheduled.ListByUser(ctx, email, model.ScheduledCanceled, nil, 10)
+		if err != nil {
+			t.Fatalf("ListByUser: %v", err)
+		}
+		if len(page) != 1 || page[0].ID != canceled.ID {
+			t.Errorf("ListByUser canceled = %+v, want only the canceled message", page)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		repos := newRepos(t)
+		m := mustSchedule(t, repos.Scheduled, randomEmail(), time.Now().UTC().Truncate(time.Microsecond))
+
+		sendAt := m.SendAt.Add(time.Hour)
+		m.Text = "edited"
+		m.SendAt = sendAt
+		m.NextAttemptAt = &sendAt
+		m.Timezone = "Europe/Berlin"
+		if err := repos.Scheduled.Update(ctx, m, model.ScheduledPending); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		got, err := repos.Scheduled.Get(ctx, m.ID)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if got.Text != "edited" || !got.SendAt.Equal(sendAt) || got.Timezone != "Europe/Berlin" || got.Status != model.ScheduledPending {
+			t.Errorf("Get = %+v, want the edited message", got)
+		}
+
+		// 狀態已改變時不能更新
+		if err := repos.Scheduled.Update(ctx, m, model.ScheduledSending); !errors.Is(err, apperr.ErrConflict) {
+			t.Errorf("Update with stale status err = %v, want apperr.ErrConflict", err)
+		}
+		if err := repos.Scheduled.Update(ctx, &model.ScheduledMessage{ID: uuid.New(), Status: model.ScheduledCanceled}, model.ScheduledPending); !errors.Is(err, apperr.ErrConflict) {
+			t.Errorf("Update missing err = %v, want apperr.ErrConflict", err)
+		}
+		if _, err := repos.Scheduled.Get(ctx, uuid.New()); !errors.Is(err, apperr.ErrNotFound) {
+			t.Errorf("Get missing err = %v, want apperr.ErrNotFound", err)
+		}
+	})
+
+	t.Run("ClaimDue", func(t *testing.T) {
+		repos := newRepos(t)
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		email := randomEmail()
+		due := mustSchedule(t, repos.Scheduled, email, now.Add(-time.Minute))
+		later := mustSchedule(t, repos.Scheduled, email, now.Add(time.Hour))
+		canceled := mustSchedule(t, repos.Scheduled, email, now.Add(-time.Minute))
+		canceled.Status = model.ScheduledCanceled
+		if err := repos.Scheduled.Update(ctx, canceled, model.ScheduledPending); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+
+		// 共用的資料庫可能有其他測試的資料，只檢查這個測試建立的訊息
+		claim := func(at time.Time) map[uuid.UUID]model.ScheduledMessage {
+			t.Helper()
+			claimed, err := repos.Scheduled.ClaimDue(ctx, at, 10*time.Minute, 1000)
+			if err != nil {
+				t.Fatalf("ClaimDue: %v", err)
+			}
+			mine := map[uuid.UUID]model.ScheduledMessage{}
+			for _, m := range claimed {
+				if m.UserEmail == email {
+					mine[m.ID] = m
+				}
+			}
+			return mine
+		}
+
+		claimed := claim(now)
+		if _, ok := claimed[due.ID]; !ok || len(claimed) != 1 {
+			t.Fatalf("ClaimDue = %+v, want only the due message", claimed)
+		}
+		if m := claimed[due.ID]; m.Status != model.ScheduledSending || !m.NextAttemptAt.Equal(now.Add(10*time.Minute)) {
+			t.Errorf("claimed message = %+v, want sending until the end of the lease", m)
+		}
+
+		// 租約期間不會再被取出，到期後 (例如程序中斷) 會再被取出
+		if claimed := claim(now); len(claimed) != 0 {
+			t.Errorf("ClaimDue returned leased messages %+v", claimed)
+		}
+		reclaimed := claim(now.Add(11 * time.Minute))
+		if len(reclaimed) != 1 || reclaimed[due.ID].ID != due.ID {
+			t.Fatalf("ClaimDue after the lease = %+v, want the expired message", reclaimed)
+		}
+
+		// 租約到期的原持有者不能再記錄結果，只有重新取出的程序可以
+		stale := claimed[due.ID]
+		stale.Status = model.ScheduledSent
+		if err := repos.Scheduled.Update(ctx, &stale, model.ScheduledSending); !errors.Is(err, apperr.ErrConflict) {
+			t.Errorf("Update with an expired lease err = %v, want apperr.ErrConflict", err)
+		}
+		current := reclaimed[due.ID]
+		current.Status = model.ScheduledSent
+		if err := repos.Scheduled.Update(ctx, &current, model.ScheduledSending); err != nil {
+			t.Errorf("Update with the current lease: %v", err)
+		}
+		if claimed := claim(now.Add(2 * time.Hour)); claimed[later.ID].ID != later.ID {
+			t.Errorf("ClaimDue later = %+v, want to include the later message", claimed)
+		}
+	})
+}
+
+func mustEnqueue(t *testing.T, repo itfc.JobRepository, queue, uniqueKey string, runAt time.Time) *model.Job {
+	t.Helper()
+	j := &model.Job{Queue: queue, Type: "repotest", Payload: "{}", UniqueKey: uniqueKey, Status: model.JobQueued, RunAt: runAt, MaxAttempts: 3}
+	if err := repo.Create(context.Background(), j); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return j
+}
+
+func testJobRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	t.Run("UniqueKey", func(t *testing.T) {
+		repos := newRepos(t)
+		queue := "repotest-" + uuid.NewString()
+		key := "key-" + uuid.NewString()
+		now := time.Now().UTC().Truncate(time.Microsecond)
+
+		first := mustEnqueue(t, repos.Jobs, queue, key, now)
+		if first.ID == uuid.Nil || first.CreatedAt == nil {
+			t.Errorf("Create did not populate ID and CreatedAt: %+v", first)
+		}
+		dup := &model.Job{Queue: queue, Type: "repotest", Payload: "{}", UniqueKey: key, Status: model.JobQueued, RunAt: now, MaxAttempts: 3}
+		if err := repos.Jobs.Create(ctx, dup); !errors.Is(err, apperr.ErrConflict) {
+			t.Fatalf("Create duplicate queued key err = %v, want apperr.ErrConflict", err)
+		}
+		// 沒有 unique key 的工作不受限制
+		mustEnqueue(t, repos.Jobs, queue, "", now)
+		mustEnqueue(t, repos.Jobs, queue, "", now)
+
+		// 執行中也算重複，結束後可以再排入
+		claimed, err := repos.Jobs.ClaimDue(ctx, queue, now, time.Minute, 10)
+		if err != nil {
+			t.Fatalf("ClaimDue: %v", err)
+		}
+		if len(claimed) != 3 {
+			t.Fatalf("ClaimDue = %d jobs, want 3", len(claimed))
+		}
+		if err := repos.Jobs.Create(ctx, dup); !errors.Is(err, apperr.ErrConflict) {
+			t.Fatalf("Create duplicate running key err = %v, want apperr.ErrConflict", err)
+		}
+		for _, j := range claimed {
+			if j.ID != first.ID {
+				continue
+			}
+			finished := now
+			j.Status = model.JobDead
+			j.LastError = "boom"
+			j.FinishedAt = &finished
+			if err := repos.Jobs.Finish(ctx, &j); err != nil {
+				t.Fatalf("Finish: %v", err)
+			}
+		}
+		if err := repos.Jobs.Create(ctx, dup); err != nil {
+			t.Fatalf("Create after the first job finished: %v", err)
+		}
+
+		// 重新排入 dead 工作時，key 與等待中的工作重複
+		if err := repos.Jobs.Retry(ctx, first.ID, now); !errors.Is(err, apperr.ErrConflict) {
+			t.Errorf("Retry with a queued duplicate err = %v, want apperr.ErrConflict", err)
+		}
+	})
+
+	t.Run("ClaimDue", func(t *testing.T) {
+		repos := newRepos(t)
+		queue := "repotest-" + uuid.NewString()
+		now := time.Now().UTC().Truncate(time.Microsecond)
+
+		due := mustEnqueue(t, repos.Jobs, queue, "", now.Add(-time.Minute))
+		later := mustEnqueue(t, repos.Jobs, queue, "", now.Add(time.Hour))
+		mustEnqueue(t, repos.Jobs, "repotest-"+uuid.NewString(), "", now.Add(-time.Minute))
+
+		claimed, err := repos.Jobs.ClaimDue(ctx, queue, now, 10*time.Minute, 10)
+		if err != nil {
+			t.Fatalf("ClaimDue: %v", err)
+		}
+		if len(claimed) != 1 || claimed[0].ID != due.ID {
+			t.Fatalf("ClaimDue = %+v, want only the due job of the queue", claimed)
+		}
+		if j := claimed[0]; j.Status != model.JobRunning || j.Attempts != 1 || !j.RunAt.Equal(now.Add(10*time.Minute)) {
+			t.Errorf("claimed job = %+v, want running with one attempt until the end of the lease", j)
+		}
+
+		// 租約期間不會再被取出，到期後 (例如程序中斷) 會再被取出並增加次數
+		if claimed, _ := repos.Jobs.ClaimDue(ctx, queue, now, 10*time.Minute, 10); len(claimed) != 0 {
+			t.Errorf("ClaimDue returned leased jobs %+v", claimed)
+		}
+		stale := claimed[0]
+		claimed, err = repos.Jobs.ClaimDue(ctx, queue, now.Add(11*time.Minute), 10*time.Minute, 10)
+		if err != nil {
+			t.Fatalf("ClaimDue: %v", err)
+		}
+		if len(claimed) != 1 || claimed[0].ID != due.ID || claimed[0].Attempts != 2 {
+			t.Fatalf("ClaimDue after the lease = %+v, want the expired job with two attempts", claimed)
+		}
+
+		// 前一次取出的結果不能覆寫重新取出的工作
+		stale.Status = model.JobSucceeded
+		if err := repos.Jobs.Finish(ctx, &stale); !errors.Is(err, apperr.ErrConflict) {
+			t.Errorf("Finish with a stale attempt err = %v, want apperr.ErrConflict", err)
+		}
+		retry := claimed[0]
+		retry.Status = model.JobQueued
+		retry.RunAt = now.Add(30 * time.Minute)
+		retry.LastError = "temporary"
+		if err := repos.Jobs.Finish(ctx, &retry); err != nil {
+			t.Fatalf("Finish: %v", err)
+		}
+		got, err := repos.Jobs.Get(ctx, due.ID)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if got.Status != model.JobQueued || got.Attempts != 2 || got.LastError != "temporary" || !got.RunAt.Equal(retry.RunAt) {
+			t.Errorf("Get = %+v, want queued for retry", got)
+		}
+
+		claimed, err = repos.Jobs.ClaimDue(ctx, queue, now.Add(2*time.Hour), 10*time.Minute, 1)
+		if err != nil {
+			t.Fatalf("ClaimDue: %v", err)
+		}
+		if len(claimed) != 1 || claimed[0].ID != due.ID {
+			t.Errorf("ClaimDue limit 1 = %+v, want the earliest run_at first", claimed)
+		}
+		if _, err := repos.Jobs.Get(ctx, later.ID); err != nil {
+			t.Errorf("Get: %v", err)
+		}
+		if _, err := repos.Jobs.Get(ctx, uuid.New()); !errors.Is(err, apperr.ErrNotFound) {
+			t.Errorf("Get missing err = %v, want apperr.ErrNotFound", err)
+		}
+	})
+
+	t.Run("ListAndRetry", func(t *testing.T) {
+		repos := newRepos(t)
+		queue := "repotest-" + uuid.NewString()
+		now := time.Now().UTC().Truncate(time.Microsecond)
+
+		var created []*model.Job
+		for range 3 {
+			created = append(created, mustEnqueue(t, repos.Jobs, queue, "", now))
+		}
+		claimed, err := repos.Jobs.ClaimDue(ctx, queue, now, time.Minute, 1)
+		if err != nil || len(claimed) != 1 {
+			t.Fatalf("ClaimDue = %+v, %v", claimed, err)
+		}
+		dead := claimed[0]
+		finished := now
+		dead.Status = model.JobDead
+		dead.LastError = "permanent"
+		dead.FinishedAt = &finished
+		if err := repos.Jobs.Finish(ctx, &dead); err != nil {
+			t.Fatalf("Finish: %v", err)
+		}
+
+		page, err := repos.Jobs.List(ctx, queue, "", nil, 2)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(page) != 2 {
+			t.Fatalf("List first page = %+v, want 2 jobs", page)
+		}
+		last := page[1]
+		rest, err := repos.Jobs.List(ctx, queue, "", &pagination.Cursor{Time: *last.CreatedAt, ID: last.ID.String()}, 2)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		seen := map[uuid.UUID]bool{}
+		for _, j := range append(page, rest...) {
+			seen[j.ID] = true
+		}
+		if len(rest) != 1 || len(seen) != len(created) {
+			t.Errorf("List pages = %+v, %+v, want all 3 jobs once", page, rest)
+		}
+
+		deadList, err := repos.Jobs.List(ctx, queue, model.JobDead, nil, 10)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(deadList) != 1 || deadList[0].ID != dead.ID || deadList[0].LastError != "permanent" {
+			t.Errorf("List dead = %+v, want the dead job", deadList)
+		}
+
+		if err := repos.Jobs.Retry(ctx, dead.ID, now); err != nil {
+			t.Fatalf("Retry: %v", err)
+		}
+		got, err := repos.Jobs.Get(ctx, dead.ID)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if got.Status != model.JobQueued || got.Attempts != 0 || got.FinishedAt != nil {
+			t.Errorf("Get after Retry = %+v, want queued with no attempts", got)
+		}
+		if err := repos.Jobs.Retry(ctx, dead.ID, now); !errors.Is(err, apperr.ErrConflict) {
+			t.Errorf("Retry queued job err = %v, want apperr.ErrConflict", err)
+		}
+		if err := repos.Jobs.Retry(ctx, uuid.New(), now); !errors.Is(err, apperr.ErrConflict) {
+			t.Errorf("Retry missing job err = %v, want apperr.ErrConflict", err)
+		}
+	})
+
+	t.Run("DeleteFinished", func(t *testing.T) {
+		repos := newRepos(t)
+		queue := "repotest-" + uuid.NewString()
+		now := time.Now().UTC().Truncate(time.Microsecond)
+
+		mustEnqueue(t, repos.Jobs, queue, "", now)
+		mustEnqueue(t, repos.Jobs, queue, "", now)
+		queued := mustEnqueue(t, repos.Jobs, queue, "", now.Add(time.Hour))
+		claimed, err := repos.Jobs.ClaimDue(ctx, queue, now, time.Minute, 10)
+		if err != nil || len(claimed) != 2 {
+			t.Fatalf("ClaimDue = %+v, %v", claimed, err)
+		}
+		old, recent := now.Add(-48*time.Hour), now
+		for i, j := range claimed {
+			j.Status = model.JobSucceeded
+			j.FinishedAt = &old
+			if i == 1 {
+				j.Status = model.JobDead
+				j.FinishedAt = &recent
+			}
+			if err := repos.Jobs.Finish(ctx, &j); err != nil {
+				t.Fatalf("Finish: %v", err)
+			}
+		}
+
+		if _, err := repos.Jobs.DeleteFinished(ctx, now.Add(-24*time.Hour)); err != nil {
+			t.Fatalf("DeleteFinished: %v", err)
+		}
+		left, err := repos.Jobs.List(ctx, queue, "", nil, 10)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		ids := map[uuid.UUID]bool{}
+		for _, j := range left {
+			ids[j.ID] = true
+		}
+		if len(left) != 2 || ids[claimed[0].ID] || !ids[claimed[1].ID] || !ids[queued.ID] {
+			t.Errorf("List after DeleteFinished = %+v, want the recent dead job and the queued job", left)
+		}
+	})
+	t.Run("CountByStatus", func(t *testing.T) {
+		repos := newRepos(t)
+		queue := "repotest-" + uuid.NewString()
+		now := time.Now().UTC().Truncate(time.Microsecond)
+
+		mustEnqueue(t, repos.Jobs, queue, "", now)
+		mustEnqueue(t, repos.Jobs, queue, "", now)
+		mustEnqueue(t, repos.Jobs, queue, "", now.Add(time.Hour))
+		if _, err := repos.Jobs.ClaimDue(ctx, queue, now, time.Minute, 1); err != nil {
+			t.Fatalf("ClaimDue: %v", err)
+		}
+
+		counts, err := repos.Jobs.CountByStatus(ctx, []string{model.JobQueued, model.JobRunning, model.JobDead})
+		if err != nil {
+			t.Fatalf("CountByStatus: %v", err)
+		}
+		got := map[string]int64{}
+		for _, c := range counts {
+			if c.Queue == queue {
+				got[c.Status] = c.Count
+			}
+		}
+		if len(got) != 2 || got[model.JobQueued] != 2 || got[model.JobRunning] != 1 {
+			t.Errorf("CountByStatus = %+v, want 2 queued and 1 running", got)
+		}
+	})
+}
+
+func testTaskRunRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+	repos := newRepos(t)
+	name := "repotest-" + uuid.NewString()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	if _, err := repos.TaskRuns.Get(ctx, name); !errors.Is(err, apperr.ErrNotFound) {
+		t.Fatalf("Get never run err = %v, want apperr.ErrNotFound", err)
+	}
+
+	run := &model.TaskRun{Name: name, Schedule: "@hourly", ScheduledAt: now, StartedAt: now, Status: model.TaskRunning, Host: "host-a"}
+	if err := repos.TaskRuns.Save(ctx, run); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// 同一個名稱覆寫紀錄
+	finished := now.Add(time.Second)
+	run.Status = model.TaskFailed
+	run.FinishedAt = &finished
+	run.Error = "boom"
+	run.DurationMs = 1000
+	if err := repos.TaskRuns.Save(ctx, run); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := repos.TaskRuns.Get(ctx, name)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Status != model.TaskFailed || got.Error != "boom" || got.FinishedAt == nil || !got.FinishedAt.Equal(finished) || !got.ScheduledAt.Equal(now) || got.DurationMs != 1000 {
+		t.Errorf("Get = %+v, want the overwritten run", got)
+	}
+
+	other := &model.TaskRun{Name: "repotest-" + uuid.NewString(), Schedule: "@daily", ScheduledAt: now, StartedAt: now, Status: model.TaskSucceeded}
+	if err := repos.TaskRuns.Save(ctx, other); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	runs, err := repos.TaskRuns.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	// 共用的資料庫可能有其他測試的資料，只檢查這個測試建立的紀錄與順序
+	var mine []string
+	for i, r := range runs {
+		if i > 0 && runs[i-1].Name > r.Name {
+			t.Errorf("List is not ordered by name: %q before %q", runs[i-1].Name, r.Name)
+		}
+		if r.Name == name || r.Name == other.Name {
+			mine = append(mine, r.Name)
+		}
+	}
+	if len(mine) != 2 {
+		t.Errorf("List = %v, want both runs", mine)
+	}
+}
+
+func testLocker(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+	repos := newRepos(t)
+	name := "repotest-" + uuid.NewString()
+
+	unlock, ok, err := repos.Locker.TryLock(ctx, name)
+	if err != nil || !ok {
+		t.Fatalf("TryLock = %v, %v, want the lock", ok, err)
+	}
+	if _, ok, err := repos.Locker.TryLock(ctx, name); err != nil || ok {
+		t.Errorf("TryLock held lock = %v, %v, want false", ok, err)
+	}
+	otherUnlock, ok, err := repos.Locker.TryLock(ctx, name+"-other")
+	if err != nil || !ok {
+		t.Fatalf("TryLock other name = %v, %v, want the lock", ok, err)
+	}
+	otherUnlock()
+
+	unlock()
+	unlock() // 重複釋放不影響
+	again, ok, err := repos.Locker.TryLock(ctx, name)
+	if err != nil || !ok {
+		t.Fatalf("TryLock after unlock = %v, %v, want the lock", ok, err)
+	}
+	again()
+}
+
+// mustCreateEndpoint 建立訂閱 events 的 webhook endpoint
+func mustCreateEndpoint(t *testing.T, repo itfc.WebhookEndpointRepository, email string, events ...string) *model.WebhookEndpoint {
+	t.Helper()
+	ep := &model.WebhookEndpoint{
+		UserEmail:        email,
+		URL:              "https://example.com/hooks",
+		Events:           events,
+		Active:           true,
+		KeyVersion:       1,
+		WrappedKey:       []byte("wrapped"),
+		SecretCiphertext: []byte("ciphertext"),
+	}
+	if err := repo.Create(context.Background(), ep); err != nil {
+		t.Fatalf("Create endpoint: %v", err)
+	}
+	return ep
+}
+
+func testWebhookEndpointRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+	repos := newRepos(t)
+	email := randomEmail()
+
+	first := mustCreateEndpoint(t, repos.Webhooks, email, model.EventAccountConnected)
+	if first.ID == uuid.Nil || first.CreatedAt == nil {
+		t.Errorf("Create did not populate ID and CreatedAt: %+v", first)
+	}
+	// 建立停用的 endpoint 時不會被預設值覆寫
+	second := &model.WebhookEndpoint{UserEmail: email, URL: "https://example.com/other", Events: model.StringList{model.EventMessageReceived}, WrappedKey: []byte("w"), SecretCiphertext: []byte("c")}
+	if err := repos.Webhooks.Create(ctx, second); err != nil {
+		t.Fatalf("Create second: %v", err)
+	}
+	mustCreateEndpoint(t, repos.Webhooks, randomEmail(), model.EventAccountConnected)
+
+	list, err := repos.Webhooks.ListByUser(ctx, email)
+	if err != nil {
+		t.Fatalf("ListByUser: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != first.ID || list[1].ID != second.ID || list[1].Active {
+		t.Errorf("ListByUser = %+v, want [first, inactive second]", list)
+	}
+
+	first.URL = "https://example.com/updated"
+	first.Description = "CRM"
+	first.Events = model.StringList{model.EventAccountConnected, model.EventAccountDisconnected}
+	first.Active = false
+	if err := repos.Webhooks.Update(ctx, first); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	first.KeyVersion = 2
+	first.WrappedKey = []byte("wrapped-2")
+	first.SecretCiphertext = []byte("ciphertext-2")
+	if err := repos.Webhooks.UpdateSecret(ctx, first); err != nil {
+		t.Fatalf("UpdateSecret: %v", err)
+	}
+	got, err := repos.Webhooks.Get(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.URL != first.URL || got.Description != "CRM" || len(got.Events) != 2 || got.Active ||
+		got.KeyVersion != 2 || !bytes.Equal(got.WrappedKey, first.WrappedKey) || !bytes.Equal(got.SecretCiphertext, first.SecretCiphertext) {
+		t.Errorf("Get = %+v, want the updated endpoint", got)
+	}
+	if err := repos.Webhooks.Update(ctx, &model.WebhookEndpoint{ID: uuid.New()}); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("Update missing err = %v, want apperr.ErrNotFound", err)
+	}
+	if err := repos.Webhooks.UpdateSecret(ctx, &model.WebhookEndpoint{ID: uuid.New()}); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("UpdateSecret missing err = %v, want apperr.ErrNotFound", err)
+	}
+
+	if err := repos.Webhooks.Delete(ctx, first.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := repos.Webhooks.Get(ctx, first.ID); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("Get after Delete err = %v, want apperr.ErrNotFound", err)
+	}
+	if err := repos.Webhooks.Delete(ctx, first.ID); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("Delete missing err = %v, want apperr.ErrNotFound", err)
+	}
+}
+
+func testWebhookDeliveryRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+	repos := newRepos(t)
+	ep := mustCreateEndpoint(t, repos.Webhooks, randomEmail(), model.EventAccountConnected)
+	other := mustCreateEndpoint(t, repos.Webhooks, randomEmail(), model.EventAccountConnected)
+
+	eventID := uuid.New()
+	newDelivery := func(endpointID uuid.UUID) *model.WebhookDelivery {
+		return &model.WebhookDelivery{EndpointID: endpointID, EventID: eventID, EventType: model.EventAccountConnected, Payload: `{}`, Status: model.DeliveryPending}
+	}
+
+	first := newDelivery(ep.ID)
+	if err := repos.Deliveries.Create(ctx, first); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := repos.Deliveries.Create(ctx, newDelivery(ep.ID)); !errors.Is(err, apperr.ErrConflict) {
+		t.Errorf("Create duplicate event err = %v, want apperr.ErrConflict", err)
+	}
+	// 同一個事件可以送到其他 endpoint，也可以手動重送
+	if err := repos.Deliveries.Create(ctx, newDelivery(other.ID)); err != nil {
+		t.Errorf("Create same event for another endpoint: %v", err)
+	}
+	redelivery := newDelivery(ep.ID)
+	redelivery.RedeliveryOf = &first.ID
+	if err := repos.Deliveries.Create(ctx, redelivery); err != nil {
+		t.Fatalf("Create redelivery: %v", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	first.Status = model.DeliverySucceeded
+	first.Attempts = 2
+	first.ResponseStatus = 204
+	first.ResponseBody = "ok"
+	first.DurationMs = 12
+	first.LastAttemptAt = &now
+	first.DeliveredAt = &now
+	if err := repos.Deliveries.SaveAttempt(ctx, first); err != nil {
+		t.Fatalf("SaveAttempt: %v", err)
+	}
+	got, err := repos.Deliveries.Get(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Status != model.DeliverySucceeded || got.Attempts != 2 || got.ResponseStatus != 204 || got.ResponseBody != "ok" ||
+		got.DeliveredAt == nil || !got.DeliveredAt.Equal(now) {
+		t.Errorf("Get = %+v, want the saved attempt", got)
+	}
+	if err := repos.Deliveries.SaveAttempt(ctx, &model.WebhookDelivery{ID: uuid.New()}); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("SaveAttempt missing err = %v, want apperr.ErrNotFound", err)
+	}
+
+	page, err := repos.Deliveries.ListByEndpoint(ctx, ep.ID, nil, 1)
+	if err != nil {
+		t.Fatalf("ListByEndpoint: %v", err)
+	}
+	if len(page) != 1 || page[0].ID != redelivery.ID {
+		t.Fatalf("ListByEndpoint page 1 = %+v, want the redelivery", page)
+	}
+	page, err = repos.Deliveries.ListByEndpoint(ctx, ep.ID, &pagination.Cursor{Time: *page[0].CreatedAt, ID: page[0].ID.String()}, 10)
+	if err != nil {
+		t.Fatalf("ListByEndpoint page 2: %v", err)
+	}
+	if len(page) != 1 || page[0].ID != first.ID {
+		t.Errorf("ListByEndpoint page 2 = %+v, want the first delivery", page)
+	}
+
+	if err := repos.Deliveries.DeleteByEndpoint(ctx, ep.ID); err != nil {
+		t.Fatalf("DeleteByEndpoint: %v", err)
+	}
+	if page, _ := repos.Deliveries.ListByEndpoint(ctx, ep.ID, nil, 10); len(page) != 0 {
+		t.Errorf("ListByEndpoint after DeleteByEndpoint = %+v, want none", page)
+	}
+	if page, _ := repos.Deliveries.ListByEndpoint(ctx, other.ID, nil, 10); len(page) != 1 {
+		t.Errorf("ListByEndpoint other endpoint = %+v, want its delivery kept", page)
+	}
+}
+
+func testOutboxRepository(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+	repos := newRepos(t)
+	email := randomEmail()
+	mustCreateUser(t, repos.Users, email)
+
+	// 共用的資料庫可能有其他測試的事件，以很久以前的時間取出，只會取到這個測試建立的事件
+	base := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(rand.IntN(1_000_000)) * time.Minute)
+	mustAdd := func(ctx context.Context, topic string, availableAt time.Time) *model.OutboxEvent {
+		t.Helper()
+		e := &model.OutboxEvent{Topic: topic, Key: "k", Payload: `{}`, AvailableAt: availableAt}
+		if err := repos.Outbox.Create(ctx, e); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		return e
+	}
+
+	// 在交易中寫入的資料變更與事件，commit 後都可以讀到
+	accountID := "repotest-" + uuid.NewString()
+	var first *model.OutboxEvent
+	err := repos.Transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := repos.Unipile.Upsert(ctx, &model.UnipileAccount{UserEmail: email, Provider: "linkedin", AccountID: accountID}); err != nil {
+			return err
+		}
+		// 巢狀呼叫加入外層的交易
+		return repos.Transactor.WithinTx(ctx, func(ctx context.Context) error {
+			first = mustAdd(ctx, "test.first", base)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("WithinTx: %v", err)
+	}
+	if _, err := repos.Unipile.GetByAccountID(ctx, accountID); err != nil {
+		t.Fatalf("GetByAccountID after commit: %v", err)
+	}
+	if err := repos.Transactor.WithinTx(ctx, func(ctx context.Context) error { return apperr.ErrConflict }); !errors.Is(err, apperr.ErrConflict) {
+		t.Errorf("WithinTx err = %v, want the error returned by fn", err)
+	}
+
+	second := mustAdd(ctx, "test.second", base)
+	later := mustAdd(ctx, "test.later", base.Add(time.Hour))
+
+	// 依建立順序取出到期的事件，尚未到期的不會取出
+	claimed, err := repos.Outbox.ClaimPending(ctx, base, time.Minute, 10)
+	if err != nil {
+		t.Fatalf("ClaimPending: %v", err)
+	}
+	if len(claimed) != 2 || claimed[0].ID != first.ID || claimed[1].ID != second.ID {
+		t.Fatalf("ClaimPending = %+v, want first and second in order", claimed)
+	}
+	if claimed[0].Attempts != 1 || !claimed[0].AvailableAt.Equal(base.Add(time.Minute)) || claimed[0].Topic != "test.first" || claimed[0].Payload != `{}` {
+		t.Errorf("ClaimPending[0] = %+v, want attempts 1 and the lease as available_at", claimed[0])
+	}
+	// 租約期間不會再取出
+	if again, err := repos.Outbox.ClaimPending(ctx, base, time.Minute, 10); err != nil || len(again) != 0 {
+		t.Errorf("ClaimPending during lease = %d events, %v, want none", len(again), err)
+	}
+
+	published := base.Add(time.Second)
+	if err := repos.Outbox.MarkPublished(ctx, first.ID, published); err != nil {
+		t.Fatalf("MarkPublished: %v", err)
+	}
+	if err := repos.Outbox.MarkPublished(ctx, uuid.New(), published); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("MarkPublished unknown err = %v, want apperr.ErrNotFound", err)
+	}
+	if err := repos.Outbox.Reschedule(ctx, first.ID, base, "late"); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("Reschedule published err = %v, want apperr.ErrNotFound", err)
+	}
+	if err := repos.Outbox.Reschedule(ctx, second.ID, base.Add(2*time.Minute), "boom"); err != nil {
+		t.Fatalf("Reschedule: %v", err)
+	}
+
+	// 租約到期後，只會取出尚未發布且到期的事件
+	claimed, err = repos.Outbox.ClaimPending(ctx, base.Add(2*time.Minute), time.Minute, 10)
+	if err != nil {
+		t.Fatalf("ClaimPending: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != second.ID || claimed[0].Attempts != 2 || claimed[0].LastError != "boom" {
+		t.Fatalf("ClaimPending after reschedule = %+v, want second with attempts 2", claimed)
+	}
+	claimed, err = repos.Outbox.ClaimPending(ctx, base.Add(time.Hour), time.Minute, 1)
+	if err != nil {
+		t.Fatalf("ClaimPending: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != second.ID {
+		t.Errorf("ClaimPending limit 1 = %+v, want second only", claimed)
+	}
+
+	// 只刪除在 before 之前發布的事件
+	if err := repos.Outbox.MarkPublished(ctx, later.ID, base.Add(time.Hour)); err != nil {
+		t.Fatalf("MarkPublished: %v", err)
+	}
+	n, err := repos.Outbox.DeletePublished(ctx, base.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("DeletePublished: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("DeletePublished = %d, want 1", n)
+	}
+	if err := repos.Outbox.MarkPublished(ctx, first.ID, published); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("MarkPublished deleted err = %v, want apperr.ErrNotFound", err)
+	}
+	if err := repos.Outbox.MarkPublished(ctx, later.ID, base.Add(time.Hour)); err != nil {
+		t.Errorf("MarkPublished kept event: %v", err)
+	}
+}
+
+func testRateLimitStore(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+	repos := newRepos(t)
+	key := "repotest-" + uuid.NewString()
+	// 最多 3 個，每秒補充 1 個
+	bucket := itfc.TokenBucket{Capacity: 3, Rate: 1}
+	now := time.Now().UTC().Truncate(time.Second)
+
+	// Peek 回傳 Take 的結果但不取出 token
+	for range 2 {
+		res, err := repos.RateLimits.Peek(ctx, key, bucket, now)
+		if err != nil {
+			t.Fatalf("Peek: %v", err)
+		}
+		if !res.Allowed || res.Remaining != 2 {
+			t.Fatalf("Peek new bucket = %+v, want allowed with 2 remaining", res)
+		}
+	}
+
+	for i := 2; i >= 0; i-- {
+		res, err := repos.RateLimits.Take(ctx, key, bucket, now)
+		if err != nil {
+			t.Fatalf("Take: %v", err)
+		}
+		if !res.Allowed || res.Remaining != i {
+			t.Fatalf("Take = %+v, want allowed with %d remaining", res, i)
+		}
+	}
+	res, err := repos.RateLimits.Take(ctx, key, bucket, now)
+	if err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+	if res.Allowed || res.RetryAfter != time.Second || res.ResetAfter != 3*time.Second {
+		t.Errorf("Take empty bucket = %+v, want denied, retry after 1s, reset after 3s", res)
+	}
+	if res, err := repos.RateLimits.Peek(ctx, key, bucket, now); err != nil || res.Allowed || res.RetryAfter != time.Second {
+		t.Errorf("Peek empty bucket = %+v, %v, want denied, retry after 1s", res, err)
+	}
+
+	// 其他 key 不受影響
+	if res, err := repos.RateLimits.Take(ctx, key+"-other", bucket, now); err != nil || !res.Allowed {
+		t.Errorf("Take other key = %+v, %v, want allowed", res, err)
+	}
+
+	// 1.5 秒後補充了一個半，取出一個後剩下半個
+	res, err = repos.RateLimits.Take(ctx, key, bucket, now.Add(1500*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+	if !res.Allowed || res.Remaining != 0 || res.ResetAfter != 2500*time.Millisecond {
+		t.Errorf("Take after refill = %+v, want allowed with 0 remaining, reset after 2.5s", res)
+	}
+
+	// 補滿之後可以刪除，刪除後與補滿的 bucket 相同
+	if _, err := repos.RateLimits.DeleteExpired(ctx, now.Add(3*time.Second)); err != nil {
+		t.Fatalf("DeleteExpired: %v", err)
+	}
+	res, err = repos.RateLimits.Take(ctx, key, bucket, now.Add(2*time.Second))
+	if err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+	if !res.Allowed || res.Remaining != 0 {
+		t.Errorf("Take before expiry = %+v, want the bucket to be kept", res)
+	}
+	// 自行讓 bucket 到期的實作 (Redis) 回傳 0，刪除與否都不影響結果
+	if _, err := repos.RateLimits.DeleteExpired(ctx, now.Add(time.Hour)); err != nil {
+		t.Fatalf("DeleteExpired: %v", err)
+	}
+	res, err = repos.RateLimits.Take(ctx, key, bucket, now.Add(time.Hour))
+	if err != nil || !res.Allowed || res.Remaining != 2 {
+		t.Errorf("Take after DeleteExpired = %+v, %v, want a full bucket", res, err)
+	}
+}
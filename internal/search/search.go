@@ -0,0 +1,170 @@
+// Package search 提供全文搜尋共用的字詞切分、排序分數與標記。
+//
+// Postgres 使用 tsvector 與 ts_headline；記憶體實作以 Tokens 切分的字詞比對，
+// 並使用這裡的規則計算分數與標記。兩者都以完整的字詞比對，"budg" 不會符合 "budget"。
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 標記符合字詞的起訖字元 (Unicode 私用區)，在輸出前才轉換為 HTML，避免與訊息內容混淆
+const (
+	HighlightStart = "\uE000"
+	HighlightStop  = "\uE001"
+)
+
+// snippetRunes 標記結果的最大長度，超過時只保留第一個符合字詞附近的內容
+const snippetRunes = 200
+
+// Terms 將查詢切分為小寫的字詞，所有字詞都必須符合
+func Terms(q string) []string {
+	return Tokens(q)
+}
+
+// Tokens 將 s 切分為小寫的字詞：連續的字母與數字為一個字詞，其他字元都是分隔
+// 與 Postgres 'simple' 設定的切分方式相同 (但 email 與 URL 也會被切開)
+func Tokens(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), isSeparator)
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// tokenSet 回傳 s 中所有字詞的集合
+func tokenSet(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, t := range Tokens(s) {
+		set[t] = true
+	}
+	return set
+}
+
+// Rank 計算符合程度：每個字詞出現在內文得 1 分、只出現在寄件者名稱得 0.5 分，再除以字詞數
+// 回傳 0 代表有字詞完全不符合
+func Rank(text, senderName string, terms []string) float64 {
+	if len(terms) == 0 {
+		return 0
+	}
+
+	textTokens, senderTokens := tokenSet(text), tokenSet(senderName)
+	var score float64
+	for _, t := range terms {
+		switch {
+		case textTokens[t]:
+			score += 1
+		case senderTokens[t]:
+			score += 0.5
+		default:
+			return 0
+		}
+	}
+
+	return score / float64(len(terms))
+}
+
+// MatchesAll 回傳 s 是否包含所有字詞 (不分大小寫)
+func MatchesAll(s string, terms []string) bool {
+	tokens := tokenSet(s)
+	for _, t := range terms {
+		if !tokens[t] {
+			return false
+		}
+	}
+	return len(terms) > 0
+}
+
+// Highlight 以 HighlightStart / HighlightStop 標記內文中符合的完整字詞 (不分大小寫)
+func Highlight(text string, terms []string) string {
+	lower := strings.ToLower(text)
+	// 大小寫轉換改變長度時 (少數語言)，無法對應位置，只回傳原文
+	if len(lower) != len(text) {
+		return snippet(text, 0)
+	}
+
+	marked := make([]bool, len(text))
+	first := -1
+	for _, t := range terms {
+		for i := 0; ; {
+			j := strings.Index(lower[i:], t)
+			if j < 0 || t == "" {
+				break
+			}
+			// 只標記完整的字詞，不標記其他字詞的一部分
+			if !isWord(lower, i+j, i+j+len(t)) {
+				i += j + 1
+				continue
+			}
+			for k := i + j; k < i+j+len(t); k++ {
+				marked[k] = true
+			}
+			if first < 0 || i+j < first {
+				first = i + j
+			}
+			i += j + len(t)
+		}
+	}
+
+	var b strings.Builder
+	in := false
+	for i := 0; i < len(text); i++ {
+		if marked[i] != in {
+			if marked[i] {
+				b.WriteString(HighlightStart)
+			} else {
+				b.WriteString(HighlightStop)
+			}
+			in = marked[i]
+		}
+		b.WriteByte(text[i])
+	}
+	if in {
+		b.WriteString(HighlightStop)
+	}
+
+	return snippet(b.String(), max(first, 0))
+}
+
+// isWord 回傳 s[start:end] 前後是否都是分隔字元或字串的開頭與結尾
+func isWord(s string, start, end int) bool {
+	if r, _ := utf8.DecodeLastRuneInString(s[:start]); start > 0 && !isSeparator(r) {
+		return false
+	}
+	if r, _ := utf8.DecodeRuneInString(s[end:]); end < len(s) && !isSeparator(r) {
+		return false
+	}
+	return true
+}
+
+// snippet 內容過長時，從 offset 前約 60 個字元開始截取
+func snippet(s string, offset int) string {
+	if utf8.RuneCountInString(s) <= snippetRunes {
+		return s
+	}
+
+	start := 0
+	if runesBefore := utf8.RuneCountInString(s[:min(offset, len(s))]); runesBefore > 60 {
+		start = runesBefore - 60
+	}
+
+	runes := []rune(s)
+	end := min(start+snippetRunes, len(runes))
+	out := string(runes[start:end])
+	// 截斷時補上未關閉的標記
+	if strings.Count(out, HighlightStart) > strings.Count(out, HighlightStop) {
+		out += HighlightStop
+	}
+	if strings.Count(out, HighlightStop) > strings.Count(out, HighlightStart) {
+		out = HighlightStart + out
+	}
+	if start > 0 {
+		out = "…" + out
+	}
+	if end < len(runes) {
+		out += "…"
+	}
+	return out
+}
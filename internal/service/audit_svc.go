@@ -0,0 +1,115 @@
+package service
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// exportBatchSize 匯出稽核事件時每次讀取的筆數
+const exportBatchSize = 500
+
+// AuditEntry 描述一個要記錄的事件，Err 為 nil 代表成功
+type AuditEntry struct {
+	ActorEmail string
+	Action     string
+	TargetType string
+	TargetID   string
+	IP         string
+	UserAgent  string
+	Err        error
+}
+
+// AuditLogger 記錄與查詢安全相關的稽核事件
+type AuditLogger struct {
+	auditRepo itfc.AuditRepository
+}
+
+func NewAuditLogger(repo itfc.AuditRepository) *AuditLogger {
+	return &AuditLogger{auditRepo: repo}
+}
+
+// Log 寫入一筆稽核事件
+// 稽核失敗不應影響使用者的請求，因此只記錄錯誤而不回傳
+func (l *AuditLogger) Log(ctx context.Context, entry AuditEntry) {
+	event := &model.AuditEvent{
+		ActorEmail: entry.ActorEmail,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		IP:         entry.IP,
+		UserAgent:  entry.UserAgent,
+		Result:     model.AuditSuccess,
+		// Postgres 只保存到微秒，先截斷讓各種實作的游標一致
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if entry.Err != nil {
+		event.Result = model.AuditFailure
+		event.Reason = auditReason(entry.Err)
+	}
+
+	// 即使客戶端已斷線，事件仍然要寫入
+	if err := l.auditRepo.Append(context.WithoutCancel(ctx), event); err != nil {
+		slog.ErrorContext(ctx, "Failed to write audit event", "action", entry.Action, "actor", entry.ActorEmail, "err", err)
+	}
+}
+
+// List 分頁列出稽核事件，actorEmail 為空字串代表所有使用者
+// 回傳的 nextCursor 為空字串代表沒有下一頁
+func (l *AuditLogger) List(ctx context.Context, actorEmail, cursor string, limit int) ([]model.AuditEvent, string, error) {
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return nil, "", apperr.Wrap(apperr.ErrValidation, "Invalid cursor", err)
+	}
+
+	limit = pagination.Limit(limit)
+	events, err := l.auditRepo.List(ctx, itfc.AuditFilter{ActorEmail: actorEmail, After: after, Limit: limit})
+	if err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(events) == limit {
+		last := events[len(events)-1]
+		next = pagination.Cursor{Time: last.CreatedAt, ID: last.ID.String()}.Encode()
+	}
+
+	return events, next, nil
+}
+
+// Export 依序將所有符合條件的稽核事件交給 fn，用於串流匯出而不需一次載入記憶體
+func (l *AuditLogger) Export(ctx context.Context, actorEmail string, fn func(model.AuditEvent) error) error {
+	var after *pagination.Cursor
+	for {
+		events, err := l.auditRepo.List(ctx, itfc.AuditFilter{ActorEmail: actorEmail, After: after, Limit: exportBatchSize})
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+
+		if len(events) < exportBatchSize {
+			return nil
+		}
+		last := events[len(events)-1]
+		after = &pagination.Cursor{Time: last.CreatedAt, ID: last.ID.String()}
+	}
+}
+
+// auditReason 取得可以安全記錄的失敗原因，避免寫入底層錯誤的細節
+func auditReason(err error) string {
+	var appErr *apperr.Error
+	if errors.As(err, &appErr) {
+		return appErr.Message
+	}
+	return "internal error"
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/model"
+	"chatsheet/internal/repository/memory"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAuditLogRecordsSafeReason(t *testing.T) {
+	ctx := context.Background()
+	l := NewAuditLogger(memory.NewAuditRepository())
+
+	l.Log(ctx, AuditEntry{ActorEmail: "a@example.com", Action: model.AuditUserLogin})
+	l.Log(ctx, AuditEntry{ActorEmail: "a@example.com", Action: model.AuditUserLogin, Err: apperr.Unauthorized("Invalid email or password")})
+	// 底層錯誤可能包含連線字串或憑證，不能寫入稽核紀錄
+	l.Log(ctx, AuditEntry{ActorEmail: "a@example.com", Action: model.AuditUserLogin, Err: errors.New("dial postgres://user:secret@db")})
+
+	events, next, err := l.List(ctx, "a@example.com", "", 10)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(events) != 3 || next != "" {
+		t.Fatalf("List = %d events, next %q; want 3 events and no next page", len(events), next)
+	}
+
+	// 同一微秒內寫入的事件順序不固定，依原因比對
+	want := map[string]string{
+		"":                          model.AuditSuccess,
+		"Invalid email or password": model.AuditFailure,
+		"internal error":            model.AuditFailure,
+	}
+	for _, e := range events {
+		result, ok := want[e.Reason]
+		if !ok || e.Result != result {
+			t.Errorf("event = %s %q, want one of %v", e.Result, e.Reason, want)
+		}
+		delete(want, e.Reason)
+	}
+}
+
+func TestAuditListPagesAndFiltersByActor(t *testing.T) {
+	ctx := context.Background()
+	l := NewAuditLogger(memory.NewAuditRepository())
+
+	for range 5 {
+		l.Log(ctx, AuditEntry{ActorEmail: "a@example.com", Action: model.AuditUserLogin})
+	}
+	l.Log(ctx, AuditEntry{ActorEmail: "b@example.com", Action: model.AuditUserLogin})
+
+	seen := map[string]bool{}
+	cursor := ""
+	for page := 0; ; page++ {
+		events, next, err := l.List(ctx, "a@example.com", cursor, 2)
+		if err != nil {
+			t.Fatalf("List page %d: %v", page, err)
+		}
+		for _, e := range events {
+			if e.ActorEmail != "a@example.com" {
+				t.Errorf("List returned another actor's event: %+v", e)
+			}
+			if seen[e.ID.String()] {
+				t.Errorf("event %s returned twice", e.ID)
+			}
+			seen[e.ID.String()] = true
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if len(seen) != 5 {
+		t.Errorf("listed %d events, want 5", len(seen))
+	}
+
+	if _, _, err := l.List(ctx, "", "not-a-cursor", 2); !errors.Is(err, apperr.ErrValidation) {
+		t.Errorf("List with invalid cursor err = %v, want apperr.ErrValidation", err)
+	}
+}
+
+func TestAuditExportReadsAllBatches(t *testing.T) {
+	ctx := context.Background()
+	l := NewAuditLogger(memory.NewAuditRepository())
+
+	total := exportBatchSize + 3
+	for range total {
+		l.Log(ctx, AuditEntry{ActorEmail: "a@example.com", Action: model.AuditUserLogin})
+	}
+
+	seen := map[string]bool{}
+	err := l.Export(ctx, "a@example.com", func(e model.AuditEvent) error {
+		seen[e.ID.String()] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if len(seen) != total {
+		t.Errorf("exported %d events, want %d", len(seen), total)
+	}
+}
@@ -9,6 +9,7 @@ import (
 // Claims 定義 JWT 中包含的資料
 type Claims struct {
 	Email string `json:"email"`
+	Role  string `json:"role"`
 	jwt.RegisteredClaims
 }
 
@@ -20,10 +21,11 @@ func NewAuthService(JWTSecret string) *AuthService {
 	return &AuthService{JWTSecret: JWTSecret}
 }
 
-// GenerateToken 根據使用者 ID 產生 JWT
-func (s *AuthService) GenerateToken(email string) (string, error) {
+// GenerateToken 根據使用者 ID 與角色產生 JWT
+func (s *AuthService) GenerateToken(email, role string) (string, error) {
 	claims := &Claims{
 		Email: email,
+		Role:  role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24)), // Token 有效期限 24 小時
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -0,0 +1,600 @@
+package service
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// maxCampaignSteps 一個外展活動最多的步驟數
+const maxCampaignSteps = 20
+
+// maxEnrollProspects 一次報名的對象數量上限
+const maxEnrollProspects = 500
+
+// CampaignInput 是建立外展活動的內容
+type CampaignInput struct {
+	AccountID string
+	Name      string
+	Steps     []model.CampaignStep
+}
+
+// Prospect 是要報名外展活動的對象
+type Prospect struct {
+	ProviderID string
+	Name       string
+}
+
+// CampaignService 管理外展活動，並以排程器透過連結的帳號依序執行每位對象的步驟
+//
+// 排程器每 cfg.PollInterval 取出到期的報名 (EnrollmentRepository.ClaimDue)，
+// 多個程序可以同時執行，同一筆報名只會被其中一個取出。
+// 對方回覆時 (同步或 webhook 寫入的訊息，或執行步驟前檢查) 報名自動停止。
+// 每次狀態改變都會記錄在 EnrollmentEvent。
+type CampaignService struct {
+	cfg            config.CampaignsConfig
+	unipileSvc     *UnipileService
+	inboxSvc       *InboxService
+	invitationSvc  *InvitationService
+	campaignRepo   itfc.CampaignRepository
+	enrollmentRepo itfc.EnrollmentRepository
+	messageRepo    itfc.MessageRepository
+}
+
+func NewCampaignService(
+	cfg config.CampaignsConfig,
+	unipileSvc *UnipileService,
+	inboxSvc *InboxService,
+	invitationSvc *InvitationService,
+	campaignRepo itfc.CampaignRepository,
+	enrollmentRepo itfc.EnrollmentRepository,
+	messageRepo itfc.MessageRepository,
+) *CampaignService {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Minute
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 20
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 10 * time.Minute
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = 15 * time.Minute
+	}
+	if cfg.MaxRetryDelay <= 0 {
+		cfg.MaxRetryDelay = 6 * time.Hour
+	}
+
+	return &CampaignService{
+		cfg:            cfg,
+		unipileSvc:     unipileSvc,
+		inboxSvc:       inboxSvc,
+		invitationSvc:  invitationSvc,
+		campaignRepo:   campaignRepo,
+		enrollmentRepo: enrollmentRepo,
+		messageRepo:    messageRepo,
+	}
+}
+
+// Create 建立外展活動，帳號必須屬於該使用者
+func (s *CampaignService) Create(ctx context.Context, email string, in CampaignInput) (*model.Campaign, error) {
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return nil, apperr.Validation("name is required")
+	}
+	if err := validateSteps(in.Steps); err != nil {
+		return nil, err
+	}
+	if _, err := s.unipileSvc.Get(ctx, email, in.AccountID); err != nil {
+		return nil, err
+	}
+
+	campaign := &model.Campaign{
+		UserEmail: email,
+		AccountID: in.AccountID,
+		Name:      name,
+		Status:    model.CampaignActive,
+		Steps:     in.Steps,
+	}
+	if err := s.campaignRepo.Create(ctx, campaign); err != nil {
+		return nil, err
+	}
+
+	return campaign, nil
+}
+
+func validateSteps(steps []model.CampaignStep) error {
+	if len(steps) == 0 || len(steps) > maxCampaignSteps {
+		return apperr.Validation(fmt.Sprintf("steps must have 1 to %d items", maxCampaignSteps))
+	}
+	for i, step := range steps {
+		if step.DelayHours < 0 {
+			return apperr.Validation(fmt.Sprintf("steps[%d].delay_hours must not be negative", i))
+		}
+		switch step.Action {
+		case model.StepInvite:
+			if utf8.RuneCountInString(step.Text) > maxInvitationNote {
+				return apperr.Validation(fmt.Sprintf("steps[%d].text must be at most 300 characters", i))
+			}
+		case model.StepMessage:
+			if strings.TrimSpace(step.Text) == "" {
+				return apperr.Validation(fmt.Sprintf("steps[%d].text is required", i))
+			}
+		default:
+			return apperr.Validation(fmt.Sprintf("steps[%d].action must be invite or message", i))
+		}
+	}
+	return nil
+}
+
+// List 列出使用者的外展活動
+func (s *CampaignService) List(ctx context.Context, email string) ([]model.Campaign, error) {
+	return s.campaignRepo.ListByUser(ctx, email)
+}
+
+// Get 取得外展活動，活動不存在或不屬於該使用者時回傳 apperr.ErrNotFound
+func (s *CampaignService) Get(ctx context.Context, email, campaignID string) (*model.Campaign, error) {
+	id, err := uuid.Parse(campaignID)
+	if err != nil {
+		return nil, apperr.NotFound("Campaign not found")
+	}
+
+	campaign, err := s.campaignRepo.Get(ctx, id)
+	if errors.Is(err, apperr.ErrNotFound) || (err == nil && campaign.UserEmail != email) {
+		return nil, apperr.NotFound("Campaign not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return campaign, nil
+}
+
+// Pause 暫停外展活動，暫停期間不執行任何步驟
+func (s *CampaignService) Pause(ctx context.Context, email, campaignID string) (*model.Campaign, error) {
+	return s.setStatus(ctx, email, campaignID, model.CampaignPaused)
+}
+
+// Resume 恢復外展活動，暫停期間到期的步驟會盡快執行
+func (s *CampaignService) Resume(ctx context.Context, email, campaignID string) (*model.Campaign, error) {
+	return s.setStatus(ctx, email, campaignID, model.CampaignActive)
+}
+
+func (s *CampaignService) setStatus(ctx context.Context, email, campaignID, status string) (*model.Campaign, error) {
+	campaign, err := s.Get(ctx, email, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	if campaign.Status == status {
+		return campaign, nil
+	}
+
+	if err := s.campaignRepo.UpdateStatus(ctx, campaign.ID, status); err != nil {
+		return nil, err
+	}
+	campaign.Status = status
+
+	return campaign, nil
+}
+
+// Enroll 讓對象報名外展活動，第一個步驟在 delay_hours 之後執行
+// 已經報名過的對象會被略過，回傳新增的報名與略過的數量
+func (s *CampaignService) Enroll(ctx context.Context, email, campaignID string, prospects []Prospect) ([]model.Enrollment, int, error) {
+	if len(prospects) == 0 || len(prospects) > maxEnrollProspects {
+		return nil, 0, apperr.Validation(fmt.Sprintf("prospects must have 1 to %d items", maxEnrollProspects))
+	}
+	for i, p := range prospects {
+		if strings.TrimSpace(p.ProviderID) == "" {
+			return nil, 0, apperr.Validation(fmt.Sprintf("prospects[%d].provider_id is required", i))
+		}
+	}
+
+	campaign, err := s.Get(ctx, email, campaignID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	nextRunAt := now.Add(stepDelay(campaign.Steps[0]))
+	enrollments := []model.Enrollment{}
+	skipped := 0
+	for _, p := range prospects {
+		providerID := strings.TrimSpace(p.ProviderID)
+		e := &model.Enrollment{
+			CampaignID: campaign.ID,
+			AccountID:  campaign.AccountID,
+			ProviderID: providerID,
+			Name:       p.Name,
+			Status:     model.EnrollmentActive,
+			NextRunAt:  &nextRunAt,
+		}
+		ev := &model.EnrollmentEvent{
+			CampaignID: campaign.ID,
+			ProviderID: providerID,
+			ToStatus:   model.EnrollmentActive,
+			Detail:     "enrolled by " + email,
+			CreatedAt:  now,
+		}
+		err := s.enrollmentRepo.Create(ctx, e, ev)
+		if errors.Is(err, apperr.ErrConflict) {
+			skipped++
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		enrollments = append(enrollments, *e)
+	}
+
+	return enrollments, skipped, nil
+}
+
+// ListEnrollments 以建立時間由新到舊分頁列出活動的報名
+// 回傳的 nextCursor 為空字串代表沒有下一頁
+func (s *CampaignService) ListEnrollments(ctx context.Context, email, campaignID, cursor string, limit int) ([]model.Enrollment, string, error) {
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return nil, "", apperr.Wrap(apperr.ErrValidation, "Invalid cursor", err)
+	}
+
+	campaign, err := s.Get(ctx, email, campaignID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	limit = pagination.Limit(limit)
+	enrollments, err := s.enrollmentRepo.ListByCampaign(ctx, campaign.ID, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(enrollments) == limit {
+		last := enrollments[len(enrollments)-1]
+		next = pagination.Cursor{Time: *last.CreatedAt, ID: last.ID.String()}.Encode()
+	}
+
+	return enrollments, next, nil
+}
+
+// Stop 手動停止進行中的報名，已結束的報名回傳 apperr.ErrConflict
+func (s *CampaignService) Stop(ctx context.Context, email, campaignID, enrollmentID string) (*model.Enrollment, error) {
+	campaign, err := s.Get(ctx, email, campaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.Parse(enrollmentID)
+	if err != nil {
+		return nil, apperr.NotFound("Enrollment not found")
+	}
+	e, err := s.enrollmentRepo.Get(ctx, id)
+	if errors.Is(err, apperr.ErrNotFound) || (err == nil && e.CampaignID != campaign.ID) {
+		return nil, apperr.NotFound("Enrollment not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.transition(ctx, e, model.EnrollmentStopped, "stopped by "+email)
+	if errors.Is(err, apperr.ErrConflict) {
+		return nil, apperr.Conflict("Enrollment is not active")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// History 以時間由新到舊分頁列出活動所有報名的狀態歷史
+// 回傳的 nextCursor 為空字串代表沒有下一頁
+func (s *CampaignService) History(ctx context.Context, email, campaignID, cursor string, limit int) ([]model.EnrollmentEvent, string, error) {
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return nil, "", apperr.Wrap(apperr.ErrValidation, "Invalid cursor", err)
+	}
+
+	campaign, err := s.Get(ctx, email, campaignID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	limit = pagination.Limit(limit)
+	events, err := s.enrollmentRepo.ListEvents(ctx, campaign.ID, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(events) == limit {
+		last := events[len(events)-1]
+		next = pagination.Cursor{Time: last.CreatedAt, ID: last.ID.String()}.Encode()
+	}
+
+	return events, next, nil
+}
+
+// HandleReply 在收到對方的訊息時停止帳號與對方之間所有進行中的報名
+// 只計算報名之後收到的訊息，由 SyncService.OnInboundMessage 呼叫
+func (s *CampaignService) HandleReply(ctx context.Context, msg *model.Message) {
+	if msg.IsSender || msg.SenderID == "" {
+		return
+	}
+
+	enrollments, err := s.enrollmentRepo.ListActiveByProspect(ctx, msg.AccountID, msg.SenderID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list enrollments for reply", "account_id", msg.AccountID, "provider_id", msg.SenderID, "err", err)
+		return
+	}
+
+	for _, e := range enrollments {
+		if e.CreatedAt != nil && msg.SentAt.Before(*e.CreatedAt) {
+			continue
+		}
+		err := s.transition(ctx, &e, model.EnrollmentReplied, "reply "+msg.UnipileID)
+		if err != nil && !errors.Is(err, apperr.ErrConflict) {
+			slog.ErrorContext(ctx, "Failed to stop enrollment on reply", "enrollment_id", e.ID, "err", err)
+		}
+	}
+}
+
+// Run 定期執行到期的步驟，阻塞直到 ctx 結束
+func (s *CampaignService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.RunDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue 執行所有到期的步驟，直到沒有到期的報名
+func (s *CampaignService) RunDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		enrollments, err := s.enrollmentRepo.ClaimDue(ctx, time.Now().UTC(), s.cfg.Lease, s.cfg.BatchSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to claim due enrollments", "err", err)
+			return
+		}
+
+		campaigns := map[uuid.UUID]*model.Campaign{}
+		for _, e := range enrollments {
+			campaign, ok := campaigns[e.CampaignID]
+			if !ok {
+				campaign, err = s.campaignRepo.Get(ctx, e.CampaignID)
+				if err != nil {
+					slog.ErrorContext(ctx, "Failed to get campaign", "campaign_id", e.CampaignID, "err", err)
+					continue
+				}
+				campaigns[e.CampaignID] = campaign
+			}
+
+			if err := s.runStep(ctx, campaign, e); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Failed to run campaign step", "enrollment_id", e.ID, "err", err)
+			}
+		}
+
+		if len(enrollments) < s.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+// runStep 執行報名目前的步驟並記錄結果
+// 租約結束前沒有記錄結果 (例如程序中斷) 時，步驟會再被取出執行
+func (s *CampaignService) runStep(ctx context.Context, campaign *model.Campaign, e model.Enrollment) error {
+	// webhook 或同步可能延遲，執行前再確認對方是否已經回覆
+	replied, err := s.hasReplied(ctx, e)
+	if err != nil {
+		return err
+	}
+	if replied {
+		return ignoreConflict(s.transition(ctx, &e, model.EnrollmentReplied, "reply found before step"))
+	}
+
+	if e.CurrentStep >= len(campaign.Steps) {
+		return ignoreConflict(s.transition(ctx, &e, model.EnrollmentCompleted, ""))
+	}
+
+	stepIndex := e.CurrentStep
+	step := campaign.Steps[stepIndex]
+	stepErr := s.execute(ctx, campaign, &e, step)
+	if stepErr != nil && ctx.Err() != nil {
+		// 正在關機，租約結束後再執行
+		return nil
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	status := model.EnrollmentActive
+	var detail string
+	if retryAt, ok := quotaRetryAt(stepErr); ok {
+		// 帳號配額用完或不在工作時間，延到可以執行時，不算失敗
+		detail = stepErr.Error()
+		e.NextRunAt = &retryAt
+	} else if stepErr != nil {
+		e.Attempts++
+		e.LastError = stepErr.Error()
+		detail = stepErr.Error()
+		if e.Attempts >= s.cfg.MaxAttempts || permanentStepError(stepErr) {
+			status = model.EnrollmentFailed
+			e.NextRunAt = nil
+		} else {
+			retryAt := now.Add(s.retryDelay(e.Attempts))
+			e.NextRunAt = &retryAt
+		}
+	} else {
+		e.CurrentStep++
+		e.Attempts = 0
+		e.LastError = ""
+		if e.CurrentStep >= len(campaign.Steps) {
+			status = model.EnrollmentCompleted
+			e.NextRunAt = nil
+		} else {
+			nextRunAt := now.Add(stepDelay(campaign.Steps[e.CurrentStep]))
+			e.NextRunAt = &nextRunAt
+		}
+	}
+
+	// 步驟已經執行，即使 ctx 已結束也要記錄，避免重複送出
+	// e.LeaseID 是取出時的租約，租約到期後被其他程序重新取出時不會覆寫它的結果
+	ev := &model.EnrollmentEvent{
+		CampaignID: campaign.ID,
+		ProviderID: e.ProviderID,
+		Step:       stepIndex,
+		Action:     step.Action,
+		FromStatus: e.Status,
+		ToStatus:   status,
+		Detail:     detail,
+		CreatedAt:  now,
+	}
+	fromStatus := e.Status
+	e.Status = status
+	err = s.enrollmentRepo.Transition(context.WithoutCancel(ctx), &e, fromStatus, ev)
+	if errors.Is(err, apperr.ErrConflict) {
+		// 執行期間對方回覆、使用者停止了報名，或租約已經到期被其他程序取出
+		slog.InfoContext(ctx, "Enrollment changed while running step", "enrollment_id", e.ID)
+		return nil
+	}
+
+	return err
+}
+
+// retryDelay 第 attempts 次失敗後的重試間隔，每次加倍直到 MaxRetryDelay
+func (s *CampaignService) retryDelay(attempts int) time.Duration {
+	delay := s.cfg.RetryDelay
+	for i := 1; i < attempts && delay < s.cfg.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, s.cfg.MaxRetryDelay)
+}
+
+// execute 透過活動的帳號執行步驟，以建立活動的使用者身分驗證帳號
+func (s *CampaignService) execute(ctx context.Context, campaign *model.Campaign, e *model.Enrollment, step model.CampaignStep) error {
+	switch step.Action {
+	case model.StepInvite:
+		_, err := s.invitationSvc.Send(ctx, campaign.UserEmail, campaign.AccountID, e.ProviderID, step.Text)
+		return err
+	case model.StepMessage:
+		// 以對方的 provider id 送出，Unipile 會沿用既有的一對一對話，不需要等對話同步到本地
+		started, err := s.inboxSvc.StartChat(ctx, campaign.UserEmail, campaign.AccountID, []string{e.ProviderID}, OutgoingMessage{Text: step.Text})
+		if err != nil {
+			return err
+		}
+		e.ChatID = started.ChatID
+		return nil
+	default:
+		return apperr.Validation("unknown step action " + step.Action)
+	}
+}
+
+// hasReplied 檢查本地是否有報名之後對方送出的訊息
+func (s *CampaignService) hasReplied(ctx context.Context, e model.Enrollment) (bool, error) {
+	received := false
+	filter := itfc.MessageFilter{AccountID: e.AccountID, SenderID: e.ProviderID, IsSender: &received, Limit: 1}
+	if e.CreatedAt != nil {
+		filter.Since = *e.CreatedAt
+	}
+
+	msgs, err := s.messageRepo.List(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return len(msgs) > 0, nil
+}
+
+// transition 把進行中的報名結束為 status 並記錄歷史
+// 報名在讀取後被排程器取出時租約已經改變，重新讀取後再試；已經結束的報名回傳 apperr.ErrConflict
+func (s *CampaignService) transition(ctx context.Context, e *model.Enrollment, status, detail string) error {
+	for {
+		ev := &model.EnrollmentEvent{
+			CampaignID: e.CampaignID,
+			ProviderID: e.ProviderID,
+			Step:       e.CurrentStep,
+			FromStatus: model.EnrollmentActive,
+			ToStatus:   status,
+			Detail:     detail,
+			CreatedAt:  time.Now().UTC().Truncate(time.Microsecond),
+		}
+
+		next := *e
+		next.Status = status
+		next.NextRunAt = nil
+		err := s.enrollmentRepo.Transition(ctx, &next, model.EnrollmentActive, ev)
+		if err == nil {
+			*e = next
+			return nil
+		}
+		if !errors.Is(err, apperr.ErrConflict) {
+			return err
+		}
+
+		current, err := s.enrollmentRepo.Get(ctx, e.ID)
+		if err != nil {
+			return err
+		}
+		if current.Status != model.EnrollmentActive || sameLeaseID(current.LeaseID, e.LeaseID) {
+			return apperr.ErrConflict
+		}
+		*e = *current
+	}
+}
+
+// sameLeaseID 比較兩個租約 id，兩者皆為 nil 時視為相同
+func sameLeaseID(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// ignoreConflict 報名已經被其他程序結束時不視為錯誤
+func ignoreConflict(err error) error {
+	if errors.Is(err, apperr.ErrConflict) {
+		return nil
+	}
+	return err
+}
+
+// permanentStepError 重試也不會成功的錯誤，例如帳號已被移除或內容不合法
+func permanentStepError(err error) bool {
+	return errors.Is(err, apperr.ErrNotFound) || errors.Is(err, apperr.ErrValidation)
+}
+
+// quotaRetryAt 回傳被帳號配額擋下的步驟可以再執行的時間
+func quotaRetryAt(err error) (time.Time, bool) {
+	var appErr *apperr.Error
+	if !errors.As(err, &appErr) || !errors.Is(err, apperr.ErrTooManyRequests) {
+		return time.Time{}, false
+	}
+	exceeded, ok := appErr.Details.(QuotaExceeded)
+	if !ok {
+		return time.Time{}, false
+	}
+	return exceeded.RetryAt, true
+}
+
+func stepDelay(step model.CampaignStep) time.Duration {
+	return time.Duration(step.DelayHours) * time.Hour
+}
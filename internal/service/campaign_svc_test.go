@@ -0,0 +1,255 @@
+package service
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/outbox"
+	"chatsheet/internal/repository/memory"
+	"chatsheet/internal/unipile"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testCampaigns 是 newTestCampaignService 建立的服務與 Unipile 收到的邀請、訊息數
+type testCampaigns struct {
+	svc         *CampaignService
+	enrollments itfc.EnrollmentRepository
+	messages    itfc.MessageRepository
+	invites     atomic.Int32
+	sent        atomic.Int32
+}
+
+// newTestCampaignService 建立屬於 owner@example.com 的帳號 acc-1，Unipile 接受所有邀請與訊息
+func newTestCampaignService(t *testing.T, cfg config.CampaignsConfig) *testCampaigns {
+	t.Helper()
+
+	tc := &testCampaigns{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == unipile.InviteEndpoint:
+			tc.invites.Add(1)
+			json.NewEncoder(w).Encode(unipile.InvitationSent{Object: "UserInvitationSent", InvitationID: "inv-1"})
+		case r.Method == http.MethodPost && r.URL.Path == unipile.ChatsEndpoint:
+			tc.sent.Add(1)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(unipile.ChatStarted{Object: "ChatStarted", ChatID: "chat-1", MessageID: "msg-1"})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	client := unipile.NewClient(config.UnipileConfig{APIBaseURL: srv.URL})
+
+	unipileRepo := newTestUnipileRepository(t, "owner@example.com")
+	unipileSvc := NewUnipileService(unipileRepo, memory.NewTransactor(), outbox.NewWriter(memory.NewOutboxRepository()))
+	if _, err := unipileSvc.Create(context.Background(), "owner@example.com", "linkedin", "acc-1"); err != nil {
+		t.Fatalf("Create account: %v", err)
+	}
+	quotaSvc := NewQuotaService(config.QuotasConfig{}, unipileSvc, memory.NewQuotaRepository())
+	tc.messages = memory.NewMessageRepository()
+	inboxSvc := NewInboxService(unipileSvc, client, tc.messages, memory.NewChatRepository(), memory.NewChatAttendeeRepository(),
+		memory.NewSyncCheckpointRepository(), memory.NewContactRepository(), quotaSvc)
+	invitationSvc := NewInvitationService(unipileSvc, client, unipileRepo, memory.NewInvitationRepository(), quotaSvc)
+
+	campaignRepo := memory.NewCampaignRepository()
+	tc.enrollments = memory.NewEnrollmentRepository(campaignRepo)
+	tc.svc = NewCampaignService(cfg, unipileSvc, inboxSvc, invitationSvc, campaignRepo, tc.enrollments, tc.messages)
+	return tc
+}
+
+// mustStartCampaign 建立邀請後立即送出訊息的活動，並讓 prospects 報名
+func (tc *testCampaigns) mustStartCampaign(t *testing.T, prospects ...string) (*model.Campaign, []model.Enrollment) {
+	t.Helper()
+	ctx := context.Background()
+
+	campaign, err := tc.svc.Create(ctx, "owner@example.com", CampaignInput{
+		AccountID: "acc-1",
+		Name:      "Outreach",
+		Steps: []model.CampaignStep{
+			{Action: model.StepInvite, Text: "Hi"},
+			{Action: model.StepMessage, Text: "Thanks for connecting"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Create campaign: %v", err)
+	}
+	var in []Prospect
+	for _, p := range prospects {
+		in = append(in, Prospect{ProviderID: p})
+	}
+	enrollments, _, err := tc.svc.Enroll(ctx, "owner@example.com", campaign.ID.String(), in)
+	if err != nil || len(enrollments) != len(prospects) {
+		t.Fatalf("Enroll = %v, %v", enrollments, err)
+	}
+	return campaign, enrollments
+}
+
+func (tc *testCampaigns) mustGet(t *testing.T, e model.Enrollment) *model.Enrollment {
+	t.Helper()
+	got, err := tc.enrollments.Get(context.Background(), e.ID)
+	if err != nil {
+		t.Fatalf("Get enrollment: %v", err)
+	}
+	return got
+}
+
+func TestCampaignRunsSteps(t *testing.T) {
+	ctx := context.Background()
+	tc := newTestCampaignService(t, config.CampaignsConfig{})
+	campaign, enrollments := tc.mustStartCampaign(t, "prov-1")
+
+	// 每次 RunDue 執行一個步驟，下一步的 delay_hours 為 0 所以立即到期
+	tc.svc.RunDue(ctx)
+	got := tc.mustGet(t, enrollments[0])
+	if tc.invites.Load() != 1 || tc.sent.Load() != 0 || got.Status != model.EnrollmentActive || got.CurrentStep != 1 {
+		t.Fatalf("after the first step: %d invites, %d messages, enrollment %+v", tc.invites.Load(), tc.sent.Load(), got)
+	}
+
+	tc.svc.RunDue(ctx)
+	got = tc.mustGet(t, enrollments[0])
+	if tc.invites.Load() != 1 || tc.sent.Load() != 1 || got.Status != model.EnrollmentCompleted || got.ChatID != "chat-1" || got.NextRunAt != nil {
+		t.Fatalf("after the last step: %d invites, %d messages, enrollment %+v", tc.invites.Load(), tc.sent.Load(), got)
+	}
+
+	// 結束的報名不會再執行
+	tc.svc.RunDue(ctx)
+	if tc.invites.Load() != 1 || tc.sent.Load() != 1 {
+		t.Errorf("completed enrollment ran again: %d invites, %d messages", tc.invites.Load(), tc.sent.Load())
+	}
+
+	events, _, err := tc.svc.History(ctx, "owner@example.com", campaign.ID.String(), "", 10)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(events) != 3 || events[0].Action != model.StepMessage || events[0].ToStatus != model.EnrollmentCompleted || events[1].Action != model.StepInvite {
+		t.Errorf("History = %+v, want enrolled, invite and message newest first", events)
+	}
+}
+
+func TestCampaignStopsOnReply(t *testing.T) {
+	ctx := context.Background()
+	tc := newTestCampaignService(t, config.CampaignsConfig{})
+	_, enrollments := tc.mustStartCampaign(t, "prov-1", "prov-2")
+
+	// 同步收到的回覆立即停止報名
+	tc.svc.HandleReply(ctx, &model.Message{AccountID: "acc-1", UnipileID: "reply-1", SenderID: "prov-1", SentAt: time.Now().UTC()})
+	if got := tc.mustGet(t, enrollments[0]); got.Status != model.EnrollmentReplied || got.NextRunAt != nil {
+		t.Errorf("enrollment after reply = %+v, want replied", got)
+	}
+
+	// 回覆還沒觸發 HandleReply 時，執行步驟前也會檢查本地的訊息
+	reply := &model.Message{AccountID: "acc-1", ChatID: "chat-2", UnipileID: "reply-2", SenderID: "prov-2", Text: "Not interested", SentAt: time.Now().UTC()}
+	if _, err := tc.messages.Create(ctx, reply); err != nil {
+		t.Fatalf("Create message: %v", err)
+	}
+	tc.svc.RunDue(ctx)
+	if got := tc.mustGet(t, enrollments[1]); got.Status != model.EnrollmentReplied {
+		t.Errorf("enrollment with a stored reply = %+v, want replied", got)
+	}
+	if tc.invites.Load() != 0 {
+		t.Errorf("sent %d invites to prospects who replied", tc.invites.Load())
+	}
+
+	// 自己送出的訊息不算回覆
+	_, others := tc.mustStartCampaign(t, "prov-3")
+	tc.svc.HandleReply(ctx, &model.Message{AccountID: "acc-1", UnipileID: "own-1", SenderID: "prov-3", IsSender: true, SentAt: time.Now().UTC()})
+	if got := tc.mustGet(t, others[0]); got.Status != model.EnrollmentActive {
+		t.Errorf("enrollment after own message = %+v, want active", got)
+	}
+}
+
+func TestCampaignPauseResume(t *testing.T) {
+	ctx := context.Background()
+	tc := newTestCampaignService(t, config.CampaignsConfig{})
+	campaign, enrollments := tc.mustStartCampaign(t, "prov-1")
+
+	if paused, err := tc.svc.Pause(ctx, "owner@example.com", campaign.ID.String()); err != nil || paused.Status != model.CampaignPaused {
+		t.Fatalf("Pause = %+v, %v", paused, err)
+	}
+	tc.svc.RunDue(ctx)
+	if tc.invites.Load() != 0 {
+		t.Fatalf("paused campaign sent %d invites", tc.invites.Load())
+	}
+	if _, err := tc.svc.Pause(ctx, "other@example.com", campaign.ID.String()); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("Pause by another user = %v, want apperr.ErrNotFound", err)
+	}
+
+	// 暫停期間到期的步驟在恢復後執行
+	if resumed, err := tc.svc.Resume(ctx, "owner@example.com", campaign.ID.String()); err != nil || resumed.Status != model.CampaignActive {
+		t.Fatalf("Resume = %+v, %v", resumed, err)
+	}
+	tc.svc.RunDue(ctx)
+	if got := tc.mustGet(t, enrollments[0]); tc.invites.Load() != 1 || got.CurrentStep != 1 {
+		t.Errorf("after resume: %d invites, enrollment %+v", tc.invites.Load(), got)
+	}
+}
+
+func TestCampaignStepWithExpiredLease(t *testing.T) {
+	ctx := context.Background()
+	tc := newTestCampaignService(t, config.CampaignsConfig{Lease: time.Minute})
+	campaign, enrollments := tc.mustStartCampaign(t, "prov-1")
+
+	now := time.Now().UTC()
+	claimed, err := tc.enrollments.ClaimDue(ctx, now, time.Minute, 10)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("ClaimDue = %v, %v", claimed, err)
+	}
+	// 租約到期，報名被另一個程序重新取出
+	reclaimed, err := tc.enrollments.ClaimDue(ctx, now.Add(2*time.Minute), time.Minute, 10)
+	if err != nil || len(reclaimed) != 1 {
+		t.Fatalf("ClaimDue after the lease = %v, %v", reclaimed, err)
+	}
+
+	// 原本的程序執行步驟後無法記錄結果，報名仍由重新取出的程序持有
+	if err := tc.svc.runStep(ctx, campaign, claimed[0]); err != nil {
+		t.Fatalf("runStep with expired lease: %v", err)
+	}
+	got := tc.mustGet(t, enrollments[0])
+	if got.CurrentStep != 0 || *got.LeaseID != *reclaimed[0].LeaseID {
+		t.Fatalf("enrollment = %+v, want step 0 under the new lease", got)
+	}
+
+	if err := tc.svc.runStep(ctx, campaign, reclaimed[0]); err != nil {
+		t.Fatalf("runStep: %v", err)
+	}
+	if got := tc.mustGet(t, enrollments[0]); got.CurrentStep != 1 || got.Status != model.EnrollmentActive {
+		t.Errorf("enrollment = %+v, want step 1 recorded by the current lease", got)
+	}
+
+	// 讀取後才被取出的報名仍可以停止
+	stale := *tc.mustGet(t, enrollments[0])
+	if _, err := tc.enrollments.ClaimDue(ctx, now.Add(time.Hour), time.Minute, 10); err != nil {
+		t.Fatalf("ClaimDue: %v", err)
+	}
+	if err := tc.svc.transition(ctx, &stale, model.EnrollmentStopped, "stopped"); err != nil {
+		t.Fatalf("transition after a new lease: %v", err)
+	}
+	if got := tc.mustGet(t, enrollments[0]); got.Status != model.EnrollmentStopped {
+		t.Errorf("enrollment = %+v, want stopped", got)
+	}
+	if err := tc.svc.transition(ctx, &stale, model.EnrollmentStopped, "stopped"); !errors.Is(err, apperr.ErrConflict) {
+		t.Errorf("transition of a stopped enrollment = %v, want apperr.ErrConflict", err)
+	}
+}
+
+func TestCampaignRetryDelayIsCapped(t *testing.T) {
+	tc := newTestCampaignService(t, config.CampaignsConfig{RetryDelay: 15 * time.Minute, MaxRetryDelay: 6 * time.Hour})
+
+	for _, d := range []struct {
+		attempts int
+		want     time.Duration
+	}{{1, 15 * time.Minute}, {3, time.Hour}, {6, 6 * time.Hour}, {70, 6 * time.Hour}} {
+		if got := tc.svc.retryDelay(d.attempts); got != d.want {
+			t.Errorf("retryDelay(%d) = %v, want %v", d.attempts, got, d.want)
+		}
+	}
+}
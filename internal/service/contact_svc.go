@@ -0,0 +1,85 @@
+package service
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/unipile"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// ContactService 查詢 LinkedIn 個人檔案並快取到本地
+type ContactService struct {
+	cfg         config.ContactsConfig
+	unipileSvc  *UnipileService
+	client      *unipile.Client
+	contactRepo itfc.ContactRepository
+}
+
+func NewContactService(cfg config.ContactsConfig, unipileSvc *UnipileService, client *unipile.Client, contactRepo itfc.ContactRepository) *ContactService {
+	return &ContactService{
+		cfg:         cfg,
+		unipileSvc:  unipileSvc,
+		client:      client,
+		contactRepo: contactRepo,
+	}
+}
+
+// GetProfile 透過帳號查詢個人檔案，帳號必須屬於該使用者
+// identifier 可以是 provider id 或公開識別碼；快取未過期且 refresh 為 false 時不呼叫 Unipile
+func (s *ContactService) GetProfile(ctx context.Context, email, accountID, identifier string, refresh bool) (*model.Contact, error) {
+	identifier = strings.TrimSpace(identifier)
+	if identifier == "" {
+		return nil, apperr.Validation("identifier is required")
+	}
+	if _, err := s.unipileSvc.Get(ctx, email, accountID); err != nil {
+		return nil, err
+	}
+
+	cached, err := s.contactRepo.GetByIdentifier(ctx, accountID, identifier)
+	if err != nil && !errors.Is(err, apperr.ErrNotFound) {
+		return nil, err
+	}
+	if cached != nil && !refresh && time.Since(cached.FetchedAt) < s.cfg.TTL {
+		return cached, nil
+	}
+
+	profile, err := s.client.GetUserProfile(ctx, accountID, identifier)
+	if err != nil {
+		if cached != nil && !errors.Is(err, apperr.ErrNotFound) {
+			// Unipile 暫時無法使用時回傳過期的快取
+			slog.WarnContext(ctx, "Failed to refresh profile, using cached contact", "account_id", accountID, "identifier", identifier, "err", err)
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	contact := contactFromProfile(accountID, profile)
+	if err := s.contactRepo.Upsert(ctx, contact); err != nil {
+		return nil, err
+	}
+
+	return contact, nil
+}
+
+func contactFromProfile(accountID string, p *unipile.UserProfile) *model.Contact {
+	return &model.Contact{
+		AccountID:        accountID,
+		ProviderID:       p.ProviderID,
+		PublicIdentifier: p.PublicIdentifier,
+		Name:             p.Name(),
+		FirstName:        p.FirstName,
+		LastName:         p.LastName,
+		Headline:         p.Headline,
+		Company:          p.Company(),
+		Location:         p.Location,
+		ProfileURL:       p.ProfileURL(),
+		PictureURL:       p.ProfilePictureURL,
+		FetchedAt:        time.Now().UTC().Truncate(time.Microsecond),
+	}
+}
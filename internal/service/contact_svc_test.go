@@ -0,0 +1,183 @@
+package service
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/repository/memory"
+	"chatsheet/internal/unipile"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// testContacts 是 newTestContactService 建立的服務與 Unipile 上的個人檔案
+type testContacts struct {
+	svc      *ContactService
+	contacts itfc.ContactRepository
+	headline atomic.Value // Unipile 回傳的 headline
+	down     atomic.Bool  // true 時 Unipile 回傳 503
+	fetches  atomic.Int32
+}
+
+// newTestContactService 建立屬於 owner@example.com 的帳號 acc-1，個人檔案快取 1 小時
+// Unipile 上只有 prov-alice (公開識別碼 alice-wang) 的個人檔案
+func newTestContactService(t *testing.T) *testContacts {
+	t.Helper()
+
+	tc := &testContacts{}
+	tc.headline.Store("Engineer")
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/users/{identifier}", func(w http.ResponseWriter, r *http.Request) {
+		tc.fetches.Add(1)
+		if r.URL.Query().Get("account_id") != "acc-1" {
+			t.Errorf("account_id = %q, want acc-1", r.URL.Query().Get("account_id"))
+		}
+		if tc.down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if id := r.PathValue("identifier"); id != "prov-alice" && id != "alice-wang" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(unipile.UserProfile{
+			Object:           "UserProfile",
+			ProviderID:       "prov-alice",
+			PublicIdentifier: "alice-wang",
+			FirstName:        "Alice",
+			LastName:         "Wang",
+			Headline:         tc.headline.Load().(string),
+			Location:         "Taipei",
+			WorkExperience: []unipile.WorkExperience{
+				{Company: "Old Corp", End: "2020"},
+				{Company: "Acme"},
+			},
+		})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	unipileSvc := newTestUnipileService(t, "owner@example.com", "other@example.com")
+	if _, err := unipileSvc.Create(context.Background(), "owner@example.com", "linkedin", "acc-1"); err != nil {
+		t.Fatalf("Create account: %v", err)
+	}
+	tc.contacts = memory.NewContactRepository()
+	client := unipile.NewClient(config.UnipileConfig{APIBaseURL: srv.URL})
+	tc.svc = NewContactService(config.ContactsConfig{TTL: time.Hour}, unipileSvc, client, tc.contacts)
+	return tc
+}
+
+func TestContactGetProfileMergesProfile(t *testing.T) {
+	tc := newTestContactService(t)
+
+	contact, err := tc.svc.GetProfile(context.Background(), "owner@example.com", "acc-1", " alice-wang ", false)
+	if err != nil {
+		t.Fatalf("GetProfile: %v", err)
+	}
+	// 姓名、目前任職的公司與個人檔案網址由 Unipile 的欄位組成
+	want := model.Contact{
+		AccountID:        "acc-1",
+		ProviderID:       "prov-alice",
+		PublicIdentifier: "alice-wang",
+		Name:             "Alice Wang",
+		FirstName:        "Alice",
+		LastName:         "Wang",
+		Headline:         "Engineer",
+		Company:          "Acme",
+		Location:         "Taipei",
+		ProfileURL:       "https://www.linkedin.com/in/alice-wang",
+	}
+	got := *contact
+	got.ID, got.FetchedAt, got.CreatedAt, got.UpdatedAt = uuid.Nil, time.Time{}, nil, nil
+	if got != want {
+		t.Errorf("GetProfile = %+v, want %+v", got, want)
+	}
+	if contact.FetchedAt.IsZero() {
+		t.Error("FetchedAt is not set")
+	}
+}
+
+func TestContactGetProfileUsesCache(t *testing.T) {
+	ctx := context.Background()
+	tc := newTestContactService(t)
+
+	first, err := tc.svc.GetProfile(ctx, "owner@example.com", "acc-1", "prov-alice", false)
+	if err != nil {
+		t.Fatalf("GetProfile: %v", err)
+	}
+	tc.headline.Store("Engineering Manager")
+
+	// 快取未過期：以 provider id 或公開識別碼查詢都不呼叫 Unipile
+	for _, identifier := range []string{"prov-alice", "alice-wang"} {
+		got, err := tc.svc.GetProfile(ctx, "owner@example.com", "acc-1", identifier, false)
+		if err != nil || got.ID != first.ID || got.Headline != "Engineer" {
+			t.Errorf("GetProfile(%s) = %+v, %v, want the cached contact", identifier, got, err)
+		}
+	}
+	if n := tc.fetches.Load(); n != 1 {
+		t.Fatalf("Unipile called %d times, want 1", n)
+	}
+
+	// refresh 時重新取得，更新同一筆聯絡人
+	got, err := tc.svc.GetProfile(ctx, "owner@example.com", "acc-1", "prov-alice", true)
+	if err != nil || got.ID != first.ID || got.Headline != "Engineering Manager" || tc.fetches.Load() != 2 {
+		t.Errorf("GetProfile(refresh) = %+v, %v (%d Unipile calls)", got, err, tc.fetches.Load())
+	}
+}
+
+func TestContactGetProfileRefreshesExpiredCache(t *testing.T) {
+	ctx := context.Background()
+	tc := newTestContactService(t)
+	stale := &model.Contact{AccountID: "acc-1", ProviderID: "prov-alice", PublicIdentifier: "alice-wang", Name: "Alice Wang", Headline: "Intern", FetchedAt: time.Now().Add(-2 * time.Hour)}
+	if err := tc.contacts.Upsert(ctx, stale); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+
+	// Unipile 暫時無法使用時回傳過期的快取
+	tc.down.Store(true)
+	got, err := tc.svc.GetProfile(ctx, "owner@example.com", "acc-1", "alice-wang", false)
+	if err != nil || got.Headline != "Intern" {
+		t.Errorf("GetProfile while Unipile is down = %+v, %v, want the stale contact", got, err)
+	}
+
+	tc.down.Store(false)
+	got, err = tc.svc.GetProfile(ctx, "owner@example.com", "acc-1", "alice-wang", false)
+	if err != nil || got.ID != stale.ID || got.Headline != "Engineer" || time.Since(got.FetchedAt) > time.Minute {
+		t.Fatalf("GetProfile after expiry = %+v, %v, want the refreshed contact", got, err)
+	}
+	if n := tc.fetches.Load(); n != 2 {
+		t.Errorf("Unipile called %d times, want 2", n)
+	}
+	saved, err := tc.contacts.GetByIdentifier(ctx, "acc-1", "prov-alice")
+	if err != nil || saved.Headline != "Engineer" {
+		t.Errorf("saved contact = %+v, %v", saved, err)
+	}
+}
+
+func TestContactGetProfileErrors(t *testing.T) {
+	ctx := context.Background()
+	tc := newTestContactService(t)
+
+	if _, err := tc.svc.GetProfile(ctx, "owner@example.com", "acc-1", "  ", false); !errors.Is(err, apperr.ErrValidation) {
+		t.Errorf("GetProfile without identifier = %v, want apperr.ErrValidation", err)
+	}
+	if _, err := tc.svc.GetProfile(ctx, "other@example.com", "acc-1", "prov-alice", false); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("GetProfile through another user's account = %v, want apperr.ErrNotFound", err)
+	}
+	if _, err := tc.svc.GetProfile(ctx, "owner@example.com", "acc-1", "prov-nobody", false); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("GetProfile for an unknown profile = %v, want apperr.ErrNotFound", err)
+	}
+	tc.down.Store(true)
+	if _, err := tc.svc.GetProfile(ctx, "owner@example.com", "acc-1", "prov-alice", false); !errors.Is(err, apperr.ErrUpstream) {
+		t.Errorf("GetProfile while Unipile is down without cache = %v, want apperr.ErrUpstream", err)
+	}
+}
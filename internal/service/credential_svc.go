@@ -0,0 +1,113 @@
+package service
+
+import (
+	"chatsheet/internal/envelope"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+// rotateBatchSize 每次輪替處理的憑證數量
+const rotateBatchSize = 100
+
+// CredentialService 負責連線憑證的加密儲存與解密讀取
+type CredentialService struct {
+	credRepo itfc.CredentialRepository
+	envelope *envelope.Envelope
+}
+
+func NewCredentialService(repo itfc.CredentialRepository, env *envelope.Envelope) *CredentialService {
+	return &CredentialService{
+		credRepo: repo,
+		envelope: env,
+	}
+}
+
+// Save 加密並儲存帳號的連線憑證，同一個 account_id 會覆寫舊的憑證
+func (s *CredentialService) Save(ctx context.Context, email, accountID string, secret model.ConnectionSecret) error {
+	plaintext, err := json.Marshal(secret)
+	if err != nil {
+		return err
+	}
+
+	// 以 account_id 作為 AAD，避免密文被搬到其他帳號的資料列
+	sealed, err := s.envelope.Seal(ctx, plaintext, []byte(accountID))
+	if err != nil {
+		return fmt.Errorf("encrypt credential: %w", err)
+	}
+
+	_, err = s.credRepo.Upsert(ctx, &model.UnipileCredential{
+		UserEmail:  email,
+		AccountID:  accountID,
+		KeyVersion: sealed.KeyVersion,
+		WrappedKey: sealed.WrappedKey,
+		Ciphertext: sealed.Ciphertext,
+	})
+	return err
+}
+
+// Get 讀取並解密帳號的連線憑證
+func (s *CredentialService) Get(ctx context.Context, accountID string) (*model.ConnectionSecret, error) {
+	cred, err := s.credRepo.GetByAccountID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := s.envelope.Open(ctx, &envelope.Sealed{
+		KeyVersion: cred.KeyVersion,
+		WrappedKey: cred.WrappedKey,
+		Ciphertext: cred.Ciphertext,
+	}, []byte(cred.AccountID))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt credential: %w", err)
+	}
+
+	var secret model.ConnectionSecret
+	if err := json.Unmarshal(plaintext, &secret); err != nil {
+		return nil, err
+	}
+
+	return &secret, nil
+}
+
+// Delete 刪除帳號的連線憑證
+func (s *CredentialService) Delete(ctx context.Context, accountID string) error {
+	return s.credRepo.DeleteByAccountID(ctx, accountID)
+}
+
+// Rotate 將所有非目前主金鑰版本的資料金鑰重新包裝，回傳處理的筆數
+func (s *CredentialService) Rotate(ctx context.Context) (int, error) {
+	active := s.envelope.ActiveVersion()
+	total := 0
+
+	for {
+		creds, err := s.credRepo.ListByKeyVersionNot(ctx, active, rotateBatchSize)
+		if err != nil {
+			return total, err
+		}
+		if len(creds) == 0 {
+			return total, nil
+		}
+
+		for _, cred := range creds {
+			sealed := &envelope.Sealed{
+				KeyVersion: cred.KeyVersion,
+				WrappedKey: cred.WrappedKey,
+				Ciphertext: cred.Ciphertext,
+			}
+			// 任何一筆失敗都直接中止，否則同一批資料會被重複列出
+			if _, err := s.envelope.Rewrap(ctx, sealed); err != nil {
+				return total, fmt.Errorf("rewrap credential %s: %w", cred.ID, err)
+			}
+			if err := s.credRepo.UpdateWrappedKey(ctx, cred.ID, sealed.KeyVersion, sealed.WrappedKey); err != nil {
+				return total, err
+			}
+			total++
+		}
+
+		slog.InfoContext(ctx, "Rewrapped credentials", "count", total, "key_version", active)
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"chatsheet/internal/envelope"
+	"chatsheet/internal/model"
+	"chatsheet/internal/repository/memory"
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestCredentialRotate(t *testing.T) {
+	ctx := context.Background()
+	keyring, err := envelope.OpenKeyring(filepath.Join(t.TempDir(), "keyring.json"))
+	if err != nil {
+		t.Fatalf("OpenKeyring: %v", err)
+	}
+	repo := memory.NewCredentialRepository()
+	s := NewCredentialService(repo, envelope.New(keyring))
+
+	for i := range 3 {
+		secret := model.ConnectionSecret{AccessToken: fmt.Sprintf("li_at-%d", i), UserAgent: "agent"}
+		if err := s.Save(ctx, "owner@example.com", fmt.Sprintf("acc-%d", i), secret); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	// 與 cmd/reencrypt -rotate 相同：產生新版本後重新包裝所有資料金鑰
+	if _, err := keyring.Rotate(); err != nil {
+		t.Fatalf("Rotate keyring: %v", err)
+	}
+	n, err := s.Rotate(ctx)
+	if err != nil || n != 3 {
+		t.Fatalf("Rotate = %d, %v, want 3", n, err)
+	}
+	for i := range 3 {
+		accountID := fmt.Sprintf("acc-%d", i)
+		cred, _ := repo.GetByAccountID(ctx, accountID)
+		if cred.KeyVersion != 2 {
+			t.Errorf("%s key version = %d, want 2", accountID, cred.KeyVersion)
+		}
+		secret, err := s.Get(ctx, accountID)
+		if err != nil || secret.AccessToken != fmt.Sprintf("li_at-%d", i) {
+			t.Errorf("Get(%s) = %+v, %v", accountID, secret, err)
+		}
+	}
+
+	if n, err := s.Rotate(ctx); err != nil || n != 0 {
+		t.Errorf("second Rotate = %d, %v, want nothing left to rewrap", n, err)
+	}
+}
+
+func TestCredentialBoundToAccount(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewCredentialRepository()
+	s := NewCredentialService(repo, newTestCredentialService(t).envelope)
+
+	if err := s.Save(ctx, "owner@example.com", "acc-1", model.ConnectionSecret{AccessToken: "one"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := s.Save(ctx, "owner@example.com", "acc-2", model.ConnectionSecret{AccessToken: "two"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// 把 acc-1 的密文搬到 acc-2 的資料列，解密必須失敗
+	one, _ := repo.GetByAccountID(ctx, "acc-1")
+	two, _ := repo.GetByAccountID(ctx, "acc-2")
+	two.KeyVersion, two.WrappedKey, two.Ciphertext = one.KeyVersion, one.WrappedKey, one.Ciphertext
+	if _, err := repo.Upsert(ctx, two); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if secret, err := s.Get(ctx, "acc-2"); err == nil {
+		t.Errorf("Get with another account's ciphertext = %+v, want an error", secret)
+	}
+}
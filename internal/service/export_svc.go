@@ -0,0 +1,184 @@
+package service
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// 訊息方向
+const (
+	DirectionInbound  = "inbound"  // 收到的訊息
+	DirectionOutbound = "outbound" // 由連結的帳號送出
+)
+
+// ExportFilter 匯出訊息的篩選條件，零值的欄位代表不篩選
+type ExportFilter struct {
+	ChatID    string
+	Since     time.Time // sent_at >= Since
+	Until     time.Time // sent_at < Until
+	Direction string    // inbound、outbound 或空字串 (全部)
+}
+
+// ExportRow 是匯出的一列，對應一則訊息
+type ExportRow struct {
+	MessageID   string    `json:"message_id"`
+	ChatID      string    `json:"chat_id"`
+	ChatName    string    `json:"chat_name"`
+	SentAt      time.Time `json:"sent_at"`
+	Direction   string    `json:"direction"`
+	Sender      string    `json:"sender"`
+	Recipient   string    `json:"recipient"`
+	Text        string    `json:"text"`
+	Attachments []string  `json:"attachments"`
+}
+
+// ExportService 匯出已同步到本地的訊息
+type ExportService struct {
+	unipileSvc   *UnipileService
+	messageRepo  itfc.MessageRepository
+	chatRepo     itfc.ChatRepository
+	attendeeRepo itfc.ChatAttendeeRepository
+}
+
+func NewExportService(unipileSvc *UnipileService, messageRepo itfc.MessageRepository, chatRepo itfc.ChatRepository, attendeeRepo itfc.ChatAttendeeRepository) *ExportService {
+	return &ExportService{
+		unipileSvc:   unipileSvc,
+		messageRepo:  messageRepo,
+		chatRepo:     chatRepo,
+		attendeeRepo: attendeeRepo,
+	}
+}
+
+// exportChat 是匯出時快取的對話名稱與參與者
+type exportChat struct {
+	name   string
+	self   string            // 連結帳號本身的名稱
+	others []string          // 其他參與者的名稱
+	byID   map[string]string // provider id → 名稱
+}
+
+// Export 依序將帳號中符合條件的訊息 (由新到舊) 交給 fn，帳號必須屬於該使用者
+// 每次只讀取 exportBatchSize 筆，用於串流匯出而不需一次載入記憶體
+func (s *ExportService) Export(ctx context.Context, email, accountID string, filter ExportFilter, fn func(ExportRow) error) error {
+	if _, err := s.unipileSvc.Get(ctx, email, accountID); err != nil {
+		return err
+	}
+
+	msgFilter := itfc.MessageFilter{
+		AccountID: accountID,
+		ChatID:    filter.ChatID,
+		Since:     filter.Since,
+		Until:     filter.Until,
+		Limit:     exportBatchSize,
+	}
+	switch filter.Direction {
+	case "":
+	case DirectionInbound, DirectionOutbound:
+		isSender := filter.Direction == DirectionOutbound
+		msgFilter.IsSender = &isSender
+	default:
+		return apperr.Validation("direction must be inbound or outbound")
+	}
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && !filter.Since.Before(filter.Until) {
+		return apperr.Validation("from must be before to")
+	}
+
+	chats := map[string]*exportChat{}
+	for {
+		msgs, err := s.messageRepo.List(ctx, msgFilter)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range msgs {
+			chat, ok := chats[m.ChatID]
+			if !ok {
+				if chat, err = s.loadChat(ctx, m.ChatID); err != nil {
+					return err
+				}
+				chats[m.ChatID] = chat
+			}
+			if err := fn(exportRow(m, chat)); err != nil {
+				return err
+			}
+		}
+
+		if len(msgs) < exportBatchSize {
+			return nil
+		}
+		last := msgs[len(msgs)-1]
+		msgFilter.After = &pagination.Cursor{Time: last.SentAt, ID: last.ID.String()}
+	}
+}
+
+func (s *ExportService) loadChat(ctx context.Context, chatID string) (*exportChat, error) {
+	chat := &exportChat{byID: map[string]string{}}
+
+	c, err := s.chatRepo.GetByUnipileID(ctx, chatID)
+	if err != nil && !errors.Is(err, apperr.ErrNotFound) {
+		return nil, err
+	}
+	if c != nil {
+		chat.name = c.Name
+	}
+
+	attendees, err := s.attendeeRepo.ListByChats(ctx, []string{chatID})
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range attendees {
+		name := a.Name
+		if name == "" {
+			name = a.ProviderID
+		}
+		chat.byID[a.ProviderID] = name
+		if a.IsSelf {
+			chat.self = name
+		} else {
+			chat.others = append(chat.others, name)
+		}
+	}
+	if chat.self == "" {
+		chat.self = "me"
+	}
+
+	return chat, nil
+}
+
+func exportRow(m model.Message, chat *exportChat) ExportRow {
+	row := ExportRow{
+		MessageID:   m.UnipileID,
+		ChatID:      m.ChatID,
+		ChatName:    chat.name,
+		SentAt:      m.SentAt,
+		Text:        m.Text,
+		Attachments: m.AttachmentNames,
+	}
+	if row.Attachments == nil {
+		row.Attachments = []string{}
+	}
+
+	if m.IsSender {
+		row.Direction = DirectionOutbound
+		row.Sender = chat.self
+		row.Recipient = strings.Join(chat.others, ", ")
+	} else {
+		row.Direction = DirectionInbound
+		row.Sender = chat.byID[m.SenderID]
+		if row.Sender == "" {
+			row.Sender = m.SenderName
+		}
+		if row.Sender == "" {
+			row.Sender = m.SenderID
+		}
+		row.Recipient = chat.self
+	}
+
+	return row
+}
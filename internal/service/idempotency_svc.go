@@ -0,0 +1,112 @@
+package service
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// idempotencyPollInterval 等待同一個 key 的請求完成時，重新查詢的間隔
+const idempotencyPollInterval = 100 * time.Millisecond
+
+// IdempotencyService 處理 Idempotency-Key 的保留、回應快取與重播
+type IdempotencyService struct {
+	idemRepo    itfc.IdempotencyRepository
+	ttl         time.Duration // 回應快取保留多久
+	lockTimeout time.Duration // 超過這個時間仍在處理中，視為程序已中斷
+	waitTimeout time.Duration // 重複請求最多等待多久，超過則回傳 409
+}
+
+func NewIdempotencyService(repo itfc.IdempotencyRepository, cfg config.IdempotencyConfig) *IdempotencyService {
+	return &IdempotencyService{
+		idemRepo:    repo,
+		ttl:         cfg.TTL,
+		lockTimeout: cfg.LockTimeout,
+		waitTimeout: cfg.WaitTimeout,
+	}
+}
+
+// Begin 嘗試保留 key
+//   - 回傳 (rec, nil) 且 rec.Status 為 in_progress：已保留，呼叫端應執行請求後呼叫 Complete 或 Release
+//   - 回傳 (rec, nil) 且 rec.Status 為 completed：重複的請求，呼叫端應重播 rec 中的回應
+//   - 回傳 apperr.ErrValidation：同一個 key 被用在不同的請求
+//   - 回傳 apperr.ErrConflict：相同的請求仍在處理中，且等待逾時
+func (s *IdempotencyService) Begin(ctx context.Context, email, key, fingerprint string) (*model.IdempotencyKey, error) {
+	deadline := time.Now().Add(s.waitTimeout)
+
+	for {
+		rec, err := s.idemRepo.Create(ctx, &model.IdempotencyKey{
+			UserEmail:   email,
+			Key:         key,
+			Fingerprint: fingerprint,
+			Status:      model.IdempotencyInProgress,
+			ExpiresAt:   time.Now().Add(s.ttl),
+		})
+		if err == nil {
+			return rec, nil
+		}
+		if !errors.Is(err, apperr.ErrConflict) {
+			return nil, err
+		}
+
+		existing, err := s.idemRepo.Get(ctx, email, key)
+		if errors.Is(err, apperr.ErrNotFound) {
+			// 前一個請求失敗並釋放了 key，重新嘗試保留
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// 過期或處理中斷的 key 可以被重新使用
+		if time.Now().After(existing.ExpiresAt) ||
+			(existing.Status == model.IdempotencyInProgress && existing.CreatedAt != nil && time.Since(*existing.CreatedAt) > s.lockTimeout) {
+			if err := s.idemRepo.Delete(ctx, existing.ID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if existing.Fingerprint != fingerprint {
+			return nil, apperr.Validation("Idempotency-Key has already been used for a different request")
+		}
+		if existing.Status == model.IdempotencyCompleted {
+			return existing, nil
+		}
+
+		// 相同的請求仍在處理中，等待其完成
+		if time.Now().After(deadline) {
+			return nil, apperr.Conflict("A request with the same Idempotency-Key is still being processed")
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(idempotencyPollInterval):
+		}
+	}
+}
+
+// Complete 儲存請求的回應，之後相同 key 的請求會重播此回應
+func (s *IdempotencyService) Complete(ctx context.Context, rec *model.IdempotencyKey, code int, contentType string, body []byte) error {
+	return s.idemRepo.Complete(ctx, rec.ID, code, contentType, body)
+}
+
+// Release 釋放 key，讓客戶端可以用相同的 key 重試 (用於請求失敗時)
+func (s *IdempotencyService) Release(ctx context.Context, rec *model.IdempotencyKey) error {
+	return s.idemRepo.Delete(ctx, rec.ID)
+}
+
+// PurgeExpired 刪除已過期的 key，由定期工作執行
+// 過期的 key 在 Begin 時也會被覆寫，這裡只是避免資料表無限增長
+func (s *IdempotencyService) PurgeExpired(ctx context.Context) (string, error) {
+	n, err := s.idemRepo.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("deleted %d expired keys", n), nil
+}
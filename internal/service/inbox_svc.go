@@ -0,0 +1,460 @@
+package service
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"chatsheet/internal/unipile"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// attendeeConcurrency 同時向 Unipile 查詢對話參與者的數量上限
+const attendeeConcurrency = 5
+
+// InboxChat 是回傳給前端的對話，附帶參與者資訊
+type InboxChat struct {
+	unipile.Chat
+	Attendees []InboxAttendee `json:"attendees"`
+}
+
+// InboxAttendee 是對話的參與者，附帶快取的個人檔案 (尚未查詢過時為 nil)
+type InboxAttendee struct {
+	unipile.ChatAttendee
+	Contact *model.Contact `json:"contact,omitempty"`
+}
+
+// InboxMessage 是回傳給前端的訊息，附帶寄件者資訊
+type InboxMessage struct {
+	unipile.Message
+	Sender *unipile.ChatAttendee `json:"sender,omitempty"`
+}
+
+// InboxPage 是分頁的結果，NextCursor 為空字串代表沒有下一頁
+type InboxPage[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// OutgoingMessage 是要送出的訊息內容，Text 與 Files 至少要有一項
+type OutgoingMessage struct {
+	Text  string
+	Files []unipile.File
+}
+
+// StartedChat 是建立新對話的結果
+type StartedChat struct {
+	ChatID  string         `json:"chat_id"`
+	Message *model.Message `json:"sent"`
+}
+
+// InboxService 讀取與送出使用者已連結帳號的對話與訊息
+// 已同步到本地的資料直接從資料庫讀取，尚未回填完成時才向 Unipile 查詢
+type InboxService struct {
+	unipileSvc     *UnipileService
+	client         *unipile.Client
+	messageRepo    itfc.MessageRepository
+	chatRepo       itfc.ChatRepository
+	attendeeRepo   itfc.ChatAttendeeRepository
+	checkpointRepo itfc.SyncCheckpointRepository
+	contactRepo    itfc.ContactRepository
+	quotaSvc       *QuotaService
+}
+
+func NewInboxService(
+	unipileSvc *UnipileService,
+	client *unipile.Client,
+	messageRepo itfc.MessageRepository,
+	chatRepo itfc.ChatRepository,
+	attendeeRepo itfc.ChatAttendeeRepository,
+	checkpointRepo itfc.SyncCheckpointRepository,
+	contactRepo itfc.ContactRepository,
+	quotaSvc *QuotaService,
+) *InboxService {
+	return &InboxService{
+		unipileSvc:     unipileSvc,
+		client:         client,
+		messageRepo:    messageRepo,
+		chatRepo:       chatRepo,
+		attendeeRepo:   attendeeRepo,
+		checkpointRepo: checkpointRepo,
+		contactRepo:    contactRepo,
+		quotaSvc:       quotaSvc,
+	}
+}
+
+// SyncStatus 回傳帳號的同步進度，帳號必須屬於該使用者
+func (s *InboxService) SyncStatus(ctx context.Context, email, accountID string) (*model.SyncCheckpoint, error) {
+	if _, err := s.unipileSvc.Get(ctx, email, accountID); err != nil {
+		return nil, err
+	}
+
+	cp, err := s.checkpointRepo.Get(ctx, accountID)
+	if errors.Is(err, apperr.ErrNotFound) {
+		return &model.SyncCheckpoint{AccountID: accountID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return cp, nil
+}
+
+// ListChats 列出帳號的對話，帳號必須屬於該使用者
+func (s *InboxService) ListChats(ctx context.Context, email, accountID, cursor string, limit int) (*InboxPage[InboxChat], error) {
+	if _, err := s.unipileSvc.Get(ctx, email, accountID); err != nil {
+		return nil, err
+	}
+
+	if cp, err := s.checkpointRepo.Get(ctx, accountID); err == nil && cp.ChatsBackfilled {
+		if after, ok := localCursor(cursor); ok {
+			return s.listLocalChats(ctx, accountID, after, pagination.Limit(limit))
+		}
+	}
+
+	list, err := s.client.ListChats(ctx, accountID, unipile.ListOptions{Cursor: cursor, Limit: pagination.Limit(limit)})
+	if err != nil {
+		return nil, err
+	}
+
+	chats := make([]InboxChat, len(list.Items))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, attendeeConcurrency)
+	for i, chat := range list.Items {
+		chats[i] = InboxChat{Chat: chat, Attendees: []InboxAttendee{}}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			attendees, err := s.client.ListChatAttendees(ctx, chat.ID)
+			if err != nil {
+				// 參與者只是附加資訊，查詢失敗時仍回傳對話
+				slog.WarnContext(ctx, "Failed to list chat attendees", "chat_id", chat.ID, "err", err)
+				return
+			}
+			for _, a := range attendees {
+				chats[i].Attendees = append(chats[i].Attendees, InboxAttendee{ChatAttendee: a})
+			}
+		}()
+	}
+	wg.Wait()
+	s.enrichAttendees(ctx, accountID, chats)
+
+	return &InboxPage[InboxChat]{Items: chats, NextCursor: list.Cursor}, nil
+}
+
+// ListMessages 列出對話的訊息，對話所屬的帳號必須屬於該使用者
+func (s *InboxService) ListMessages(ctx context.Context, email, chatID, cursor string, limit int) (*InboxPage[InboxMessage], error) {
+	_, local, err := s.authorizeChat(ctx, email, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	if local != nil && local.MessagesBackfilled {
+		if after, ok := localCursor(cursor); ok {
+			return s.listLocalMessages(ctx, chatID, after, pagination.Limit(limit))
+		}
+	}
+
+	list, err := s.client.ListMessages(ctx, chatID, unipile.ListOptions{Cursor: cursor, Limit: pagination.Limit(limit)})
+	if err != nil {
+		return nil, err
+	}
+
+	attendees, err := s.client.ListChatAttendees(ctx, chatID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to list chat attendees", "chat_id", chatID, "err", err)
+	}
+	byProviderID := make(map[string]*unipile.ChatAttendee, len(attendees))
+	for i := range attendees {
+		byProviderID[attendees[i].ProviderID] = &attendees[i]
+	}
+
+	items := list.Items
+	if cursor == "" {
+		// Unipile 索引剛送出的訊息需要一點時間，第一頁補上本地已保存但尚未出現的訊息
+		items = s.mergeSent(ctx, chatID, items)
+	}
+
+	msgs := make([]InboxMessage, len(items))
+	for i, m := range items {
+		msgs[i] = InboxMessage{Message: m, Sender: byProviderID[m.SenderID]}
+	}
+
+	return &InboxPage[InboxMessage]{Items: msgs, NextCursor: list.Cursor}, nil
+}
+
+// SendMessage 在對話中送出訊息並保存到本地
+func (s *InboxService) SendMessage(ctx context.Context, email, chatID string, out OutgoingMessage) (*model.Message, error) {
+	if err := validateOutgoing(out); err != nil {
+		return nil, err
+	}
+
+	accountID, _, err := s.authorizeChat(ctx, email, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	var sent *unipile.MessageSent
+	err = s.quotaSvc.Do(ctx, accountID, model.ActionMessage, func() (err error) {
+		sent, err = s.client.SendMessage(ctx, chatID, out.Text, out.Files)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.saveSent(ctx, email, accountID, chatID, sent.MessageID, out), nil
+}
+
+// StartChat 以對方的 provider id 建立新對話並送出第一則訊息，帳號必須屬於該使用者
+func (s *InboxService) StartChat(ctx context.Context, email, accountID string, attendeeIDs []string, out OutgoingMessage) (*StartedChat, error) {
+	if len(attendeeIDs) == 0 {
+		return nil, apperr.Validation("attendee_ids is required")
+	}
+	if err := validateOutgoing(out); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.unipileSvc.Get(ctx, email, accountID); err != nil {
+		return nil, err
+	}
+
+	var started *unipile.ChatStarted
+	err := s.quotaSvc.Do(ctx, accountID, model.ActionMessage, func() (err error) {
+		started, err = s.client.StartChat(ctx, accountID, attendeeIDs, out.Text, out.Files)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &StartedChat{
+		ChatID:  started.ChatID,
+		Message: s.saveSent(ctx, email, accountID, started.ChatID, started.MessageID, out),
+	}, nil
+}
+
+func validateOutgoing(out OutgoingMessage) error {
+	if out.Text == "" && len(out.Files) == 0 {
+		return apperr.Validation("text or attachments is required")
+	}
+	return nil
+}
+
+// saveSent 保存已送出的訊息
+// 訊息已經送到 LinkedIn，保存失敗只記錄錯誤，不讓請求失敗 (避免使用者重送)
+func (s *InboxService) saveSent(ctx context.Context, email, accountID, chatID, messageID string, out OutgoingMessage) *model.Message {
+	names := make(model.StringList, len(out.Files))
+	for i, f := range out.Files {
+		names[i] = f.Name
+	}
+
+	msg := &model.Message{
+		AccountID:       accountID,
+		ChatID:          chatID,
+		UnipileID:       messageID,
+		Text:            out.Text,
+		IsSender:        true,
+		AttachmentNames: names,
+		SentByEmail:     email,
+		SentAt:          time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if messageID == "" {
+		slog.WarnContext(ctx, "Unipile did not return message id, skip saving", "chat_id", chatID)
+		return msg
+	}
+
+	if _, err := s.messageRepo.Create(context.WithoutCancel(ctx), msg); err != nil && !errors.Is(err, apperr.ErrConflict) {
+		slog.ErrorContext(ctx, "Failed to save sent message", "chat_id", chatID, "message_id", messageID, "err", err)
+	}
+
+	return msg
+}
+
+// mergeSent 把本地保存、比第一頁最舊訊息更新但 Unipile 尚未回傳的訊息合併進來 (由新到舊)
+func (s *InboxService) mergeSent(ctx context.Context, chatID string, items []unipile.Message) []unipile.Message {
+	var since time.Time
+	seen := make(map[string]bool, len(items))
+	for _, m := range items {
+		seen[m.ID] = true
+		if t := unipile.ParseTimestamp(m.Timestamp); !t.IsZero() && (since.IsZero() || t.Before(since)) {
+			since = t
+		}
+	}
+
+	local, err := s.messageRepo.ListByChatSince(ctx, chatID, since)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to list local messages", "chat_id", chatID, "err", err)
+		return items
+	}
+
+	merged := items
+	for _, m := range local {
+		if seen[m.UnipileID] {
+			continue
+		}
+		merged = append(merged, messageToUnipile(m))
+	}
+	if len(merged) == len(items) {
+		return items
+	}
+
+	slices.SortStableFunc(merged, func(a, b unipile.Message) int {
+		return unipile.ParseTimestamp(b.Timestamp).Compare(unipile.ParseTimestamp(a.Timestamp))
+	})
+	return merged
+}
+
+// listLocalChats 從本地讀取帳號的對話與參與者
+func (s *InboxService) listLocalChats(ctx context.Context, accountID string, after *pagination.Cursor, limit int) (*InboxPage[InboxChat], error) {
+	chats, err := s.chatRepo.ListByAccount(ctx, accountID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	chatIDs := make([]string, len(chats))
+	for i, c := range chats {
+		chatIDs[i] = c.UnipileID
+	}
+	attendees, err := s.attendeeRepo.ListByChats(ctx, chatIDs)
+	if err != nil {
+		return nil, err
+	}
+	byChat := map[string][]InboxAttendee{}
+	for _, a := range attendees {
+		byChat[a.ChatID] = append(byChat[a.ChatID], InboxAttendee{ChatAttendee: attendeeToUnipile(a)})
+	}
+
+	page := &InboxPage[InboxChat]{Items: make([]InboxChat, len(chats))}
+	for i, c := range chats {
+		page.Items[i] = InboxChat{Chat: chatToUnipile(c), Attendees: byChat[c.UnipileID]}
+		if page.Items[i].Attendees == nil {
+			page.Items[i].Attendees = []InboxAttendee{}
+		}
+	}
+	s.enrichAttendees(ctx, accountID, page.Items)
+	if len(chats) == limit {
+		last := chats[len(chats)-1]
+		page.NextCursor = pagination.Cursor{Time: last.LastMessageAt, ID: last.ID.String()}.Encode()
+	}
+
+	return page, nil
+}
+
+// enrichAttendees 以快取的個人檔案補充參與者資訊 (不會向 Unipile 查詢)
+// 個人檔案只是附加資訊，查詢失敗時只記錄錯誤
+func (s *InboxService) enrichAttendees(ctx context.Context, accountID string, chats []InboxChat) {
+	var providerIDs []string
+	for _, c := range chats {
+		for _, a := range c.Attendees {
+			if a.IsSelf == 0 && a.ProviderID != "" && !slices.Contains(providerIDs, a.ProviderID) {
+				providerIDs = append(providerIDs, a.ProviderID)
+			}
+		}
+	}
+	if len(providerIDs) == 0 {
+		return
+	}
+
+	contacts, err := s.contactRepo.ListByProviderIDs(ctx, accountID, providerIDs)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to list contacts", "account_id", accountID, "err", err)
+		return
+	}
+	byProviderID := make(map[string]*model.Contact, len(contacts))
+	for i := range contacts {
+		byProviderID[contacts[i].ProviderID] = &contacts[i]
+	}
+
+	for i := range chats {
+		for j := range chats[i].Attendees {
+			chats[i].Attendees[j].Contact = byProviderID[chats[i].Attendees[j].ProviderID]
+		}
+	}
+}
+
+// listLocalMessages 從本地讀取對話的訊息
+func (s *InboxService) listLocalMessages(ctx context.Context, chatID string, after *pagination.Cursor, limit int) (*InboxPage[InboxMessage], error) {
+	msgs, err := s.messageRepo.ListByChat(ctx, chatID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	attendees, err := s.attendeeRepo.ListByChats(ctx, []string{chatID})
+	if err != nil {
+		return nil, err
+	}
+	byProviderID := make(map[string]*unipile.ChatAttendee, len(attendees))
+	for _, a := range attendees {
+		ua := attendeeToUnipile(a)
+		byProviderID[a.ProviderID] = &ua
+	}
+
+	page := &InboxPage[InboxMessage]{Items: make([]InboxMessage, len(msgs))}
+	for i, m := range msgs {
+		page.Items[i] = InboxMessage{Message: messageToUnipile(m), Sender: byProviderID[m.SenderID]}
+	}
+	if len(msgs) == limit {
+		last := msgs[len(msgs)-1]
+		page.NextCursor = pagination.Cursor{Time: last.SentAt, ID: last.ID.String()}.Encode()
+	}
+
+	return page, nil
+}
+
+// localCursor 解析本地分頁的游標
+// 無法解析時 (例如回填完成前由 Unipile 回傳的游標) 回傳 false，由呼叫者改向 Unipile 查詢
+func localCursor(cursor string) (*pagination.Cursor, bool) {
+	if cursor == "" {
+		return nil, true
+	}
+	c, err := pagination.Decode(cursor)
+	if err != nil {
+		return nil, false
+	}
+	return c, true
+}
+
+// ChatAccount 回傳對話所屬的帳號 id，帳號必須屬於該使用者
+func (s *InboxService) ChatAccount(ctx context.Context, email, chatID string) (string, error) {
+	accountID, _, err := s.authorizeChat(ctx, email, chatID)
+	return accountID, err
+}
+
+// authorizeChat 確認對話所屬的帳號屬於該使用者，回傳帳號 id 與本地的對話 (尚未同步時為 nil)
+// 不屬於該使用者時同樣回傳 404，避免洩漏對話是否存在
+func (s *InboxService) authorizeChat(ctx context.Context, email, chatID string) (string, *model.Chat, error) {
+	var accountID string
+	local, err := s.chatRepo.GetByUnipileID(ctx, chatID)
+	switch {
+	case err == nil:
+		accountID = local.AccountID
+	case errors.Is(err, apperr.ErrNotFound):
+		local = nil
+		chat, err := s.client.GetChat(ctx, chatID)
+		if err != nil {
+			return "", nil, err
+		}
+		accountID = chat.AccountID
+	default:
+		return "", nil, err
+	}
+
+	if _, err := s.unipileSvc.Get(ctx, email, accountID); err != nil {
+		if errors.Is(err, apperr.ErrNotFound) {
+			return "", nil, apperr.NotFound("Chat not found")
+		}
+		return "", nil, err
+	}
+
+	return accountID, local, nil
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/repository/memory"
+	"chatsheet/internal/unipile"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testInbox 是 newTestInboxService 建立的服務、本地資料與 Unipile 收到的請求數
+type testInbox struct {
+	svc         *InboxService
+	chats       itfc.ChatRepository
+	attendees   itfc.ChatAttendeeRepository
+	messages    itfc.MessageRepository
+	checkpoints itfc.SyncCheckpointRepository
+	contacts    itfc.ContactRepository
+	chatLists   atomic.Int32 // GET /api/v1/chats
+	sent        atomic.Int32 // 送出的訊息與新對話
+	uploads     atomic.Int32 // 送出的附件
+}
+
+// inboxTime 是 Unipile 上最新一則訊息的時間
+var inboxTime = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestInboxService 建立 owner@example.com 的帳號 acc-1 與 other@example.com 的帳號 acc-2
+// Unipile 上 acc-1 有 chat-1 到 chat-3 (每頁 2 筆)，acc-2 有 chat-other；每個對話有 Bob 與帳號本身兩個參與者
+func newTestInboxService(t *testing.T) *testInbox {
+	t.Helper()
+
+	ti := &testInbox{}
+	chatAccounts := map[string]string{"chat-1": "acc-1", "chat-2": "acc-1", "chat-3": "acc-1", "chat-other": "acc-2"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/chats", func(w http.ResponseWriter, r *http.Request) {
+		ti.chatLists.Add(1)
+		list := unipile.List[unipile.Chat]{}
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			list.Items = []unipile.Chat{{ID: "chat-1", AccountID: "acc-1"}, {ID: "chat-2", AccountID: "acc-1"}}
+			list.Cursor = "unipile-page-2"
+		case "unipile-page-2":
+			list.Items = []unipile.Chat{{ID: "chat-3", AccountID: "acc-1"}}
+		default:
+			t.Errorf("unexpected chats cursor %q", r.URL.Query().Get("cursor"))
+		}
+		json.NewEncoder(w).Encode(list)
+	})
+	mux.HandleFunc("GET /api/v1/chats/{id}", func(w http.ResponseWriter, r *http.Request) {
+		accountID, ok := chatAccounts[r.PathValue("id")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(unipile.Chat{ID: r.PathValue("id"), AccountID: accountID})
+	})
+	mux.HandleFunc("GET /api/v1/chats/{id}/attendees", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(unipile.List[unipile.ChatAttendee]{Items: []unipile.ChatAttendee{
+			{ID: "att-bob", ProviderID: "prov-bob", Name: "Bob"},
+			{ID: "att-self", ProviderID: "prov-self", Name: "Me", IsSelf: 1},
+		}})
+	})
+	mux.HandleFunc("GET /api/v1/chats/{id}/messages", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(unipile.List[unipile.Message]{
+			Items: []unipile.Message{
+				{ID: "msg-2", ChatID: r.PathValue("id"), SenderID: "prov-bob", Text: "Sure", Timestamp: inboxTime.Format(time.RFC3339Nano)},
+				{ID: "msg-1", ChatID: r.PathValue("id"), SenderID: "prov-self", Text: "Hi", Timestamp: inboxTime.Add(-time.Hour).Format(time.RFC3339Nano), IsSender: 1},
+			},
+			Cursor: "older",
+		})
+	})
+	mux.HandleFunc("POST /api/v1/chats/{id}/messages", func(w http.ResponseWriter, r *http.Request) {
+		ti.countUploads(t, r)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(unipile.MessageSent{Object: "MessageSent", MessageID: "sent-1"})
+	})
+	mux.HandleFunc("POST /api/v1/chats", func(w http.ResponseWriter, r *http.Request) {
+		ti.countUploads(t, r)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(unipile.ChatStarted{Object: "ChatStarted", ChatID: "chat-new", MessageID: "sent-2"})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	unipileSvc := newTestUnipileService(t, "owner@example.com", "other@example.com")
+	for email, accountID := range map[string]string{"owner@example.com": "acc-1", "other@example.com": "acc-2"} {
+		if _, err := unipileSvc.Create(context.Background(), email, "linkedin", accountID); err != nil {
+			t.Fatalf("Create account: %v", err)
+		}
+	}
+	ti.chats, ti.attendees, ti.messages = memory.NewChatRepository(), memory.NewChatAttendeeRepository(), memory.NewMessageRepository()
+	ti.checkpoints, ti.contacts = memory.NewSyncCheckpointRepository(), memory.NewContactRepository()
+	quotaSvc := NewQuotaService(config.QuotasConfig{}, unipileSvc, memory.NewQuotaRepository())
+	client := unipile.NewClient(config.UnipileConfig{APIBaseURL: srv.URL})
+	ti.svc = NewInboxService(unipileSvc, client, ti.messages, ti.chats, ti.attendees, ti.checkpoints, ti.contacts, quotaSvc)
+	return ti
+}
+
+// countUploads 記錄一次送出與請求中的附件數
+func (ti *testInbox) countUploads(t *testing.T, r *http.Request) {
+	ti.sent.Add(1)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		t.Errorf("MultipartReader: %v", err)
+		return
+	}
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return
+		}
+		if part.FormName() == "attachments" {
+			ti.uploads.Add(1)
+		}
+	}
+}
+
+func TestInboxListChatsFromUnipile(t *testing.T) {
+	ctx := context.Background()
+	ti := newTestInboxService(t)
+	// 已經查詢過 Bob 的個人檔案
+	if err := ti.contacts.Upsert(ctx, &model.Contact{AccountID: "acc-1", ProviderID: "prov-bob", Name: "Bob", Headline: "Engineer", FetchedAt: inboxTime}); err != nil {
+		t.Fatalf("Upsert contact: %v", err)
+	}
+
+	// 尚未回填完成，以 Unipile 的游標分頁
+	page, err := ti.svc.ListChats(ctx, "owner@example.com", "acc-1", "", 2)
+	if err != nil {
+		t.Fatalf("ListChats: %v", err)
+	}
+	if len(page.Items) != 2 || page.NextCursor != "unipile-page-2" {
+		t.Fatalf("first page = %d chats, next %q", len(page.Items), page.NextCursor)
+	}
+	attendees := page.Items[0].Attendees
+	if len(attendees) != 2 || attendees[0].Contact == nil || attendees[0].Contact.Headline != "Engineer" || attendees[1].Contact != nil {
+		t.Errorf("attendees = %+v, want Bob with the cached profile and self without one", attendees)
+	}
+
+	page, err = ti.svc.ListChats(ctx, "owner@example.com", "acc-1", page.NextCursor, 2)
+	if err != nil || len(page.Items) != 1 || page.Items[0].ID != "chat-3" || page.NextCursor != "" {
+		t.Fatalf("last page = %+v, %v", page, err)
+	}
+}
+
+func TestInboxListChatsFromLocal(t *testing.T) {
+	ctx := context.Background()
+	ti := newTestInboxService(t)
+	for i, id := range []string{"chat-1", "chat-2", "chat-3"} {
+		chat := &model.Chat{AccountID: "acc-1", UnipileID: id, LastMessageAt: inboxTime.Add(-time.Duration(i) * time.Hour)}
+		if err := ti.chats.Upsert(ctx, chat); err != nil {
+			t.Fatalf("Upsert chat: %v", err)
+		}
+	}
+	if err := ti.attendees.Upsert(ctx, &model.ChatAttendee{AccountID: "acc-1", ChatID: "chat-1", ProviderID: "prov-bob", Name: "Bob"}); err != nil {
+		t.Fatalf("Upsert attendee: %v", err)
+	}
+	if err := ti.checkpoints.Save(ctx, &model.SyncCheckpoint{AccountID: "acc-1", ChatsBackfilled: true}); err != nil {
+		t.Fatalf("Save checkpoint: %v", err)
+	}
+
+	// 回填完成後從本地讀取 (由新到舊)，不再呼叫 Unipile
+	page, err := ti.svc.ListChats(ctx, "owner@example.com", "acc-1", "", 2)
+	if err != nil {
+		t.Fatalf("ListChats: %v", err)
+	}
+	if len(page.Items) != 2 || page.Items[0].ID != "chat-1" || len(page.Items[0].Attendees) != 1 || page.Items[1].Attendees == nil || page.NextCursor == "" {
+		t.Fatalf("first page = %+v", page)
+	}
+	page, err = ti.svc.ListChats(ctx, "owner@example.com", "acc-1", page.NextCursor, 2)
+	if err != nil || len(page.Items) != 1 || page.Items[0].ID != "chat-3" || page.NextCursor != "" {
+		t.Fatalf("last page = %+v, %v", page, err)
+	}
+	if n := ti.chatLists.Load(); n != 0 {
+		t.Errorf("Unipile chat list called %d times, want 0", n)
+	}
+
+	// 回填完成前由 Unipile 回傳的游標無法在本地使用，繼續向 Unipile 查詢
+	page, err = ti.svc.ListChats(ctx, "owner@example.com", "acc-1", "unipile-page-2", 2)
+	if err != nil || len(page.Items) != 1 || ti.chatLists.Load() != 1 {
+		t.Errorf("ListChats with a Unipile cursor = %+v, %v (%d Unipile calls)", page, err, ti.chatLists.Load())
+	}
+}
+
+func TestInboxListMessagesMergesSent(t *testing.T) {
+	ctx := context.Background()
+	ti := newTestInboxService(t)
+	// 剛送出、Unipile 尚未索引的訊息
+	if _, err := ti.messages.Create(ctx, &model.Message{AccountID: "acc-1", ChatID: "chat-1", UnipileID: "sent-0", Text: "Following up", IsSender: true, SentAt: inboxTime.Add(time.Minute)}); err != nil {
+		t.Fatalf("Create message: %v", err)
+	}
+
+	page, err := ti.svc.ListMessages(ctx, "owner@example.com", "chat-1", "", 10)
+	if err != nil {
+		t.Fatalf("ListMessages: %v", err)
+	}
+	var ids []string
+	for _, m := range page.Items {
+		ids = append(ids, m.ID)
+	}
+	if strings.Join(ids, ",") != "sent-0,msg-2,msg-1" || page.NextCursor != "older" {
+		t.Fatalf("first page = %v (next %q), want the local message merged newest first", ids, page.NextCursor)
+	}
+	if sender := page.Items[1].Sender; sender == nil || sender.Name != "Bob" {
+		t.Errorf("sender of msg-2 = %+v, want Bob", sender)
+	}
+
+	// 之後的頁面只回傳 Unipile 的訊息
+	page, err = ti.svc.ListMessages(ctx, "owner@example.com", "chat-1", "older", 10)
+	if err != nil || len(page.Items) != 2 {
+		t.Errorf("second page = %+v, %v", page, err)
+	}
+}
+
+func TestInboxSendMessage(t *testing.T) {
+	ctx := context.Background()
+	ti := newTestInboxService(t)
+
+	if _, err := ti.svc.SendMessage(ctx, "owner@example.com", "chat-1", OutgoingMessage{}); !errors.Is(err, apperr.ErrValidation) {
+		t.Errorf("SendMessage without text or attachments = %v, want apperr.ErrValidation", err)
+	}
+
+	msg, err := ti.svc.SendMessage(ctx, "owner@example.com", "chat-1", OutgoingMessage{
+		Text:  "See attached",
+		Files: []unipile.File{{Name: "deck.pdf", ContentType: "application/pdf", Content: strings.NewReader("%PDF")}},
+	})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if ti.sent.Load() != 1 || ti.uploads.Load() != 1 {
+		t.Errorf("Unipile received %d messages with %d attachments, want 1 and 1", ti.sent.Load(), ti.uploads.Load())
+	}
+	saved, err := ti.messages.ListByChatSince(ctx, "chat-1", time.Time{})
+	if err != nil || len(saved) != 1 {
+		t.Fatalf("saved messages = %+v, %v", saved, err)
+	}
+	if saved[0].UnipileID != "sent-1" || saved[0].SentByEmail != "owner@example.com" || !saved[0].IsSender ||
+		len(saved[0].AttachmentNames) != 1 || saved[0].AttachmentNames[0] != "deck.pdf" || msg.UnipileID != "sent-1" {
+		t.Errorf("saved message = %+v", saved[0])
+	}
+}
+
+func TestInboxStartChat(t *testing.T) {
+	ctx := context.Background()
+	ti := newTestInboxService(t)
+
+	if _, err := ti.svc.StartChat(ctx, "owner@example.com", "acc-1", nil, OutgoingMessage{Text: "Hello"}); !errors.Is(err, apperr.ErrValidation) {
+		t.Errorf("StartChat without attendees = %v, want apperr.ErrValidation", err)
+	}
+
+	started, err := ti.svc.StartChat(ctx, "owner@example.com", "acc-1", []string{"prov-bob"}, OutgoingMessage{Text: "Hello"})
+	if err != nil {
+		t.Fatalf("StartChat: %v", err)
+	}
+	if started.ChatID != "chat-new" || started.Message.UnipileID != "sent-2" || started.Message.ChatID != "chat-new" {
+		t.Errorf("StartChat = %+v", started)
+	}
+	if saved, _ := ti.messages.ListByChatSince(ctx, "chat-new", time.Time{}); len(saved) != 1 {
+		t.Errorf("saved %d messages in the new chat, want 1", len(saved))
+	}
+}
+
+func TestInboxChecksAccountOwnership(t *testing.T) {
+	ctx := context.Background()
+	ti := newTestInboxService(t)
+	// chat-3 已同步到本地，屬於 acc-1
+	if err := ti.chats.Upsert(ctx, &model.Chat{AccountID: "acc-1", UnipileID: "chat-3", LastMessageAt: inboxTime}); err != nil {
+		t.Fatalf("Upsert chat: %v", err)
+	}
+
+	for name, call := range map[string]func() error{
+		"ListChats": func() error {
+			_, err := ti.svc.ListChats(ctx, "owner@example.com", "acc-2", "", 10)
+			return err
+		},
+		"ListMessages": func() error {
+			_, err := ti.svc.ListMessages(ctx, "owner@example.com", "chat-other", "", 10)
+			return err
+		},
+		"ListMessagesLocal": func() error {
+			_, err := ti.svc.ListMessages(ctx, "other@example.com", "chat-3", "", 10)
+			return err
+		},
+		"ListMessagesUnknown": func() error {
+			_, err := ti.svc.ListMessages(ctx, "owner@example.com", "chat-missing", "", 10)
+			return err
+		},
+		"SendMessage": func() error {
+			_, err := ti.svc.SendMessage(ctx, "owner@example.com", "chat-other", OutgoingMessage{Text: "Hi", Files: []unipile.File{{Name: "a.txt", Content: strings.NewReader("a")}}})
+			return err
+		},
+		"StartChat": func() error {
+			_, err := ti.svc.StartChat(ctx, "owner@example.com", "acc-2", []string{"prov-bob"}, OutgoingMessage{Text: "Hi"})
+			return err
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// 不屬於使用者的帳號與對話都回傳 404，不洩漏是否存在
+			if err := call(); !errors.Is(err, apperr.ErrNotFound) {
+				t.Errorf("%s = %v, want apperr.ErrNotFound", name, err)
+			}
+		})
+	}
+	if n := ti.sent.Load(); n != 0 {
+		t.Errorf("Unipile received %d messages for another user's account, want 0", n)
+	}
+}
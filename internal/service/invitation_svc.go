@@ -0,0 +1,301 @@
+package service
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"chatsheet/internal/unipile"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxInvitationNote LinkedIn 邀請附加訊息的字數上限
+const maxInvitationNote = 300
+
+// maxInvitationPages 找不到收到的邀請時，最多向 Unipile 讀取的頁數
+const maxInvitationPages = 10
+
+// InvitationService 管理連結帳號的 LinkedIn 連結邀請，並在本地記錄每個邀請的狀態
+type InvitationService struct {
+	unipileSvc     *UnipileService
+	client         *unipile.Client
+	unipileRepo    itfc.UnipileRepository
+	invitationRepo itfc.InvitationRepository
+	quotaSvc       *QuotaService
+}
+
+func NewInvitationService(unipileSvc *UnipileService, client *unipile.Client, unipileRepo itfc.UnipileRepository, invitationRepo itfc.InvitationRepository, quotaSvc *QuotaService) *InvitationService {
+	return &InvitationService{
+		unipileSvc:     unipileSvc,
+		client:         client,
+		unipileRepo:    unipileRepo,
+		invitationRepo: invitationRepo,
+		quotaSvc:       quotaSvc,
+	}
+}
+
+// Send 透過帳號向對方送出連結邀請，帳號必須屬於該使用者
+func (s *InvitationService) Send(ctx context.Context, email, accountID, providerID, message string) (*model.Invitation, error) {
+	providerID = strings.TrimSpace(providerID)
+	if providerID == "" {
+		return nil, apperr.Validation("provider_id is required")
+	}
+	if utf8.RuneCountInString(message) > maxInvitationNote {
+		return nil, apperr.Validation("message must be at most 300 characters")
+	}
+	if _, err := s.unipileSvc.Get(ctx, email, accountID); err != nil {
+		return nil, err
+	}
+
+	var sent *unipile.InvitationSent
+	err := s.quotaSvc.Do(ctx, accountID, model.ActionInvite, func() (err error) {
+		sent, err = s.client.SendInvitation(ctx, accountID, providerID, message)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	inv := &model.Invitation{
+		AccountID:   accountID,
+		UnipileID:   sent.InvitationID,
+		Direction:   model.InvitationSent,
+		Status:      model.InvitationPending,
+		ProviderID:  providerID,
+		Message:     message,
+		SentByEmail: email,
+		InvitedAt:   &now,
+	}
+	if err := s.invitationRepo.Upsert(ctx, inv); err != nil {
+		// 邀請已經送出，本地紀錄失敗不影響結果
+		slog.ErrorContext(ctx, "Failed to save sent invitation", "account_id", accountID, "invitation_id", sent.InvitationID, "err", err)
+	}
+
+	return inv, nil
+}
+
+// List 列出帳號尚未回應的邀請 (direction 為 sent 或 received)，並更新本地紀錄
+// 回傳的 next_cursor 為空字串代表沒有下一頁
+func (s *InvitationService) List(ctx context.Context, email, accountID, direction, cursor string, limit int) ([]model.Invitation, string, error) {
+	if direction != model.InvitationSent && direction != model.InvitationReceived {
+		return nil, "", apperr.Validation("direction must be sent or received")
+	}
+	if _, err := s.unipileSvc.Get(ctx, email, accountID); err != nil {
+		return nil, "", err
+	}
+
+	return s.fetch(ctx, accountID, direction, unipile.ListOptions{Cursor: cursor, Limit: pagination.Limit(limit)})
+}
+
+// Accept 接受收到的邀請
+func (s *InvitationService) Accept(ctx context.Context, email, accountID, invitationID string) (*model.Invitation, error) {
+	return s.respond(ctx, email, accountID, invitationID, unipile.InvitationAccept, model.InvitationAccepted)
+}
+
+// Decline 拒絕收到的邀請
+func (s *InvitationService) Decline(ctx context.Context, email, accountID, invitationID string) (*model.Invitation, error) {
+	return s.respond(ctx, email, accountID, invitationID, unipile.InvitationDecline, model.InvitationDeclined)
+}
+
+func (s *InvitationService) respond(ctx context.Context, email, accountID, invitationID, action, status string) (*model.Invitation, error) {
+	if _, err := s.unipileSvc.Get(ctx, email, accountID); err != nil {
+		return nil, err
+	}
+
+	inv, err := s.invitationRepo.GetByUnipileID(ctx, accountID, invitationID)
+	if errors.Is(err, apperr.ErrNotFound) {
+		// 尚未列出過的邀請沒有 shared_secret，從 Unipile 找出來
+		inv, err = s.findReceived(ctx, accountID, invitationID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if inv.Direction != model.InvitationReceived {
+		return nil, apperr.Validation("Only received invitations can be accepted or declined")
+	}
+	if inv.Status != model.InvitationPending {
+		return nil, apperr.Conflict("Invitation is already " + inv.Status)
+	}
+
+	if err := s.client.HandleInvitation(ctx, accountID, invitationID, inv.SharedSecret, action); err != nil {
+		return nil, err
+	}
+
+	return s.updateStatus(ctx, inv, status), nil
+}
+
+// Withdraw 撤回已送出的邀請
+func (s *InvitationService) Withdraw(ctx context.Context, email, accountID, invitationID string) (*model.Invitation, error) {
+	if _, err := s.unipileSvc.Get(ctx, email, accountID); err != nil {
+		return nil, err
+	}
+
+	inv, err := s.invitationRepo.GetByUnipileID(ctx, accountID, invitationID)
+	if err != nil && !errors.Is(err, apperr.ErrNotFound) {
+		return nil, err
+	}
+	if inv != nil && inv.Direction != model.InvitationSent {
+		return nil, apperr.Validation("Only sent invitations can be withdrawn")
+	}
+	if inv != nil && inv.Status != model.InvitationPending {
+		return nil, apperr.Conflict("Invitation is already " + inv.Status)
+	}
+
+	if err := s.client.CancelInvitation(ctx, accountID, invitationID); err != nil {
+		return nil, err
+	}
+
+	if inv == nil {
+		// 不是透過 Chatsheet 送出的邀請，仍然留下紀錄
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		inv = &model.Invitation{
+			AccountID:   accountID,
+			UnipileID:   invitationID,
+			Direction:   model.InvitationSent,
+			Status:      model.InvitationWithdrawn,
+			RespondedAt: &now,
+		}
+		if err := s.invitationRepo.Upsert(ctx, inv); err != nil {
+			slog.ErrorContext(ctx, "Failed to save withdrawn invitation", "account_id", accountID, "invitation_id", invitationID, "err", err)
+		}
+		return inv, nil
+	}
+
+	return s.updateStatus(ctx, inv, model.InvitationWithdrawn), nil
+}
+
+// HandleRelationEvent 處理 new_relation webhook：與對方之間 pending 的邀請都已被接受
+func (s *InvitationService) HandleRelationEvent(ctx context.Context, ev *unipile.RelationEvent) error {
+	if ev.AccountID == "" || ev.UserProviderID == "" {
+		return apperr.Validation("account_id and user_provider_id are required")
+	}
+
+	if _, err := s.unipileRepo.GetByAccountID(ctx, ev.AccountID); err != nil {
+		if errors.Is(err, apperr.ErrNotFound) {
+			slog.InfoContext(ctx, "Ignore webhook for unknown account", "account_id", ev.AccountID, "event", ev.Event)
+			return nil
+		}
+		return err
+	}
+
+	n, err := s.invitationRepo.MarkAccepted(ctx, ev.AccountID, ev.UserProviderID, time.Now().UTC().Truncate(time.Microsecond))
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "New relation", "account_id", ev.AccountID, "provider_id", ev.UserProviderID, "invitations", n)
+
+	return nil
+}
+
+// fetch 向 Unipile 列出一頁尚未回應的邀請並更新本地紀錄
+func (s *InvitationService) fetch(ctx context.Context, accountID, direction string, opts unipile.ListOptions) ([]model.Invitation, string, error) {
+	var invs []model.Invitation
+	var next string
+	if direction == model.InvitationSent {
+		list, err := s.client.ListSentInvitations(ctx, accountID, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, i := range list.Items {
+			invs = append(invs, sentInvitationFromUnipile(accountID, i))
+		}
+		next = list.Cursor
+	} else {
+		list, err := s.client.ListReceivedInvitations(ctx, accountID, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, i := range list.Items {
+			invs = append(invs, receivedInvitationFromUnipile(accountID, i))
+		}
+		next = list.Cursor
+	}
+
+	for i := range invs {
+		if err := s.invitationRepo.Upsert(ctx, &invs[i]); err != nil {
+			return nil, "", err
+		}
+	}
+	if invs == nil {
+		invs = []model.Invitation{}
+	}
+
+	return invs, next, nil
+}
+
+// findReceived 從 Unipile 收到的邀請中找出指定的邀請 (會同時更新本地紀錄)
+func (s *InvitationService) findReceived(ctx context.Context, accountID, invitationID string) (*model.Invitation, error) {
+	opts := unipile.ListOptions{Limit: pagination.MaxLimit}
+	for page := 0; page < maxInvitationPages; page++ {
+		invs, next, err := s.fetch(ctx, accountID, model.InvitationReceived, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, inv := range invs {
+			if inv.UnipileID == invitationID {
+				return &inv, nil
+			}
+		}
+		if next == "" {
+			break
+		}
+		opts.Cursor = next
+	}
+
+	return nil, apperr.NotFound("Invitation not found")
+}
+
+// updateStatus 更新本地紀錄的狀態，Unipile 已經完成動作，本地失敗時只記錄錯誤
+func (s *InvitationService) updateStatus(ctx context.Context, inv *model.Invitation, status string) *model.Invitation {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	if err := s.invitationRepo.UpdateStatus(ctx, inv.ID, status, now); err != nil {
+		slog.ErrorContext(ctx, "Failed to update invitation status", "invitation_id", inv.UnipileID, "status", status, "err", err)
+	}
+	inv.Status = status
+	inv.RespondedAt = &now
+	return inv
+}
+
+func sentInvitationFromUnipile(accountID string, i unipile.SentInvitation) model.Invitation {
+	return model.Invitation{
+		AccountID:        accountID,
+		UnipileID:        i.ID,
+		Direction:        model.InvitationSent,
+		ProviderID:       i.InvitedUserID,
+		PublicIdentifier: i.InvitedUserPublicID,
+		Name:             i.InvitedUser,
+		Headline:         i.InvitedUserDescription,
+		Message:          i.InvitationText,
+		InvitedAt:        invitationTime(i.ParsedDatetime),
+	}
+}
+
+func receivedInvitationFromUnipile(accountID string, i unipile.ReceivedInvitation) model.Invitation {
+	return model.Invitation{
+		AccountID:        accountID,
+		UnipileID:        i.ID,
+		Direction:        model.InvitationReceived,
+		ProviderID:       i.Inviter.InviterID,
+		PublicIdentifier: i.Inviter.InviterPublicIdentifier,
+		Name:             i.Inviter.InviterName,
+		Headline:         i.Inviter.InviterDescription,
+		Message:          i.InvitationText,
+		SharedSecret:     i.Specifics.SharedSecret,
+		InvitedAt:        invitationTime(i.ParsedDatetime),
+	}
+}
+
+// invitationTime 解析邀請時間，無法解析時回傳 nil
+func invitationTime(ts string) *time.Time {
+	t := unipile.ParseTimestamp(ts)
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/outbox"
+	"chatsheet/internal/repository/memory"
+	"chatsheet/internal/unipile"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testInvitations 是 newTestInvitationService 建立的服務與 Unipile 收到的動作
+type testInvitations struct {
+	svc         *InvitationService
+	invitations itfc.InvitationRepository
+
+	mu      sync.Mutex
+	actions []string // 例如 "invite prov-bob"、"accept inv-r1"、"withdraw inv-sent-prov-bob"
+}
+
+// newTestInvitationService 建立 owner@example.com 的帳號 acc-1 與 other@example.com 的帳號 acc-2
+// Unipile 上 acc-1 收到兩個邀請，每頁 1 筆：inv-r1 (prov-carol) 與 inv-r2 (prov-dave)
+func newTestInvitationService(t *testing.T) *testInvitations {
+	t.Helper()
+
+	ti := &testInvitations{}
+	secrets := map[string]string{"inv-r1": "secret-1", "inv-r2": "secret-2"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/users/invite", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		ti.record("invite " + body["provider_id"] + " " + body["message"])
+		json.NewEncoder(w).Encode(unipile.InvitationSent{Object: "UserInvitationSent", InvitationID: "inv-sent-" + body["provider_id"]})
+	})
+	mux.HandleFunc("GET /api/v1/users/invite/received", func(w http.ResponseWriter, r *http.Request) {
+		var list unipile.List[unipile.ReceivedInvitation]
+		var inv unipile.ReceivedInvitation
+		if r.URL.Query().Get("cursor") == "" {
+			inv.ID, inv.Inviter.InviterID, inv.Inviter.InviterName = "inv-r1", "prov-carol", "Carol Lin"
+			list.Cursor = "page-2"
+		} else {
+			inv.ID, inv.Inviter.InviterID, inv.Inviter.InviterName = "inv-r2", "prov-dave", "Dave Wu"
+		}
+		inv.Specifics.SharedSecret = secrets[inv.ID]
+		list.Items = []unipile.ReceivedInvitation{inv}
+		json.NewEncoder(w).Encode(list)
+	})
+	mux.HandleFunc("POST /api/v1/users/invite/received/{id}", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["shared_secret"] != secrets[r.PathValue("id")] {
+			t.Errorf("shared_secret for %s = %q", r.PathValue("id"), body["shared_secret"])
+		}
+		ti.record(body["action"] + " " + r.PathValue("id"))
+	})
+	mux.HandleFunc("DELETE /api/v1/users/invite/sent/{id}", func(w http.ResponseWriter, r *http.Request) {
+		ti.record("withdraw " + r.PathValue("id"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	unipileRepo := newTestUnipileRepository(t, "owner@example.com", "other@example.com")
+	unipileSvc := NewUnipileService(unipileRepo, memory.NewTransactor(), outbox.NewWriter(memory.NewOutboxRepository()))
+	for email, accountID := range map[string]string{"owner@example.com": "acc-1", "other@example.com": "acc-2"} {
+		if _, err := unipileSvc.Create(context.Background(), email, "linkedin", accountID); err != nil {
+			t.Fatalf("Create account: %v", err)
+		}
+	}
+	quotaSvc := NewQuotaService(config.QuotasConfig{}, unipileSvc, memory.NewQuotaRepository())
+	ti.invitations = memory.NewInvitationRepository()
+	client := unipile.NewClient(config.UnipileConfig{APIBaseURL: srv.URL})
+	ti.svc = NewInvitationService(unipileSvc, client, unipileRepo, ti.invitations, quotaSvc)
+	return ti
+}
+
+func (ti *testInvitations) record(action string) {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	ti.actions = append(ti.actions, strings.TrimSpace(action))
+}
+
+// takeActions 回傳並清除 Unipile 收到的動作
+func (ti *testInvitations) takeActions() string {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	actions := strings.Join(ti.actions, ", ")
+	ti.actions = nil
+	return actions
+}
+
+func TestInvitationSend(t *testing.T) {
+	ctx := context.Background()
+	ti := newTestInvitationService(t)
+
+	for name, call := range map[string]func() error{
+		"NoProvider": func() error {
+			_, err := ti.svc.Send(ctx, "owner@example.com", "acc-1", " ", "")
+			return err
+		},
+		"LongMessage": func() error {
+			_, err := ti.svc.Send(ctx, "owner@example.com", "acc-1", "prov-bob", strings.Repeat("字", maxInvitationNote+1))
+			return err
+		},
+	} {
+		if err := call(); !errors.Is(err, apperr.ErrValidation) {
+			t.Errorf("%s = %v, want apperr.ErrValidation", name, err)
+		}
+	}
+	if _, err := ti.svc.Send(ctx, "owner@example.com", "acc-2", "prov-bob", ""); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("Send through another user's account = %v, want apperr.ErrNotFound", err)
+	}
+	if got := ti.takeActions(); got != "" {
+		t.Fatalf("Unipile received %q for rejected invitations", got)
+	}
+
+	inv, err := ti.svc.Send(ctx, "owner@example.com", "acc-1", "prov-bob", "Hi Bob")
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got := ti.takeActions(); got != "invite prov-bob Hi Bob" {
+		t.Errorf("Unipile received %q", got)
+	}
+	saved, err := ti.invitations.GetByUnipileID(ctx, "acc-1", "inv-sent-prov-bob")
+	if err != nil || saved.ID != inv.ID || saved.Direction != model.InvitationSent || saved.Status != model.InvitationPending ||
+		saved.SentByEmail != "owner@example.com" || saved.Message != "Hi Bob" || saved.InvitedAt == nil {
+		t.Errorf("saved invitation = %+v, %v", saved, err)
+	}
+}
+
+func TestInvitationAcceptAndDecline(t *testing.T) {
+	ctx := context.Background()
+	ti := newTestInvitationService(t)
+
+	// 尚未列出過的邀請：從 Unipile 的收到的邀請中找出 shared_secret (在第 2 頁)
+	inv, err := ti.svc.Accept(ctx, "owner@example.com", "acc-1", "inv-r2")
+	if err != nil || inv.Status != model.InvitationAccepted || inv.RespondedAt == nil {
+		t.Fatalf("Accept = %+v, %v", inv, err)
+	}
+	if got := ti.takeActions(); got != "accept inv-r2" {
+		t.Errorf("Unipile received %q, want accept inv-r2", got)
+	}
+
+	// 已經列出過的邀請使用本地的 shared_secret
+	invs, next, err := ti.svc.List(ctx, "owner@example.com", "acc-1", model.InvitationReceived, "", 10)
+	if err != nil || len(invs) != 1 || invs[0].UnipileID != "inv-r1" || next != "page-2" {
+		t.Fatalf("List = %+v, %q, %v", invs, next, err)
+	}
+	inv, err = ti.svc.Decline(ctx, "owner@example.com", "acc-1", "inv-r1")
+	if err != nil || inv.Status != model.InvitationDeclined {
+		t.Fatalf("Decline = %+v, %v", inv, err)
+	}
+	if got := ti.takeActions(); got != "decline inv-r1" {
+		t.Errorf("Unipile received %q, want decline inv-r1", got)
+	}
+
+	// 已經回應的邀請不能再接受或拒絕，重新列出也不會把狀態改回 pending
+	for _, id := range []string{"inv-r1", "inv-r2"} {
+		if _, err := ti.svc.Accept(ctx, "owner@example.com", "acc-1", id); !errors.Is(err, apperr.ErrConflict) {
+			t.Errorf("Accept(%s) after responding = %v, want apperr.ErrConflict", id, err)
+		}
+	}
+	invs, _, err = ti.svc.List(ctx, "owner@example.com", "acc-1", model.InvitationReceived, "", 10)
+	if err != nil || invs[0].Status != model.InvitationDeclined {
+		t.Errorf("List after Decline = %+v, %v, want inv-r1 still declined", invs, err)
+	}
+	if got := ti.takeActions(); got != "" {
+		t.Errorf("Unipile received %q for invalid transitions", got)
+	}
+
+	if _, err := ti.svc.Accept(ctx, "owner@example.com", "acc-1", "inv-missing"); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("Accept(unknown) = %v, want apperr.ErrNotFound", err)
+	}
+	if _, err := ti.svc.Decline(ctx, "other@example.com", "acc-1", "inv-r1"); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("Decline through another user's account = %v, want apperr.ErrNotFound", err)
+	}
+	if _, _, err := ti.svc.List(ctx, "owner@example.com", "acc-1", "both", "", 10); !errors.Is(err, apperr.ErrValidation) {
+		t.Errorf("List(both) = %v, want apperr.ErrValidation", err)
+	}
+}
+
+func TestInvitationWithdraw(t *testing.T) {
+	ctx := context.Background()
+	ti := newTestInvitationService(t)
+	if _, err := ti.svc.Send(ctx, "owner@example.com", "acc-1", "prov-bob", ""); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if _, _, err := ti.svc.List(ctx, "owner@example.com", "acc-1", model.InvitationReceived, "", 10); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	ti.takeActions()
+
+	inv, err := ti.svc.Withdraw(ctx, "owner@example.com", "acc-1", "inv-sent-prov-bob")
+	if err != nil || inv.Status != model.InvitationWithdrawn {
+		t.Fatalf("Withdraw = %+v, %v", inv, err)
+	}
+	if got := ti.takeActions(); got != "withdraw inv-sent-prov-bob" {
+		t.Errorf("Unipile received %q", got)
+	}
+
+	// 不合法的狀態轉換不呼叫 Unipile
+	if _, err := ti.svc.Withdraw(ctx, "owner@example.com", "acc-1", "inv-sent-prov-bob"); !errors.Is(err, apperr.ErrConflict) {
+		t.Errorf("Withdraw twice = %v, want apperr.ErrConflict", err)
+	}
+	if _, err := ti.svc.Accept(ctx, "owner@example.com", "acc-1", "inv-sent-prov-bob"); !errors.Is(err, apperr.ErrValidation) {
+		t.Errorf("Accept a sent invitation = %v, want apperr.ErrValidation", err)
+	}
+	if _, err := ti.svc.Withdraw(ctx, "owner@example.com", "acc-1", "inv-r1"); !errors.Is(err, apperr.ErrValidation) {
+		t.Errorf("Withdraw a received invitation = %v, want apperr.ErrValidation", err)
+	}
+	if got := ti.takeActions(); got != "" {
+		t.Errorf("Unipile received %q for invalid transitions", got)
+	}
+
+	// 不是透過 Chatsheet 送出的邀請，撤回後留下紀錄
+	inv, err = ti.svc.Withdraw(ctx, "owner@example.com", "acc-1", "inv-elsewhere")
+	if err != nil || inv.Status != model.InvitationWithdrawn {
+		t.Fatalf("Withdraw an unknown invitation = %+v, %v", inv, err)
+	}
+	if saved, err := ti.invitations.GetByUnipileID(ctx, "acc-1", "inv-elsewhere"); err != nil || saved.Status != model.InvitationWithdrawn {
+		t.Errorf("saved invitation = %+v, %v", saved, err)
+	}
+}
+
+func TestInvitationRelationEvent(t *testing.T) {
+	ctx := context.Background()
+	ti := newTestInvitationService(t)
+	for _, provider := range []string{"prov-bob", "prov-erin"} {
+		if _, err := ti.svc.Send(ctx, "owner@example.com", "acc-1", provider, ""); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	if _, err := ti.svc.Withdraw(ctx, "owner@example.com", "acc-1", "inv-sent-prov-erin"); err != nil {
+		t.Fatalf("Withdraw: %v", err)
+	}
+
+	// new_relation：與對方之間 pending 的邀請改為已接受，已撤回的邀請不變
+	for _, provider := range []string{"prov-bob", "prov-erin"} {
+		if err := ti.svc.HandleRelationEvent(ctx, &unipile.RelationEvent{Event: "new_relation", AccountID: "acc-1", UserProviderID: provider}); err != nil {
+			t.Fatalf("HandleRelationEvent: %v", err)
+		}
+	}
+	for id, want := range map[string]string{"inv-sent-prov-bob": model.InvitationAccepted, "inv-sent-prov-erin": model.InvitationWithdrawn} {
+		inv, err := ti.invitations.GetByUnipileID(ctx, "acc-1", id)
+		if err != nil || inv.Status != want {
+			t.Errorf("%s = %+v, %v, want %s", id, inv, err, want)
+		}
+	}
+
+	// 不認識的帳號略過 (Unipile 不必重送)，缺少欄位時回傳錯誤
+	if err := ti.svc.HandleRelationEvent(ctx, &unipile.RelationEvent{AccountID: "acc-unknown", UserProviderID: "prov-bob"}); err != nil {
+		t.Errorf("HandleRelationEvent for an unknown account = %v, want nil", err)
+	}
+	if err := ti.svc.HandleRelationEvent(ctx, &unipile.RelationEvent{AccountID: "acc-1"}); !errors.Is(err, apperr.ErrValidation) {
+		t.Errorf("HandleRelationEvent without user_provider_id = %v, want apperr.ErrValidation", err)
+	}
+}
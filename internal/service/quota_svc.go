@@ -0,0 +1,338 @@
+package service
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// quotaActions 受配額限制的動作，依回傳給前端的順序排列
+var quotaActions = []string{model.ActionInvite, model.ActionMessage}
+
+// weekdayNames 是 AccountLimits.WorkDays 使用的名稱，索引對應 time.Weekday
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// QuotaExceeded 是超過配額或不在工作時間時，錯誤回應的 details
+type QuotaExceeded struct {
+	Action  string    `json:"action"`
+	Reason  string    `json:"reason"` // daily、weekly 或 working_hours
+	Limit   int       `json:"limit,omitempty"`
+	RetryAt time.Time `json:"retry_at"` // 最早可以再執行的時間
+}
+
+// AccountQuota 是帳號的配額設定與目前的使用情況
+type AccountQuota struct {
+	Limits             *model.AccountLimits `json:"limits"`
+	WithinWorkingHours bool                 `json:"within_working_hours"`
+	NextWorkingTime    *time.Time           `json:"next_working_time,omitempty"` // 不在工作時間時，下一次開始的時間
+	Actions            []ActionQuota        `json:"actions"`
+}
+
+// ActionQuota 是一種動作的每日與每週配額
+type ActionQuota struct {
+	Action string      `json:"action"`
+	Daily  QuotaStatus `json:"daily"`
+	Weekly QuotaStatus `json:"weekly"`
+}
+
+// QuotaStatus 是一段配額期間的使用情況
+type QuotaStatus struct {
+	Limit     int       `json:"limit"` // 0 代表不限制
+	Used      int       `json:"used"`
+	Remaining *int      `json:"remaining"` // 不限制時為 null
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// QuotaService 在每個對外動作 (邀請、訊息) 之前檢查連結帳號的配額與工作時間，避免帳號被 LinkedIn 限制
+// 配額以帳號時區的日曆日與週 (週一開始) 計算，帳號沒有設定時使用 config 的預設值
+type QuotaService struct {
+	cfg        config.QuotasConfig
+	unipileSvc *UnipileService
+	quotaRepo  itfc.QuotaRepository
+}
+
+func NewQuotaService(cfg config.QuotasConfig, unipileSvc *UnipileService, quotaRepo itfc.QuotaRepository) *QuotaService {
+	if cfg.Timezone == "" {
+		cfg.Timezone = "UTC"
+	}
+	if cfg.WorkStart == "" {
+		cfg.WorkStart = "00:00"
+	}
+	if cfg.WorkEnd == "" {
+		cfg.WorkEnd = "24:00"
+	}
+
+	return &QuotaService{
+		cfg:        cfg,
+		unipileSvc: unipileSvc,
+		quotaRepo:  quotaRepo,
+	}
+}
+
+// Do 在帳號的配額與工作時間允許時執行 fn，fn 失敗時歸還配額
+// 不允許時回傳 apperr.ErrTooManyRequests，details 為 QuotaExceeded；呼叫者必須已經確認帳號的擁有者
+func (s *QuotaService) Do(ctx context.Context, accountID, action string, fn func() error) error {
+	limits, err := s.limits(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	loc, err := time.LoadLocation(limits.Timezone)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	if ok, next := workingHours(limits, loc, now); !ok {
+		return apperr.TooManyRequests("Outside the account's working hours").
+			WithDetails(QuotaExceeded{Action: action, Reason: "working_hours", RetryAt: next})
+	}
+
+	day, week := quotaPeriods(now.In(loc))
+	daily, weekly := actionLimits(limits, action)
+	var windows []itfc.QuotaWindow
+	if daily > 0 {
+		windows = append(windows, itfc.QuotaWindow{Since: day, Limit: daily})
+	}
+	if weekly > 0 {
+		windows = append(windows, itfc.QuotaWindow{Since: week, Limit: weekly})
+	}
+
+	usage := &model.ActionUsage{AccountID: accountID, Action: action, CreatedAt: now}
+	ok, err := s.quotaRepo.Consume(ctx, usage, windows)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return s.exceeded(ctx, accountID, action, day, week, daily, weekly)
+	}
+
+	if err := fn(); err != nil {
+		// 動作沒有成功，歸還配額
+		if rerr := s.quotaRepo.Release(context.WithoutCancel(ctx), usage.ID); rerr != nil {
+			slog.ErrorContext(ctx, "Failed to release quota", "account_id", accountID, "action", action, "err", rerr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// exceeded 回傳用完的配額期間 (優先回報每日配額) 對應的錯誤
+func (s *QuotaService) exceeded(ctx context.Context, accountID, action string, day, week time.Time, daily, weekly int) error {
+	if daily > 0 {
+		used, err := s.quotaRepo.Count(ctx, accountID, action, day)
+		if err != nil {
+			return err
+		}
+		if used >= daily {
+			return apperr.TooManyRequests(fmt.Sprintf("Daily %s quota exceeded", action)).
+				WithDetails(QuotaExceeded{Action: action, Reason: "daily", Limit: daily, RetryAt: day.AddDate(0, 0, 1).UTC()})
+		}
+	}
+
+	return apperr.TooManyRequests(fmt.Sprintf("Weekly %s quota exceeded", action)).
+		WithDetails(QuotaExceeded{Action: action, Reason: "weekly", Limit: weekly, RetryAt: week.AddDate(0, 0, 7).UTC()})
+}
+
+// Get 回傳帳號的配額設定與使用情況，帳號必須屬於該使用者
+func (s *QuotaService) Get(ctx context.Context, email, accountID string) (*AccountQuota, error) {
+	if _, err := s.unipileSvc.Get(ctx, email, accountID); err != nil {
+		return nil, err
+	}
+
+	limits, err := s.limits(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.status(ctx, limits)
+}
+
+// Update 覆寫帳號的配額設定，帳號必須屬於該使用者
+func (s *QuotaService) Update(ctx context.Context, email, accountID string, limits model.AccountLimits) (*AccountQuota, error) {
+	if err := validateLimits(&limits); err != nil {
+		return nil, err
+	}
+	if _, err := s.unipileSvc.Get(ctx, email, accountID); err != nil {
+		return nil, err
+	}
+
+	limits.AccountID = accountID
+	if err := s.quotaRepo.SaveLimits(ctx, &limits); err != nil {
+		return nil, err
+	}
+
+	return s.status(ctx, &limits)
+}
+
+func (s *QuotaService) status(ctx context.Context, limits *model.AccountLimits) (*AccountQuota, error) {
+	loc, err := time.LoadLocation(limits.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	quota := &AccountQuota{Limits: limits, Actions: []ActionQuota{}}
+	ok, next := workingHours(limits, loc, now)
+	quota.WithinWorkingHours = ok
+	if !ok {
+		quota.NextWorkingTime = &next
+	}
+
+	day, week := quotaPeriods(now.In(loc))
+	for _, action := range quotaActions {
+		daily, weekly := actionLimits(limits, action)
+		dailyStatus, err := s.usage(ctx, limits.AccountID, action, day, day.AddDate(0, 0, 1), daily)
+		if err != nil {
+			return nil, err
+		}
+		weeklyStatus, err := s.usage(ctx, limits.AccountID, action, week, week.AddDate(0, 0, 7), weekly)
+		if err != nil {
+			return nil, err
+		}
+		quota.Actions = append(quota.Actions, ActionQuota{Action: action, Daily: dailyStatus, Weekly: weeklyStatus})
+	}
+
+	return quota, nil
+}
+
+func (s *QuotaService) usage(ctx context.Context, accountID, action string, since, resetsAt time.Time, limit int) (QuotaStatus, error) {
+	used, err := s.quotaRepo.Count(ctx, accountID, action, since)
+	if err != nil {
+		return QuotaStatus{}, err
+	}
+
+	status := QuotaStatus{Limit: limit, Used: used, ResetsAt: resetsAt.UTC()}
+	if limit > 0 {
+		remaining := max(limit-used, 0)
+		status.Remaining = &remaining
+	}
+	return status, nil
+}
+
+// limits 回傳帳號的設定，沒有設定時使用 config 的預設值
+func (s *QuotaService) limits(ctx context.Context, accountID string) (*model.AccountLimits, error) {
+	limits, err := s.quotaRepo.GetLimits(ctx, accountID)
+	if errors.Is(err, apperr.ErrNotFound) {
+		return &model.AccountLimits{
+			AccountID:     accountID,
+			Timezone:      s.cfg.Timezone,
+			WorkStart:     s.cfg.WorkStart,
+			WorkEnd:       s.cfg.WorkEnd,
+			WorkDays:      model.StringList(slices.Clone(s.cfg.WorkDays)),
+			InviteDaily:   s.cfg.InviteDaily,
+			InviteWeekly:  s.cfg.InviteWeekly,
+			MessageDaily:  s.cfg.MessageDaily,
+			MessageWeekly: s.cfg.MessageWeekly,
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return limits, nil
+}
+
+func validateLimits(l *model.AccountLimits) error {
+	if _, err := time.LoadLocation(l.Timezone); err != nil || l.Timezone == "" {
+		return apperr.Validation("timezone must be an IANA time zone, e.g. Asia/Taipei")
+	}
+	start, ok := parseClock(l.WorkStart)
+	if !ok {
+		return apperr.Validation("work_start must be HH:MM")
+	}
+	end, ok := parseClock(l.WorkEnd)
+	if !ok {
+		return apperr.Validation("work_end must be HH:MM")
+	}
+	if start >= end {
+		return apperr.Validation("work_start must be before work_end")
+	}
+
+	days := make(model.StringList, 0, len(l.WorkDays))
+	for _, d := range l.WorkDays {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if !slices.Contains(weekdayNames, d) {
+			return apperr.Validation("work_days must be mon, tue, wed, thu, fri, sat or sun")
+		}
+		if !slices.Contains(days, d) {
+			days = append(days, d)
+		}
+	}
+	l.WorkDays = days
+
+	if l.InviteDaily < 0 || l.InviteWeekly < 0 || l.MessageDaily < 0 || l.MessageWeekly < 0 {
+		return apperr.Validation("quotas must not be negative")
+	}
+	return nil
+}
+
+func actionLimits(l *model.AccountLimits, action string) (daily, weekly int) {
+	switch action {
+	case model.ActionInvite:
+		return l.InviteDaily, l.InviteWeekly
+	case model.ActionMessage:
+		return l.MessageDaily, l.MessageWeekly
+	default:
+		return 0, 0
+	}
+}
+
+// quotaPeriods 回傳 local 所在的日與週 (週一開始) 的開始時間
+func quotaPeriods(local time.Time) (day, week time.Time) {
+	day = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	offset := (int(day.Weekday()) + 6) % 7 // 週一為 0
+	week = day.AddDate(0, 0, -offset)
+	return day, week
+}
+
+// workingHours 回傳 now 是否在工作時間內；不在時一併回傳下一次開始的時間
+func workingHours(l *model.AccountLimits, loc *time.Location, now time.Time) (bool, time.Time) {
+	start, _ := parseClock(l.WorkStart)
+	end, _ := parseClock(l.WorkEnd)
+
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	for i := range 8 {
+		day := today.AddDate(0, 0, i)
+		if len(l.WorkDays) > 0 && !slices.Contains(l.WorkDays, weekdayNames[day.Weekday()]) {
+			continue
+		}
+		from, to := day.Add(start), day.Add(end)
+		if !local.Before(from) && local.Before(to) {
+			return true, time.Time{}
+		}
+		if local.Before(from) {
+			return false, from.UTC()
+		}
+	}
+
+	// 沒有任何工作日 (不會發生，WorkDays 只能是合法的名稱)
+	return false, today.AddDate(0, 0, 8).UTC()
+}
+
+// parseClock 解析 HH:MM (00:00 ~ 24:00) 為一天開始後的時間
+func parseClock(s string) (time.Duration, bool) {
+	hh, mm, ok := strings.Cut(s, ":")
+	if !ok || len(hh) != 2 || len(mm) != 2 {
+		return 0, false
+	}
+	h, err := strconv.Atoi(hh)
+	if err != nil || h < 0 || h > 24 {
+		return 0, false
+	}
+	m, err := strconv.Atoi(mm)
+	if err != nil || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, false
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, true
+}
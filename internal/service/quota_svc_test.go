@@ -0,0 +1,137 @@
+package service
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/model"
+	"chatsheet/internal/repository/memory"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestQuotaService(t *testing.T, cfg config.QuotasConfig) *QuotaService {
+	unipileSvc := newTestUnipileService(t, "owner@example.com")
+	return NewQuotaService(cfg, unipileSvc, memory.NewQuotaRepository())
+}
+
+func TestQuotaDoEnforcesDailyLimit(t *testing.T) {
+	ctx := context.Background()
+	s := newTestQuotaService(t, config.QuotasConfig{InviteDaily: 2})
+
+	var calls int
+	fn := func() error { calls++; return nil }
+	for range 2 {
+		if err := s.Do(ctx, "acc-1", model.ActionInvite, fn); err != nil {
+			t.Fatalf("Do: %v", err)
+		}
+	}
+
+	err := s.Do(ctx, "acc-1", model.ActionInvite, fn)
+	if !errors.Is(err, apperr.ErrTooManyRequests) {
+		t.Fatalf("Do over the limit err = %v, want apperr.ErrTooManyRequests", err)
+	}
+	var appErr *apperr.Error
+	if !errors.As(err, &appErr) {
+		t.Fatalf("Do err = %T, want *apperr.Error", err)
+	}
+	details, ok := appErr.Details.(QuotaExceeded)
+	if !ok || details.Reason != "daily" || details.Limit != 2 || !details.RetryAt.After(time.Now()) {
+		t.Errorf("details = %+v, want daily limit 2 with a future retry_at", appErr.Details)
+	}
+	if calls != 2 {
+		t.Errorf("fn ran %d times, want 2", calls)
+	}
+
+	// 配額以帳號與動作分別計算
+	if err := s.Do(ctx, "acc-1", model.ActionMessage, fn); err != nil {
+		t.Errorf("Do message: %v", err)
+	}
+	if err := s.Do(ctx, "acc-2", model.ActionInvite, fn); err != nil {
+		t.Errorf("Do other account: %v", err)
+	}
+}
+
+func TestQuotaDoReleasesOnFailure(t *testing.T) {
+	ctx := context.Background()
+	s := newTestQuotaService(t, config.QuotasConfig{MessageDaily: 1})
+
+	failed := errors.New("send failed")
+	if err := s.Do(ctx, "acc-1", model.ActionMessage, func() error { return failed }); !errors.Is(err, failed) {
+		t.Fatalf("Do err = %v, want the fn error", err)
+	}
+	// 失敗的動作歸還配額
+	if err := s.Do(ctx, "acc-1", model.ActionMessage, func() error { return nil }); err != nil {
+		t.Fatalf("Do after failure: %v", err)
+	}
+}
+
+func TestQuotaDoOutsideWorkingHours(t *testing.T) {
+	// 只有一天的 00:00 ~ 00:01 可以執行，其他時間都會被拒絕
+	now := time.Now().UTC()
+	tomorrow := weekdayNames[now.AddDate(0, 0, 1).Weekday()]
+	s := newTestQuotaService(t, config.QuotasConfig{WorkStart: "00:00", WorkEnd: "00:01", WorkDays: []string{tomorrow}})
+
+	err := s.Do(context.Background(), "acc-1", model.ActionInvite, func() error {
+		t.Error("fn ran outside working hours")
+		return nil
+	})
+	var appErr *apperr.Error
+	if !errors.As(err, &appErr) || !errors.Is(err, apperr.ErrTooManyRequests) {
+		t.Fatalf("Do err = %v, want apperr.ErrTooManyRequests", err)
+	}
+	if details, ok := appErr.Details.(QuotaExceeded); !ok || details.Reason != "working_hours" {
+		t.Errorf("details = %+v, want working_hours", appErr.Details)
+	}
+}
+
+func TestWorkingHours(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Taipei")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	limits := &model.AccountLimits{WorkStart: "09:00", WorkEnd: "18:00", WorkDays: model.StringList{"mon", "tue", "wed", "thu", "fri"}}
+
+	for name, tc := range map[string]struct {
+		now      time.Time
+		ok       bool
+		nextWant time.Time
+	}{
+		// 2025-01-06 是週一
+		"Within":        {time.Date(2025, 1, 6, 10, 0, 0, 0, loc), true, time.Time{}},
+		"BeforeStart":   {time.Date(2025, 1, 6, 8, 0, 0, 0, loc), false, time.Date(2025, 1, 6, 9, 0, 0, 0, loc)},
+		"AfterEnd":      {time.Date(2025, 1, 6, 18, 0, 0, 0, loc), false, time.Date(2025, 1, 7, 9, 0, 0, 0, loc)},
+		"FridayEvening": {time.Date(2025, 1, 10, 20, 0, 0, 0, loc), false, time.Date(2025, 1, 13, 9, 0, 0, 0, loc)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ok, next := workingHours(limits, loc, tc.now.UTC())
+			if ok != tc.ok || !next.Equal(tc.nextWant) {
+				t.Errorf("workingHours = %v %v, want %v %v", ok, next, tc.ok, tc.nextWant)
+			}
+		})
+	}
+}
+
+func TestValidateLimits(t *testing.T) {
+	valid := model.AccountLimits{Timezone: "UTC", WorkStart: "09:00", WorkEnd: "24:00", WorkDays: model.StringList{" MON ", "mon", "fri"}}
+	if err := validateLimits(&valid); err != nil {
+		t.Fatalf("validateLimits: %v", err)
+	}
+	if len(valid.WorkDays) != 2 || valid.WorkDays[0] != "mon" {
+		t.Errorf("WorkDays = %v, want normalized [mon fri]", valid.WorkDays)
+	}
+
+	for name, l := range map[string]model.AccountLimits{
+		"Timezone": {Timezone: "Mars/Olympus", WorkStart: "09:00", WorkEnd: "18:00"},
+		"Clock":    {Timezone: "UTC", WorkStart: "9am", WorkEnd: "18:00"},
+		"Order":    {Timezone: "UTC", WorkStart: "18:00", WorkEnd: "09:00"},
+		"WorkDays": {Timezone: "UTC", WorkStart: "09:00", WorkEnd: "18:00", WorkDays: model.StringList{"funday"}},
+		"Negative": {Timezone: "UTC", WorkStart: "09:00", WorkEnd: "18:00", InviteDaily: -1},
+		"After24h": {Timezone: "UTC", WorkStart: "09:00", WorkEnd: "24:30"},
+	} {
+		if err := validateLimits(&l); !errors.Is(err, apperr.ErrValidation) {
+			t.Errorf("%s: validateLimits err = %v, want apperr.ErrValidation", name, err)
+		}
+	}
+}
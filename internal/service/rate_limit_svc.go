@@ -0,0 +1,140 @@
+package service
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/itfc"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 限流鍵的類型
+const (
+	RateLimitByIP   = "ip"
+	RateLimitByUser = "user" // 未登入的請求改用 IP
+)
+
+// RateLimitPolicy 是 config 中一個 policy 解析後的設定
+type RateLimitPolicy struct {
+	Name   string
+	Key    string // ip 或 user
+	Limit  int
+	Period time.Duration
+	Bucket itfc.TokenBucket
+}
+
+// RateLimitExceeded 是超過限流時 429 回應的 details
+type RateLimitExceeded struct {
+	Policy     string `json:"policy"`
+	Limit      int    `json:"limit"`
+	Period     string `json:"period"`
+	RetryAfter int    `json:"retry_after"` // 秒
+}
+
+// RateLimitService 依路由找出適用的 policy，並從 store 取出 token
+type RateLimitService struct {
+	store  itfc.RateLimitStore
+	routes map[string][]*RateLimitPolicy // "METHOD /path" → 適用的 policy
+}
+
+// NewRateLimitService 解析 config 中的 policy，設定錯誤時回傳錯誤；未啟用時不限制任何路由
+func NewRateLimitService(cfg config.RateLimitConfig, store itfc.RateLimitStore) (*RateLimitService, error) {
+	s := &RateLimitService{store: store, routes: map[string][]*RateLimitPolicy{}}
+	if !cfg.Enabled {
+		return s, nil
+	}
+
+	// 依名稱排序，同一個路由的多個 policy 依固定順序取出 token
+	names := make([]string, 0, len(cfg.Policies))
+	for name := range cfg.Policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		pc := cfg.Policies[name]
+		switch pc.Key {
+		case RateLimitByIP, RateLimitByUser:
+		case "api_key":
+			// 目前沒有驗證 API key 的 middleware，未驗證的 X-API-Key header 可以任意更換，不能作為限流的鍵
+			return nil, fmt.Errorf("rate_limit.policies.%s: key api_key is not supported until API key authentication exists, use ip or user", name)
+		default:
+			return nil, fmt.Errorf("rate_limit.policies.%s: unknown key %q", name, pc.Key)
+		}
+		if pc.Limit <= 0 || pc.Period <= 0 {
+			return nil, fmt.Errorf("rate_limit.policies.%s: limit and period must be positive", name)
+		}
+		burst := pc.Burst
+		if burst <= 0 {
+			burst = pc.Limit
+		}
+
+		p := &RateLimitPolicy{
+			Name:   name,
+			Key:    pc.Key,
+			Limit:  pc.Limit,
+			Period: pc.Period,
+			Bucket: itfc.TokenBucket{Capacity: burst, Rate: float64(pc.Limit) / pc.Period.Seconds()},
+		}
+		for _, route := range pc.Routes {
+			method, path, ok := strings.Cut(strings.TrimSpace(route), " ")
+			method = strings.ToUpper(method)
+			path = strings.TrimSpace(path)
+			if !ok || !validMethod(method) || !strings.HasPrefix(path, "/") {
+				return nil, fmt.Errorf("rate_limit.policies.%s: route %q must be METHOD /path", name, route)
+			}
+			route = method + " " + path
+			s.routes[route] = append(s.routes[route], p)
+		}
+	}
+
+	return s, nil
+}
+
+func validMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// Policies 回傳適用於請求的 policy，route 為 Gin 的路由 (例如 /api/unipile/:account_id)
+func (s *RateLimitService) Policies(method, route string) []*RateLimitPolicy {
+	return s.routes[method+" "+route]
+}
+
+// Take 從 subject 在 policy 下的 bucket 取出一個 token
+// kind 為 subject 的類型，policy 以使用者為鍵、但請求沒有登入時為 RateLimitByIP
+func (s *RateLimitService) Take(ctx context.Context, p *RateLimitPolicy, kind, subject string) (itfc.RateLimitResult, error) {
+	return s.store.Take(ctx, rateLimitKey(p, kind, subject), p.Bucket, time.Now())
+}
+
+// Peek 回傳此時 Take 的結果，但不取出 token
+func (s *RateLimitService) Peek(ctx context.Context, p *RateLimitPolicy, kind, subject string) (itfc.RateLimitResult, error) {
+	return s.store.Peek(ctx, rateLimitKey(p, kind, subject), p.Bucket, time.Now())
+}
+
+// rateLimitKey 組合保存 bucket 的鍵；使用者以雜湊保存，store 中不留下 email
+func rateLimitKey(p *RateLimitPolicy, kind, subject string) string {
+	if kind != RateLimitByIP {
+		sum := sha256.Sum256([]byte(subject))
+		subject = hex.EncodeToString(sum[:16])
+	}
+	return p.Name + ":" + kind + ":" + subject
+}
+
+// PurgeExpired 刪除已補滿的 bucket，由定期工作執行
+// 補滿的 bucket 與不存在相同，這裡只是避免資料表無限增長
+func (s *RateLimitService) PurgeExpired(ctx context.Context) (string, error) {
+	n, err := s.store.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("deleted %d expired buckets", n), nil
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/repository/memory"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewRateLimitServiceValidatesPolicies(t *testing.T) {
+	tests := map[string]struct {
+		policy config.RateLimitPolicy
+		want   string
+	}{
+		"api key":        {config.RateLimitPolicy{Routes: []string{"POST /auth/login"}, Key: "api_key", Limit: 1, Period: time.Minute}, "api_key is not supported"},
+		"unknown key":    {config.RateLimitPolicy{Routes: []string{"POST /auth/login"}, Key: "session", Limit: 1, Period: time.Minute}, `unknown key "session"`},
+		"zero limit":     {config.RateLimitPolicy{Routes: []string{"POST /auth/login"}, Key: RateLimitByIP, Period: time.Minute}, "must be positive"},
+		"route":          {config.RateLimitPolicy{Routes: []string{"/auth/login"}, Key: RateLimitByIP, Limit: 1, Period: time.Minute}, "METHOD /path"},
+		"invalid method": {config.RateLimitPolicy{Routes: []string{"FETCH /auth/login"}, Key: RateLimitByIP, Limit: 1, Period: time.Minute}, "METHOD /path"},
+	}
+	for name, tt := range tests {
+		cfg := config.RateLimitConfig{Enabled: true, Policies: map[string]config.RateLimitPolicy{"p": tt.policy}}
+		if _, err := NewRateLimitService(cfg, memory.NewRateLimitStore()); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: NewRateLimitService = %v, want an error containing %q", name, err, tt.want)
+		}
+	}
+}
+
+func TestRateLimitPolicies(t *testing.T) {
+	s, err := NewRateLimitService(config.RateLimitConfig{Enabled: true, Policies: map[string]config.RateLimitPolicy{
+		"login":   {Routes: []string{"post /auth/login"}, Key: RateLimitByIP, Limit: 10, Period: time.Minute, Burst: 20},
+		"connect": {Routes: []string{"POST /auth/login", "POST /api/unipile/linkedin/cookie"}, Key: RateLimitByUser, Limit: 2, Period: time.Hour},
+	}}, memory.NewRateLimitStore())
+	if err != nil {
+		t.Fatalf("NewRateLimitService: %v", err)
+	}
+
+	// 依名稱排序，method 不分大小寫
+	policies := s.Policies("POST", "/auth/login")
+	if len(policies) != 2 || policies[0].Name != "connect" || policies[1].Name != "login" {
+		t.Fatalf("policies = %+v, want connect then login", policies)
+	}
+	if b := policies[1].Bucket; b.Capacity != 20 || b.Rate != 10.0/60 {
+		t.Errorf("login bucket = %+v, want capacity 20 refilling 10 per minute", b)
+	}
+	if len(s.Policies("GET", "/auth/login")) != 0 {
+		t.Error("GET /auth/login has policies")
+	}
+
+	// 未啟用時不限制
+	s, _ = NewRateLimitService(config.RateLimitConfig{Policies: map[string]config.RateLimitPolicy{
+		"login": {Routes: []string{"POST /auth/login"}, Key: RateLimitByIP, Limit: 1, Period: time.Minute},
+	}}, memory.NewRateLimitStore())
+	if len(s.Policies("POST", "/auth/login")) != 0 {
+		t.Error("disabled rate limit has policies")
+	}
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/jobs"
+	"chatsheet/internal/model"
+	"chatsheet/internal/unipile"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// reconnectJobType 以保存的 Cookie 重新連結帳號的背景工作類型
+const reconnectJobType = "account.reconnect"
+
+// reconnectPayload 重新連結帳號的背景工作內容
+type reconnectPayload struct {
+	AccountID string `json:"account_id"`
+}
+
+// ReconnectService 在帳號需要重新登入 (CREDENTIALS) 時，以加密保存的 Cookie 重新連結帳號
+// 沒有保存的憑證 (以帳號密碼連結) 或 LinkedIn 要求 checkpoint 時只記錄在日誌，由使用者重新連結
+type ReconnectService struct {
+	credSvc *CredentialService
+	client  *unipile.Client
+	queue   *jobs.Queue
+}
+
+func NewReconnectService(credSvc *CredentialService, client *unipile.Client, queue *jobs.Queue) *ReconnectService {
+	s := &ReconnectService{
+		credSvc: credSvc,
+		client:  client,
+		queue:   queue,
+	}
+	jobs.Handle(queue, reconnectJobType, s.reconnect)
+
+	return s
+}
+
+// AccountStatusChanged 在帳號變成需要重新登入時排入重新連結的工作，以 SyncService.OnAccountStatusChanged 註冊
+func (s *ReconnectService) AccountStatusChanged(ctx context.Context, acct *model.UnipileAccount, previous string) {
+	if acct.Status != unipile.AccountStatusCredentials {
+		return
+	}
+
+	_, err := s.queue.Enqueue(ctx, reconnectJobType, reconnectPayload{AccountID: acct.AccountID}, jobs.EnqueueOptions{
+		UniqueKey: reconnectJobType + ":" + acct.AccountID,
+	})
+	if err != nil && !errors.Is(err, apperr.ErrConflict) {
+		slog.ErrorContext(ctx, "Failed to enqueue account reconnect", "account_id", acct.AccountID, "err", err)
+	}
+}
+
+// reconnect 是重新連結帳號的背景工作，Unipile 無法連線時回傳錯誤重試
+func (s *ReconnectService) reconnect(ctx context.Context, p reconnectPayload) error {
+	secret, err := s.credSvc.Get(ctx, p.AccountID)
+	if errors.Is(err, apperr.ErrNotFound) {
+		slog.InfoContext(ctx, "No saved credential, account must be reconnected by the user", "account_id", p.AccountID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	resp, status, err := s.client.ReconnectAccount(ctx, p.AccountID, secret.AccessToken, secret.UserAgent)
+	if err != nil {
+		// Cookie 失效等 4xx 錯誤重試也不會成功
+		var apiErr *unipile.APIError
+		if errors.As(err, &apiErr) && apiErr.Status >= 400 && apiErr.Status < 500 && apiErr.Status != http.StatusTooManyRequests {
+			return jobs.Permanent(err)
+		}
+		return err
+	}
+	if status == http.StatusAccepted || resp.Object == "Checkpoint" {
+		slog.WarnContext(ctx, "Reconnect requires a checkpoint, account must be reconnected by the user", "account_id", p.AccountID)
+		return nil
+	}
+
+	slog.InfoContext(ctx, "Reconnected account with saved credential", "account_id", p.AccountID)
+	return nil
+}
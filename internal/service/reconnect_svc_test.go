@@ -0,0 +1,99 @@
+package service
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/envelope"
+	"chatsheet/internal/jobs"
+	"chatsheet/internal/model"
+	"chatsheet/internal/repository/memory"
+	"chatsheet/internal/unipile"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestCredentialService(t *testing.T) *CredentialService {
+	t.Helper()
+
+	key, err := envelope.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kms, err := envelope.NewLocalKMS(map[int][]byte{1: key}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewCredentialService(memory.NewCredentialRepository(), envelope.New(kms))
+}
+
+func TestReconnectUsesSavedCookie(t *testing.T) {
+	ctx := context.Background()
+
+	var gotPath string
+	var gotBody map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.Method + " " + r.URL.Path
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"object":"AccountReconnected","account_id":"acc-1"}`))
+	}))
+	defer srv.Close()
+
+	credSvc := newTestCredentialService(t)
+	secret := model.ConnectionSecret{AccessToken: "li_at-value", UserAgent: "test-agent"}
+	if err := credSvc.Save(ctx, "owner@example.com", "acc-1", secret); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	client := unipile.NewClient(config.UnipileConfig{APIBaseURL: srv.URL})
+	s := NewReconnectService(credSvc, client, jobs.NewQueue(config.JobsConfig{}, memory.NewJobRepository()))
+
+	if err := s.reconnect(ctx, reconnectPayload{AccountID: "acc-1"}); err != nil {
+		t.Fatalf("reconnect: %v", err)
+	}
+	if gotPath != "POST /api/v1/accounts/acc-1" {
+		t.Errorf("request = %q, want POST /api/v1/accounts/acc-1", gotPath)
+	}
+	if gotBody["access_token"] != "li_at-value" || gotBody["user_agent"] != "test-agent" || gotBody["provider"] != "LINKEDIN" {
+		t.Errorf("request body = %v", gotBody)
+	}
+}
+
+func TestReconnectWithoutCredential(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	client := unipile.NewClient(config.UnipileConfig{APIBaseURL: srv.URL})
+	s := NewReconnectService(newTestCredentialService(t), client, jobs.NewQueue(config.JobsConfig{}, memory.NewJobRepository()))
+
+	// 以帳號密碼連結的帳號沒有保存的憑證，只能由使用者重新連結
+	if err := s.reconnect(context.Background(), reconnectPayload{AccountID: "acc-2"}); err != nil {
+		t.Fatalf("reconnect without credential: %v", err)
+	}
+}
+
+func TestReconnectRejectedCookieIsPermanent(t *testing.T) {
+	ctx := context.Background()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"status":401,"type":"errors/invalid_credentials","title":"Invalid credentials"}`))
+	}))
+	defer srv.Close()
+
+	credSvc := newTestCredentialService(t)
+	if err := credSvc.Save(ctx, "owner@example.com", "acc-3", model.ConnectionSecret{AccessToken: "expired"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	client := unipile.NewClient(config.UnipileConfig{APIBaseURL: srv.URL})
+	s := NewReconnectService(credSvc, client, jobs.NewQueue(config.JobsConfig{}, memory.NewJobRepository()))
+
+	// 失效的 Cookie 重試也不會成功
+	if err := s.reconnect(ctx, reconnectPayload{AccountID: "acc-3"}); !jobs.IsPermanent(err) {
+		t.Fatalf("reconnect with rejected cookie err = %v, want permanent", err)
+	}
+}
@@ -0,0 +1,398 @@
+package service
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// localTimeLayout 以對方時區指定送出時間的格式
+const localTimeLayout = "2006-01-02T15:04"
+
+// maxScheduleAhead 最多可以排程到多久之後
+const maxScheduleAhead = 365 * 24 * time.Hour
+
+// ScheduleInput 排程訊息的送出時間與內容
+// 送出時間以 SendAt 指定，或以 LocalTime 與 Timezone 指定對方時區的當地時間
+type ScheduleInput struct {
+	Text      string
+	SendAt    time.Time
+	LocalTime string // 2006-01-02T15:04
+	Timezone  string // IANA 時區，例如 Europe/Berlin
+}
+
+// ScheduledMessageInput 建立排程訊息的內容，ChatID 與 ProviderID 二擇一
+type ScheduledMessageInput struct {
+	ScheduleInput
+	AccountID  string // 以 ProviderID 送出時必填；以 ChatID 送出時使用對話所屬的帳號
+	ChatID     string
+	ProviderID string
+}
+
+// ScheduledMessageService 管理排程訊息，並由 worker 在到期時透過連結帳號送出
+type ScheduledMessageService struct {
+	cfg           config.ScheduledConfig
+	unipileSvc    *UnipileService
+	inboxSvc      *InboxService
+	scheduledRepo itfc.ScheduledMessageRepository
+}
+
+func NewScheduledMessageService(cfg config.ScheduledConfig, unipileSvc *UnipileService, inboxSvc *InboxService, scheduledRepo itfc.ScheduledMessageRepository) *ScheduledMessageService {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 30 * time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 20
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 5 * time.Minute
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = time.Minute
+	}
+	if cfg.MaxRetryDelay <= 0 {
+		cfg.MaxRetryDelay = time.Hour
+	}
+
+	return &ScheduledMessageService{
+		cfg:           cfg,
+		unipileSvc:    unipileSvc,
+		inboxSvc:      inboxSvc,
+		scheduledRepo: scheduledRepo,
+	}
+}
+
+// Create 建立排程訊息，帳號 (或對話所屬的帳號) 必須屬於該使用者
+func (s *ScheduledMessageService) Create(ctx context.Context, email string, in ScheduledMessageInput) (*model.ScheduledMessage, error) {
+	sendAt, err := validateSchedule(in.ScheduleInput)
+	if err != nil {
+		return nil, err
+	}
+
+	in.ChatID = strings.TrimSpace(in.ChatID)
+	in.ProviderID = strings.TrimSpace(in.ProviderID)
+	if (in.ChatID == "") == (in.ProviderID == "") {
+		return nil, apperr.Validation("Exactly one of chat_id and provider_id is required")
+	}
+
+	accountID := in.AccountID
+	if in.ChatID != "" {
+		chatAccountID, err := s.inboxSvc.ChatAccount(ctx, email, in.ChatID)
+		if err != nil {
+			return nil, err
+		}
+		if accountID != "" && accountID != chatAccountID {
+			return nil, apperr.Validation("chat_id does not belong to account_id")
+		}
+		accountID = chatAccountID
+	} else {
+		if accountID == "" {
+			return nil, apperr.Validation("account_id is required")
+		}
+		if _, err := s.unipileSvc.Get(ctx, email, accountID); err != nil {
+			return nil, err
+		}
+	}
+
+	m := &model.ScheduledMessage{
+		UserEmail:     email,
+		AccountID:     accountID,
+		ChatID:        in.ChatID,
+		ProviderID:    in.ProviderID,
+		Text:          in.Text,
+		SendAt:        sendAt,
+		Timezone:      in.Timezone,
+		Status:        model.ScheduledPending,
+		NextAttemptAt: &sendAt,
+	}
+	if err := s.scheduledRepo.Create(ctx, m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// List 依送出時間由晚到早列出使用者的排程訊息，status 為空字串時列出所有狀態
+func (s *ScheduledMessageService) List(ctx context.Context, email, status, cursor string, limit int) ([]model.ScheduledMessage, string, error) {
+	if status != "" && !slices.Contains([]string{model.ScheduledPending, model.ScheduledSending, model.ScheduledSent, model.ScheduledFailed, model.ScheduledCanceled}, status) {
+		return nil, "", apperr.Validation("status must be scheduled, sending, sent, failed or canceled")
+	}
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return nil, "", apperr.Wrap(apperr.ErrValidation, "Invalid cursor", err)
+	}
+
+	limit = pagination.Limit(limit)
+	messages, err := s.scheduledRepo.ListByUser(ctx, email, status, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(messages) == limit {
+		last := messages[len(messages)-1]
+		next = pagination.Cursor{Time: last.SendAt, ID: last.ID.String()}.Encode()
+	}
+
+	return messages, next, nil
+}
+
+// Get 取得排程訊息，不存在或不屬於該使用者時回傳 apperr.ErrNotFound
+func (s *ScheduledMessageService) Get(ctx context.Context, email, messageID string) (*model.ScheduledMessage, error) {
+	id, err := uuid.Parse(messageID)
+	if err != nil {
+		return nil, apperr.NotFound("Scheduled message not found")
+	}
+
+	m, err := s.scheduledRepo.Get(ctx, id)
+	if errors.Is(err, apperr.ErrNotFound) || (err == nil && m.UserEmail != email) {
+		return nil, apperr.NotFound("Scheduled message not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Update 修改等待送出或已失敗的訊息的內容與送出時間，已失敗的訊息會重新排程
+func (s *ScheduledMessageService) Update(ctx context.Context, email, messageID string, in ScheduleInput) (*model.ScheduledMessage, error) {
+	sendAt, err := validateSchedule(in)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := s.Get(ctx, email, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if m.Status != model.ScheduledPending && m.Status != model.ScheduledFailed {
+		return nil, scheduledConflict(m.Status)
+	}
+
+	fromStatus := m.Status
+	m.Text = in.Text
+	m.SendAt = sendAt
+	m.Timezone = in.Timezone
+	m.Status = model.ScheduledPending
+	m.NextAttemptAt = &sendAt
+	m.Attempts = 0
+	m.LastError = ""
+	if err := s.scheduledRepo.Update(ctx, m, fromStatus); err != nil {
+		if errors.Is(err, apperr.ErrConflict) {
+			// worker 剛好取出了訊息
+			return nil, scheduledConflict(model.ScheduledSending)
+		}
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Cancel 取消等待送出或已失敗的訊息，正在送出或已送出的訊息回傳 apperr.ErrConflict
+func (s *ScheduledMessageService) Cancel(ctx context.Context, email, messageID string) (*model.ScheduledMessage, error) {
+	m, err := s.Get(ctx, email, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if m.Status != model.ScheduledPending && m.Status != model.ScheduledFailed {
+		return nil, scheduledConflict(m.Status)
+	}
+
+	fromStatus := m.Status
+	m.Status = model.ScheduledCanceled
+	m.NextAttemptAt = nil
+	if err := s.scheduledRepo.Update(ctx, m, fromStatus); err != nil {
+		if errors.Is(err, apperr.ErrConflict) {
+			return nil, scheduledConflict(model.ScheduledSending)
+		}
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func scheduledConflict(status string) error {
+	switch status {
+	case model.ScheduledSending:
+		return apperr.Conflict("Message is being sent")
+	case model.ScheduledSent:
+		return apperr.Conflict("Message was already sent")
+	default:
+		return apperr.Conflict("Message was canceled")
+	}
+}
+
+// validateSchedule 檢查內容並回傳 UTC 的送出時間
+func validateSchedule(in ScheduleInput) (time.Time, error) {
+	if strings.TrimSpace(in.Text) == "" {
+		return time.Time{}, apperr.Validation("text is required")
+	}
+	if utf8.RuneCountInString(in.Text) > maxMessageLength {
+		return time.Time{}, apperr.Validation(fmt.Sprintf("text must be at most %d characters", maxMessageLength))
+	}
+
+	loc := time.UTC
+	if in.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(in.Timezone); err != nil {
+			return time.Time{}, apperr.Validation("timezone must be an IANA time zone, e.g. Europe/Berlin")
+		}
+	}
+
+	sendAt := in.SendAt
+	switch {
+	case in.LocalTime != "" && !sendAt.IsZero():
+		return time.Time{}, apperr.Validation("Only one of send_at and local_time is allowed")
+	case in.LocalTime != "":
+		if in.Timezone == "" {
+			return time.Time{}, apperr.Validation("timezone is required with local_time")
+		}
+		local, err := time.ParseInLocation(localTimeLayout, in.LocalTime, loc)
+		if err != nil {
+			return time.Time{}, apperr.Validation("local_time must be YYYY-MM-DDTHH:MM")
+		}
+		sendAt = local
+	case sendAt.IsZero():
+		return time.Time{}, apperr.Validation("send_at or local_time is required")
+	}
+
+	sendAt = sendAt.UTC().Truncate(time.Microsecond)
+	now := time.Now()
+	if sendAt.Before(now) {
+		return time.Time{}, apperr.Validation("Send time must be in the future")
+	}
+	if sendAt.After(now.Add(maxScheduleAhead)) {
+		return time.Time{}, apperr.Validation("Send time must be within a year")
+	}
+
+	return sendAt, nil
+}
+
+// Run 定期送出到期的訊息，直到 ctx 結束
+// 多個程序可以同時執行，ClaimDue 保證每則訊息只會被其中一個送出
+func (s *ScheduledMessageService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.RunDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue 送出所有到期的訊息，直到沒有到期的訊息
+func (s *ScheduledMessageService) RunDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		messages, err := s.scheduledRepo.ClaimDue(ctx, time.Now().UTC(), s.cfg.Lease, s.cfg.BatchSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to claim due scheduled messages", "err", err)
+			return
+		}
+
+		for _, m := range messages {
+			if err := s.send(ctx, m); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Failed to send scheduled message", "scheduled_message_id", m.ID, "err", err)
+			}
+		}
+
+		if len(messages) < s.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+// send 送出訊息並記錄結果，失敗時依次數延後重試
+// 租約結束前沒有記錄結果 (例如程序中斷) 時，訊息會再被取出送出
+func (s *ScheduledMessageService) send(ctx context.Context, m model.ScheduledMessage) error {
+	sendErr := s.deliver(ctx, &m)
+	if sendErr != nil && ctx.Err() != nil {
+		// 正在關機，租約結束後再送出
+		return nil
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	if retryAt, ok := quotaRetryAt(sendErr); ok {
+		// 帳號配額用完或不在工作時間，延到可以送出時，不算失敗
+		m.Status = model.ScheduledPending
+		m.NextAttemptAt = &retryAt
+		m.LastError = sendErr.Error()
+	} else if sendErr != nil {
+		m.Attempts++
+		m.LastError = sendErr.Error()
+		if m.Attempts >= s.cfg.MaxAttempts || permanentStepError(sendErr) {
+			m.Status = model.ScheduledFailed
+			m.NextAttemptAt = nil
+		} else {
+			m.Status = model.ScheduledPending
+			retryAt := now.Add(s.retryDelay(m.Attempts))
+			m.NextAttemptAt = &retryAt
+		}
+	} else {
+		m.Status = model.ScheduledSent
+		m.NextAttemptAt = nil
+		m.LastError = ""
+		m.SentAt = &now
+	}
+
+	// 訊息已經送出，即使 ctx 已結束也要記錄，避免重複送出
+	// m.LeaseID 是取出時的租約，租約到期後被其他程序重新取出時不會覆寫它的結果
+	err := s.scheduledRepo.Update(context.WithoutCancel(ctx), &m, model.ScheduledSending)
+	if errors.Is(err, apperr.ErrConflict) {
+		// 租約已經到期，訊息被其他程序取出
+		slog.WarnContext(ctx, "Scheduled message changed while sending", "scheduled_message_id", m.ID)
+		return nil
+	}
+
+	return err
+}
+
+// retryDelay 第 attempts 次失敗後的重試間隔，每次加倍直到 MaxRetryDelay
+func (s *ScheduledMessageService) retryDelay(attempts int) time.Duration {
+	delay := s.cfg.RetryDelay
+	for i := 1; i < attempts && delay < s.cfg.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, s.cfg.MaxRetryDelay)
+}
+
+// deliver 以建立排程的使用者身分送出訊息，送出後記錄 chat id 與 message id
+func (s *ScheduledMessageService) deliver(ctx context.Context, m *model.ScheduledMessage) error {
+	out := OutgoingMessage{Text: m.Text}
+	if m.ChatID != "" {
+		sent, err := s.inboxSvc.SendMessage(ctx, m.UserEmail, m.ChatID, out)
+		if err != nil {
+			return err
+		}
+		m.MessageID = sent.UnipileID
+		return nil
+	}
+
+	started, err := s.inboxSvc.StartChat(ctx, m.UserEmail, m.AccountID, []string{m.ProviderID}, out)
+	if err != nil {
+		return err
+	}
+	m.ChatID = started.ChatID
+	m.MessageID = started.Message.UnipileID
+	return nil
+}
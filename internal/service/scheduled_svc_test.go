@@ -0,0 +1,133 @@
+package service
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/repository/memory"
+	"chatsheet/internal/unipile"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestScheduledService 建立屬於 owner@example.com 的帳號 acc-1，sent 記錄 Unipile 收到的訊息數
+// fail 為 true 時 Unipile 開始對話回傳 500
+func newTestScheduledService(t *testing.T, cfg config.ScheduledConfig, fail bool, sent *atomic.Int32) (*ScheduledMessageService, itfc.ScheduledMessageRepository) {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != unipile.ChatsEndpoint {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		sent.Add(1)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(unipile.ChatStarted{Object: "ChatStarted", ChatID: "chat-1", MessageID: "msg-1"})
+	}))
+	t.Cleanup(srv.Close)
+	client := unipile.NewClient(config.UnipileConfig{APIBaseURL: srv.URL})
+
+	unipileSvc := newTestUnipileService(t, "owner@example.com")
+	if _, err := unipileSvc.Create(context.Background(), "owner@example.com", "linkedin", "acc-1"); err != nil {
+		t.Fatalf("Create account: %v", err)
+	}
+	quotaSvc := NewQuotaService(config.QuotasConfig{}, unipileSvc, memory.NewQuotaRepository())
+	inboxSvc := NewInboxService(unipileSvc, client, memory.NewMessageRepository(), memory.NewChatRepository(), memory.NewChatAttendeeRepository(),
+		memory.NewSyncCheckpointRepository(), memory.NewContactRepository(), quotaSvc)
+
+	repo := memory.NewScheduledMessageRepository()
+	return NewScheduledMessageService(cfg, unipileSvc, inboxSvc, repo), repo
+}
+
+// mustCreateDue 建立已經到期、失敗過 attempts 次的排程訊息
+func mustCreateDue(t *testing.T, repo itfc.ScheduledMessageRepository, attempts int) *model.ScheduledMessage {
+	t.Helper()
+	past := time.Now().UTC().Add(-time.Minute)
+	m := &model.ScheduledMessage{
+		UserEmail: "owner@example.com", AccountID: "acc-1", ProviderID: "prov-1", Text: "hello",
+		SendAt: past, Status: model.ScheduledPending, NextAttemptAt: &past, Attempts: attempts,
+	}
+	if err := repo.Create(context.Background(), m); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return m
+}
+
+func TestScheduledRetryDelayIsCapped(t *testing.T) {
+	var sent atomic.Int32
+	s, repo := newTestScheduledService(t, config.ScheduledConfig{MaxAttempts: 100, RetryDelay: time.Minute, MaxRetryDelay: time.Hour}, true, &sent)
+
+	for _, d := range []struct {
+		attempts int
+		want     time.Duration
+	}{{1, time.Minute}, {3, 4 * time.Minute}, {7, time.Hour}, {70, time.Hour}} {
+		if got := s.retryDelay(d.attempts); got != d.want {
+			t.Errorf("retryDelay(%d) = %v, want %v", d.attempts, got, d.want)
+		}
+	}
+
+	// 失敗多次後 RetryDelay << attempts 會溢位，重試時間仍不能超過上限
+	ctx := context.Background()
+	m := mustCreateDue(t, repo, 69)
+	s.RunDue(ctx)
+
+	got, err := repo.Get(ctx, m.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Status != model.ScheduledPending || got.Attempts != 70 || got.LastError == "" {
+		t.Fatalf("message after failure = %+v, want pending with 70 attempts", got)
+	}
+	if wait := time.Until(*got.NextAttemptAt); wait <= 0 || wait > time.Hour {
+		t.Errorf("next attempt in %v, want within MaxRetryDelay", wait)
+	}
+}
+
+func TestScheduledSendWithExpiredLease(t *testing.T) {
+	ctx := context.Background()
+	var sent atomic.Int32
+	s, repo := newTestScheduledService(t, config.ScheduledConfig{Lease: time.Minute}, false, &sent)
+	m := mustCreateDue(t, repo, 0)
+
+	now := time.Now().UTC()
+	claimed, err := repo.ClaimDue(ctx, now, time.Minute, 10)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("ClaimDue = %v, %v", claimed, err)
+	}
+	// 租約到期，訊息被另一個程序重新取出
+	reclaimed, err := repo.ClaimDue(ctx, now.Add(2*time.Minute), time.Minute, 10)
+	if err != nil || len(reclaimed) != 1 {
+		t.Fatalf("ClaimDue after the lease = %v, %v", reclaimed, err)
+	}
+
+	// 原本的程序送出後無法記錄結果，訊息仍由重新取出的程序持有
+	if err := s.send(ctx, claimed[0]); err != nil {
+		t.Fatalf("send with expired lease: %v", err)
+	}
+	got, err := repo.Get(ctx, m.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Status != model.ScheduledSending || *got.LeaseID != *reclaimed[0].LeaseID {
+		t.Fatalf("message = %+v, want still sending under the new lease", got)
+	}
+
+	if err := s.send(ctx, reclaimed[0]); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	got, err = repo.Get(ctx, m.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Status != model.ScheduledSent || got.MessageID != "msg-1" || got.SentAt == nil {
+		t.Errorf("message = %+v, want sent", got)
+	}
+}
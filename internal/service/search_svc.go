@@ -0,0 +1,130 @@
+package service
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/pagination"
+	"chatsheet/internal/search"
+	"context"
+	"html"
+	"strings"
+	"time"
+)
+
+// SearchQuery 是搜尋訊息的條件
+type SearchQuery struct {
+	Query     string
+	AccountID string    // 只搜尋此帳號，空字串代表使用者所有的帳號
+	Since     time.Time // sent_at >= Since
+	Until     time.Time // sent_at < Until
+	Cursor    string
+	Limit     int
+}
+
+// SearchResult 是一筆符合的訊息
+type SearchResult struct {
+	MessageID  string    `json:"message_id"` // Unipile message id
+	AccountID  string    `json:"account_id"`
+	ChatID     string    `json:"chat_id"`
+	SenderID   string    `json:"sender_id"`
+	SenderName string    `json:"sender_name"`
+	IsSender   bool      `json:"is_sender"`
+	Text       string    `json:"text"`
+	SentAt     time.Time `json:"sent_at"`
+	Rank       float64   `json:"rank"`
+	// 已經過 HTML escape 的內文片段，符合的字詞以 <mark></mark> 標記
+	Highlight string `json:"highlight"`
+}
+
+// SearchService 全文搜尋已同步到本地的訊息
+type SearchService struct {
+	unipileSvc *UnipileService
+	searchRepo itfc.SearchRepository
+}
+
+func NewSearchService(unipileSvc *UnipileService, searchRepo itfc.SearchRepository) *SearchService {
+	return &SearchService{
+		unipileSvc: unipileSvc,
+		searchRepo: searchRepo,
+	}
+}
+
+// Search 在使用者的帳號中搜尋訊息內文與寄件者名稱，依符合程度排序
+// 回傳的 next_cursor 為空字串代表沒有下一頁
+func (s *SearchService) Search(ctx context.Context, email string, q SearchQuery) ([]SearchResult, string, error) {
+	q.Query = strings.TrimSpace(q.Query)
+	if q.Query == "" {
+		return nil, "", apperr.Validation("q is required")
+	}
+	if !q.Since.IsZero() && !q.Until.IsZero() && !q.Since.Before(q.Until) {
+		return nil, "", apperr.Validation("from must be before to")
+	}
+
+	after, err := pagination.DecodeRanked(q.Cursor)
+	if err != nil {
+		return nil, "", apperr.Wrap(apperr.ErrValidation, "Invalid cursor", err)
+	}
+
+	var accountIDs []string
+	if q.AccountID != "" {
+		if _, err := s.unipileSvc.Get(ctx, email, q.AccountID); err != nil {
+			return nil, "", err
+		}
+		accountIDs = []string{q.AccountID}
+	} else {
+		accounts, err := s.unipileSvc.ListByEmail(ctx, email)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, a := range accounts {
+			accountIDs = append(accountIDs, a.AccountID)
+		}
+	}
+	if len(accountIDs) == 0 {
+		return []SearchResult{}, "", nil
+	}
+
+	limit := pagination.Limit(q.Limit)
+	hits, err := s.searchRepo.Search(ctx, itfc.SearchFilter{
+		Query:      q.Query,
+		AccountIDs: accountIDs,
+		Since:      q.Since,
+		Until:      q.Until,
+		After:      after,
+		Limit:      limit,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	results := make([]SearchResult, len(hits))
+	for i, h := range hits {
+		results[i] = SearchResult{
+			MessageID:  h.UnipileID,
+			AccountID:  h.AccountID,
+			ChatID:     h.ChatID,
+			SenderID:   h.SenderID,
+			SenderName: h.SenderName,
+			IsSender:   h.IsSender,
+			Text:       h.Text,
+			SentAt:     h.SentAt,
+			Rank:       h.Rank,
+			Highlight:  highlightHTML(h.Highlight),
+		}
+	}
+
+	next := ""
+	if len(hits) == limit {
+		next = hits[len(hits)-1].Cursor().Encode()
+	}
+
+	return results, next, nil
+}
+
+// highlightHTML 先 escape 內文，再將標記字元轉為 <mark>，避免訊息內容被當作 HTML
+func highlightHTML(s string) string {
+	return strings.NewReplacer(
+		search.HighlightStart, "<mark>",
+		search.HighlightStop, "</mark>",
+	).Replace(html.EscapeString(s))
+}
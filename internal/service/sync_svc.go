@@ -0,0 +1,628 @@
+package service
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/events"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/unipile"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// syncChatBatch 每次從本地取出等待回填訊息的對話數量
+const syncChatBatch = 50
+
+// SyncService 把連結帳號的對話、訊息與參與者同步到本地
+//
+// 每個帳號有一個 worker goroutine：第一次先以游標回填 (backfill) 所有對話與訊息，
+// 之後只做增量同步，由 webhook (Notify) 與每 cfg.Interval 一次的補同步觸發。
+// 進度保存在 SyncCheckpoint 與 Chat 的回填欄位，重新啟動時會從中斷的地方繼續。
+type SyncService struct {
+	cfg            config.SyncConfig
+	client         *unipile.Client
+	unipileRepo    itfc.UnipileRepository
+	chatRepo       itfc.ChatRepository
+	messageRepo    itfc.MessageRepository
+	attendeeRepo   itfc.ChatAttendeeRepository
+	checkpointRepo itfc.SyncCheckpointRepository
+
+	// 收到對方訊息時呼叫，由 OnInboundMessage 在 Run 之前註冊
+	inboundHandlers []func(ctx context.Context, msg *model.Message)
+	// 帳號在 Unipile 上的狀態改變時呼叫，由 OnAccountStatusChanged 在啟動時註冊
+	statusHandlers []func(ctx context.Context, acct *model.UnipileAccount, previous string)
+
+	mu      sync.Mutex
+	ctx     context.Context // Run 的 context，nil 代表 worker 尚未啟動
+	workers map[string]*syncWorker
+	wg      sync.WaitGroup
+}
+
+// syncWorker 是單一帳號的同步 goroutine
+type syncWorker struct {
+	nudge  chan struct{} // 容量為 1，多次觸發會合併成一次同步
+	cancel context.CancelFunc
+}
+
+func NewSyncService(
+	cfg config.SyncConfig,
+	client *unipile.Client,
+	unipileRepo itfc.UnipileRepository,
+	chatRepo itfc.ChatRepository,
+	messageRepo itfc.MessageRepository,
+	attendeeRepo itfc.ChatAttendeeRepository,
+	checkpointRepo itfc.SyncCheckpointRepository,
+) *SyncService {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Minute
+	}
+	if cfg.PageSize <= 0 {
+		cfg.PageSize = 100
+	}
+
+	return &SyncService{
+		cfg:            cfg,
+		client:         client,
+		unipileRepo:    unipileRepo,
+		chatRepo:       chatRepo,
+		messageRepo:    messageRepo,
+		attendeeRepo:   attendeeRepo,
+		checkpointRepo: checkpointRepo,
+		workers:        map[string]*syncWorker{},
+	}
+}
+
+// OnInboundMessage 註冊收到對方訊息時的處理函式，同步與 webhook 寫入的訊息都會觸發
+// 同一則訊息可能因為重疊的補同步而觸發多次，處理函式必須可以重複執行；必須在 Run 之前呼叫
+func (s *SyncService) OnInboundMessage(fn func(ctx context.Context, msg *model.Message)) {
+	s.inboundHandlers = append(s.inboundHandlers, fn)
+}
+
+// OnAccountStatusChanged 註冊帳號狀態改變時的處理函式，由 RefreshAccountStatuses 觸發
+func (s *SyncService) OnAccountStatusChanged(fn func(ctx context.Context, acct *model.UnipileAccount, previous string)) {
+	s.statusHandlers = append(s.statusHandlers, fn)
+}
+
+// Run 為每個連結帳號啟動 worker，並定期觸發補同步
+// 阻塞直到 ctx 結束，且所有 worker 都已停止
+func (s *SyncService) Run(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.catchUp(ctx)
+
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Notify 要求帳號盡快同步，例如收到 webhook 時
+// 帳號的 worker 尚未啟動時會啟動它；Run 尚未執行時不做任何事
+func (s *SyncService) Notify(accountID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx == nil || s.ctx.Err() != nil {
+		return
+	}
+
+	w, ok := s.workers[accountID]
+	if !ok {
+		w = s.startWorker(accountID)
+	}
+
+	select {
+	case w.nudge <- struct{}{}:
+	default: // 已經有一次待執行的同步
+	}
+}
+
+// HandleAccountConnected 訂閱 EventBus 上的 account.connected，立即開始同步新連結的帳號，
+// 而不是等到下一次補同步；事件的 key 為 account_id
+func (s *SyncService) HandleAccountConnected(ctx context.Context, ev events.Event) error {
+	s.Notify(ev.Key)
+	return nil
+}
+
+// catchUp 觸發所有帳號的同步，並停止已移除帳號的 worker
+func (s *SyncService) catchUp(ctx context.Context) {
+	accts, err := s.unipileRepo.ListAll(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list accounts for sync", "err", err)
+		return
+	}
+
+	linked := make(map[string]bool, len(accts))
+	for _, a := range accts {
+		linked[a.AccountID] = true
+		s.Notify(a.AccountID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for accountID, w := range s.workers {
+		if !linked[accountID] {
+			w.cancel()
+			delete(s.workers, accountID)
+		}
+	}
+}
+
+// startWorker 啟動帳號的 worker，呼叫者必須持有 s.mu
+func (s *SyncService) startWorker(accountID string) *syncWorker {
+	ctx, cancel := context.WithCancel(s.ctx)
+	w := &syncWorker{nudge: make(chan struct{}, 1), cancel: cancel}
+	s.workers[accountID] = w
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.nudge:
+			}
+
+			if err := s.SyncAccount(ctx, accountID); err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "Failed to sync account", "account_id", accountID, "err", err)
+			}
+		}
+	}()
+
+	return w
+}
+
+// SyncAccount 同步一個帳號：先完成回填，再做增量同步
+// 結果 (包含錯誤訊息) 會寫回帳號的 SyncCheckpoint
+func (s *SyncService) SyncAccount(ctx context.Context, accountID string) error {
+	cp, err := s.checkpointRepo.Get(ctx, accountID)
+	if errors.Is(err, apperr.ErrNotFound) {
+		cp = &model.SyncCheckpoint{AccountID: accountID}
+	} else if err != nil {
+		return err
+	}
+
+	syncErr := s.syncAccount(ctx, cp)
+
+	cp.LastError = ""
+	if syncErr != nil {
+		cp.LastError = syncErr.Error()
+	}
+	// 即使 ctx 已結束 (例如正在關機)，也要保存已完成的進度
+	if err := s.checkpointRepo.Save(context.WithoutCancel(ctx), cp); err != nil {
+		return err
+	}
+
+	return syncErr
+}
+
+// RefreshAccountStatuses 向 Unipile 查詢所有連結帳號的狀態並保存，由定期工作執行
+// Unipile 上已經不存在的帳號標記為 DELETED；單一帳號查詢失敗不影響其他帳號
+func (s *SyncService) RefreshAccountStatuses(ctx context.Context) (string, error) {
+	accts, err := s.unipileRepo.ListAll(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var changed, failed int
+	for _, a := range accts {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		status := unipile.AccountStatusDeleted
+		remote, err := s.client.GetAccount(ctx, a.AccountID)
+		switch {
+		case err == nil:
+			status = remote.Status()
+		case !errors.Is(err, apperr.ErrNotFound):
+			slog.WarnContext(ctx, "Failed to get account status", "account_id", a.AccountID, "err", err)
+			failed++
+			continue
+		}
+
+		checkedAt := time.Now()
+		if err := s.unipileRepo.UpdateStatus(ctx, a.AccountID, status, checkedAt); err != nil {
+			if errors.Is(err, apperr.ErrNotFound) {
+				continue // 查詢期間被移除
+			}
+			return "", err
+		}
+		if status != a.Status {
+			slog.InfoContext(ctx, "Account status changed", "account_id", a.AccountID, "from", a.Status, "to", status)
+			changed++
+
+			previous := a.Status
+			a.Status = status
+			a.StatusCheckedAt = &checkedAt
+			for _, fn := range s.statusHandlers {
+				fn(ctx, &a, previous)
+			}
+		}
+	}
+
+	return fmt.Sprintf("checked %d accounts, %d changed, %d failed", len(accts)-failed, changed, failed), nil
+}
+
+func (s *SyncService) syncAccount(ctx context.Context, cp *model.SyncCheckpoint) error {
+	// 第一次同步：以回填開始的時間作為增量同步的起點，回填期間的新訊息會在增量同步時補上
+	if cp.LastSyncedAt == nil {
+		now := time.Now().UTC()
+		cp.LastSyncedAt = &now
+		if err := s.checkpointRepo.Save(ctx, cp); err != nil {
+			return err
+		}
+	}
+
+	// 1. 回填對話列表，每一頁都保存游標
+	for !cp.ChatsBackfilled {
+		list, err := s.client.ListChats(ctx, cp.AccountID, unipile.ListOptions{Cursor: cp.ChatsCursor, Limit: s.cfg.PageSize})
+		if err != nil {
+			return err
+		}
+		for _, c := range list.Items {
+			if err := s.saveChat(ctx, c); err != nil {
+				return err
+			}
+		}
+
+		cp.ChatsCursor = list.Cursor
+		cp.ChatsBackfilled = list.Cursor == ""
+		if err := s.checkpointRepo.Save(ctx, cp); err != nil {
+			return err
+		}
+	}
+
+	// 2. 增量同步：上次同步之後有變動的對話與其新訊息
+	started := time.Now().UTC()
+	since := cp.LastSyncedAt.Add(-s.cfg.Overlap)
+	opts := unipile.ListOptions{Limit: s.cfg.PageSize, After: since}
+	for {
+		list, err := s.client.ListChats(ctx, cp.AccountID, opts)
+		if err != nil {
+			return err
+		}
+		for _, c := range list.Items {
+			if err := s.saveChat(ctx, c); err != nil {
+				return err
+			}
+			if err := s.syncNewMessages(ctx, c.ID, since); err != nil {
+				return err
+			}
+		}
+		if list.Cursor == "" {
+			break
+		}
+		opts.Cursor = list.Cursor
+	}
+	cp.LastSyncedAt = &started
+
+	// 3. 回填訊息，每個對話各自保存游標
+	for {
+		chats, err := s.chatRepo.ListNotBackfilled(ctx, cp.AccountID, syncChatBatch)
+		if err != nil {
+			return err
+		}
+		if len(chats) == 0 {
+			return nil
+		}
+		for _, chat := range chats {
+			if err := s.backfillMessages(ctx, chat); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// saveChat 保存對話與其參與者
+func (s *SyncService) saveChat(ctx context.Context, c unipile.Chat) error {
+	if err := s.chatRepo.Upsert(ctx, chatFromUnipile(c)); err != nil {
+		return err
+	}
+
+	attendees, err := s.client.ListChatAttendees(ctx, c.ID)
+	if err != nil {
+		return err
+	}
+	for _, a := range attendees {
+		if err := s.attendeeRepo.Upsert(ctx, attendeeFromUnipile(c.AccountID, c.ID, a)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncNewMessages 保存對話中 since 之後的訊息，訊息尚未回填的對話由回填處理
+func (s *SyncService) syncNewMessages(ctx context.Context, chatID string, since time.Time) error {
+	chat, err := s.chatRepo.GetByUnipileID(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	if !chat.MessagesBackfilled {
+		return nil
+	}
+
+	names, err := s.senderNames(ctx, chatID)
+	if err != nil {
+		return err
+	}
+
+	opts := unipile.ListOptions{Limit: s.cfg.PageSize, After: since}
+	for {
+		list, err := s.client.ListMessages(ctx, chatID, opts)
+		if err != nil {
+			return err
+		}
+		for _, m := range list.Items {
+			if err := s.saveMessage(ctx, messageFromUnipile(chat.AccountID, m, names)); err != nil {
+				return err
+			}
+		}
+		if list.Cursor == "" {
+			return nil
+		}
+		opts.Cursor = list.Cursor
+	}
+}
+
+// backfillMessages 從對話保存的游標繼續回填訊息，直到沒有下一頁
+func (s *SyncService) backfillMessages(ctx context.Context, chat model.Chat) error {
+	names, err := s.senderNames(ctx, chat.UnipileID)
+	if err != nil {
+		return err
+	}
+
+	cursor := chat.MessagesCursor
+	for {
+		list, err := s.client.ListMessages(ctx, chat.UnipileID, unipile.ListOptions{Cursor: cursor, Limit: s.cfg.PageSize})
+		if errors.Is(err, apperr.ErrNotFound) {
+			// 對話已在 LinkedIn 上被刪除，不再回填
+			return s.chatRepo.UpdateMessagesCheckpoint(ctx, chat.UnipileID, "", true)
+		}
+		if err != nil {
+			return err
+		}
+		for _, m := range list.Items {
+			if err := s.saveMessage(ctx, messageFromUnipile(chat.AccountID, m, names)); err != nil {
+				return err
+			}
+		}
+
+		cursor = list.Cursor
+		if err := s.chatRepo.UpdateMessagesCheckpoint(ctx, chat.UnipileID, cursor, cursor == ""); err != nil {
+			return err
+		}
+		if cursor == "" {
+			return nil
+		}
+	}
+}
+
+// saveMessage 保存訊息，對方送出的訊息會通知 OnInboundMessage 註冊的處理函式
+func (s *SyncService) saveMessage(ctx context.Context, msg *model.Message) error {
+	if err := s.messageRepo.Upsert(ctx, msg); err != nil {
+		return err
+	}
+
+	if !msg.IsSender {
+		for _, fn := range s.inboundHandlers {
+			fn(ctx, msg)
+		}
+	}
+	return nil
+}
+
+// senderNames 回傳對話參與者 provider id → 名稱，用於保存訊息的寄件者名稱
+func (s *SyncService) senderNames(ctx context.Context, chatID string) (map[string]string, error) {
+	attendees, err := s.attendeeRepo.ListByChats(ctx, []string{chatID})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string, len(attendees))
+	for _, a := range attendees {
+		names[a.ProviderID] = a.Name
+	}
+	return names, nil
+}
+
+// HandleMessageEvent 處理 Unipile 的訊息 webhook
+// 訊息會立即寫入本地，對話的其他變動 (未讀數、新對話) 交給帳號的 worker 補同步
+func (s *SyncService) HandleMessageEvent(ctx context.Context, ev *unipile.MessageEvent) error {
+	if ev.AccountID == "" {
+		return apperr.Validation("account_id is required")
+	}
+
+	if _, err := s.unipileRepo.GetByAccountID(ctx, ev.AccountID); err != nil {
+		if errors.Is(err, apperr.ErrNotFound) {
+			// 不是由 Chatsheet 使用者連結的帳號 (例如已移除)，忽略
+			slog.InfoContext(ctx, "Ignore webhook for unknown account", "account_id", ev.AccountID, "event", ev.Event)
+			return nil
+		}
+		return err
+	}
+
+	switch ev.Event {
+	case unipile.EventMessageReceived, unipile.EventMessageEdited:
+		if ev.ChatID == "" || ev.MessageID == "" {
+			return apperr.Validation("chat_id and message_id are required")
+		}
+		if err := s.saveMessageEvent(ctx, ev); err != nil {
+			return err
+		}
+	}
+
+	s.Notify(ev.AccountID)
+	return nil
+}
+
+func (s *SyncService) saveMessageEvent(ctx context.Context, ev *unipile.MessageEvent) error {
+	names := make(model.StringList, 0, len(ev.Attachments))
+	for _, a := range ev.Attachments {
+		names = append(names, attachmentName(a))
+	}
+
+	sentAt := unipile.ParseTimestamp(ev.Timestamp)
+	if sentAt.IsZero() {
+		sentAt = time.Now()
+	}
+
+	err := s.saveMessage(ctx, &model.Message{
+		AccountID:       ev.AccountID,
+		ChatID:          ev.ChatID,
+		UnipileID:       ev.MessageID,
+		SenderID:        ev.Sender.AttendeeProviderID,
+		SenderName:      ev.Sender.AttendeeName,
+		Text:            ev.Message,
+		IsSender:        ev.IsSender(),
+		AttachmentNames: names,
+		SentAt:          sentAt.UTC().Truncate(time.Microsecond),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, a := range append(ev.Attendees, ev.Sender) {
+		if a.AttendeeProviderID == "" {
+			continue
+		}
+		err := s.attendeeRepo.Upsert(ctx, &model.ChatAttendee{
+			AccountID:  ev.AccountID,
+			ChatID:     ev.ChatID,
+			UnipileID:  a.AttendeeID,
+			ProviderID: a.AttendeeProviderID,
+			Name:       a.AttendeeName,
+			IsSelf:     a.AttendeeProviderID == ev.AccountInfo.UserID,
+			ProfileURL: a.AttendeeProfileURL,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 以下為 Unipile 型別與本地模型之間的轉換
+// Postgres 只保存到微秒，時間先截斷讓各種實作的游標一致
+
+func chatFromUnipile(c unipile.Chat) *model.Chat {
+	return &model.Chat{
+		AccountID:          c.AccountID,
+		UnipileID:          c.ID,
+		ProviderID:         c.ProviderID,
+		AttendeeProviderID: c.AttendeeProviderID,
+		Name:               c.Name,
+		Type:               c.Type,
+		UnreadCount:        c.UnreadCount,
+		Archived:           c.Archived != 0,
+		ReadOnly:           c.ReadOnly != 0,
+		LastMessageAt:      unipile.ParseTimestamp(c.Timestamp).UTC().Truncate(time.Microsecond),
+	}
+}
+
+func chatToUnipile(c model.Chat) unipile.Chat {
+	return unipile.Chat{
+		Object:             "Chat",
+		ID:                 c.UnipileID,
+		AccountID:          c.AccountID,
+		AccountType:        "LINKEDIN",
+		ProviderID:         c.ProviderID,
+		AttendeeProviderID: c.AttendeeProviderID,
+		Name:               c.Name,
+		Type:               c.Type,
+		Timestamp:          c.LastMessageAt.UTC().Format(time.RFC3339Nano),
+		UnreadCount:        c.UnreadCount,
+		Archived:           boolToInt(c.Archived),
+		ReadOnly:           boolToInt(c.ReadOnly),
+	}
+}
+
+// senderNames 是對話參與者 provider id → 名稱
+func messageFromUnipile(accountID string, m unipile.Message, senderNames map[string]string) *model.Message {
+	names := make(model.StringList, 0, len(m.Attachments))
+	for _, a := range m.Attachments {
+		names = append(names, attachmentName(a))
+	}
+
+	return &model.Message{
+		AccountID:       accountID,
+		ChatID:          m.ChatID,
+		UnipileID:       m.ID,
+		SenderID:        m.SenderID,
+		SenderName:      senderNames[m.SenderID],
+		Text:            m.Text,
+		IsSender:        m.IsSender != 0,
+		AttachmentNames: names,
+		SentAt:          unipile.ParseTimestamp(m.Timestamp).UTC().Truncate(time.Microsecond),
+	}
+}
+
+func messageToUnipile(m model.Message) unipile.Message {
+	return unipile.Message{
+		Object:    "Message",
+		ID:        m.UnipileID,
+		AccountID: m.AccountID,
+		ChatID:    m.ChatID,
+		SenderID:  m.SenderID,
+		Text:      m.Text,
+		Timestamp: m.SentAt.UTC().Format(time.RFC3339Nano),
+		IsSender:  boolToInt(m.IsSender),
+	}
+}
+
+func attendeeFromUnipile(accountID, chatID string, a unipile.ChatAttendee) *model.ChatAttendee {
+	return &model.ChatAttendee{
+		AccountID:  accountID,
+		ChatID:     chatID,
+		UnipileID:  a.ID,
+		ProviderID: a.ProviderID,
+		Name:       a.Name,
+		IsSelf:     a.IsSelf != 0,
+		ProfileURL: a.ProfileURL,
+		PictureURL: a.PictureURL,
+	}
+}
+
+func attendeeToUnipile(a model.ChatAttendee) unipile.ChatAttendee {
+	return unipile.ChatAttendee{
+		Object:     "ChatAttendee",
+		ID:         a.UnipileID,
+		AccountID:  a.AccountID,
+		ProviderID: a.ProviderID,
+		Name:       a.Name,
+		IsSelf:     boolToInt(a.IsSelf),
+		ProfileURL: a.ProfileURL,
+		PictureURL: a.PictureURL,
+	}
+}
+
+func attachmentName(a unipile.Attachment) string {
+	if a.FileName != "" {
+		return a.FileName
+	}
+	return a.ID
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/model"
+	"chatsheet/internal/repository/memory"
+	"chatsheet/internal/unipile"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeSyncUnipile 模擬一個有 3 個對話的帳號，對話列表每頁 2 筆，每個對話有 3 頁各 1 則對方送出的訊息
+// failChatsPage 為 true 時對話列表的第 2 頁回傳 500
+func fakeSyncUnipile(t *testing.T, failChatsPage *atomic.Bool) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/chats", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("after") != "" {
+			// 增量同步：回填之後沒有變動
+			json.NewEncoder(w).Encode(map[string]any{"items": []any{}})
+			return
+		}
+		page, _ := strconv.Atoi(q.Get("cursor"))
+		if page == 1 && failChatsPage.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var items []map[string]any
+		for i := page * 2; i < min(page*2+2, 3); i++ {
+			items = append(items, map[string]any{"id": fmt.Sprintf("c%d", i), "account_id": "acc-1", "timestamp": "2025-01-01T00:00:00.000Z"})
+		}
+		next := ""
+		if page == 0 {
+			next = "1"
+		}
+		json.NewEncoder(w).Encode(map[string]any{"items": items, "cursor": next})
+	})
+	mux.HandleFunc("GET /api/v1/chats/{id}/attendees", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		json.NewEncoder(w).Encode(map[string]any{"items": []map[string]any{{"id": "a-" + id, "provider_id": "p-" + id, "name": "Bob " + id}}})
+	})
+	mux.HandleFunc("GET /api/v1/chats/{id}/messages", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		page, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+		next := ""
+		if page < 2 {
+			next = strconv.Itoa(page + 1)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"items": []map[string]any{{
+				"id": fmt.Sprintf("%s-m%d", id, page), "chat_id": id, "sender_id": "p-" + id, "text": "hi",
+				"timestamp": fmt.Sprintf("2024-12-0%dT00:00:00.000Z", 9-page),
+			}},
+			"cursor": next,
+		})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSyncAccountResumesBackfill(t *testing.T) {
+	ctx := context.Background()
+	var failChatsPage atomic.Bool
+	failChatsPage.Store(true)
+	srv := fakeSyncUnipile(t, &failChatsPage)
+
+	chats, messages, attendees, checkpoints := memory.NewChatRepository(), memory.NewMessageRepository(), memory.NewChatAttendeeRepository(), memory.NewSyncCheckpointRepository()
+	s := NewSyncService(config.SyncConfig{PageSize: 2}, unipile.NewClient(config.UnipileConfig{APIBaseURL: srv.URL}), newTestUnipileRepository(t), chats, messages, attendees, checkpoints)
+	inbound := map[string]int{}
+	s.OnInboundMessage(func(ctx context.Context, msg *model.Message) { inbound[msg.UnipileID]++ })
+
+	// 1. 對話列表的第 2 頁失敗：已完成的第 1 頁與游標要保存下來，錯誤寫入 checkpoint
+	if err := s.SyncAccount(ctx, "acc-1"); err == nil {
+		t.Fatal("SyncAccount succeeded, want error from the failing page")
+	}
+	cp, err := checkpoints.Get(ctx, "acc-1")
+	if err != nil {
+		t.Fatalf("Get checkpoint: %v", err)
+	}
+	if cp.ChatsBackfilled || cp.ChatsCursor != "1" || cp.LastError == "" {
+		t.Fatalf("checkpoint after failure = %+v, want cursor 1 with an error", cp)
+	}
+	if _, err := chats.GetByUnipileID(ctx, "c1"); err != nil {
+		t.Fatalf("chat from the first page was not saved: %v", err)
+	}
+
+	// 2. 再次同步從游標繼續，完成對話與訊息的回填
+	failChatsPage.Store(false)
+	if err := s.SyncAccount(ctx, "acc-1"); err != nil {
+		t.Fatalf("SyncAccount: %v", err)
+	}
+	cp, err = checkpoints.Get(ctx, "acc-1")
+	if err != nil {
+		t.Fatalf("Get checkpoint: %v", err)
+	}
+	if !cp.ChatsBackfilled || cp.LastError != "" || cp.LastSyncedAt == nil {
+		t.Fatalf("checkpoint after sync = %+v, want backfilled without error", cp)
+	}
+
+	for i := range 3 {
+		chatID := fmt.Sprintf("c%d", i)
+		chat, err := chats.GetByUnipileID(ctx, chatID)
+		if err != nil {
+			t.Fatalf("GetByUnipileID %s: %v", chatID, err)
+		}
+		if !chat.MessagesBackfilled {
+			t.Errorf("chat %s messages not backfilled", chatID)
+		}
+
+		msgs, err := messages.ListByChatSince(ctx, chatID, time.Time{})
+		if err != nil {
+			t.Fatalf("ListByChatSince %s: %v", chatID, err)
+		}
+		if len(msgs) != 3 {
+			t.Fatalf("chat %s has %d messages, want 3", chatID, len(msgs))
+		}
+		for _, m := range msgs {
+			// 寄件者名稱來自對話參與者，供全文搜尋使用
+			if m.SenderName != "Bob "+chatID || m.IsSender {
+				t.Errorf("message %s = sender %q is_sender %v", m.UnipileID, m.SenderName, m.IsSender)
+			}
+			if inbound[m.UnipileID] == 0 {
+				t.Errorf("inbound handler not called for %s", m.UnipileID)
+			}
+		}
+	}
+}
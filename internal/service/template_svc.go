@@ -0,0 +1,405 @@
+package service
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/unipile"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// maxMessageLength LinkedIn 單則訊息的長度上限
+const maxMessageLength = 8000
+
+// TemplateData 是訊息範本可以使用的變數，例如 {{.FirstName}}、{{.Sender.Name}}
+// 對方的資料來自個人檔案快取 (ContactService)，Sender 為送出訊息的連結帳號
+type TemplateData struct {
+	FirstName  string
+	LastName   string
+	Name       string
+	Company    string
+	Headline   string
+	Location   string
+	ProfileURL string
+	Sender     TemplateSender
+}
+
+// TemplateSender 是送出訊息的連結帳號
+type TemplateSender struct {
+	FirstName string
+	LastName  string
+	Name      string
+	Headline  string
+	Email     string // 使用者的 email
+}
+
+// templateVariables TemplateData 中所有可用的變數
+var templateVariables = dataFields(reflect.TypeOf(TemplateData{}), "")
+
+func dataFields(t reflect.Type, prefix string) []string {
+	var fields []string
+	for i := range t.NumField() {
+		f := t.Field(i)
+		switch f.Type.Kind() {
+		case reflect.String:
+			fields = append(fields, prefix+f.Name)
+		case reflect.Struct:
+			fields = append(fields, dataFields(f.Type, prefix+f.Name+".")...)
+		}
+	}
+	return fields
+}
+
+// TemplateInput 建立或更新範本的內容
+type TemplateInput struct {
+	Name string
+	Body string
+}
+
+// TemplatePreview 是範本套用到對象的結果
+type TemplatePreview struct {
+	Text    string         `json:"text"`
+	Missing []string       `json:"missing"` // 對象沒有資料的變數 (不在 {{if}} 之中)，送出前必須補上
+	Contact *model.Contact `json:"contact"`
+}
+
+// TemplateService 管理使用者的訊息範本，並以個人檔案快取的資料產生訊息
+type TemplateService struct {
+	contactSvc   *ContactService
+	client       *unipile.Client
+	templateRepo itfc.TemplateRepository
+}
+
+func NewTemplateService(contactSvc *ContactService, client *unipile.Client, templateRepo itfc.TemplateRepository) *TemplateService {
+	return &TemplateService{
+		contactSvc:   contactSvc,
+		client:       client,
+		templateRepo: templateRepo,
+	}
+}
+
+// Create 建立範本，名稱在同一使用者中不能重複
+func (s *TemplateService) Create(ctx context.Context, email string, in TemplateInput) (*model.MessageTemplate, error) {
+	tmpl := &model.MessageTemplate{UserEmail: email}
+	if err := applyTemplateInput(tmpl, in); err != nil {
+		return nil, err
+	}
+
+	err := s.templateRepo.Create(ctx, tmpl)
+	if errors.Is(err, apperr.ErrConflict) {
+		return nil, apperr.Conflict("Template name already exists")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// List 依名稱列出使用者的範本
+func (s *TemplateService) List(ctx context.Context, email string) ([]model.MessageTemplate, error) {
+	return s.templateRepo.ListByUser(ctx, email)
+}
+
+// Get 取得範本，範本不存在或不屬於該使用者時回傳 apperr.ErrNotFound
+func (s *TemplateService) Get(ctx context.Context, email, templateID string) (*model.MessageTemplate, error) {
+	id, err := uuid.Parse(templateID)
+	if err != nil {
+		return nil, apperr.NotFound("Template not found")
+	}
+
+	tmpl, err := s.templateRepo.Get(ctx, id)
+	if errors.Is(err, apperr.ErrNotFound) || (err == nil && tmpl.UserEmail != email) {
+		return nil, apperr.NotFound("Template not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// Update 覆寫範本的名稱與內容
+func (s *TemplateService) Update(ctx context.Context, email, templateID string, in TemplateInput) (*model.MessageTemplate, error) {
+	tmpl, err := s.Get(ctx, email, templateID)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyTemplateInput(tmpl, in); err != nil {
+		return nil, err
+	}
+
+	err = s.templateRepo.Update(ctx, tmpl)
+	if errors.Is(err, apperr.ErrConflict) {
+		return nil, apperr.Conflict("Template name already exists")
+	}
+	if errors.Is(err, apperr.ErrNotFound) {
+		return nil, apperr.NotFound("Template not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// Delete 刪除範本
+func (s *TemplateService) Delete(ctx context.Context, email, templateID string) error {
+	tmpl, err := s.Get(ctx, email, templateID)
+	if err != nil {
+		return err
+	}
+
+	err = s.templateRepo.Delete(ctx, tmpl.ID)
+	if errors.Is(err, apperr.ErrNotFound) {
+		return apperr.NotFound("Template not found")
+	}
+	return err
+}
+
+// Preview 以帳號查詢對象的個人檔案 (優先使用快取) 並套用範本，對象缺少的變數列在 Missing 中
+// identifier 可以是 provider id 或公開識別碼
+func (s *TemplateService) Preview(ctx context.Context, email, templateID, accountID, identifier string) (*TemplatePreview, error) {
+	tmpl, err := s.Get(ctx, email, templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.render(ctx, email, tmpl, accountID, identifier)
+}
+
+// Render 與 Preview 相同，但對象缺少變數時回傳 apperr.ErrValidation，details 為缺少的變數
+func (s *TemplateService) Render(ctx context.Context, email, templateID, accountID, identifier string) (string, error) {
+	tmpl, err := s.Get(ctx, email, templateID)
+	if err != nil {
+		return "", err
+	}
+
+	preview, err := s.render(ctx, email, tmpl, accountID, identifier)
+	if err != nil {
+		return "", err
+	}
+	if len(preview.Missing) > 0 {
+		return "", apperr.Validation("Missing template variables").
+			WithDetails(map[string][]string{"missing": preview.Missing})
+	}
+
+	return preview.Text, nil
+}
+
+func (s *TemplateService) render(ctx context.Context, email string, tmpl *model.MessageTemplate, accountID, identifier string) (*TemplatePreview, error) {
+	t, vars, err := parseTemplate(tmpl.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// GetProfile 會確認帳號屬於該使用者
+	contact, err := s.contactSvc.GetProfile(ctx, email, accountID, identifier, false)
+	if err != nil {
+		return nil, err
+	}
+
+	data := contactTemplateData(contact)
+	data.Sender.Email = email
+	if slices.ContainsFunc(vars.all, func(v string) bool { return strings.HasPrefix(v, "Sender.") }) {
+		profile, err := s.client.GetOwnProfile(ctx, accountID)
+		if err != nil {
+			return nil, err
+		}
+		data.Sender.FirstName = profile.FirstName
+		data.Sender.LastName = profile.LastName
+		data.Sender.Name = profile.Name()
+		data.Sender.Headline = profile.Occupation
+	}
+
+	var text strings.Builder
+	if err := t.Execute(&text, data); err != nil {
+		return nil, apperr.Wrap(apperr.ErrValidation, "Failed to render template", err)
+	}
+
+	missing := []string{}
+	for _, v := range vars.required {
+		if dataValue(data, v) == "" {
+			missing = append(missing, v)
+		}
+	}
+
+	return &TemplatePreview{Text: text.String(), Missing: missing, Contact: contact}, nil
+}
+
+func applyTemplateInput(tmpl *model.MessageTemplate, in TemplateInput) error {
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return apperr.Validation("name is required")
+	}
+	if strings.TrimSpace(in.Body) == "" {
+		return apperr.Validation("body is required")
+	}
+	if utf8.RuneCountInString(in.Body) > maxMessageLength {
+		return apperr.Validation(fmt.Sprintf("body must be at most %d characters", maxMessageLength))
+	}
+
+	_, vars, err := parseTemplate(in.Body)
+	if err != nil {
+		return err
+	}
+
+	tmpl.Name = name
+	tmpl.Body = in.Body
+	tmpl.Variables = model.StringList(vars.all)
+	return nil
+}
+
+// contactTemplateData 以快取的個人檔案填入對方的變數
+func contactTemplateData(c *model.Contact) TemplateData {
+	first, last := c.FirstName, c.LastName
+	if first == "" && last == "" {
+		// 新增名字與姓氏欄位之前快取的個人檔案只有完整姓名
+		first, last, _ = strings.Cut(c.Name, " ")
+	}
+
+	return TemplateData{
+		FirstName:  first,
+		LastName:   last,
+		Name:       c.Name,
+		Company:    c.Company,
+		Headline:   c.Headline,
+		Location:   c.Location,
+		ProfileURL: c.ProfileURL,
+	}
+}
+
+// dataValue 回傳 TemplateData 中 path (例如 Sender.Name) 的值
+func dataValue(data TemplateData, path string) string {
+	v := reflect.ValueOf(data)
+	for _, name := range strings.Split(path, ".") {
+		v = v.FieldByName(name)
+		if !v.IsValid() {
+			return ""
+		}
+	}
+	return strings.TrimSpace(v.String())
+}
+
+// templateVars 是範本使用的變數
+type templateVars struct {
+	all      []string // 所有變數
+	required []string // 不在 {{if}}、{{with}} 或 {{range}} 之中的變數，對象沒有資料時不能送出
+}
+
+// parseTemplate 解析範本並確認只使用 TemplateData 中的變數
+func parseTemplate(body string) (*template.Template, templateVars, error) {
+	t, err := template.New("message").Option("missingkey=error").Parse(body)
+	if err != nil {
+		return nil, templateVars{}, apperr.Validation("Invalid template").
+			WithDetails(map[string]string{"error": err.Error()})
+	}
+
+	var vars templateVars
+	if t.Tree != nil {
+		collectVars(t.Tree.Root, false, true, &vars)
+	}
+	slices.Sort(vars.all)
+	vars.all = slices.Compact(vars.all)
+	slices.Sort(vars.required)
+	vars.required = slices.Compact(vars.required)
+	if vars.all == nil {
+		vars.all = []string{}
+	}
+
+	var unknown []string
+	for _, v := range vars.all {
+		if !slices.Contains(templateVariables, v) {
+			unknown = append(unknown, v)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, templateVars{}, apperr.Validation("Unknown template variables").
+			WithDetails(map[string][]string{"unknown": unknown, "available": templateVariables})
+	}
+
+	// 以所有變數都有值的資料執行一次，找出其他執行時才會發生的錯誤
+	if err := t.Execute(io.Discard, sampleTemplateData()); err != nil {
+		return nil, templateVars{}, apperr.Validation("Invalid template").
+			WithDetails(map[string]string{"error": err.Error()})
+	}
+
+	return t, vars, nil
+}
+
+// collectVars 收集節點中以 . 或 $. 開頭的變數，optional 代表在 {{if}}、{{with}} 或 {{range}} 之中
+// {{with}} 與 {{range}} 之中的 . 不是 TemplateData (inScope 為 false)，只收集 $. 開頭的變數
+func collectVars(node parse.Node, optional, inScope bool, vars *templateVars) {
+	add := func(path string) {
+		vars.all = append(vars.all, path)
+		if !optional {
+			vars.required = append(vars.required, path)
+		}
+	}
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			collectVars(c, optional, inScope, vars)
+		}
+	case *parse.ActionNode:
+		collectVars(n.Pipe, optional, inScope, vars)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			for _, arg := range cmd.Args {
+				collectVars(arg, optional, inScope, vars)
+			}
+		}
+	case *parse.FieldNode:
+		if inScope {
+			add(strings.Join(n.Ident, "."))
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			add(strings.Join(n.Ident[1:], "."))
+		}
+	case *parse.IfNode:
+		collectVars(n.Pipe, true, inScope, vars)
+		collectVars(n.List, true, inScope, vars)
+		collectVars(n.ElseList, true, inScope, vars)
+	case *parse.WithNode:
+		collectVars(n.Pipe, true, inScope, vars)
+		collectVars(n.List, true, false, vars)
+		collectVars(n.ElseList, true, inScope, vars)
+	case *parse.RangeNode:
+		collectVars(n.Pipe, true, inScope, vars)
+		collectVars(n.List, true, false, vars)
+		collectVars(n.ElseList, true, inScope, vars)
+	case *parse.TemplateNode:
+		collectVars(n.Pipe, optional, inScope, vars)
+	}
+}
+
+func sampleTemplateData() TemplateData {
+	var data TemplateData
+	for _, v := range templateVariables {
+		f := reflect.ValueOf(&data).Elem()
+		for _, name := range strings.Split(v, ".") {
+			f = f.FieldByName(name)
+		}
+		f.SetString(v)
+	}
+	return data
+}
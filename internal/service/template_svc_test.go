@@ -0,0 +1,124 @@
+package service
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/model"
+	"chatsheet/internal/repository/memory"
+	"chatsheet/internal/unipile"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseTemplateVariables(t *testing.T) {
+	_, vars, err := parseTemplate("Hi {{.FirstName}}{{if .Company}} at {{.Company}}{{end}}, {{with .Headline}}{{.}} and {{$.Sender.Name}}{{end}}")
+	if err != nil {
+		t.Fatalf("parseTemplate: %v", err)
+	}
+	if want := []string{"Company", "FirstName", "Headline", "Sender.Name"}; !slices.Equal(vars.all, want) {
+		t.Errorf("all = %v, want %v", vars.all, want)
+	}
+	// {{if}} 與 {{with}} 之中的變數可以沒有資料
+	if want := []string{"FirstName"}; !slices.Equal(vars.required, want) {
+		t.Errorf("required = %v, want %v", vars.required, want)
+	}
+
+	for name, body := range map[string]string{
+		"Syntax":  "Hi {{.FirstName",
+		"Unknown": "Hi {{.Nickname}}",
+		"Nested":  "Hi {{.Sender.Phone}}",
+		"Runtime": "Hi {{index .FirstName 99}}",
+	} {
+		if _, _, err := parseTemplate(body); !errors.Is(err, apperr.ErrValidation) {
+			t.Errorf("%s: parseTemplate err = %v, want apperr.ErrValidation", name, err)
+		}
+	}
+}
+
+// newTestTemplateService 建立屬於 owner@example.com 的帳號 acc-1 與快取的個人檔案 prov-1
+func newTestTemplateService(t *testing.T) *TemplateService {
+	t.Helper()
+	ctx := context.Background()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != unipile.UsersEndpoint+"/me" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(unipile.OwnProfile{FirstName: "Sam", LastName: "Sender", Occupation: "Recruiter"})
+	}))
+	t.Cleanup(srv.Close)
+	client := unipile.NewClient(config.UnipileConfig{APIBaseURL: srv.URL})
+
+	unipileSvc := newTestUnipileService(t, "owner@example.com")
+	if _, err := unipileSvc.Create(ctx, "owner@example.com", "linkedin", "acc-1"); err != nil {
+		t.Fatalf("Create account: %v", err)
+	}
+	contacts := memory.NewContactRepository()
+	err := contacts.Upsert(ctx, &model.Contact{AccountID: "acc-1", ProviderID: "prov-1", Name: "Dana Lee", FirstName: "Dana", LastName: "Lee", FetchedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("Upsert contact: %v", err)
+	}
+
+	contactSvc := NewContactService(config.ContactsConfig{TTL: time.Hour}, unipileSvc, client, contacts)
+	return NewTemplateService(contactSvc, client, memory.NewTemplateRepository())
+}
+
+func TestTemplateRender(t *testing.T) {
+	ctx := context.Background()
+	s := newTestTemplateService(t)
+
+	tmpl, err := s.Create(ctx, "owner@example.com", TemplateInput{Name: " Intro ", Body: "Hi {{.FirstName}}, I'm {{.Sender.Name}}{{if .Company}} ({{.Company}}){{end}}"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if tmpl.Name != "Intro" {
+		t.Errorf("Name = %q, want trimmed", tmpl.Name)
+	}
+
+	text, err := s.Render(ctx, "owner@example.com", tmpl.ID.String(), "acc-1", "prov-1")
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if want := "Hi Dana, I'm Sam Sender"; text != want {
+		t.Errorf("Render = %q, want %q", text, want)
+	}
+
+	// 範本屬於建立的使用者
+	if _, err := s.Render(ctx, "other@example.com", tmpl.ID.String(), "acc-1", "prov-1"); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("Render by another user err = %v, want apperr.ErrNotFound", err)
+	}
+	if _, err := s.Create(ctx, "owner@example.com", TemplateInput{Name: "Intro", Body: "Hello"}); !errors.Is(err, apperr.ErrConflict) {
+		t.Errorf("Create duplicate name err = %v, want apperr.ErrConflict", err)
+	}
+}
+
+func TestTemplateRenderMissingVariables(t *testing.T) {
+	ctx := context.Background()
+	s := newTestTemplateService(t)
+
+	tmpl, err := s.Create(ctx, "owner@example.com", TemplateInput{Name: "Company", Body: "Saw your work at {{.Company}}"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	preview, err := s.Preview(ctx, "owner@example.com", tmpl.ID.String(), "acc-1", "prov-1")
+	if err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	if !slices.Equal(preview.Missing, []string{"Company"}) {
+		t.Errorf("Missing = %v, want [Company]", preview.Missing)
+	}
+
+	// 缺少變數的訊息不能送出
+	if _, err := s.Render(ctx, "owner@example.com", tmpl.ID.String(), "acc-1", "prov-1"); !errors.Is(err, apperr.ErrValidation) {
+		t.Errorf("Render err = %v, want apperr.ErrValidation", err)
+	}
+}
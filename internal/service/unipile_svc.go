@@ -1,30 +1,57 @@
 package service
 
 import (
+	"chatsheet/internal/apperr"
 	"chatsheet/internal/itfc"
 	"chatsheet/internal/model"
+	"chatsheet/internal/outbox"
+	"chatsheet/internal/unipile"
 	"context"
+	"errors"
+	"time"
 )
 
 // UnipileService 包含業務邏輯
 type UnipileService struct {
 	unipileRepo itfc.UnipileRepository // 依賴介面，而非實作
+	tx          itfc.Transactor
+	outbox      *outbox.Writer
 }
 
-func NewUnipileService(repo itfc.UnipileRepository) *UnipileService {
+func NewUnipileService(repo itfc.UnipileRepository, tx itfc.Transactor, outboxWriter *outbox.Writer) *UnipileService {
 	return &UnipileService{
 		unipileRepo: repo,
+		tx:          tx,
+		outbox:      outboxWriter,
 	}
 }
 
+// Create 連結帳號，同一個使用者重新連結相同的 account_id 時會更新既有資料
+// 在同一個交易中寫入 account.connected 事件 (payload 為帳號)，由 outbox relay 發布
 func (s *UnipileService) Create(ctx context.Context, email, provider, accountID string) (*model.UnipileAccount, error) {
+	// 剛完成連結，帳號在 Unipile 上的狀態一定正常
+	now := time.Now()
 	acct := &model.UnipileAccount{
-		UserEmail: email,
-		Provider:  provider,
-		AccountID: accountID,
+		UserEmail:       email,
+		Provider:        provider,
+		AccountID:       accountID,
+		Status:          unipile.AccountStatusOK,
+		StatusCheckedAt: &now,
 	}
 
-	newAcct, err := s.unipileRepo.Create(ctx, acct)
+	var newAcct *model.UnipileAccount
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		newAcct, err = s.unipileRepo.Upsert(ctx, acct)
+		if err != nil {
+			return err
+		}
+		return s.outbox.Add(ctx, model.EventAccountConnected, newAcct.AccountID, newAcct)
+	})
+	if errors.Is(err, apperr.ErrConflict) {
+		return nil, apperr.Wrap(apperr.ErrConflict, "LinkedIn account already linked to another user", err).
+			WithDetails(map[string]any{"account_id": accountID})
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -40,3 +67,42 @@ func (s *UnipileService) ListByEmail(ctx context.Context, email string) ([]model
 
 	return accts, nil
 }
+
+// Get 取得使用者的帳號，帳號不存在或不屬於該使用者時回傳 apperr.ErrNotFound
+func (s *UnipileService) Get(ctx context.Context, email, accountID string) (*model.UnipileAccount, error) {
+	accts, err := s.unipileRepo.ListByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range accts {
+		if a.AccountID == accountID {
+			return &a, nil
+		}
+	}
+
+	return nil, apperr.NotFound("LinkedIn account not found")
+}
+
+// Delete 刪除使用者的帳號，並在同一個交易中寫入 account.disconnected 事件 (payload 為刪除前的帳號)
+func (s *UnipileService) Delete(ctx context.Context, email, accountID string) error {
+	acct, err := s.Get(ctx, email, accountID)
+	if err != nil {
+		return err
+	}
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.unipileRepo.DeleteByAccountID(ctx, email, accountID); err != nil {
+			return err
+		}
+		return s.outbox.Add(ctx, model.EventAccountDisconnected, acct.AccountID, acct)
+	})
+	if errors.Is(err, apperr.ErrNotFound) {
+		return apperr.Wrap(apperr.ErrNotFound, "LinkedIn account not found", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/model"
+	"chatsheet/internal/outbox"
+	"chatsheet/internal/repository/memory"
+	"context"
+	"errors"
+	"testing"
+)
+
+// newTestUnipileRepository 建立記憶體的 UnipileRepository，並先建立 emails 中的使用者 (帳號需要參照存在的使用者)
+func newTestUnipileRepository(t *testing.T, emails ...string) itfc.UnipileRepository {
+	t.Helper()
+
+	users := memory.NewUserRepository()
+	for _, email := range emails {
+		if _, err := users.Create(context.Background(), &model.User{Email: email, Password: "hashed"}); err != nil {
+			t.Fatalf("create user %s: %v", email, err)
+		}
+	}
+	return memory.NewUnipileRepository(users)
+}
+
+// newTestUnipileService 與 newTestUnipileRepository 相同，回傳 UnipileService
+func newTestUnipileService(t *testing.T, emails ...string) *UnipileService {
+	t.Helper()
+
+	return NewUnipileService(newTestUnipileRepository(t, emails...), memory.NewTransactor(), outbox.NewWriter(memory.NewOutboxRepository()))
+}
+
+func TestUnipileCreate(t *testing.T) {
+	ctx := context.Background()
+	s := newTestUnipileService(t, "owner@example.com", "other@example.com")
+
+	if _, err := s.Create(ctx, "owner@example.com", "linkedin", "acc-1"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	// 同一個使用者重新連結時更新
+	if _, err := s.Create(ctx, "owner@example.com", "linkedin", "acc-1"); err != nil {
+		t.Errorf("relink by the owner: %v", err)
+	}
+	if _, err := s.Create(ctx, "other@example.com", "linkedin", "acc-1"); !errors.Is(err, apperr.ErrConflict) {
+		t.Errorf("link by another user = %v, want apperr.ErrConflict", err)
+	}
+	if _, err := s.Create(ctx, "ghost@example.com", "linkedin", "acc-2"); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("link for an unknown user = %v, want apperr.ErrNotFound", err)
+	}
+	if accts, _ := s.ListByEmail(ctx, "owner@example.com"); len(accts) != 1 {
+		t.Errorf("owner accounts = %+v, want 1", accts)
+	}
+}
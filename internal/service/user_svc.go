@@ -1,6 +1,7 @@
 package service
 
 import (
+	"chatsheet/internal/apperr"
 	"chatsheet/internal/itfc"
 	"chatsheet/internal/model"
 	"context"
@@ -23,7 +24,7 @@ func NewUserService(repo itfc.UserRepository) *UserService {
 // Create 是一個業務邏輯方法
 func (s *UserService) Create(ctx context.Context, email, password string) (*model.User, error) {
 	if email == "" || password == "" {
-		return nil, errors.New("email & password cannot be empty")
+		return nil, apperr.Validation("email & password cannot be empty")
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -34,9 +35,13 @@ func (s *UserService) Create(ctx context.Context, email, password string) (*mode
 	user := &model.User{
 		Email:    email,
 		Password: string(hashedPassword),
+		Role:     model.RoleUser,
 	}
 
 	newUser, err := s.userRepo.Create(ctx, user)
+	if errors.Is(err, apperr.ErrConflict) {
+		return nil, apperr.Wrap(apperr.ErrConflict, "email already registered", err)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -48,16 +53,19 @@ func (s *UserService) Create(ctx context.Context, email, password string) (*mode
 func (s *UserService) Authenticate(ctx context.Context, email, password string) (*model.User, error) {
 	// 1. 根據 email 從資料庫查詢使用者
 	user, err := s.userRepo.GetByEmail(ctx, email)
-	if err != nil || user == nil {
-		// 建議統一回傳 "Invalid email or password" 以避免暴露使用者是否存在
-		return nil, errors.New("invalid email or password")
+	if errors.Is(err, apperr.ErrNotFound) {
+		// 統一回傳 "Invalid email or password" 以避免暴露使用者是否存在
+		return nil, apperr.Unauthorized("Invalid email or password")
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	// 2. 使用 bcrypt 比對使用者輸入的密碼與資料庫中的雜湊密碼
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if err != nil {
 		// 比對失敗，回傳認證失敗
-		return nil, errors.New("invalid email or password")
+		return nil, apperr.Unauthorized("Invalid email or password")
 	}
 
 	// 3. 認證成功，回傳使用者資訊
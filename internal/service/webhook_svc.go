@@ -0,0 +1,664 @@
+package service
+
+import (
+	"bytes"
+	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/envelope"
+	"chatsheet/internal/events"
+	"chatsheet/internal/itfc"
+	"chatsheet/internal/jobs"
+	"chatsheet/internal/model"
+	"chatsheet/internal/pagination"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// webhook 送出時的 header，接收端以 Signature 驗證內容來自 Chatsheet
+const (
+	WebhookEventHeader     = "X-Chatsheet-Event"
+	WebhookDeliveryHeader  = "X-Chatsheet-Delivery"
+	WebhookTimestampHeader = "X-Chatsheet-Timestamp" // Unix 秒數，接收端應拒絕太舊的請求以防重放
+	WebhookSignatureHeader = "X-Chatsheet-Signature" // "v1=" + hex(HMAC-SHA256(secret, timestamp + "." + body))
+)
+
+const (
+	// webhookJobType 送出 webhook 的背景工作類型
+	webhookJobType = "webhook.deliver"
+	// webhookQueue 送出 webhook 的佇列，避免回應慢的接收端佔用其他工作
+	webhookQueue = "webhooks"
+	// webhookResponseLimit 送出紀錄保存的回應內容長度上限
+	webhookResponseLimit = 1024
+)
+
+// WebhookEvent 是送到 endpoint 的 JSON 內容，重送時內容 (包含 id) 不變，接收端可以用 id 去除重複
+type WebhookEvent struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// AccountStatusChange 是 account.status_changed 事件的 data
+type AccountStatusChange struct {
+	Account        *model.UnipileAccount `json:"account"`
+	PreviousStatus string                `json:"previous_status"`
+}
+
+// WebhookEndpointInput 建立或更新 endpoint 的內容
+type WebhookEndpointInput struct {
+	URL         string
+	Description string
+	Events      []string
+	Active      *bool // 建立時預設啟用，更新時 nil 代表不變
+}
+
+// webhookDeliveryPayload 送出 webhook 的背景工作內容
+type webhookDeliveryPayload struct {
+	DeliveryID uuid.UUID `json:"delivery_id"`
+}
+
+// WebhookService 管理使用者的 webhook endpoint，並把事件以 HMAC-SHA256 簽章後送出
+//
+// 每個訂閱事件的 endpoint 會建立一筆送出紀錄，由 jobs 的 webhooks 佇列送出；
+// 失敗 (連線錯誤或非 2xx) 時依 jobs.retry_delay 加倍延後重試，超過 webhooks.max_attempts 後標記為 failed。
+type WebhookService struct {
+	cfg          config.WebhooksConfig
+	envelope     *envelope.Envelope
+	endpointRepo itfc.WebhookEndpointRepository
+	deliveryRepo itfc.WebhookDeliveryRepository
+	unipileRepo  itfc.UnipileRepository
+	queue        *jobs.Queue
+	httpClient   *http.Client
+}
+
+func NewWebhookService(
+	cfg config.WebhooksConfig,
+	env *envelope.Envelope,
+	endpointRepo itfc.WebhookEndpointRepository,
+	deliveryRepo itfc.WebhookDeliveryRepository,
+	unipileRepo itfc.UnipileRepository,
+	queue *jobs.Queue,
+) *WebhookService {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+
+	s := &WebhookService{
+		cfg:          cfg,
+		envelope:     env,
+		endpointRepo: endpointRepo,
+		deliveryRepo: deliveryRepo,
+		unipileRepo:  unipileRepo,
+		queue:        queue,
+		httpClient:   newWebhookClient(cfg),
+	}
+	jobs.Handle(queue, webhookJobType, s.deliver)
+
+	return s
+}
+
+// Create 建立 endpoint，回傳的密鑰只會出現這一次
+func (s *WebhookService) Create(ctx context.Context, email string, in WebhookEndpointInput) (*model.WebhookEndpoint, string, error) {
+	ep := &model.WebhookEndpoint{ID: uuid.New(), UserEmail: email, Active: true}
+	if err := applyWebhookInput(ep, in, s.cfg.AllowPrivateNetworks); err != nil {
+		return nil, "", err
+	}
+
+	secret, err := s.sealNewSecret(ctx, ep)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.endpointRepo.Create(ctx, ep); err != nil {
+		return nil, "", err
+	}
+
+	return ep, secret, nil
+}
+
+// List 依建立時間列出使用者的 endpoint
+func (s *WebhookService) List(ctx context.Context, email string) ([]model.WebhookEndpoint, error) {
+	return s.endpointRepo.ListByUser(ctx, email)
+}
+
+// Get 取得 endpoint，不存在或不屬於該使用者時回傳 apperr.ErrNotFound
+func (s *WebhookService) Get(ctx context.Context, email, endpointID string) (*model.WebhookEndpoint, error) {
+	id, err := uuid.Parse(endpointID)
+	if err != nil {
+		return nil, apperr.NotFound("Webhook endpoint not found")
+	}
+
+	ep, err := s.endpointRepo.Get(ctx, id)
+	if errors.Is(err, apperr.ErrNotFound) || (err == nil && ep.UserEmail != email) {
+		return nil, apperr.NotFound("Webhook endpoint not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return ep, nil
+}
+
+// Update 覆寫 endpoint 的網址、說明與訂閱的事件，Active 不是 nil 時一併更新
+func (s *WebhookService) Update(ctx context.Context, email, endpointID string, in WebhookEndpointInput) (*model.WebhookEndpoint, error) {
+	ep, err := s.Get(ctx, email, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyWebhookInput(ep, in, s.cfg.AllowPrivateNetworks); err != nil {
+		return nil, err
+	}
+
+	if err := s.endpointRepo.Update(ctx, ep); err != nil {
+		return nil, err
+	}
+
+	return ep, nil
+}
+
+// Delete 刪除 endpoint 與其送出紀錄，尚未送出的事件不會再送出
+func (s *WebhookService) Delete(ctx context.Context, email, endpointID string) error {
+	ep, err := s.Get(ctx, email, endpointID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.deliveryRepo.DeleteByEndpoint(ctx, ep.ID); err != nil {
+		return err
+	}
+	return s.endpointRepo.Delete(ctx, ep.ID)
+}
+
+// RotateSecret 產生新的密鑰並立即生效，回傳的密鑰只會出現這一次
+func (s *WebhookService) RotateSecret(ctx context.Context, email, endpointID string) (*model.WebhookEndpoint, string, error) {
+	ep, err := s.Get(ctx, email, endpointID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := s.sealNewSecret(ctx, ep)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.endpointRepo.UpdateSecret(ctx, ep); err != nil {
+		return nil, "", err
+	}
+
+	return ep, secret, nil
+}
+
+// ListDeliveries 依建立時間由新到舊列出 endpoint 的送出紀錄
+func (s *WebhookService) ListDeliveries(ctx context.Context, email, endpointID, cursor string, limit int) ([]model.WebhookDelivery, string, error) {
+	ep, err := s.Get(ctx, email, endpointID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	after, err := pagination.Decode(cursor)
+	if err != nil {
+		return nil, "", apperr.Wrap(apperr.ErrValidation, "Invalid cursor", err)
+	}
+
+	limit = pagination.Limit(limit)
+	deliveries, err := s.deliveryRepo.ListByEndpoint(ctx, ep.ID, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(deliveries) == limit {
+		last := deliveries[len(deliveries)-1]
+		next = pagination.Cursor{Time: *last.CreatedAt, ID: last.ID.String()}.Encode()
+	}
+
+	return deliveries, next, nil
+}
+
+// Redeliver 以相同的內容重新送出事件，建立新的送出紀錄
+func (s *WebhookService) Redeliver(ctx context.Context, email, endpointID, deliveryID string) (*model.WebhookDelivery, error) {
+	ep, err := s.Get(ctx, email, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	if !ep.Active {
+		return nil, apperr.Conflict("Webhook endpoint is disabled")
+	}
+
+	orig, err := s.getDelivery(ctx, ep.ID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &model.WebhookDelivery{
+		EndpointID:   ep.ID,
+		EventID:      orig.EventID,
+		EventType:    orig.EventType,
+		Payload:      orig.Payload,
+		Status:       model.DeliveryPending,
+		RedeliveryOf: &orig.ID,
+	}
+	if err := s.deliveryRepo.Create(ctx, d); err != nil {
+		return nil, err
+	}
+	if err := s.enqueue(ctx, d); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// getDelivery 取得 endpoint 的送出紀錄，不存在或屬於其他 endpoint 時回傳 apperr.ErrNotFound
+func (s *WebhookService) getDelivery(ctx context.Context, endpointID uuid.UUID, deliveryID string) (*model.WebhookDelivery, error) {
+	id, err := uuid.Parse(deliveryID)
+	if err != nil {
+		return nil, apperr.NotFound("Webhook delivery not found")
+	}
+
+	d, err := s.deliveryRepo.Get(ctx, id)
+	if errors.Is(err, apperr.ErrNotFound) || (err == nil && d.EndpointID != endpointID) {
+		return nil, apperr.NotFound("Webhook delivery not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// HandleAccountEvent 訂閱 EventBus 上的 account.connected 與 account.disconnected (payload 為帳號)
+// webhook 事件 id 使用 outbox 事件的 id，同一個事件重複發布時只會送出一次
+func (s *WebhookService) HandleAccountEvent(ctx context.Context, ev events.Event) error {
+	eventID, err := uuid.Parse(ev.ID)
+	if err != nil {
+		slog.WarnContext(ctx, "Dropping account event with invalid id", "topic", ev.Topic, "event_id", ev.ID)
+		return nil
+	}
+	var acct model.UnipileAccount
+	if err := json.Unmarshal(ev.Payload, &acct); err != nil {
+		slog.WarnContext(ctx, "Dropping malformed account event", "topic", ev.Topic, "event_id", ev.ID, "err", err)
+		return nil
+	}
+
+	return s.publish(ctx, acct.UserEmail, ev.Topic, eventID, &acct)
+}
+
+// AccountStatusChanged 在帳號於 Unipile 上的狀態改變時送出 account.status_changed
+func (s *WebhookService) AccountStatusChanged(ctx context.Context, acct *model.UnipileAccount, previous string) {
+	s.publish(ctx, acct.UserEmail, model.EventAccountStatusChanged, uuid.New(), AccountStatusChange{
+		Account:        acct,
+		PreviousStatus: previous,
+	})
+}
+
+// MessageReceived 在收到對方訊息時送出 message.received
+// 事件 id 由訊息決定，同一則訊息重複觸發 (例如重疊的補同步) 時只會送出一次
+func (s *WebhookService) MessageReceived(ctx context.Context, msg *model.Message) {
+	acct, err := s.unipileRepo.GetByAccountID(ctx, msg.AccountID)
+	if err != nil {
+		if !errors.Is(err, apperr.ErrNotFound) {
+			slog.ErrorContext(ctx, "Failed to get account for webhook", "account_id", msg.AccountID, "err", err)
+		}
+		return
+	}
+
+	eventID := uuid.NewSHA1(uuid.NameSpaceURL, []byte(model.EventMessageReceived+":"+msg.UnipileID))
+	s.publish(ctx, acct.UserEmail, model.EventMessageReceived, eventID, msg)
+}
+
+// publish 為使用者每個訂閱事件的 endpoint 建立送出紀錄並排入背景工作
+// 錯誤會記錄在日誌；回傳的錯誤讓 EventBus 的事件重新發布，已經建立送出紀錄的 endpoint 不會重複送出
+func (s *WebhookService) publish(ctx context.Context, email, eventType string, eventID uuid.UUID, data any) error {
+	eps, err := s.endpointRepo.ListByUser(ctx, email)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list webhook endpoints", "email", email, "err", err)
+		return err
+	}
+
+	var payload []byte
+	var errs []error
+	for _, ep := range eps {
+		if !ep.Active || !slices.Contains(ep.Events, eventType) {
+			continue
+		}
+
+		if payload == nil {
+			payload, err = json.Marshal(WebhookEvent{ID: eventID, Type: eventType, CreatedAt: time.Now().UTC(), Data: data})
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to encode webhook event", "type", eventType, "err", err)
+				return err
+			}
+		}
+
+		d := &model.WebhookDelivery{
+			EndpointID: ep.ID,
+			EventID:    eventID,
+			EventType:  eventType,
+			Payload:    string(payload),
+			Status:     model.DeliveryPending,
+		}
+		if err := s.deliveryRepo.Create(ctx, d); err != nil {
+			// ErrConflict 代表同一個事件已經送到這個 endpoint
+			if !errors.Is(err, apperr.ErrConflict) {
+				slog.ErrorContext(ctx, "Failed to create webhook delivery", "endpoint_id", ep.ID, "err", err)
+				errs = append(errs, err)
+			}
+			continue
+		}
+		// 排入失敗時送出紀錄會標記為 failed，使用者可以手動重送
+		if err := s.enqueue(ctx, d); err != nil {
+			slog.ErrorContext(ctx, "Failed to enqueue webhook delivery", "delivery_id", d.ID, "err", err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// enqueue 排入送出的背景工作，失敗時把送出紀錄標記為 failed，讓使用者可以手動重送
+func (s *WebhookService) enqueue(ctx context.Context, d *model.WebhookDelivery) error {
+	_, err := s.queue.Enqueue(ctx, webhookJobType, webhookDeliveryPayload{DeliveryID: d.ID}, jobs.EnqueueOptions{
+		Queue:       webhookQueue,
+		MaxAttempts: s.cfg.MaxAttempts,
+	})
+	if err == nil {
+		return nil
+	}
+
+	d.Status = model.DeliveryFailed
+	d.Error = "enqueue: " + err.Error()
+	if saveErr := s.deliveryRepo.SaveAttempt(context.WithoutCancel(ctx), d); saveErr != nil {
+		slog.ErrorContext(ctx, "Failed to save webhook delivery", "delivery_id", d.ID, "err", saveErr)
+	}
+	return err
+}
+
+// deliver 是送出 webhook 的背景工作：送出一次並保存結果，需要重試時回傳錯誤
+func (s *WebhookService) deliver(ctx context.Context, p webhookDeliveryPayload) error {
+	d, err := s.deliveryRepo.Get(ctx, p.DeliveryID)
+	if errors.Is(err, apperr.ErrNotFound) {
+		// endpoint 已被刪除
+		return jobs.Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	if d.Status != model.DeliveryPending {
+		return nil
+	}
+
+	ep, err := s.endpointRepo.Get(ctx, d.EndpointID)
+	if errors.Is(err, apperr.ErrNotFound) {
+		return jobs.Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	if !ep.Active {
+		d.Status = model.DeliveryFailed
+		d.Error = "Webhook endpoint is disabled"
+		if err := s.deliveryRepo.SaveAttempt(ctx, d); err != nil {
+			return err
+		}
+		return jobs.Permanent(errors.New(d.Error))
+	}
+
+	secret, err := s.openSecret(ctx, ep)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+
+	start := time.Now()
+	status, body, sendErr := s.send(ctx, ep.URL, secret, d)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	d.Attempts++
+	d.LastAttemptAt = &now
+	d.DurationMs = time.Since(start).Milliseconds()
+	d.ResponseStatus = status
+	d.ResponseBody = body
+	d.Error = ""
+	switch {
+	case sendErr != nil:
+		d.Error = sendErr.Error()
+	case status < 200 || status > 299:
+		d.Error = fmt.Sprintf("Endpoint responded with status %d", status)
+	}
+
+	switch {
+	case d.Error == "":
+		d.Status = model.DeliverySucceeded
+		d.DeliveredAt = &now
+	case d.Attempts >= s.cfg.MaxAttempts:
+		d.Status = model.DeliveryFailed
+	}
+
+	// 即使 ctx 已結束 (例如正在關機)，也要保存這次嘗試的結果
+	if err := s.deliveryRepo.SaveAttempt(context.WithoutCancel(ctx), d); err != nil {
+		return err
+	}
+
+	switch d.Status {
+	case model.DeliveryPending:
+		return errors.New(d.Error)
+	case model.DeliveryFailed:
+		return jobs.Permanent(errors.New(d.Error))
+	}
+	return nil
+}
+
+// send 以簽章後的 POST 送出事件，回傳 HTTP 狀態碼與截斷的回應內容
+func (s *WebhookService) send(ctx context.Context, endpointURL, secret string, d *model.WebhookDelivery) (int, string, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointURL, strings.NewReader(d.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chatsheet-Webhooks/1.0")
+	req.Header.Set(WebhookEventHeader, d.EventType)
+	req.Header.Set(WebhookDeliveryHeader, d.ID.String())
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(secret, timestamp, []byte(d.Payload)))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	// 截斷可能切在多位元組字元中間，移除無效的部分，避免寫入資料庫時失敗
+	return resp.StatusCode, string(bytes.ToValidUTF8(body, nil)), nil
+}
+
+// newWebhookClient 建立送出 webhook 的 HTTP client
+// 連線時檢查解析後的 IP (DNS rebinding 也無法繞過)，且不跟隨 redirect，避免被導向內部服務
+func newWebhookClient(cfg config.WebhooksConfig) *http.Client {
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivateNetworks {
+		dialer.Control = denyPrivateAddress
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// 經過 proxy 時連線的是 proxy 的位址，無法檢查接收端
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout: cfg.Timeout,
+		// 每次送出建立一個 client span，以接收端的 host 命名
+		Transport: otelhttp.NewTransport(transport, otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+			return "webhook " + req.Method + " " + req.URL.Host
+		})),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			// 以 3xx 回應作為結果，記錄為失敗
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// errPrivateAddress 接收端位址不是公開的網路位址
+var errPrivateAddress = errors.New("webhook URL resolves to a non-public address")
+
+// denyPrivateAddress 是 net.Dialer 的 Control，拒絕連線到 isPublicAddr 以外的位址
+func denyPrivateAddress(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", errPrivateAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// nonPublicPrefixes 不是公開網際網路位址的網段 (IANA special-purpose registry)，
+// 補足 netip.Addr 的 IsPrivate、IsLoopback 等方法沒有涵蓋的部分
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // this network
+	netip.MustParsePrefix("100.64.0.0/10"),   // CGNAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // TEST-NET-1
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // TEST-NET-2
+	netip.MustParsePrefix("203.0.113.0/24"),  // TEST-NET-3
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved 與 broadcast
+	netip.MustParsePrefix("::/96"),           // IPv4-compatible (已棄用)
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use NAT64
+	netip.MustParsePrefix("100::/64"),        // discard-only
+	netip.MustParsePrefix("2001::/23"),       // IETF protocol assignments，包含 Teredo
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+}
+
+var (
+	nat64Prefix  = netip.MustParsePrefix("64:ff9b::/96")
+	sixToFourNet = netip.MustParsePrefix("2002::/16")
+)
+
+// isPublicAddr 判斷 ip 是否為公開的 unicast 位址
+// 內嵌 IPv4 的 IPv6 位址 (IPv4-mapped、NAT64、6to4) 以內嵌的 IPv4 位址判斷
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if ip.Is6() {
+		b := ip.As16()
+		switch {
+		case nat64Prefix.Contains(ip):
+			return isPublicAddr(netip.AddrFrom4([4]byte(b[12:16])))
+		case sixToFourNet.Contains(ip):
+			return isPublicAddr(netip.AddrFrom4([4]byte(b[2:6])))
+		}
+	}
+
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// SignWebhook 計算 X-Chatsheet-Signature 的值，接收端以相同方式計算後用常數時間比較
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// sealNewSecret 產生新的密鑰並加密到 ep，回傳明文
+func (s *WebhookService) sealNewSecret(ctx context.Context, ep *model.WebhookEndpoint) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	secret := "whsec_" + hex.EncodeToString(raw)
+
+	// 以 endpoint id 作為 AAD，避免密文被搬到其他 endpoint
+	sealed, err := s.envelope.Seal(ctx, []byte(secret), []byte(ep.ID.String()))
+	if err != nil {
+		return "", fmt.Errorf("encrypt webhook secret: %w", err)
+	}
+	ep.KeyVersion = sealed.KeyVersion
+	ep.WrappedKey = sealed.WrappedKey
+	ep.SecretCiphertext = sealed.Ciphertext
+
+	return secret, nil
+}
+
+// openSecret 解密 endpoint 的密鑰
+func (s *WebhookService) openSecret(ctx context.Context, ep *model.WebhookEndpoint) (string, error) {
+	secret, err := s.envelope.Open(ctx, &envelope.Sealed{
+		KeyVersion: ep.KeyVersion,
+		WrappedKey: ep.WrappedKey,
+		Ciphertext: ep.SecretCiphertext,
+	}, []byte(ep.ID.String()))
+	if err != nil {
+		return "", fmt.Errorf("decrypt webhook secret: %w", err)
+	}
+	return string(secret), nil
+}
+
+// applyWebhookInput 驗證並套用 endpoint 的內容，allowPrivate 為 false 時拒絕內部位址
+func applyWebhookInput(ep *model.WebhookEndpoint, in WebhookEndpointInput, allowPrivate bool) error {
+	u, err := url.Parse(strings.TrimSpace(in.URL))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return apperr.Validation("url must be an absolute http or https URL")
+	}
+	// 先拒絕明顯的內部位址；以主機名稱指向內部位址的 URL 在連線時 (denyPrivateAddress) 拒絕
+	if !allowPrivate {
+		host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+		if ip, err := netip.ParseAddr(host); (err == nil && !isPublicAddr(ip)) || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+			return apperr.Validation("url must not point to a private or loopback address")
+		}
+	}
+
+	if len(in.Events) == 0 {
+		return apperr.Validation("At least one event type is required").
+			WithDetails(map[string]any{"available": model.WebhookEventTypes})
+	}
+	var events model.StringList
+	for _, e := range in.Events {
+		if !slices.Contains(model.WebhookEventTypes, e) {
+			return apperr.Validation("Unknown event type: " + e).
+				WithDetails(map[string]any{"available": model.WebhookEventTypes})
+		}
+		if !slices.Contains(events, e) {
+			events = append(events, e)
+		}
+	}
+
+	ep.URL = u.String()
+	ep.Description = strings.TrimSpace(in.Description)
+	ep.Events = events
+	if in.Active != nil {
+		ep.Active = *in.Active
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"chatsheet/internal/envelope"
+	"chatsheet/internal/jobs"
+	"chatsheet/internal/model"
+	"chatsheet/internal/repository/memory"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestWebhookService(t *testing.T, cfg config.WebhooksConfig) *WebhookService {
+	t.Helper()
+
+	key, err := envelope.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kms, err := envelope.NewLocalKMS(map[int][]byte{1: key}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue := jobs.NewQueue(config.JobsConfig{Queues: map[string]int{webhookQueue: 1}}, memory.NewJobRepository())
+	return NewWebhookService(cfg, envelope.New(kms), memory.NewWebhookEndpointRepository(),
+		memory.NewWebhookDeliveryRepository(), newTestUnipileRepository(t), queue)
+}
+
+// deliverOnce 建立 endpoint、發布一個事件並直接執行送出的背景工作，回傳送出紀錄
+func deliverOnce(t *testing.T, s *WebhookService, url string) (*model.WebhookDelivery, string, error) {
+	t.Helper()
+	ctx := context.Background()
+
+	ep, secret, err := s.Create(ctx, "owner@example.com", WebhookEndpointInput{URL: url, Events: []string{model.EventAccountConnected}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := s.publish(ctx, "owner@example.com", model.EventAccountConnected, uuid.New(), map[string]string{"account_id": "acc-1"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	deliveries, _, err := s.ListDeliveries(ctx, "owner@example.com", ep.ID.String(), "", 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("ListDeliveries = %d, %v; want 1 delivery", len(deliveries), err)
+	}
+
+	deliverErr := s.deliver(ctx, webhookDeliveryPayload{DeliveryID: deliveries[0].ID})
+	d, err := s.deliveryRepo.Get(ctx, deliveries[0].ID)
+	if err != nil {
+		t.Fatalf("Get delivery: %v", err)
+	}
+	return d, secret, deliverErr
+}
+
+func TestWebhookDeliverySigned(t *testing.T) {
+	var got *http.Request
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	s := newTestWebhookService(t, config.WebhooksConfig{AllowPrivateNetworks: true})
+	d, secret, err := deliverOnce(t, s, srv.URL)
+	if err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	if d.Status != model.DeliverySucceeded || d.ResponseStatus != http.StatusOK || d.ResponseBody != "ok" {
+		t.Errorf("delivery = %s %d %q, want succeeded 200 \"ok\"", d.Status, d.ResponseStatus, d.ResponseBody)
+	}
+	want := SignWebhook(secret, got.Header.Get(WebhookTimestampHeader), gotBody)
+	if sig := got.Header.Get(WebhookSignatureHeader); sig != want {
+		t.Errorf("signature = %q, want %q", sig, want)
+	}
+	if got.Header.Get(WebhookDeliveryHeader) != d.ID.String() || got.Header.Get(WebhookEventHeader) != model.EventAccountConnected {
+		t.Errorf("headers = %v", got.Header)
+	}
+}
+
+func TestWebhookDeliveryBlocksPrivateAddress(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	// 模擬以主機名稱指向 loopback (建立時的檢查無法發現)，連線時才被拒絕
+	s := newTestWebhookService(t, config.WebhooksConfig{AllowPrivateNetworks: true, MaxAttempts: 1})
+	s.httpClient = newWebhookClient(config.WebhooksConfig{Timeout: time.Second})
+	d, _, err := deliverOnce(t, s, srv.URL)
+
+	if !jobs.IsPermanent(err) {
+		t.Errorf("deliver error = %v, want permanent", err)
+	}
+	if called {
+		t.Error("request reached the private address")
+	}
+	if d.Status != model.DeliveryFailed || !strings.Contains(d.Error, errPrivateAddress.Error()) {
+		t.Errorf("delivery = %s %q, want failed with %q", d.Status, d.Error, errPrivateAddress)
+	}
+}
+
+func TestWebhookDeliveryDoesNotFollowRedirects(t *testing.T) {
+	followed := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal" {
+			followed = true
+			return
+		}
+		http.Redirect(w, r, "/internal", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	s := newTestWebhookService(t, config.WebhooksConfig{AllowPrivateNetworks: true, MaxAttempts: 3})
+	d, _, err := deliverOnce(t, s, srv.URL)
+
+	if err == nil || jobs.IsPermanent(err) {
+		t.Errorf("deliver error = %v, want retryable", err)
+	}
+	if followed {
+		t.Error("redirect was followed")
+	}
+	if d.Status != model.DeliveryPending || d.ResponseStatus != http.StatusFound || d.Attempts != 1 {
+		t.Errorf("delivery = %s %d attempts=%d, want pending 302 attempts=1", d.Status, d.ResponseStatus, d.Attempts)
+	}
+}
+
+func TestWebhookDeliveryTruncatesResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 10*webhookResponseLimit)))
+	}))
+	defer srv.Close()
+
+	s := newTestWebhookService(t, config.WebhooksConfig{AllowPrivateNetworks: true})
+	d, _, err := deliverOnce(t, s, srv.URL)
+	if err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if len(d.ResponseBody) != webhookResponseLimit {
+		t.Errorf("response body length = %d, want %d", len(d.ResponseBody), webhookResponseLimit)
+	}
+}
+
+func TestWebhookCreateRejectsPrivateURL(t *testing.T) {
+	s := newTestWebhookService(t, config.WebhooksConfig{})
+
+	for _, url := range []string{
+		"http://127.0.0.1/hook",
+		"http://localhost:8080/hook",
+		"http://LOCALHOST./hook",
+		"http://api.localhost/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.5/hook",
+		"http://[::1]/hook",
+		"http://[::ffff:192.168.1.1]/hook",
+		"http://0.0.0.0/hook",
+		"ftp://example.com/hook",
+	} {
+		_, _, err := s.Create(context.Background(), "owner@example.com", WebhookEndpointInput{URL: url, Events: []string{model.EventAccountConnected}})
+		if !errors.Is(err, apperr.ErrValidation) {
+			t.Errorf("Create(%q) error = %v, want ErrValidation", url, err)
+		}
+	}
+
+	if _, _, err := s.Create(context.Background(), "owner@example.com", WebhookEndpointInput{URL: "https://example.com/hook", Events: []string{model.EventAccountConnected}}); err != nil {
+		t.Errorf("Create(public URL): %v", err)
+	}
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34":                        true,
+		"2606:4700::1111":                      true,
+		"127.0.0.1":                            false,
+		"10.1.2.3":                             false,
+		"172.16.0.1":                           false,
+		"192.168.0.1":                          false,
+		"169.254.169.254":                      false,
+		"0.0.0.0":                              false,
+		"::1":                                  false,
+		"fe80::1":                              false,
+		"fd00::1":                              false,
+		"::ffff:10.0.0.1":                      false,
+		"100.64.0.1":                           false,
+		"0.1.2.3":                              false,
+		"192.0.0.8":                            false,
+		"198.18.0.1":                           false,
+		"240.0.0.1":                            false,
+		"255.255.255.255":                      false,
+		"224.0.0.1":                            false,
+		"239.1.2.3":                            false,
+		"ff02::1":                              false,
+		"ff0e::1":                              false,
+		"::10.0.0.1":                           false,
+		"::ffff:1.1.1.1":                       true,
+		"64:ff9b::a00:1":                       false, // NAT64 包住 10.0.0.1
+		"64:ff9b::101:101":                     true,  // NAT64 包住 1.1.1.1
+		"2002:c0a8:101::1":                     false, // 6to4 包住 192.168.1.1
+		"2002:7f00:1::1":                       false, // 6to4 包住 127.0.0.1
+		"2002:101:101::1":                      true,  // 6to4 包住 1.1.1.1
+		"2001:0:4136:e378:8000:63bf:3fff:fdd2": false, // Teredo
+		"2001:db8::1":                          false,
+	}
+	for addr, want := range tests {
+		if got := isPublicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("isPublicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
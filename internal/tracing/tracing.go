@@ -0,0 +1,118 @@
+// Package tracing 設定 OpenTelemetry 的分散式追蹤 (go.opentelemetry.io/otel)。
+//
+// New 依設定建立 TracerProvider 並設為全域的 provider，各套件以 otel.Tracer 建立 span：
+// 請求由 otelgin、資料庫查詢由 otelgorm、對外的 HTTP 請求由 otelhttp 記錄。
+// 跨程序以 W3C Trace Context (traceparent header) 傳遞。
+package tracing
+
+import (
+	"chatsheet/config"
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// New 依 tracing.exporter 建立 TracerProvider 並設為全域的 provider
+// exporter 為 none 或未設定時回傳 nil，不記錄任何 span，但仍然轉送上游的 traceparent
+// 呼叫端在結束前必須呼叫 Shutdown 送出剩下的 span
+func New(cfg config.TracingConfig) (*sdktrace.TracerProvider, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "chatsheet"
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 512
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	if cfg.OTLP.Timeout <= 0 {
+		cfg.OTLP.Timeout = 10 * time.Second
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "", "none":
+		return nil, nil
+	case "stdout":
+		exporter, err = stdouttrace.New()
+	case "otlp":
+		exporter, err = newOTLPExporter(cfg.OTLP)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(rootSampler{ratio: sdktrace.TraceIDRatioBased(cfg.SampleRatio)})),
+		sdktrace.WithBatcher(exporter,
+			sdktrace.WithMaxExportBatchSize(cfg.BatchSize),
+			sdktrace.WithBatchTimeout(cfg.FlushInterval),
+			sdktrace.WithExportTimeout(cfg.OTLP.Timeout),
+		),
+	)
+	otel.SetTracerProvider(tp)
+	return tp, nil
+}
+
+// newOTLPExporter 建立以 OTLP/HTTP 將 span 送到 endpoint + /v1/traces 的 exporter
+func newOTLPExporter(cfg config.OTLPConfig) (sdktrace.SpanExporter, error) {
+	if cfg.Endpoint == "" {
+		return nil, fmt.Errorf("tracing.otlp.endpoint is required")
+	}
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid tracing.otlp.endpoint %q", cfg.Endpoint)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/v1/traces"
+
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpointURL(u.String()),
+		otlptracehttp.WithTimeout(cfg.Timeout),
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	// 建立時不連線，collector 無法連線時只在匯出時記錄錯誤
+	return otlptracehttp.New(context.Background(), opts...)
+}
+
+// rootSampler 決定沒有上層的 span 是否記錄：依 sample_ratio 決定，但不記錄沒有上層的 client span
+// 避免背景工作的每個資料庫查詢與對外請求各自成為一個 trace
+type rootSampler struct {
+	ratio sdktrace.Sampler
+}
+
+func (s rootSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if p.Kind == trace.SpanKindClient {
+		return sdktrace.SamplingResult{
+			Decision:   sdktrace.Drop,
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+	return s.ratio.ShouldSample(p)
+}
+
+func (s rootSampler) Description() string {
+	return "RootSampler{" + s.ratio.Description() + ",drop client spans}"
+}
@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"chatsheet/config"
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNew(t *testing.T) {
+	tp, err := New(config.TracingConfig{Exporter: "none"})
+	if err != nil || tp != nil {
+		t.Fatalf("New(none) = %v, %v, want nil provider", tp, err)
+	}
+
+	if _, err := New(config.TracingConfig{Exporter: "jaeger"}); err == nil {
+		t.Fatal("New(jaeger): want error for unknown exporter")
+	}
+	if _, err := New(config.TracingConfig{Exporter: "otlp"}); err == nil {
+		t.Fatal("New(otlp): want error without endpoint")
+	}
+	if _, err := New(config.TracingConfig{Exporter: "otlp", OTLP: config.OTLPConfig{Endpoint: "localhost"}}); err == nil {
+		t.Fatal("New(otlp): want error for endpoint without scheme")
+	}
+
+	tp, err = New(config.TracingConfig{Exporter: "otlp", OTLP: config.OTLPConfig{Endpoint: "http://localhost:4318"}})
+	if err != nil || tp == nil {
+		t.Fatalf("New(otlp) = %v, %v", tp, err)
+	}
+	if err := tp.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
+
+func TestRootSamplerDropsClientSpansWithoutParent(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.ParentBased(rootSampler{ratio: sdktrace.AlwaysSample()})),
+		sdktrace.WithSyncer(exporter),
+	)
+	tracer := tp.Tracer("test")
+	ctx := context.Background()
+
+	// 背景工作的查詢沒有上層，不記錄
+	_, orphan := tracer.Start(ctx, "db.query", trace.WithSpanKind(trace.SpanKindClient))
+	orphan.End()
+
+	ctx, server := tracer.Start(ctx, "GET /api/chats", trace.WithSpanKind(trace.SpanKindServer))
+	_, child := tracer.Start(ctx, "db.query", trace.WithSpanKind(trace.SpanKindClient))
+	child.End()
+	server.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2 (server and its client child)", len(spans))
+	}
+	if spans[0].Name != "db.query" || spans[0].Parent.SpanID() != server.SpanContext().SpanID() {
+		t.Fatalf("client span = %s (parent %s), want child of the server span", spans[0].Name, spans[0].Parent.SpanID())
+	}
+}
@@ -0,0 +1,77 @@
+package unipile
+
+import (
+	"chatsheet/internal/apperr"
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// 帳號來源的狀態
+const (
+	AccountStatusOK          = "OK"
+	AccountStatusCredentials = "CREDENTIALS" // 需要重新登入
+	AccountStatusDeleted     = "DELETED"     // Unipile 上已經沒有這個帳號 (本地使用，不是 Unipile 的狀態)
+)
+
+// Account 是 Unipile 上連結的帳號
+type Account struct {
+	Object  string          `json:"object"` // "Account"
+	ID      string          `json:"id"`
+	Type    string          `json:"type"` // 例如: "LINKEDIN"
+	Name    string          `json:"name"`
+	Sources []AccountSource `json:"sources"`
+}
+
+// AccountSource 是帳號的一個同步來源 (例如訊息)
+type AccountSource struct {
+	ID     string `json:"id"`
+	Status string `json:"status"` // OK, STOPPED, ERROR, CREDENTIALS, PERMISSIONS 或 CONNECTING
+}
+
+// Status 回傳帳號的狀態，有多個來源時回傳第一個不是 OK 的狀態
+func (a *Account) Status() string {
+	for _, src := range a.Sources {
+		if src.Status != AccountStatusOK {
+			return src.Status
+		}
+	}
+	return AccountStatusOK
+}
+
+// GetAccount 取得 Unipile 上的帳號，不存在時回傳 apperr.ErrNotFound
+func (c *Client) GetAccount(ctx context.Context, accountID string) (*Account, error) {
+	var acct Account
+	status, err := c.Do(ctx, http.MethodGet, AccountEndpoint(accountID), nil, nil, &acct)
+	if status == http.StatusNotFound {
+		return nil, apperr.Wrap(apperr.ErrNotFound, "Account not found", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &acct, nil
+}
+
+// Ping 以列出一個帳號的請求確認 Unipile API 可以連線且 API key 有效
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Do(ctx, http.MethodGet, AccountsEndpoint, url.Values{"limit": {"1"}}, nil, nil)
+	return err
+}
+
+// ReconnectAccount 以 Cookie 重新連結需要重新登入的帳號 (POST /accounts/{account_id})
+// 回傳的 status 為 202 時 LinkedIn 要求解決 checkpoint，需要使用者操作
+func (c *Client) ReconnectAccount(ctx context.Context, accountID, accessToken, userAgent string) (*CheckpointResponse, int, error) {
+	body := map[string]string{
+		"provider":     "LINKEDIN",
+		"access_token": accessToken,
+		"user_agent":   userAgent,
+	}
+	var resp CheckpointResponse
+	status, err := c.Do(ctx, http.MethodPost, AccountEndpoint(accountID), nil, body, &resp)
+	if err != nil {
+		return nil, status, err
+	}
+
+	return &resp, status, nil
+}
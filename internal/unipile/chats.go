@@ -0,0 +1,172 @@
+package unipile
+
+import (
+	"chatsheet/internal/apperr"
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Unipile 訊息相關端點
+const (
+	ChatsEndpoint = "/api/v1/chats"
+)
+
+// ChatEndpoint 回傳單一對話的端點
+func ChatEndpoint(chatID string) string {
+	return ChatsEndpoint + "/" + url.PathEscape(chatID)
+}
+
+// Chat 是 Unipile 的對話
+type Chat struct {
+	Object             string `json:"object"` // "Chat"
+	ID                 string `json:"id"`
+	AccountID          string `json:"account_id"`
+	AccountType        string `json:"account_type"` // 例如: "LINKEDIN"
+	ProviderID         string `json:"provider_id"`
+	AttendeeProviderID string `json:"attendee_provider_id"` // 一對一對話中對方的 provider id
+	Name               string `json:"name"`
+	Type               int    `json:"type"` // 0: 一對一, 1: 群組
+	Timestamp          string `json:"timestamp"`
+	UnreadCount        int    `json:"unread_count"`
+	Archived           int    `json:"archived"`
+	ReadOnly           int    `json:"read_only"`
+	Subject            string `json:"subject,omitempty"`
+}
+
+// Attachment 是訊息的附件
+type Attachment struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"` // 例如: "img", "file", "video"
+	FileName string `json:"file_name,omitempty"`
+	FileSize int64  `json:"file_size,omitempty"`
+	MimeType string `json:"mimetype,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+// Message 是 Unipile 的訊息
+type Message struct {
+	Object           string       `json:"object"` // "Message"
+	ID               string       `json:"id"`
+	AccountID        string       `json:"account_id"`
+	ChatID           string       `json:"chat_id"`
+	ProviderID       string       `json:"provider_id"`
+	SenderID         string       `json:"sender_id"` // 寄件者的 provider id
+	SenderAttendeeID string       `json:"sender_attendee_id"`
+	Text             string       `json:"text"`
+	Timestamp        string       `json:"timestamp"`
+	IsSender         int          `json:"is_sender"` // 1 代表由連結的帳號送出
+	Attachments      []Attachment `json:"attachments"`
+	Seen             int          `json:"seen"`
+	Hidden           int          `json:"hidden"`
+	Deleted          int          `json:"deleted"`
+	Edited           int          `json:"edited"`
+	IsEvent          int          `json:"is_event"`
+}
+
+// ChatAttendee 是對話的參與者
+type ChatAttendee struct {
+	Object     string `json:"object"` // "ChatAttendee"
+	ID         string `json:"id"`
+	AccountID  string `json:"account_id"`
+	ProviderID string `json:"provider_id"`
+	Name       string `json:"name"`
+	IsSelf     int    `json:"is_self"`
+	ProfileURL string `json:"profile_url,omitempty"`
+	PictureURL string `json:"picture_url,omitempty"`
+	Specifics  *struct {
+		Provider   string `json:"provider"`
+		MemberURN  string `json:"member_urn,omitempty"`
+		Occupation string `json:"occupation,omitempty"`
+	} `json:"specifics,omitempty"`
+}
+
+// List 是 Unipile 列表 API 的回應，Cursor 為空代表沒有下一頁
+type List[T any] struct {
+	Object string `json:"object"`
+	Items  []T    `json:"items"`
+	Cursor string `json:"cursor"`
+}
+
+// ListOptions 列表 API 的分頁參數
+type ListOptions struct {
+	Cursor string
+	Limit  int
+	After  time.Time // 只列出此時間之後的項目，零值代表不限制
+}
+
+func (o ListOptions) values() url.Values {
+	q := url.Values{}
+	if !o.After.IsZero() {
+		q.Set("after", o.After.UTC().Format("2006-01-02T15:04:05.000Z"))
+	}
+	if o.Cursor != "" {
+		q.Set("cursor", o.Cursor)
+	}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return q
+}
+
+// ListChats 列出帳號的對話 (由新到舊)
+func (c *Client) ListChats(ctx context.Context, accountID string, opts ListOptions) (*List[Chat], error) {
+	q := opts.values()
+	q.Set("account_id", accountID)
+
+	var list List[Chat]
+	if _, err := c.Do(ctx, http.MethodGet, ChatsEndpoint, q, nil, &list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// GetChat 取得單一對話，不存在時回傳 apperr.ErrNotFound
+func (c *Client) GetChat(ctx context.Context, chatID string) (*Chat, error) {
+	var chat Chat
+	status, err := c.Do(ctx, http.MethodGet, ChatEndpoint(chatID), nil, nil, &chat)
+	if status == http.StatusNotFound {
+		return nil, apperr.Wrap(apperr.ErrNotFound, "Chat not found", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &chat, nil
+}
+
+// ListMessages 列出對話的訊息 (由新到舊)
+func (c *Client) ListMessages(ctx context.Context, chatID string, opts ListOptions) (*List[Message], error) {
+	var list List[Message]
+	status, err := c.Do(ctx, http.MethodGet, ChatEndpoint(chatID)+"/messages", opts.values(), nil, &list)
+	if status == http.StatusNotFound {
+		return nil, apperr.Wrap(apperr.ErrNotFound, "Chat not found", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// ListChatAttendees 列出對話的所有參與者
+func (c *Client) ListChatAttendees(ctx context.Context, chatID string) ([]ChatAttendee, error) {
+	var list List[ChatAttendee]
+	if _, err := c.Do(ctx, http.MethodGet, ChatEndpoint(chatID)+"/attendees", nil, nil, &list); err != nil {
+		return nil, err
+	}
+
+	return list.Items, nil
+}
+
+// ParseTimestamp 解析 Unipile 的 ISO 8601 時間，格式錯誤時回傳零值
+func ParseTimestamp(ts string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,136 @@
+package unipile
+
+import (
+	"chatsheet/config"
+	"chatsheet/internal/apperr"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestClient 建立連到 handler 的 Client，並確認每個請求都帶有 API key
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-API-KEY"); got != "test-key" {
+			t.Errorf("%s %s: X-API-KEY = %q", r.Method, r.URL.Path, got)
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return NewClient(config.UnipileConfig{APIKey: "test-key", APIBaseURL: srv.URL})
+}
+
+func TestListChatsPaginates(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/chats", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("account_id") != "acc-1" || q.Get("limit") != "2" || q.Get("after") != "2025-01-02T03:04:05.000Z" {
+			t.Errorf("query = %v", q)
+		}
+		// 3 個對話，每頁 2 筆，游標是下一頁的頁碼
+		page, _ := strconv.Atoi(q.Get("cursor"))
+		list := List[Chat]{Object: "ChatList"}
+		for i := page * 2; i < min(page*2+2, 3); i++ {
+			list.Items = append(list.Items, Chat{ID: "chat-" + strconv.Itoa(i), AccountID: "acc-1"})
+		}
+		if page == 0 {
+			list.Cursor = "1"
+		}
+		json.NewEncoder(w).Encode(list)
+	})
+	c := newTestClient(t, mux)
+
+	opts := ListOptions{Limit: 2, After: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)}
+	var ids []string
+	for page := 0; page < 5; page++ {
+		list, err := c.ListChats(context.Background(), "acc-1", opts)
+		if err != nil {
+			t.Fatalf("ListChats: %v", err)
+		}
+		for _, chat := range list.Items {
+			ids = append(ids, chat.ID)
+		}
+		if list.Cursor == "" {
+			break
+		}
+		opts.Cursor = list.Cursor
+	}
+	if len(ids) != 3 || ids[0] != "chat-0" || ids[2] != "chat-2" {
+		t.Errorf("paged through %v, want chat-0 to chat-2", ids)
+	}
+}
+
+func TestGetChat(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/chats/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != "chat/1" {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"status": 404, "type": "errors/resource_not_found"})
+			return
+		}
+		json.NewEncoder(w).Encode(Chat{Object: "Chat", ID: "chat/1", AccountID: "acc-1"})
+	})
+	c := newTestClient(t, mux)
+
+	// chat id 在路徑中需要跳脫
+	chat, err := c.GetChat(context.Background(), "chat/1")
+	if err != nil || chat.AccountID != "acc-1" {
+		t.Fatalf("GetChat = %+v, %v", chat, err)
+	}
+	if _, err := c.GetChat(context.Background(), "missing"); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("GetChat(missing) = %v, want apperr.ErrNotFound", err)
+	}
+}
+
+func TestListMessages(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/chats/{id}/messages", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != "chat-1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if got := r.URL.Query().Get("cursor"); got != "next" {
+			t.Errorf("cursor = %q, want next", got)
+		}
+		json.NewEncoder(w).Encode(List[Message]{Items: []Message{{ID: "msg-1", ChatID: "chat-1", Text: "hi"}}})
+	})
+	c := newTestClient(t, mux)
+
+	list, err := c.ListMessages(context.Background(), "chat-1", ListOptions{Cursor: "next"})
+	if err != nil || len(list.Items) != 1 || list.Items[0].Text != "hi" || list.Cursor != "" {
+		t.Fatalf("ListMessages = %+v, %v", list, err)
+	}
+	if _, err := c.ListMessages(context.Background(), "missing", ListOptions{}); !errors.Is(err, apperr.ErrNotFound) {
+		t.Errorf("ListMessages(missing) = %v, want apperr.ErrNotFound", err)
+	}
+}
+
+func TestListChatAttendees(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/chats/{id}/attendees", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(List[ChatAttendee]{Items: []ChatAttendee{
+			{ID: "att-1", ProviderID: "prov-1", Name: "Bob"},
+			{ID: "att-2", ProviderID: "prov-self", Name: "Me", IsSelf: 1},
+		}})
+	})
+	c := newTestClient(t, mux)
+
+	attendees, err := c.ListChatAttendees(context.Background(), "chat-1")
+	if err != nil || len(attendees) != 2 || attendees[1].IsSelf != 1 {
+		t.Fatalf("ListChatAttendees = %+v, %v", attendees, err)
+	}
+	if _, err := c.ListChatAttendees(context.Background(), "broken"); !errors.Is(err, apperr.ErrUpstream) {
+		t.Errorf("ListChatAttendees(broken) = %v, want apperr.ErrUpstream", err)
+	}
+}
@@ -2,13 +2,20 @@ package unipile
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"chatsheet/config"
+	"chatsheet/internal/apperr"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 // API 響應結構
@@ -23,45 +30,137 @@ type CheckpointResponse struct {
 	// 其他成功的欄位，例如 provider, status
 }
 
+// APIError 是 Unipile 回傳的錯誤內容
+type APIError struct {
+	Status int    `json:"status"`
+	Type   string `json:"type"`   // 例如: "errors/invalid_credentials"
+	Title  string `json:"title"`  // 例如: "Invalid credentials"
+	Detail string `json:"detail"` // 較詳細的說明
+}
+
+func (e *APIError) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("Unipile API 錯誤 %d %s: %s", e.Status, e.Title, e.Detail)
+	}
+	return fmt.Sprintf("Unipile API 錯誤 %d %s", e.Status, e.Title)
+}
+
+// upstreamError 將 Unipile 的錯誤回應轉換為 apperr.ErrUpstream
+// 只解析已知欄位，避免將可能包含憑證的原始響應體回傳給前端
+func upstreamError(status int, body []byte) error {
+	apiErr := &APIError{Status: status}
+	_ = json.Unmarshal(body, apiErr)
+	if apiErr.Status == 0 {
+		apiErr.Status = status
+	}
+	if apiErr.Title == "" {
+		apiErr.Title = http.StatusText(status)
+	}
+
+	return apperr.Upstream(apiErr.Title, apiErr).WithDetails(map[string]any{
+		"upstream_status": apiErr.Status,
+		"type":            apiErr.Type,
+		"detail":          apiErr.Detail,
+	})
+}
+
 // Unipile API 端點
 const (
 	AccountsEndpoint   = "/api/v1/accounts"
 	CheckpointEndpoint = "/api/v1/accounts/checkpoint"
 )
 
+// AccountEndpoint 回傳單一帳號的端點
+func AccountEndpoint(accountID string) string {
+	return AccountsEndpoint + "/" + url.PathEscape(accountID)
+}
+
+// Client 是 Unipile API 的客戶端
+type Client struct {
+	cfg        config.UnipileConfig
+	httpClient *http.Client
+}
+
+// NewClient 建立 Unipile API 客戶端，逾時由呼叫端的 context 控制
+// 每個請求建立一個 client span (見 otelhttp.Transport)，以端點命名
+func NewClient(cfg config.UnipileConfig) *Client {
+	transport := otelhttp.NewTransport(http.DefaultTransport, otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+		return "unipile " + req.Method + " " + endpointLabel(req.URL.Path)
+	}))
+	return &Client{cfg: cfg, httpClient: &http.Client{Transport: transport}}
+}
+
 // PerformRequest 執行對 Unipile API 的 POST 請求
-func PerformRequest(cfg config.UnipileConfig, endpoint string, data interface{}, target interface{}) (int, error) {
-	url := cfg.APIBaseURL + endpoint
+func PerformRequest(ctx context.Context, cfg config.UnipileConfig, endpoint string, data interface{}, target interface{}) (int, error) {
+	return Do(ctx, cfg, http.MethodPost, endpoint, data, target)
+}
 
-	jsonBody, err := json.Marshal(data)
-	if err != nil {
-		return 0, fmt.Errorf("無法序列化請求體: %w", err)
+// DeleteAccount 刪除 Unipile 上的帳號，帳號已不存在時視為成功
+func DeleteAccount(ctx context.Context, cfg config.UnipileConfig, accountID string) error {
+	status, err := Do(ctx, cfg, http.MethodDelete, AccountEndpoint(accountID), nil, nil)
+	if status == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+// Do 執行對 Unipile API 的請求，data 為 nil 時不送出請求體
+func Do(ctx context.Context, cfg config.UnipileConfig, method, endpoint string, data interface{}, target interface{}) (int, error) {
+	return NewClient(cfg).Do(ctx, method, endpoint, nil, data, target)
+}
+
+// Do 執行對 Unipile API 的請求，query 與 data 為 nil 時不送出查詢參數與請求體
+func (c *Client) Do(ctx context.Context, method, endpoint string, query url.Values, data interface{}, target interface{}) (int, error) {
+	reqURL := c.cfg.APIBaseURL + endpoint
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
+	var body io.Reader
+	if data != nil {
+		jsonBody, err := json.Marshal(data)
+		if err != nil {
+			return 0, fmt.Errorf("無法序列化請求體: %w", err)
+		}
+		body = bytes.NewBuffer(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, body)
 	if err != nil {
 		return 0, fmt.Errorf("無法建立請求: %w", err)
 	}
 
-	req.Header.Set("X-API-KEY", cfg.APIKey)
-	req.Header.Set("Content-Type", "application/json")
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return c.send(req, target)
+}
+
+// send 送出已建立好的請求並解析響應
+func (c *Client) send(req *http.Request, target interface{}) (int, error) {
+	req.Header.Set("X-API-KEY", c.cfg.APIKey)
 	req.Header.Set("Accept", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	endpoint := endpointLabel(req.URL.Path)
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	requestDuration.WithLabelValues(endpoint, req.Method).Observe(time.Since(start).Seconds())
 	if err != nil {
-		return 0, fmt.Errorf("API 請求失敗: %w", err)
+		requestsTotal.WithLabelValues(endpoint, req.Method, "error").Inc()
+		return 0, apperr.Upstream("Unipile API 無法連線", err)
 	}
+	requestsTotal.WithLabelValues(endpoint, req.Method, strconv.Itoa(resp.StatusCode)).Inc()
 	defer resp.Body.Close()
 
 	// 讀取響應體
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return resp.StatusCode, fmt.Errorf("讀取響應體失敗: %w", err)
+		return resp.StatusCode, apperr.Upstream("讀取 Unipile 響應體失敗", err)
 	}
 
 	// 檢查狀態碼
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		// 即使是錯誤，也嘗試解析為 CheckpointResponse 以獲取可能的 account_id
 		var checkpoint CheckpointResponse
 		if json.Unmarshal(bodyBytes, &checkpoint) == nil && checkpoint.Object == "Checkpoint" {
@@ -73,14 +172,16 @@ func PerformRequest(cfg config.UnipileConfig, endpoint string, data interface{},
 			return resp.StatusCode, nil
 		}
 
-		slog.Error("Unipile API 請求失敗", "status", resp.StatusCode, "body", string(bodyBytes))
-		return resp.StatusCode, fmt.Errorf("Unipile API 錯誤: %s", string(bodyBytes))
+		// 不記錄響應體，Unipile 的錯誤內容可能包含送出的憑證
+		err := upstreamError(resp.StatusCode, bodyBytes)
+		slog.ErrorContext(req.Context(), "Unipile API 請求失敗", "endpoint", endpoint, "status", resp.StatusCode, "err", err)
+		return resp.StatusCode, err
 	}
 
 	// 成功或 202 (Accepted/Checkpoint)
-	if target != nil {
+	if target != nil && len(bodyBytes) > 0 {
 		if err := json.Unmarshal(bodyBytes, target); err != nil {
-			return resp.StatusCode, fmt.Errorf("解析響應失敗: %w", err)
+			return resp.StatusCode, apperr.Upstream("解析 Unipile 響應失敗", err)
 		}
 	}
 
@@ -0,0 +1,128 @@
+package unipile
+
+import (
+	"chatsheet/internal/apperr"
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Unipile 邀請相關端點
+const (
+	InviteEndpoint         = UsersEndpoint + "/invite"
+	SentInvitesEndpoint    = InviteEndpoint + "/sent"
+	ReceivedInviteEndpoint = InviteEndpoint + "/received"
+)
+
+// 處理收到的邀請的動作
+const (
+	InvitationAccept  = "accept"
+	InvitationDecline = "decline"
+)
+
+// InvitationSent 是送出邀請後 Unipile 的回應
+type InvitationSent struct {
+	Object       string `json:"object"` // "UserInvitationSent"
+	InvitationID string `json:"invitation_id"`
+}
+
+// SentInvitation 是尚未回應的已送出邀請
+type SentInvitation struct {
+	Object                 string `json:"object"` // "UserInvitation"
+	ID                     string `json:"id"`
+	Date                   string `json:"date"`            // LinkedIn 顯示的相對時間，例如: "1 week ago"
+	ParsedDatetime         string `json:"parsed_datetime"` // ISO 8601
+	InvitedUser            string `json:"invited_user"`
+	InvitedUserID          string `json:"invited_user_id"` // 對方的 provider id
+	InvitedUserPublicID    string `json:"invited_user_public_id"`
+	InvitedUserDescription string `json:"invited_user_description,omitempty"`
+	InvitationText         string `json:"invitation_text,omitempty"`
+}
+
+// ReceivedInvitation 是尚未回應的收到的邀請
+type ReceivedInvitation struct {
+	Object         string `json:"object"` // "UserInvitation"
+	ID             string `json:"id"`
+	Date           string `json:"date"`
+	ParsedDatetime string `json:"parsed_datetime"`
+	InvitationText string `json:"invitation_text,omitempty"`
+	Inviter        struct {
+		InviterID               string `json:"inviter_id"` // 對方的 provider id
+		InviterName             string `json:"inviter_name"`
+		InviterPublicIdentifier string `json:"inviter_public_identifier"`
+		InviterDescription      string `json:"inviter_description,omitempty"`
+	} `json:"inviter"`
+	Specifics struct {
+		Provider     string `json:"provider"`
+		SharedSecret string `json:"shared_secret"` // 接受或拒絕時必須帶入
+	} `json:"specifics"`
+}
+
+// SendInvitation 透過帳號向對方送出連結邀請，message 為空字串時不附加訊息
+func (c *Client) SendInvitation(ctx context.Context, accountID, providerID, message string) (*InvitationSent, error) {
+	body := map[string]string{"account_id": accountID, "provider_id": providerID}
+	if message != "" {
+		body["message"] = message
+	}
+
+	var sent InvitationSent
+	if _, err := c.Do(ctx, http.MethodPost, InviteEndpoint, nil, body, &sent); err != nil {
+		return nil, err
+	}
+
+	return &sent, nil
+}
+
+// ListSentInvitations 列出帳號尚未被回應的已送出邀請
+func (c *Client) ListSentInvitations(ctx context.Context, accountID string, opts ListOptions) (*List[SentInvitation], error) {
+	q := opts.values()
+	q.Set("account_id", accountID)
+
+	var list List[SentInvitation]
+	if _, err := c.Do(ctx, http.MethodGet, SentInvitesEndpoint, q, nil, &list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// ListReceivedInvitations 列出帳號尚未回應的收到的邀請
+func (c *Client) ListReceivedInvitations(ctx context.Context, accountID string, opts ListOptions) (*List[ReceivedInvitation], error) {
+	q := opts.values()
+	q.Set("account_id", accountID)
+
+	var list List[ReceivedInvitation]
+	if _, err := c.Do(ctx, http.MethodGet, ReceivedInviteEndpoint, q, nil, &list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// HandleInvitation 接受或拒絕收到的邀請，action 為 InvitationAccept 或 InvitationDecline
+func (c *Client) HandleInvitation(ctx context.Context, accountID, invitationID, sharedSecret, action string) error {
+	body := map[string]string{
+		"provider":      "LINKEDIN",
+		"account_id":    accountID,
+		"shared_secret": sharedSecret,
+		"action":        action,
+	}
+
+	status, err := c.Do(ctx, http.MethodPost, ReceivedInviteEndpoint+"/"+url.PathEscape(invitationID), nil, body, nil)
+	if status == http.StatusNotFound {
+		return apperr.Wrap(apperr.ErrNotFound, "Invitation not found", err)
+	}
+	return err
+}
+
+// CancelInvitation 撤回已送出的邀請
+func (c *Client) CancelInvitation(ctx context.Context, accountID, invitationID string) error {
+	q := url.Values{}
+	q.Set("account_id", accountID)
+
+	status, err := c.Do(ctx, http.MethodDelete, SentInvitesEndpoint+"/"+url.PathEscape(invitationID), q, nil, nil)
+	if status == http.StatusNotFound {
+		return apperr.Wrap(apperr.ErrNotFound, "Invitation not found", err)
+	}
+	return err
+}
@@ -0,0 +1,107 @@
+package unipile
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// File 是要上傳到 Unipile 的附件
+type File struct {
+	Name        string
+	ContentType string
+	Content     io.Reader
+}
+
+// MessageSent 是送出訊息後 Unipile 的回應
+type MessageSent struct {
+	Object    string `json:"object"` // "MessageSent"
+	MessageID string `json:"message_id"`
+}
+
+// ChatStarted 是建立新對話後 Unipile 的回應
+type ChatStarted struct {
+	Object    string `json:"object"` // "ChatStarted"
+	ChatID    string `json:"chat_id"`
+	MessageID string `json:"message_id"`
+}
+
+// SendMessage 在既有的對話中送出訊息
+func (c *Client) SendMessage(ctx context.Context, chatID, text string, files []File) (*MessageSent, error) {
+	fields := [][2]string{{"text", text}}
+
+	var sent MessageSent
+	if _, err := c.doMultipart(ctx, ChatEndpoint(chatID)+"/messages", fields, files, &sent); err != nil {
+		return nil, err
+	}
+
+	return &sent, nil
+}
+
+// StartChat 以帳號與對方的 provider id 建立新對話並送出第一則訊息
+func (c *Client) StartChat(ctx context.Context, accountID string, attendeeIDs []string, text string, files []File) (*ChatStarted, error) {
+	fields := [][2]string{{"account_id", accountID}, {"text", text}}
+	for _, id := range attendeeIDs {
+		fields = append(fields, [2]string{"attendees_ids", id})
+	}
+
+	var started ChatStarted
+	if _, err := c.doMultipart(ctx, ChatsEndpoint, fields, files, &started); err != nil {
+		return nil, err
+	}
+
+	return &started, nil
+}
+
+// doMultipart 以 multipart/form-data 送出 POST 請求，附件欄位名稱為 attachments
+func (c *Client) doMultipart(ctx context.Context, endpoint string, fields [][2]string, files []File, target interface{}) (int, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	for _, f := range fields {
+		if err := w.WriteField(f[0], f[1]); err != nil {
+			return 0, fmt.Errorf("無法建立請求體: %w", err)
+		}
+	}
+
+	for _, f := range files {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="attachments"; filename="%s"`, escapeQuotes(f.Name)))
+		contentType := f.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		h.Set("Content-Type", contentType)
+
+		part, err := w.CreatePart(h)
+		if err != nil {
+			return 0, fmt.Errorf("無法建立附件: %w", err)
+		}
+		if _, err := io.Copy(part, f.Content); err != nil {
+			return 0, fmt.Errorf("無法讀取附件 %s: %w", f.Name, err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return 0, fmt.Errorf("無法建立請求體: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.APIBaseURL+endpoint, &body)
+	if err != nil {
+		return 0, fmt.Errorf("無法建立請求: %w", err)
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	return c.send(req, target)
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
@@ -0,0 +1,107 @@
+package unipile
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// receivedFile 是 multipart 請求中的一個附件
+type receivedFile struct {
+	name, contentType, content string
+}
+
+// readMultipart 解析 multipart/form-data 請求，回傳一般欄位與 attachments 欄位的附件
+func readMultipart(t *testing.T, r *http.Request) (map[string][]string, []receivedFile) {
+	t.Helper()
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		t.Fatalf("MultipartReader: %v", err)
+	}
+	fields := map[string][]string{}
+	var files []receivedFile
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		data, _ := io.ReadAll(part)
+		if part.FileName() == "" {
+			fields[part.FormName()] = append(fields[part.FormName()], string(data))
+			continue
+		}
+		if part.FormName() != "attachments" {
+			t.Errorf("file field = %q, want attachments", part.FormName())
+		}
+		files = append(files, receivedFile{part.FileName(), part.Header.Get("Content-Type"), string(data)})
+	}
+	return fields, files
+}
+
+func TestSendMessageUploadsAttachments(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/chats/{id}/messages", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != "chat-1" {
+			t.Errorf("chat id = %q", r.PathValue("id"))
+		}
+		fields, files := readMultipart(t, r)
+		if got := fields["text"]; len(got) != 1 || got[0] != "See attached" {
+			t.Errorf("text = %v", got)
+		}
+		want := []receivedFile{
+			{"report.pdf", "application/pdf", "%PDF"},
+			// 沒有指定類型時使用 application/octet-stream，檔名中的引號需要跳脫
+			{`say "hi".txt`, "application/octet-stream", "hi"},
+		}
+		if len(files) != len(want) {
+			t.Fatalf("received %d attachments, want %d", len(files), len(want))
+		}
+		for i := range want {
+			if files[i] != want[i] {
+				t.Errorf("attachment %d = %+v, want %+v", i, files[i], want[i])
+			}
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(MessageSent{Object: "MessageSent", MessageID: "msg-1"})
+	})
+	c := newTestClient(t, mux)
+
+	sent, err := c.SendMessage(context.Background(), "chat-1", "See attached", []File{
+		{Name: "report.pdf", ContentType: "application/pdf", Content: strings.NewReader("%PDF")},
+		{Name: `say "hi".txt`, Content: strings.NewReader("hi")},
+	})
+	if err != nil || sent.MessageID != "msg-1" {
+		t.Fatalf("SendMessage = %+v, %v", sent, err)
+	}
+}
+
+func TestStartChat(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/chats", func(w http.ResponseWriter, r *http.Request) {
+		fields, files := readMultipart(t, r)
+		if fields["account_id"][0] != "acc-1" || fields["text"][0] != "Hello" {
+			t.Errorf("fields = %v", fields)
+		}
+		if got := fields["attendees_ids"]; len(got) != 2 || got[0] != "prov-1" || got[1] != "prov-2" {
+			t.Errorf("attendees_ids = %v, want prov-1 and prov-2", got)
+		}
+		if len(files) != 0 {
+			t.Errorf("received %d attachments, want none", len(files))
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(ChatStarted{Object: "ChatStarted", ChatID: "chat-9", MessageID: "msg-9"})
+	})
+	c := newTestClient(t, mux)
+
+	started, err := c.StartChat(context.Background(), "acc-1", []string{"prov-1", "prov-2"}, "Hello", nil)
+	if err != nil || started.ChatID != "chat-9" || started.MessageID != "msg-9" {
+		t.Fatalf("StartChat = %+v, %v", started, err)
+	}
+}
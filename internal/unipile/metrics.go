@@ -0,0 +1,41 @@
+package unipile
+
+import (
+	"chatsheet/internal/metrics"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	requestsTotal = promauto.With(metrics.Registry).NewCounterVec(prometheus.CounterOpts{
+		Name: "unipile_requests_total",
+		Help: "Unipile API calls by endpoint, method and status (\"error\" when no response was received).",
+	}, []string{"endpoint", "method", "status"})
+	requestDuration = promauto.With(metrics.Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "unipile_request_duration_seconds",
+		Help:    "Unipile API call latency by endpoint.",
+		Buckets: slices.Concat(prometheus.DefBuckets, []float64{30, 60}), // 連結帳號可能需要數十秒
+	}, []string{"endpoint", "method"})
+)
+
+// endpointSegments 是 Unipile 路徑中固定的部分，其他部分 (帳號、對話、使用者 ID 等) 以 :id 取代
+var endpointSegments = map[string]bool{
+	"api": true, "v1": true,
+	"accounts": true, "checkpoint": true,
+	"chats": true, "messages": true, "attendees": true,
+	"users": true, "me": true, "invite": true, "sent": true, "received": true,
+}
+
+// endpointLabel 將請求路徑轉換為指標使用的端點，例如 /api/v1/chats/abc/messages → /api/v1/chats/:id/messages
+func endpointLabel(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, s := range segments {
+		if !endpointSegments[s] {
+			segments[i] = ":id"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
@@ -0,0 +1,116 @@
+package unipile
+
+import (
+	"chatsheet/internal/apperr"
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// UsersEndpoint Unipile 使用者 (LinkedIn 個人檔案) 端點
+const UsersEndpoint = "/api/v1/users"
+
+// UserEndpoint 回傳單一使用者的端點，identifier 可以是 provider id 或公開識別碼 (個人檔案網址中的名稱)
+func UserEndpoint(identifier string) string {
+	return UsersEndpoint + "/" + url.PathEscape(identifier)
+}
+
+// WorkExperience 是個人檔案中的一段經歷
+type WorkExperience struct {
+	Position string `json:"position"`
+	Company  string `json:"company"`
+	Location string `json:"location,omitempty"`
+	Start    string `json:"start,omitempty"`
+	End      string `json:"end,omitempty"` // 空字串代表目前仍在職
+}
+
+// UserProfile 是 Unipile 回傳的 LinkedIn 個人檔案
+type UserProfile struct {
+	Object            string           `json:"object"`   // "UserProfile"
+	Provider          string           `json:"provider"` // 例如: "LINKEDIN"
+	ProviderID        string           `json:"provider_id"`
+	PublicIdentifier  string           `json:"public_identifier"`
+	FirstName         string           `json:"first_name"`
+	LastName          string           `json:"last_name"`
+	Headline          string           `json:"headline"`
+	Location          string           `json:"location"`
+	ProfilePictureURL string           `json:"profile_picture_url,omitempty"`
+	PublicProfileURL  string           `json:"public_profile_url,omitempty"`
+	NetworkDistance   string           `json:"network_distance,omitempty"` // 例如: "FIRST_DEGREE"
+	WorkExperience    []WorkExperience `json:"work_experience,omitempty"`
+}
+
+// Name 回傳完整姓名
+func (p *UserProfile) Name() string {
+	return strings.TrimSpace(p.FirstName + " " + p.LastName)
+}
+
+// Company 回傳目前任職的公司，沒有經歷時回傳空字串
+func (p *UserProfile) Company() string {
+	for _, w := range p.WorkExperience {
+		if w.End == "" && w.Company != "" {
+			return w.Company
+		}
+	}
+	return ""
+}
+
+// ProfileURL 回傳個人檔案網址，Unipile 沒有提供時以公開識別碼組成
+func (p *UserProfile) ProfileURL() string {
+	if p.PublicProfileURL != "" {
+		return p.PublicProfileURL
+	}
+	if p.PublicIdentifier != "" {
+		return "https://www.linkedin.com/in/" + url.PathEscape(p.PublicIdentifier)
+	}
+	return ""
+}
+
+// GetUserProfile 透過連結的帳號查詢 LinkedIn 個人檔案，不存在時回傳 apperr.ErrNotFound
+func (c *Client) GetUserProfile(ctx context.Context, accountID, identifier string) (*UserProfile, error) {
+	q := url.Values{}
+	q.Set("account_id", accountID)
+	q.Set("linkedin_sections", "experience")
+
+	var profile UserProfile
+	status, err := c.Do(ctx, http.MethodGet, UserEndpoint(identifier), q, nil, &profile)
+	if status == http.StatusNotFound {
+		return nil, apperr.Wrap(apperr.ErrNotFound, "Profile not found", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+// OwnProfile 是連結帳號本身的 LinkedIn 個人檔案
+type OwnProfile struct {
+	Object           string `json:"object"` // "AccountOwnerProfile"
+	Provider         string `json:"provider"`
+	ProviderID       string `json:"provider_id"`
+	PublicIdentifier string `json:"public_identifier"`
+	FirstName        string `json:"first_name"`
+	LastName         string `json:"last_name"`
+	Occupation       string `json:"occupation"` // 個人檔案的標題
+	Location         string `json:"location,omitempty"`
+}
+
+// Name 回傳完整姓名
+func (p *OwnProfile) Name() string {
+	return strings.TrimSpace(p.FirstName + " " + p.LastName)
+}
+
+// GetOwnProfile 取得連結帳號本身的個人檔案
+func (c *Client) GetOwnProfile(ctx context.Context, accountID string) (*OwnProfile, error) {
+	q := url.Values{}
+	q.Set("account_id", accountID)
+
+	var profile OwnProfile
+	if _, err := c.Do(ctx, http.MethodGet, UsersEndpoint+"/me", q, nil, &profile); err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
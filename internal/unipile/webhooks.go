@@ -0,0 +1,55 @@
+package unipile
+
+// Unipile webhook 事件名稱
+const (
+	EventMessageReceived = "message_received"
+	EventMessageRead     = "message_read"
+	EventMessageEdited   = "message_edited"
+	EventMessageDeleted  = "message_deleted"
+	EventNewRelation     = "new_relation" // 送出的邀請被接受，或接受了對方的邀請
+)
+
+// WebhookAuthHeader 是建立 webhook 時設定的驗證 header
+const WebhookAuthHeader = "Unipile-Auth"
+
+// WebhookAttendee 是 webhook 事件中的參與者
+type WebhookAttendee struct {
+	AttendeeID         string `json:"attendee_id"`
+	AttendeeName       string `json:"attendee_name"`
+	AttendeeProviderID string `json:"attendee_provider_id"`
+	AttendeeProfileURL string `json:"attendee_profile_url,omitempty"`
+}
+
+// MessageEvent 是訊息相關 webhook (message_received 等) 的內容
+type MessageEvent struct {
+	Event       string `json:"event"`
+	AccountID   string `json:"account_id"`
+	AccountType string `json:"account_type"`
+	AccountInfo struct {
+		UserID string `json:"user_id"` // 連結帳號本身的 provider id
+	} `json:"account_info"`
+	ChatID      string            `json:"chat_id"`
+	MessageID   string            `json:"message_id"`
+	Message     string            `json:"message"`
+	Timestamp   string            `json:"timestamp"`
+	Sender      WebhookAttendee   `json:"sender"`
+	Attendees   []WebhookAttendee `json:"attendees"`
+	Attachments []Attachment      `json:"attachments"`
+}
+
+// IsSender 回傳訊息是否由連結的帳號送出
+func (e *MessageEvent) IsSender() bool {
+	return e.AccountInfo.UserID != "" && e.Sender.AttendeeProviderID == e.AccountInfo.UserID
+}
+
+// RelationEvent 是 users webhook (new_relation) 的內容
+type RelationEvent struct {
+	Event                string `json:"event"`
+	AccountID            string `json:"account_id"`
+	AccountType          string `json:"account_type"`
+	UserFullName         string `json:"user_full_name"`
+	UserProviderID       string `json:"user_provider_id"`
+	UserPublicIdentifier string `json:"user_public_identifier"`
+	UserProfileURL       string `json:"user_profile_url,omitempty"`
+	UserPictureURL       string `json:"user_picture_url,omitempty"`
+}
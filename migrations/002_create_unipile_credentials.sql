@@ -0,0 +1,45 @@
+-- Up Migration: 創建連線憑證資料表
+
+-- 'unipile_credentials' 以信封加密保存 LinkedIn li_at cookie 與 user agent
+-- 每一列有自己的資料金鑰 (wrapped_key)，由 key_version 指定的主金鑰包裝
+CREATE TABLE unipile_credentials (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+
+    -- 關聯到 User.Email
+    user_email VARCHAR(255) NOT NULL,
+
+    -- Unipile 服務返回的帳號 ID，每個帳號只保留一份憑證
+    account_id VARCHAR(255) UNIQUE NOT NULL,
+
+    -- 包裝資料金鑰所使用的主金鑰版本，輪替時用來找出需要重新包裝的資料列
+    key_version INTEGER NOT NULL,
+
+    -- 被主金鑰包裝的資料金鑰
+    wrapped_key BYTEA NOT NULL,
+
+    -- nonce || AES-256-GCM 密文
+    ciphertext BYTEA NOT NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_credential_user_email
+        FOREIGN KEY(user_email)
+        REFERENCES users(email)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_unipile_credentials_key_version ON unipile_credentials(key_version);
+
+CREATE TRIGGER update_unipile_credential_updated_at
+BEFORE UPDATE ON unipile_credentials
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+
+-- Down Migration
+
+/*
+DROP TRIGGER IF EXISTS update_unipile_credential_updated_at ON unipile_credentials;
+DROP TABLE IF EXISTS unipile_credentials;
+*/
@@ -0,0 +1,43 @@
+-- Up Migration: 創建 Idempotency-Key 資料表
+
+-- 'idempotency_keys' 記錄帶有 Idempotency-Key header 的請求與其第一次的成功回應
+CREATE TABLE idempotency_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+
+    -- key 以使用者為範圍
+    user_email VARCHAR(255) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+
+    -- method + path + body 的 SHA-256，用來偵測同一個 key 被用在不同請求
+    fingerprint VARCHAR(64) NOT NULL,
+
+    -- in_progress 或 completed
+    status VARCHAR(20) NOT NULL,
+
+    -- 快取的回應
+    response_code INTEGER,
+    content_type VARCHAR(255),
+    response_body BYTEA,
+
+    -- 過期後 key 可以被重新使用
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_idempotency_keys_user_key ON idempotency_keys(user_email, key);
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+
+CREATE TRIGGER update_idempotency_key_updated_at
+BEFORE UPDATE ON idempotency_keys
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+
+-- Down Migration
+
+/*
+DROP TRIGGER IF EXISTS update_idempotency_key_updated_at ON idempotency_keys;
+DROP TABLE IF EXISTS idempotency_keys;
+*/
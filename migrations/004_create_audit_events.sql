@@ -0,0 +1,58 @@
+-- Up Migration: 使用者角色與稽核事件
+
+-- 1. 使用者角色 (user 或 admin)，管理員可以查詢所有人的稽核事件
+--    目前沒有管理介面，請直接更新資料庫：UPDATE users SET role = 'admin' WHERE email = '...';
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user';
+
+
+-- 2. 創建 'audit_events' 資料表
+--    刻意不設定外鍵，使用者被刪除後其稽核事件仍需保留
+CREATE TABLE audit_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+
+    -- 執行動作的使用者 (登入失敗時為嘗試登入的 email)
+    actor_email VARCHAR(255) NOT NULL,
+
+    -- 動作，例如: user.login, account.link, account.reconnect, account.remove
+    action VARCHAR(50) NOT NULL,
+
+    -- 動作的對象，例如: unipile_account / <account_id>
+    target_type VARCHAR(50),
+    target_id VARCHAR(255),
+
+    -- 請求來源
+    ip VARCHAR(64),
+    user_agent TEXT,
+
+    -- success 或 failure，失敗時記錄原因
+    result VARCHAR(20) NOT NULL,
+    reason TEXT,
+
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_audit_events_actor_created ON audit_events(actor_email, created_at);
+CREATE INDEX idx_audit_events_created_at ON audit_events(created_at);
+
+-- 稽核事件只能新增，禁止修改與刪除
+CREATE OR REPLACE FUNCTION prevent_audit_event_change()
+RETURNS TRIGGER AS $$
+BEGIN
+   RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER prevent_audit_event_update_delete
+BEFORE UPDATE OR DELETE ON audit_events
+FOR EACH ROW
+EXECUTE FUNCTION prevent_audit_event_change();
+
+
+-- Down Migration
+
+/*
+DROP TRIGGER IF EXISTS prevent_audit_event_update_delete ON audit_events;
+DROP FUNCTION IF EXISTS prevent_audit_event_change;
+DROP TABLE IF EXISTS audit_events;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
+*/
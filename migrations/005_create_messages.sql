@@ -0,0 +1,47 @@
+-- Up Migration: 創建訊息資料表
+
+-- 'messages' 保存在本地的 LinkedIn 訊息 (目前為透過 Chatsheet 送出的訊息)
+CREATE TABLE messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+
+    -- Unipile account_id 與 chat id
+    account_id VARCHAR(255) NOT NULL,
+    chat_id VARCHAR(255) NOT NULL,
+
+    -- Unipile message id
+    unipile_id VARCHAR(255) UNIQUE NOT NULL,
+
+    -- 寄件者的 provider id
+    sender_id VARCHAR(255),
+    text TEXT,
+
+    -- true 代表由連結的帳號送出
+    is_sender BOOLEAN NOT NULL,
+
+    -- 附件檔名 (JSON 陣列)
+    attachment_names TEXT,
+
+    -- 透過 Chatsheet 送出時的使用者
+    sent_by_email VARCHAR(255),
+
+    sent_at TIMESTAMP WITH TIME ZONE NOT NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_messages_account_id ON messages(account_id);
+CREATE INDEX idx_messages_chat_sent ON messages(chat_id, sent_at);
+
+CREATE TRIGGER update_message_updated_at
+BEFORE UPDATE ON messages
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+
+-- Down Migration
+
+/*
+DROP TRIGGER IF EXISTS update_message_updated_at ON messages;
+DROP TABLE IF EXISTS messages;
+*/
@@ -0,0 +1,98 @@
+-- Up Migration: 創建本地訊息同步的資料表
+
+-- 'chats' 同步到本地的 LinkedIn 對話
+CREATE TABLE chats (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+
+    -- Unipile account_id 與 chat id
+    account_id VARCHAR(255) NOT NULL,
+    unipile_id VARCHAR(255) UNIQUE NOT NULL,
+
+    provider_id VARCHAR(255),
+    -- 一對一對話中對方的 provider id
+    attendee_provider_id VARCHAR(255),
+    name VARCHAR(255),
+    -- 0: 一對一, 1: 群組
+    type INTEGER NOT NULL DEFAULT 0,
+    unread_count INTEGER NOT NULL DEFAULT 0,
+    archived BOOLEAN NOT NULL DEFAULT FALSE,
+    read_only BOOLEAN NOT NULL DEFAULT FALSE,
+    last_message_at TIMESTAMP WITH TIME ZONE NOT NULL,
+
+    -- 訊息回填 (backfill) 的進度
+    messages_cursor TEXT,
+    messages_backfilled BOOLEAN NOT NULL DEFAULT FALSE,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_chats_account_last ON chats(account_id, last_message_at);
+
+CREATE TRIGGER update_chat_updated_at
+BEFORE UPDATE ON chats
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- 'chat_attendees' 同步到本地的對話參與者
+CREATE TABLE chat_attendees (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+
+    account_id VARCHAR(255) NOT NULL,
+    chat_id VARCHAR(255) NOT NULL,
+
+    -- Unipile attendee id 與 LinkedIn provider id
+    unipile_id VARCHAR(255),
+    provider_id VARCHAR(255) NOT NULL,
+
+    name VARCHAR(255),
+    is_self BOOLEAN NOT NULL DEFAULT FALSE,
+    profile_url TEXT,
+    picture_url TEXT,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_chat_attendees_account_id ON chat_attendees(account_id);
+CREATE UNIQUE INDEX idx_chat_attendees_chat_provider ON chat_attendees(chat_id, provider_id);
+
+CREATE TRIGGER update_chat_attendee_updated_at
+BEFORE UPDATE ON chat_attendees
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- 'sync_checkpoints' 每個連結帳號的同步進度，重新啟動時從這裡繼續
+CREATE TABLE sync_checkpoints (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+
+    account_id VARCHAR(255) UNIQUE NOT NULL,
+
+    -- 對話列表回填的進度
+    chats_cursor TEXT,
+    chats_backfilled BOOLEAN NOT NULL DEFAULT FALSE,
+
+    -- 上次增量同步開始的時間
+    last_synced_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER update_sync_checkpoint_updated_at
+BEFORE UPDATE ON sync_checkpoints
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+
+-- Down Migration
+
+/*
+DROP TRIGGER IF EXISTS update_sync_checkpoint_updated_at ON sync_checkpoints;
+DROP TABLE IF EXISTS sync_checkpoints;
+DROP TRIGGER IF EXISTS update_chat_attendee_updated_at ON chat_attendees;
+DROP TABLE IF EXISTS chat_attendees;
+DROP TRIGGER IF EXISTS update_chat_updated_at ON chats;
+DROP TABLE IF EXISTS chats;
+*/
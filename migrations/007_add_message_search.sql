@@ -0,0 +1,21 @@
+-- Up Migration: 訊息全文搜尋
+
+-- 寄件者名稱，與內文一起搜尋
+ALTER TABLE messages ADD COLUMN sender_name VARCHAR(255);
+
+-- 內文 (權重 A) 與寄件者名稱 (權重 B) 的 tsvector，使用 'simple' 設定以支援各種語言
+ALTER TABLE messages ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(text, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(sender_name, '')), 'B')
+) STORED;
+
+CREATE INDEX idx_messages_search ON messages USING GIN (search_vector);
+
+
+-- Down Migration
+
+/*
+DROP INDEX IF EXISTS idx_messages_search;
+ALTER TABLE messages DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE messages DROP COLUMN IF EXISTS sender_name;
+*/
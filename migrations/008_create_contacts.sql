@@ -0,0 +1,41 @@
+-- Up Migration: 創建 LinkedIn 個人檔案快取的資料表
+
+-- 'contacts' 透過連結帳號查詢到的 LinkedIn 個人檔案
+CREATE TABLE contacts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+
+    -- 查詢時使用的 Unipile account_id 與對方的 LinkedIn provider id
+    account_id VARCHAR(255) NOT NULL,
+    provider_id VARCHAR(255) NOT NULL,
+    -- 個人檔案網址中的名稱
+    public_identifier VARCHAR(255),
+
+    name VARCHAR(255),
+    headline TEXT,
+    company VARCHAR(255),
+    location VARCHAR(255),
+    profile_url TEXT,
+    picture_url TEXT,
+
+    -- 上次向 Unipile 取得的時間，超過 TTL 後重新取得
+    fetched_at TIMESTAMP WITH TIME ZONE NOT NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_contacts_account_provider ON contacts(account_id, provider_id);
+CREATE INDEX idx_contacts_public_identifier ON contacts(public_identifier);
+
+CREATE TRIGGER update_contact_updated_at
+BEFORE UPDATE ON contacts
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+
+-- Down Migration
+
+/*
+DROP TRIGGER IF EXISTS update_contact_updated_at ON contacts;
+DROP TABLE IF EXISTS contacts;
+*/
@@ -0,0 +1,50 @@
+-- Up Migration: 創建 LinkedIn 連結邀請的資料表
+
+-- 'invitations' 連結邀請在本地的紀錄
+CREATE TABLE invitations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+
+    -- Unipile account_id 與 invitation id
+    account_id VARCHAR(255) NOT NULL,
+    unipile_id VARCHAR(255) NOT NULL,
+
+    -- sent: 由連結的帳號送出, received: 連結的帳號收到
+    direction VARCHAR(20) NOT NULL,
+    -- pending、accepted、declined 或 withdrawn
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+
+    -- 對方的資訊
+    provider_id VARCHAR(255),
+    public_identifier VARCHAR(255),
+    name VARCHAR(255),
+    headline TEXT,
+
+    -- 邀請附加的訊息
+    message TEXT,
+    -- 接受或拒絕收到的邀請時 Unipile 需要的值
+    shared_secret TEXT,
+    -- 透過 Chatsheet 送出時的使用者
+    sent_by_email VARCHAR(255),
+
+    invited_at TIMESTAMP WITH TIME ZONE,
+    responded_at TIMESTAMP WITH TIME ZONE,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_invitations_account_unipile ON invitations(account_id, unipile_id);
+CREATE INDEX idx_invitations_account_provider ON invitations(account_id, provider_id);
+
+CREATE TRIGGER update_invitation_updated_at
+BEFORE UPDATE ON invitations
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+
+-- Down Migration
+
+/*
+DROP TRIGGER IF EXISTS update_invitation_updated_at ON invitations;
+DROP TABLE IF EXISTS invitations;
+*/
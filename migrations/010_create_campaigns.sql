@@ -0,0 +1,106 @@
+-- Up Migration: 創建外展活動、報名與報名歷史的資料表
+
+-- 'campaigns' 透過連結帳號執行的多步驟外展活動
+CREATE TABLE campaigns (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+
+    -- 建立活動的使用者
+    user_email VARCHAR(255) NOT NULL,
+    -- 執行步驟的 Unipile account_id
+    account_id VARCHAR(255) NOT NULL,
+
+    name VARCHAR(255) NOT NULL,
+    -- active 或 paused
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    -- 步驟的 JSON 陣列: [{"action": "invite|message", "delay_hours": 0, "text": "..."}]
+    steps TEXT NOT NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_campaigns_user_email ON campaigns(user_email);
+
+CREATE TRIGGER update_campaign_updated_at
+BEFORE UPDATE ON campaigns
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- 'enrollments' 每位對象在活動中的進度
+CREATE TABLE enrollments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+
+    campaign_id UUID NOT NULL,
+    -- 與活動相同的 account_id，用於比對對方的回覆
+    account_id VARCHAR(255) NOT NULL,
+    -- 對方的 LinkedIn provider id
+    provider_id VARCHAR(255) NOT NULL,
+    name VARCHAR(255),
+
+    -- active、completed、replied、failed 或 stopped
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    -- 下一個要執行的步驟 (從 0 開始) 與執行時間
+    current_step INTEGER NOT NULL DEFAULT 0,
+    next_run_at TIMESTAMP WITH TIME ZONE,
+    -- 送出第一則訊息後的 Unipile chat id
+    chat_id VARCHAR(255),
+    -- 目前步驟失敗的次數與最後的錯誤
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_enrollments_campaign
+        FOREIGN KEY(campaign_id)
+        REFERENCES campaigns(id)
+        ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_enrollments_campaign_provider ON enrollments(campaign_id, provider_id);
+CREATE INDEX idx_enrollments_prospect ON enrollments(account_id, provider_id);
+-- 排程器以 status = 'active' AND next_run_at <= now() 取出到期的報名
+CREATE INDEX idx_enrollments_due ON enrollments(status, next_run_at);
+
+CREATE TRIGGER update_enrollment_updated_at
+BEFORE UPDATE ON enrollments
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- 'enrollment_events' 報名狀態機的歷史，只能新增 (沒有 updated_at)
+CREATE TABLE enrollment_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+
+    campaign_id UUID NOT NULL,
+    enrollment_id UUID NOT NULL,
+    provider_id VARCHAR(255),
+
+    -- 相關的步驟與執行的動作 (狀態改變時為空字串)
+    step INTEGER NOT NULL DEFAULT 0,
+    action VARCHAR(20),
+    from_status VARCHAR(20),
+    to_status VARCHAR(20),
+    -- 例如錯誤訊息
+    detail TEXT,
+
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+
+    CONSTRAINT fk_enrollment_events_enrollment
+        FOREIGN KEY(enrollment_id)
+        REFERENCES enrollments(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_enrollment_events_campaign_created ON enrollment_events(campaign_id, created_at);
+CREATE INDEX idx_enrollment_events_enrollment_id ON enrollment_events(enrollment_id);
+
+
+-- Down Migration
+
+/*
+DROP TABLE IF EXISTS enrollment_events;
+DROP TRIGGER IF EXISTS update_enrollment_updated_at ON enrollments;
+DROP TABLE IF EXISTS enrollments;
+DROP TRIGGER IF EXISTS update_campaign_updated_at ON campaigns;
+DROP TABLE IF EXISTS campaigns;
+*/
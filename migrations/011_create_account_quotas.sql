@@ -0,0 +1,50 @@
+-- Up Migration: 創建連結帳號配額設定與使用紀錄的資料表
+
+-- 'account_limits' 每個連結帳號的配額與工作時間，沒有資料時使用 config 的預設值
+CREATE TABLE account_limits (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+
+    -- Unipile account_id
+    account_id VARCHAR(255) UNIQUE NOT NULL,
+
+    -- 工作時間 (帳號時區的 HH:MM，結束時間不包含) 與工作日 (JSON 陣列，例如 ["mon","tue"])
+    timezone VARCHAR(64) NOT NULL,
+    work_start VARCHAR(5) NOT NULL,
+    work_end VARCHAR(5) NOT NULL,
+    work_days TEXT,
+
+    -- 每日與每週的配額，0 代表不限制
+    invite_daily INTEGER NOT NULL,
+    invite_weekly INTEGER NOT NULL,
+    message_daily INTEGER NOT NULL,
+    message_weekly INTEGER NOT NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER update_account_limits_updated_at
+BEFORE UPDATE ON account_limits
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- 'action_usages' 消耗配額的對外動作 (invite、message)，動作失敗時刪除
+CREATE TABLE action_usages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+
+    account_id VARCHAR(255) NOT NULL,
+    action VARCHAR(20) NOT NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_action_usages_account_action_created ON action_usages(account_id, action, created_at);
+
+
+-- Down Migration
+
+/*
+DROP TABLE IF EXISTS action_usages;
+DROP TRIGGER IF EXISTS update_account_limits_updated_at ON account_limits;
+DROP TABLE IF EXISTS account_limits;
+*/
@@ -0,0 +1,37 @@
+-- Up Migration: 創建訊息範本的資料表，並在個人檔案快取中加入名字與姓氏
+
+-- 'message_templates' 使用者儲存的訊息範本 (text/template 語法)
+CREATE TABLE message_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+
+    -- 建立範本的使用者
+    user_email VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    -- body 中使用的變數的 JSON 陣列，例如 ["FirstName", "Sender.Name"]
+    variables TEXT,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_message_templates_user_name ON message_templates(user_email, name);
+
+CREATE TRIGGER update_message_template_updated_at
+BEFORE UPDATE ON message_templates
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- 範本的 {{.FirstName}} 與 {{.LastName}} 需要分開的名字與姓氏
+ALTER TABLE contacts ADD COLUMN first_name VARCHAR(255);
+ALTER TABLE contacts ADD COLUMN last_name VARCHAR(255);
+
+
+-- Down Migration
+
+/*
+ALTER TABLE contacts DROP COLUMN IF EXISTS last_name;
+ALTER TABLE contacts DROP COLUMN IF EXISTS first_name;
+DROP TRIGGER IF EXISTS update_message_template_updated_at ON message_templates;
+DROP TABLE IF EXISTS message_templates;
+*/
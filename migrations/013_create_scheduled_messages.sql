@@ -0,0 +1,48 @@
+-- Up Migration: 創建排程訊息的資料表
+
+-- 'scheduled_messages' 在指定時間透過連結帳號送出的訊息
+CREATE TABLE scheduled_messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+
+    -- 建立排程的使用者與送出訊息的 Unipile account_id
+    user_email VARCHAR(255) NOT NULL,
+    account_id VARCHAR(255) NOT NULL,
+    -- 送到既有的對話 (chat_id) 或以對方的 provider id 開始對話，兩者擇一
+    chat_id VARCHAR(255),
+    provider_id VARCHAR(255),
+    text TEXT NOT NULL,
+
+    send_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    -- 建立時指定的對方時區，僅供顯示
+    timezone VARCHAR(64),
+
+    -- scheduled, sending, sent, failed 或 canceled
+    status VARCHAR(20) NOT NULL DEFAULT 'scheduled',
+    -- 下一次送出的時間，sending 時為租約到期時間，結束後為 NULL
+    next_attempt_at TIMESTAMP WITH TIME ZONE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    -- 送出後的 Unipile message id
+    message_id VARCHAR(255),
+    sent_at TIMESTAMP WITH TIME ZONE,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_scheduled_messages_user_send ON scheduled_messages(user_email, send_at);
+-- worker 以 (status, next_attempt_at) 取出到期的訊息
+CREATE INDEX idx_scheduled_messages_due ON scheduled_messages(status, next_attempt_at);
+
+CREATE TRIGGER update_scheduled_message_updated_at
+BEFORE UPDATE ON scheduled_messages
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+
+-- Down Migration
+
+/*
+DROP TRIGGER IF EXISTS update_scheduled_message_updated_at ON scheduled_messages;
+DROP TABLE IF EXISTS scheduled_messages;
+*/
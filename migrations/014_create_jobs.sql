@@ -0,0 +1,43 @@
+-- Up Migration: 創建背景工作佇列的資料表
+
+-- 'jobs' 由 internal/jobs 的 worker 依佇列取出執行的背景工作
+CREATE TABLE jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+
+    queue VARCHAR(64) NOT NULL,
+    -- 工作類型，決定由哪個 handler 執行
+    type VARCHAR(255) NOT NULL,
+    payload TEXT NOT NULL,
+    -- 相同 key 的工作同時只會有一個在等待或執行中
+    unique_key VARCHAR(255),
+
+    -- queued, running, succeeded 或 dead
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    -- 下一次執行的時間，running 時為租約到期時間
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    last_error TEXT,
+    finished_at TIMESTAMP WITH TIME ZONE,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- worker 以 (queue, status, run_at) 取出到期的工作
+CREATE INDEX idx_jobs_due ON jobs(queue, status, run_at);
+CREATE UNIQUE INDEX idx_jobs_unique_key ON jobs(unique_key)
+WHERE unique_key <> '' AND status IN ('queued', 'running');
+
+CREATE TRIGGER update_job_updated_at
+BEFORE UPDATE ON jobs
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+
+-- Down Migration
+
+/*
+DROP TRIGGER IF EXISTS update_job_updated_at ON jobs;
+DROP TABLE IF EXISTS jobs;
+*/
@@ -0,0 +1,40 @@
+-- Up Migration: 創建定期工作執行紀錄的資料表，並記錄連結帳號在 Unipile 上的狀態
+
+-- 'task_runs' 每個定期工作 (internal/cron) 最後一次的執行
+CREATE TABLE task_runs (
+    name VARCHAR(100) PRIMARY KEY NOT NULL,
+    -- 執行時的 cron 運算式
+    schedule VARCHAR(100) NOT NULL,
+    -- 這次執行對應的排程時間，其他程序以此略過同一次排程
+    scheduled_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    -- running, succeeded 或 failed
+    status VARCHAR(20) NOT NULL,
+    result TEXT,
+    error TEXT,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    -- 執行的程序所在的主機
+    host VARCHAR(255),
+
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER update_task_run_updated_at
+BEFORE UPDATE ON task_runs
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- Unipile 上帳號的狀態 (OK、CREDENTIALS 等)，由 refresh_account_status 定期更新
+ALTER TABLE unipile_accounts ADD COLUMN status VARCHAR(50);
+ALTER TABLE unipile_accounts ADD COLUMN status_checked_at TIMESTAMP WITH TIME ZONE;
+
+
+-- Down Migration
+
+/*
+ALTER TABLE unipile_accounts DROP COLUMN IF EXISTS status_checked_at;
+ALTER TABLE unipile_accounts DROP COLUMN IF EXISTS status;
+DROP TRIGGER IF EXISTS update_task_run_updated_at ON task_runs;
+DROP TABLE IF EXISTS task_runs;
+*/
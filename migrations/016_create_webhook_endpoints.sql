@@ -0,0 +1,76 @@
+-- Up Migration: 創建對外 webhook 的接收網址與送出紀錄的資料表
+
+-- 'webhook_endpoints' 使用者設定的 webhook 接收網址
+CREATE TABLE webhook_endpoints (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+    user_email VARCHAR(255) NOT NULL,
+    url TEXT NOT NULL,
+    description TEXT,
+    -- 訂閱的事件類型 (JSON 陣列)，例如 ["account.connected", "message.received"]
+    events TEXT NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+
+    -- 簽章密鑰以 envelope 加密保存，與 unipile_credentials 相同
+    key_version INTEGER NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    secret_ciphertext BYTEA NOT NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_webhook_endpoints_user_email ON webhook_endpoints(user_email);
+
+CREATE TRIGGER update_webhook_endpoint_updated_at
+BEFORE UPDATE ON webhook_endpoints
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- 'webhook_deliveries' 每次事件送出的紀錄，失敗時由背景工作重試
+CREATE TABLE webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+    endpoint_id UUID NOT NULL,
+    -- 手動重送的紀錄與原本的紀錄有相同的 event_id
+    event_id UUID NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    payload TEXT NOT NULL,
+
+    -- pending, succeeded 或 failed
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    -- 最後一次嘗試的 HTTP 狀態碼與回應內容 (截斷)
+    response_status INTEGER,
+    response_body TEXT,
+    error TEXT,
+    duration_ms BIGINT,
+    redelivery_of UUID,
+    last_attempt_at TIMESTAMP WITH TIME ZONE,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_webhook_deliveries_endpoint
+        FOREIGN KEY(endpoint_id)
+        REFERENCES webhook_endpoints(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webhook_deliveries_endpoint ON webhook_deliveries(endpoint_id, created_at);
+-- 同一個事件只會送到同一個 endpoint 一次 (手動重送除外)，重複觸發的事件 (例如補同步) 會被略過
+CREATE UNIQUE INDEX idx_webhook_deliveries_event ON webhook_deliveries(endpoint_id, event_id) WHERE redelivery_of IS NULL;
+
+CREATE TRIGGER update_webhook_delivery_updated_at
+BEFORE UPDATE ON webhook_deliveries
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+
+-- Down Migration
+
+/*
+DROP TRIGGER IF EXISTS update_webhook_delivery_updated_at ON webhook_deliveries;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TRIGGER IF EXISTS update_webhook_endpoint_updated_at ON webhook_endpoints;
+DROP TABLE IF EXISTS webhook_endpoints;
+*/
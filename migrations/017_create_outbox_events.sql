@@ -0,0 +1,32 @@
+-- Up Migration: 創建 transactional outbox 的資料表
+
+-- 'outbox_events' 與資料變更在同一個交易中寫入的事件，由 internal/outbox 的 relay 發布到 EventBus
+CREATE TABLE outbox_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+
+    -- 事件類型，例如 account.connected
+    topic VARCHAR(255) NOT NULL,
+    -- 事件所屬的資料，例如 account_id
+    key VARCHAR(255),
+    payload TEXT NOT NULL,
+
+    -- 下一次發布的時間，relay 取出後為租約到期時間
+    available_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    published_at TIMESTAMP WITH TIME ZONE,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- relay 只會查詢尚未發布的事件
+CREATE INDEX idx_outbox_events_pending ON outbox_events(available_at) WHERE published_at IS NULL;
+-- purge_outbox 依發布時間刪除
+CREATE INDEX idx_outbox_events_published_at ON outbox_events(published_at);
+
+
+-- Down Migration
+
+/*
+DROP TABLE IF EXISTS outbox_events;
+*/
@@ -0,0 +1,17 @@
+-- Up Migration: 記錄資料庫結構的版本，/readyz 以此確認資料庫已遷移到程式需要的版本
+
+-- 與 golang-migrate 相同的格式：只有一列，version 為最後套用的 migration 編號
+CREATE TABLE schema_migrations (
+    version BIGINT PRIMARY KEY NOT NULL,
+    -- 遷移中途失敗時為 true，需要人工處理
+    dirty BOOLEAN NOT NULL
+);
+
+INSERT INTO schema_migrations (version, dirty) VALUES (18, false);
+
+
+-- Down Migration
+
+/*
+DROP TABLE IF EXISTS schema_migrations;
+*/
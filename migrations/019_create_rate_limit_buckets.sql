@@ -0,0 +1,23 @@
+-- Up Migration: 創建限流使用的 token bucket 資料表 (rate_limit.store 為 postgres 時使用)
+
+-- 'rate_limit_buckets' 每個限流鍵 (policy 與 IP、使用者或 API key) 一列
+CREATE TABLE rate_limit_buckets (
+    key VARCHAR(255) PRIMARY KEY NOT NULL,
+    -- updated_at 時剩下的 token
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    -- 補滿的時間，之後與不存在相同，由 purge_rate_limits 定期刪除
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_rate_limit_buckets_expires_at ON rate_limit_buckets(expires_at);
+
+UPDATE schema_migrations SET version = 19;
+
+
+-- Down Migration
+
+/*
+DROP TABLE IF EXISTS rate_limit_buckets;
+UPDATE schema_migrations SET version = 18;
+*/
@@ -0,0 +1,15 @@
+-- Up Migration: 排程訊息的租約 id
+
+-- 每次取出 (ClaimDue) 時產生新的 lease_id，記錄送出結果時以它確認訊息仍由同一次取出持有，
+-- 避免租約到期後被其他程序重新取出時，原本的程序覆寫結果
+ALTER TABLE scheduled_messages ADD COLUMN lease_id UUID;
+
+UPDATE schema_migrations SET version = 20;
+
+
+-- Down Migration
+
+/*
+ALTER TABLE scheduled_messages DROP COLUMN IF EXISTS lease_id;
+UPDATE schema_migrations SET version = 19;
+*/
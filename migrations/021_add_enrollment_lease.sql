@@ -0,0 +1,15 @@
+-- Up Migration: 報名的租約 id
+
+-- 每次取出 (ClaimDue) 時產生新的 lease_id，記錄步驟結果時以它確認報名仍由同一次取出持有，
+-- 避免租約到期後被其他程序重新取出時，兩個程序都執行同一個步驟並覆寫彼此的結果
+ALTER TABLE enrollments ADD COLUMN lease_id UUID;
+
+UPDATE schema_migrations SET version = 21;
+
+
+-- Down Migration
+
+/*
+ALTER TABLE enrollments DROP COLUMN IF EXISTS lease_id;
+UPDATE schema_migrations SET version = 20;
+*/
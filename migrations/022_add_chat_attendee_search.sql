@@ -0,0 +1,20 @@
+-- Up Migration: 對話參與者名稱的全文搜尋
+
+-- 參與者名稱的 tsvector，與 messages.search_vector 一樣使用 'simple' 設定
+-- 搜尋時先分別以兩個 GIN index 找出內文符合與參與者名稱符合的訊息，再合併 (UNION)
+ALTER TABLE chat_attendees ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    to_tsvector('simple', coalesce(name, ''))
+) STORED;
+
+CREATE INDEX idx_chat_attendees_search ON chat_attendees USING GIN (search_vector);
+
+UPDATE schema_migrations SET version = 22;
+
+
+-- Down Migration
+
+/*
+DROP INDEX IF EXISTS idx_chat_attendees_search;
+ALTER TABLE chat_attendees DROP COLUMN IF EXISTS search_vector;
+UPDATE schema_migrations SET version = 21;
+*/
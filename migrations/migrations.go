@@ -0,0 +1,9 @@
+// Package migrations 將資料庫遷移的 SQL 檔案嵌入程式，讓程式知道自己需要的結構版本
+package migrations
+
+import "embed"
+
+// Files 為本目錄中的所有遷移檔案 (NNN_description.sql)
+//
+//go:embed *.sql
+var Files embed.FS
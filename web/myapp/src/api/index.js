@@ -57,6 +57,18 @@ api.interceptors.response.use(response => {
     return Promise.reject(error);
 });
 
+// ----------------------------------------------------
+// Idempotency-Key
+// ----------------------------------------------------
+
+export function newIdempotencyKey() {
+    return crypto.randomUUID();
+}
+
+function idempotencyHeaders(key) {
+    return key ? { headers: { 'Idempotency-Key': key } } : {};
+}
+
 // ----------------------------------------------------
 // 服務定義
 // ----------------------------------------------------
@@ -78,9 +90,10 @@ export const authService = {
 
     getAccounts: () => api.get('/api/unipile'),
     
-    connectLinkedInBasic: (username, password) => api.post('/api/unipile/linkedin/basic', { username, password }),
+    // idempotencyKey 讓重複送出 (例如連點兩下) 的請求只會被執行一次
+    connectLinkedInBasic: (username, password, idempotencyKey) => api.post('/api/unipile/linkedin/basic', { username, password }, idempotencyHeaders(idempotencyKey)),
     
-    connectLinkedInCookie: (accessToken, userAgent) => api.post('/api/unipile/linkedin/cookie', { access_token: accessToken, user_agent: userAgent }),
+    connectLinkedInCookie: (accessToken, userAgent, idempotencyKey) => api.post('/api/unipile/linkedin/cookie', { access_token: accessToken, user_agent: userAgent }, idempotencyHeaders(idempotencyKey)),
     
-    solveCheckpoint: (accountId, code) => api.post('/api/unipile/linkedin/checkpoint', { account_id: accountId, code: code }),
+    solveCheckpoint: (accountId, code, idempotencyKey) => api.post('/api/unipile/linkedin/checkpoint', { account_id: accountId, code: code }, idempotencyHeaders(idempotencyKey)),
 };
\ No newline at end of file